@@ -1,12 +1,30 @@
 package agent
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"flashcat.cloud/categraf/agent/events"
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/writer"
 )
 
 type Agent struct {
 	agents []AgentModule
+
+	mu      sync.RWMutex
+	running bool
+	// modules failing to start, by type
+	failed map[string]error
 }
 
 // AgentModule is the interface for agent modules
@@ -36,21 +54,29 @@ func NewAgent() (*Agent, error) {
 
 func (a *Agent) Start() {
 	log.Println("I! agent starting")
+	failed := make(map[string]error)
 	for _, agent := range a.agents {
 		if agent == nil {
 			continue
 		}
 		if err := agent.Start(); err != nil {
 			log.Printf("E! start [%T] err: [%+v]", agent, err)
+			failed[fmt.Sprintf("%T", agent)] = err
 		} else {
 			log.Printf("I! [%T] started", agent)
 		}
 	}
+	a.mu.Lock()
+	a.running, a.failed = true, failed
+	a.mu.Unlock()
 	log.Println("I! agent started")
 }
 
 func (a *Agent) Stop() {
 	log.Println("I! agent stopping")
+	a.mu.Lock()
+	a.running = false
+	a.mu.Unlock()
 	for _, agent := range a.agents {
 		if agent == nil {
 			continue
@@ -64,9 +90,58 @@ func (a *Agent) Stop() {
 	log.Println("I! agent stopped")
 }
 
+// Health returns nil when the agent is running, all its modules started and the writer
+// queue is not full
+func (a *Agent) Health() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if !a.running {
+		return errors.New("agent is not running")
+	}
+	if len(a.failed) > 0 {
+		names := make([]string, 0, len(a.failed))
+		for name, err := range a.failed {
+			names = append(names, fmt.Sprintf("%s: %v", name, err))
+		}
+		sort.Strings(names)
+		return fmt.Errorf("modules failed to start: %s", strings.Join(names, "; "))
+	}
+	if q := writer.QueueMetrics(); q.QueueSize >= uint64(config.Config.WriterOpt.ChanSize) {
+		return fmt.Errorf("writer queue is full(%d), the samples are dropped", q.QueueSize)
+	}
+	return nil
+}
+
 func (a *Agent) Reload() {
 	log.Println("I! agent reloading")
 	a.Stop()
 	a.Start()
 	log.Println("I! agent reloaded")
+
+	digest, err := configDigest(config.Config.ConfigDir)
+	if err != nil {
+		log.Println("W! failed to compute digest of configs:", err)
+	}
+	events.Publish(events.ConfigReloaded, map[string]string{"config_dir": config.Config.ConfigDir, "digest": digest})
+}
+
+// configDigest is the sha256 of the paths and contents of all files in the config dir
+func configDigest(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(path))
+		h.Write(bs)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
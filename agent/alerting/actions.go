@@ -0,0 +1,221 @@
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/cmdx"
+)
+
+const (
+	defaultFileName     = "./alerts/alerts.jsonl"
+	defaultMaxSize      = 100
+	defaultMaxBackups   = 5
+	defaultExecTimeout  = 10 * time.Second
+	defaultMaxPerMinute = 10
+	defaultHookTimeout  = 5 * time.Second
+)
+
+// action is run by the dispatch goroutine for every notification of the rules using it
+type action interface {
+	Name() string
+	Do(*Notification) error
+}
+
+func newActions(c *config.Alerting) (map[string]action, error) {
+	actions := make(map[string]action)
+	if c.File != nil {
+		actions["file"] = newFileAction(c.File)
+	}
+	if c.Exec != nil {
+		a, err := newExecAction(c.Exec)
+		if err != nil {
+			return nil, err
+		}
+		actions["exec"] = a
+	}
+	if c.Webhook != nil {
+		a, err := newWebhookAction(c.Webhook)
+		if err != nil {
+			return nil, err
+		}
+		actions["webhook"] = a
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("alerting has no action configured, one of alerting.file, alerting.exec or alerting.webhook is required")
+	}
+	return actions, nil
+}
+
+type fileAction struct {
+	w *lumberjack.Logger
+}
+
+func newFileAction(c *config.AlertFile) *fileAction {
+	if c.FileName == "" {
+		c.FileName = defaultFileName
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultMaxSize
+	}
+	if c.MaxBackups <= 0 {
+		c.MaxBackups = defaultMaxBackups
+	}
+	return &fileAction{
+		w: &lumberjack.Logger{
+			Filename:   c.FileName,
+			MaxSize:    c.MaxSize,
+			MaxBackups: c.MaxBackups,
+			LocalTime:  true,
+		},
+	}
+}
+
+func (a *fileAction) Name() string {
+	return "file"
+}
+
+func (a *fileAction) Do(n *Notification) error {
+	bs, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = a.w.Write(append(bs, '\n'))
+	return err
+}
+
+// execAction runs the hook script, a flapping rule must not fork a process per gather so
+// the runs are limited to max_per_minute
+type execAction struct {
+	command []string
+	timeout time.Duration
+	limiter *rate.Limiter
+}
+
+func newExecAction(c *config.AlertExec) (*execAction, error) {
+	if len(c.Command) == 0 {
+		return nil, fmt.Errorf("alerting.exec.command is required")
+	}
+	a := &execAction{
+		command: c.Command,
+		timeout: time.Duration(c.Timeout),
+	}
+	if a.timeout <= 0 {
+		a.timeout = defaultExecTimeout
+	}
+	if c.MaxPerMinute <= 0 {
+		c.MaxPerMinute = defaultMaxPerMinute
+	}
+	a.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(c.MaxPerMinute)), c.MaxPerMinute)
+	return a, nil
+}
+
+func (a *execAction) Name() string {
+	return "exec"
+}
+
+func (a *execAction) Do(n *Notification) error {
+	if !a.limiter.Allow() {
+		return fmt.Errorf("rate limited, %s notification dropped", n.Status)
+	}
+	bs, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(a.command[0], a.command[1:]...)
+	cmd.Stdin = bytes.NewReader(bs)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.Env = append(os.Environ(),
+		"ALERT_RULE="+n.Rule,
+		"ALERT_STATUS="+string(n.Status),
+		"ALERT_METRIC="+n.Metric,
+		"ALERT_LABELS="+formatLabels(n.Labels),
+		fmt.Sprintf("ALERT_VALUE=%v", n.Value),
+		fmt.Sprintf("ALERT_THRESHOLD=%v", n.Threshold),
+		"ALERT_HOST="+n.Host,
+	)
+	err, timeout := cmdx.RunTimeout(cmd, a.timeout)
+	if timeout {
+		return fmt.Errorf("%s timeout after %s", a.command[0], a.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v - %s", a.command[0], err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+type webhookAction struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookAction(c *config.AlertWebhook) (*webhookAction, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("alerting.webhook.url is required")
+	}
+	timeout := time.Duration(c.Timeout)
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	return &webhookAction{
+		url:     c.URL,
+		headers: c.Headers,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (a *webhookAction) Name() string {
+	return "webhook"
+}
+
+func (a *webhookAction) Do(n *Notification) error {
+	bs, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(bs))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s responded %d: %s", a.url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// formatLabels returns the labels as sorted k=v pairs separated by commas
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
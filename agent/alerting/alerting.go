@@ -0,0 +1,326 @@
+package alerting
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/types"
+)
+
+// Status is the state of a series of a rule, only the firing and resolved transitions
+// are notified
+type Status string
+
+const (
+	Pending  Status = "pending"
+	Firing   Status = "firing"
+	Resolved Status = "resolved"
+)
+
+const (
+	defaultResolveTimeout = 5 * time.Minute
+	defaultBufferSize     = 1024
+)
+
+type Notification struct {
+	Time      time.Time         `json:"time"`
+	Rule      string            `json:"rule"`
+	Status    Status            `json:"status"`
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels"`
+	Value     float64           `json:"value"`
+	Op        string            `json:"op"`
+	Threshold float64           `json:"threshold"`
+	ActiveAt  time.Time         `json:"active_at"`
+	Host      string            `json:"host"`
+
+	actions []action
+}
+
+type rule struct {
+	*config.AlertRule
+	matchers []*labels.Matcher
+	compare  func(value, threshold float64) bool
+	actions  []action
+}
+
+// series is the state of a series matching a rule
+type series struct {
+	metric   string
+	labels   map[string]string
+	value    float64
+	status   Status
+	activeAt time.Time
+	lastSeen time.Time
+}
+
+type evaluator struct {
+	rules          []*rule
+	resolveTimeout time.Duration
+	now            func() time.Time
+	ch             chan *Notification
+
+	sync.Mutex
+	// by rule, then by series
+	states []map[string]*series
+}
+
+var e *evaluator
+
+// Init compiles the rules and starts the actions, Evaluate is a no-op when alerting is
+// not enabled
+func Init(c *config.Alerting) error {
+	if c == nil || !c.Enable {
+		return nil
+	}
+
+	actions, err := newActions(c)
+	if err != nil {
+		return err
+	}
+	ev, err := newEvaluator(c, actions, time.Now)
+	if err != nil {
+		return err
+	}
+	e = ev
+	go e.dispatch()
+	go e.sweepLoop()
+	log.Printf("I! edge alerting enabled with %d rules", len(e.rules))
+	return nil
+}
+
+func newEvaluator(c *config.Alerting, actions map[string]action, now func() time.Time) (*evaluator, error) {
+	ev := &evaluator{
+		resolveTimeout: time.Duration(c.ResolveTimeout),
+		now:            now,
+	}
+	if ev.resolveTimeout <= 0 {
+		ev.resolveTimeout = defaultResolveTimeout
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaultBufferSize
+	}
+	ev.ch = make(chan *Notification, c.BufferSize)
+
+	for i, r := range c.Rules {
+		compiled, err := compile(r, actions)
+		if err != nil {
+			return nil, fmt.Errorf("alerting rule #%d %s: %v", i, r.Name, err)
+		}
+		ev.rules = append(ev.rules, compiled)
+		ev.states = append(ev.states, make(map[string]*series))
+	}
+	return ev, nil
+}
+
+func compile(r *config.AlertRule, actions map[string]action) (*rule, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	matchers, err := parser.ParseMetricSelector(r.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %s: %v", r.Selector, err)
+	}
+
+	ret := &rule{AlertRule: r, matchers: matchers}
+	switch r.Op {
+	case ">":
+		ret.compare = func(v, t float64) bool { return v > t }
+	case ">=":
+		ret.compare = func(v, t float64) bool { return v >= t }
+	case "<":
+		ret.compare = func(v, t float64) bool { return v < t }
+	case "<=":
+		ret.compare = func(v, t float64) bool { return v <= t }
+	case "==":
+		ret.compare = func(v, t float64) bool { return v == t }
+	case "!=":
+		ret.compare = func(v, t float64) bool { return v != t }
+	default:
+		return nil, fmt.Errorf("invalid op %q, one of >, >=, <, <=, == or != expected", r.Op)
+	}
+
+	if len(r.Actions) == 0 {
+		names := make([]string, 0, len(actions))
+		for name := range actions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			ret.actions = append(ret.actions, actions[name])
+		}
+	}
+	for _, name := range r.Actions {
+		a, has := actions[name]
+		if !has {
+			return nil, fmt.Errorf("action %s is not configured", name)
+		}
+		ret.actions = append(ret.actions, a)
+	}
+	return ret, nil
+}
+
+// Evaluate updates the state of the series of the rules matching the samples, it is
+// called with the samples handed to the writers
+func Evaluate(samples []*types.Sample) {
+	if e == nil {
+		return
+	}
+	e.evaluate(samples)
+}
+
+func (ev *evaluator) evaluate(samples []*types.Sample) {
+	now := ev.now()
+	ev.Lock()
+	defer ev.Unlock()
+	for _, s := range samples {
+		value, err := conv.ToFloat64(s.Value)
+		if err != nil || math.IsNaN(value) {
+			continue
+		}
+		for i, r := range ev.rules {
+			if !r.matches(s) {
+				continue
+			}
+			ev.update(i, r, s, value, now)
+		}
+	}
+}
+
+func (ev *evaluator) update(i int, r *rule, s *types.Sample, value float64, now time.Time) {
+	key := seriesKey(s)
+	st := ev.states[i][key]
+	if !r.compare(value, r.Threshold) {
+		if st != nil {
+			delete(ev.states[i], key)
+			if st.status == Firing {
+				st.value = value
+				ev.notify(r, st, Resolved, now)
+			}
+		}
+		return
+	}
+
+	if st == nil {
+		st = &series{metric: s.Metric, labels: s.Labels, status: Pending, activeAt: now}
+		ev.states[i][key] = st
+	}
+	st.value = value
+	st.lastSeen = now
+	if st.status == Pending && now.Sub(st.activeAt) >= time.Duration(r.For) {
+		st.status = Firing
+		ev.notify(r, st, Firing, now)
+	}
+}
+
+// sweep resolves the firing series not gathered for resolve_timeout, e.g. the input
+// failing or the series gone
+func (ev *evaluator) sweep() {
+	now := ev.now()
+	ev.Lock()
+	defer ev.Unlock()
+	for i, r := range ev.rules {
+		for key, st := range ev.states[i] {
+			if now.Sub(st.lastSeen) < ev.resolveTimeout {
+				continue
+			}
+			delete(ev.states[i], key)
+			if st.status == Firing {
+				ev.notify(r, st, Resolved, now)
+			}
+		}
+	}
+}
+
+func (ev *evaluator) sweepLoop() {
+	interval := ev.resolveTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	for range time.Tick(interval) {
+		ev.sweep()
+	}
+}
+
+// notify never blocks, the notification is dropped when the actions lag behind
+func (ev *evaluator) notify(r *rule, st *series, status Status, now time.Time) {
+	lbs := make(map[string]string, len(st.labels)+len(r.Labels))
+	for k, v := range st.labels {
+		lbs[k] = v
+	}
+	for k, v := range r.Labels {
+		lbs[k] = v
+	}
+	n := &Notification{
+		Time:      now,
+		Rule:      r.Name,
+		Status:    status,
+		Metric:    st.metric,
+		Labels:    lbs,
+		Value:     st.value,
+		Op:        r.Op,
+		Threshold: r.Threshold,
+		ActiveAt:  st.activeAt,
+		actions:   r.actions,
+	}
+	select {
+	case ev.ch <- n:
+	default:
+		log.Printf("W! alerting notification buffer is full, %s notification of rule %s dropped", status, r.Name)
+	}
+}
+
+func (ev *evaluator) dispatch() {
+	for n := range ev.ch {
+		n.Host = config.Config.GetHostname()
+		for _, a := range n.actions {
+			if err := a.Do(n); err != nil {
+				log.Printf("E! failed to run alerting action %s for rule %s: %v", a.Name(), n.Rule, err)
+			}
+		}
+	}
+}
+
+// matches reports whether the sample is selected, the metric name is matched as __name__
+func (r *rule) matches(s *types.Sample) bool {
+	for _, m := range r.matchers {
+		var value string
+		if m.Name == labels.MetricName {
+			value = s.Metric
+		} else {
+			value = s.Labels[m.Name]
+		}
+		if !m.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func seriesKey(s *types.Sample) string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(s.Metric)
+	for _, k := range keys {
+		b.WriteByte(0xff)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(s.Labels[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package alerting
+
+import (
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+type recorder struct {
+	notifications []*Notification
+}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Do(n *Notification) error {
+	r.notifications = append(r.notifications, n)
+	return nil
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := &config.Alerting{
+		Enable:         true,
+		ResolveTimeout: config.Duration(time.Minute),
+		Rules: []*config.AlertRule{{
+			Name:      "disk_full",
+			Selector:  `disk_used_percent{path=~"/|/data"}`,
+			Op:        ">=",
+			Threshold: 90,
+			For:       config.Duration(30 * time.Second),
+			Labels:    map[string]string{"severity": "critical"},
+		}},
+	}
+	rec := &recorder{}
+	ev, err := newEvaluator(c, map[string]action{"recorder": rec}, func() time.Time { return now })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gather := func(path string, value float64) {
+		ev.evaluate([]*types.Sample{types.NewSample("", "disk_used_percent", value, map[string]string{"path": path})})
+	}
+	drain := func() []*Notification {
+		var ret []*Notification
+		for {
+			select {
+			case n := <-ev.ch:
+				ret = append(ret, n)
+			default:
+				return ret
+			}
+		}
+	}
+	status := func() Status {
+		st := ev.states[0]["disk_used_percent\xffpath=/"]
+		if st == nil {
+			return ""
+		}
+		return st.status
+	}
+
+	gather("/", 95)
+	gather("/boot", 99)
+	if status() != Pending || len(ev.states[0]) != 1 || len(drain()) != 0 {
+		t.Fatalf("expected / pending, got %q, %d series", status(), len(ev.states[0]))
+	}
+
+	now = now.Add(15 * time.Second)
+	gather("/", 96)
+	if status() != Pending || len(drain()) != 0 {
+		t.Fatalf("expected / still pending before the for duration, got %q", status())
+	}
+
+	now = now.Add(15 * time.Second)
+	gather("/", 97)
+	ns := drain()
+	if status() != Firing || len(ns) != 1 || ns[0].Status != Firing || ns[0].Value != 97 || ns[0].Labels["severity"] != "critical" {
+		t.Fatalf("expected / firing, got %q, %+v", status(), ns)
+	}
+
+	now = now.Add(15 * time.Second)
+	gather("/", 98)
+	if len(drain()) != 0 {
+		t.Fatal("expected a single firing notification")
+	}
+
+	now = now.Add(15 * time.Second)
+	gather("/", 50)
+	ns = drain()
+	if status() != "" || len(ns) != 1 || ns[0].Status != Resolved || ns[0].Value != 50 {
+		t.Fatalf("expected / resolved, got %q, %+v", status(), ns)
+	}
+
+	// a pending series going back to normal is not notified
+	gather("/data", 91)
+	gather("/data", 10)
+	if len(ev.states[0]) != 0 || len(drain()) != 0 {
+		t.Fatal("expected the pending series to be forgotten")
+	}
+
+	// a firing series no longer gathered is resolved after resolve_timeout
+	gather("/", 95)
+	now = now.Add(30 * time.Second)
+	gather("/", 95)
+	if len(drain()) != 1 {
+		t.Fatal("expected / firing")
+	}
+	now = now.Add(30 * time.Second)
+	ev.sweep()
+	if status() != Firing {
+		t.Fatal("expected / firing before resolve_timeout")
+	}
+	now = now.Add(30 * time.Second)
+	ev.sweep()
+	ns = drain()
+	if status() != "" || len(ns) != 1 || ns[0].Status != Resolved {
+		t.Fatalf("expected / resolved by the sweep, got %q, %+v", status(), ns)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	actions := map[string]action{"recorder": &recorder{}}
+	for _, r := range []*config.AlertRule{
+		{Name: "", Selector: "up", Op: "=="},
+		{Name: "invalid_selector", Selector: "up{", Op: "=="},
+		{Name: "invalid_op", Selector: "up", Op: "=~"},
+		{Name: "unknown_action", Selector: "up", Op: "==", Actions: []string{"webhook"}},
+	} {
+		if _, err := compile(r, actions); err == nil {
+			t.Fatalf("expected rule %q to be invalid", r.Name)
+		}
+	}
+	r, err := compile(&config.AlertRule{Name: "down", Selector: "up", Op: "=="}, actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.actions) != 1 {
+		t.Fatalf("expected all the actions by default, got %d", len(r.actions))
+	}
+}
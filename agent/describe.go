@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/cfg"
+	"flashcat.cloud/categraf/types"
+)
+
+type instanceDescription struct {
+	Instance    int         `json:"instance"`
+	Error       string      `json:"error,omitempty"`
+	Description interface{} `json:"description,omitempty"`
+}
+
+// DescribeInput loads the local configuration of the input, inits its instances and writes
+// the descriptions of the instances implementing inputs.Describer as json
+func DescribeInput(name string, w io.Writer) error {
+	creator, has := inputs.InputCreators[name]
+	if !has {
+		return fmt.Errorf("input %s not supported", name)
+	}
+	input := creator()
+	if err := cfg.LoadConfigByDir(path.Join(config.Config.ConfigDir, "input."+name), input); err != nil {
+		return fmt.Errorf("failed to load configuration of input %s: %v", name, err)
+	}
+	if err := input.InitInternalConfig(); err != nil {
+		return fmt.Errorf("failed to init input %s: %v", name, err)
+	}
+	if err := inputs.MayInit(input); err != nil && !errors.Is(err, types.ErrInstancesEmpty) {
+		return fmt.Errorf("failed to init input %s: %v", name, err)
+	}
+
+	instances := inputs.MayGetInstances(input)
+	descriptions := make([]instanceDescription, 0, len(instances))
+	for i, ins := range instances {
+		describer, ok := ins.(inputs.Describer)
+		if !ok {
+			return fmt.Errorf("input %s can not be described", name)
+		}
+		d := instanceDescription{Instance: i}
+		err := ins.InitInternalConfig()
+		if err == nil {
+			err = inputs.MayInit(ins)
+		}
+		if err == nil {
+			d.Description, err = describer.Describe()
+		}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		descriptions = append(descriptions, d)
+	}
+	if len(descriptions) == 0 {
+		return fmt.Errorf("no instances for input %s", name)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(descriptions)
+}
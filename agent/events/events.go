@@ -0,0 +1,197 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// Type is the stable name of an event, parsers rely on these names and
+// on the field names documented next to them, do not rename
+type Type string
+
+const (
+	// fields: input, checksum
+	InputStarted Type = "input.started"
+	// fields: input, checksum
+	InputStopped Type = "input.stopped"
+	// fields: provider, input
+	InputReloaded Type = "input.reloaded"
+	// fields: metric, limit, dropped, published once per cardinality_ttl and metric,
+	// dropped counts the series dropped since the previous event of the metric
+	SeriesLimitTriggered Type = "series_limit.triggered"
+	// fields: config_dir, digest
+	ConfigReloaded Type = "config.reloaded"
+)
+
+const (
+	defaultFileName   = "./events/events.jsonl"
+	defaultMaxSize    = 100
+	defaultMaxBackups = 5
+	defaultBufferSize = 1024
+)
+
+type Event struct {
+	Time   time.Time         `json:"time"`
+	Type   Type              `json:"type"`
+	Host   string            `json:"host"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Sink receives the events in publish order from the bus goroutine
+type Sink interface {
+	Write(*Event) error
+}
+
+type bus struct {
+	ch    chan *Event
+	done  chan struct{}
+	sinks []Sink
+	sync.RWMutex
+}
+
+var (
+	b       *bus
+	dropped uint64
+
+	droppedTotal = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "events_dropped_total",
+		Help: "Number of agent events dropped because the event buffer was full.",
+	}, func() float64 {
+		return float64(atomic.LoadUint64(&dropped))
+	})
+)
+
+func init() {
+	prometheus.MustRegister(droppedTotal)
+}
+
+// Init starts the event bus with the json lines file sink, Publish is a no-op
+// when events are not enabled
+func Init(c *config.Events) error {
+	if c == nil || !c.Enable {
+		return nil
+	}
+
+	if c.FileName == "" {
+		c.FileName = defaultFileName
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultMaxSize
+	}
+	if c.MaxBackups <= 0 {
+		c.MaxBackups = defaultMaxBackups
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = defaultBufferSize
+	}
+
+	b = &bus{
+		ch:   make(chan *Event, c.BufferSize),
+		done: make(chan struct{}),
+		sinks: []Sink{
+			newFileSink(c.FileName, c.MaxSize, c.MaxBackups),
+		},
+	}
+	go b.loop()
+	return nil
+}
+
+// Close stops the event bus once the buffered events are written, Publish is a no-op
+// afterwards. The events must no longer be published while closing
+func Close() {
+	if b == nil {
+		return
+	}
+	closing := b
+	b = nil
+	close(closing.ch)
+	<-closing.done
+}
+
+// AddSink registers another sink, e.g. the writers
+func AddSink(s Sink) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	b.sinks = append(b.sinks, s)
+	b.Unlock()
+}
+
+// Publish never blocks, the event is dropped and counted when the buffer is full
+func Publish(typ Type, fields map[string]string) {
+	if b == nil {
+		return
+	}
+
+	e := &Event{
+		Time:   time.Now(),
+		Type:   typ,
+		Host:   hostname(),
+		Fields: fields,
+	}
+	select {
+	case b.ch <- e:
+	default:
+		atomic.AddUint64(&dropped, 1)
+	}
+}
+
+// hostname returns the hostname of the events, empty until the config and the host
+// info are loaded
+func hostname() string {
+	if config.Config == nil || config.HostInfo == nil {
+		return ""
+	}
+	return config.Config.GetHostname()
+}
+
+// Dropped returns the number of events dropped since start
+func Dropped() uint64 {
+	return atomic.LoadUint64(&dropped)
+}
+
+func (b *bus) loop() {
+	defer close(b.done)
+	for e := range b.ch {
+		b.RLock()
+		for _, s := range b.sinks {
+			if err := s.Write(e); err != nil {
+				log.Printf("E! failed to write event %s to %T: %v", e.Type, s, err)
+			}
+		}
+		b.RUnlock()
+	}
+}
+
+type fileSink struct {
+	w *lumberjack.Logger
+}
+
+func newFileSink(fileName string, maxSize, maxBackups int) *fileSink {
+	return &fileSink{
+		w: &lumberjack.Logger{
+			Filename:   fileName,
+			MaxSize:    maxSize,
+			MaxBackups: maxBackups,
+			LocalTime:  true,
+		},
+	}
+}
+
+func (s *fileSink) Write(e *Event) error {
+	bs, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(bs, '\n'))
+	return err
+}
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// testConfig sets the config read by Publish and restores it after the test
+func testConfig(t *testing.T) {
+	oldConfig, oldHostInfo, oldBus := config.Config, config.HostInfo, b
+	config.Config = &config.ConfigType{}
+	config.HostInfo = &config.HostInfoCache{}
+	t.Cleanup(func() {
+		config.Config, config.HostInfo, b = oldConfig, oldHostInfo, oldBus
+	})
+}
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+func (s *recordingSink) Write(e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *recordingSink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func TestPublishDisabled(t *testing.T) {
+	testConfig(t)
+	b = nil
+	before := Dropped()
+	Publish(InputStarted, map[string]string{"input": "cpu"})
+	if Dropped() != before {
+		t.Fatal("expected Publish to be a no-op without the bus")
+	}
+}
+
+func TestPublishDropped(t *testing.T) {
+	testConfig(t)
+	// the bus loop is not started, the buffer of one event fills up
+	b = &bus{ch: make(chan *Event, 1)}
+	before := Dropped()
+
+	Publish(InputStarted, map[string]string{"input": "cpu"})
+	Publish(InputStopped, map[string]string{"input": "cpu"})
+	Publish(ConfigReloaded, nil)
+	if n := Dropped() - before; n != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", n)
+	}
+
+	e := <-b.ch
+	if e.Type != InputStarted || e.Fields["input"] != "cpu" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	testConfig(t)
+	fileName := filepath.Join(t.TempDir(), "events", "events.jsonl")
+	if err := Init(&config.Events{Enable: true, FileName: fileName}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Close)
+	rs := &recordingSink{}
+	AddSink(rs)
+
+	Publish(InputStarted, map[string]string{"input": "cpu", "checksum": "abc"})
+	Publish(ConfigReloaded, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for rs.len() != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the events were not written to the sinks")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// every sink has written the event once the last sink has
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid json line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 json lines, got %d", len(lines))
+	}
+	fields, _ := lines[0]["fields"].(map[string]interface{})
+	if lines[0]["type"] != "input.started" || fields["input"] != "cpu" || fields["checksum"] != "abc" {
+		t.Fatalf("unexpected first event %v", lines[0])
+	}
+	for _, key := range []string{"time", "host"} {
+		if _, has := lines[0][key]; !has {
+			t.Fatalf("expected the %s of the event, got %v", key, lines[0])
+		}
+	}
+	// the empty fields are omitted
+	if _, has := lines[1]["fields"]; has || lines[1]["type"] != "config.reloaded" {
+		t.Fatalf("unexpected second event %v", lines[1])
+	}
+}
+
+func TestClose(t *testing.T) {
+	testConfig(t)
+	// the host info is not loaded yet
+	config.HostInfo = nil
+	if err := Init(&config.Events{Enable: true, FileName: filepath.Join(t.TempDir(), "events.jsonl")}); err != nil {
+		t.Fatal(err)
+	}
+	rs := &recordingSink{}
+	AddSink(rs)
+	Publish(InputStarted, map[string]string{"input": "cpu"})
+
+	// the buffered events are written on close
+	Close()
+	if rs.len() != 1 || rs.events[0].Host != "" {
+		t.Fatalf("expected one event without host, got %v", rs.events)
+	}
+	before := Dropped()
+	Publish(InputStopped, map[string]string{"input": "cpu"})
+	if rs.len() != 1 || Dropped() != before {
+		t.Fatal("expected Publish to be a no-op after close")
+	}
+	Close()
+}
@@ -19,11 +19,15 @@ import (
 	"flashcat.cloud/categraf/logs/client"
 	"flashcat.cloud/categraf/logs/diagnostic"
 	"flashcat.cloud/categraf/logs/input/container"
+	"flashcat.cloud/categraf/logs/input/docker"
+	"flashcat.cloud/categraf/logs/input/eventlog"
 	"flashcat.cloud/categraf/logs/input/file"
 	"flashcat.cloud/categraf/logs/input/journald"
 	"flashcat.cloud/categraf/logs/input/kubernetes"
 	"flashcat.cloud/categraf/logs/input/listener"
+	"flashcat.cloud/categraf/logs/input/syslog"
 	"flashcat.cloud/categraf/logs/pipeline"
+	"flashcat.cloud/categraf/logs/processor"
 	"flashcat.cloud/categraf/logs/restart"
 	"flashcat.cloud/categraf/logs/status"
 	"flashcat.cloud/categraf/logs/util"
@@ -31,12 +35,15 @@ import (
 	coreconfig "flashcat.cloud/categraf/config"
 	logsconfig "flashcat.cloud/categraf/config/logs"
 	logService "flashcat.cloud/categraf/logs/service"
+	"flashcat.cloud/categraf/pkg/cfg"
+	"flashcat.cloud/categraf/writer"
 )
 
 const (
 	intakeTrackType         = "logs"
 	AgentJSONIntakeProtocol = "agent-json"
 	invalidProcessingRules  = "invalid_global_processing_rules"
+	invalidMetricRules      = "invalid_global_metric_rules"
 )
 
 // LogsAgent represents the data pipeline that collects, decodes,
@@ -57,6 +64,9 @@ type LogsAgent struct {
 	pipelineProvider          pipeline.Provider
 	inputs                    []restart.Restartable
 	diagnosticMessageReceiver *diagnostic.BufferedMessageReceiver
+
+	started     bool
+	metricsStop chan struct{}
 }
 
 // NewLogsAgent returns a new Logs LogsAgent
@@ -81,6 +91,18 @@ func NewLogsAgent() AgentModule {
 		log.Println("E!", errors.New(message))
 		return nil
 	}
+	if err := logsconfig.CompileMetricRules(coreconfig.Config.Logs.GlobalMetricRules); err != nil {
+		message := fmt.Sprintf("Invalid metric rules: %v", err)
+		status.AddGlobalError(invalidMetricRules, message)
+		log.Println("E!", errors.New(message))
+		return nil
+	}
+	processor.SetGlobalMetricRules(coreconfig.Config.Logs.GlobalMetricRules)
+	if err := coreconfig.Config.Logs.RateLimit.Validate(); err != nil {
+		log.Println("E! invalid logs rate_limit, logs are not limited:", err)
+	} else {
+		processor.SetGlobalRateLimit(coreconfig.Config.Logs.RateLimit)
+	}
 
 	sources := logsconfig.NewLogSources()
 	services := logService.NewServices()
@@ -89,7 +111,7 @@ func NewLogsAgent() AgentModule {
 	// setup the auditor
 	// We pass the health handle to the auditor because it's the end of the pipeline and the most
 	// critical part. Arguably it could also be plugged to the destination.
-	auditorTTL := time.Duration(23) * time.Hour
+	auditorTTL := coreconfig.LogRegistryTTL()
 	_, err = os.Stat(coreconfig.GetLogRunPath())
 	if os.IsNotExist(err) {
 		os.MkdirAll(coreconfig.GetLogRunPath(), 0755)
@@ -103,14 +125,34 @@ func NewLogsAgent() AgentModule {
 
 	validatePodContainerID := coreconfig.ValidatePodContainerID()
 	//
-	containerLaunchables := []container.Launchable{
-		{
-			IsAvailable: kubernetes.IsAvailable,
-			Launcher: func() restart.Restartable {
-				return kubernetes.NewLauncher(sources, services, coreconfig.GetContainerCollectAll())
-			},
+	kubernetesLaunchable := container.Launchable{
+		IsAvailable: kubernetes.IsAvailable,
+		Launcher: func() restart.Restartable {
+			return kubernetes.NewLauncher(sources, services, coreconfig.GetContainerCollectAll())
+		},
+	}
+	socketLaunchable := container.Launchable{
+		IsAvailable: docker.IsAvailable,
+		Launcher: func() restart.Restartable {
+			return docker.NewLauncher(sources, pipelineProvider, auditor, coreconfig.GetContainerCollectAll())
+		},
+	}
+	filesLaunchable := container.Launchable{
+		IsAvailable: docker.FilesAvailable,
+		Launcher: func() restart.Restartable {
+			return docker.NewFilesLauncher(sources, coreconfig.GetContainerCollectAll())
 		},
 	}
+	var containerLaunchables []container.Launchable
+	switch coreconfig.GetContainerLogsFrom() {
+	case coreconfig.ContainerLogsFromSocket:
+		containerLaunchables = []container.Launchable{socketLaunchable}
+	case coreconfig.ContainerLogsFromFiles:
+		containerLaunchables = []container.Launchable{filesLaunchable}
+	default:
+		// the log files are the last resort, when neither the kubelet nor the docker API is reachable
+		containerLaunchables = []container.Launchable{kubernetesLaunchable, socketLaunchable, filesLaunchable}
+	}
 
 	// setup the inputs
 	inputs := []restart.Restartable{
@@ -118,6 +160,8 @@ func NewLogsAgent() AgentModule {
 			file.DefaultSleepDuration, validatePodContainerID, time.Duration(time.Duration(coreconfig.FileScanPeriod())*time.Second)),
 		listener.NewLauncher(sources, coreconfig.LogFrameSize(), pipelineProvider),
 		journald.NewLauncher(sources, pipelineProvider, auditor),
+		eventlog.NewLauncher(sources, pipelineProvider, auditor),
+		syslog.NewLauncher(sources, pipelineProvider),
 	}
 	if coreconfig.EnableCollectContainer() {
 		log.Println("collect docker logs...")
@@ -138,7 +182,13 @@ func NewLogsAgent() AgentModule {
 }
 
 func (la *LogsAgent) Start() error {
+	if la.started {
+		reloadMetricRules()
+	}
+	la.started = true
 	la.startInner()
+	la.metricsStop = make(chan struct{})
+	go forwardLogMetrics(la.metricsStop)
 	if coreconfig.EnableCollectContainer() {
 		// collect container all
 		if util.Debug() {
@@ -188,6 +238,10 @@ func (a *LogsAgent) Flush(ctx context.Context) {
 // Stop stops all the elements of the data pipeline
 // in the right order to prevent data loss
 func (a *LogsAgent) Stop() error {
+	if a.metricsStop != nil {
+		close(a.metricsStop)
+		a.metricsStop = nil
+	}
 	inputs := restart.NewParallelStopper()
 	for _, input := range a.inputs {
 		inputs.Add(input)
@@ -245,3 +299,62 @@ func GlobalProcessingRules() ([]*logsconfig.ProcessingRule, error) {
 	}
 	return rules, nil
 }
+
+// reloadMetricRules reads again the metric rules of the config files, so that a reload
+// applies their changes. The previous rules are kept when the new ones are invalid.
+func reloadMetricRules() {
+	fresh := &coreconfig.ConfigType{}
+	if err := cfg.LoadConfigByDir(coreconfig.Config.ConfigDir, fresh); err != nil {
+		log.Println("E! failed to reload log metric rules:", err)
+		return
+	}
+	if err := logsconfig.CompileMetricRules(fresh.Logs.GlobalMetricRules); err != nil {
+		log.Println("E! invalid metric rules, keeping the previous ones:", err)
+	} else {
+		processor.SetGlobalMetricRules(fresh.Logs.GlobalMetricRules)
+	}
+	if err := fresh.Logs.RateLimit.Validate(); err != nil {
+		log.Println("E! invalid logs rate_limit, keeping the previous one:", err)
+	} else {
+		processor.SetGlobalRateLimit(fresh.Logs.RateLimit)
+	}
+
+	items := coreconfig.Config.Logs.Items
+	if len(fresh.Logs.Items) != len(items) {
+		log.Println("W! logs items added or removed, their log_metric_rules are reloaded on restart")
+		return
+	}
+	for i := range items {
+		if items[i] == nil || fresh.Logs.Items[i] == nil {
+			continue
+		}
+		if err := logsconfig.CompileMetricRules(fresh.Logs.Items[i].MetricRules); err != nil {
+			log.Printf("E! invalid log_metric_rules of logs item %d, keeping the previous ones: %v", i, err)
+			continue
+		}
+		items[i].MetricRules = fresh.Logs.Items[i].MetricRules
+	}
+}
+
+// forwardLogMetrics writes the metrics of the metric rules every interval
+func forwardLogMetrics(stop chan struct{}) {
+	ticker := time.NewTicker(coreconfig.GetInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		samples := processor.GatherMetrics()
+		if len(samples) == 0 {
+			continue
+		}
+		now := time.Now()
+		coreconfig.NewTagInheritance().ApplySamples(samples)
+		for _, s := range samples {
+			s.Timestamp = now
+		}
+		writer.WriteSamples("logs", samples)
+	}
+}
@@ -5,6 +5,7 @@ package agent
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -40,6 +41,8 @@ func BuildEndpointsWithConfig(endpointPrefix string, intakeTrackType logsconfig.
 		return buildTCPEndpoints(logsConfig)
 	case "kafka":
 		return buildKafkaEndpoints(logsConfig)
+	case "elasticsearch":
+		return buildElasticsearchEndpoints(logsConfig)
 
 	}
 	return buildTCPEndpoints(logsConfig)
@@ -60,7 +63,7 @@ func buildKafkaEndpoints(logsConfig coreconfig.Logs) (*logsconfig.Endpoints, err
 		BackoffFactor:           2.0,
 		RecoveryInterval:        2,
 		RecoveryReset:           false,
-		Addr:                    logsConfig.SendTo,
+		Addr:                    coreconfig.KafkaBrokers(),
 		Topic:                   logsConfig.Topic,
 	}
 
@@ -71,24 +74,56 @@ func buildKafkaEndpoints(logsConfig coreconfig.Logs) (*logsconfig.Endpoints, err
 		main.Version = logsconfig.EPIntakeVersion1
 	}
 
-	if len(logsConfig.SendTo) != 0 {
-		brokers := strings.Split(logsConfig.SendTo, ",")
+	if addr := coreconfig.KafkaBrokers(); len(addr) != 0 {
+		brokers := strings.Split(addr, ",")
 		if len(brokers) == 0 {
-			return nil, fmt.Errorf("wrong send_to content %s", logsConfig.SendTo)
+			return nil, fmt.Errorf("wrong send_to content %s", addr)
 		}
 		host, port, err := parseAddress(brokers[0])
 		if err != nil {
-			return nil, fmt.Errorf("could not parse %s: %v", logsConfig.SendTo, err)
+			return nil, fmt.Errorf("could not parse %s: %v", addr, err)
 		}
 		main.Host = host
 		main.Port = port
 		main.UseSSL = defaultTLS
 	} else {
-		return nil, fmt.Errorf("empty send_to is not allowed when send_type is kafka")
+		return nil, fmt.Errorf("empty send_to and brokers are not allowed when send_type is kafka")
 	}
 	return NewEndpoints(main, false, "kafka"), nil
 }
 
+func buildElasticsearchEndpoints(logsConfig coreconfig.Logs) (*logsconfig.Endpoints, error) {
+	main := logsconfig.Endpoint{
+		UseCompression:          logsConfig.UseCompression,
+		CompressionLevel:        logsConfig.CompressionLevel,
+		ConnectionResetInterval: 0,
+		BackoffBase:             1.0,
+		BackoffMax:              120.0,
+		BackoffFactor:           2.0,
+		RecoveryInterval:        2,
+		RecoveryReset:           false,
+		Addr:                    coreconfig.ElasticsearchHosts(),
+		UseSSL:                  logsConfig.SendWithTLS,
+	}
+	if main.Addr == "" {
+		return nil, fmt.Errorf("empty send_to and hosts are not allowed when send_type is elasticsearch")
+	}
+	// the first host is displayed in the status
+	first := strings.TrimSpace(strings.Split(main.Addr, ",")[0])
+	if u, err := url.Parse(first); err == nil && u.Host != "" {
+		main.Host = u.Hostname()
+		main.Port, _ = strconv.Atoi(u.Port())
+		main.UseSSL = main.UseSSL || u.Scheme == "https"
+	} else if host, port, err := parseAddress(first); err == nil {
+		main.Host, main.Port = host, port
+	} else {
+		main.Host = first
+	}
+
+	batchWait := time.Duration(logsConfig.BatchWait) * time.Second
+	return NewEndpointsWithBatchSettings(main, false, "elasticsearch", batchWait, coreconfig.BatchConcurrence(), coreconfig.BatchMaxSize(), coreconfig.BatchMaxContentSize()), nil
+}
+
 func buildTCPEndpoints(logsConfig coreconfig.Logs) (*logsconfig.Endpoints, error) {
 	main := logsconfig.Endpoint{
 		APIKey:                  logsConfig.APIKey,
@@ -157,6 +192,25 @@ func BuildHTTPEndpointsWithConfig(endpointPrefix string, intakeTrackType logscon
 		main.Host = logsConfig.SendTo
 		main.UseSSL = defaultTLS
 	}
+	if cfg := logsConfig.HTTP; cfg != nil {
+		switch cfg.Compression {
+		case "":
+		case "gzip":
+			main.UseCompression = true
+		case "none":
+			main.UseCompression = false
+		default:
+			return nil, fmt.Errorf("unsupported logs.http compression %s, should be gzip or none", cfg.Compression)
+		}
+		if cfg.CompressionLevel != 0 {
+			main.CompressionLevel = cfg.CompressionLevel
+		} else if cfg.Compression == "gzip" && main.CompressionLevel == 0 {
+			// level 0 stores the batches without compressing them, 6 is the default level of gzip
+			main.CompressionLevel = 6
+		}
+		// the client certificate is sent over https
+		main.UseSSL = main.UseSSL || cfg.UseTLS
+	}
 
 	batchWait := time.Duration(logsConfig.BatchWait) * time.Second
 	batchMaxConcurrentSend := coreconfig.BatchConcurrence()
@@ -6,9 +6,11 @@ import (
 	"strings"
 	"sync"
 
+	"flashcat.cloud/categraf/agent/events"
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/pkg/cfg"
+	"flashcat.cloud/categraf/pkg/secrets"
 	"flashcat.cloud/categraf/types"
 
 	// auto registry
@@ -17,6 +19,7 @@ import (
 	_ "flashcat.cloud/categraf/inputs/arp_packet"
 	_ "flashcat.cloud/categraf/inputs/bind"
 	_ "flashcat.cloud/categraf/inputs/cadvisor"
+	_ "flashcat.cloud/categraf/inputs/cgroup"
 	_ "flashcat.cloud/categraf/inputs/chrony"
 	_ "flashcat.cloud/categraf/inputs/clickhouse"
 	_ "flashcat.cloud/categraf/inputs/cloudwatch"
@@ -28,11 +31,15 @@ import (
 	_ "flashcat.cloud/categraf/inputs/diskio"
 	_ "flashcat.cloud/categraf/inputs/dns_query"
 	_ "flashcat.cloud/categraf/inputs/docker"
+	_ "flashcat.cloud/categraf/inputs/ebpf_latency"
 	_ "flashcat.cloud/categraf/inputs/elasticsearch"
+	_ "flashcat.cloud/categraf/inputs/ethtool"
 	_ "flashcat.cloud/categraf/inputs/exec"
 	_ "flashcat.cloud/categraf/inputs/filecount"
+	_ "flashcat.cloud/categraf/inputs/filestat"
 	_ "flashcat.cloud/categraf/inputs/googlecloud"
 	_ "flashcat.cloud/categraf/inputs/greenplum"
+	_ "flashcat.cloud/categraf/inputs/gunicorn"
 	_ "flashcat.cloud/categraf/inputs/haproxy"
 	_ "flashcat.cloud/categraf/inputs/http_response"
 	_ "flashcat.cloud/categraf/inputs/influxdb"
@@ -57,6 +64,7 @@ import (
 	_ "flashcat.cloud/categraf/inputs/net_response"
 	_ "flashcat.cloud/categraf/inputs/netstat"
 	_ "flashcat.cloud/categraf/inputs/netstat_filter"
+	_ "flashcat.cloud/categraf/inputs/nettcp"
 	_ "flashcat.cloud/categraf/inputs/nfsclient"
 	_ "flashcat.cloud/categraf/inputs/nginx"
 	_ "flashcat.cloud/categraf/inputs/nginx_upstream_check"
@@ -65,31 +73,42 @@ import (
 	_ "flashcat.cloud/categraf/inputs/ntp"
 	_ "flashcat.cloud/categraf/inputs/nvidia_smi"
 	_ "flashcat.cloud/categraf/inputs/oracle"
+	_ "flashcat.cloud/categraf/inputs/otlp"
+	_ "flashcat.cloud/categraf/inputs/pgbouncer"
 	_ "flashcat.cloud/categraf/inputs/phpfpm"
 	_ "flashcat.cloud/categraf/inputs/ping"
 	_ "flashcat.cloud/categraf/inputs/postgresql"
+	_ "flashcat.cloud/categraf/inputs/powermetrics"
 	_ "flashcat.cloud/categraf/inputs/processes"
 	_ "flashcat.cloud/categraf/inputs/procstat"
 	_ "flashcat.cloud/categraf/inputs/prometheus"
+	_ "flashcat.cloud/categraf/inputs/proxysql"
 	_ "flashcat.cloud/categraf/inputs/rabbitmq"
 	_ "flashcat.cloud/categraf/inputs/redis"
 	_ "flashcat.cloud/categraf/inputs/redis_sentinel"
 	_ "flashcat.cloud/categraf/inputs/rocketmq_offset"
 	_ "flashcat.cloud/categraf/inputs/self_metrics"
+	_ "flashcat.cloud/categraf/inputs/serial"
+	_ "flashcat.cloud/categraf/inputs/slurm"
 	_ "flashcat.cloud/categraf/inputs/smart"
 	_ "flashcat.cloud/categraf/inputs/snmp"
 	_ "flashcat.cloud/categraf/inputs/snmp_trap"
+	_ "flashcat.cloud/categraf/inputs/socket_listener"
 	_ "flashcat.cloud/categraf/inputs/sockstat"
 	_ "flashcat.cloud/categraf/inputs/sqlserver"
+	_ "flashcat.cloud/categraf/inputs/statsd"
 	_ "flashcat.cloud/categraf/inputs/supervisor"
 	_ "flashcat.cloud/categraf/inputs/switch_legacy"
 	_ "flashcat.cloud/categraf/inputs/system"
 	_ "flashcat.cloud/categraf/inputs/systemd"
 	_ "flashcat.cloud/categraf/inputs/tengine"
+	_ "flashcat.cloud/categraf/inputs/thermal"
 	_ "flashcat.cloud/categraf/inputs/tomcat"
 	_ "flashcat.cloud/categraf/inputs/traffic_server"
+	_ "flashcat.cloud/categraf/inputs/uwsgi"
 	_ "flashcat.cloud/categraf/inputs/vsphere"
 	_ "flashcat.cloud/categraf/inputs/whois"
+	_ "flashcat.cloud/categraf/inputs/winperfcounters"
 	_ "flashcat.cloud/categraf/inputs/xskyapi"
 	_ "flashcat.cloud/categraf/inputs/zookeeper"
 )
@@ -98,6 +117,10 @@ type MetricsAgent struct {
 	InputFilters   map[string]struct{}
 	InputReaders   *Readers
 	InputProviders []inputs.Provider
+
+	secrets      *secrets.Manager
+	secretInputs *secretInputs
+	secretsStop  chan struct{}
 }
 
 type Readers struct {
@@ -159,6 +182,15 @@ func NewMetricsAgent() AgentModule {
 		return nil
 	}
 	agent.InputProviders = provider
+
+	if c.Secrets != nil {
+		agent.secrets, err = secrets.NewManager(c.Secrets)
+		if err != nil {
+			log.Println("E! init metrics agent error: ", err)
+			return nil
+		}
+		agent.secretInputs = &secretInputs{record: make(map[string]map[string]*secretInput)}
+	}
 	return agent
 }
 
@@ -173,6 +205,10 @@ func (ma *MetricsAgent) FilterPass(inputKey string) bool {
 }
 
 func (ma *MetricsAgent) Start() error {
+	if ma.secrets != nil {
+		ma.secretsStop = make(chan struct{})
+		go ma.refreshSecrets(ma.secretsStop)
+	}
 	for idx := range ma.InputProviders {
 		err := ma.start(idx)
 		if err != nil {
@@ -216,6 +252,13 @@ func (ma *MetricsAgent) start(idx int) error {
 }
 
 func (ma *MetricsAgent) Stop() error {
+	if ma.secretsStop != nil {
+		close(ma.secretsStop)
+		ma.secretsStop = nil
+		ma.secretInputs.Lock()
+		ma.secretInputs.record = make(map[string]map[string]*secretInput)
+		ma.secretInputs.Unlock()
+	}
 	for idx := range ma.InputProviders {
 		ma.InputProviders[idx].StopReloader()
 	}
@@ -224,6 +267,7 @@ func (ma *MetricsAgent) Stop() error {
 		for sum, r := range inputs {
 			r.Stop()
 			ma.InputReaders.Del(name, sum)
+			events.Publish(events.InputStopped, map[string]string{"input": name, "checksum": sum})
 		}
 	}
 	return nil
@@ -251,6 +295,12 @@ func (ma *MetricsAgent) RegisterInput(name string, configs []cfg.ConfigWithForma
 		log.Println("E! input provider:", typ, "not found")
 		// hint and panic next line
 	}
+	if ma.secrets != nil {
+		var ok bool
+		if configs, ok = ma.resolveSecrets(name, configs); !ok {
+			return
+		}
+	}
 	newInputs, err := ma.InputProviders[idx].LoadInputConfig(configs, creator())
 	if err != nil {
 		log.Println("E! failed to load configuration of plugin:", name, "error:", err)
@@ -313,6 +363,7 @@ func (ma *MetricsAgent) inputGo(name string, sum string, input inputs.Input) {
 	go reader.startInput()
 	ma.InputReaders.Add(name, sum, reader)
 	log.Println("I! input:", name, "started")
+	events.Publish(events.InputStarted, map[string]string{"input": name, "checksum": sum})
 }
 
 func (ma *MetricsAgent) DeregisterInput(name string, sum string) {
@@ -320,6 +371,7 @@ func (ma *MetricsAgent) DeregisterInput(name string, sum string) {
 		for isum, input := range inputs {
 			if len(sum) == 0 || sum == isum {
 				input.Stop()
+				events.Publish(events.InputStopped, map[string]string{"input": name, "checksum": isum})
 			}
 		}
 		ma.InputReaders.Del(name, sum)
@@ -327,6 +379,9 @@ func (ma *MetricsAgent) DeregisterInput(name string, sum string) {
 	} else {
 		log.Printf("W! dereigster input name [%s] not found", name)
 	}
+	if ma.secrets != nil {
+		ma.forgetSecrets(name, sum)
+	}
 }
 
 func parseFilter(filterStr string) map[string]struct{} {
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/pkg/cfg"
+	"flashcat.cloud/categraf/pkg/secrets"
+)
+
+// secretsRefreshTick is how often the expiry of the resolved secrets is checked
+const secretsRefreshTick = 10 * time.Second
+
+// secretInputs records the configs referencing secrets, as given to RegisterInput, to
+// restart their inputs when the secrets are rotated
+type secretInputs struct {
+	sync.Mutex
+	// input name => configs checksums => configs
+	record map[string]map[string]*secretInput
+}
+
+type secretInput struct {
+	configs []cfg.ConfigWithFormat
+	refs    []string
+}
+
+func configsKey(configs []cfg.ConfigWithFormat) string {
+	sums := make([]string, 0, len(configs))
+	for i := range configs {
+		sums = append(sums, configs[i].CheckSum())
+	}
+	return strings.Join(sums, ",")
+}
+
+// resolveSecrets returns the configs with the values of the secrets they reference, the
+// configs whose secrets can't be resolved yet are registered once they are
+func (ma *MetricsAgent) resolveSecrets(name string, configs []cfg.ConfigWithFormat) ([]cfg.ConfigWithFormat, bool) {
+	refs := secrets.References(configs)
+	if len(refs) == 0 {
+		return configs, true
+	}
+
+	ma.secretInputs.Lock()
+	if _, has := ma.secretInputs.record[name]; !has {
+		ma.secretInputs.record[name] = make(map[string]*secretInput)
+	}
+	ma.secretInputs.record[name][configsKey(configs)] = &secretInput{configs: configs, refs: refs}
+	ma.secretInputs.Unlock()
+
+	resolved, err := ma.secrets.Interpolate(configs)
+	if err != nil {
+		log.Println("E! input:", name, "not started, retrying later:", err)
+		return nil, false
+	}
+	return resolved, true
+}
+
+// forgetSecrets stops tracking the configs of the deregistered input
+func (ma *MetricsAgent) forgetSecrets(name string, sum string) {
+	ma.secretInputs.Lock()
+	defer ma.secretInputs.Unlock()
+	if len(sum) == 0 {
+		delete(ma.secretInputs.record, name)
+		return
+	}
+	for key, si := range ma.secretInputs.record[name] {
+		for i := range si.configs {
+			if si.configs[i].CheckSum() == sum {
+				delete(ma.secretInputs.record[name], key)
+				break
+			}
+		}
+	}
+}
+
+func (ma *MetricsAgent) refreshSecrets(stop chan struct{}) {
+	ticker := time.NewTicker(secretsRefreshTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		inUse := make(map[string]struct{})
+		ma.secretInputs.Lock()
+		for _, record := range ma.secretInputs.record {
+			for _, si := range record {
+				for _, ref := range si.refs {
+					inUse[ref] = struct{}{}
+				}
+			}
+		}
+		ma.secretInputs.Unlock()
+
+		changed := ma.secrets.Refresh(inUse)
+		if len(changed) > 0 {
+			ma.restartSecretInputs(changed)
+		}
+	}
+}
+
+// restartSecretInputs registers again the configs referencing the changed secrets
+func (ma *MetricsAgent) restartSecretInputs(changed []string) {
+	isChanged := make(map[string]struct{}, len(changed))
+	for _, ref := range changed {
+		isChanged[ref] = struct{}{}
+	}
+
+	type restart struct {
+		name    string
+		configs []cfg.ConfigWithFormat
+	}
+	var restarts []restart
+	ma.secretInputs.Lock()
+	for name, record := range ma.secretInputs.record {
+		for _, si := range record {
+			for _, ref := range si.refs {
+				if _, has := isChanged[ref]; has {
+					restarts = append(restarts, restart{name: name, configs: si.configs})
+					break
+				}
+			}
+		}
+	}
+	ma.secretInputs.Unlock()
+
+	for _, r := range restarts {
+		log.Println("I! secrets of input:", r.name, "changed, restarting")
+		var sums []string
+		if running, has := ma.InputReaders.GetInput(r.name); has {
+			ma.InputReaders.lock.RLock()
+			for i := range r.configs {
+				sum := r.configs[i].CheckSum()
+				if _, has := running[sum]; has || len(sum) == 0 {
+					sums = append(sums, sum)
+				}
+			}
+			ma.InputReaders.lock.RUnlock()
+		}
+		for _, sum := range sums {
+			// the configs without checksum, of the local provider, are loaded as one input
+			ma.DeregisterInput(r.name, sum)
+			if len(sum) == 0 {
+				break
+			}
+		}
+		ma.RegisterInput(r.name, r.configs)
+	}
+}
@@ -1,11 +1,14 @@
 package agent
 
 import (
+	"hash/fnv"
 	"log"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
 	"flashcat.cloud/categraf/pkg/runtimex"
@@ -13,6 +16,28 @@ import (
 	"flashcat.cloud/categraf/writer"
 )
 
+var (
+	// exported as categraf_input_collect_duration_seconds and categraf_input_collect_errors_total by the self_metrics input
+	inputCollectDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "input_collect_duration_seconds",
+		Help:    "Duration of the gathers of the input plugins and their instances.",
+		Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"input"})
+	inputCollectErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "input_collect_errors_total",
+		Help: "Number of the gathers of the input plugins and their instances failed with a panic.",
+	}, []string{"input"})
+	// exported as categraf_samples_dropped_total by the self_metrics input
+	samplesDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "samples_dropped_total",
+		Help: "Number of the samples of the inputs dropped by metrics_drop, metrics_pass or relabel_configs before the writers.",
+	}, []string{"input"})
+)
+
+func init() {
+	prometheus.MustRegister(inputCollectDuration, inputCollectErrors, samplesDroppedTotal)
+}
+
 type InputReader struct {
 	inputName  string
 	input      inputs.Input
@@ -39,7 +64,21 @@ func (r *InputReader) startInput() {
 	if r.input.GetInterval() > 0 {
 		interval = time.Duration(r.input.GetInterval())
 	}
-	timer := time.NewTimer(0 * time.Second)
+	align := config.Config.Global.AlignInterval
+	var offset time.Duration
+	if align && config.Config.Global.SpreadInputs {
+		offset = spreadOffset(r.inputName, interval)
+	}
+
+	// an aligned input waits for its next tick, after a reload too, so the cycle of the
+	// stopped reader is not gathered twice
+	var tick time.Time
+	first := time.Duration(0)
+	if align {
+		tick = nextTick(time.Now(), interval, offset)
+		first = time.Until(tick)
+	}
+	timer := time.NewTimer(first)
 	defer timer.Stop()
 	var start time.Time
 
@@ -60,6 +99,18 @@ func (r *InputReader) startInput() {
 				log.Println("D!", r.inputName, ": after gather once,", "duration:", time.Since(start))
 			}
 
+			if align {
+				// the ticks missed by a long gather are skipped, the wall clock set back
+				// does not fire the same tick again
+				next := nextTick(time.Now(), interval, offset)
+				if !next.After(tick) {
+					next = tick.Add(interval)
+				}
+				tick = next
+				timer.Reset(time.Until(tick))
+				continue
+			}
+
 			next := interval - time.Since(start)
 			if next < 0 {
 				next = 0
@@ -69,6 +120,27 @@ func (r *InputReader) startInput() {
 	}
 }
 
+// nextTick returns the first time after now which is offset past a multiple of interval
+// since the unix epoch, e.g. :00, :15, :30 and :45 for 15s without offset
+func nextTick(now time.Time, interval, offset time.Duration) time.Time {
+	n := now.UnixNano() - int64(offset)
+	next := (n/int64(interval) + 1) * int64(interval)
+	return time.Unix(0, next+int64(offset))
+}
+
+// spreadOffset returns the offset of the ticks of an input in the interval, the same on
+// every host
+func spreadOffset(name string, interval time.Duration) time.Duration {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	// whole milliseconds read better in the timestamps
+	ms := uint64(interval / time.Millisecond)
+	if ms == 0 {
+		return 0
+	}
+	return time.Duration(h.Sum64()%ms) * time.Millisecond
+}
+
 func (r *InputReader) gatherOnce() {
 	defer func() {
 		if rc := recover(); rc != nil {
@@ -78,8 +150,8 @@ func (r *InputReader) gatherOnce() {
 
 	// plugin level, for system plugins
 	slist := types.NewSampleList()
-	inputs.MayGather(r.input, slist)
-	r.forward(r.input.Process(slist))
+	r.gather(r.input, slist)
+	r.forward(r.process(r.input, slist))
 
 	instances := inputs.MayGetInstances(r.input)
 	if len(instances) == 0 {
@@ -112,18 +184,55 @@ func (r *InputReader) gatherOnce() {
 			}
 
 			insList := types.NewSampleList()
-			inputs.MayGather(ins, insList)
-			r.forward(ins.Process(insList))
+			r.gather(ins, insList)
+			r.forward(r.process(ins, insList))
 		}(instances[i])
 	}
 
 	r.waitGroup.Wait()
 }
 
+// gather gathers the samples of the plugin or the instance, the duration and the panics of
+// the gathers are recorded in the self metrics
+func (r *InputReader) gather(t interface{}, slist *types.SampleList) {
+	gatherer, ok := t.(inputs.SampleGatherer)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	defer func() {
+		inputCollectDuration.WithLabelValues(r.inputName).Observe(time.Since(start).Seconds())
+		if rc := recover(); rc != nil {
+			inputCollectErrors.WithLabelValues(r.inputName).Inc()
+			log.Println("E!", r.inputName, ": gather metrics panic:", rc, string(runtimex.Stack(3)))
+		}
+	}()
+	gatherer.Gather(slist)
+}
+
+// sampleProcessor is the plugin or an instance of an input
+type sampleProcessor interface {
+	Process(*types.SampleList) *types.SampleList
+}
+
+// process applies the filters, the labels and the relabel_configs of the plugin or the instance
+// and counts the samples they dropped
+func (r *InputReader) process(p sampleProcessor, slist *types.SampleList) *types.SampleList {
+	n := slist.Len()
+	nlst := p.Process(slist)
+	if dropped := n - nlst.Len(); dropped > 0 {
+		samplesDroppedTotal.WithLabelValues(r.inputName).Add(float64(dropped))
+	}
+	return nlst
+}
+
 func (r *InputReader) forward(slist *types.SampleList) {
 	if slist == nil {
 		return
 	}
 	arr := slist.PopBackAll()
-	writer.WriteSamples(arr)
+	if config.Config.Global.EnableRateConversion {
+		arr = rateConverter.Convert(arr)
+	}
+	writer.WriteSamples(r.inputName, arr)
 }
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextTick(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		now      time.Time
+		interval time.Duration
+		offset   time.Duration
+		want     time.Time
+	}{
+		{name: "aligned on the interval", now: base.Add(7 * time.Second), interval: 15 * time.Second, want: base.Add(15 * time.Second)},
+		{name: "on a tick", now: base.Add(15 * time.Second), interval: 15 * time.Second, want: base.Add(30 * time.Second)},
+		{name: "just before a tick", now: base.Add(15*time.Second - time.Nanosecond), interval: 15 * time.Second, want: base.Add(15 * time.Second)},
+		{name: "minute", now: base.Add(61 * time.Second), interval: time.Minute, want: base.Add(2 * time.Minute)},
+		{name: "offset", now: base.Add(7 * time.Second), interval: 15 * time.Second, offset: 4 * time.Second, want: base.Add(19 * time.Second)},
+		{name: "before the offset", now: base.Add(2 * time.Second), interval: 15 * time.Second, offset: 4 * time.Second, want: base.Add(4 * time.Second)},
+		{name: "on an offset tick", now: base.Add(4 * time.Second), interval: 15 * time.Second, offset: 4 * time.Second, want: base.Add(19 * time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextTick(tt.now, tt.interval, tt.offset); !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNextTickAfterReload(t *testing.T) {
+	interval := 15 * time.Second
+	offset := spreadOffset("cpu", interval)
+	tick := nextTick(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), interval, offset)
+	// the reader gathered on tick and is reloaded while or right after gathering, the new
+	// reader waits for the next cycle
+	for _, elapsed := range []time.Duration{0, time.Millisecond, time.Second, interval - time.Millisecond} {
+		if got := nextTick(tick.Add(elapsed), interval, offset); !got.Equal(tick.Add(interval)) {
+			t.Errorf("reloaded %v after the tick: expected %v, got %v", elapsed, tick.Add(interval), got)
+		}
+	}
+}
+
+func TestSpreadOffset(t *testing.T) {
+	interval := 15 * time.Second
+	offsets := map[time.Duration]bool{}
+	for _, name := range []string{"cpu", "mem", "disk", "diskio", "net", "netstat", "processes", "system"} {
+		offset := spreadOffset(name, interval)
+		if offset < 0 || offset >= interval || offset%time.Millisecond != 0 {
+			t.Errorf("%s: unexpected offset %v", name, offset)
+		}
+		// the same on every host
+		if spreadOffset(name, interval) != offset {
+			t.Errorf("%s: expected a stable offset", name)
+		}
+		offsets[offset] = true
+	}
+	if len(offsets) < 2 {
+		t.Error("expected the inputs to be spread in the interval")
+	}
+	if offset := spreadOffset("cpu", time.Microsecond); offset != 0 {
+		t.Errorf("expected no offset below a millisecond, got %v", offset)
+	}
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	rateTypeLabel    = "_type"
+	rateTypeCounter  = "counter"
+	rateStateExpired = time.Hour
+	ratePruneEvery   = 10 * time.Minute
+)
+
+// RateConverter turns samples labeled _type=counter into per-second rates,
+// the first sample of a series is only recorded and a counter reset yields 0
+type RateConverter struct {
+	last      sync.Map // series key -> rateState
+	lastPrune time.Time
+	sync.Mutex
+}
+
+type rateState struct {
+	value float64
+	ts    time.Time
+}
+
+func NewRateConverter() *RateConverter {
+	return &RateConverter{lastPrune: time.Now()}
+}
+
+var rateConverter = NewRateConverter()
+
+// Convert returns the samples with counters replaced by their rate, the _type label is removed
+func (rc *RateConverter) Convert(samples []*types.Sample) []*types.Sample {
+	ret := samples[:0]
+	for _, s := range samples {
+		if s.Labels[rateTypeLabel] != rateTypeCounter {
+			ret = append(ret, s)
+			continue
+		}
+		delete(s.Labels, rateTypeLabel)
+
+		value, err := conv.ToFloat64(s.Value)
+		if err != nil {
+			continue
+		}
+		ts := s.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+
+		key := seriesKey(s)
+		prev, has := rc.last.Load(key)
+		rc.last.Store(key, rateState{value: value, ts: ts})
+		if !has {
+			continue
+		}
+
+		p := prev.(rateState)
+		dt := ts.Sub(p.ts).Seconds()
+		if dt <= 0 {
+			continue
+		}
+		if value < p.value {
+			// counter reset
+			s.Value = 0
+		} else {
+			s.Value = (value - p.value) / dt
+		}
+		ret = append(ret, s)
+	}
+
+	rc.prune()
+	return ret
+}
+
+// prune drops the state of series which have not been seen for a while
+func (rc *RateConverter) prune() {
+	rc.Lock()
+	defer rc.Unlock()
+	if time.Since(rc.lastPrune) < ratePruneEvery {
+		return
+	}
+	rc.lastPrune = time.Now()
+	rc.last.Range(func(key, value interface{}) bool {
+		if time.Since(value.(rateState).ts) > rateStateExpired {
+			rc.last.Delete(key)
+		}
+		return true
+	})
+}
+
+func seriesKey(s *types.Sample) string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(s.Metric)
+	for _, k := range keys {
+		sb.WriteByte(0xff)
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(s.Labels[k])
+	}
+	return sb.String()
+}
@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func counter(metric string, value interface{}, ts time.Time, labels map[string]string) *types.Sample {
+	l := map[string]string{rateTypeLabel: rateTypeCounter}
+	for k, v := range labels {
+		l[k] = v
+	}
+	return &types.Sample{Metric: metric, Value: value, Timestamp: ts, Labels: l}
+}
+
+func TestRateConverter(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name    string
+		values  []interface{}
+		offsets []time.Duration
+		// the rates of the samples after the first one, nil when the sample is dropped
+		want []interface{}
+	}{
+		{
+			name:    "rate",
+			values:  []interface{}{uint64(100), uint64(160), 220.0},
+			offsets: []time.Duration{0, 10 * time.Second, 40 * time.Second},
+			want:    []interface{}{6.0, 2.0},
+		},
+		{
+			name:    "counter reset",
+			values:  []interface{}{100, 30, 60},
+			offsets: []time.Duration{0, 10 * time.Second, 20 * time.Second},
+			want:    []interface{}{0, 3.0},
+		},
+		{
+			name:    "same timestamp",
+			values:  []interface{}{100, 200, 300},
+			offsets: []time.Duration{0, 0, 10 * time.Second},
+			want:    []interface{}{nil, 10.0},
+		},
+		{
+			name:    "timestamp going back",
+			values:  []interface{}{100, 200},
+			offsets: []time.Duration{10 * time.Second, 0},
+			want:    []interface{}{nil},
+		},
+		{
+			name:    "not a number",
+			values:  []interface{}{100, "x", 200},
+			offsets: []time.Duration{0, 10 * time.Second, 20 * time.Second},
+			want:    []interface{}{nil, 5.0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := NewRateConverter()
+			// the first sample of the series is only recorded
+			if got := rc.Convert([]*types.Sample{counter("requests", tt.values[0], start.Add(tt.offsets[0]), nil)}); len(got) != 0 {
+				t.Fatalf("expected the first sample to be dropped, got %v", got)
+			}
+			for i, want := range tt.want {
+				got := rc.Convert([]*types.Sample{counter("requests", tt.values[i+1], start.Add(tt.offsets[i+1]), nil)})
+				if want == nil {
+					if len(got) != 0 {
+						t.Errorf("sample %d: expected to be dropped, got %v", i+1, got[0].Value)
+					}
+					continue
+				}
+				if len(got) != 1 {
+					t.Fatalf("sample %d: expected a rate, got %d samples", i+1, len(got))
+				}
+				if got[0].Value != want {
+					t.Errorf("sample %d: expected %v, got %v", i+1, want, got[0].Value)
+				}
+				if _, has := got[0].Labels[rateTypeLabel]; has {
+					t.Errorf("sample %d: expected the %s label to be removed", i+1, rateTypeLabel)
+				}
+			}
+		})
+	}
+}
+
+func TestRateConverterSeries(t *testing.T) {
+	rc := NewRateConverter()
+	start := time.Now()
+	gauge := &types.Sample{Metric: "temperature", Value: 20, Timestamp: start, Labels: map[string]string{}}
+	got := rc.Convert([]*types.Sample{
+		counter("requests", 100, start, map[string]string{"code": "200"}),
+		counter("requests", 10, start, map[string]string{"code": "500"}),
+		gauge,
+	})
+	if len(got) != 1 || got[0] != gauge {
+		t.Fatalf("expected only the gauge, got %v", got)
+	}
+
+	// the series labeled differently have their own state
+	got = rc.Convert([]*types.Sample{
+		counter("requests", 200, start.Add(10*time.Second), map[string]string{"code": "200"}),
+		counter("requests", 20, start.Add(10*time.Second), map[string]string{"code": "500"}),
+	})
+	rates := map[string]interface{}{}
+	for _, s := range got {
+		rates[s.Labels["code"]] = s.Value
+	}
+	if len(rates) != 2 || rates["200"] != 10.0 || rates["500"] != 1.0 {
+		t.Errorf("unexpected rates %v", rates)
+	}
+}
+
+func TestRateConverterPrune(t *testing.T) {
+	rc := NewRateConverter()
+	now := time.Now()
+	rc.Convert([]*types.Sample{
+		counter("stale", 1, now.Add(-2*rateStateExpired), nil),
+		counter("fresh", 1, now, nil),
+	})
+	count := func() int {
+		n := 0
+		rc.last.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("expected the state of 2 series, got %d", n)
+	}
+
+	// the states are pruned every ratePruneEvery only
+	rc.lastPrune = now.Add(-ratePruneEvery)
+	rc.Convert(nil)
+	if n := count(); n != 1 {
+		t.Fatalf("expected the state of 1 series, got %d", n)
+	}
+	if _, has := rc.last.Load(seriesKey(&types.Sample{Metric: "fresh", Labels: map[string]string{}})); !has {
+		t.Error("expected the state of the fresh series to be kept")
+	}
+}
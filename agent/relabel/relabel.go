@@ -0,0 +1,141 @@
+package relabel
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+
+	modelLabel "flashcat.cloud/categraf/pkg/prom/labels"
+	pkgrelabel "flashcat.cloud/categraf/pkg/relabel"
+	"flashcat.cloud/categraf/types"
+)
+
+// RelabelRule is a global relabel rule applied to every sample before it is handed to the writers,
+// the metric name is available as the __name__ label
+type RelabelRule struct {
+	SourceLabels []string `toml:"source_labels"`
+	Separator    string   `toml:"separator"`
+	Regex        string   `toml:"regex"`
+	TargetLabel  string   `toml:"target_label"`
+	Replacement  string   `toml:"replacement"`
+	// modulus of the hash of the source labels for the hashmod action
+	Modulus uint64 `toml:"modulus"`
+	// keep, drop, replace, labelmap, and the other actions of relabel_configs
+	Action string `toml:"action"`
+}
+
+var rules []*pkgrelabel.Config
+
+// Init compiles the global rules, rules are applied in order
+func Init(rs []*RelabelRule) error {
+	compiled, err := Compile(rs)
+	if err != nil {
+		return err
+	}
+	rules = compiled
+	return nil
+}
+
+// Compile compiles the rules for pkgrelabel.Process, e.g. the relabel_configs of a writer
+func Compile(rs []*RelabelRule) ([]*pkgrelabel.Config, error) {
+	compiled := make([]*pkgrelabel.Config, 0, len(rs))
+	for i, r := range rs {
+		c, err := compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("relabel rule #%d: %v", i, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+func compile(r *RelabelRule) (*pkgrelabel.Config, error) {
+	regex := r.Regex
+	if regex == "" {
+		regex = "(.*)"
+	}
+	action := pkgrelabel.Action(r.Action)
+	if action == "" {
+		action = pkgrelabel.Replace
+	}
+	replacement := r.Replacement
+	if replacement == "" {
+		replacement = "$1"
+	}
+	separator := r.Separator
+	if separator == "" {
+		separator = ";"
+	}
+
+	switch action {
+	case pkgrelabel.Replace, pkgrelabel.Keep, pkgrelabel.Drop, pkgrelabel.LabelMap,
+		pkgrelabel.KeepEqual, pkgrelabel.DropEqual, pkgrelabel.LabelDrop, pkgrelabel.LabelKeep,
+		pkgrelabel.Lowercase, pkgrelabel.Uppercase, pkgrelabel.HashMod:
+	default:
+		return nil, fmt.Errorf("unsupported action %s", r.Action)
+	}
+	if (action == pkgrelabel.Replace || action == pkgrelabel.Lowercase || action == pkgrelabel.Uppercase) && r.TargetLabel == "" {
+		return nil, fmt.Errorf("target_label is required for action %s", action)
+	}
+	if action == pkgrelabel.HashMod && (r.TargetLabel == "" || r.Modulus == 0) {
+		return nil, fmt.Errorf("target_label and a non zero modulus are required for action %s", action)
+	}
+
+	reg, err := pkgrelabel.NewRegexp(regex)
+	if err != nil {
+		return nil, fmt.Errorf("regex:%s compile error:%s", regex, err)
+	}
+
+	sourceLabels := make(model.LabelNames, 0, len(r.SourceLabels))
+	for _, l := range r.SourceLabels {
+		sourceLabels = append(sourceLabels, model.LabelName(l))
+	}
+
+	return &pkgrelabel.Config{
+		SourceLabels: sourceLabels,
+		Separator:    separator,
+		Regex:        reg,
+		TargetLabel:  r.TargetLabel,
+		Replacement:  replacement,
+		Modulus:      r.Modulus,
+		Action:       action,
+	}, nil
+}
+
+// Enabled returns whether any global rule is configured
+func Enabled() bool {
+	return len(rules) > 0
+}
+
+// Process applies the global rules to the samples, dropped samples are removed
+func Process(samples []*types.Sample) []*types.Sample {
+	if len(rules) == 0 {
+		return samples
+	}
+
+	ret := samples[:0]
+	for _, s := range samples {
+		all := make(modelLabel.Labels, 0, len(s.Labels)+1)
+		all = append(all, modelLabel.Label{Name: model.MetricNameLabel, Value: s.Metric})
+		for k, v := range s.Labels {
+			all = append(all, modelLabel.Label{Name: k, Value: v})
+		}
+
+		newAll, keep := pkgrelabel.Process(all, rules...)
+		if !keep {
+			continue
+		}
+
+		newLabels := make(map[string]string, len(newAll))
+		for _, l := range newAll {
+			if l.Name == model.MetricNameLabel {
+				s.Metric = l.Value
+				continue
+			}
+			newLabels[l.Name] = l.Value
+		}
+		s.Labels = newLabels
+		ret = append(ret, s)
+	}
+	return ret
+}
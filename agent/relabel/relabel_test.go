@@ -0,0 +1,127 @@
+package relabel
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name string
+		rule *RelabelRule
+		err  string
+	}{
+		{name: "defaults", rule: &RelabelRule{SourceLabels: []string{"job"}, TargetLabel: "service"}},
+		{name: "keep", rule: &RelabelRule{SourceLabels: []string{"__name__"}, Regex: "cpu_.*", Action: "keep"}},
+		{name: "unknown action", rule: &RelabelRule{Action: "rename"}, err: "unsupported action rename"},
+		{name: "replace without target_label", rule: &RelabelRule{SourceLabels: []string{"job"}}, err: "target_label is required"},
+		{name: "lowercase without target_label", rule: &RelabelRule{SourceLabels: []string{"job"}, Action: "lowercase"}, err: "target_label is required"},
+		{name: "hashmod without modulus", rule: &RelabelRule{SourceLabels: []string{"instance"}, TargetLabel: "shard", Action: "hashmod"}, err: "non zero modulus"},
+		{name: "bad regex", rule: &RelabelRule{SourceLabels: []string{"job"}, Regex: "(", TargetLabel: "service"}, err: "compile error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]*RelabelRule{{SourceLabels: []string{"job"}, TargetLabel: "service"}, tt.rule})
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) || !strings.HasPrefix(err.Error(), "relabel rule #1:") {
+				t.Fatalf("expected the error %q of rule #1, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []*RelabelRule
+		// the metric and the labels of the samples kept
+		want []string
+	}{
+		{
+			name: "no rule",
+			want: []string{"cpu_usage_idle{cpu=cpu0}", "mem_used{}", "disk_free{path=/}"},
+		},
+		{
+			name:  "keep",
+			rules: []*RelabelRule{{SourceLabels: []string{"__name__"}, Regex: "cpu_.*|mem_.*", Action: "keep"}},
+			want:  []string{"cpu_usage_idle{cpu=cpu0}", "mem_used{}"},
+		},
+		{
+			name:  "drop",
+			rules: []*RelabelRule{{SourceLabels: []string{"path"}, Regex: "/", Action: "drop"}},
+			want:  []string{"cpu_usage_idle{cpu=cpu0}", "mem_used{}"},
+		},
+		{
+			name: "replace",
+			rules: []*RelabelRule{{
+				SourceLabels: []string{"__name__", "cpu"},
+				Separator:    "/",
+				Regex:        "cpu_(.*)/cpu(.*)",
+				TargetLabel:  "core",
+				Replacement:  "$2",
+			}},
+			want: []string{"cpu_usage_idle{core=0,cpu=cpu0}", "mem_used{}", "disk_free{path=/}"},
+		},
+		{
+			name: "rename the metric",
+			rules: []*RelabelRule{{
+				SourceLabels: []string{"__name__"},
+				Regex:        "mem_(.*)",
+				TargetLabel:  "__name__",
+				Replacement:  "memory_$1",
+			}},
+			want: []string{"cpu_usage_idle{cpu=cpu0}", "memory_used{}", "disk_free{path=/}"},
+		},
+		{
+			name: "rules in order",
+			rules: []*RelabelRule{
+				{SourceLabels: []string{"__name__"}, Regex: "disk_(.*)", TargetLabel: "__name__", Replacement: "fs_$1"},
+				{SourceLabels: []string{"__name__"}, Regex: "fs_.*", Action: "drop"},
+			},
+			want: []string{"cpu_usage_idle{cpu=cpu0}", "mem_used{}"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Init(tt.rules); err != nil {
+				t.Fatal(err)
+			}
+			defer Init(nil)
+			if Enabled() != (len(tt.rules) > 0) {
+				t.Errorf("expected Enabled() to be %v", len(tt.rules) > 0)
+			}
+
+			samples := Process([]*types.Sample{
+				{Metric: "cpu_usage_idle", Value: 90, Labels: map[string]string{"cpu": "cpu0"}},
+				{Metric: "mem_used", Value: 1024, Labels: map[string]string{}},
+				{Metric: "disk_free", Value: 2048, Labels: map[string]string{"path": "/"}},
+			})
+			var got []string
+			for _, s := range samples {
+				got = append(got, series(s))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// series formats the metric and the sorted labels of s
+func series(s *types.Sample) string {
+	pairs := make([]string, 0, len(s.Labels))
+	for k, v := range s.Labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return s.Metric + "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/writer"
+)
+
+// StartAgentServer serves the health, the self metrics and the profiles of the agent on
+// [agent] http_listen, health returns nil when the agent runs cleanly
+func StartAgentServer(health func() error) {
+	if config.Config == nil || config.Config.Agent == nil || config.Config.Agent.HTTPListen == "" {
+		return
+	}
+	if config.Config.TestMode {
+		return
+	}
+
+	srv := &http.Server{
+		Addr:        config.Config.Agent.HTTPListen,
+		Handler:     agentHandler(health),
+		ReadTimeout: 10 * time.Second,
+		// the cpu profiles and the traces last 30 seconds by default
+		WriteTimeout: 90 * time.Second,
+		IdleTimeout:  2 * time.Minute,
+	}
+	log.Println("I! agent http server listening on:", srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Println("E! agent http server:", err)
+	}
+}
+
+func agentHandler(health func() error) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		status, code := map[string]string{"status": "ok"}, http.StatusOK
+		if err := health(); err != nil {
+			status["status"], status["error"] = "unhealthy", err.Error()
+			code = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(status)
+	})
+	// the default registry holds the go and process collectors and the self metrics
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prefixedGatherer(prometheus.DefaultGatherer), promhttp.HandlerOpts{})))
+	// the deliveries of writer_opt.compare_writers, 404 when no writers are compared
+	mux.HandleFunc("/debug/writer/compare", func(w http.ResponseWriter, r *http.Request) {
+		report := writer.Compare()
+		if report == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	})
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// selfMetricsPrefix is the prefix given to the self metrics by the self_metrics input
+const selfMetricsPrefix = "categraf_"
+
+// runtimeMetricPrefixes are the families of the go, process and promhttp collectors, served as is
+var runtimeMetricPrefixes = []string{"go_", "process_", "promhttp_"}
+
+// prefixedGatherer serves the self metrics under the names reported by the self_metrics
+// input, e.g. categraf_input_collect_duration_seconds
+func prefixedGatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		// the families are built on each gather, they can be renamed
+		mfs, err := g.Gather()
+		for _, mf := range mfs {
+			if !isRuntimeMetric(mf.GetName()) {
+				name := selfMetricsPrefix + mf.GetName()
+				mf.Name = &name
+			}
+		}
+		sort.Slice(mfs, func(i, j int) bool {
+			return mfs[i].GetName() < mfs[j].GetName()
+		})
+		return mfs, err
+	})
+}
+
+func isRuntimeMetric(name string) bool {
+	for _, prefix := range runtimeMetricPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -65,6 +65,6 @@ func pushgateway(c *gin.Context) {
 		}
 
 	}
-	writer.WriteSamples(samples)
+	writer.WriteSamples("pushgateway", samples)
 	c.String(200, "forwarding...")
 }
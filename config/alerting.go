@@ -0,0 +1,53 @@
+package config
+
+type (
+	// Alerting evaluates threshold rules on the gathered samples on the edge, for the sites
+	// losing the connection to the central alerting system
+	Alerting struct {
+		Enable bool `toml:"enable"`
+		// a firing series no longer gathered is resolved after resolve_timeout
+		ResolveTimeout Duration      `toml:"resolve_timeout"`
+		Rules          []*AlertRule  `toml:"rules"`
+		File           *AlertFile    `toml:"file"`
+		Exec           *AlertExec    `toml:"exec"`
+		Webhook        *AlertWebhook `toml:"webhook"`
+		// notifications are dropped when the buffer is full
+		BufferSize int `toml:"buffer_size"`
+	}
+
+	AlertRule struct {
+		Name string `toml:"name"`
+		// a metric name selector with label matchers, e.g. disk_used_percent{path="/",fstype!~"tmpfs|overlay"}
+		Selector string `toml:"selector"`
+		// >, >=, <, <=, == or !=
+		Op        string   `toml:"op"`
+		Threshold float64  `toml:"threshold"`
+		For       Duration `toml:"for"`
+		// file, exec and/or webhook, all the configured actions when empty
+		Actions []string          `toml:"actions"`
+		Labels  map[string]string `toml:"labels"`
+	}
+
+	// AlertFile writes the notifications as json lines, rotated by size
+	AlertFile struct {
+		FileName   string `toml:"file_name"`
+		MaxSize    int    `toml:"max_size"`
+		MaxBackups int    `toml:"max_backups"`
+	}
+
+	// AlertExec runs the command for each notification, the notification is written as json
+	// to its stdin and the ALERT_* environment variables are set
+	AlertExec struct {
+		Command []string `toml:"command"`
+		Timeout Duration `toml:"timeout"`
+		// at most max_per_minute commands are run per minute, the other notifications are dropped
+		MaxPerMinute int `toml:"max_per_minute"`
+	}
+
+	// AlertWebhook posts the notifications as json, e.g. to a local alert manager or pager gateway
+	AlertWebhook struct {
+		URL     string            `toml:"url"`
+		Headers map[string]string `toml:"headers"`
+		Timeout Duration          `toml:"timeout"`
+	}
+)
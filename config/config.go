@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"flashcat.cloud/categraf/agent/relabel"
 	"flashcat.cloud/categraf/pkg/cfg"
 	"flashcat.cloud/categraf/pkg/tls"
 	jsoniter "github.com/json-iterator/go"
@@ -27,14 +28,19 @@ var envVarEscaper = strings.NewReplacer(
 )
 
 type Global struct {
-	PrintConfigs bool              `toml:"print_configs"`
-	Hostname     string            `toml:"hostname"`
-	OmitHostname bool              `toml:"omit_hostname"`
-	Labels       map[string]string `toml:"labels"`
-	Precision    string            `toml:"precision"`
-	Interval     Duration          `toml:"interval"`
-	Providers    []string          `toml:"providers"`
-	Concurrency  int               `toml:"concurrency"`
+	PrintConfigs         bool              `toml:"print_configs"`
+	Hostname             string            `toml:"hostname"`
+	OmitHostname         bool              `toml:"omit_hostname"`
+	Labels               map[string]string `toml:"labels"`
+	Precision            string            `toml:"precision"`
+	Interval             Duration          `toml:"interval"`
+	Providers            []string          `toml:"providers"`
+	Concurrency          int               `toml:"concurrency"`
+	EnableRateConversion bool              `toml:"enable_rate_conversion"`
+	// start the gathers on the multiples of the interval of the wall clock, spread the inputs
+	// over the interval by the hash of their names with spread_inputs
+	AlignInterval bool `toml:"align_interval"`
+	SpreadInputs  bool `toml:"spread_inputs"`
 }
 
 type Log struct {
@@ -49,6 +55,29 @@ type Log struct {
 type WriterOpt struct {
 	Batch    int `toml:"batch"`
 	ChanSize int `toml:"chan_size"`
+
+	// drop the samples with the labels and timestamp of a sample already written in the window
+	Dedup          bool     `toml:"dedup"`
+	DedupWindow    Duration `toml:"dedup_window"`
+	DedupMaxSeries int      `toml:"dedup_max_series"`
+
+	// fix the series rejected by the backends: label names, invalid values, too many labels
+	Sanitize                    bool `toml:"sanitize"`
+	SanitizeMaxLabels           int  `toml:"sanitize_max_labels"`
+	SanitizeMaxLabelValueLength int  `toml:"sanitize_max_label_value_length"`
+
+	// drop the new series of a metric once it has max_cardinality series written in cardinality_ttl
+	MaxCardinality        int      `toml:"max_cardinality"`
+	CardinalityTTL        Duration `toml:"cardinality_ttl"`
+	CardinalityMaxMetrics int      `toml:"cardinality_max_metrics"`
+
+	// the urls of two writers whose deliveries are compared, e.g. while migrating to another backend
+	CompareWriters []string `toml:"compare_writers"`
+	// the series delivered to one writer of the pair only kept for /debug/writer/compare, per writer
+	CompareMaxSeries int `toml:"compare_max_series"`
+
+	// the batches waiting to be written by each output, the batches beyond are dropped
+	OutputQueueSize int `toml:"output_queue_size"`
 }
 
 type WriterOption struct {
@@ -61,6 +90,17 @@ type WriterOption struct {
 	DialTimeout         int64 `toml:"dial_timeout"`
 	MaxIdleConnsPerHost int   `toml:"max_idle_conns_per_host"`
 
+	// applied in order to the series sent to this writer only, after the global relabel rules
+	RelabelConfigs []*relabel.RelabelRule `toml:"relabel_configs"`
+
+	// retry the batches failed with a 5xx or a network error in the background, up to max_retries
+	// times after retry_backoff doubled on every retry up to retry_max_backoff, 0 disables the retries
+	MaxRetries      int      `toml:"max_retries"`
+	RetryBackoff    Duration `toml:"retry_backoff"`
+	RetryMaxBackoff Duration `toml:"retry_max_backoff"`
+	// the samples of the failed batches waiting to be retried, the oldest batches are dropped beyond
+	QueueMaxSamples int `toml:"queue_max_samples"`
+
 	tls.ClientConfig
 }
 
@@ -78,6 +118,12 @@ type HTTP struct {
 	IdleTimeout        int    `toml:"idle_timeout"`
 }
 
+// Agent configures the http server exposing the health, the self metrics and the
+// profiles of the agent
+type Agent struct {
+	HTTPListen string `toml:"http_listen"`
+}
+
 type IbexConfig struct {
 	Enable   bool
 	Interval Duration `toml:"interval"`
@@ -114,12 +160,20 @@ type ConfigType struct {
 	Writers    []WriterOption   `toml:"writers"`
 	Logs       Logs             `toml:"logs"`
 	HTTP       *HTTP            `toml:"http"`
+	Agent      *Agent           `toml:"agent"`
 	Prometheus *Prometheus      `toml:"prometheus"`
 	Ibex       *IbexConfig      `toml:"ibex"`
 	Heartbeat  *HeartbeatConfig `toml:"heartbeat"`
 	Log        Log              `toml:"log"`
+	Events     *Events          `toml:"events"`
+	Alerting   *Alerting        `toml:"alerting"`
+
+	// global relabel rules, applied to every sample before it is handed to the writers
+	Relabel []*relabel.RelabelRule `toml:"relabel"`
 
 	HTTPProviderConfig *HTTPProviderConfig `toml:"http_provider"`
+
+	Secrets *SecretProviders `toml:"secrets"`
 }
 
 var Config *ConfigType
@@ -158,6 +212,30 @@ func InitConfig(configDir string, debugLevel int, debugMode, testMode bool, inte
 		Config.WriterOpt.Batch = 1000
 	}
 
+	if Config.WriterOpt.OutputQueueSize <= 0 {
+		Config.WriterOpt.OutputQueueSize = 16
+	}
+
+	if Config.WriterOpt.DedupWindow <= 0 {
+		Config.WriterOpt.DedupWindow = Duration(GetInterval())
+	}
+
+	if Config.WriterOpt.DedupMaxSeries <= 0 {
+		Config.WriterOpt.DedupMaxSeries = 1000000
+	}
+
+	if Config.WriterOpt.CardinalityTTL <= 0 {
+		Config.WriterOpt.CardinalityTTL = Duration(time.Hour)
+	}
+
+	if Config.WriterOpt.CardinalityMaxMetrics <= 0 {
+		Config.WriterOpt.CardinalityMaxMetrics = 10000
+	}
+
+	if Config.WriterOpt.CompareMaxSeries <= 0 {
+		Config.WriterOpt.CompareMaxSeries = 1000
+	}
+
 	Config.Global.Hostname = strings.TrimSpace(Config.Global.Hostname)
 
 	if err := InitHostInfo(); err != nil {
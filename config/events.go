@@ -0,0 +1,15 @@
+package config
+
+type (
+	Events struct {
+		Enable bool `toml:"enable"`
+		// json lines file, rotated by size
+		FileName   string `toml:"file_name"`
+		MaxSize    int    `toml:"max_size"`
+		MaxBackups int    `toml:"max_backups"`
+		// events are dropped when the buffer is full
+		BufferSize int `toml:"buffer_size"`
+		// forward events as categraf_event samples to writers
+		ForwardToWriters bool `toml:"forward_to_writers"`
+	}
+)
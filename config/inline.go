@@ -24,11 +24,15 @@ type InternalConfig struct {
 	// append labels
 	Labels map[string]string `toml:"labels"`
 
-	// metrics drop and pass filter
+	// metrics drop and pass filter, globs or /regexps/ of the metric names
 	MetricsDrop       []string `toml:"metrics_drop"`
 	MetricsPass       []string `toml:"metrics_pass"`
-	MetricsDropFilter filter.Filter
-	MetricsPassFilter filter.Filter
+	MetricsDropFilter *filter.Matcher
+	MetricsPassFilter *filter.Matcher
+
+	// globs or /regexps/ of the label names removed from the samples, after relabel_configs
+	LabelsDrop       []string `toml:"labels_drop"`
+	labelsDropFilter *filter.Matcher
 
 	// metric name prefix
 	MetricsNamePrefix string `toml:"metrics_name_prefix"`
@@ -75,18 +79,26 @@ func (ic *InternalConfig) GetLabels() map[string]string {
 func (ic *InternalConfig) InitInternalConfig() error {
 	if len(ic.MetricsDrop) > 0 {
 		var err error
-		ic.MetricsDropFilter, err = filter.Compile(ic.MetricsDrop)
+		ic.MetricsDropFilter, err = filter.NewMatcher(ic.MetricsDrop)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to compile metrics_drop: %v", err)
 		}
 	}
 	ic.DebugMod = Config.DebugMode
 
 	if len(ic.MetricsPass) > 0 {
 		var err error
-		ic.MetricsPassFilter, err = filter.Compile(ic.MetricsPass)
+		ic.MetricsPassFilter, err = filter.NewMatcher(ic.MetricsPass)
+		if err != nil {
+			return fmt.Errorf("failed to compile metrics_pass: %v", err)
+		}
+	}
+
+	if len(ic.LabelsDrop) > 0 {
+		var err error
+		ic.labelsDropFilter, err = filter.NewMatcher(ic.LabelsDrop)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to compile labels_drop: %v", err)
 		}
 	}
 
@@ -142,6 +154,7 @@ func (ic *InternalConfig) Process(slist *types.SampleList) *types.SampleList {
 
 	now := time.Now()
 	ss := slist.PopBackAll()
+	inheritance := NewTagInheritance()
 
 	for i := range ss {
 		if ss[i] == nil {
@@ -191,19 +204,8 @@ func (ic *InternalConfig) Process(slist *types.SampleList) *types.SampleList {
 			ss[i].Labels[k] = Expand(v)
 		}
 
-		// add global labels
-		for k, v := range GlobalLabels() {
-			if _, has := ss[i].Labels[k]; !has {
-				ss[i].Labels[k] = v
-			}
-		}
-
-		// add label: agent_hostname
-		if _, has := ss[i].Labels[agentHostnameLabelKey]; !has {
-			if !Config.Global.OmitHostname {
-				ss[i].Labels[agentHostnameLabelKey] = Config.GetHostname()
-			}
-		}
+		// add global labels and agent_hostname, before relabel to be matched or dropped
+		inheritance.Apply(ss[i].Labels)
 		// relabel
 		if len(ic.relabelConfigs) != 0 {
 			all := make(modelLabel.Labels, len(ss[i].Labels))
@@ -221,6 +223,15 @@ func (ic *InternalConfig) Process(slist *types.SampleList) *types.SampleList {
 			ss[i].Labels = newLabel
 		}
 
+		// drop labels
+		if ic.labelsDropFilter != nil {
+			for k := range ss[i].Labels {
+				if ic.labelsDropFilter.Match(k) {
+					delete(ss[i].Labels, k)
+				}
+			}
+		}
+
 		nlst.PushFront(ss[i])
 	}
 
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestProcessFilters(t *testing.T) {
+	Config = &ConfigType{Global: Global{Labels: map[string]string{"env": "prod"}}}
+	HostInfo = &HostInfoCache{name: "h1"}
+
+	ic := &InstanceConfig{InternalConfig: InternalConfig{
+		MetricsPass: []string{"elasticsearch_indices_*", "elasticsearch_jvm_*"},
+		MetricsDrop: []string{"*_total_scrapes", "/^elasticsearch_jvm_buffer_pool_/"},
+		LabelsDrop:  []string{"es_*_node", "agent_hostname"},
+	}}
+	if err := ic.InitInternalConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	labels := func() map[string]string {
+		return map[string]string{"name": "es01", "es_data_node": "true", "es_master_node": "false"}
+	}
+	slist := types.NewSampleList()
+	slist.PushSample("elasticsearch", "indices_docs", 10, labels())
+	slist.PushSample("elasticsearch", "indices_total_scrapes", 1, labels())
+	slist.PushSample("elasticsearch", "jvm_memory_used_bytes", 1024, labels())
+	slist.PushSample("elasticsearch", "jvm_buffer_pool_used_bytes", 512, labels())
+	slist.PushSample("elasticsearch", "os_cpu_percent", 5, labels())
+
+	ss := ic.Process(slist).PopBackAll()
+	if len(ss) != 2 || ss[0].Metric != "elasticsearch_indices_docs" || ss[1].Metric != "elasticsearch_jvm_memory_used_bytes" {
+		t.Fatalf("unexpected samples: %v", ss)
+	}
+	for _, s := range ss {
+		if len(s.Labels) != 2 || s.Labels["name"] != "es01" || s.Labels["env"] != "prod" {
+			t.Fatalf("unexpected labels of %s: %v", s.Metric, s.Labels)
+		}
+	}
+
+	for _, bad := range []InternalConfig{{MetricsDrop: []string{"/(/"}}, {LabelsDrop: []string{"es_[a"}}} {
+		if err := bad.InitInternalConfig(); err == nil {
+			t.Errorf("expected an error for %v", bad)
+		}
+	}
+}
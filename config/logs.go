@@ -3,6 +3,10 @@
 package config
 
 import (
+	"runtime"
+	"strings"
+	"time"
+
 	"github.com/IBM/sarama"
 
 	logsconfig "flashcat.cloud/categraf/config/logs"
@@ -12,6 +16,10 @@ import (
 const (
 	Docker     = "docker"
 	Kubernetes = "kubernetes"
+
+	ContainerLogsFromSocket = "socket"
+	ContainerLogsFromFiles  = "files"
+	ContainerLogsFromAuto   = "auto"
 )
 
 type (
@@ -27,24 +35,47 @@ type (
 		RunPath               string                       `json:"run_path" toml:"run_path"`
 		OpenFilesLimit        int                          `json:"open_files_limit" toml:"open_files_limit"`
 		ScanPeriod            int                          `json:"scan_period" toml:"scan_period"`
+		RegistryTTL           Duration                     `json:"registry_ttl" toml:"registry_ttl"`
+		DockerInspectCacheTTL Duration                     `json:"docker_inspect_cache_ttl" toml:"docker_inspect_cache_ttl"`
 		FrameSize             int                          `json:"frame_size" toml:"frame_size"`
 		CollectContainerAll   bool                         `json:"collect_container_all" toml:"collect_container_all"`
 		ContainerInclude      []string                     `json:"container_include" toml:"container_include"`
 		ContainerExclude      []string                     `json:"container_exclude" toml:"container_exclude"`
+		ContainerLogsFrom     string                       `json:"collect_container_logs_from" toml:"collect_container_logs_from"`
 		GlobalProcessingRules []*logsconfig.ProcessingRule `json:"processing_rules" toml:"processing_rules"`
+		GlobalMetricRules     []*logsconfig.MetricRule     `json:"metric_rules" toml:"metric_rules"`
+		RateLimit             *logsconfig.RateLimit        `json:"rate_limit" toml:"rate_limit"`
 		Items                 []*logsconfig.LogsConfig     `json:"items" toml:"items"`
 		Accuracy              string                       `toml:"accuracy" json:"accuracy"`
 		KafkaConfig
 		KubeConfig
+		// Elasticsearch configures send_type = "elasticsearch"
+		Elasticsearch *LogsElasticsearch `json:"elasticsearch" toml:"elasticsearch"`
+		// HTTP configures send_type = "http"
+		HTTP *LogsHTTP `json:"http" toml:"http"`
 
 		ChanSize            int `toml:"chan_size" json:"chan_size"`
-		Pipeline            int `toml:"pipeline" json:"pipeline"`
+		Pipelines           int `toml:"logs_pipelines" json:"logs_pipelines"`
+		Pipeline            int `toml:"pipeline" json:"pipeline"` // former name of logs_pipelines
+		BatchMaxCount       int `toml:"batch_max_count" json:"batch_max_count"`
 		BatchMaxSize        int `toml:"batch_max_size" json:"batch_max_size"`
 		BatchMaxContentSize int `toml:"batch_max_content_size" json:"batch_max_content_size"`
 		BatchConcurrence    int `toml:"batch_max_concurrence" json:"batch_max_concurrence"`
 		ProducerTimeout     int `toml:"producer_timeout" json:"producer_timeout"`
 
+		// the logs overflowing the sender channel are spooled to logs_disk_buffer_path/<pipeline>
+		// and replayed once the sender recovers, disabled when empty
+		DiskBufferPath     string `toml:"logs_disk_buffer_path" json:"logs_disk_buffer_path"`
+		DiskBufferMaxBytes int64  `toml:"logs_disk_buffer_max_bytes" json:"logs_disk_buffer_max_bytes"`
+
+		// the logs of the containers dying before they are tailed are read once
+		MaxDeadContainerLogBytes   int64    `toml:"max_dead_container_log_bytes" json:"max_dead_container_log_bytes"`
+		CollectDeadContainersSince Duration `toml:"collect_dead_containers_since" json:"collect_dead_containers_since"`
+
 		EnableCollectContainer bool `json:"enable_collect_container" toml:"enable_collect_container"`
+
+		// the logs are tagged with cloud_provider, instance_id, region and az from the metadata of the cloud
+		IncludeCloudTags bool `json:"logs_include_cloud_tags" toml:"logs_include_cloud_tags"`
 	}
 	KafkaConfig struct {
 		Topic   string   `json:"topic" toml:"topic"`
@@ -65,6 +96,43 @@ type (
 		CertificateAuth []string `toml:"certificate_authorities"`
 		tls.ClientConfig
 		PartitionStrategy string `toml:"partition_strategy"`
+		// PartitionKey is the key of the messages: hostname, source or tag:<name>, defaults to <hostname>/<identifier>
+		PartitionKey string `toml:"partition_key"`
+
+		// batching of the async producer (batch_max_concurrence > 0)
+		FlushMaxMessages int      `toml:"flush_max_messages"`
+		FlushMaxBytes    int      `toml:"flush_max_bytes"`
+		Linger           Duration `toml:"linger"`
+	}
+	// LogsElasticsearch configures the logs sent to elasticsearch or opensearch with the bulk api,
+	// the batches follow batch_wait, batch_max_size and batch_max_content_size and are gzipped
+	// with use_compression
+	LogsElasticsearch struct {
+		// defaults to the comma separated send_to, e.g. http://127.0.0.1:9200
+		Hosts []string `json:"hosts" toml:"hosts"`
+		// %Y, %m, %d and %H are replaced by the date of the logs in UTC
+		Index    string `json:"index" toml:"index"`
+		Username string `json:"username" toml:"username"`
+		Password string `json:"password" toml:"password"`
+		// the base64 encoded id:api_key
+		APIKey string `json:"api_key" toml:"api_key"`
+		// the documents rejected with 429 are sent again max_retries times with backoff
+		MaxRetries int      `json:"max_retries" toml:"max_retries"`
+		Timeout    Duration `json:"timeout" toml:"timeout"`
+		tls.ClientConfig
+	}
+	// LogsHTTP configures the batches posted with send_type = "http"
+	LogsHTTP struct {
+		// gzip or none, defaults to use_compression
+		Compression string `json:"compression" toml:"compression"`
+		// the gzip level, defaults to compression_level
+		CompressionLevel int `json:"compression_level" toml:"compression_level"`
+		// the batches smaller than compression_min_bytes are sent uncompressed
+		CompressionMinBytes int `json:"compression_min_bytes" toml:"compression_min_bytes"`
+		// set on every request, e.g. X-Scope-OrgID
+		Headers map[string]string `json:"headers" toml:"headers"`
+		// the client certificate of mutual tls, https is used when use_tls is true
+		tls.ClientConfig
 	}
 	KubeConfig struct {
 		KubeletHTTPPort  int    `json:"kubernetes_http_kubelet_port" toml:"kubernetes_http_kubelet_port"`
@@ -74,6 +142,30 @@ type (
 	}
 )
 
+// KafkaBrokers returns the brokers of the kafka sender, send_to takes precedence over brokers
+func KafkaBrokers() string {
+	if len(Config.Logs.SendTo) == 0 {
+		return strings.Join(Config.Logs.Brokers, ",")
+	}
+	return Config.Logs.SendTo
+}
+
+// ElasticsearchHosts returns the hosts of the elasticsearch sender, hosts takes precedence over send_to
+func ElasticsearchHosts() string {
+	if Config.Logs.Elasticsearch != nil && len(Config.Logs.Elasticsearch.Hosts) != 0 {
+		return strings.Join(Config.Logs.Elasticsearch.Hosts, ",")
+	}
+	return Config.Logs.SendTo
+}
+
+// ElasticsearchIndex returns the index pattern of the elasticsearch sender
+func ElasticsearchIndex() string {
+	if Config.Logs.Elasticsearch == nil || Config.Logs.Elasticsearch.Index == "" {
+		return "categraf-logs-%Y.%m.%d"
+	}
+	return Config.Logs.Elasticsearch.Index
+}
+
 func GetLogRunPath() string {
 	if len(Config.Logs.RunPath) == 0 {
 		Config.Logs.RunPath = "/opt/categraf/run"
@@ -98,17 +190,55 @@ func FileScanPeriod() int {
 	return Config.Logs.ScanPeriod
 }
 
+// LogRegistryTTL is how long the offset of a file which is no longer tailed is kept
+func LogRegistryTTL() time.Duration {
+	if Config.Logs.RegistryTTL <= 0 {
+		Config.Logs.RegistryTTL = Duration(23 * time.Hour)
+	}
+	return time.Duration(Config.Logs.RegistryTTL)
+}
+
+// DockerInspectCacheTTL is how long a docker inspect is cached, the inspect of a container is
+// dropped from the cache earlier when the container dies or is destroyed
+func DockerInspectCacheTTL() time.Duration {
+	if Config.Logs.DockerInspectCacheTTL <= 0 {
+		Config.Logs.DockerInspectCacheTTL = Duration(10 * time.Second)
+	}
+	return time.Duration(Config.Logs.DockerInspectCacheTTL)
+}
+
+// MaxDeadContainerLogBytes is the most bytes of logs read from a container which died before
+// it was tailed, 10MiB by default, negative to not read the logs of the dead containers
+func MaxDeadContainerLogBytes() int64 {
+	if Config.Logs.MaxDeadContainerLogBytes == 0 {
+		Config.Logs.MaxDeadContainerLogBytes = 10 * 1024 * 1024
+	}
+	return Config.Logs.MaxDeadContainerLogBytes
+}
+
+// CollectDeadContainersSince is the window before the start of categraf in which the exited
+// containers are read once, 0 by default
+func CollectDeadContainersSince() time.Duration {
+	return time.Duration(Config.Logs.CollectDeadContainersSince)
+}
+
 func LogFrameSize() int {
 	if Config.Logs.FrameSize == 0 {
 		Config.Logs.FrameSize = 9000
 	}
 	return Config.Logs.FrameSize
 }
+
+// NumberOfPipelines returns logs_pipelines, or pipeline when it's not set,
+// and defaults to one pipeline per cpu up to 4
 func NumberOfPipelines() int {
-	if Config.Logs.Pipeline == 0 {
-		Config.Logs.Pipeline = 4
+	if Config.Logs.Pipelines == 0 {
+		Config.Logs.Pipelines = Config.Logs.Pipeline
 	}
-	return Config.Logs.Pipeline
+	if Config.Logs.Pipelines <= 0 {
+		Config.Logs.Pipelines = min(4, runtime.NumCPU())
+	}
+	return Config.Logs.Pipelines
 }
 
 func ChanSize() int {
@@ -118,7 +248,12 @@ func ChanSize() int {
 	return Config.Logs.ChanSize
 }
 
+// BatchMaxSize returns the maximum number of the messages of a batch,
+// batch_max_count takes precedence over batch_max_size
 func BatchMaxSize() int {
+	if Config.Logs.BatchMaxCount > 0 {
+		return Config.Logs.BatchMaxCount
+	}
 	if Config.Logs.BatchMaxSize == 0 {
 		Config.Logs.BatchMaxSize = 100
 	}
@@ -146,6 +281,19 @@ func ClientTimeout() int {
 	return Config.Logs.ProducerTimeout
 }
 
+// LogsDiskBufferPath returns the directory of the disk buffers, empty when disabled
+func LogsDiskBufferPath() string {
+	return Config.Logs.DiskBufferPath
+}
+
+// LogsDiskBufferMaxBytes returns the max size of the disk buffers, shared by the pipelines
+func LogsDiskBufferMaxBytes() int64 {
+	if Config.Logs.DiskBufferMaxBytes <= 0 {
+		Config.Logs.DiskBufferMaxBytes = 1024 * 1024 * 1024
+	}
+	return Config.Logs.DiskBufferMaxBytes
+}
+
 func ValidatePodContainerID() bool {
 	return false
 }
@@ -165,6 +313,17 @@ func GetContainerCollectAll() bool {
 	return Config.Logs.CollectContainerAll
 }
 
+// GetContainerLogsFrom returns where the container logs are read from: the docker API
+// (socket), the log files of the containers (files) or the first available (auto)
+func GetContainerLogsFrom() string {
+	switch v := strings.ToLower(Config.Logs.ContainerLogsFrom); v {
+	case ContainerLogsFromSocket, ContainerLogsFromFiles:
+		return v
+	default:
+		return ContainerLogsFromAuto
+	}
+}
+
 func GetContainerIncludeList() []string {
 	if Config.Logs.ContainerInclude == nil {
 		return []string{}
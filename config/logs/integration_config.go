@@ -10,6 +10,9 @@ package logs
 import (
 	"fmt"
 	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/pkg/tls"
 )
 
 // Logs source types
@@ -20,8 +23,14 @@ const (
 	DockerType        = "docker"
 	JournaldType      = "journald"
 	WindowsEventType  = "windows_event"
+	EventLogType      = "eventlog"
 	SnmpTrapsType     = "snmp_traps"
 	StringChannelType = "string_channel"
+	SyslogType        = "syslog"
+
+	// the framings of the tcp and udp sources
+	NewlineFraming      = "newline"
+	LengthPrefixFraming = "length_prefix"
 
 	// UTF16BE for UTF-16 Big endian encoding
 	UTF16BE string = "utf-16-be"
@@ -54,10 +63,15 @@ type (
 		Encoding     string   `mapstructure:"encoding" json:"encoding" toml:"encoding"`                   // File
 		ExcludePaths []string `mapstructure:"exclude_paths" json:"exclude_paths" toml:"exclude_paths"`    // File
 		TailingMode  string   `mapstructure:"start_position" json:"start_position" toml:"start_position"` // File
+		// TailFrom is end, beginning or since:<duration> for the files without a registered
+		// offset, since seeks to the first line more recent than the duration
+		TailFrom string `mapstructure:"tail_from" json:"tail_from" toml:"tail_from"` // File
 
-		IncludeUnits  []string `mapstructure:"include_units" json:"include_units" toml:"include_units"`    // Journald
-		ExcludeUnits  []string `mapstructure:"exclude_units" json:"exclude_units" toml:"exclude_units"`    // Journald
-		ContainerMode bool     `mapstructure:"container_mode" json:"container_mode" toml:"container_mode"` // Journald
+		IncludeUnits      []string `mapstructure:"include_units" json:"include_units" toml:"include_units"`                // Journald
+		Units             []string `mapstructure:"units" json:"units" toml:"units"`                                        // Journald, same as include_units
+		ExcludeUnits      []string `mapstructure:"exclude_units" json:"exclude_units" toml:"exclude_units"`                // Journald
+		IncludePriorities []string `mapstructure:"include_priorities" json:"include_priorities" toml:"include_priorities"` // Journald
+		ContainerMode     bool     `mapstructure:"container_mode" json:"container_mode" toml:"container_mode"`             // Journald
 
 		Image string // Docker
 		Label string // Docker
@@ -66,8 +80,19 @@ type (
 		// Identifier contains the container ID
 		Identifier string // Docker
 
+		Protocol       string `mapstructure:"protocol" json:"protocol" toml:"protocol"`                         // Syslog, udp or tcp
+		MaxMessageSize int    `mapstructure:"max_message_size" json:"max_message_size" toml:"max_message_size"` // Syslog, Network
+		// Framing splits the stream of the tcp and udp sources, newline or length_prefix, a 4-byte
+		// big-endian length before each message
+		Framing string `mapstructure:"framing" json:"framing" toml:"framing"` // Network
+		// tls_cert and tls_key enable tls, tls_allowed_cacerts requires client certificates
+		tls.ServerConfig // Syslog over tcp, TCP
+
 		ChannelPath string `mapstructure:"channel_path" json:"channel_path" toml:"channel_path"` // Windows Event
-		Query       string // Windows Event
+		Query       string // Windows Event, EventLog: XPath filter of the events
+		// EventLogChannel is the event log channel, e.g. System, Application or
+		// Microsoft-Windows-Sysmon/Operational
+		EventLogChannel string `mapstructure:"channel" json:"channel" toml:"channel"` // EventLog
 
 		// used as input only by the Channel tailer.
 		// could have been unidirectional but the tailer could not close it in this case.
@@ -78,6 +103,27 @@ type (
 		SourceCategory  string
 		Tags            []string
 		ProcessingRules []*ProcessingRule `mapstructure:"log_processing_rules" json:"log_processing_rules" toml:"log_processing_rules"`
+		MetricRules     []*MetricRule     `mapstructure:"log_metric_rules" json:"log_metric_rules" toml:"log_metric_rules"`
+		Multiline       *MultilineConfig  `mapstructure:"multiline" json:"multiline" toml:"multiline"`
+		// RateLimit overrides the global rate limit of the logs for this source
+		RateLimit *RateLimit `mapstructure:"rate_limit" json:"rate_limit" toml:"rate_limit"`
+
+		// AutoParseJSON parses the messages starting with {, after the multiline aggregation and
+		// before the processing rules, the keys select the fields of the message, nested with dots
+		AutoParseJSON bool     `mapstructure:"auto_parse_json" json:"auto_parse_json" toml:"auto_parse_json"`
+		TimestampKey  string   `mapstructure:"timestamp_key" json:"timestamp_key" toml:"timestamp_key"`
+		LevelKey      string   `mapstructure:"level_key" json:"level_key" toml:"level_key"`
+		MessageKey    string   `mapstructure:"message_key" json:"message_key" toml:"message_key"`
+		TagKeys       []string `mapstructure:"tag_keys" json:"tag_keys" toml:"tag_keys"`
+		// JSONMaxSize is the size of the largest message parsed, the larger ones are sent as is
+		JSONMaxSize int `mapstructure:"json_max_size" json:"json_max_size" toml:"json_max_size"`
+
+		// TimestampRegex and TimestampFormat parse the time of the events out of the lines,
+		// the timestamps without a zone are in Timezone
+		TimestampRegex  string `mapstructure:"timestamp_regex" json:"timestamp_regex" toml:"timestamp_regex"`
+		TimestampFormat string `mapstructure:"timestamp_format" json:"timestamp_format" toml:"timestamp_format"`
+		Timezone        string `mapstructure:"timezone" json:"timezone" toml:"timezone"`
+		timestampParser *TimestampParser
 
 		AutoMultiLine               bool    `mapstructure:"auto_multi_line_detection" json:"auto_multi_line_detection" toml:"auto_multi_line_detectio"`
 		AutoMultiLineSampleSize     int     `mapstructure:"auto_multi_line_sample_size" json:"auto_multi_line_sample_size" toml:"auto_multi_line_sample_size"`
@@ -142,29 +188,200 @@ func (c *LogsConfig) Validate() error {
 		if err != nil {
 			return err
 		}
-	case c.Type == TCPType && c.Port == 0:
-		return fmt.Errorf("tcp source must have a port")
-	case c.Type == UDPType && c.Port == 0:
-		return fmt.Errorf("udp source must have a port")
+	case c.Type == TCPType || c.Type == UDPType:
+		if c.Port == 0 {
+			return fmt.Errorf("%s source must have a port", c.Type)
+		}
+		switch c.Framing {
+		case "", NewlineFraming, LengthPrefixFraming:
+		default:
+			return fmt.Errorf("invalid framing '%s', must be newline or length_prefix", c.Framing)
+		}
+		if c.Type == UDPType && (c.TLSCert != "" || c.TLSKey != "") {
+			return fmt.Errorf("tls requires a tcp source")
+		}
+		if c.MaxMessageSize < 0 {
+			return fmt.Errorf("max_message_size must not be negative")
+		}
+	case c.Type == SyslogType:
+		if c.Port == 0 {
+			return fmt.Errorf("syslog source must have a port")
+		}
+		switch c.Protocol {
+		case "", UDPType, TCPType:
+		default:
+			return fmt.Errorf("invalid syslog protocol '%s', must be udp or tcp", c.Protocol)
+		}
+		if c.Protocol != TCPType && (c.TLSCert != "" || c.TLSKey != "") {
+			return fmt.Errorf("syslog over tls requires protocol tcp")
+		}
+		if c.MaxMessageSize < 0 {
+			return fmt.Errorf("syslog max_message_size must not be negative")
+		}
+	case c.Type == EventLogType:
+		if c.EventLogChannel == "" && c.ChannelPath == "" {
+			return fmt.Errorf("eventlog source must have a channel")
+		}
+		if c.TailingMode != "" && c.TailingMode != "beginning" && c.TailingMode != "end" {
+			return fmt.Errorf("invalid eventlog start_position '%s', must be beginning or end", c.TailingMode)
+		}
+	case c.Type == JournaldType:
+		for _, p := range c.IncludePriorities {
+			if _, ok := JournaldPriority(p); !ok {
+				return fmt.Errorf("invalid journald priority '%s', must be 0-7 or one of emerg, alert, crit, err, warning, notice, info, debug", p)
+			}
+		}
 	}
 	err := ValidateProcessingRules(c.ProcessingRules)
 	if err != nil {
 		return err
 	}
+	if err := CompileMetricRules(c.MetricRules); err != nil {
+		return err
+	}
+	if err := c.RateLimit.Validate(); err != nil {
+		return err
+	}
+	if c.JSONMaxSize < 0 {
+		return fmt.Errorf("invalid json_max_size %d, must be positive", c.JSONMaxSize)
+	}
+	if c.Multiline != nil {
+		if err := c.Multiline.Compile(); err != nil {
+			return err
+		}
+	}
+	if err := c.compileTimestampParser(); err != nil {
+		return err
+	}
 	return CompileProcessingRules(c.ProcessingRules)
 }
 
+func (c *LogsConfig) compileTimestampParser() error {
+	if c.TimestampRegex == "" && c.TimestampFormat == "" && c.Timezone == "" {
+		return nil
+	}
+	p, err := NewTimestampParser(c.TimestampRegex, c.TimestampFormat, c.Timezone)
+	if err != nil {
+		return err
+	}
+	c.timestampParser = p
+	return nil
+}
+
+// TimestampParser returns the parser of the timestamps of the lines, nil if the source has no
+// timestamp_format or if it is invalid
+func (c *LogsConfig) TimestampParser() *TimestampParser {
+	if c.timestampParser == nil && c.TimestampFormat != "" {
+		if err := c.compileTimestampParser(); err != nil {
+			return nil
+		}
+	}
+	return c.timestampParser
+}
+
+// DefaultJSONMaxSize is the default size of the largest message parsed with auto_parse_json
+const DefaultJSONMaxSize = 64 * 1024
+
+// GetJSONMaxSize returns the size of the largest message parsed with auto_parse_json
+func (c *LogsConfig) GetJSONMaxSize() int {
+	if c.JSONMaxSize == 0 {
+		return DefaultJSONMaxSize
+	}
+	return c.JSONMaxSize
+}
+
+// EventLogChannelName returns the channel of the eventlog source, channel_path is accepted
+// as the channel of the windows_event sources
+func (c *LogsConfig) EventLogChannelName() string {
+	if c.EventLogChannel != "" {
+		return c.EventLogChannel
+	}
+	return c.ChannelPath
+}
+
+// JournaldUnits returns the units of the journald source, from both include_units and units
+func (c *LogsConfig) JournaldUnits() []string {
+	units := make([]string, 0, len(c.IncludeUnits)+len(c.Units))
+	return append(append(units, c.IncludeUnits...), c.Units...)
+}
+
+var journaldPriorities = map[string]string{
+	"emerg":   "0",
+	"alert":   "1",
+	"crit":    "2",
+	"err":     "3",
+	"error":   "3",
+	"warning": "4",
+	"warn":    "4",
+	"notice":  "5",
+	"info":    "6",
+	"debug":   "7",
+}
+
+// JournaldPriority returns the value of the PRIORITY field of a journal entry of the
+// priority p, either a syslog level name, e.g. "err", or a number from 0 to 7
+func JournaldPriority(p string) (string, bool) {
+	p = strings.ToLower(strings.TrimSpace(p))
+	if len(p) == 1 && p[0] >= '0' && p[0] <= '7' {
+		return p, true
+	}
+	v, ok := journaldPriorities[p]
+	return v, ok
+}
+
 func (c *LogsConfig) validateTailingMode() error {
 	mode, found := TailingModeFromString(c.TailingMode)
 	if !found && c.TailingMode != "" {
 		return fmt.Errorf("invalid tailing mode '%v' for %v", c.TailingMode, c.Path)
 	}
+	if c.TailFrom != "" {
+		if c.TailingMode != "" {
+			return fmt.Errorf("start_position and tail_from are exclusive for %v", c.Path)
+		}
+		var ok bool
+		if mode, ok = c.TailFromMode(); !ok {
+			return fmt.Errorf("invalid tail_from '%v' for %v, must be end, beginning or since:<duration>", c.TailFrom, c.Path)
+		}
+		if _, since := c.TailFromSince(); since {
+			// the files are read from the first recent line, not from the beginning
+			return nil
+		}
+	}
 	if ContainsWildcard(c.Path) && (mode == Beginning || mode == ForceBeginning) {
 		return fmt.Errorf("tailing from the beginning is not supported for wildcard path %v", c.Path)
 	}
 	return nil
 }
 
+// TailFromMode returns the tailing mode of tail_from, false if tail_from is not set or invalid.
+// since:<duration> tails from the beginning so that the registered offsets are honored, the
+// files without an offset are read from the first line more recent than the duration
+func (c *LogsConfig) TailFromMode() (TailingMode, bool) {
+	switch c.TailFrom {
+	case "end":
+		return End, true
+	case "beginning":
+		return Beginning, true
+	}
+	if _, ok := c.TailFromSince(); ok {
+		return Beginning, true
+	}
+	return End, false
+}
+
+// TailFromSince returns the duration of tail_from = "since:<duration>"
+func (c *LogsConfig) TailFromSince() (time.Duration, bool) {
+	v, ok := strings.CutPrefix(c.TailFrom, "since:")
+	if !ok {
+		return 0, false
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
 // ContainsWildcard returns true if the path contains any wildcard character
 func ContainsWildcard(path string) bool {
 	return strings.ContainsAny(path, "*?[")
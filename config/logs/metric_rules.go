@@ -0,0 +1,97 @@
+//go:build !no_logs
+
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Metric rule types
+const (
+	CounterMetric   = "counter"
+	HistogramMetric = "histogram"
+)
+
+// DefaultHistogramBuckets are the buckets of the histograms without buckets, in seconds
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// MetricRule turns the log lines matching a pattern into a counter, or into a histogram
+// of a numeric capture group, e.g. the latency of the access logs
+type MetricRule struct {
+	Match  string `mapstructure:"match" json:"match" toml:"match"`
+	Metric string `mapstructure:"metric" json:"metric" toml:"metric"`
+	// counter (default) or histogram
+	Type string `mapstructure:"type" json:"type" toml:"type"`
+	// the labels are the named capture groups of match, service, source or the tags of the logs
+	LabelsFrom []string `mapstructure:"labels_from" json:"labels_from" toml:"labels_from"`
+	// the capture group, by name or number, holding the value observed by the histogram
+	ValueFrom string    `mapstructure:"value_from" json:"value_from" toml:"value_from"`
+	Buckets   []float64 `mapstructure:"buckets" json:"buckets" toml:"buckets"`
+	// the matching lines are counted but not forwarded
+	DropAfterMatch bool `mapstructure:"drop_after_match" json:"drop_after_match" toml:"drop_after_match"`
+
+	Regex      *regexp.Regexp `json:"-" toml:"-"`
+	ValueGroup int            `json:"-" toml:"-"`
+}
+
+// CompileMetricRules validates and compiles the rules, so that the misconfigured ones are
+// reported when the config is loaded
+func CompileMetricRules(rules []*MetricRule) error {
+	for _, rule := range rules {
+		if rule.Metric == "" {
+			return fmt.Errorf("all metric rules must have a metric")
+		}
+		if !metricNamePattern.MatchString(rule.Metric) {
+			return fmt.Errorf("invalid metric name %s of metric rule", rule.Metric)
+		}
+		if rule.Match == "" {
+			return fmt.Errorf("no match provided for metric rule: %s", rule.Metric)
+		}
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return fmt.Errorf("invalid match %s for metric rule %s: %v", rule.Match, rule.Metric, err)
+		}
+		rule.Regex = re
+
+		switch rule.Type {
+		case "", CounterMetric:
+			rule.Type = CounterMetric
+			if rule.ValueFrom != "" {
+				return fmt.Errorf("value_from requires type histogram for metric rule: %s", rule.Metric)
+			}
+		case HistogramMetric:
+			if rule.ValueGroup, err = captureGroup(re, rule.ValueFrom); err != nil {
+				return fmt.Errorf("metric rule %s: %v", rule.Metric, err)
+			}
+			if len(rule.Buckets) == 0 {
+				rule.Buckets = DefaultHistogramBuckets
+			}
+			if !sort.Float64sAreSorted(rule.Buckets) {
+				return fmt.Errorf("buckets of metric rule %s must be sorted", rule.Metric)
+			}
+		default:
+			return fmt.Errorf("type %s is not supported for metric rule %s", rule.Type, rule.Metric)
+		}
+	}
+	return nil
+}
+
+// captureGroup returns the index of the capture group given by name or by number
+func captureGroup(re *regexp.Regexp, group string) (int, error) {
+	if group == "" {
+		return 0, fmt.Errorf("value_from is required by the histograms")
+	}
+	if idx := re.SubexpIndex(group); idx > 0 {
+		return idx, nil
+	}
+	idx, err := strconv.Atoi(group)
+	if err != nil || idx <= 0 || idx > re.NumSubexp() {
+		return 0, fmt.Errorf("value_from %s is not a capture group of %s", group, re.String())
+	}
+	return idx, nil
+}
@@ -0,0 +1,79 @@
+//go:build !no_logs
+
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const (
+	// DefaultMultilineMaxBytes caps the size of an aggregated event
+	DefaultMultilineMaxBytes = 256 * 1024
+)
+
+// MultilineConfig aggregates the lines of an event, e.g. a java stack trace, into one message.
+// A line matching Pattern starts a new event, with Negate a line not matching Pattern does.
+type MultilineConfig struct {
+	Pattern string `mapstructure:"pattern" json:"pattern" toml:"pattern"`
+	Negate  bool   `mapstructure:"negate" json:"negate" toml:"negate"`
+	// Timeout in milliseconds after which a pending event is sent
+	Timeout  int `mapstructure:"timeout" json:"timeout" toml:"timeout"`
+	MaxBytes int `mapstructure:"max_bytes" json:"max_bytes" toml:"max_bytes"`
+
+	regex *regexp.Regexp
+}
+
+// Compile compiles the pattern, an empty pattern disables the aggregation
+func (m *MultilineConfig) Compile() error {
+	if m.Pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(m.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid multiline.pattern %s: %v", m.Pattern, err)
+	}
+	m.regex = re
+	return nil
+}
+
+// Regexp returns the compiled pattern, nil if the pattern is empty or invalid
+func (m *MultilineConfig) Regexp() *regexp.Regexp {
+	if m.regex == nil && m.Pattern != "" {
+		if err := m.Compile(); err != nil {
+			return nil
+		}
+	}
+	return m.regex
+}
+
+// FlushTimeout returns the timeout after which a pending event is sent
+func (m *MultilineConfig) FlushTimeout() time.Duration {
+	if m.Timeout <= 0 {
+		return AggregationTimeout()
+	}
+	return time.Duration(m.Timeout) * time.Millisecond
+}
+
+// Limit returns the max size of an aggregated event
+func (m *MultilineConfig) Limit() int {
+	if m.MaxBytes <= 0 {
+		return DefaultMultilineMaxBytes
+	}
+	return m.MaxBytes
+}
+
+// ParseMultilineConfig parses the JSON multiline config of a container label or a pod annotation,
+// e.g. {"pattern":"^\\d{4}-\\d{2}-\\d{2}","timeout":1000}
+func ParseMultilineConfig(s string) (*MultilineConfig, error) {
+	m := &MultilineConfig{}
+	if err := json.Unmarshal([]byte(s), m); err != nil {
+		return nil, fmt.Errorf("invalid multiline config %s: %v", s, err)
+	}
+	if err := m.Compile(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,23 @@
+//go:build !no_logs
+
+package logs
+
+import "fmt"
+
+// RateLimit bounds the lines and the bytes sent per second by a source, the lines over the
+// limit are dropped. 0 means unlimited.
+type RateLimit struct {
+	LinesPerSecond float64 `mapstructure:"lines_per_second" json:"lines_per_second" toml:"lines_per_second"`
+	BytesPerSecond float64 `mapstructure:"bytes_per_second" json:"bytes_per_second" toml:"bytes_per_second"`
+}
+
+// Validate returns an error if the limits are negative
+func (r *RateLimit) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.LinesPerSecond < 0 || r.BytesPerSecond < 0 {
+		return fmt.Errorf("rate_limit must not be negative")
+	}
+	return nil
+}
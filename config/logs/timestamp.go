@@ -0,0 +1,144 @@
+//go:build !no_logs
+
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unixLayout is the layout of the timestamps in seconds since the epoch, strftime %s
+const unixLayout = "unix"
+
+// strftimeLayouts maps the strftime directives to the go layouts
+var strftimeLayouts = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	// the fraction of the seconds, of any number of digits when it follows a dot or a comma
+	'f': "999999999",
+	'L': "000",
+	'p': "PM",
+	'b': "Jan",
+	'h': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'j': "002",
+	'z': "-0700",
+	'Z': "MST",
+	'T': "15:04:05",
+	'F': "2006-01-02",
+	'D': "01/02/06",
+	'R': "15:04",
+	'%': "%",
+}
+
+// TimestampParser parses the time of the events out of the lines of a source, with the
+// timestamp_regex, timestamp_format and timezone of the source
+type TimestampParser struct {
+	regex  *regexp.Regexp
+	layout string
+	// the location of the timestamps without a zone
+	location *time.Location
+}
+
+// NewTimestampParser compiles the regexp and the format of the timestamps. The timestamp is
+// the group named timestamp of the regexp, else its first group, else the whole match. The
+// format is a strftime format when it contains %, e.g. %Y-%m-%d %H:%M:%S, else a go layout.
+// The timestamps without a zone are in timezone, an IANA name, Local by default.
+func NewTimestampParser(pattern, format, timezone string) (*TimestampParser, error) {
+	if pattern == "" || format == "" {
+		return nil, fmt.Errorf("timestamp_regex and timestamp_format must be set together")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp_regex %s: %v", pattern, err)
+	}
+	layout := format
+	if strings.Contains(format, "%") {
+		if layout, err = StrftimeLayout(format); err != nil {
+			return nil, err
+		}
+	}
+	location := time.Local
+	if timezone != "" {
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %s: %v", timezone, err)
+		}
+	}
+	return &TimestampParser{regex: re, layout: layout, location: location}, nil
+}
+
+// StrftimeLayout converts a strftime format to a go layout, %s is the unix time in seconds
+// and must be the whole format
+func StrftimeLayout(format string) (string, error) {
+	if format == "%s" {
+		return unixLayout, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b.WriteByte(format[i])
+			continue
+		}
+		if i+1 == len(format) {
+			return "", fmt.Errorf("invalid timestamp_format %s: trailing %%", format)
+		}
+		i++
+		layout, ok := strftimeLayouts[format[i]]
+		if !ok {
+			return "", fmt.Errorf("invalid timestamp_format %s: unsupported directive %%%c", format, format[i])
+		}
+		b.WriteString(layout)
+	}
+	return b.String(), nil
+}
+
+// Parse returns the timestamp of the line, false if the regexp doesn't match or the timestamp
+// doesn't fit the format. The timestamps without a year, e.g. of syslog, are of the last 12
+// months before now.
+func (p *TimestampParser) Parse(line []byte, now time.Time) (time.Time, bool) {
+	m := p.regex.FindSubmatchIndex(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	group := 0
+	if i := p.regex.SubexpIndex("timestamp"); i > 0 {
+		group = i
+	} else if p.regex.NumSubexp() > 0 {
+		group = 1
+	}
+	if m[2*group] < 0 {
+		return time.Time{}, false
+	}
+	s := string(line[m[2*group]:m[2*group+1]])
+
+	if p.layout == unixLayout {
+		sec, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return time.Unix(sec, 0), true
+	}
+	ts, err := time.ParseInLocation(p.layout, s, p.location)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if ts.Year() == 0 {
+		ts = ts.AddDate(now.In(p.location).Year(), 0, 0)
+		if ts.After(now.Add(24 * time.Hour)) {
+			ts = ts.AddDate(-1, 0, 0)
+		}
+	}
+	return ts, true
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+// SecretProviders configures the stores of the secrets referenced by the input configs,
+// a reference being replaced by the value of the secret when the input is loaded, e.g.
+// password = "${vault:secret/data/db#password}" or password = "${k8s:default/db#password}"
+type SecretProviders struct {
+	// how long the resolved values are cached unless the store tells a lease, default 5m
+	RefreshInterval Duration `toml:"refresh_interval"`
+
+	Vault      *VaultSecretProvider      `toml:"vault"`
+	Kubernetes *KubernetesSecretProvider `toml:"kubernetes"`
+}
+
+type VaultSecretProvider struct {
+	Address   string `toml:"address"`
+	Namespace string `toml:"namespace"`
+	// token, approle or kubernetes
+	AuthMethod string `toml:"auth_method"`
+
+	// token auth, the file is re-read when the token expires, e.g. the sink of a vault agent
+	Token     string `toml:"token"`
+	TokenFile string `toml:"token_file"`
+
+	// approle auth, the files are read again at every login, e.g. a secret_id delivered by
+	// the orchestrator
+	AppRoleRoleID       string `toml:"approle_role_id"`
+	AppRoleRoleIDFile   string `toml:"approle_role_id_file"`
+	AppRoleSecretID     string `toml:"approle_secret_id"`
+	AppRoleSecretIDFile string `toml:"approle_secret_id_file"`
+	AppRoleMountPath    string `toml:"approle_mount_path"`
+
+	// kubernetes auth
+	KubernetesRole      string `toml:"kubernetes_role"`
+	KubernetesMountPath string `toml:"kubernetes_mount_path"`
+	KubernetesTokenFile string `toml:"kubernetes_token_file"`
+
+	Timeout Duration `toml:"timeout"`
+
+	tls.ClientConfig
+}
+
+type KubernetesSecretProvider struct {
+	// the secrets are read from <mount_dir>/<namespace>/<name>/<key> when the file exists
+	MountDir string `toml:"mount_dir"`
+
+	// otherwise from the API server with the service account of the pod
+	UseAPI    bool     `toml:"use_api"`
+	APIServer string   `toml:"api_server"`
+	TokenFile string   `toml:"token_file"`
+	Timeout   Duration `toml:"timeout"`
+
+	tls.ClientConfig
+}
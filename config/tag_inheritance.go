@@ -0,0 +1,40 @@
+package config
+
+import "flashcat.cloud/categraf/types"
+
+// TagInheritance merges the global labels and agent_hostname into the samples emitted by
+// the inputs, the labels set by the inputs and their instances take precedence on conflict.
+// The global labels are expanded once, make a TagInheritance per gather.
+type TagInheritance struct {
+	labels map[string]string
+}
+
+func NewTagInheritance() *TagInheritance {
+	labels := GlobalLabels()
+	if !Config.Global.OmitHostname {
+		labels[agentHostnameLabelKey] = Config.GetHostname()
+	}
+	return &TagInheritance{labels: labels}
+}
+
+// Apply adds the inherited labels missing from the labels
+func (t *TagInheritance) Apply(labels map[string]string) {
+	for k, v := range t.labels {
+		if _, has := labels[k]; !has {
+			labels[k] = v
+		}
+	}
+}
+
+// ApplySamples adds the inherited labels to every sample
+func (t *TagInheritance) ApplySamples(samples []*types.Sample) {
+	for _, s := range samples {
+		if s == nil {
+			continue
+		}
+		if s.Labels == nil {
+			s.Labels = make(map[string]string, len(t.labels))
+		}
+		t.Apply(s.Labels)
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestTagInheritance(t *testing.T) {
+	Config = &ConfigType{Global: Global{
+		Labels: map[string]string{"datacenter": "us-east-1", "env": "prod"},
+	}}
+	HostInfo = &HostInfoCache{name: "h1"}
+
+	// two inputs gathered in the same cycle, the instance of redis overrides env
+	cpu := &InstanceConfig{}
+	redis := &InstanceConfig{InternalConfig: InternalConfig{Labels: map[string]string{"env": "staging"}}}
+	for _, ic := range []*InstanceConfig{cpu, redis} {
+		if err := ic.InitInternalConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cpuList := types.NewSampleList()
+	cpuList.PushSample("cpu", "usage_idle", 90, map[string]string{"cpu": "cpu-total"})
+	redisList := types.NewSampleList()
+	redisList.PushSample("redis", "connected_clients", 3, map[string]string{"datacenter": "eu-west-1"})
+
+	want := map[string]map[string]string{
+		"cpu_usage_idle": {
+			"cpu": "cpu-total", "datacenter": "us-east-1", "env": "prod", "agent_hostname": "h1",
+		},
+		"redis_connected_clients": {
+			"datacenter": "eu-west-1", "env": "staging", "agent_hostname": "h1",
+		},
+	}
+	for _, s := range append(cpu.Process(cpuList).PopBackAll(), redis.Process(redisList).PopBackAll()...) {
+		labels, has := want[s.Metric]
+		if !has {
+			t.Fatalf("unexpected metric %s", s.Metric)
+		}
+		delete(want, s.Metric)
+		if len(s.Labels) != len(labels) {
+			t.Fatalf("unexpected labels of %s: %v", s.Metric, s.Labels)
+		}
+		for k, v := range labels {
+			if s.Labels[k] != v {
+				t.Fatalf("unexpected label %s=%s of %s, %s expected", k, s.Labels[k], s.Metric, v)
+			}
+		}
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing metrics: %v", want)
+	}
+
+	Config.Global.OmitHostname = true
+	labels := map[string]string{}
+	NewTagInheritance().Apply(labels)
+	if _, has := labels["agent_hostname"]; has || labels["env"] != "prod" {
+		t.Fatalf("unexpected inherited labels with omit_hostname: %v", labels)
+	}
+}
@@ -0,0 +1,235 @@
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/globpath"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName   = "cgroup"
+	defaultRoot = "/sys/fs/cgroup"
+)
+
+type Cgroup struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Cgroup{}
+	})
+}
+
+func (c *Cgroup) Clone() inputs.Input {
+	return &Cgroup{}
+}
+
+func (c *Cgroup) Name() string {
+	return inputName
+}
+
+func (c *Cgroup) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(c.Instances))
+	for i := 0; i < len(c.Instances); i++ {
+		ret[i] = c.Instances[i]
+	}
+	return ret
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// Root is the mount point of the cgroup v2 unified hierarchy
+	Root string `toml:"root"`
+	// Patterns are the globs of the cgroups relative to the root, e.g. system.slice/docker-*.scope
+	Patterns []string `toml:"patterns"`
+
+	globs []*globpath.GlobPath
+
+	// the names of the block devices by major:minor
+	devicesLock sync.Mutex
+	devices     map[string]string
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Patterns) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.Root == "" {
+		ins.Root = defaultRoot
+	}
+	for _, p := range ins.Patterns {
+		g, err := globpath.Compile(filepath.Join(ins.Root, p))
+		if err != nil {
+			return fmt.Errorf("failed to compile pattern %s: %v", p, err)
+		}
+		ins.globs = append(ins.globs, g)
+	}
+	ins.devices = make(map[string]string)
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	for _, dir := range ins.cgroups() {
+		name, err := filepath.Rel(ins.Root, dir)
+		if err != nil {
+			continue
+		}
+		ins.gatherCgroup(dir, map[string]string{"cgroup": filepath.ToSlash(name)}, slist)
+	}
+}
+
+// cgroups returns the directories matching the patterns
+func (ins *Instance) cgroups() []string {
+	seen := make(map[string]struct{})
+	var dirs []string
+	for _, g := range ins.globs {
+		for _, p := range g.Match() {
+			if _, has := seen[p]; has {
+				continue
+			}
+			if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+				continue
+			}
+			seen[p] = struct{}{}
+			dirs = append(dirs, p)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// gatherCgroup reads the files of the cgroup, the files of the controllers not enabled
+// for the cgroup are missing and skipped
+func (ins *Instance) gatherCgroup(dir string, labels map[string]string, slist *types.SampleList) {
+	if v, ok := ins.readValue(dir, "memory.current"); ok {
+		slist.PushSample(inputName, "memory_current_bytes", v, labels)
+	}
+	if stats, ok := ins.readKeyValues(dir, "memory.stat"); ok {
+		for k, v := range stats {
+			slist.PushSample(inputName, "memory_stat_"+k, v, labels)
+		}
+	}
+	if stats, ok := ins.readKeyValues(dir, "cpu.stat"); ok {
+		for k, v := range stats {
+			// usage_usec, user_usec, system_usec, throttled_usec are converted to seconds
+			if strings.HasSuffix(k, "_usec") {
+				slist.PushSample(inputName, "cpu_"+strings.TrimSuffix(k, "_usec")+"_seconds", v/1e6, labels)
+			} else {
+				slist.PushSample(inputName, "cpu_"+k, v, labels)
+			}
+		}
+	}
+	if v, ok := ins.readValue(dir, "pids.current"); ok {
+		slist.PushSample(inputName, "pids_current", v, labels)
+	}
+	ins.gatherIOStat(dir, labels, slist)
+}
+
+// gatherIOStat reads io.stat, one line per device, e.g.
+// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func (ins *Instance) gatherIOStat(dir string, labels map[string]string, slist *types.SampleList) {
+	data, ok := ins.readFile(dir, "io.stat")
+	if !ok {
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		ioLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			ioLabels[k] = v
+		}
+		ioLabels["device"] = ins.deviceName(fields[0])
+		for _, field := range fields[1:] {
+			k, raw, found := strings.Cut(field, "=")
+			if !found {
+				continue
+			}
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				continue
+			}
+			slist.PushSample(inputName, "io_"+k, v, ioLabels)
+		}
+	}
+}
+
+// deviceName returns the name of the block device, e.g. sda, or major:minor when unknown
+func (ins *Instance) deviceName(majorMinor string) string {
+	ins.devicesLock.Lock()
+	defer ins.devicesLock.Unlock()
+	if name, has := ins.devices[majorMinor]; has {
+		return name
+	}
+	name := majorMinor
+	if target, err := os.Readlink(filepath.Join("/sys/dev/block", majorMinor)); err == nil {
+		name = filepath.Base(target)
+	}
+	ins.devices[majorMinor] = name
+	return name
+}
+
+func (ins *Instance) readFile(dir, file string) ([]byte, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Println("E! failed to read", filepath.Join(dir, file), "error:", err)
+		}
+		return nil, false
+	}
+	return data, true
+}
+
+// readValue reads a file holding a single value
+func (ins *Instance) readValue(dir, file string) (float64, bool) {
+	data, ok := ins.readFile(dir, file)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(string(bytes.TrimSpace(data)), 64)
+	if err != nil {
+		// e.g. max of the files holding limits
+		return 0, false
+	}
+	return v, true
+}
+
+// readKeyValues reads a flat keyed file, one "key value" pair per line
+func (ins *Instance) readKeyValues(dir, file string) (map[string]float64, bool) {
+	data, ok := ins.readFile(dir, file)
+	if !ok {
+		return nil, false
+	}
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = v
+	}
+	return values, true
+}
@@ -4,6 +4,9 @@
 package conntrack
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,6 +15,7 @@ import (
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/osx"
 	"flashcat.cloud/categraf/types"
 )
 
@@ -22,6 +26,8 @@ type Conntrack struct {
 	Dirs  []string `toml:"dirs"`
 	Files []string `toml:"files"`
 	Quiet bool     `toml:"quiet"`
+	// count the entries of /proc/net/nf_conntrack by protocol, the whole table is read
+	ProtocolCounts bool `toml:"protocol_counts"`
 }
 
 var dfltDirs = []string{
@@ -102,5 +108,130 @@ func (c *Conntrack) Gather(slist *types.SampleList) {
 		log.Println("E! Conntrack input failed to collect metrics. Is the conntrack kernel module loaded?")
 	}
 
+	// the names of the counters of nf_conntrack_stat for the table
+	if v, has := fields["ip_conntrack_count"]; has {
+		fields["current_total"] = v
+	}
+	if v, has := fields["ip_conntrack_max"]; has {
+		fields["max"] = v
+	}
 	slist.PushSamples("conntrack", fields)
+
+	c.gatherStat(slist)
+	if c.ProtocolCounts {
+		c.gatherProtocols(slist)
+	}
+}
+
+// gatherStat pushes the counters of /proc/net/stat/nf_conntrack summed across the cpus, e.g. the
+// drop, early_drop and insert_failed of the new connections dropped when the table is full
+func (c *Conntrack) gatherStat(slist *types.SampleList) {
+	f, err := os.Open(filepath.Join(osx.GetHostProc(), "net/stat/nf_conntrack"))
+	if err != nil {
+		if !c.Quiet {
+			log.Println("E! failed to open nf_conntrack stat:", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	stats, err := parseStat(f)
+	if err != nil {
+		log.Println("E! failed to parse nf_conntrack stat:", err)
+		return
+	}
+	for name, v := range stats {
+		slist.PushSample(inputName, name+"_total", v)
+	}
+}
+
+// parseStat sums the per cpu columns of nf_conntrack stat, the values are hexadecimal:
+//
+//	entries  clashres found new invalid ignore delete chainlength insert insert_failed drop ...
+//	00000a1f  00000000 00000012 00000000 00000003 0000f2a1 00000000 00000000 00000000 00000000 00000000 ...
+//
+// entries and chainlength are not counters and are skipped
+func parseStat(r io.Reader) (map[string]uint64, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("no header: %v", scanner.Err())
+	}
+	columns := strings.Fields(scanner.Text())
+	stats := make(map[string]uint64, len(columns))
+	for scanner.Scan() {
+		values := strings.Fields(scanner.Text())
+		if len(values) != len(columns) {
+			return nil, fmt.Errorf("%d values for %d columns", len(values), len(columns))
+		}
+		for i, column := range columns {
+			if column == "entries" || column == "chainlength" {
+				continue
+			}
+			v, err := strconv.ParseUint(values[i], 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %s of %s: %v", values[i], column, err)
+			}
+			stats[column] += v
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// gatherProtocols pushes the entries of /proc/net/nf_conntrack by protocol, which is only
+// there with CONFIG_NF_CONNTRACK_PROCFS
+func (c *Conntrack) gatherProtocols(slist *types.SampleList) {
+	f, err := os.Open(filepath.Join(osx.GetHostProc(), "net/nf_conntrack"))
+	if err != nil {
+		if !c.Quiet {
+			log.Println("E! failed to open nf_conntrack:", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	counts, err := parseProtocols(f)
+	if err != nil {
+		log.Println("E! failed to read nf_conntrack:", err)
+		return
+	}
+	for protocol, n := range counts {
+		slist.PushSample(inputName, "protocol_entries", n, map[string]string{"protocol": protocol})
+	}
+}
+
+// parseProtocols counts the entries by the protocol name of the third field:
+//
+//	ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=52314 dport=22 ...
+//
+// the table may hold millions of entries, so only the first fields of the lines are split
+func parseProtocols(r io.Reader) (map[string]uint64, error) {
+	counts := map[string]uint64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if protocol := nthField(scanner.Bytes(), 2); protocol != "" {
+			counts[protocol]++
+		}
+	}
+	return counts, scanner.Err()
+}
+
+// nthField returns the n-th field of the line separated by spaces, "" when the line is shorter
+func nthField(line []byte, n int) string {
+	for {
+		for len(line) > 0 && line[0] == ' ' {
+			line = line[1:]
+		}
+		end := 0
+		for end < len(line) && line[end] != ' ' {
+			end++
+		}
+		if end == 0 {
+			return ""
+		}
+		if n == 0 {
+			return string(line[:end])
+		}
+		line = line[end:]
+		n--
+	}
 }
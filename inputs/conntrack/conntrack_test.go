@@ -0,0 +1,48 @@
+//go:build linux
+// +build linux
+
+package conntrack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	stat := `entries  clashres found new invalid ignore delete chainlength insert insert_failed drop early_drop icmp_error  expect_new expect_create expect_delete search_restart
+00000a1f  00000000 00000012 00000000 00000003 0000f2a1 00000000 00000004 00000000 00000001 00000002 00000000 00000000  00000000 00000000 00000000 00000000
+00000a1f  00000001 00000010 00000000 0000000d 00000010 00000000 00000002 00000000 00000000 00000003 00000001 00000000  00000000 00000000 00000000 0000000a
+`
+	stats, err := parseStat(strings.NewReader(stat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"found": 0x22, "invalid": 0x10, "insert_failed": 1, "drop": 5, "early_drop": 1, "search_restart": 10, "ignore": 0xf2b1}
+	for name, v := range want {
+		if stats[name] != v {
+			t.Errorf("%s: expected %d, got %d", name, v, stats[name])
+		}
+	}
+	if _, has := stats["entries"]; has {
+		t.Error("expected entries to be skipped")
+	}
+	if _, err := parseStat(strings.NewReader("entries found\n00000001\n")); err == nil {
+		t.Error("expected an error for a short line")
+	}
+}
+
+func TestParseProtocols(t *testing.T) {
+	table := `ipv4     2 tcp      6 431999 ESTABLISHED src=10.0.0.2 dst=10.0.0.1 sport=52314 dport=22 src=10.0.0.1 dst=10.0.0.2 sport=22 dport=52314 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 udp      17 28 src=10.0.0.2 dst=10.0.0.53 sport=41234 dport=53 src=10.0.0.53 dst=10.0.0.2 sport=53 dport=41234 mark=0 zone=0 use=2
+ipv6     10 tcp      6 118 TIME_WAIT src=fd00::2 dst=fd00::1 sport=40000 dport=443 src=fd00::1 dst=fd00::2 sport=443 dport=40000 [ASSURED] mark=0 zone=0 use=2
+ipv4     2 icmp     1 29 src=10.0.0.2 dst=10.0.0.1 type=8 code=0 id=1 src=10.0.0.1 dst=10.0.0.2 type=0 code=0 id=1 mark=0 zone=0 use=2
+
+`
+	counts, err := parseProtocols(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 3 || counts["tcp"] != 2 || counts["udp"] != 1 || counts["icmp"] != 1 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+}
@@ -3,6 +3,8 @@ package diskio
 import (
 	"fmt"
 	"log"
+	"runtime"
+	"time"
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
@@ -19,6 +21,13 @@ type DiskIO struct {
 	config.PluginConfig
 	Devices      []string `toml:"devices"`
 	deviceFilter filter.Filter
+
+	// the histograms of the read and write latencies, derived from two reads of
+	// /sys/block/<dev>/stat latency_interval apart on every gather, linux only
+	Latency         bool            `toml:"latency"`
+	LatencyInterval config.Duration `toml:"latency_interval"`
+	LatencyBuckets  []float64       `toml:"latency_buckets"`
+	latency         *latencyHistograms
 }
 
 func init() {
@@ -49,9 +58,64 @@ func (d *DiskIO) Init() error {
 			d.deviceFilter = deviceFilter
 		}
 	}
+
+	if !d.Latency {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		log.Println("W! diskio: latency is only supported on linux, disabled")
+		d.Latency = false
+		return nil
+	}
+	if d.LatencyInterval <= 0 {
+		d.LatencyInterval = config.Duration(time.Second)
+	}
+	if len(d.LatencyBuckets) == 0 {
+		d.LatencyBuckets = defaultLatencyBuckets
+	}
+	for i, b := range d.LatencyBuckets {
+		if b <= 0 || (i > 0 && b <= d.LatencyBuckets[i-1]) {
+			return fmt.Errorf("latency_buckets must be positive and ascending, got %v", d.LatencyBuckets)
+		}
+	}
+	d.latency = newLatencyHistograms(d.LatencyBuckets)
 	return nil
 }
 
+// match reports whether the stats of the device are collected
+func (d *DiskIO) match(device string) bool {
+	if d.deviceFilter != nil {
+		return d.deviceFilter.Match(device)
+	}
+	if len(d.Devices) == 0 {
+		return true
+	}
+	for _, name := range d.Devices {
+		if name == device {
+			return true
+		}
+	}
+	return false
+}
+
+// gatherLatency observes the requests completed in a window of latency_interval
+func (d *DiskIO) gatherLatency(slist *types.SampleList) {
+	root := sysBlockPath()
+	prev, err := readBlockStats(root, d.match)
+	if err != nil {
+		log.Println("E! diskio: failed to read the block stats:", err)
+		return
+	}
+	time.Sleep(time.Duration(d.LatencyInterval))
+	cur, err := readBlockStats(root, d.match)
+	if err != nil {
+		log.Println("E! diskio: failed to read the block stats:", err)
+		return
+	}
+	d.latency.observe(prev, cur)
+	d.latency.push(slist)
+}
+
 func (d *DiskIO) Gather(slist *types.SampleList) {
 	devices := []string{}
 	if d.deviceFilter == nil {
@@ -86,4 +150,8 @@ func (d *DiskIO) Gather(slist *types.SampleList) {
 
 		slist.PushSamples("diskio", fields, map[string]string{"name": io.Name})
 	}
+
+	if d.latency != nil {
+		d.gatherLatency(slist)
+	}
 }
@@ -0,0 +1,156 @@
+package diskio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"flashcat.cloud/categraf/pkg/osx"
+	"flashcat.cloud/categraf/types"
+)
+
+// defaultLatencyBuckets are the upper bounds of the buckets of the latencies in seconds, from 100us to 2.5s
+var defaultLatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// blockStat holds the fields of /sys/block/<dev>/stat used for the latencies, the ticks are the
+// milliseconds spent by the completed requests, queueing included
+type blockStat struct {
+	reads      uint64
+	readTicks  uint64
+	writes     uint64
+	writeTicks uint64
+}
+
+// parseBlockStat parses the content of /sys/block/<dev>/stat, see Documentation/block/stat.rst
+func parseBlockStat(content string) (blockStat, error) {
+	var s blockStat
+	fields := strings.Fields(content)
+	if len(fields) < 8 {
+		return s, fmt.Errorf("expected at least 8 fields, got %d", len(fields))
+	}
+	values := make([]uint64, 8)
+	for i := range values {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("field %d: %v", i+1, err)
+		}
+		values[i] = v
+	}
+	s.reads, s.readTicks, s.writes, s.writeTicks = values[0], values[3], values[4], values[7]
+	return s, nil
+}
+
+// sysBlockPath returns /sys/block, under HOST_SYS when categraf runs in a container
+func sysBlockPath() string {
+	return filepath.Join(osx.GetEnv("HOST_SYS", "/sys"), "block")
+}
+
+// readBlockStats reads the stats of the block devices under root matched by match
+func readBlockStats(root string, match func(string) bool) (map[string]blockStat, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]blockStat, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !match(name) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(root, name, "stat"))
+		if err != nil {
+			// the device is gone
+			continue
+		}
+		s, err := parseBlockStat(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the stat of %s: %v", name, err)
+		}
+		stats[name] = s
+	}
+	return stats, nil
+}
+
+// latencyHistogram is the histogram of the requests of a device by the average latency of
+// the window they completed in, the counts are not cumulative
+type latencyHistogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// observe adds the requests completed in a window with their ticks in milliseconds
+func (h *latencyHistogram) observe(bounds []float64, requests, ticks uint64) {
+	if requests == 0 {
+		return
+	}
+	avg := float64(ticks) / float64(requests) / 1000
+	i := sort.SearchFloat64s(bounds, avg)
+	h.counts[i] += requests
+	h.sum += float64(ticks) / 1000
+}
+
+// latencyHistograms holds the read and write histograms by device
+type latencyHistograms struct {
+	bounds []float64
+	reads  map[string]*latencyHistogram
+	writes map[string]*latencyHistogram
+}
+
+func newLatencyHistograms(bounds []float64) *latencyHistograms {
+	return &latencyHistograms{
+		bounds: bounds,
+		reads:  make(map[string]*latencyHistogram),
+		writes: make(map[string]*latencyHistogram),
+	}
+}
+
+func (l *latencyHistograms) histogram(m map[string]*latencyHistogram, device string) *latencyHistogram {
+	h, ok := m[device]
+	if !ok {
+		h = &latencyHistogram{counts: make([]uint64, len(l.bounds)+1)}
+		m[device] = h
+	}
+	return h
+}
+
+// observe adds the requests completed between the two reads of the stats, the histograms of a
+// device are created once it completes a request
+func (l *latencyHistograms) observe(prev, cur map[string]blockStat) {
+	for device, c := range cur {
+		p, ok := prev[device]
+		if !ok {
+			continue
+		}
+		// the counters are reset when the device is attached again
+		if c.reads > p.reads && c.readTicks >= p.readTicks {
+			l.histogram(l.reads, device).observe(l.bounds, c.reads-p.reads, c.readTicks-p.readTicks)
+		}
+		if c.writes > p.writes && c.writeTicks >= p.writeTicks {
+			l.histogram(l.writes, device).observe(l.bounds, c.writes-p.writes, c.writeTicks-p.writeTicks)
+		}
+	}
+}
+
+func (l *latencyHistograms) push(slist *types.SampleList) {
+	l.pushHistograms(slist, "read_latency_seconds", l.reads)
+	l.pushHistograms(slist, "write_latency_seconds", l.writes)
+}
+
+func (l *latencyHistograms) pushHistograms(slist *types.SampleList, metric string, m map[string]*latencyHistogram) {
+	for device, h := range m {
+		var cumulative uint64
+		for i, count := range h.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(l.bounds) {
+				le = strconv.FormatFloat(l.bounds[i], 'f', -1, 64)
+			}
+			slist.PushSample("disk", metric+"_bucket", cumulative, map[string]string{"name": device, "le": le})
+		}
+		slist.PushSample("disk", metric+"_count", cumulative, map[string]string{"name": device})
+		slist.PushSample("disk", metric+"_sum", h.sum, map[string]string{"name": device})
+	}
+}
@@ -0,0 +1,149 @@
+package diskio
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestParseBlockStat(t *testing.T) {
+	s, err := parseBlockStat("  120341     2233  7387940    61839   512686   296893 19736842   754311        0   497700   831448        0        0        0        0    21447    15297\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := blockStat{reads: 120341, readTicks: 61839, writes: 512686, writeTicks: 754311}
+	if s != want {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+	if _, err := parseBlockStat("1 2 3"); err == nil {
+		t.Error("expected an error for a short stat")
+	}
+	if _, err := parseBlockStat("1 2 3 x 5 6 7 8 9 10 11"); err == nil {
+		t.Error("expected an error for a bad field")
+	}
+}
+
+func TestReadBlockStats(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"sda":   "10 0 0 20 30 0 0 40 0 0 0",
+		"loop0": "1 0 0 2 3 0 0 4 0 0 0",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name, "stat"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// no stat, the device is skipped
+	if err := os.MkdirAll(filepath.Join(root, "sdb"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := readBlockStats(root, func(name string) bool { return name != "loop0" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats["sda"] != (blockStat{reads: 10, readTicks: 20, writes: 30, writeTicks: 40}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLatencyHistograms(t *testing.T) {
+	l := newLatencyHistograms([]float64{0.001, 0.01})
+	l.observe(map[string]blockStat{
+		"sda": {reads: 100, readTicks: 50, writes: 10, writeTicks: 100},
+		"sdb": {reads: 7, readTicks: 7, writes: 50, writeTicks: 500},
+	}, map[string]blockStat{
+		// 10 reads of 0.5ms and 2 writes of 20ms
+		"sda": {reads: 110, readTicks: 55, writes: 12, writeTicks: 140},
+		// no read, and the writes are reset
+		"sdb": {reads: 7, readTicks: 7, writes: 5, writeTicks: 50},
+		// not read in the first read
+		"sdc": {reads: 10, readTicks: 10},
+	})
+	// 5 reads of 5ms
+	l.observe(map[string]blockStat{
+		"sda": {reads: 110, readTicks: 55, writes: 12, writeTicks: 140},
+	}, map[string]blockStat{
+		"sda": {reads: 115, readTicks: 80, writes: 12, writeTicks: 140},
+	})
+
+	slist := types.NewSampleList()
+	l.push(slist)
+	got := map[string]interface{}{}
+	for _, s := range slist.PopBackAll() {
+		got[s.Metric+"/"+s.Labels["name"]+"/"+s.Labels["le"]] = s.Value
+	}
+	want := map[string]interface{}{
+		"disk_read_latency_seconds_bucket/sda/0.001":  uint64(10),
+		"disk_read_latency_seconds_bucket/sda/0.01":   uint64(15),
+		"disk_read_latency_seconds_bucket/sda/+Inf":   uint64(15),
+		"disk_read_latency_seconds_count/sda/":        uint64(15),
+		"disk_read_latency_seconds_sum/sda/":          0.03,
+		"disk_write_latency_seconds_bucket/sda/0.001": uint64(0),
+		"disk_write_latency_seconds_bucket/sda/0.01":  uint64(0),
+		"disk_write_latency_seconds_bucket/sda/+Inf":  uint64(2),
+		"disk_write_latency_seconds_count/sda/":       uint64(2),
+		"disk_write_latency_seconds_sum/sda/":         0.04,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d samples, got %v", len(want), got)
+	}
+	for k, v := range want {
+		if f, ok := v.(float64); ok {
+			if g, _ := got[k].(float64); math.Abs(g-f) > 1e-9 {
+				t.Errorf("%s: expected %v, got %v", k, v, got[k])
+			}
+			continue
+		}
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
+
+func TestInitLatencyBuckets(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the latencies are only collected on linux")
+	}
+	d := &DiskIO{Latency: true}
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if d.latency == nil || len(d.LatencyBuckets) != len(defaultLatencyBuckets) {
+		t.Error("expected the default buckets")
+	}
+
+	d = &DiskIO{Latency: true, LatencyBuckets: []float64{0.01, 0.001}}
+	if err := d.Init(); err == nil {
+		t.Error("expected an error for the descending buckets")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	d := &DiskIO{Devices: []string{"sda"}}
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !d.match("sda") || d.match("sdb") {
+		t.Error("expected only sda to match")
+	}
+
+	d = &DiskIO{Devices: []string{"vd*"}}
+	if err := d.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !d.match("vda") || d.match("sda") {
+		t.Error("expected only vd* to match")
+	}
+
+	if !(&DiskIO{}).match("sda") {
+		t.Error("expected all the devices to match without devices")
+	}
+}
@@ -8,17 +8,17 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	dockerClient "github.com/docker/docker/client"
-)
 
-var (
-	version        = "1.24" // https://docs.docker.com/engine/api/
-	defaultHeaders = map[string]string{"User-Agent": "engine-api-cli-1.0"}
+	"flashcat.cloud/categraf/pkg/dock"
 )
 
+var defaultHeaders = map[string]string{"User-Agent": "engine-api-cli-1.0"}
+
 type Client interface {
 	Info(ctx context.Context) (types.Info, error)
 	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
 	ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error)
+	ContainerStatsOneShot(ctx context.Context, containerID string) (types.ContainerStats, error)
 	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
 	Ping(ctx context.Context) (types.Ping, error)
 	ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error)
@@ -27,15 +27,18 @@ type Client interface {
 	Close() error
 }
 
+// NewEnvClient creates the client with the settings of the environment, as the logs agent
 func NewEnvClient() (Client, error) {
-	client, err := dockerClient.NewClientWithOpts(dockerClient.FromEnv)
+	client, err := dock.NewEnvClient()
 	if err != nil {
 		return nil, err
 	}
 	return &SocketClient{client}, nil
 }
 
-func NewClient(host string, tlsConfig *tls.Config) (Client, error) {
+// NewClient creates the client of the daemon at host, the API version is negotiated with the
+// daemon when apiVersion is empty, https://docs.docker.com/engine/api/
+func NewClient(host string, tlsConfig *tls.Config, apiVersion string) (Client, error) {
 	transport := &http.Transport{}
 
 	if tlsConfig != nil {
@@ -44,10 +47,14 @@ func NewClient(host string, tlsConfig *tls.Config) (Client, error) {
 
 	httpClient := &http.Client{Transport: transport}
 
+	version := dockerClient.WithAPIVersionNegotiation()
+	if apiVersion != "" {
+		version = dockerClient.WithVersion(apiVersion)
+	}
 	client, err := dockerClient.NewClientWithOpts(
 		dockerClient.WithHTTPHeaders(defaultHeaders),
 		dockerClient.WithHTTPClient(httpClient),
-		dockerClient.WithVersion(version),
+		version,
 		dockerClient.WithHost(host))
 	if err != nil {
 		return nil, err
@@ -72,6 +79,9 @@ func (c *SocketClient) ContainerList(ctx context.Context, options types.Containe
 func (c *SocketClient) ContainerStats(ctx context.Context, containerID string, stream bool) (types.ContainerStats, error) {
 	return c.client.ContainerStats(ctx, containerID, stream)
 }
+func (c *SocketClient) ContainerStatsOneShot(ctx context.Context, containerID string) (types.ContainerStats, error) {
+	return c.client.ContainerStatsOneShot(ctx, containerID)
+}
 func (c *SocketClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
 	return c.client.ContainerInspect(ctx, containerID)
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"strings"
 	"sync"
 	"time"
@@ -85,6 +86,15 @@ type Instance struct {
 	ContainerExclude           []string `toml:"container_name_exclude"`
 	ContainerStateInclude      []string `toml:"container_state_include"`
 	ContainerStateExclude      []string `toml:"container_state_exclude"`
+	// the classes of perdevice_include and total_include all enabled, as in telegraf
+	PerDevice bool `toml:"perdevice"`
+	Total     bool `toml:"total"`
+	// filters of the container list of the docker API, e.g. "label=com.docker.compose.project=web"
+	ContainerListFilters []string `toml:"container_list_filters"`
+	// the containers whose stats are queried at the same time
+	MaxConcurrentStats int `toml:"max_concurrent_stats"`
+	// the API version of the daemon, negotiated with the daemon when empty
+	APIVersion string `toml:"api_version"`
 
 	Timeout config.Duration
 	tlsx.ClientConfig
@@ -93,6 +103,18 @@ type Instance struct {
 	labelFilter     filter.Filter
 	containerFilter filter.Filter
 	stateFilter     filter.Filter
+	listFilters     filters.Args
+
+	// the cpu stats of the previous gather by container id
+	cpuSamplesMu sync.Mutex
+	cpuSamples   map[string]cpuSample
+}
+
+// cpuSample is the cpu stats of a container at the previous gather. The one-shot stats have no
+// previous sample, the cpu usage percent is computed between two gathers instead.
+type cpuSample struct {
+	read time.Time
+	cpu  types.CPUStats
 }
 
 func (ins *Instance) Init() error {
@@ -106,6 +128,17 @@ func (ins *Instance) Init() error {
 	}
 	ins.client = c
 
+	if ins.PerDevice {
+		ins.PerDeviceInclude = containerMetricClasses
+	}
+	if ins.Total {
+		ins.TotalInclude = containerMetricClasses
+	}
+	if ins.MaxConcurrentStats <= 0 {
+		ins.MaxConcurrentStats = 20
+	}
+	ins.cpuSamples = make(map[string]cpuSample)
+
 	err = choice.CheckSlice(ins.PerDeviceInclude, containerMetricClasses)
 	if err != nil {
 		return fmt.Errorf("error validating 'perdevice_include' setting : %v", err)
@@ -128,6 +161,10 @@ func (ins *Instance) Init() error {
 		return err
 	}
 
+	if err = ins.createListFilters(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -171,6 +208,11 @@ func (ins *Instance) Gather(slist *itypes.SampleList) {
 	if filterArgs.Len() == 0 {
 		return
 	}
+	for _, key := range ins.listFilters.Keys() {
+		for _, value := range ins.listFilters.Get(key) {
+			filterArgs.Add(key, value)
+		}
+	}
 
 	// List containers
 	opts := types.ContainerListOptions{
@@ -189,16 +231,23 @@ func (ins *Instance) Gather(slist *itypes.SampleList) {
 		return
 	}
 
-	// Get container data
+	// Get container data, at most max_concurrent_stats containers at the same time
 	var wg sync.WaitGroup
+	sem := make(chan struct{}, ins.MaxConcurrentStats)
 	wg.Add(len(containers))
 	for _, container := range containers {
+		sem <- struct{}{}
 		go func(c types.Container) {
-			defer wg.Done()
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
 			ins.gatherContainer(c, slist)
 		}(container)
 	}
 	wg.Wait()
+
+	ins.pruneCPUSamples(containers)
 }
 
 func (ins *Instance) gatherContainer(container types.Container, slist *itypes.SampleList) {
@@ -238,7 +287,7 @@ func (ins *Instance) gatherContainer(container types.Container, slist *itypes.Sa
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ins.Timeout))
 	defer cancel()
 
-	r, err := ins.client.ContainerStats(ctx, container.ID, false)
+	r, err := ins.client.ContainerStatsOneShot(ctx, container.ID)
 	if err == context.DeadlineExceeded {
 		log.Println("E! failed to get container stats: timeout")
 		return
@@ -259,6 +308,7 @@ func (ins *Instance) gatherContainer(container types.Container, slist *itypes.Sa
 		}
 		return
 	}
+	ins.setPreCPUStats(container.ID, v)
 
 	// Add labels to tags
 	for k, label := range container.Labels {
@@ -317,6 +367,7 @@ func (ins *Instance) gatherContainerInspect(container types.Container, slist *it
 		}
 		statefields["status_uptime"] = uptime.Seconds()
 	}
+	statefields["status_restart_count"] = info.RestartCount
 
 	slist.PushSamples("docker_container", statefields, tags)
 
@@ -426,12 +477,13 @@ func (ins *Instance) parseContainerStats(stat *types.StatsJSON, slist *itypes.Sa
 			"throttling_throttled_time":    stat.CPUStats.ThrottlingData.ThrottledTime,
 		}
 
-		if ostype != "windows" {
+		// there is no previous sample on the first gather of the container
+		if ostype != "windows" && stat.PreCPUStats.SystemUsage != 0 {
 			previousCPU := stat.PreCPUStats.CPUUsage.TotalUsage
 			previousSystem := stat.PreCPUStats.SystemUsage
 			cpuPercent := CalculateCPUPercentUnix(previousCPU, previousSystem, stat)
 			cpufields["usage_percent"] = cpuPercent
-		} else {
+		} else if ostype == "windows" && !stat.PreRead.IsZero() {
 			cpuPercent := calculateCPUPercentWindows(stat)
 			cpufields["usage_percent"] = cpuPercent
 		}
@@ -696,9 +748,63 @@ func (ins *Instance) gatherInfo(slist *itypes.SampleList) error {
 	}
 
 	slist.PushSamples("", fields)
+
+	// only devicemapper reports the usage of its pool, e.g. "Data Space Used"
+	if info.Driver == "devicemapper" {
+		stats, err := dock.ParseStorageStats(info)
+		if err != nil {
+			log.Println("W! failed to parse docker storage stats:", err)
+			return nil
+		}
+		for _, stat := range stats {
+			tags := map[string]string{"storage_driver": info.Driver, "type": stat.Name}
+			storagefields := map[string]interface{}{}
+			if stat.Free != nil {
+				storagefields["free_bytes"] = *stat.Free
+			}
+			if stat.Used != nil {
+				storagefields["used_bytes"] = *stat.Used
+			}
+			if stat.Total != nil {
+				storagefields["total_bytes"] = *stat.Total
+			}
+			if percent := stat.GetPercentUsed(); !math.IsNaN(percent) {
+				storagefields["used_percent"] = percent
+			}
+			slist.PushSamples("docker_storage", storagefields, tags)
+		}
+	}
 	return nil
 }
 
+// setPreCPUStats sets the previous cpu stats of the one-shot stats to the stats of the
+// previous gather of the container
+func (ins *Instance) setPreCPUStats(id string, v *types.StatsJSON) {
+	ins.cpuSamplesMu.Lock()
+	defer ins.cpuSamplesMu.Unlock()
+	prev, ok := ins.cpuSamples[id]
+	ins.cpuSamples[id] = cpuSample{read: v.Read, cpu: v.CPUStats}
+	if ok && v.PreRead.IsZero() {
+		v.PreCPUStats = prev.cpu
+		v.PreRead = prev.read
+	}
+}
+
+// pruneCPUSamples forgets the cpu stats of the containers not listed anymore
+func (ins *Instance) pruneCPUSamples(containers []types.Container) {
+	listed := make(map[string]struct{}, len(containers))
+	for _, c := range containers {
+		listed[c.ID] = struct{}{}
+	}
+	ins.cpuSamplesMu.Lock()
+	defer ins.cpuSamplesMu.Unlock()
+	for id := range ins.cpuSamples {
+		if _, ok := listed[id]; !ok {
+			delete(ins.cpuSamples, id)
+		}
+	}
+}
+
 func (ins *Instance) getNewClient() (Client, error) {
 	if ins.Endpoint == "ENV" {
 		return NewEnvClient()
@@ -709,7 +815,7 @@ func (ins *Instance) getNewClient() (Client, error) {
 		return nil, err
 	}
 
-	c, err := NewClient(ins.Endpoint, tlsConfig)
+	c, err := NewClient(ins.Endpoint, tlsConfig, ins.APIVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -752,6 +858,24 @@ func (ins *Instance) createContainerStateFilters() error {
 	return nil
 }
 
+// createListFilters parses the key=value filters of container_list_filters
+func (ins *Instance) createListFilters() error {
+	pairs := make([]string, 0, 2*len(ins.ContainerListFilters))
+	for _, f := range ins.ContainerListFilters {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid container_list_filters %q, should be key=value, e.g. label=app=web", f)
+		}
+		pairs = append(pairs, key, value)
+	}
+	listFilters, err := dock.BuildFilter(pairs...)
+	if err != nil {
+		return err
+	}
+	ins.listFilters = listFilters
+	return nil
+}
+
 func hostnameFromID(id string) string {
 	if len(id) > 12 {
 		return id[0:12]
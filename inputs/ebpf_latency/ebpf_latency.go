@@ -0,0 +1,250 @@
+//go:build linux
+// +build linux
+
+package ebpf_latency
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/rlimit"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName = "ebpf_latency"
+	// the prefix of the metrics
+	prefix = "ebpf"
+)
+
+// the functions receiving the struct sock *sk of the established connections as their first argument
+var probedFunctions = []string{"tcp_rcv_established", "tcp_sendmsg"}
+
+// defaultBuckets are the upper bounds of the buckets of the rtts in seconds, from 50us to 1s
+var defaultBuckets = []float64{0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+type EBPFLatency struct {
+	config.PluginConfig
+
+	// only count the connections to these destination ports, all of them otherwise
+	Ports []int `toml:"ports"`
+	// the upper bounds of the buckets in seconds
+	Buckets []float64 `toml:"buckets"`
+	// the entries of the map of the counts, a port takes len(buckets) + 2 entries
+	MaxMapEntries int `toml:"max_map_entries"`
+
+	bounds []uint32
+	counts *ebpf.Map
+	prog   *ebpf.Program
+	links  []link.Link
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &EBPFLatency{}
+	})
+}
+
+func (e *EBPFLatency) Clone() inputs.Input {
+	return &EBPFLatency{}
+}
+
+func (e *EBPFLatency) Name() string {
+	return inputName
+}
+
+// parseBuckets converts the bounds in seconds to the microseconds compared by the program
+func parseBuckets(buckets []float64) ([]uint32, error) {
+	bounds := make([]uint32, 0, len(buckets))
+	for i, b := range buckets {
+		us := math.Round(b * 1e6)
+		if us < 1 || us > math.MaxInt32 {
+			return nil, fmt.Errorf("bucket %v out of range, from 0.000001 to 2147 seconds", b)
+		}
+		if i > 0 && uint32(us) <= bounds[i-1] {
+			return nil, fmt.Errorf("buckets must be ascending, got %v after %v", b, buckets[i-1])
+		}
+		bounds = append(bounds, uint32(us))
+	}
+	return bounds, nil
+}
+
+func (e *EBPFLatency) Init() error {
+	if len(e.Buckets) == 0 {
+		e.Buckets = defaultBuckets
+	}
+	bounds, err := parseBuckets(e.Buckets)
+	if err != nil {
+		return err
+	}
+	if len(bounds) >= sumBucket {
+		return fmt.Errorf("too many buckets: %d", len(bounds))
+	}
+	e.bounds = bounds
+	ports := make([]uint16, 0, len(e.Ports))
+	for _, port := range e.Ports {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d of ports", port)
+		}
+		ports = append(ports, uint16(port))
+	}
+	if e.MaxMapEntries <= 0 {
+		e.MaxMapEntries = 16384
+	}
+
+	offsets, err := kernelOffsets()
+	if err != nil {
+		return err
+	}
+	// the locked memory of the maps and programs is limited before 5.11
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return fmt.Errorf("failed to remove the memlock limit: %v", err)
+	}
+
+	e.counts, err = ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "tcp_rtt",
+		Type:       ebpf.Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: uint32(e.MaxMapEntries),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the map: %v", err)
+	}
+
+	// bpf_probe_read_kernel appeared in 5.5, bpf_probe_read reads the kernel memory before
+	probeRead := asm.FnProbeReadKernel
+	if features.HaveProgramHelper(ebpf.Kprobe, asm.FnProbeReadKernel) != nil {
+		probeRead = asm.FnProbeRead
+	}
+	e.prog, err = ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         "tcp_rtt",
+		Type:         ebpf.Kprobe,
+		License:      "GPL",
+		Instructions: buildProgram(e.counts.FD(), offsets, e.bounds, ports, probeRead),
+	})
+	if err != nil {
+		e.Drop()
+		return fmt.Errorf("failed to load the program: %v", err)
+	}
+
+	for _, fn := range probedFunctions {
+		l, err := link.Kprobe(fn, e.prog, nil)
+		if err != nil {
+			e.Drop()
+			return fmt.Errorf("failed to attach the kprobe of %s: %v", fn, err)
+		}
+		e.links = append(e.links, l)
+	}
+	return nil
+}
+
+func (e *EBPFLatency) Drop() {
+	for _, l := range e.links {
+		l.Close()
+	}
+	e.links = nil
+	if e.prog != nil {
+		e.prog.Close()
+		e.prog = nil
+	}
+	if e.counts != nil {
+		e.counts.Close()
+		e.counts = nil
+	}
+}
+
+// portHistogram is the histogram of the rtts of a destination port, the counts are not cumulative
+type portHistogram struct {
+	counts []uint64
+	sumUs  uint64
+}
+
+// histograms holds the histograms by destination port
+type histograms struct {
+	bounds []uint32
+	ports  map[uint16]*portHistogram
+}
+
+func newHistograms(bounds []uint32) *histograms {
+	return &histograms{bounds: bounds, ports: make(map[uint16]*portHistogram)}
+}
+
+// add adds an entry of the map, the key is {__be16 dport; __u16 bucket}
+func (h *histograms) add(key [4]byte, value uint64) {
+	dport := binary.BigEndian.Uint16(key[0:2])
+	bucket := binary.NativeEndian.Uint16(key[2:4])
+
+	ph, ok := h.ports[dport]
+	if !ok {
+		ph = &portHistogram{counts: make([]uint64, len(h.bounds)+1)}
+		h.ports[dport] = ph
+	}
+	switch {
+	case bucket == sumBucket:
+		ph.sumUs = value
+	case int(bucket) < len(ph.counts):
+		ph.counts[bucket] = value
+	}
+}
+
+func (h *histograms) push(slist *types.SampleList) {
+	ports := make([]int, 0, len(h.ports))
+	for port := range h.ports {
+		ports = append(ports, int(port))
+	}
+	sort.Ints(ports)
+
+	for _, port := range ports {
+		ph := h.ports[uint16(port)]
+		dport := strconv.Itoa(port)
+		var cumulative uint64
+		for i, count := range ph.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(h.bounds) {
+				le = strconv.FormatFloat(float64(h.bounds[i])/1e6, 'f', -1, 64)
+			}
+			slist.PushSample(prefix, "tcp_rtt_seconds_bucket", cumulative, map[string]string{"dport": dport, "le": le})
+		}
+		slist.PushSample(prefix, "tcp_rtt_seconds_count", cumulative, map[string]string{"dport": dport})
+		slist.PushSample(prefix, "tcp_rtt_seconds_sum", float64(ph.sumUs)/1e6, map[string]string{"dport": dport})
+	}
+}
+
+func (e *EBPFLatency) Gather(slist *types.SampleList) {
+	if e.counts == nil {
+		return
+	}
+	h := newHistograms(e.bounds)
+	var (
+		key     [4]byte
+		value   uint64
+		entries int
+	)
+	iter := e.counts.Iterate()
+	for iter.Next(&key, &value) {
+		h.add(key, value)
+		entries++
+	}
+	if err := iter.Err(); err != nil {
+		log.Println("E! ebpf_latency: failed to read the counts:", err)
+		return
+	}
+	// the entries of the new ports are not created once the map is full
+	if entries >= e.MaxMapEntries {
+		log.Printf("W! ebpf_latency: the map is full with %d ports, please increase max_map_entries or set ports", len(h.ports))
+	}
+	h.push(slist)
+}
@@ -0,0 +1,182 @@
+//go:build linux
+// +build linux
+
+package ebpf_latency
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestMemberOffset(t *testing.T) {
+	u16 := &btf.Int{Name: "__u16", Size: 2}
+	// struct sock_common { ...; union { __portpair skc_portpair; struct { __be16 skc_dport; __u16 skc_num; }; }; }
+	common := &btf.Struct{Name: "sock_common", Size: 16, Members: []btf.Member{
+		{Name: "skc_hash", Type: &btf.Int{Size: 4}, Offset: 0},
+		{Name: "", Type: &btf.Union{Size: 4, Members: []btf.Member{
+			{Name: "skc_portpair", Type: &btf.Int{Size: 4}},
+			{Name: "", Type: &btf.Struct{Size: 4, Members: []btf.Member{
+				{Name: "skc_dport", Type: &btf.Typedef{Name: "__be16", Type: u16}, Offset: 0},
+				{Name: "skc_num", Type: u16, Offset: 16},
+			}}},
+		}}, Offset: 96},
+	}}
+	sock := &btf.Struct{Name: "sock", Size: 64, Members: []btf.Member{
+		{Name: "__sk_common", Type: common, Offset: 0},
+		{Name: "sk_flags", Type: u16, Offset: 128, BitfieldSize: 3},
+	}}
+
+	if off, err := memberOffset(sock, "__sk_common", "skc_num"); err != nil || off != 14 {
+		t.Fatalf("expected skc_num at 14, got %d %v", off, err)
+	}
+	if off, err := memberOffset(sock, "__sk_common", "skc_dport"); err != nil || off != 12 {
+		t.Fatalf("expected skc_dport at 12, got %d %v", off, err)
+	}
+	if _, err := memberOffset(sock, "sk_flags"); err == nil {
+		t.Fatal("expected the bitfield to be rejected")
+	}
+	if _, err := memberOffset(sock, "__sk_common", "skc_daddr"); err == nil {
+		t.Fatal("expected a missing member to be reported")
+	}
+}
+
+// hostByteOrder returns binary.LittleEndian or binary.BigEndian, the instructions are not
+// marshaled with binary.NativeEndian
+func hostByteOrder() binary.ByteOrder {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+func TestBuildProgram(t *testing.T) {
+	bounds, err := parseBuckets(defaultBuckets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds[0] != 50 || bounds[len(bounds)-1] != 1000000 {
+		t.Fatalf("unexpected bounds %v", bounds)
+	}
+	for _, buckets := range [][]float64{{0.1, 0.01}, {0}, {3000}} {
+		if _, err := parseBuckets(buckets); err == nil {
+			t.Errorf("expected buckets %v to be rejected", buckets)
+		}
+	}
+
+	o := probeOffsets{arg: 112, srtt: 1744, dport: 12}
+	for _, ports := range [][]uint16{nil, {443, 3306}} {
+		insns := buildProgram(3, o, bounds, ports, asm.FnProbeReadKernel)
+		// the jumps resolve to the labels of the program
+		var buf bytes.Buffer
+		if err := insns.Marshal(&buf, hostByteOrder()); err != nil {
+			t.Fatalf("ports %v: %v", ports, err)
+		}
+		if ins := insns[len(insns)-1]; ins.OpCode != asm.Return().OpCode {
+			t.Fatalf("expected the program to return, got %v", ins)
+		}
+	}
+
+	// the port is compared with the network byte order value loaded from the stack
+	var port [2]byte
+	binary.BigEndian.PutUint16(port[:], 443)
+	if portKey(443) != int32(binary.NativeEndian.Uint16(port[:])) {
+		t.Fatalf("unexpected key of port 443: %x", portKey(443))
+	}
+}
+
+func TestHistograms(t *testing.T) {
+	key := func(dport, bucket uint16) [4]byte {
+		var k [4]byte
+		binary.BigEndian.PutUint16(k[0:2], dport)
+		binary.NativeEndian.PutUint16(k[2:4], bucket)
+		return k
+	}
+	h := newHistograms([]uint32{100, 1000})
+	h.add(key(443, 0), 3)
+	h.add(key(443, 2), 1)
+	h.add(key(443, sumBucket), 2500)
+	h.add(key(80, 1), 2)
+	h.add(key(80, sumBucket), 1000)
+
+	slist := types.NewSampleList()
+	h.push(slist)
+	got := map[string]interface{}{}
+	for _, s := range slist.PopBackAll() {
+		got[s.Metric+"/"+s.Labels["dport"]+"/"+s.Labels["le"]] = s.Value
+	}
+	want := map[string]interface{}{
+		"ebpf_tcp_rtt_seconds_bucket/443/0.0001": uint64(3),
+		"ebpf_tcp_rtt_seconds_bucket/443/0.001":  uint64(3),
+		"ebpf_tcp_rtt_seconds_bucket/443/+Inf":   uint64(4),
+		"ebpf_tcp_rtt_seconds_count/443/":        uint64(4),
+		"ebpf_tcp_rtt_seconds_sum/443/":          0.0025,
+		"ebpf_tcp_rtt_seconds_bucket/80/0.0001":  uint64(0),
+		"ebpf_tcp_rtt_seconds_bucket/80/0.001":   uint64(2),
+		"ebpf_tcp_rtt_seconds_bucket/80/+Inf":    uint64(2),
+		"ebpf_tcp_rtt_seconds_count/80/":         uint64(2),
+		"ebpf_tcp_rtt_seconds_sum/80/":           0.001,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d samples, got %v", len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
+
+// TestLoad loads and attaches the program, it needs root and a kernel with BTF
+func TestLoad(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("loading the program needs root")
+	}
+	e := &EBPFLatency{Ports: []int{0}}
+	if err := e.Init(); err == nil {
+		t.Fatal("expected port 0 to be rejected")
+	}
+	e = &EBPFLatency{}
+	if err := e.Init(); err != nil {
+		t.Skip("the program cannot be loaded here:", err)
+	}
+	defer e.Drop()
+
+	// an established connection through the loopback
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			buf := make([]byte, 5)
+			c.Read(buf)
+			c.Write(buf)
+			c.Close()
+		}
+	}()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Write([]byte("hello"))
+	buf := make([]byte, 5)
+	c.Read(buf)
+	c.Write([]byte("bye"))
+	c.Close()
+
+	slist := types.NewSampleList()
+	e.Gather(slist)
+	if slist.Len() == 0 {
+		t.Fatal("expected the rtts of the connection")
+	}
+}
@@ -0,0 +1,253 @@
+//go:build linux
+// +build linux
+
+package ebpf_latency
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+)
+
+// sumBucket is the bucket of the key holding the sum of the rtts of the port in microseconds
+const sumBucket = 0xffff
+
+// the stack of the program: the key {__be16 dport; __u16 bucket} of the map at -16,
+// the srtt_us read from the socket at -8 and the value of a new entry at -24
+const (
+	stackKey    = -16
+	stackBucket = -14
+	stackSrtt   = -8
+	stackValue  = -24
+)
+
+// probeOffsets are the offsets in the kernel structures read by the program
+type probeOffsets struct {
+	// of struct sock *sk, the first argument of the probed functions, in struct pt_regs
+	arg uint32
+	// of srtt_us in struct tcp_sock, the smoothed rtt in microseconds << 3
+	srtt uint32
+	// of __sk_common.skc_dport in struct sock, in network byte order
+	dport uint32
+}
+
+// firstArgOffset returns the offset of the first argument of a function in struct pt_regs
+func firstArgOffset(arch string) (uint32, error) {
+	switch arch {
+	case "amd64":
+		// di follows r15 r14 r13 r12 bp bx r11 r10 r9 r8 ax cx dx si
+		return 14 * 8, nil
+	case "arm64":
+		// regs[0]
+		return 0, nil
+	}
+	return 0, fmt.Errorf("unsupported architecture %s", arch)
+}
+
+// kernelOffsets finds the offsets of the fields read by the program in the BTF of the kernel,
+// so the program does not depend on the layout of the structures of a kernel version
+func kernelOffsets() (probeOffsets, error) {
+	var o probeOffsets
+	arg, err := firstArgOffset(runtime.GOARCH)
+	if err != nil {
+		return o, err
+	}
+	o.arg = arg
+
+	spec, err := btf.LoadKernelSpec()
+	if err != nil {
+		return o, fmt.Errorf("failed to load the BTF of the kernel, CONFIG_DEBUG_INFO_BTF is required: %v", err)
+	}
+	var tcpSock *btf.Struct
+	if err := spec.TypeByName("tcp_sock", &tcpSock); err != nil {
+		return o, fmt.Errorf("struct tcp_sock: %v", err)
+	}
+	if o.srtt, err = memberOffset(tcpSock, "srtt_us"); err != nil {
+		return o, err
+	}
+	var sock *btf.Struct
+	if err := spec.TypeByName("sock", &sock); err != nil {
+		return o, fmt.Errorf("struct sock: %v", err)
+	}
+	if o.dport, err = memberOffset(sock, "__sk_common", "skc_dport"); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+// memberOffset returns the offset in bytes of the member at the path, the members of the
+// anonymous structs and unions are looked up as the members of their parent like in C
+func memberOffset(typ btf.Type, path ...string) (uint32, error) {
+	var offset btf.Bits
+	for _, name := range path {
+		m, ok := findMember(typ, name)
+		if !ok {
+			return 0, fmt.Errorf("member %s of %s not found", name, typ.TypeName())
+		}
+		if m.BitfieldSize != 0 {
+			return 0, fmt.Errorf("member %s of %s is a bitfield", name, typ.TypeName())
+		}
+		offset += m.Offset
+		typ = m.Type
+	}
+	if offset%8 != 0 {
+		return 0, fmt.Errorf("member %v is not byte aligned", path)
+	}
+	return offset.Bytes(), nil
+}
+
+// findMember returns the member of the struct or union with its offset from the beginning of typ
+func findMember(typ btf.Type, name string) (btf.Member, bool) {
+	var members []btf.Member
+	switch t := btf.UnderlyingType(typ).(type) {
+	case *btf.Struct:
+		members = t.Members
+	case *btf.Union:
+		members = t.Members
+	default:
+		return btf.Member{}, false
+	}
+	for _, m := range members {
+		if m.Name == name {
+			return m, true
+		}
+		if m.Name != "" {
+			continue
+		}
+		if inner, ok := findMember(m.Type, name); ok {
+			inner.Offset += m.Offset
+			return inner, true
+		}
+	}
+	return btf.Member{}, false
+}
+
+// portKey returns the destination port as the program loads it from the stack, the network
+// byte order value of the socket read as a host order __u16
+func portKey(port uint16) int32 {
+	return int32(binary.NativeEndian.Uint16(binary.BigEndian.AppendUint16(nil, port)))
+}
+
+// builder appends the instructions of the program, a label names the next instruction
+type builder struct {
+	insns   asm.Instructions
+	pending string
+}
+
+func (b *builder) emit(insns ...asm.Instruction) {
+	for _, ins := range insns {
+		if b.pending != "" {
+			ins = ins.WithSymbol(b.pending)
+			b.pending = ""
+		}
+		b.insns = append(b.insns, ins)
+	}
+}
+
+func (b *builder) label(name string) {
+	if b.pending != "" {
+		// two labels of the same instruction, the first one jumps to the second one
+		b.emit(asm.Ja.Label(name))
+	}
+	b.pending = name
+}
+
+// add adds the value register to the entry of the key on the stack, the entry is created
+// when missing. The concurrent creations of an entry may lose a sample, the rtts are samples
+func (b *builder) add(mapFD int, value asm.Register, name string) {
+	b.emit(
+		asm.LoadMapPtr(asm.R1, mapFD),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, name+"_new"),
+		asm.StoreXAdd(asm.R0, value, asm.DWord),
+		asm.Ja.Label(name+"_done"),
+	)
+	b.label(name + "_new")
+	b.emit(
+		asm.StoreMem(asm.RFP, stackValue, value, asm.DWord),
+		asm.LoadMapPtr(asm.R1, mapFD),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, stackKey),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, stackValue),
+		// BPF_NOEXIST
+		asm.Mov.Imm(asm.R4, 1),
+		asm.FnMapUpdateElem.Call(),
+	)
+	b.label(name + "_done")
+}
+
+// buildProgram returns the kprobe counting the rtts of the sockets by destination port and
+// bucket in the map, bounds are the upper bounds of the buckets in microseconds, the last
+// bucket is +Inf. Only the ports listed are counted when ports is not empty
+func buildProgram(mapFD int, o probeOffsets, bounds []uint32, ports []uint16, probeRead asm.BuiltinFunc) asm.Instructions {
+	b := &builder{}
+	b.emit(
+		// r6 = (struct sock *)PT_REGS_PARM1(ctx)
+		asm.LoadMem(asm.R6, asm.R1, int16(o.arg), asm.DWord),
+		asm.JEq.Imm(asm.R6, 0, "exit"),
+
+		// srtt_us
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, stackSrtt),
+		asm.Mov.Imm(asm.R2, 4),
+		asm.Mov.Reg(asm.R3, asm.R6),
+		asm.Add.Imm(asm.R3, int32(o.srtt)),
+		probeRead.Call(),
+		asm.JNE.Imm(asm.R0, 0, "exit"),
+
+		// the dport is the first field of the key
+		asm.Mov.Reg(asm.R1, asm.RFP),
+		asm.Add.Imm(asm.R1, stackKey),
+		asm.Mov.Imm(asm.R2, 2),
+		asm.Mov.Reg(asm.R3, asm.R6),
+		asm.Add.Imm(asm.R3, int32(o.dport)),
+		probeRead.Call(),
+		asm.JNE.Imm(asm.R0, 0, "exit"),
+
+		// r7 = srtt_us >> 3, no rtt is measured before the first ack
+		asm.LoadMem(asm.R7, asm.RFP, stackSrtt, asm.Word),
+		asm.RSh.Imm(asm.R7, 3),
+		asm.JEq.Imm(asm.R7, 0, "exit"),
+	)
+
+	if len(ports) > 0 {
+		b.emit(asm.LoadMem(asm.R9, asm.RFP, stackKey, asm.Half))
+		for _, port := range ports {
+			b.emit(asm.JEq.Imm(asm.R9, portKey(port), "port"))
+		}
+		b.emit(asm.Ja.Label("exit"))
+		b.label("port")
+	}
+
+	// r8 = the index of the first bound >= rtt, the bounds are ascending
+	b.emit(asm.Mov.Imm(asm.R8, int32(len(bounds))))
+	for i := len(bounds) - 1; i >= 0; i-- {
+		skip := fmt.Sprintf("bound_%d", i)
+		b.emit(
+			asm.JGT.Imm(asm.R7, int32(bounds[i]), skip),
+			asm.Mov.Imm(asm.R8, int32(i)),
+		)
+		b.label(skip)
+	}
+
+	b.emit(
+		asm.StoreMem(asm.RFP, stackBucket, asm.R8, asm.Half),
+		asm.Mov.Imm(asm.R9, 1),
+	)
+	b.add(mapFD, asm.R9, "count")
+	b.emit(asm.StoreImm(asm.RFP, stackBucket, sumBucket, asm.Half))
+	b.add(mapFD, asm.R7, "sum")
+
+	b.label("exit")
+	b.emit(
+		asm.Mov.Imm(asm.R0, 0),
+		asm.Return(),
+	)
+	return b.insns
+}
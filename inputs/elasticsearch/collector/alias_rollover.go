@@ -0,0 +1,393 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	defaultAliasRolloverLabels = []string{"alias", "index"}
+	aliasRolloverAliasLabels   = []string{"alias"}
+)
+
+// AliasRollover checks whether the write index of the configured aliases
+// already reached the rollover conditions of its ILM policy.
+type AliasRollover struct {
+	client  *http.Client
+	url     *url.URL
+	aliases []string
+
+	up                prometheus.Gauge
+	totalScrapes      prometheus.Counter
+	jsonParseFailures prometheus.Counter
+
+	overdueDesc           *prometheus.Desc
+	ageDesc               *prometheus.Desc
+	docsDesc              *prometheus.Desc
+	storeDesc             *prometheus.Desc
+	maxAgeDesc            *prometheus.Desc
+	maxDocsDesc           *prometheus.Desc
+	maxSizeDesc           *prometheus.Desc
+	indicesDesc           *prometheus.Desc
+	writeIndexMissingDesc *prometheus.Desc
+}
+
+// rolloverConditions are the rollover conditions of the hot phase of an ILM policy
+type rolloverConditions struct {
+	MaxAge  string `json:"max_age"`
+	MaxDocs int64  `json:"max_docs"`
+	MaxSize string `json:"max_size"`
+}
+
+type ilmPolicyResponse map[string]struct {
+	Policy struct {
+		Phases struct {
+			Hot struct {
+				Actions struct {
+					Rollover *rolloverConditions `json:"rollover"`
+				} `json:"actions"`
+			} `json:"hot"`
+		} `json:"phases"`
+	} `json:"policy"`
+}
+
+type rolloverIndexStatsResponse struct {
+	Indices map[string]struct {
+		Primaries struct {
+			Docs struct {
+				Count int64 `json:"count"`
+			} `json:"docs"`
+			Store struct {
+				SizeInBytes int64 `json:"size_in_bytes"`
+			} `json:"store"`
+		} `json:"primaries"`
+	} `json:"indices"`
+}
+
+type rolloverIndexSettingsResponse map[string]struct {
+	Settings map[string]string `json:"settings"`
+}
+
+// NewAliasRollover defines alias rollover Prometheus metrics
+func NewAliasRollover(client *http.Client, url *url.URL, aliases []string) *AliasRollover {
+	subsystem := "alias_rollover"
+	return &AliasRollover{
+		client:  client,
+		url:     url,
+		aliases: aliases,
+
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "up"),
+			Help: "Was the last scrape of the Elasticsearch alias rollover endpoints successful.",
+		}),
+		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "total_scrapes"),
+			Help: "Current total Elasticsearch alias rollover scrapes.",
+		}),
+		jsonParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "json_parse_failures"),
+			Help: "Number of errors while parsing JSON.",
+		}),
+
+		overdueDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "overdue"),
+			"Whether the write index already meets one of the rollover conditions of its ILM policy",
+			defaultAliasRolloverLabels, nil,
+		),
+		ageDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "write_index_age_seconds"),
+			"Age of the write index of the alias",
+			defaultAliasRolloverLabels, nil,
+		),
+		docsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "write_index_docs"),
+			"Count of primary documents in the write index of the alias",
+			defaultAliasRolloverLabels, nil,
+		),
+		storeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "write_index_primary_store_bytes"),
+			"Primary store size of the write index of the alias",
+			defaultAliasRolloverLabels, nil,
+		),
+		maxAgeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "max_age_seconds"),
+			"Rollover max_age condition of the ILM policy of the write index",
+			defaultAliasRolloverLabels, nil,
+		),
+		maxDocsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "max_docs"),
+			"Rollover max_docs condition of the ILM policy of the write index",
+			defaultAliasRolloverLabels, nil,
+		),
+		maxSizeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "max_size_bytes"),
+			"Rollover max_size condition of the ILM policy of the write index",
+			defaultAliasRolloverLabels, nil,
+		),
+		indicesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "indices"),
+			"Count of indices the alias points at",
+			aliasRolloverAliasLabels, nil,
+		),
+		writeIndexMissingDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "write_index_missing"),
+			"Whether the alias has no resolvable write index, e.g. it points at multiple indices without is_write_index",
+			aliasRolloverAliasLabels, nil,
+		),
+	}
+}
+
+// Describe adds alias rollover metrics descriptions
+func (ar *AliasRollover) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ar.up.Desc()
+	ch <- ar.totalScrapes.Desc()
+	ch <- ar.jsonParseFailures.Desc()
+	ch <- ar.overdueDesc
+	ch <- ar.ageDesc
+	ch <- ar.docsDesc
+	ch <- ar.storeDesc
+	ch <- ar.maxAgeDesc
+	ch <- ar.maxDocsDesc
+	ch <- ar.maxSizeDesc
+	ch <- ar.indicesDesc
+	ch <- ar.writeIndexMissingDesc
+}
+
+func (ar *AliasRollover) getAndParseURL(u *url.URL, data interface{}) error {
+	res, err := ar.client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to get from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Println("failed to close http.Client, err: ", err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	bts, err := io.ReadAll(res.Body)
+	if err != nil {
+		ar.jsonParseFailures.Inc()
+		return err
+	}
+
+	if err := json.Unmarshal(bts, data); err != nil {
+		ar.jsonParseFailures.Inc()
+		return err
+	}
+
+	return nil
+}
+
+func (ar *AliasRollover) endpoint(p string, query url.Values) *url.URL {
+	u := *ar.url
+	u.Path = path.Join(u.Path, p)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return &u
+}
+
+// resolveWriteIndices returns the write index of every alias, aliases without
+// a resolvable write index are mapped to an empty string
+func resolveWriteIndices(asr aliasesResponse) (map[string]string, map[string]int) {
+	writeIndices := make(map[string]string)
+	counts := make(map[string]int)
+	candidates := make(map[string][]string)
+
+	for index, mapping := range asr {
+		for alias, settings := range mapping.Aliases {
+			counts[alias]++
+			isWrite, explicit := settings["is_write_index"].(bool)
+			if explicit && isWrite {
+				writeIndices[alias] = index
+				continue
+			}
+			if !explicit {
+				candidates[alias] = append(candidates[alias], index)
+			}
+		}
+	}
+
+	for alias, count := range counts {
+		if _, ok := writeIndices[alias]; ok {
+			continue
+		}
+		// an alias pointing at exactly one index writes to it unless disabled explicitly
+		if count == 1 && len(candidates[alias]) == 1 {
+			writeIndices[alias] = candidates[alias][0]
+			continue
+		}
+		writeIndices[alias] = ""
+	}
+
+	return writeIndices, counts
+}
+
+// Collect gets alias rollover metric values
+func (ar *AliasRollover) Collect(ch chan<- prometheus.Metric) {
+	ar.totalScrapes.Inc()
+	defer func() {
+		ch <- ar.up
+		ch <- ar.totalScrapes
+		ch <- ar.jsonParseFailures
+	}()
+
+	if len(ar.aliases) == 0 {
+		return
+	}
+
+	var asr aliasesResponse
+	if err := ar.getAndParseURL(ar.endpoint("/_alias/"+strings.Join(ar.aliases, ","), nil), &asr); err != nil {
+		ar.up.Set(0)
+		log.Println("failed to fetch and decode aliases, err: ", err)
+		return
+	}
+
+	writeIndices, counts := resolveWriteIndices(asr)
+	indices := make([]string, 0, len(writeIndices))
+	for alias, index := range writeIndices {
+		missing := 0.0
+		if index == "" {
+			missing = 1
+		} else {
+			indices = append(indices, index)
+		}
+		ch <- prometheus.MustNewConstMetric(ar.writeIndexMissingDesc, prometheus.GaugeValue, missing, alias)
+		ch <- prometheus.MustNewConstMetric(ar.indicesDesc, prometheus.GaugeValue, float64(counts[alias]), alias)
+	}
+	if len(indices) == 0 {
+		ar.up.Set(1)
+		return
+	}
+	sort.Strings(indices)
+	target := strings.Join(indices, ",")
+
+	var stats rolloverIndexStatsResponse
+	if err := ar.getAndParseURL(ar.endpoint("/"+target+"/_stats/docs,store", nil), &stats); err != nil {
+		ar.up.Set(0)
+		log.Println("failed to fetch and decode write index stats, err: ", err)
+		return
+	}
+
+	var settings rolloverIndexSettingsResponse
+	q := url.Values{}
+	q.Set("flat_settings", "true")
+	if err := ar.getAndParseURL(ar.endpoint("/"+target+"/_settings/index.creation_date,index.lifecycle.name", q), &settings); err != nil {
+		ar.up.Set(0)
+		log.Println("failed to fetch and decode write index settings, err: ", err)
+		return
+	}
+
+	policies := make(map[string]*rolloverConditions)
+	for _, s := range settings {
+		name := s.Settings["index.lifecycle.name"]
+		if name == "" {
+			continue
+		}
+		if _, ok := policies[name]; ok {
+			continue
+		}
+		var ipr ilmPolicyResponse
+		if err := ar.getAndParseURL(ar.endpoint("/_ilm/policy/"+name, nil), &ipr); err != nil {
+			log.Println("failed to fetch and decode ilm policy:", name, "err: ", err)
+			policies[name] = nil
+			continue
+		}
+		policies[name] = ipr[name].Policy.Phases.Hot.Actions.Rollover
+	}
+	ar.up.Set(1)
+
+	now := time.Now()
+	for alias, index := range writeIndices {
+		if index == "" {
+			continue
+		}
+		st := stats.Indices[index].Primaries
+		docs := float64(st.Docs.Count)
+		store := float64(st.Store.SizeInBytes)
+		ch <- prometheus.MustNewConstMetric(ar.docsDesc, prometheus.GaugeValue, docs, alias, index)
+		ch <- prometheus.MustNewConstMetric(ar.storeDesc, prometheus.GaugeValue, store, alias, index)
+
+		age := -1.0
+		if created, err := strconv.ParseInt(settings[index].Settings["index.creation_date"], 10, 64); err == nil {
+			age = now.Sub(time.UnixMilli(created)).Seconds()
+			ch <- prometheus.MustNewConstMetric(ar.ageDesc, prometheus.GaugeValue, age, alias, index)
+		}
+
+		overdue := 0.0
+		if cond := policies[settings[index].Settings["index.lifecycle.name"]]; cond != nil {
+			if cond.MaxAge != "" {
+				if maxAge, err := getTimeValueInSeconds(cond.MaxAge); err == nil {
+					ch <- prometheus.MustNewConstMetric(ar.maxAgeDesc, prometheus.GaugeValue, maxAge, alias, index)
+					if age >= 0 && age >= maxAge {
+						overdue = 1
+					}
+				}
+			}
+			if cond.MaxDocs > 0 {
+				ch <- prometheus.MustNewConstMetric(ar.maxDocsDesc, prometheus.GaugeValue, float64(cond.MaxDocs), alias, index)
+				if docs >= float64(cond.MaxDocs) {
+					overdue = 1
+				}
+			}
+			if cond.MaxSize != "" {
+				if maxSize, err := getValueInBytes(cond.MaxSize); err == nil {
+					ch <- prometheus.MustNewConstMetric(ar.maxSizeDesc, prometheus.GaugeValue, maxSize, alias, index)
+					if store >= maxSize {
+						overdue = 1
+					}
+				}
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(ar.overdueDesc, prometheus.GaugeValue, overdue, alias, index)
+	}
+}
+
+// getTimeValueInSeconds converts an Elasticsearch time unit value, e.g. 30d or 12h, to seconds
+func getTimeValueInSeconds(value string) (float64, error) {
+	type UnitValue struct {
+		unit string
+		val  float64
+	}
+
+	// longer suffixes first, "ms" must be checked before "s" and "m"
+	unitValues := []UnitValue{
+		{"nanos", 1e-9},
+		{"micros", 1e-6},
+		{"ms", 1e-3},
+		{"d", 24 * 60 * 60},
+		{"h", 60 * 60},
+		{"m", 60},
+		{"s", 1},
+	}
+
+	for _, uv := range unitValues {
+		if strings.HasSuffix(value, uv.unit) {
+			number, err := strconv.ParseFloat(strings.TrimSuffix(value, uv.unit), 64)
+			if err != nil {
+				return 0, err
+			}
+			return number * uv.val, nil
+		}
+	}
+
+	return 0, fmt.Errorf("failed to convert time value %s to seconds", value)
+}
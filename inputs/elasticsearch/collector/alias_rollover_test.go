@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAliasRollover(t *testing.T) {
+	created := time.Now().Add(-48 * time.Hour).UnixMilli()
+	responses := map[string]string{
+		"/_alias/logs-write,broken-write": `{
+			"logs-000002":{"aliases":{"logs-write":{"is_write_index":true}}},
+			"logs-000001":{"aliases":{"logs-write":{"is_write_index":false}}},
+			"broken-000001":{"aliases":{"broken-write":{}}},
+			"broken-000002":{"aliases":{"broken-write":{}}}
+		}`,
+		"/logs-000002/_stats/docs,store": `{"indices":{"logs-000002":{"primaries":{"docs":{"count":1500},"store":{"size_in_bytes":1024}}}}}`,
+		"/logs-000002/_settings/index.creation_date,index.lifecycle.name": fmt.Sprintf(
+			`{"logs-000002":{"settings":{"index.creation_date":"%d","index.lifecycle.name":"logs"}}}`, created),
+		"/_ilm/policy/logs": `{"logs":{"policy":{"phases":{"hot":{"actions":{"rollover":{"max_age":"1d","max_docs":100000,"max_size":"50gb"}}}}}}}`,
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, out)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewAliasRollover(http.DefaultClient, u, []string{"logs-write", "broken-write"})
+	want := `# HELP elasticsearch_alias_rollover_indices Count of indices the alias points at
+		# TYPE elasticsearch_alias_rollover_indices gauge
+		elasticsearch_alias_rollover_indices{alias="broken-write"} 2
+		elasticsearch_alias_rollover_indices{alias="logs-write"} 2
+		# HELP elasticsearch_alias_rollover_max_docs Rollover max_docs condition of the ILM policy of the write index
+		# TYPE elasticsearch_alias_rollover_max_docs gauge
+		elasticsearch_alias_rollover_max_docs{alias="logs-write",index="logs-000002"} 100000
+		# HELP elasticsearch_alias_rollover_overdue Whether the write index already meets one of the rollover conditions of its ILM policy
+		# TYPE elasticsearch_alias_rollover_overdue gauge
+		elasticsearch_alias_rollover_overdue{alias="logs-write",index="logs-000002"} 1
+		# HELP elasticsearch_alias_rollover_up Was the last scrape of the Elasticsearch alias rollover endpoints successful.
+		# TYPE elasticsearch_alias_rollover_up gauge
+		elasticsearch_alias_rollover_up 1
+		# HELP elasticsearch_alias_rollover_write_index_docs Count of primary documents in the write index of the alias
+		# TYPE elasticsearch_alias_rollover_write_index_docs gauge
+		elasticsearch_alias_rollover_write_index_docs{alias="logs-write",index="logs-000002"} 1500
+		# HELP elasticsearch_alias_rollover_write_index_missing Whether the alias has no resolvable write index, e.g. it points at multiple indices without is_write_index
+		# TYPE elasticsearch_alias_rollover_write_index_missing gauge
+		elasticsearch_alias_rollover_write_index_missing{alias="broken-write"} 1
+		elasticsearch_alias_rollover_write_index_missing{alias="logs-write"} 0
+	`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"elasticsearch_alias_rollover_indices",
+		"elasticsearch_alias_rollover_max_docs",
+		"elasticsearch_alias_rollover_overdue",
+		"elasticsearch_alias_rollover_up",
+		"elasticsearch_alias_rollover_write_index_docs",
+		"elasticsearch_alias_rollover_write_index_missing",
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetTimeValueInSeconds(t *testing.T) {
+	tcs := map[string]float64{
+		"30d":   30 * 24 * 3600,
+		"12h":   12 * 3600,
+		"5m":    300,
+		"10s":   10,
+		"500ms": 0.5,
+	}
+	for in, want := range tcs {
+		got, err := getTimeValueInSeconds(in)
+		if err != nil {
+			t.Fatalf("%s: %s", in, err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %v, got %v", in, want, got)
+		}
+	}
+	if _, err := getTimeValueInSeconds("forever"); err == nil {
+		t.Error("expected error for invalid time value")
+	}
+}
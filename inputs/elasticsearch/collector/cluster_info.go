@@ -31,6 +31,8 @@ func init() {
 type ClusterInfoCollector struct {
 	u  *url.URL
 	hc *http.Client
+	// the cached response of /, requested on every update when nil
+	source func() (*ClusterInfoResponse, error)
 }
 
 func NewClusterInfo(u *url.URL, hc *http.Client) (Collector, error) {
@@ -72,20 +74,14 @@ type VersionInfo struct {
 	BuildDate     string         `json:"build_date"`
 	BuildSnapshot bool           `json:"build_snapshot"`
 	LuceneVersion semver.Version `json:"lucene_version"`
+	// e.g. opensearch, empty for elasticsearch
+	Distribution string `json:"distribution"`
+	// e.g. default or serverless
+	BuildFlavor string `json:"build_flavor"`
 }
 
 func (c *ClusterInfoCollector) Update(_ context.Context, ch chan<- prometheus.Metric) error {
-	resp, err := c.hc.Get(c.u.String())
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-	var info ClusterInfoResponse
-	err = json.Unmarshal(b, &info)
+	info, err := c.fetch()
 	if err != nil {
 		return err
 	}
@@ -104,3 +100,23 @@ func (c *ClusterInfoCollector) Update(_ context.Context, ch chan<- prometheus.Me
 
 	return nil
 }
+
+func (c *ClusterInfoCollector) fetch() (*ClusterInfoResponse, error) {
+	if c.source != nil {
+		return c.source()
+	}
+	resp, err := c.hc.Get(c.u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var info ClusterInfoResponse
+	if err := json.Unmarshal(b, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,104 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ClusterInfoCache caches the response of the / endpoint for one connection, it feeds
+// elasticsearch_version of the cluster-info collector and the cluster label, so / is
+// requested once per interval instead of on every gather. The cached value is kept when
+// a refresh fails, so labels derived from it don't flap on transient errors of the root endpoint.
+type ClusterInfoCache struct {
+	client   *http.Client
+	url      *url.URL
+	interval time.Duration
+
+	mu        sync.Mutex
+	info      *ClusterInfoResponse
+	lastFetch time.Time
+}
+
+// NewClusterInfoCache creates a cache which refreshes the cluster info every interval
+func NewClusterInfoCache(client *http.Client, url *url.URL, interval time.Duration) *ClusterInfoCache {
+	return &ClusterInfoCache{
+		client:   client,
+		url:      url,
+		interval: interval,
+	}
+}
+
+// Info returns the cached cluster info, refreshing it when it is older than the interval.
+// It returns nil only if the root endpoint never answered successfully.
+func (c *ClusterInfoCache) Info() *ClusterInfoResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.info != nil && time.Since(c.lastFetch) < c.interval {
+		return c.info
+	}
+
+	info, err := c.fetchAndDecodeClusterInfo()
+	if err != nil {
+		log.Println("E! failed to refresh cluster info, keep the cached value, err:", err)
+		return c.info
+	}
+	c.info = info
+	c.lastFetch = time.Now()
+	return c.info
+}
+
+// Get returns the cached cluster info like Info, or an error if the root endpoint never
+// answered successfully, it is the source of the cluster-info collector
+func (c *ClusterInfoCache) Get() (*ClusterInfoResponse, error) {
+	if info := c.Info(); info != nil {
+		return info, nil
+	}
+	u := *c.url
+	return nil, fmt.Errorf("no cluster info of %s://%s:%s%s yet", u.Scheme, u.Hostname(), u.Port(), u.Path)
+}
+
+// ClusterName returns the cached cluster name, or an empty string if unknown
+func (c *ClusterInfoCache) ClusterName() string {
+	if info := c.Info(); info != nil {
+		return info.ClusterName
+	}
+	return ""
+}
+
+func (c *ClusterInfoCache) fetchAndDecodeClusterInfo() (*ClusterInfoResponse, error) {
+	u := *c.url
+	res, err := c.client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster info from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Println("failed to close http.Client, err: ", err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	bts, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var info ClusterInfoResponse
+	if err := json.Unmarshal(bts, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClusterInfoCache(t *testing.T) {
+	body, err := os.ReadFile("../fixtures/clusterinfo/7.13.1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failing atomic.Bool
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP elasticsearch_version Elasticsearch version information.
+            # TYPE elasticsearch_version gauge
+            elasticsearch_version{build_date="2021-05-28T17:40:59.346932922Z",build_hash="9a7758028e4ea59bcab41c12004603c5a7dd84a9",cluster="docker-cluster",cluster_uuid="aCMrCY1VQpqJ6U4Sw_xdiw",lucene_version="8.8.2",version="7.13.1"} 1
+	`
+
+	c := NewClusterInfoCache(http.DefaultClient, u, time.Hour)
+	e, err := NewElasticsearchCollector([]string{"cluster-info"}, WithElasticsearchURL(u), WithHTTPClient(http.DefaultClient), WithClusterInfo(c.Get))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := wrapCollector{e.Collectors["cluster-info"]}
+
+	// the version and the cluster label of every gather share one request to the root endpoint
+	for i := 0; i < 2; i++ {
+		if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.ClusterName() != "docker-cluster" {
+		t.Fatalf("unexpected cluster name %q", c.ClusterName())
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one request to the root endpoint, got %d", calls.Load())
+	}
+
+	// expire the cache and make the root endpoint fail, the cached value must survive
+	c.interval = 0
+	failing.Store(true)
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	if c.ClusterName() != "docker-cluster" {
+		t.Fatalf("cached cluster name lost after failure, got %q", c.ClusterName())
+	}
+
+	// the root endpoint never answered
+	empty := NewClusterInfoCache(http.DefaultClient, u, time.Hour)
+	if _, err := empty.Get(); err == nil {
+		t.Fatal("expected an error without cluster info")
+	}
+}
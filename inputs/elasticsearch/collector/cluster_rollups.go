@@ -0,0 +1,302 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rollupTiers are always reported by elasticsearch_rollup_nodes and elasticsearch_rollup_tier_data_bytes,
+// so the dashboards get zeroes instead of missing series
+var rollupTiers = []string{"hot", "warm", "cold"}
+
+// rollupTierRoles maps the data roles of the nodes to their tier, the first role of the node in
+// this order decides, the nodes without a data role are in the none tier
+var rollupTierRoles = []struct {
+	role string
+	tier string
+}{
+	{"data_hot", "hot"},
+	{"data_warm", "warm"},
+	{"data_cold", "cold"},
+	{"data_frozen", "frozen"},
+	{"data_content", "content"},
+	{"data", "data"},
+}
+
+// clusterRollupsNodesFilter keeps the part of /_nodes/stats the rollups need
+const clusterRollupsNodesFilter = "cluster_name,nodes.*.name,nodes.*.roles,nodes.*.attributes," +
+	"nodes.*.indices.store.size_in_bytes,nodes.*.indexing_pressure.memory.total.primary_in_bytes"
+
+// ClusterRollups reports a handful of cluster wide totals for the capacity and cost dashboards:
+// the data bytes, documents, shards and indices of /_cluster/stats, the nodes and the data bytes
+// of each tier, and the bytes ingested over the last day. The tier of a node is the value of the
+// tier attribute of the node when configured, its data roles otherwise.
+type ClusterRollups struct {
+	client        *http.Client
+	url           *url.URL
+	tierAttribute string
+	ingest        *IngestRollup
+
+	dataBytes          *prometheus.Desc
+	docs               *prometheus.Desc
+	shards             *prometheus.Desc
+	indices            *prometheus.Desc
+	diskTotalBytes     *prometheus.Desc
+	diskAvailableBytes *prometheus.Desc
+	nodes              *prometheus.Desc
+	tierDataBytes      *prometheus.Desc
+	ingestBytesTotal   *prometheus.Desc
+	ingestBytes1d      *prometheus.Desc
+}
+
+// NewClusterRollups defines cluster rollups Prometheus metrics, the ingest rollup should be kept across gathers
+func NewClusterRollups(client *http.Client, url *url.URL, tierAttribute string, ingest *IngestRollup) *ClusterRollups {
+	subsystem := "rollup"
+
+	return &ClusterRollups{
+		client:        client,
+		url:           url,
+		tierAttribute: tierAttribute,
+		ingest:        ingest,
+
+		dataBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "data_bytes"),
+			"Size of the data of the indices of the cluster including the replicas",
+			[]string{"cluster"}, nil,
+		),
+		docs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "docs"),
+			"Count of the documents of the primaries of the cluster",
+			[]string{"cluster"}, nil,
+		),
+		shards: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "shards"),
+			"Count of the started shards of the cluster",
+			[]string{"cluster"}, nil,
+		),
+		indices: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "indices"),
+			"Count of the indices of the cluster",
+			[]string{"cluster"}, nil,
+		),
+		diskTotalBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "disk_total_bytes"),
+			"Size of the filesystems of the data paths of the nodes",
+			[]string{"cluster"}, nil,
+		),
+		diskAvailableBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "disk_available_bytes"),
+			"Available bytes of the filesystems of the data paths of the nodes",
+			[]string{"cluster"}, nil,
+		),
+		nodes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "nodes"),
+			"Count of the nodes of the tier",
+			[]string{"cluster", "tier"}, nil,
+		),
+		tierDataBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "tier_data_bytes"),
+			"Size of the data of the indices on the nodes of the tier",
+			[]string{"cluster", "tier"}, nil,
+		),
+		ingestBytesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "ingest_bytes_total"),
+			"Bytes of the primary indexing operations of the cluster since categraf started",
+			[]string{"cluster"}, nil,
+		),
+		ingestBytes1d: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "ingest_bytes_1d"),
+			"Bytes of the primary indexing operations of the cluster over the last day, or since categraf started",
+			[]string{"cluster"}, nil,
+		),
+	}
+}
+
+// Describe adds cluster rollups metrics descriptions
+func (c *ClusterRollups) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.dataBytes
+	ch <- c.docs
+	ch <- c.shards
+	ch <- c.indices
+	ch <- c.diskTotalBytes
+	ch <- c.diskAvailableBytes
+	ch <- c.nodes
+	ch <- c.tierDataBytes
+	ch <- c.ingestBytesTotal
+	ch <- c.ingestBytes1d
+}
+
+func (c *ClusterRollups) get(p string, query url.Values, v interface{}) error {
+	u := *c.url
+	u.Path = path.Join(u.Path, p)
+	u.RawQuery = query.Encode()
+
+	res, err := c.client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to get from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Println("failed to close http.Client, err: ", err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	bts, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bts, v)
+}
+
+// tier returns the tier of the node
+func (c *ClusterRollups) tier(node NodeStatsNodeResponse) string {
+	if c.tierAttribute != "" {
+		if v := node.Attributes[c.tierAttribute]; v != "" {
+			return strings.ToLower(v)
+		}
+	}
+	for _, r := range rollupTierRoles {
+		for _, role := range node.Roles {
+			if role == r.role {
+				return r.tier
+			}
+		}
+	}
+	return "none"
+}
+
+// Collect gets cluster rollups metric values
+func (c *ClusterRollups) Collect(ch chan<- prometheus.Metric) {
+	var csr ClusterStatsResponse
+	if err := c.get("/_cluster/stats", nil, &csr); err != nil {
+		log.Println("failed to fetch and decode cluster stats for the rollups, err: ", err)
+		return
+	}
+	cluster := csr.ClusterName
+	ch <- prometheus.MustNewConstMetric(c.dataBytes, prometheus.GaugeValue, float64(csr.Indices.Store.Size), cluster)
+	ch <- prometheus.MustNewConstMetric(c.docs, prometheus.GaugeValue, float64(csr.Indices.Docs.Count), cluster)
+	ch <- prometheus.MustNewConstMetric(c.shards, prometheus.GaugeValue, csr.Indices.Shards.Total, cluster)
+	ch <- prometheus.MustNewConstMetric(c.indices, prometheus.GaugeValue, float64(csr.Indices.Count), cluster)
+	ch <- prometheus.MustNewConstMetric(c.diskTotalBytes, prometheus.GaugeValue, float64(csr.Nodes.FS.TotalInBytes), cluster)
+	ch <- prometheus.MustNewConstMetric(c.diskAvailableBytes, prometheus.GaugeValue, float64(csr.Nodes.FS.AvailableInBytes), cluster)
+
+	q := url.Values{}
+	q.Set("filter_path", clusterRollupsNodesFilter)
+	var nsr nodeStatsResponse
+	if err := c.get("/_nodes/stats/indices,indexing_pressure/store", q, &nsr); err != nil {
+		log.Println("failed to fetch and decode node stats for the rollups, err: ", err)
+		return
+	}
+
+	nodes := make(map[string]float64, len(rollupTiers))
+	bytes := make(map[string]float64, len(rollupTiers))
+	for _, tier := range rollupTiers {
+		nodes[tier] = 0
+		bytes[tier] = 0
+	}
+	for _, node := range nsr.Nodes {
+		tier := c.tier(node)
+		nodes[tier]++
+		bytes[tier] += float64(node.Indices.Store.Size)
+	}
+	tiers := make([]string, 0, len(nodes))
+	for tier := range nodes {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+	for _, tier := range tiers {
+		ch <- prometheus.MustNewConstMetric(c.nodes, prometheus.GaugeValue, nodes[tier], cluster, tier)
+		ch <- prometheus.MustNewConstMetric(c.tierDataBytes, prometheus.GaugeValue, bytes[tier], cluster, tier)
+	}
+
+	if c.ingest == nil {
+		return
+	}
+	if total, day, ok := c.ingest.update(nsr.Nodes, time.Now()); ok {
+		ch <- prometheus.MustNewConstMetric(c.ingestBytesTotal, prometheus.CounterValue, total, cluster)
+		ch <- prometheus.MustNewConstMetric(c.ingestBytes1d, prometheus.GaugeValue, day, cluster)
+	}
+}
+
+// ingestPoint is the ingested bytes at a gather
+type ingestPoint struct {
+	at    time.Time
+	bytes float64
+}
+
+// IngestRollup sums the primary indexing bytes of the indexing pressure stats of the nodes,
+// ES 7.9+, across gathers. The counters of the nodes restart with the nodes, so the bytes are
+// accumulated from the deltas of the counters and a counter lower than at the previous gather
+// is a delta from zero. The totals of the last day are kept to report the bytes of the day.
+type IngestRollup struct {
+	mu     sync.Mutex
+	window time.Duration
+	last   map[string]int64
+	total  float64
+	points []ingestPoint
+}
+
+func NewIngestRollup() *IngestRollup {
+	return &IngestRollup{window: 24 * time.Hour}
+}
+
+// update accumulates the counters of the nodes and returns the bytes ingested since the first
+// gather and over the window, false when no node reports indexing pressure stats
+func (r *IngestRollup) update(nodes map[string]NodeStatsNodeResponse, now time.Time) (float64, float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := make(map[string]int64, len(nodes))
+	for id, node := range nodes {
+		if node.IndexingPressure == nil {
+			continue
+		}
+		cur := node.IndexingPressure.Memory.Total.Primary
+		last[id] = cur
+		// the first gather only records the counters
+		if r.last == nil {
+			continue
+		}
+		prev, ok := r.last[id]
+		switch {
+		case !ok:
+			// a new node, its counter is counted from the next gather
+		case cur >= prev:
+			r.total += float64(cur - prev)
+		default:
+			r.total += float64(cur)
+		}
+	}
+	if len(last) == 0 {
+		return 0, 0, false
+	}
+	r.last = last
+
+	r.points = append(r.points, ingestPoint{at: now, bytes: r.total})
+	// keep the last point before the window, so the delta spans the whole window once it is covered
+	cutoff := now.Add(-r.window)
+	i := 0
+	for i+1 < len(r.points) && !r.points[i+1].at.After(cutoff) {
+		i++
+	}
+	r.points = r.points[i:]
+	return r.total, r.total - r.points[0].bytes, true
+}
@@ -0,0 +1,122 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClusterRollups(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_cluster/stats":
+			fmt.Fprint(w, `{"cluster_name": "es8", "indices": {"count": 12, "docs": {"count": 1000}, "store": {"size_in_bytes": 4096},
+				"shards": {"total": 24}}, "nodes": {"fs": {"total_in_bytes": 100000, "available_in_bytes": 60000}}}`)
+		case "/_nodes/stats/indices,indexing_pressure/store":
+			if r.URL.Query().Get("filter_path") == "" {
+				t.Error("expected the node stats to be filtered")
+			}
+			fmt.Fprint(w, `{"cluster_name": "es8", "nodes": {
+				"n1": {"name": "es01", "roles": ["data_content", "data_hot", "master"], "attributes": {"box_type": "Hot"},
+					"indices": {"store": {"size_in_bytes": 3000}}},
+				"n2": {"name": "es02", "roles": ["data_warm"], "attributes": {},
+					"indices": {"store": {"size_in_bytes": 1000}}},
+				"n3": {"name": "es03", "roles": ["master"], "indices": {"store": {"size_in_bytes": 0}}}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP elasticsearch_rollup_data_bytes Size of the data of the indices of the cluster including the replicas
+		# TYPE elasticsearch_rollup_data_bytes gauge
+		elasticsearch_rollup_data_bytes{cluster="es8"} 4096
+		# HELP elasticsearch_rollup_disk_available_bytes Available bytes of the filesystems of the data paths of the nodes
+		# TYPE elasticsearch_rollup_disk_available_bytes gauge
+		elasticsearch_rollup_disk_available_bytes{cluster="es8"} 60000
+		# HELP elasticsearch_rollup_disk_total_bytes Size of the filesystems of the data paths of the nodes
+		# TYPE elasticsearch_rollup_disk_total_bytes gauge
+		elasticsearch_rollup_disk_total_bytes{cluster="es8"} 100000
+		# HELP elasticsearch_rollup_docs Count of the documents of the primaries of the cluster
+		# TYPE elasticsearch_rollup_docs gauge
+		elasticsearch_rollup_docs{cluster="es8"} 1000
+		# HELP elasticsearch_rollup_indices Count of the indices of the cluster
+		# TYPE elasticsearch_rollup_indices gauge
+		elasticsearch_rollup_indices{cluster="es8"} 12
+		# HELP elasticsearch_rollup_nodes Count of the nodes of the tier
+		# TYPE elasticsearch_rollup_nodes gauge
+		elasticsearch_rollup_nodes{cluster="es8",tier="cold"} 0
+		elasticsearch_rollup_nodes{cluster="es8",tier="hot"} 1
+		elasticsearch_rollup_nodes{cluster="es8",tier="none"} 1
+		elasticsearch_rollup_nodes{cluster="es8",tier="warm"} 1
+		# HELP elasticsearch_rollup_shards Count of the started shards of the cluster
+		# TYPE elasticsearch_rollup_shards gauge
+		elasticsearch_rollup_shards{cluster="es8"} 24
+		# HELP elasticsearch_rollup_tier_data_bytes Size of the data of the indices on the nodes of the tier
+		# TYPE elasticsearch_rollup_tier_data_bytes gauge
+		elasticsearch_rollup_tier_data_bytes{cluster="es8",tier="cold"} 0
+		elasticsearch_rollup_tier_data_bytes{cluster="es8",tier="hot"} 3000
+		elasticsearch_rollup_tier_data_bytes{cluster="es8",tier="none"} 0
+		elasticsearch_rollup_tier_data_bytes{cluster="es8",tier="warm"} 1000
+	`
+	// the roles decide without an attribute, and the attribute of n1 gives the same tier
+	for _, attr := range []string{"", "box_type"} {
+		c := NewClusterRollups(http.DefaultClient, u, attr, NewIngestRollup())
+		if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+			t.Fatalf("tier attribute %q: %v", attr, err)
+		}
+	}
+
+	// a node without the attribute falls back to its roles
+	c := NewClusterRollups(http.DefaultClient, u, "rack", nil)
+	if tier := c.tier(NodeStatsNodeResponse{Roles: []string{"data"}, Attributes: map[string]string{"rack": "COLD"}}); tier != "cold" {
+		t.Errorf("expected the attribute tier, got %s", tier)
+	}
+	if tier := c.tier(NodeStatsNodeResponse{Roles: []string{"ingest", "data"}}); tier != "data" {
+		t.Errorf("expected the data tier, got %s", tier)
+	}
+}
+
+func TestIngestRollup(t *testing.T) {
+	node := func(primary int64) NodeStatsNodeResponse {
+		n := NodeStatsNodeResponse{IndexingPressure: &NodeStatsIndexingPressureResponse{}}
+		n.IndexingPressure.Memory.Total.Primary = primary
+		return n
+	}
+	begin := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	gathers := []struct {
+		after  time.Duration
+		nodes  map[string]NodeStatsNodeResponse
+		total  float64
+		day    float64
+		hasAny bool
+	}{
+		// the first gather only records the counters
+		{0, map[string]NodeStatsNodeResponse{"n1": node(1000), "n2": node(500)}, 0, 0, true},
+		{12 * time.Hour, map[string]NodeStatsNodeResponse{"n1": node(1600), "n2": node(900)}, 1000, 1000, true},
+		// n2 restarted and n3 joined
+		{24 * time.Hour, map[string]NodeStatsNodeResponse{"n1": node(2000), "n2": node(100), "n3": node(7000)}, 1500, 1500, true},
+		// the first gather left the window
+		{36 * time.Hour, map[string]NodeStatsNodeResponse{"n1": node(2000), "n2": node(100), "n3": node(7500)}, 2000, 1000, true},
+		// the nodes before 7.9 have no indexing pressure stats
+		{37 * time.Hour, map[string]NodeStatsNodeResponse{"n1": {}}, 0, 0, false},
+	}
+
+	r := NewIngestRollup()
+	for i, g := range gathers {
+		total, day, ok := r.update(g.nodes, begin.Add(g.after))
+		if ok != g.hasAny || total != g.total || day != g.day {
+			t.Errorf("gather %d: expected %v %v %v, got %v %v %v", i, g.total, g.day, g.hasAny, total, day, ok)
+		}
+	}
+}
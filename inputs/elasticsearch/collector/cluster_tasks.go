@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// DefaultTaskActions are the long-running task actions tracked when task_actions is not configured
+	DefaultTaskActions = []string{"*reindex*", "*forcemerge*", "*delete_by_query*"}
+
+	pendingTaskPriorities = []string{"IMMEDIATE", "URGENT", "HIGH", "NORMAL", "LOW", "LANGUID"}
+)
+
+// ClusterTasks collects the pending cluster tasks and the running long tasks
+type ClusterTasks struct {
+	client  *http.Client
+	url     *url.URL
+	actions []string
+
+	up                prometheus.Gauge
+	totalScrapes      prometheus.Counter
+	jsonParseFailures prometheus.Counter
+
+	pendingTasksDesc *prometheus.Desc
+	pendingMaxDesc   *prometheus.Desc
+	tasksRunningDesc *prometheus.Desc
+	tasksLongestDesc *prometheus.Desc
+}
+
+type pendingTasksResponse struct {
+	Tasks []struct {
+		Priority          string `json:"priority"`
+		Source            string `json:"source"`
+		TimeInQueueMillis int64  `json:"time_in_queue_millis"`
+	} `json:"tasks"`
+}
+
+type runningTasksResponse struct {
+	Tasks []struct {
+		Action             string `json:"action"`
+		RunningTimeInNanos int64  `json:"running_time_in_nanos"`
+	} `json:"tasks"`
+}
+
+// NewClusterTasks defines cluster pending tasks and task management Prometheus metrics
+func NewClusterTasks(client *http.Client, url *url.URL, actions []string) *ClusterTasks {
+	if len(actions) == 0 {
+		actions = DefaultTaskActions
+	}
+	return &ClusterTasks{
+		client:  client,
+		url:     url,
+		actions: actions,
+
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "cluster_tasks", "up"),
+			Help: "Was the last scrape of the Elasticsearch pending tasks and tasks endpoints successful.",
+		}),
+		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, "cluster_tasks", "total_scrapes"),
+			Help: "Current total Elasticsearch cluster tasks scrapes.",
+		}),
+		jsonParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, "cluster_tasks", "json_parse_failures"),
+			Help: "Number of errors while parsing JSON.",
+		}),
+
+		pendingTasksDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cluster", "pending_tasks"),
+			"Number of pending cluster tasks by priority",
+			[]string{"priority"}, nil,
+		),
+		pendingMaxDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "cluster", "pending_tasks_max_queue_seconds"),
+			"Longest time a pending cluster task of the priority is waiting in the queue",
+			[]string{"priority"}, nil,
+		),
+		tasksRunningDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tasks", "running"),
+			"Number of running tasks by action",
+			[]string{"action"}, nil,
+		),
+		tasksLongestDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "tasks", "longest_running_seconds"),
+			"Running time of the longest running task by action",
+			[]string{"action"}, nil,
+		),
+	}
+}
+
+// Describe adds cluster tasks metrics descriptions
+func (ct *ClusterTasks) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ct.up.Desc()
+	ch <- ct.totalScrapes.Desc()
+	ch <- ct.jsonParseFailures.Desc()
+	ch <- ct.pendingTasksDesc
+	ch <- ct.pendingMaxDesc
+	ch <- ct.tasksRunningDesc
+	ch <- ct.tasksLongestDesc
+}
+
+func (ct *ClusterTasks) getAndParseURL(u *url.URL, data interface{}) error {
+	res, err := ct.client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to get from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Println("failed to close http.Client, err: ", err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	bts, err := io.ReadAll(res.Body)
+	if err != nil {
+		ct.jsonParseFailures.Inc()
+		return err
+	}
+
+	if err := json.Unmarshal(bts, data); err != nil {
+		ct.jsonParseFailures.Inc()
+		return err
+	}
+
+	return nil
+}
+
+func (ct *ClusterTasks) fetchAndDecodePendingTasks() (pendingTasksResponse, error) {
+	var ptr pendingTasksResponse
+	u := *ct.url
+	u.Path = path.Join(u.Path, "/_cluster/pending_tasks")
+	err := ct.getAndParseURL(&u, &ptr)
+	return ptr, err
+}
+
+func (ct *ClusterTasks) fetchAndDecodeRunningTasks() (runningTasksResponse, error) {
+	var rtr runningTasksResponse
+	u := *ct.url
+	u.Path = path.Join(u.Path, "/_tasks")
+	q := u.Query()
+	q.Set("group_by", "none")
+	q.Set("actions", strings.Join(ct.actions, ","))
+	u.RawQuery = q.Encode()
+	err := ct.getAndParseURL(&u, &rtr)
+	return rtr, err
+}
+
+// Collect gets cluster tasks metric values
+func (ct *ClusterTasks) Collect(ch chan<- prometheus.Metric) {
+	ct.totalScrapes.Inc()
+	defer func() {
+		ch <- ct.up
+		ch <- ct.totalScrapes
+		ch <- ct.jsonParseFailures
+	}()
+
+	ptr, err := ct.fetchAndDecodePendingTasks()
+	if err != nil {
+		ct.up.Set(0)
+		log.Println("failed to fetch and decode pending cluster tasks, err: ", err)
+		return
+	}
+
+	rtr, err := ct.fetchAndDecodeRunningTasks()
+	if err != nil {
+		ct.up.Set(0)
+		log.Println("failed to fetch and decode tasks, err: ", err)
+		return
+	}
+	ct.up.Set(1)
+
+	pendingCount := make(map[string]float64, len(pendingTaskPriorities))
+	pendingMax := make(map[string]float64, len(pendingTaskPriorities))
+	for _, p := range pendingTaskPriorities {
+		pendingCount[p] = 0
+		pendingMax[p] = 0
+	}
+	for _, task := range ptr.Tasks {
+		pendingCount[task.Priority]++
+		if seconds := float64(task.TimeInQueueMillis) / 1000; seconds > pendingMax[task.Priority] {
+			pendingMax[task.Priority] = seconds
+		}
+	}
+	for priority, count := range pendingCount {
+		ch <- prometheus.MustNewConstMetric(ct.pendingTasksDesc, prometheus.GaugeValue, count, priority)
+		ch <- prometheus.MustNewConstMetric(ct.pendingMaxDesc, prometheus.GaugeValue, pendingMax[priority], priority)
+	}
+
+	runningCount := make(map[string]float64)
+	runningLongest := make(map[string]float64)
+	for _, task := range rtr.Tasks {
+		runningCount[task.Action]++
+		if seconds := float64(task.RunningTimeInNanos) / 1e9; seconds > runningLongest[task.Action] {
+			runningLongest[task.Action] = seconds
+		}
+	}
+	for action, count := range runningCount {
+		ch <- prometheus.MustNewConstMetric(ct.tasksRunningDesc, prometheus.GaugeValue, count, action)
+		ch <- prometheus.MustNewConstMetric(ct.tasksLongestDesc, prometheus.GaugeValue, runningLongest[action], action)
+	}
+}
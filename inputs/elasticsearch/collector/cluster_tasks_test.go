@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClusterTasks(t *testing.T) {
+	pending := `{"tasks":[
+		{"insert_order":101,"priority":"URGENT","source":"create-index [foo_9], cause [api]","time_in_queue_millis":86,"time_in_queue":"86ms"},
+		{"insert_order":46,"priority":"HIGH","source":"shard-started","time_in_queue_millis":842,"time_in_queue":"842ms"},
+		{"insert_order":47,"priority":"HIGH","source":"shard-started","time_in_queue_millis":1500,"time_in_queue":"1.5s"}
+	]}`
+	tasks := `{"tasks":[
+		{"node":"n1","id":1,"type":"transport","action":"indices:data/write/reindex","running_time_in_nanos":120000000000},
+		{"node":"n1","id":2,"type":"transport","action":"indices:data/write/reindex","running_time_in_nanos":30000000000},
+		{"node":"n2","id":3,"type":"transport","action":"indices:admin/forcemerge","running_time_in_nanos":5000000000}
+	]}`
+
+	var gotActions string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_cluster/pending_tasks":
+			fmt.Fprintln(w, pending)
+		case "/_tasks":
+			gotActions = r.URL.Query().Get("actions")
+			fmt.Fprintln(w, tasks)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClusterTasks(http.DefaultClient, u, nil)
+	want := `# HELP elasticsearch_cluster_pending_tasks Number of pending cluster tasks by priority
+		# TYPE elasticsearch_cluster_pending_tasks gauge
+		elasticsearch_cluster_pending_tasks{priority="HIGH"} 2
+		elasticsearch_cluster_pending_tasks{priority="IMMEDIATE"} 0
+		elasticsearch_cluster_pending_tasks{priority="LANGUID"} 0
+		elasticsearch_cluster_pending_tasks{priority="LOW"} 0
+		elasticsearch_cluster_pending_tasks{priority="NORMAL"} 0
+		elasticsearch_cluster_pending_tasks{priority="URGENT"} 1
+		# HELP elasticsearch_cluster_pending_tasks_max_queue_seconds Longest time a pending cluster task of the priority is waiting in the queue
+		# TYPE elasticsearch_cluster_pending_tasks_max_queue_seconds gauge
+		elasticsearch_cluster_pending_tasks_max_queue_seconds{priority="HIGH"} 1.5
+		elasticsearch_cluster_pending_tasks_max_queue_seconds{priority="IMMEDIATE"} 0
+		elasticsearch_cluster_pending_tasks_max_queue_seconds{priority="LANGUID"} 0
+		elasticsearch_cluster_pending_tasks_max_queue_seconds{priority="LOW"} 0
+		elasticsearch_cluster_pending_tasks_max_queue_seconds{priority="NORMAL"} 0
+		elasticsearch_cluster_pending_tasks_max_queue_seconds{priority="URGENT"} 0.086
+		# HELP elasticsearch_cluster_tasks_json_parse_failures Number of errors while parsing JSON.
+		# TYPE elasticsearch_cluster_tasks_json_parse_failures counter
+		elasticsearch_cluster_tasks_json_parse_failures 0
+		# HELP elasticsearch_cluster_tasks_total_scrapes Current total Elasticsearch cluster tasks scrapes.
+		# TYPE elasticsearch_cluster_tasks_total_scrapes counter
+		elasticsearch_cluster_tasks_total_scrapes 1
+		# HELP elasticsearch_cluster_tasks_up Was the last scrape of the Elasticsearch pending tasks and tasks endpoints successful.
+		# TYPE elasticsearch_cluster_tasks_up gauge
+		elasticsearch_cluster_tasks_up 1
+		# HELP elasticsearch_tasks_longest_running_seconds Running time of the longest running task by action
+		# TYPE elasticsearch_tasks_longest_running_seconds gauge
+		elasticsearch_tasks_longest_running_seconds{action="indices:admin/forcemerge"} 5
+		elasticsearch_tasks_longest_running_seconds{action="indices:data/write/reindex"} 120
+		# HELP elasticsearch_tasks_running Number of running tasks by action
+		# TYPE elasticsearch_tasks_running gauge
+		elasticsearch_tasks_running{action="indices:admin/forcemerge"} 1
+		elasticsearch_tasks_running{action="indices:data/write/reindex"} 2
+	`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+	if gotActions != "*reindex*,*forcemerge*,*delete_by_query*" {
+		t.Errorf("unexpected actions filter %q", gotActions)
+	}
+}
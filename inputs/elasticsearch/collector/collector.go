@@ -44,6 +44,8 @@ var (
 	initiatedCollectors    = make(map[string]Collector)
 	collectorState         = make(map[string]*bool)
 	forcedCollectors       = map[string]bool{} // collectors which have been explicitly enabled or disabled
+	// the default states, categraf does not parse the kingpin flags of collectorState
+	defaultState = make(map[string]bool)
 )
 
 var (
@@ -82,15 +84,23 @@ func registerCollector(name string, isDefaultEnabled bool, createFunc factoryFun
 
 	flag := kingpin.Flag(flagName, flagHelp).Default(defaultValue).Action(collectorFlagAction(name)).Bool()
 	collectorState[name] = flag
+	defaultState[name] = isDefaultEnabled
 
 	// Register the create function for this collector
 	factories[name] = createFunc
 }
 
+// collectorEnabled reports whether the collector is enabled by its flag or by default
+func collectorEnabled(name string) bool {
+	return *collectorState[name] || defaultState[name]
+}
+
 type ElasticsearchCollector struct {
 	Collectors map[string]Collector
 	esURL      *url.URL
 	httpClient *http.Client
+	// the cached response of / of the server, used by the cluster-info collector
+	clusterInfo func() (*ClusterInfoResponse, error)
 }
 
 type Option func(*ElasticsearchCollector) error
@@ -107,11 +117,11 @@ func NewElasticsearchCollector(filters []string, options ...Option) (*Elasticsea
 
 	f := make(map[string]bool)
 	for _, filter := range filters {
-		enabled, exist := collectorState[filter]
+		_, exist := collectorState[filter]
 		if !exist {
 			return nil, fmt.Errorf("missing collector: %s", filter)
 		}
-		if !*enabled {
+		if !collectorEnabled(filter) {
 			return nil, fmt.Errorf("disabled collector: %s", filter)
 		}
 		f[filter] = true
@@ -119,8 +129,13 @@ func NewElasticsearchCollector(filters []string, options ...Option) (*Elasticsea
 	collectors := make(map[string]Collector)
 	initiatedCollectorsMtx.Lock()
 	defer initiatedCollectorsMtx.Unlock()
-	for key, enabled := range collectorState {
-		if !*enabled || (len(f) > 0 && !f[key]) {
+	for key := range collectorState {
+		if !collectorEnabled(key) || (len(f) > 0 && !f[key]) {
+			continue
+		}
+		// the cluster info is cached per server, the collector is not shared across servers
+		if key == "cluster-info" && e.clusterInfo != nil {
+			collectors[key] = &ClusterInfoCollector{u: e.esURL, hc: e.httpClient, source: e.clusterInfo}
 			continue
 		}
 		if collector, ok := initiatedCollectors[key]; ok {
@@ -154,6 +169,15 @@ func WithHTTPClient(hc *http.Client) Option {
 	}
 }
 
+// WithClusterInfo makes the cluster-info collector report the cluster info returned by
+// source, e.g. ClusterInfoCache.Get, instead of requesting / on every collect
+func WithClusterInfo(source func() (*ClusterInfoResponse, error)) Option {
+	return func(e *ElasticsearchCollector) error {
+		e.clusterInfo = source
+		return nil
+	}
+}
+
 // Describe implements the prometheus.Collector interface.
 func (e ElasticsearchCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxDeprecationMessages bounds the distinct messages tracked, the rest are counted as other
+const maxDeprecationMessages = 100
+
+// DeprecationWarnings is a http.RoundTripper which counts the deprecation warnings
+// elasticsearch returns in the Warning header of any response, so the usage of
+// deprecated APIs, including the ones requested by the collectors, is noticed before an upgrade
+type DeprecationWarnings struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	counts map[string]float64
+
+	warningsDesc *prometheus.Desc
+}
+
+// NewDeprecationWarnings wraps the transport and defines deprecation warning Prometheus metrics
+func NewDeprecationWarnings(next http.RoundTripper) *DeprecationWarnings {
+	return &DeprecationWarnings{
+		next:   next,
+		counts: make(map[string]float64),
+
+		warningsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "deprecation", "warnings_total"),
+			"Count of deprecation warnings returned by Elasticsearch, by the hash of the message",
+			[]string{"message_hash"}, nil,
+		),
+	}
+}
+
+// RoundTrip records the Warning headers of the response
+func (d *DeprecationWarnings) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := d.next.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	for _, h := range res.Header.Values("Warning") {
+		if msg, ok := parseWarningHeader(h); ok {
+			d.record(msg, req.URL.Path)
+		}
+	}
+	return res, nil
+}
+
+func (d *DeprecationWarnings) record(msg, path string) {
+	hash := messageHash(msg)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.counts[hash]; !ok {
+		if len(d.counts) >= maxDeprecationMessages {
+			hash = "other"
+		} else {
+			log.Println("W! elasticsearch deprecation warning, message_hash:", hash, "path:", path, "message:", msg)
+		}
+	}
+	d.counts[hash]++
+}
+
+func messageHash(msg string) string {
+	sum := sha256.Sum256([]byte(msg))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// parseWarningHeader extracts the text of a warning, e.g.
+// 299 Elasticsearch-7.17.0-bee86328705acaa9a6daede7140defd4d9ec56bd "[types removal] ..." "Mon, 01 Jan 2024 00:00:00 GMT",
+// 299 is the code elasticsearch uses for deprecations
+func parseWarningHeader(h string) (string, bool) {
+	code, rest, found := strings.Cut(strings.TrimSpace(h), " ")
+	if !found || code != "299" {
+		return "", false
+	}
+	start := strings.Index(rest, `"`)
+	if start < 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	escaped := false
+	for _, r := range rest[start+1:] {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return b.String(), true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", false
+}
+
+// Describe adds deprecation warning metrics descriptions
+func (d *DeprecationWarnings) Describe(ch chan<- *prometheus.Desc) {
+	ch <- d.warningsDesc
+}
+
+// Collect exports the deprecation warning counters
+func (d *DeprecationWarnings) Collect(ch chan<- prometheus.Metric) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for hash, count := range d.counts {
+		ch <- prometheus.MustNewConstMetric(d.warningsDesc, prometheus.CounterValue, count, hash)
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeprecationWarnings(t *testing.T) {
+	msg := `[types removal] Specifying types in search requests is deprecated.`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/deprecated" {
+			w.Header().Add("Warning", `299 Elasticsearch-7.17.0-bee86328705acaa9a6daede7140defd4d9ec56bd "`+msg+`" "Mon, 01 Jan 2024 00:00:00 GMT"`)
+			w.Header().Add("Warning", `299 Elasticsearch-7.17.0-bee86328705acaa9a6daede7140defd4d9ec56bd "index name [\"a\"] is deprecated"`)
+		}
+		w.Header().Add("Warning", `199 agent "not a deprecation"`)
+		fmt.Fprintln(w, `{}`)
+	}))
+	defer ts.Close()
+
+	d := NewDeprecationWarnings(http.DefaultTransport)
+	client := &http.Client{Transport: d}
+	for _, p := range []string{"/deprecated", "/deprecated", "/"} {
+		res, err := client.Get(ts.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	want := fmt.Sprintf(`# HELP elasticsearch_deprecation_warnings_total Count of deprecation warnings returned by Elasticsearch, by the hash of the message
+		# TYPE elasticsearch_deprecation_warnings_total counter
+		elasticsearch_deprecation_warnings_total{message_hash="%s"} 2
+		elasticsearch_deprecation_warnings_total{message_hash="%s"} 2
+	`, messageHash(msg), messageHash(`index name ["a"] is deprecated`))
+	if err := testutil.CollectAndCompare(d, strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
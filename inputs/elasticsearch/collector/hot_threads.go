@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// ::: {node-1}{nodeId}{ephemeralId}...
+	hotThreadsNodeRegexp = regexp.MustCompile(`^:::\s*\{([^}]*)\}`)
+	// 23.4% (117.1ms out of 500ms) cpu usage by thread 'elasticsearch[node-1][write][T#3]'
+	// 8.x adds the split of the time: 100.0% [cpu=97.6%, other=2.4%] (500ms out of 500ms) cpu usage by thread ...
+	hotThreadsThreadRegexp = regexp.MustCompile(`^\s*(\d+(?:[.,]\d+)?)%\s*(?:\[\s*cpu=(\d+(?:[.,]\d+)?)%[^\]]*\]\s*)?(?:\([^)]*\)\s*)?cpu usage by thread`)
+)
+
+// HotThreads samples the busiest threads with GET /_nodes/hot_threads?type=cpu. The API
+// blocks while sampling, so it only runs once per interval and the results are cached
+// and exported on every collection
+type HotThreads struct {
+	client   *http.Client
+	url      *url.URL
+	all      bool
+	node     string
+	local    bool
+	threads  int
+	interval time.Duration
+
+	mu        sync.Mutex
+	lastFetch time.Time
+	results   []hotThread
+
+	up           prometheus.Gauge
+	totalScrapes prometheus.Counter
+
+	cpuPercentDesc *prometheus.Desc
+}
+
+type hotThread struct {
+	node       string
+	rank       int
+	cpuPercent float64
+}
+
+// NewHotThreads defines hot threads Prometheus metrics
+func NewHotThreads(client *http.Client, url *url.URL, all bool, node string, local bool, threads int, interval time.Duration) *HotThreads {
+	return &HotThreads{
+		client:   client,
+		url:      url,
+		all:      all,
+		node:     node,
+		local:    local,
+		threads:  threads,
+		interval: interval,
+
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, "hot_threads", "up"),
+			Help: "Was the last scrape of the Elasticsearch hot threads endpoint successful.",
+		}),
+		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, "hot_threads", "total_scrapes"),
+			Help: "Current total Elasticsearch hot threads scrapes.",
+		}),
+
+		cpuPercentDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "hot_threads", "cpu_percent"),
+			"CPU usage of the busiest threads of the node during the sampling interval, by rank",
+			[]string{"node", "thread_rank"}, nil,
+		),
+	}
+}
+
+// Describe adds hot threads metrics descriptions
+func (h *HotThreads) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.up.Desc()
+	ch <- h.totalScrapes.Desc()
+	ch <- h.cpuPercentDesc
+}
+
+func (h *HotThreads) fetchAndParse() ([]hotThread, error) {
+	u := *h.url
+
+	if h.all {
+		if h.local {
+			u.Path = path.Join(u.Path, "/_nodes/_local/hot_threads")
+		} else {
+			u.Path = path.Join(u.Path, "/_nodes/hot_threads")
+		}
+	} else {
+		if h.local {
+			u.Path = path.Join(u.Path, "/_nodes/_local", h.node, "hot_threads")
+		} else {
+			u.Path = path.Join(u.Path, "/_nodes", h.node, "hot_threads")
+		}
+	}
+	q := u.Query()
+	q.Set("threads", strconv.Itoa(h.threads))
+	q.Set("type", "cpu")
+	u.RawQuery = q.Encode()
+
+	res, err := h.client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hot threads from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	return parseHotThreads(res.Body, h.threads)
+}
+
+// parseHotThreads parses the plain text of the hot threads API, the stack traces are
+// skipped and at most n threads are returned per node, in the order of the response
+func parseHotThreads(r io.Reader, n int) ([]hotThread, error) {
+	var (
+		ret   []hotThread
+		node  string
+		rank  int
+		nodes int
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if m := hotThreadsNodeRegexp.FindStringSubmatch(line); m != nil {
+			node = m[1]
+			rank = 0
+			nodes++
+			continue
+		}
+		if node == "" {
+			continue
+		}
+		m := hotThreadsThreadRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		rank++
+		if rank > n {
+			continue
+		}
+		value := m[1]
+		if m[2] != "" {
+			value = m[2]
+		}
+		v, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, hotThread{node: node, rank: rank, cpuPercent: v})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if nodes == 0 {
+		return nil, fmt.Errorf("no node found in hot threads response")
+	}
+	return ret, nil
+}
+
+// Collect samples the hot threads when the interval has passed and exports the last results
+func (h *HotThreads) Collect(ch chan<- prometheus.Metric) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.lastFetch) >= h.interval {
+		h.totalScrapes.Inc()
+		h.lastFetch = time.Now()
+		results, err := h.fetchAndParse()
+		if err != nil {
+			h.up.Set(0)
+			h.results = nil
+			log.Println("failed to fetch and parse hot threads, err: ", err)
+		} else {
+			h.up.Set(1)
+			h.results = results
+		}
+	}
+
+	ch <- h.up
+	ch <- h.totalScrapes
+	for _, t := range h.results {
+		ch <- prometheus.MustNewConstMetric(h.cpuPercentDesc, prometheus.GaugeValue, t.cpuPercent, t.node, strconv.Itoa(t.rank))
+	}
+}
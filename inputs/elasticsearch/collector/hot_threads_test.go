@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHotThreads(t *testing.T) {
+	// Testcases created using:
+	//  curl 'http://localhost:9200/_nodes/hot_threads?threads=5&type=cpu'
+
+	tests := []struct {
+		name    string
+		file    string
+		threads int
+		want    string
+	}{
+		{
+			name:    "7.17.9",
+			file:    "../fixtures/hotthreads/7.17.9.txt",
+			threads: 5,
+			want: `# HELP elasticsearch_hot_threads_cpu_percent CPU usage of the busiest threads of the node during the sampling interval, by rank
+				# TYPE elasticsearch_hot_threads_cpu_percent gauge
+				elasticsearch_hot_threads_cpu_percent{node="es01",thread_rank="1"} 23.4
+				elasticsearch_hot_threads_cpu_percent{node="es01",thread_rank="2"} 4.1
+				elasticsearch_hot_threads_cpu_percent{node="es01",thread_rank="3"} 0.2
+				elasticsearch_hot_threads_cpu_percent{node="es02",thread_rank="1"} 1.5
+				# HELP elasticsearch_hot_threads_total_scrapes Current total Elasticsearch hot threads scrapes.
+				# TYPE elasticsearch_hot_threads_total_scrapes counter
+				elasticsearch_hot_threads_total_scrapes 1
+				# HELP elasticsearch_hot_threads_up Was the last scrape of the Elasticsearch hot threads endpoint successful.
+				# TYPE elasticsearch_hot_threads_up gauge
+				elasticsearch_hot_threads_up 1
+			`,
+		},
+		{
+			name:    "8.11.1",
+			file:    "../fixtures/hotthreads/8.11.1.txt",
+			threads: 2,
+			want: `# HELP elasticsearch_hot_threads_cpu_percent CPU usage of the busiest threads of the node during the sampling interval, by rank
+				# TYPE elasticsearch_hot_threads_cpu_percent gauge
+				elasticsearch_hot_threads_cpu_percent{node="es01",thread_rank="1"} 97.6
+				elasticsearch_hot_threads_cpu_percent{node="es01",thread_rank="2"} 12.5
+				# HELP elasticsearch_hot_threads_total_scrapes Current total Elasticsearch hot threads scrapes.
+				# TYPE elasticsearch_hot_threads_total_scrapes counter
+				elasticsearch_hot_threads_total_scrapes 1
+				# HELP elasticsearch_hot_threads_up Was the last scrape of the Elasticsearch hot threads endpoint successful.
+				# TYPE elasticsearch_hot_threads_up gauge
+				elasticsearch_hot_threads_up 1
+			`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.URL.Path != "/_nodes/hot_threads" || r.URL.Query().Get("type") != "cpu" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				w.Write(b)
+			}))
+			defer ts.Close()
+
+			u, err := url.Parse(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewHotThreads(http.DefaultClient, u, true, "", false, tt.threads, time.Hour)
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tt.want)); err != nil {
+				t.Fatal(err)
+			}
+			// the results are cached until the interval has passed
+			if err := testutil.CollectAndCompare(c, strings.NewReader(tt.want)); err != nil {
+				t.Fatal(err)
+			}
+			if requests != 1 {
+				t.Fatalf("expected 1 request, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestParseHotThreadsNoNode(t *testing.T) {
+	if _, err := parseHotThreads(strings.NewReader("<html>proxy error</html>"), 5); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := parseHotThreads(strings.NewReader(""), 5); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,232 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IndexAge reports the oldest index and the count of indices older than a threshold
+// for each configured index pattern, one series per pattern
+type IndexAge struct {
+	client    *http.Client
+	url       *url.URL
+	patterns  []string
+	threshold time.Duration
+
+	up                prometheus.Gauge
+	totalScrapes      prometheus.Counter
+	jsonParseFailures prometheus.Counter
+
+	indicesDesc        *prometheus.Desc
+	closedIndicesDesc  *prometheus.Desc
+	oldestCreationDesc *prometheus.Desc
+	oldestAgeDesc      *prometheus.Desc
+	olderThanDesc      *prometheus.Desc
+	thresholdDesc      *prometheus.Desc
+	patternUpDesc      *prometheus.Desc
+	now                func() time.Time
+}
+
+type catIndexAgeResponse []struct {
+	Index        string `json:"index"`
+	Status       string `json:"status"`
+	CreationDate string `json:"creation.date"`
+}
+
+// TimeValueToDuration parses elasticsearch time values like 30d or 12h
+func TimeValueToDuration(value string) (time.Duration, error) {
+	seconds, err := getTimeValueInSeconds(value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// NewIndexAge defines index age Prometheus metrics
+func NewIndexAge(client *http.Client, url *url.URL, patterns []string, threshold time.Duration) *IndexAge {
+	subsystem := "index_age"
+	labels := []string{"pattern"}
+
+	return &IndexAge{
+		client:    client,
+		url:       url,
+		patterns:  patterns,
+		threshold: threshold,
+		now:       time.Now,
+
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "up"),
+			Help: "Was the last scrape of the Elasticsearch cat indices endpoint for all patterns successful.",
+		}),
+		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "total_scrapes"),
+			Help: "Current total Elasticsearch index age scrapes.",
+		}),
+		jsonParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "json_parse_failures"),
+			Help: "Number of errors while parsing JSON.",
+		}),
+
+		indicesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "indices"),
+			"Count of open and closed indices matching the pattern",
+			labels, nil,
+		),
+		closedIndicesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "closed_indices"),
+			"Count of closed indices matching the pattern",
+			labels, nil,
+		),
+		oldestCreationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "oldest_creation_timestamp_seconds"),
+			"Creation date of the oldest index matching the pattern",
+			labels, nil,
+		),
+		oldestAgeDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "oldest_seconds"),
+			"Age of the oldest index matching the pattern",
+			labels, nil,
+		),
+		olderThanDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "indices_older_than_threshold"),
+			"Count of indices matching the pattern which are older than the threshold",
+			labels, nil,
+		),
+		thresholdDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "threshold_seconds"),
+			"Configured index age threshold",
+			labels, nil,
+		),
+		patternUpDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "pattern_up"),
+			"Was the last scrape of the pattern successful",
+			labels, nil,
+		),
+	}
+}
+
+// Describe adds index age metrics descriptions
+func (ia *IndexAge) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ia.up.Desc()
+	ch <- ia.totalScrapes.Desc()
+	ch <- ia.jsonParseFailures.Desc()
+	ch <- ia.indicesDesc
+	ch <- ia.closedIndicesDesc
+	ch <- ia.oldestCreationDesc
+	ch <- ia.oldestAgeDesc
+	ch <- ia.olderThanDesc
+	ch <- ia.thresholdDesc
+	ch <- ia.patternUpDesc
+}
+
+// fetchAndDecodeIndices queries /_cat/indices/<pattern>, the pattern is escaped as one path
+// segment so date math names like <logs-{now/d}> keep their slash
+func (ia *IndexAge) fetchAndDecodeIndices(pattern string) (catIndexAgeResponse, error) {
+	var cir catIndexAgeResponse
+
+	u := *ia.url
+	basePath := u.Path
+	if basePath == "/" {
+		basePath = ""
+	}
+	u.Path = basePath + "/_cat/indices/" + pattern
+	u.RawPath = basePath + "/_cat/indices/" + url.PathEscape(pattern)
+	q := u.Query()
+	q.Set("format", "json")
+	q.Set("h", "index,status,creation.date")
+	q.Set("expand_wildcards", "open,closed")
+	u.RawQuery = q.Encode()
+
+	res, err := ia.client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Println("failed to close http.Client, err: ", err)
+		}
+	}()
+
+	// a concrete index name or date math expression which does not exist
+	if res.StatusCode == http.StatusNotFound {
+		return cir, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	bts, err := io.ReadAll(res.Body)
+	if err != nil {
+		ia.jsonParseFailures.Inc()
+		return nil, err
+	}
+
+	if err := json.Unmarshal(bts, &cir); err != nil {
+		ia.jsonParseFailures.Inc()
+		return nil, err
+	}
+	return cir, nil
+}
+
+// Collect gets index age metric values
+func (ia *IndexAge) Collect(ch chan<- prometheus.Metric) {
+	ia.totalScrapes.Inc()
+	defer func() {
+		ch <- ia.up
+		ch <- ia.totalScrapes
+		ch <- ia.jsonParseFailures
+	}()
+
+	now := ia.now()
+	up := 1.0
+	for _, pattern := range ia.patterns {
+		cir, err := ia.fetchAndDecodeIndices(pattern)
+		if err != nil {
+			up = 0
+			log.Println("failed to fetch and decode indices of pattern", pattern, "err: ", err)
+			ch <- prometheus.MustNewConstMetric(ia.patternUpDesc, prometheus.GaugeValue, 0, pattern)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(ia.patternUpDesc, prometheus.GaugeValue, 1, pattern)
+
+		var closed, olderThan float64
+		var oldest int64
+		for _, idx := range cir {
+			if idx.Status == "close" {
+				closed++
+			}
+			created, err := strconv.ParseInt(idx.CreationDate, 10, 64)
+			if err != nil {
+				log.Println("failed to parse creation date of index", idx.Index, "err: ", err)
+				continue
+			}
+			if oldest == 0 || created < oldest {
+				oldest = created
+			}
+			if now.Sub(time.UnixMilli(created)) > ia.threshold {
+				olderThan++
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(ia.indicesDesc, prometheus.GaugeValue, float64(len(cir)), pattern)
+		ch <- prometheus.MustNewConstMetric(ia.closedIndicesDesc, prometheus.GaugeValue, closed, pattern)
+		ch <- prometheus.MustNewConstMetric(ia.olderThanDesc, prometheus.GaugeValue, olderThan, pattern)
+		ch <- prometheus.MustNewConstMetric(ia.thresholdDesc, prometheus.GaugeValue, ia.threshold.Seconds(), pattern)
+		if oldest > 0 {
+			ch <- prometheus.MustNewConstMetric(ia.oldestCreationDesc, prometheus.GaugeValue, float64(oldest)/1000, pattern)
+			ch <- prometheus.MustNewConstMetric(ia.oldestAgeDesc, prometheus.GaugeValue, now.Sub(time.UnixMilli(oldest)).Seconds(), pattern)
+		}
+	}
+	ia.up.Set(up)
+}
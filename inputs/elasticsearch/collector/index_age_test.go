@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestIndexAge(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := func(n int) int64 { return now.Add(-time.Duration(n) * 24 * time.Hour).UnixMilli() }
+
+	var rawPaths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawPaths = append(rawPaths, r.URL.EscapedPath())
+		switch r.URL.Path {
+		case "/_cat/indices/logs-*":
+			fmt.Fprintf(w, `[
+				{"index":"logs-2024.02.29","status":"open","creation.date":"%d"},
+				{"index":"logs-2024.01.01","status":"close","creation.date":"%d"},
+				{"index":"logs-2023.12.01","status":"open","creation.date":"%d"}
+			]`, day(1), day(60), day(91))
+		case "/_cat/indices/<audit-{now/d}>":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(w, `{"error":{"type":"index_not_found_exception"},"status":404}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threshold, err := TimeValueToDuration("30d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewIndexAge(http.DefaultClient, u, []string{"logs-*", "<audit-{now/d}>"}, threshold)
+	c.now = func() time.Time { return now }
+
+	want := `# HELP elasticsearch_index_age_closed_indices Count of closed indices matching the pattern
+		# TYPE elasticsearch_index_age_closed_indices gauge
+		elasticsearch_index_age_closed_indices{pattern="<audit-{now/d}>"} 0
+		elasticsearch_index_age_closed_indices{pattern="logs-*"} 1
+		# HELP elasticsearch_index_age_indices Count of open and closed indices matching the pattern
+		# TYPE elasticsearch_index_age_indices gauge
+		elasticsearch_index_age_indices{pattern="<audit-{now/d}>"} 0
+		elasticsearch_index_age_indices{pattern="logs-*"} 3
+		# HELP elasticsearch_index_age_indices_older_than_threshold Count of indices matching the pattern which are older than the threshold
+		# TYPE elasticsearch_index_age_indices_older_than_threshold gauge
+		elasticsearch_index_age_indices_older_than_threshold{pattern="<audit-{now/d}>"} 0
+		elasticsearch_index_age_indices_older_than_threshold{pattern="logs-*"} 2
+		# HELP elasticsearch_index_age_oldest_seconds Age of the oldest index matching the pattern
+		# TYPE elasticsearch_index_age_oldest_seconds gauge
+		elasticsearch_index_age_oldest_seconds{pattern="logs-*"} 7.8624e+06
+		# HELP elasticsearch_index_age_up Was the last scrape of the Elasticsearch cat indices endpoint for all patterns successful.
+		# TYPE elasticsearch_index_age_up gauge
+		elasticsearch_index_age_up 1
+	`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"elasticsearch_index_age_closed_indices",
+		"elasticsearch_index_age_indices",
+		"elasticsearch_index_age_indices_older_than_threshold",
+		"elasticsearch_index_age_oldest_seconds",
+		"elasticsearch_index_age_up",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range rawPaths {
+		if strings.Contains(p, "audit") && p != "/_cat/indices/%3Caudit-%7Bnow%2Fd%7D%3E" {
+			t.Errorf("date math index name is not escaped as one path segment: %s", p)
+		}
+	}
+}
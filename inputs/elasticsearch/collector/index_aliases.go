@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/pkg/filter"
+)
+
+// IndexAliases exports the alias to index mapping as an info metric, so that metrics
+// labeled with concrete index names can be joined on the alias
+type IndexAliases struct {
+	client      *http.Client
+	url         *url.URL
+	indexFilter filter.Filter
+
+	up                prometheus.Gauge
+	totalScrapes      prometheus.Counter
+	jsonParseFailures prometheus.Counter
+
+	aliasDesc *prometheus.Desc
+}
+
+type indexAlias struct {
+	index        string
+	alias        string
+	isWriteIndex bool
+}
+
+// NewIndexAliases defines index alias Prometheus metrics, indexFilter may be nil to export all indices
+func NewIndexAliases(client *http.Client, url *url.URL, indexFilter filter.Filter) *IndexAliases {
+	subsystem := "index_aliases"
+
+	return &IndexAliases{
+		client:      client,
+		url:         url,
+		indexFilter: indexFilter,
+
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "up"),
+			Help: "Was the last scrape of the Elasticsearch alias endpoint successful.",
+		}),
+		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "total_scrapes"),
+			Help: "Current total Elasticsearch alias scrapes.",
+		}),
+		jsonParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "json_parse_failures"),
+			Help: "Number of errors while parsing JSON.",
+		}),
+
+		aliasDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "index", "alias"),
+			"Alias pointing to the index, the value is always 1",
+			[]string{"index", "alias", "is_write_index"}, nil,
+		),
+	}
+}
+
+// Describe adds index alias metrics descriptions
+func (ia *IndexAliases) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ia.up.Desc()
+	ch <- ia.totalScrapes.Desc()
+	ch <- ia.jsonParseFailures.Desc()
+	ch <- ia.aliasDesc
+}
+
+// fetchAndDecodeAliases streams /_alias, the response holds every index of the cluster
+// and is decoded one index at a time instead of being read into memory at once
+func (ia *IndexAliases) fetchAndDecodeAliases() ([]indexAlias, error) {
+	u := *ia.url
+	u.Path = path.Join(u.Path, "/_alias")
+
+	res, err := ia.client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Println("failed to close http.Client, err: ", err)
+		}
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	aliases, err := decodeAliases(json.NewDecoder(res.Body), ia.indexFilter)
+	if err != nil {
+		ia.jsonParseFailures.Inc()
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func decodeAliases(dec *json.Decoder, indexFilter filter.Filter) ([]indexAlias, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var ret []indexAlias
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		index, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected token %v, expecting index name", t)
+		}
+
+		if indexFilter != nil && !indexFilter.Match(index) {
+			// still has to be decoded to advance the decoder, nothing is kept
+			var skip struct{}
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var v struct {
+			Aliases map[string]struct {
+				IsWriteIndex *bool `json:"is_write_index"`
+			} `json:"aliases"`
+		}
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		for alias, a := range v.Aliases {
+			ret = append(ret, indexAlias{
+				index:        index,
+				alias:        alias,
+				isWriteIndex: a.IsWriteIndex != nil && *a.IsWriteIndex,
+			})
+		}
+	}
+
+	return ret, expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("unexpected token %v, expecting %v", t, delim)
+	}
+	return nil
+}
+
+// Collect gets index alias metric values
+func (ia *IndexAliases) Collect(ch chan<- prometheus.Metric) {
+	ia.totalScrapes.Inc()
+	defer func() {
+		ch <- ia.up
+		ch <- ia.totalScrapes
+		ch <- ia.jsonParseFailures
+	}()
+
+	aliases, err := ia.fetchAndDecodeAliases()
+	if err != nil {
+		ia.up.Set(0)
+		log.Println("failed to fetch and decode aliases, err: ", err)
+		return
+	}
+	ia.up.Set(1)
+
+	for _, a := range aliases {
+		ch <- prometheus.MustNewConstMetric(ia.aliasDesc, prometheus.GaugeValue, 1,
+			a.index, a.alias, strconv.FormatBool(a.isWriteIndex))
+	}
+}
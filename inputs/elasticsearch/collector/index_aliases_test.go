@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"flashcat.cloud/categraf/pkg/filter"
+)
+
+func TestIndexAliases(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_alias" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, `{
+			"logs-000002":{"aliases":{"logs-write":{"is_write_index":true},"logs-read":{}}},
+			"logs-000001":{"aliases":{"logs-write":{"is_write_index":false},"logs-read":{"filter":{"term":{"a":"b"}}}}},
+			"metrics-000001":{"aliases":{"metrics-write":{"is_write_index":true}}},
+			".kibana_1":{"aliases":{".kibana":{}}},
+			"no-alias":{"aliases":{}}
+		}`)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := filter.NewIncludeExcludeFilter([]string{"logs-*", "no-*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewIndexAliases(http.DefaultClient, u, f)
+	want := `# HELP elasticsearch_index_alias Alias pointing to the index, the value is always 1
+		# TYPE elasticsearch_index_alias gauge
+		elasticsearch_index_alias{alias="logs-read",index="logs-000001",is_write_index="false"} 1
+		elasticsearch_index_alias{alias="logs-read",index="logs-000002",is_write_index="false"} 1
+		elasticsearch_index_alias{alias="logs-write",index="logs-000001",is_write_index="false"} 1
+		elasticsearch_index_alias{alias="logs-write",index="logs-000002",is_write_index="true"} 1
+		# HELP elasticsearch_index_aliases_up Was the last scrape of the Elasticsearch alias endpoint successful.
+		# TYPE elasticsearch_index_aliases_up gauge
+		elasticsearch_index_aliases_up 1
+	`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"elasticsearch_index_alias", "elasticsearch_index_aliases_up"); err != nil {
+		t.Fatal(err)
+	}
+}
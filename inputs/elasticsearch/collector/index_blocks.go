@@ -0,0 +1,273 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// indexBlockTypes are the index.blocks.* settings, read_only_allow_delete being set on the
+// indices of the nodes past the flood stage watermark
+var indexBlockTypes = []string{"read_only", "read_only_allow_delete", "read", "write", "metadata"}
+
+// IndexBlocks reports the count of indices with each block, cluster wide and for each
+// configured index pattern, along with the cluster.blocks.* settings. The settings are
+// fetched with filter_path, only the blocked indices are in the responses.
+type IndexBlocks struct {
+	client   *http.Client
+	url      *url.URL
+	patterns []string
+
+	up                prometheus.Gauge
+	totalScrapes      prometheus.Counter
+	jsonParseFailures prometheus.Counter
+
+	indicesDesc         *prometheus.Desc
+	blockedIndicesDesc  *prometheus.Desc
+	patternIndicesDesc  *prometheus.Desc
+	patternBlockedDesc  *prometheus.Desc
+	clusterReadOnlyDesc *prometheus.Desc
+	clusterAllowDelDesc *prometheus.Desc
+}
+
+// indexBlocksResponse is the response of /<index>/_settings?filter_path=*.settings.index.blocks
+type indexBlocksResponse map[string]struct {
+	Settings struct {
+		Index struct {
+			Blocks map[string]interface{} `json:"blocks"`
+		} `json:"index"`
+	} `json:"settings"`
+}
+
+type clusterBlocksSettings struct {
+	Cluster struct {
+		Blocks map[string]interface{} `json:"blocks"`
+	} `json:"cluster"`
+}
+
+type clusterBlocksResponse struct {
+	Persistent clusterBlocksSettings `json:"persistent"`
+	Transient  clusterBlocksSettings `json:"transient"`
+}
+
+// NewIndexBlocks defines index blocks Prometheus metrics
+func NewIndexBlocks(client *http.Client, url *url.URL, patterns []string) *IndexBlocks {
+	subsystem := "index_blocks"
+
+	return &IndexBlocks{
+		client:   client,
+		url:      url,
+		patterns: patterns,
+
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "up"),
+			Help: "Was the last scrape of the Elasticsearch index and cluster blocks successful.",
+		}),
+		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "total_scrapes"),
+			Help: "Current total Elasticsearch index blocks scrapes.",
+		}),
+		jsonParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: prometheus.BuildFQName(namespace, subsystem, "json_parse_failures"),
+			Help: "Number of errors while parsing JSON.",
+		}),
+
+		indicesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "indices"),
+			"Count of indices of the cluster with the block",
+			[]string{"block"}, nil,
+		),
+		blockedIndicesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "blocked_indices"),
+			"Count of indices of the cluster with any block",
+			nil, nil,
+		),
+		patternIndicesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "pattern_indices"),
+			"Count of indices matching the pattern with the block",
+			[]string{"pattern", "block"}, nil,
+		),
+		patternBlockedDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "pattern_blocked_indices"),
+			"Count of indices matching the pattern with any block",
+			[]string{"pattern"}, nil,
+		),
+		clusterReadOnlyDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "cluster_read_only"),
+			"Is the cluster.blocks.read_only setting enabled",
+			nil, nil,
+		),
+		clusterAllowDelDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "cluster_read_only_allow_delete"),
+			"Is the cluster.blocks.read_only_allow_delete setting enabled",
+			nil, nil,
+		),
+	}
+}
+
+// Describe adds index blocks metrics descriptions
+func (ib *IndexBlocks) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ib.up.Desc()
+	ch <- ib.totalScrapes.Desc()
+	ch <- ib.jsonParseFailures.Desc()
+	ch <- ib.indicesDesc
+	ch <- ib.blockedIndicesDesc
+	ch <- ib.patternIndicesDesc
+	ch <- ib.patternBlockedDesc
+	ch <- ib.clusterReadOnlyDesc
+	ch <- ib.clusterAllowDelDesc
+}
+
+func (ib *IndexBlocks) get(path, rawPath string, query url.Values, v interface{}) error {
+	u := *ib.url
+	basePath := u.Path
+	if basePath == "/" {
+		basePath = ""
+	}
+	u.Path = basePath + path
+	u.RawPath = basePath + rawPath
+	u.RawQuery = query.Encode()
+
+	res, err := ib.client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to get from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+
+	defer func() {
+		err = res.Body.Close()
+		if err != nil {
+			log.Println("failed to close http.Client, err: ", err)
+		}
+	}()
+
+	// a concrete index name or date math expression which does not exist
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	bts, err := io.ReadAll(res.Body)
+	if err != nil {
+		ib.jsonParseFailures.Inc()
+		return err
+	}
+	// filter_path returns {} when nothing matches
+	if err := json.Unmarshal(bts, v); err != nil {
+		ib.jsonParseFailures.Inc()
+		return err
+	}
+	return nil
+}
+
+// fetchIndexBlocks returns the count of indices matching the pattern for each block type
+// and the count of indices with any block
+func (ib *IndexBlocks) fetchIndexBlocks(pattern string) (map[string]float64, float64, error) {
+	q := url.Values{}
+	q.Set("filter_path", "*.settings.index.blocks")
+	q.Set("expand_wildcards", "open,closed")
+	var ibr indexBlocksResponse
+	if err := ib.get("/"+pattern+"/_settings", "/"+url.PathEscape(pattern)+"/_settings", q, &ibr); err != nil {
+		return nil, 0, err
+	}
+
+	counts := make(map[string]float64, len(indexBlockTypes))
+	for _, t := range indexBlockTypes {
+		counts[t] = 0
+	}
+	var blocked float64
+	for _, idx := range ibr {
+		hasBlock := false
+		for block, v := range idx.Settings.Index.Blocks {
+			if !settingEnabled(v) {
+				continue
+			}
+			counts[block]++
+			hasBlock = true
+		}
+		if hasBlock {
+			blocked++
+		}
+	}
+	return counts, blocked, nil
+}
+
+// settingEnabled tells a boolean setting, returned either as a string or a boolean
+func settingEnabled(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// clusterBlock returns the cluster.blocks setting, the transient setting overriding the
+// persistent one
+func clusterBlock(cbr *clusterBlocksResponse, name string) float64 {
+	v, has := cbr.Transient.Cluster.Blocks[name]
+	if !has {
+		v = cbr.Persistent.Cluster.Blocks[name]
+	}
+	if settingEnabled(v) {
+		return 1
+	}
+	return 0
+}
+
+// Collect gets index blocks metric values
+func (ib *IndexBlocks) Collect(ch chan<- prometheus.Metric) {
+	ib.totalScrapes.Inc()
+	defer func() {
+		ch <- ib.up
+		ch <- ib.totalScrapes
+		ch <- ib.jsonParseFailures
+	}()
+
+	up := 1.0
+	counts, blocked, err := ib.fetchIndexBlocks("_all")
+	if err != nil {
+		up = 0
+		log.Println("failed to fetch and decode index blocks, err: ", err)
+	} else {
+		for _, t := range indexBlockTypes {
+			ch <- prometheus.MustNewConstMetric(ib.indicesDesc, prometheus.GaugeValue, counts[t], t)
+		}
+		ch <- prometheus.MustNewConstMetric(ib.blockedIndicesDesc, prometheus.GaugeValue, blocked)
+	}
+
+	for _, pattern := range ib.patterns {
+		counts, blocked, err := ib.fetchIndexBlocks(pattern)
+		if err != nil {
+			up = 0
+			log.Println("failed to fetch and decode index blocks of pattern", pattern, "err: ", err)
+			continue
+		}
+		for _, t := range indexBlockTypes {
+			ch <- prometheus.MustNewConstMetric(ib.patternIndicesDesc, prometheus.GaugeValue, counts[t], pattern, t)
+		}
+		ch <- prometheus.MustNewConstMetric(ib.patternBlockedDesc, prometheus.GaugeValue, blocked, pattern)
+	}
+
+	q := url.Values{}
+	q.Set("filter_path", "*.cluster.blocks")
+	var cbr clusterBlocksResponse
+	if err := ib.get("/_cluster/settings", "/_cluster/settings", q, &cbr); err != nil {
+		up = 0
+		log.Println("failed to fetch and decode cluster blocks, err: ", err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(ib.clusterReadOnlyDesc, prometheus.GaugeValue, clusterBlock(&cbr, "read_only"))
+		ch <- prometheus.MustNewConstMetric(ib.clusterAllowDelDesc, prometheus.GaugeValue, clusterBlock(&cbr, "read_only_allow_delete"))
+	}
+	ib.up.Set(up)
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestIndexBlocks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_all/_settings":
+			if r.URL.Query().Get("filter_path") != "*.settings.index.blocks" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprintln(w, `{
+				"logs-2024.03.01":{"settings":{"index":{"blocks":{"read_only_allow_delete":"true"}}}},
+				"logs-2024.03.02":{"settings":{"index":{"blocks":{"read_only_allow_delete":"true","write":"true"}}}},
+				"metrics-2024.03.01":{"settings":{"index":{"blocks":{"write":"false"}}}}
+			}`)
+		case "/logs-*/_settings":
+			fmt.Fprintln(w, `{
+				"logs-2024.03.01":{"settings":{"index":{"blocks":{"read_only_allow_delete":"true"}}}},
+				"logs-2024.03.02":{"settings":{"index":{"blocks":{"read_only_allow_delete":"true","write":"true"}}}}
+			}`)
+		case "/audit-*/_settings":
+			// filter_path without any match
+			fmt.Fprintln(w, `{}`)
+		case "/_cluster/settings":
+			fmt.Fprintln(w, `{"persistent":{"cluster":{"blocks":{"read_only":"true","read_only_allow_delete":"true"}}},
+				"transient":{"cluster":{"blocks":{"read_only":"false"}}}}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewIndexBlocks(http.DefaultClient, u, []string{"logs-*", "audit-*"})
+	want := `# HELP elasticsearch_index_blocks_blocked_indices Count of indices of the cluster with any block
+		# TYPE elasticsearch_index_blocks_blocked_indices gauge
+		elasticsearch_index_blocks_blocked_indices 2
+		# HELP elasticsearch_index_blocks_cluster_read_only Is the cluster.blocks.read_only setting enabled
+		# TYPE elasticsearch_index_blocks_cluster_read_only gauge
+		elasticsearch_index_blocks_cluster_read_only 0
+		# HELP elasticsearch_index_blocks_cluster_read_only_allow_delete Is the cluster.blocks.read_only_allow_delete setting enabled
+		# TYPE elasticsearch_index_blocks_cluster_read_only_allow_delete gauge
+		elasticsearch_index_blocks_cluster_read_only_allow_delete 1
+		# HELP elasticsearch_index_blocks_indices Count of indices of the cluster with the block
+		# TYPE elasticsearch_index_blocks_indices gauge
+		elasticsearch_index_blocks_indices{block="metadata"} 0
+		elasticsearch_index_blocks_indices{block="read"} 0
+		elasticsearch_index_blocks_indices{block="read_only"} 0
+		elasticsearch_index_blocks_indices{block="read_only_allow_delete"} 2
+		elasticsearch_index_blocks_indices{block="write"} 1
+		# HELP elasticsearch_index_blocks_pattern_blocked_indices Count of indices matching the pattern with any block
+		# TYPE elasticsearch_index_blocks_pattern_blocked_indices gauge
+		elasticsearch_index_blocks_pattern_blocked_indices{pattern="audit-*"} 0
+		elasticsearch_index_blocks_pattern_blocked_indices{pattern="logs-*"} 2
+		# HELP elasticsearch_index_blocks_up Was the last scrape of the Elasticsearch index and cluster blocks successful.
+		# TYPE elasticsearch_index_blocks_up gauge
+		elasticsearch_index_blocks_up 1
+	`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"elasticsearch_index_blocks_blocked_indices",
+		"elasticsearch_index_blocks_cluster_read_only",
+		"elasticsearch_index_blocks_cluster_read_only_allow_delete",
+		"elasticsearch_index_blocks_indices",
+		"elasticsearch_index_blocks_pattern_blocked_indices",
+		"elasticsearch_index_blocks_up",
+	); err != nil {
+		t.Fatal(err)
+	}
+}
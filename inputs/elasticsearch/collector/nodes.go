@@ -15,6 +15,7 @@ package collector
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -179,6 +180,10 @@ type Nodes struct {
 	node      string
 	local     bool
 	nodeStats []string
+	// set once the cluster rejected the indexing_pressure sub-stats, older than 7.9
+	noIndexingPressure bool
+	// derives the latencies from the indices stats, kept across gathers
+	latency *NodeLatency
 
 	up                              prometheus.Gauge
 	totalScrapes, jsonParseFailures prometheus.Counter
@@ -188,6 +193,7 @@ type Nodes struct {
 	osMetrics                 []*nodeMetric
 	processMetrics            []*nodeMetric
 	breakerMetrics            []*breakerMetric
+	indexingPressureMetrics   []*nodeMetric
 	indicesMetrics            []*nodeMetric
 	transportMetrics          []*nodeMetric
 	threadPoolMetrics         []*threadPoolMetric
@@ -1121,6 +1127,210 @@ func NewNodes(client *http.Client, url *url.URL, all bool, node string, local bo
 					return append(defaultNodeLabelValues(cluster, node), breaker)
 				},
 			},
+			{
+				Type: prometheus.CounterValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "breakers", "tripped_total"),
+					"Number of times the circuit breaker has been triggered and prevented an out of memory error",
+					defaultBreakerLabels, nil,
+				),
+				Value: func(breakerStats NodeStatsBreakersResponse) float64 {
+					return float64(breakerStats.Tripped)
+				},
+				Labels: func(cluster string, node NodeStatsNodeResponse, breaker string) []string {
+					return append(defaultNodeLabelValues(cluster, node), breaker)
+				},
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "breakers", "estimated_size_bytes"),
+					"Estimated memory used for the operation of the circuit breaker",
+					defaultBreakerLabels, nil,
+				),
+				Value: func(breakerStats NodeStatsBreakersResponse) float64 {
+					return float64(breakerStats.EstimatedSize)
+				},
+				Labels: func(cluster string, node NodeStatsNodeResponse, breaker string) []string {
+					return append(defaultNodeLabelValues(cluster, node), breaker)
+				},
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "breakers", "limit_size_bytes"),
+					"Memory limit of the circuit breaker",
+					defaultBreakerLabels, nil,
+				),
+				Value: func(breakerStats NodeStatsBreakersResponse) float64 {
+					return float64(breakerStats.LimitSize)
+				},
+				Labels: func(cluster string, node NodeStatsNodeResponse, breaker string) []string {
+					return append(defaultNodeLabelValues(cluster, node), breaker)
+				},
+			},
+		},
+		indexingPressureMetrics: []*nodeMetric{
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "current_coordinating_bytes"),
+					"Memory consumed by the indexing requests in the coordinating stage",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Current.Coordinating)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "current_primary_bytes"),
+					"Memory consumed by the indexing requests in the primary stage",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Current.Primary)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "current_replica_bytes"),
+					"Memory consumed by the indexing requests in the replica stage",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Current.Replica)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "current_combined_coordinating_and_primary_bytes"),
+					"Memory consumed by the indexing requests in the coordinating or primary stage, limited by indexing_pressure.memory.limit",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Current.CombinedCoordinatingAndPrimary)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "current_all_bytes"),
+					"Memory consumed by the indexing requests in all the stages",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Current.All)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.CounterValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "coordinating_bytes_total"),
+					"Memory consumed by the indexing requests in the coordinating stage since the node start",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Total.Coordinating)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.CounterValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "primary_bytes_total"),
+					"Memory consumed by the indexing requests in the primary stage since the node start",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Total.Primary)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.CounterValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "replica_bytes_total"),
+					"Memory consumed by the indexing requests in the replica stage since the node start",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Total.Replica)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.CounterValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "coordinating_rejections_total"),
+					"Number of indexing requests rejected in the coordinating stage",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Total.CoordinatingRejections)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.CounterValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "primary_rejections_total"),
+					"Number of indexing requests rejected in the primary stage",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Total.PrimaryRejections)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.CounterValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "replica_rejections_total"),
+					"Number of indexing requests rejected in the replica stage",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Total.ReplicaRejections)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "limit_bytes"),
+					"Memory limit of the indexing requests in the coordinating or primary stage, the replica stage is limited to 1.5 times the limit",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					return float64(node.IndexingPressure.Memory.Limit)
+				},
+				Labels: defaultNodeLabelValues,
+			},
+			{
+				Type: prometheus.GaugeValue,
+				Desc: prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, "indexing_pressure", "limit_percent"),
+					"Percent of the memory limit consumed by the indexing requests in the coordinating or primary stage, the requests are rejected at 100",
+					defaultNodeLabels, nil,
+				),
+				Value: func(node NodeStatsNodeResponse) float64 {
+					memory := node.IndexingPressure.Memory
+					if memory.Limit <= 0 {
+						return 0
+					}
+					return float64(memory.Current.CombinedCoordinatingAndPrimary) / float64(memory.Limit) * 100
+				},
+				Labels: defaultNodeLabelValues,
+			},
 		},
 		indicesMetrics: []*nodeMetric{
 			{
@@ -2108,8 +2318,17 @@ func NewNodes(client *http.Client, url *url.URL, all bool, node string, local bo
 	}
 }
 
+// WithLatency reports the latencies of the nodes derived by l from the indices stats
+func (c *Nodes) WithLatency(l *NodeLatency) *Nodes {
+	c.latency = l
+	return c
+}
+
 // Describe add metrics descriptions
 func (c *Nodes) Describe(ch chan<- *prometheus.Desc) {
+	if c.latency != nil {
+		c.latency.Describe(ch)
+	}
 	for _, metric := range c.jvmMetrics {
 		ch <- metric.Desc
 	}
@@ -2119,6 +2338,9 @@ func (c *Nodes) Describe(ch chan<- *prometheus.Desc) {
 	for _, metric := range c.breakerMetrics {
 		ch <- metric.Desc
 	}
+	for _, metric := range c.indexingPressureMetrics {
+		ch <- metric.Desc
+	}
 	for _, metric := range c.osMetrics {
 		ch <- metric.Desc
 	}
@@ -2145,7 +2367,34 @@ func (c *Nodes) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.jsonParseFailures.Desc()
 }
 
+var errNodeStatsBadRequest = errors.New("HTTP Request failed with code 400")
+
 func (c *Nodes) fetchAndDecodeNodeStats() (nodeStatsResponse, error) {
+	nsr, err := c.fetchNodeStats(c.requestedNodeStats())
+	if errors.Is(err, errNodeStatsBadRequest) && !c.noIndexingPressure && isEnable("indexing_pressure", c.nodeStats) {
+		// clusters older than 7.9 reject the unrecognized indexing_pressure metric
+		c.noIndexingPressure = true
+		return c.fetchNodeStats(c.requestedNodeStats())
+	}
+	return nsr, err
+}
+
+// requestedNodeStats returns the sub-stats of the request, without indexing_pressure once
+// the cluster rejected it
+func (c *Nodes) requestedNodeStats() []string {
+	if !c.noIndexingPressure {
+		return c.nodeStats
+	}
+	stats := make([]string, 0, len(c.nodeStats))
+	for _, s := range c.nodeStats {
+		if s != "indexing_pressure" {
+			stats = append(stats, s)
+		}
+	}
+	return stats
+}
+
+func (c *Nodes) fetchNodeStats(nodeStats []string) (nodeStatsResponse, error) {
 	var nsr nodeStatsResponse
 
 	u := *c.url
@@ -2163,8 +2412,8 @@ func (c *Nodes) fetchAndDecodeNodeStats() (nodeStatsResponse, error) {
 			u.Path = path.Join(u.Path, "/_nodes", c.node, "stats")
 		}
 	}
-	if len(c.nodeStats) != 0 {
-		u.Path = fmt.Sprintf("%s/%s", u.Path, strings.Join(c.nodeStats, ","))
+	if len(nodeStats) != 0 {
+		u.Path = fmt.Sprintf("%s/%s", u.Path, strings.Join(nodeStats, ","))
 	}
 
 	res, err := c.client.Get(u.String())
@@ -2180,6 +2429,9 @@ func (c *Nodes) fetchAndDecodeNodeStats() (nodeStatsResponse, error) {
 		}
 	}()
 
+	if res.StatusCode == http.StatusBadRequest {
+		return nsr, errNodeStatsBadRequest
+	}
 	if res.StatusCode != http.StatusOK {
 		return nsr, fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
 	}
@@ -2291,6 +2543,10 @@ func (c *Nodes) Collect(ch chan<- prometheus.Metric) {
 	}
 	c.up.Set(1)
 
+	if c.latency != nil && isEnable("indices", c.nodeStats) {
+		c.latency.collect(ch, nodeStatsResp.ClusterName, nodeStatsResp.Nodes)
+	}
+
 	for _, node := range nodeStatsResp.Nodes {
 		// Handle the node labels metric
 		roles := getRoles(node)
@@ -2344,6 +2600,18 @@ func (c *Nodes) Collect(ch chan<- prometheus.Metric) {
 			}
 		}
 
+		// clusters older than 7.9 have no indexing pressure stats
+		if isEnable("indexing_pressure", c.nodeStats) && node.IndexingPressure != nil {
+			for _, metric := range c.indexingPressureMetrics {
+				ch <- prometheus.MustNewConstMetric(
+					metric.Desc,
+					metric.Type,
+					metric.Value(node),
+					metric.Labels(nodeStatsResp.ClusterName, node)...,
+				)
+			}
+		}
+
 		if isEnable("process", c.nodeStats) {
 			// Process Stats
 			for _, metric := range c.processMetrics {
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNodesBreakers(t *testing.T) {
+	// breaker names differ across versions, e.g. eql_sequence and model_inference only exist in 8.x
+	stats := `{
+		"cluster_name": "es8",
+		"nodes": {
+			"n1": {
+				"name": "es01",
+				"host": "10.0.0.1",
+				"roles": [],
+				"breakers": {
+					"parent": {"limit_size_in_bytes": 1020054732, "estimated_size_in_bytes": 600000000, "overhead": 1.0, "tripped": 3},
+					"eql_sequence": {"limit_size_in_bytes": 536870912, "estimated_size_in_bytes": 0, "overhead": 1.0, "tripped": 0},
+					"model_inference": {"limit_size_in_bytes": 536870912, "estimated_size_in_bytes": 1024, "overhead": 1.0, "tripped": 0}
+				}
+			}
+		}
+	}`
+
+	var gotPath string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		fmt.Fprintln(w, stats)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewNodes(http.DefaultClient, u, true, "_local", false, []string{"breaker"})
+	labels := `cluster="es8",es_client_node="false",es_data_node="false",es_ingest_node="false",es_master_node="false",host="10.0.0.1",name="es01"`
+	want := strings.NewReplacer("LABELS", labels).Replace(`# HELP elasticsearch_breakers_estimated_size_bytes Estimated memory used for the operation of the circuit breaker
+		# TYPE elasticsearch_breakers_estimated_size_bytes gauge
+		elasticsearch_breakers_estimated_size_bytes{breaker="eql_sequence",LABELS} 0
+		elasticsearch_breakers_estimated_size_bytes{breaker="model_inference",LABELS} 1024
+		elasticsearch_breakers_estimated_size_bytes{breaker="parent",LABELS} 6e+08
+		# HELP elasticsearch_breakers_limit_size_bytes Memory limit of the circuit breaker
+		# TYPE elasticsearch_breakers_limit_size_bytes gauge
+		elasticsearch_breakers_limit_size_bytes{breaker="eql_sequence",LABELS} 5.36870912e+08
+		elasticsearch_breakers_limit_size_bytes{breaker="model_inference",LABELS} 5.36870912e+08
+		elasticsearch_breakers_limit_size_bytes{breaker="parent",LABELS} 1.020054732e+09
+		# HELP elasticsearch_breakers_tripped_total Number of times the circuit breaker has been triggered and prevented an out of memory error
+		# TYPE elasticsearch_breakers_tripped_total counter
+		elasticsearch_breakers_tripped_total{breaker="eql_sequence",LABELS} 0
+		elasticsearch_breakers_tripped_total{breaker="model_inference",LABELS} 0
+		elasticsearch_breakers_tripped_total{breaker="parent",LABELS} 3
+	`)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"elasticsearch_breakers_estimated_size_bytes",
+		"elasticsearch_breakers_limit_size_bytes",
+		"elasticsearch_breakers_tripped_total",
+	); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/_nodes/stats/breaker" {
+		t.Errorf("unexpected node stats path %s", gotPath)
+	}
+}
@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNodesIndexingPressure(t *testing.T) {
+	stats := `{
+		"cluster_name": "es7",
+		"nodes": {
+			"n1": {
+				"name": "es01",
+				"host": "10.0.0.1",
+				"roles": [],
+				"indexing_pressure": {
+					"memory": {
+						"current": {
+							"combined_coordinating_and_primary_in_bytes": 25000000,
+							"coordinating_in_bytes": 20000000,
+							"primary_in_bytes": 5000000,
+							"replica_in_bytes": 1000,
+							"all_in_bytes": 25001000
+						},
+						"total": {
+							"combined_coordinating_and_primary_in_bytes": 900000000,
+							"coordinating_in_bytes": 600000000,
+							"primary_in_bytes": 300000000,
+							"replica_in_bytes": 250000000,
+							"all_in_bytes": 1150000000,
+							"coordinating_rejections": 12,
+							"primary_rejections": 3,
+							"replica_rejections": 0
+						},
+						"limit_in_bytes": 100000000
+					}
+				}
+			}
+		}
+	}`
+
+	var gotPath string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		fmt.Fprintln(w, stats)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewNodes(http.DefaultClient, u, true, "_local", false, []string{"indexing_pressure"})
+	labels := `cluster="es7",es_client_node="false",es_data_node="false",es_ingest_node="false",es_master_node="false",host="10.0.0.1",name="es01"`
+	want := strings.NewReplacer("LABELS", labels).Replace(`# HELP elasticsearch_indexing_pressure_coordinating_rejections_total Number of indexing requests rejected in the coordinating stage
+		# TYPE elasticsearch_indexing_pressure_coordinating_rejections_total counter
+		elasticsearch_indexing_pressure_coordinating_rejections_total{LABELS} 12
+		# HELP elasticsearch_indexing_pressure_current_combined_coordinating_and_primary_bytes Memory consumed by the indexing requests in the coordinating or primary stage, limited by indexing_pressure.memory.limit
+		# TYPE elasticsearch_indexing_pressure_current_combined_coordinating_and_primary_bytes gauge
+		elasticsearch_indexing_pressure_current_combined_coordinating_and_primary_bytes{LABELS} 2.5e+07
+		# HELP elasticsearch_indexing_pressure_limit_bytes Memory limit of the indexing requests in the coordinating or primary stage, the replica stage is limited to 1.5 times the limit
+		# TYPE elasticsearch_indexing_pressure_limit_bytes gauge
+		elasticsearch_indexing_pressure_limit_bytes{LABELS} 1e+08
+		# HELP elasticsearch_indexing_pressure_limit_percent Percent of the memory limit consumed by the indexing requests in the coordinating or primary stage, the requests are rejected at 100
+		# TYPE elasticsearch_indexing_pressure_limit_percent gauge
+		elasticsearch_indexing_pressure_limit_percent{LABELS} 25
+		# HELP elasticsearch_indexing_pressure_primary_rejections_total Number of indexing requests rejected in the primary stage
+		# TYPE elasticsearch_indexing_pressure_primary_rejections_total counter
+		elasticsearch_indexing_pressure_primary_rejections_total{LABELS} 3
+		# HELP elasticsearch_indexing_pressure_replica_bytes_total Memory consumed by the indexing requests in the replica stage since the node start
+		# TYPE elasticsearch_indexing_pressure_replica_bytes_total counter
+		elasticsearch_indexing_pressure_replica_bytes_total{LABELS} 2.5e+08
+	`)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"elasticsearch_indexing_pressure_coordinating_rejections_total",
+		"elasticsearch_indexing_pressure_current_combined_coordinating_and_primary_bytes",
+		"elasticsearch_indexing_pressure_limit_bytes",
+		"elasticsearch_indexing_pressure_limit_percent",
+		"elasticsearch_indexing_pressure_primary_rejections_total",
+		"elasticsearch_indexing_pressure_replica_bytes_total",
+	); err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/_nodes/stats/indexing_pressure" {
+		t.Errorf("unexpected node stats path %s", gotPath)
+	}
+}
+
+func TestNodesIndexingPressureUnsupported(t *testing.T) {
+	// 7.8 rejects the unrecognized metric, then the nodes have no indexing_pressure section
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if strings.Contains(r.URL.Path, "indexing_pressure") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, `{"error":{"type":"illegal_argument_exception","reason":"request [/_nodes/stats/jvm,indexing_pressure] contains unrecognized metric: [indexing_pressure]"},"status":400}`)
+			return
+		}
+		fmt.Fprintln(w, `{"cluster_name": "es7", "nodes": {"n1": {"name": "es01", "host": "10.0.0.1", "roles": [], "jvm": {}}}}`)
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewNodes(http.DefaultClient, u, true, "_local", false, []string{"jvm", "indexing_pressure"})
+	want := `# HELP elasticsearch_node_stats_up Was the last scrape of the Elasticsearch nodes endpoint successful.
+		# TYPE elasticsearch_node_stats_up gauge
+		elasticsearch_node_stats_up 1
+	`
+	for i := 0; i < 2; i++ {
+		if err := testutil.CollectAndCompare(c, strings.NewReader(want), "elasticsearch_node_stats_up"); err != nil {
+			t.Fatal(err)
+		}
+		if n := testutil.CollectAndCount(c, "elasticsearch_indexing_pressure_limit_bytes"); n != 0 {
+			t.Fatalf("expected no indexing pressure metrics, got %d", n)
+		}
+	}
+	if paths[0] != "/_nodes/stats/jvm,indexing_pressure" || paths[len(paths)-1] != "/_nodes/stats/jvm" {
+		t.Errorf("unexpected node stats paths %v", paths)
+	}
+	for _, p := range paths[1:] {
+		if strings.Contains(p, "indexing_pressure") {
+			t.Errorf("expected indexing_pressure to be requested once, got %v", paths)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nodeLatencySample is the part of the node stats the latencies are derived from
+type nodeLatencySample struct {
+	queryTotal, queryTime int64
+	fetchTotal, fetchTime int64
+	indexTotal, indexTime int64
+}
+
+func newNodeLatencySample(node NodeStatsNodeResponse) nodeLatencySample {
+	return nodeLatencySample{
+		queryTotal: node.Indices.Search.QueryTotal,
+		queryTime:  node.Indices.Search.QueryTime,
+		fetchTotal: node.Indices.Search.FetchTotal,
+		fetchTime:  node.Indices.Search.FetchTime,
+		indexTotal: node.Indices.Indexing.IndexTotal,
+		indexTime:  node.Indices.Indexing.IndexTime,
+	}
+}
+
+// reset reports whether a counter decreased since prev, e.g. after the node restarted
+func (s nodeLatencySample) reset(prev nodeLatencySample) bool {
+	return s.queryTotal < prev.queryTotal || s.queryTime < prev.queryTime ||
+		s.fetchTotal < prev.fetchTotal || s.fetchTime < prev.fetchTime ||
+		s.indexTotal < prev.indexTotal || s.indexTime < prev.indexTime
+}
+
+// NodeLatency derives the average latencies of the nodes over the interval between two
+// gathers from the deltas of the time and the count counters of the node stats. It is kept
+// across gathers, the first gather of a node and the gathers after a counter reset only
+// record the counters, and a latency is not reported over an interval without operations.
+type NodeLatency struct {
+	mu   sync.Mutex
+	last map[string]nodeLatencySample
+
+	searchQueryLatency *prometheus.Desc
+	searchFetchLatency *prometheus.Desc
+	searchFetchRatio   *prometheus.Desc
+	indexingLatency    *prometheus.Desc
+}
+
+func NewNodeLatency() *NodeLatency {
+	return &NodeLatency{
+		last: make(map[string]nodeLatencySample),
+		searchQueryLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "indices", "search_query_latency_seconds"),
+			"Average query phase latency of the searches since the previous gather",
+			defaultNodeLabels, nil,
+		),
+		searchFetchLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "indices", "search_fetch_latency_seconds"),
+			"Average fetch phase latency of the searches since the previous gather",
+			defaultNodeLabels, nil,
+		),
+		searchFetchRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "indices", "search_fetch_time_ratio"),
+			"Part of the search time spent in the fetch phase since the previous gather",
+			defaultNodeLabels, nil,
+		),
+		indexingLatency: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "indices", "indexing_latency_seconds"),
+			"Average indexing latency of the documents since the previous gather",
+			defaultNodeLabels, nil,
+		),
+	}
+}
+
+// Describe add metrics descriptions
+func (l *NodeLatency) Describe(ch chan<- *prometheus.Desc) {
+	ch <- l.searchQueryLatency
+	ch <- l.searchFetchLatency
+	ch <- l.searchFetchRatio
+	ch <- l.indexingLatency
+}
+
+// collect reports the latencies of the nodes since the previous node stats and keeps the
+// counters of the nodes, the nodes which left the cluster are forgotten
+func (l *NodeLatency) collect(ch chan<- prometheus.Metric, cluster string, nodes map[string]NodeStatsNodeResponse) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	last := make(map[string]nodeLatencySample, len(nodes))
+	for id, node := range nodes {
+		cur := newNodeLatencySample(node)
+		last[id] = cur
+		prev, ok := l.last[id]
+		if !ok || cur.reset(prev) {
+			continue
+		}
+
+		labels := defaultNodeLabelValues(cluster, node)
+		if n := cur.queryTotal - prev.queryTotal; n > 0 {
+			ch <- prometheus.MustNewConstMetric(l.searchQueryLatency, prometheus.GaugeValue,
+				float64(cur.queryTime-prev.queryTime)/float64(n)/1000, labels...)
+		}
+		if n := cur.fetchTotal - prev.fetchTotal; n > 0 {
+			ch <- prometheus.MustNewConstMetric(l.searchFetchLatency, prometheus.GaugeValue,
+				float64(cur.fetchTime-prev.fetchTime)/float64(n)/1000, labels...)
+		}
+		fetchTime := cur.fetchTime - prev.fetchTime
+		if total := cur.queryTime - prev.queryTime + fetchTime; total > 0 {
+			ch <- prometheus.MustNewConstMetric(l.searchFetchRatio, prometheus.GaugeValue,
+				float64(fetchTime)/float64(total), labels...)
+		}
+		if n := cur.indexTotal - prev.indexTotal; n > 0 {
+			ch <- prometheus.MustNewConstMetric(l.indexingLatency, prometheus.GaugeValue,
+				float64(cur.indexTime-prev.indexTime)/float64(n)/1000, labels...)
+		}
+	}
+	l.last = last
+}
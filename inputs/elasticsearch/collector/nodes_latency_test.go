@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNodeLatency(t *testing.T) {
+	// query_total, query_time, fetch_total, fetch_time, index_total, index_time of the gathers,
+	// the third one after a restart of the node
+	gathers := [][6]int{
+		{100, 1000, 100, 200, 1000, 5000},
+		{150, 1500, 150, 400, 1200, 5400},
+		{10, 50, 10, 10, 20, 40},
+		{20, 150, 20, 30, 20, 40},
+	}
+	var current [6]int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"cluster_name": "es7", "nodes": {"n1": {"name": "es01", "host": "10.0.0.1", "roles": [],
+			"indices": {"search": {"query_total": %d, "query_time_in_millis": %d, "fetch_total": %d, "fetch_time_in_millis": %d},
+			"indexing": {"index_total": %d, "index_time_in_millis": %d}}}}}`,
+			current[0], current[1], current[2], current[3], current[4], current[5])
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{
+		"elasticsearch_indices_search_query_latency_seconds",
+		"elasticsearch_indices_search_fetch_latency_seconds",
+		"elasticsearch_indices_search_fetch_time_ratio",
+		"elasticsearch_indices_indexing_latency_seconds",
+	}
+	labels := `cluster="es7",es_client_node="false",es_data_node="false",es_ingest_node="false",es_master_node="false",host="10.0.0.1",name="es01"`
+	wants := []string{
+		"",
+		strings.NewReplacer("LABELS", labels).Replace(`# HELP elasticsearch_indices_indexing_latency_seconds Average indexing latency of the documents since the previous gather
+			# TYPE elasticsearch_indices_indexing_latency_seconds gauge
+			elasticsearch_indices_indexing_latency_seconds{LABELS} 0.002
+			# HELP elasticsearch_indices_search_fetch_latency_seconds Average fetch phase latency of the searches since the previous gather
+			# TYPE elasticsearch_indices_search_fetch_latency_seconds gauge
+			elasticsearch_indices_search_fetch_latency_seconds{LABELS} 0.004
+			# HELP elasticsearch_indices_search_fetch_time_ratio Part of the search time spent in the fetch phase since the previous gather
+			# TYPE elasticsearch_indices_search_fetch_time_ratio gauge
+			elasticsearch_indices_search_fetch_time_ratio{LABELS} 0.2857142857142857
+			# HELP elasticsearch_indices_search_query_latency_seconds Average query phase latency of the searches since the previous gather
+			# TYPE elasticsearch_indices_search_query_latency_seconds gauge
+			elasticsearch_indices_search_query_latency_seconds{LABELS} 0.01
+		`),
+		"",
+		// no document indexed over the interval
+		strings.NewReplacer("LABELS", labels).Replace(`# HELP elasticsearch_indices_search_fetch_latency_seconds Average fetch phase latency of the searches since the previous gather
+			# TYPE elasticsearch_indices_search_fetch_latency_seconds gauge
+			elasticsearch_indices_search_fetch_latency_seconds{LABELS} 0.002
+			# HELP elasticsearch_indices_search_fetch_time_ratio Part of the search time spent in the fetch phase since the previous gather
+			# TYPE elasticsearch_indices_search_fetch_time_ratio gauge
+			elasticsearch_indices_search_fetch_time_ratio{LABELS} 0.16666666666666666
+			# HELP elasticsearch_indices_search_query_latency_seconds Average query phase latency of the searches since the previous gather
+			# TYPE elasticsearch_indices_search_query_latency_seconds gauge
+			elasticsearch_indices_search_query_latency_seconds{LABELS} 0.01
+		`),
+	}
+
+	latency := NewNodeLatency()
+	for i, g := range gathers {
+		current = g
+		c := NewNodes(http.DefaultClient, u, true, "_local", false, []string{"indices"}).WithLatency(latency)
+		if err := testutil.CollectAndCompare(c, strings.NewReader(wants[i]), names...); err != nil {
+			t.Fatalf("gather %d: %v", i, err)
+		}
+	}
+}
@@ -39,6 +39,7 @@ type NodeStatsNodeResponse struct {
 	ThreadPool       map[string]NodeStatsThreadPoolPoolResponse `json:"thread_pool"`
 	JVM              NodeStatsJVMResponse                       `json:"jvm"`
 	Breakers         map[string]NodeStatsBreakersResponse       `json:"breakers"`
+	IndexingPressure *NodeStatsIndexingPressureResponse         `json:"indexing_pressure"`
 	HTTP             map[string]interface{}                     `json:"http"`
 	Transport        NodeStatsTransportResponse                 `json:"transport"`
 	Process          NodeStatsProcessResponse                   `json:"process"`
@@ -52,6 +53,31 @@ type NodeStatsBreakersResponse struct {
 	Tripped       int64   `json:"tripped"`
 }
 
+// NodeStatsIndexingPressureResponse is a representation of the indexing pressure stats, ES 7.9+
+type NodeStatsIndexingPressureResponse struct {
+	Memory NodeStatsIndexingPressureMemoryResponse `json:"memory"`
+}
+
+// NodeStatsIndexingPressureMemoryResponse defines the memory consumed by the indexing requests per stage
+type NodeStatsIndexingPressureMemoryResponse struct {
+	Current NodeStatsIndexingPressureStagesResponse `json:"current"`
+	Total   NodeStatsIndexingPressureStagesResponse `json:"total"`
+	Limit   int64                                   `json:"limit_in_bytes"`
+}
+
+// NodeStatsIndexingPressureStagesResponse defines the memory and the rejections of the indexing stages,
+// the rejections are only reported in the totals
+type NodeStatsIndexingPressureStagesResponse struct {
+	CombinedCoordinatingAndPrimary int64 `json:"combined_coordinating_and_primary_in_bytes"`
+	Coordinating                   int64 `json:"coordinating_in_bytes"`
+	Primary                        int64 `json:"primary_in_bytes"`
+	Replica                        int64 `json:"replica_in_bytes"`
+	All                            int64 `json:"all_in_bytes"`
+	CoordinatingRejections         int64 `json:"coordinating_rejections"`
+	PrimaryRejections              int64 `json:"primary_rejections"`
+	ReplicaRejections              int64 `json:"replica_rejections"`
+}
+
 // NodeStatsJVMResponse is a representation of a JVM stats, memory pool information, garbage collection, buffer pools, number of loaded/unloaded classes
 type NodeStatsJVMResponse struct {
 	BufferPools map[string]NodeStatsJVMBufferPoolResponse `json:"buffer_pools"`
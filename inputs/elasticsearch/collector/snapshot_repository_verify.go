@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/pkg/filter"
+)
+
+// SnapshotRepositoryVerify verifies the snapshot repositories with POST /_snapshot/<repo>/_verify.
+// _verify writes to the repository, so it is kept apart from the read-only Snapshots collector
+// and only runs once per interval, the results are cached and exported on every collection
+type SnapshotRepositoryVerify struct {
+	client *http.Client
+	url    *url.URL
+	// repositories is the static list of repositories, /_snapshot is not called when set
+	repositories []string
+	// repositoryFilter filters the repositories listed by /_snapshot
+	repositoryFilter *filter.Matcher
+	interval         time.Duration
+	timeout          time.Duration
+
+	mu         sync.Mutex
+	lastVerify time.Time
+	results    map[string]repositoryVerification
+
+	successDesc  *prometheus.Desc
+	durationDesc *prometheus.Desc
+}
+
+type repositoryVerification struct {
+	success  bool
+	duration time.Duration
+}
+
+// NewSnapshotRepositoryVerify defines snapshot repository verification Prometheus metrics
+func NewSnapshotRepositoryVerify(client *http.Client, url *url.URL, repositories []string, interval, timeout time.Duration) *SnapshotRepositoryVerify {
+	return &SnapshotRepositoryVerify{
+		client:       client,
+		url:          url,
+		repositories: repositories,
+		interval:     interval,
+		timeout:      timeout,
+		results:      make(map[string]repositoryVerification),
+
+		successDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "snapshot_repository", "verification_success"),
+			"Whether the last verification of the snapshot repository succeeded",
+			defaultSnapshotRepositoryLabels, nil,
+		),
+		durationDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "snapshot_repository", "verification_duration_seconds"),
+			"Duration of the last verification of the snapshot repository",
+			defaultSnapshotRepositoryLabels, nil,
+		),
+	}
+}
+
+// WithRepositoryFilter only keeps the repositories listed by /_snapshot matching m
+func (s *SnapshotRepositoryVerify) WithRepositoryFilter(m *filter.Matcher) *SnapshotRepositoryVerify {
+	s.repositoryFilter = m
+	return s
+}
+
+// Describe adds snapshot repository verification metrics descriptions
+func (s *SnapshotRepositoryVerify) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.successDesc
+	ch <- s.durationDesc
+}
+
+func (s *SnapshotRepositoryVerify) fetchRepositories() ([]string, error) {
+	if len(s.repositories) > 0 {
+		return s.repositories, nil
+	}
+
+	u := *s.url
+	u.Path = path.Join(u.Path, "/_snapshot")
+
+	res, err := s.client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get from %s://%s:%s%s: %s",
+			u.Scheme, u.Hostname(), u.Port(), u.Path, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+
+	bts, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos map[string]json.RawMessage
+	if err := json.Unmarshal(bts, &repos); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		if !s.repositoryFilter.Empty() && !s.repositoryFilter.Match(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *SnapshotRepositoryVerify) verify(repo string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	u := *s.url
+	u.Path = path.Join(u.Path, "/_snapshot", repo, "_verify")
+	q := u.Query()
+	q.Set("timeout", fmt.Sprintf("%ds", int(s.timeout.Seconds())))
+	q.Set("master_timeout", fmt.Sprintf("%ds", int(s.timeout.Seconds())))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (s *SnapshotRepositoryVerify) verifyAll() {
+	repos, err := s.fetchRepositories()
+	if err != nil {
+		log.Println("failed to fetch snapshot repositories, err: ", err)
+		return
+	}
+
+	results := make(map[string]repositoryVerification, len(repos))
+	for _, repo := range repos {
+		start := time.Now()
+		err := s.verify(repo)
+		if err != nil {
+			log.Println("failed to verify snapshot repository", repo, "err: ", err)
+		}
+		results[repo] = repositoryVerification{
+			success:  err == nil,
+			duration: time.Since(start),
+		}
+	}
+	s.results = results
+	s.lastVerify = time.Now()
+}
+
+// Collect verifies the repositories when the interval has passed and exports the last results
+func (s *SnapshotRepositoryVerify) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastVerify) >= s.interval {
+		s.verifyAll()
+	}
+
+	for repo, r := range s.results {
+		success := 0.0
+		if r.success {
+			success = 1
+		}
+		ch <- prometheus.MustNewConstMetric(s.successDesc, prometheus.GaugeValue, success, repo)
+		ch <- prometheus.MustNewConstMetric(s.durationDesc, prometheus.GaugeValue, r.duration.Seconds(), repo)
+	}
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSnapshotRepositoryVerify(t *testing.T) {
+	var verifies atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/_snapshot" && r.Method == http.MethodGet:
+			fmt.Fprintln(w, `{"backup":{"type":"fs","settings":{"location":"/tmp/backup"}},"s3-expired":{"type":"s3","settings":{"bucket":"b"}}}`)
+		case r.URL.Path == "/_snapshot/backup/_verify" && r.Method == http.MethodPost:
+			verifies.Add(1)
+			fmt.Fprintln(w, `{"nodes":{"n1":{"name":"es01"}}}`)
+		case r.URL.Path == "/_snapshot/s3-expired/_verify" && r.Method == http.MethodPost:
+			verifies.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintln(w, `{"error":{"type":"repository_verification_exception"},"status":500}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewSnapshotRepositoryVerify(http.DefaultClient, u, nil, time.Hour, 5*time.Second)
+	want := `# HELP elasticsearch_snapshot_repository_verification_success Whether the last verification of the snapshot repository succeeded
+		# TYPE elasticsearch_snapshot_repository_verification_success gauge
+		elasticsearch_snapshot_repository_verification_success{repository="backup"} 1
+		elasticsearch_snapshot_repository_verification_success{repository="s3-expired"} 0
+	`
+	for i := 0; i < 2; i++ {
+		if err := testutil.CollectAndCompare(c, strings.NewReader(want), "elasticsearch_snapshot_repository_verification_success"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if verifies.Load() != 2 {
+		t.Fatalf("expected the repositories to be verified once per interval, got %d verify calls", verifies.Load())
+	}
+}
@@ -15,6 +15,7 @@ package collector
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -23,6 +24,8 @@ import (
 	"path"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/pkg/filter"
 )
 
 type snapshotMetric struct {
@@ -50,20 +53,36 @@ var (
 	}
 )
 
+// errSnapshotForbidden is returned when the user is not allowed to read the repository
+var errSnapshotForbidden = errors.New("HTTP Request failed with code 403")
+
 // Snapshots information struct
 type Snapshots struct {
 	client *http.Client
 	url    *url.URL
+	// repositories is the static list of repositories, /_snapshot is not called when set
+	repositories []string
+	// repositoryFilter filters the repositories listed by /_snapshot
+	repositoryFilter *filter.Matcher
 
-	snapshotMetrics   []*snapshotMetric
-	repositoryMetrics []*repositoryMetric
+	permissionErrorDesc *prometheus.Desc
+	snapshotMetrics     []*snapshotMetric
+	repositoryMetrics   []*repositoryMetric
 }
 
-// NewSnapshots defines Snapshots Prometheus metrics
-func NewSnapshots(client *http.Client, url *url.URL) *Snapshots {
+// NewSnapshots defines Snapshots Prometheus metrics, the repositories are discovered with
+// /_snapshot unless a static list of repositories is given
+func NewSnapshots(client *http.Client, url *url.URL, repositories []string) *Snapshots {
 	return &Snapshots{
-		client: client,
-		url:    url,
+		client:       client,
+		url:          url,
+		repositories: repositories,
+
+		permissionErrorDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "snapshot_stats", "repository_permission_error"),
+			"Whether the snapshots of the repository of snapshot_repositories were denied (403)",
+			defaultSnapshotRepositoryLabels, nil,
+		),
 
 		snapshotMetrics: []*snapshotMetric{
 			{
@@ -201,8 +220,15 @@ func NewSnapshots(client *http.Client, url *url.URL) *Snapshots {
 	}
 }
 
+// WithRepositoryFilter only keeps the repositories listed by /_snapshot matching m
+func (s *Snapshots) WithRepositoryFilter(m *filter.Matcher) *Snapshots {
+	s.repositoryFilter = m
+	return s
+}
+
 // Describe add Snapshots metrics descriptions
 func (s *Snapshots) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.permissionErrorDesc
 	for _, metric := range s.snapshotMetrics {
 		ch <- metric.Desc
 	}
@@ -226,6 +252,9 @@ func (s *Snapshots) getAndParseURL(u *url.URL, data interface{}) error {
 		}
 	}()
 
+	if res.StatusCode == http.StatusForbidden {
+		return errSnapshotForbidden
+	}
 	if res.StatusCode != http.StatusOK {
 		return fmt.Errorf("HTTP Request failed with code %d", res.StatusCode)
 	}
@@ -241,40 +270,70 @@ func (s *Snapshots) getAndParseURL(u *url.URL, data interface{}) error {
 	return nil
 }
 
-func (s *Snapshots) fetchAndDecodeSnapshotsStats() (map[string]SnapshotStatsResponse, error) {
+// fetchAndDecodeSnapshotsStats returns the snapshots of the repositories and, with the
+// static list of repositories, the repositories denied
+func (s *Snapshots) fetchAndDecodeSnapshotsStats() (map[string]SnapshotStatsResponse, map[string]bool, error) {
 	mssr := make(map[string]SnapshotStatsResponse)
 
-	u := *s.url
-	u.Path = path.Join(u.Path, "/_snapshot")
-	var srr SnapshotRepositoriesResponse
-	err := s.getAndParseURL(&u, &srr)
-	if err != nil {
-		return nil, err
+	repositories := s.repositories
+	if len(repositories) == 0 {
+		u := *s.url
+		u.Path = path.Join(u.Path, "/_snapshot")
+		var srr SnapshotRepositoriesResponse
+		err := s.getAndParseURL(&u, &srr)
+		if err != nil {
+			return nil, nil, err
+		}
+		for repository := range srr {
+			if !s.repositoryFilter.Empty() && !s.repositoryFilter.Match(repository) {
+				continue
+			}
+			repositories = append(repositories, repository)
+		}
+	}
+
+	var forbidden map[string]bool
+	if len(s.repositories) > 0 {
+		forbidden = make(map[string]bool, len(s.repositories))
 	}
-	for repository := range srr {
+	for _, repository := range repositories {
 		u := *s.url
 		u.Path = path.Join(u.Path, "/_snapshot", repository, "/_all")
 		var ssr SnapshotStatsResponse
 		err := s.getAndParseURL(&u, &ssr)
+		if forbidden != nil {
+			forbidden[repository] = err == errSnapshotForbidden
+		}
 		if err != nil {
+			if forbidden != nil {
+				log.Println("failed to fetch snapshots of repository", repository, "err: ", err)
+			}
 			continue
 		}
 		mssr[repository] = ssr
 	}
 
-	return mssr, nil
+	return mssr, forbidden, nil
 }
 
 // Collect gets Snapshots metric values
 func (s *Snapshots) Collect(ch chan<- prometheus.Metric) {
 
 	// indices
-	snapshotsStatsResp, err := s.fetchAndDecodeSnapshotsStats()
+	snapshotsStatsResp, forbidden, err := s.fetchAndDecodeSnapshotsStats()
 	if err != nil {
 		log.Println("failed to fetch and decode snapshot stats, err: ", err)
 		return
 	}
 
+	for repositoryName, denied := range forbidden {
+		v := 0.0
+		if denied {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(s.permissionErrorDesc, prometheus.GaugeValue, v, repositoryName)
+	}
+
 	// Snapshots stats
 	for repositoryName, snapshotStats := range snapshotsStatsResp {
 		for _, metric := range s.repositoryMetrics {
@@ -24,6 +24,8 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"flashcat.cloud/categraf/pkg/filter"
 )
 
 func TestSnapshots(t *testing.T) {
@@ -208,7 +210,7 @@ func TestSnapshots(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			s := NewSnapshots(http.DefaultClient, u)
+			s := NewSnapshots(http.DefaultClient, u, nil)
 
 			// TODO: Convert to collector interface
 			// c, err := NewSnapshots(log.NewNopLogger(), u, http.DefaultClient)
@@ -222,3 +224,82 @@ func TestSnapshots(t *testing.T) {
 		})
 	}
 }
+
+func TestSnapshotsStaticRepositories(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/_snapshot/backup/_all":
+			fmt.Fprint(w, `{"snapshots":[{"snapshot":"s1","version":"8.11.0","indices":["logs"],"state":"SUCCESS","start_time_in_millis":1700000000000,"end_time_in_millis":1700000060000,"failures":[],"shards":{"total":1,"failed":0,"successful":1}}]}`)
+		case "/_snapshot/restricted/_all":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"type":"security_exception"},"status":403}`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSnapshots(http.DefaultClient, u, []string{"backup", "restricted"})
+	want := `# HELP elasticsearch_snapshot_stats_number_of_snapshots Number of snapshots in a repository
+		# TYPE elasticsearch_snapshot_stats_number_of_snapshots gauge
+		elasticsearch_snapshot_stats_number_of_snapshots{repository="backup"} 1
+		# HELP elasticsearch_snapshot_stats_repository_permission_error Whether the snapshots of the repository of snapshot_repositories were denied (403)
+		# TYPE elasticsearch_snapshot_stats_repository_permission_error gauge
+		elasticsearch_snapshot_stats_repository_permission_error{repository="backup"} 0
+		elasticsearch_snapshot_stats_repository_permission_error{repository="restricted"} 1
+	`
+	if err := testutil.CollectAndCompare(s, strings.NewReader(want),
+		"elasticsearch_snapshot_stats_number_of_snapshots",
+		"elasticsearch_snapshot_stats_repository_permission_error",
+	); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range paths {
+		if p == "/_snapshot" {
+			t.Fatalf("expected the discovery to be skipped, got %v", paths)
+		}
+	}
+}
+
+func TestSnapshotsRepositoryFilter(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/_snapshot":
+			fmt.Fprint(w, `{"backup-daily":{"type":"fs"},"backup-weekly":{"type":"fs"},"scratch":{"type":"fs"}}`)
+		case "/_snapshot/backup-daily/_all", "/_snapshot/backup-weekly/_all":
+			fmt.Fprint(w, `{"snapshots":[]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSnapshots(http.DefaultClient, u, nil).WithRepositoryFilter(filter.MustNewMatcher([]string{"backup-*"}))
+	want := `# HELP elasticsearch_snapshot_stats_number_of_snapshots Number of snapshots in a repository
+		# TYPE elasticsearch_snapshot_stats_number_of_snapshots gauge
+		elasticsearch_snapshot_stats_number_of_snapshots{repository="backup-daily"} 0
+		elasticsearch_snapshot_stats_number_of_snapshots{repository="backup-weekly"} 0
+	`
+	if err := testutil.CollectAndCompare(s, strings.NewReader(want), "elasticsearch_snapshot_stats_number_of_snapshots"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range paths {
+		if p == "/_snapshot/scratch/_all" {
+			t.Fatalf("expected the filtered out repository not to be queried, got %v", paths)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/inputs/elasticsearch/collector"
+	"flashcat.cloud/categraf/types"
+)
+
+var _ inputs.Describer = new(Instance)
+
+// collectorEndpoints lists the collectors in the order of gatherServer with the endpoints they
+// call, the endpoints of the collectors which ran are the requests they sent instead
+var collectorEndpoints = []struct {
+	name      string
+	endpoints []string
+}{
+	{"nodes", []string{"GET /_nodes/stats"}},
+	{"hot_threads", []string{"GET /_nodes/hot_threads"}},
+	{"cluster_health", []string{"GET /_cluster/health"}},
+	{"cluster_health_indices", []string{"GET /_cluster/health?level=indices"}},
+	{"cluster_stats", []string{"GET /_cluster/stats"}},
+	{"shards", []string{"GET /_cat/shards"}},
+	{"indices", []string{"GET /_all/_stats", "GET /_alias"}},
+	{"slm", []string{"GET /_slm/stats", "GET /_slm/status"}},
+	{"cluster_tasks", []string{"GET /_cluster/pending_tasks", "GET /_tasks"}},
+	{"alias_rollover", []string{"GET /_alias/{alias}", "GET /{index}/_stats/docs,store", "GET /{index}/_settings", "GET /_ilm/policy/{policy}"}},
+	{"index_age", []string{"GET /_cat/indices/{pattern}"}},
+	{"index_blocks", []string{"GET /{pattern}/_settings", "GET /_cluster/settings"}},
+	{"index_aliases", []string{"GET /_alias"}},
+	{"data_stream", []string{"GET /_data_stream/*/_stats"}},
+	{"indices_settings", []string{"GET /_all/_settings"}},
+	{"indices_mappings", []string{"GET /_all/_mappings"}},
+	{"snapshots", []string{"GET /_snapshot", "GET /_snapshot/{repository}/_all"}},
+	{"snapshot_repository_verify", []string{"GET /_snapshot", "POST /_snapshot/{repository}/_verify"}},
+	{"ilm_status", []string{"GET /_ilm/status"}},
+	{"ilm_indices", []string{"GET /_all/_ilm/explain"}},
+	{"cluster_settings", []string{"GET /_cluster/settings"}},
+}
+
+type serverDescription struct {
+	Server       string                 `json:"server"`
+	Version      string                 `json:"version,omitempty"`
+	Distribution string                 `json:"distribution,omitempty"`
+	BuildFlavor  string                 `json:"build_flavor,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	Collectors   []collectorDescription `json:"collectors,omitempty"`
+}
+
+type collectorDescription struct {
+	Name      string   `json:"name"`
+	Enabled   bool     `json:"enabled"`
+	Endpoints []string `json:"endpoints"`
+	// measured on a single gather, only for the enabled collectors
+	DurationSeconds *float64 `json:"duration_seconds,omitempty"`
+	ResponseBytes   *int64   `json:"response_bytes,omitempty"`
+	Success         *bool    `json:"success,omitempty"`
+	Overran         bool     `json:"overran,omitempty"`
+}
+
+// Describe gathers every server once and reports the version of the server and the collectors,
+// enabled or not, with the endpoints they call and the duration and the size of the responses
+// of the enabled ones. The collectors run concurrently until gather_timeout as in a gather.
+func (ins *Instance) Describe() (interface{}, error) {
+	ins.recordScrapes = true
+	defer func() { ins.recordScrapes = false }()
+
+	servers := ins.Servers
+	if ins.Failover {
+		s, ok := ins.backends.pick(ins.probe)
+		if !ok {
+			return nil, fmt.Errorf("none of the elasticsearch servers is reachable: %s", redactServers(ins.Servers))
+		}
+		servers = []string{s}
+	}
+
+	slist := types.NewSampleList()
+	if ins.ClusterStats || len(ins.IndicesInclude) > 0 || ins.ElectedMasterOnly {
+		ins.serverInfo = make(map[string]serverInfo)
+		for _, s := range servers {
+			ins.gatherServerInfo(s, slist)
+		}
+	}
+
+	descriptions := make([]serverDescription, 0, len(servers))
+	for _, s := range servers {
+		d := serverDescription{Server: redactURL(s)}
+		info, err := ins.serverVersion(s)
+		if err != nil {
+			d.Error = err.Error()
+			descriptions = append(descriptions, d)
+			continue
+		}
+		d.Version = info.Number.String()
+		d.Distribution = info.Distribution
+		if d.Distribution == "" {
+			d.Distribution = "elasticsearch"
+		}
+		d.BuildFlavor = info.BuildFlavor
+
+		g := ins.gatherServer(s, slist, time.Now().Add(time.Duration(ins.GatherTimeout)))
+		d.Collectors = describeCollectors(g)
+		descriptions = append(descriptions, d)
+	}
+	return descriptions, nil
+}
+
+func describeCollectors(g *scrapeGroup) []collectorDescription {
+	scrapes := make(map[string]*collectorScrape)
+	if g != nil {
+		for _, s := range g.scrapes {
+			scrapes[s.name] = s
+		}
+	}
+
+	descriptions := make([]collectorDescription, 0, len(collectorEndpoints))
+	for _, c := range collectorEndpoints {
+		d := collectorDescription{Name: c.name, Endpoints: c.endpoints}
+		s, ok := scrapes[c.name]
+		if !ok {
+			descriptions = append(descriptions, d)
+			continue
+		}
+		d.Enabled = true
+		select {
+		case <-s.done:
+		default:
+			d.Overran = true
+			descriptions = append(descriptions, d)
+			continue
+		}
+		s.mu.Lock()
+		if requests := uniqueStrings(s.requests); len(requests) > 0 {
+			// e.g. the cached collectors send no request until their interval elapsed
+			d.Endpoints = requests
+		}
+		s.mu.Unlock()
+		duration := s.duration.Seconds()
+		size := s.respBytes.Load()
+		success := !s.failed.Load()
+		d.DurationSeconds, d.ResponseBytes, d.Success = &duration, &size, &success
+		descriptions = append(descriptions, d)
+	}
+	return descriptions
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		unique = append(unique, v)
+	}
+	return unique
+}
+
+// serverVersion returns the version of the server from the / endpoint
+func (ins *Instance) serverVersion(s string) (*collector.VersionInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, s, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ins.UserName != "" && ins.Password != "" {
+		req.SetBasicAuth(ins.UserName, ins.Password)
+	}
+	res, err := ins.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the version of %s: %v", redactURL(s), err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the version of %s: %v", redactURL(s), err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status code %d: %s", redactURL(s), res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var info collector.ClusterInfoResponse
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode the version of %s: %v", redactURL(s), err)
+	}
+	return &info.Version, nil
+}
@@ -0,0 +1,69 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestDescribe(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintln(w, `{"cluster_name":"es","version":{"number":"2.11.0","distribution":"opensearch"}}`)
+		case "/_cluster/health":
+			fmt.Fprintln(w, `{"cluster_name":"es","status":"green","number_of_nodes":1}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	ins := &Instance{
+		Servers:            []string{ts.URL},
+		ClusterHealth:      true,
+		DisableIndexBlocks: true,
+		GatherTimeout:      config.Duration(5 * time.Second),
+	}
+	if err := ins.Init(); err != nil {
+		t.Fatal(err)
+	}
+	v, err := ins.Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := v.([]serverDescription)
+	if len(servers) != 1 {
+		t.Fatalf("expected 1 server, got %d", len(servers))
+	}
+	s := servers[0]
+	if s.Version != "2.11.0" || s.Distribution != "opensearch" {
+		t.Errorf("unexpected version %s of distribution %s", s.Version, s.Distribution)
+	}
+	if len(s.Collectors) != len(collectorEndpoints) {
+		t.Fatalf("expected %d collectors, got %d", len(collectorEndpoints), len(s.Collectors))
+	}
+	for _, c := range s.Collectors {
+		switch c.Name {
+		case "cluster_health":
+			if !c.Enabled || c.Success == nil || !*c.Success || c.ResponseBytes == nil || *c.ResponseBytes == 0 {
+				t.Errorf("cluster_health should be enabled and measured: %+v", c)
+			}
+			if len(c.Endpoints) != 1 || c.Endpoints[0] != "GET /_cluster/health" {
+				t.Errorf("unexpected endpoints of cluster_health: %v", c.Endpoints)
+			}
+		case "nodes":
+			if !c.Enabled || c.DurationSeconds == nil {
+				t.Errorf("nodes should be enabled and measured: %+v", c)
+			}
+		case "index_blocks", "cluster_stats":
+			if c.Enabled || c.DurationSeconds != nil {
+				t.Errorf("%s should be disabled: %+v", c.Name, c)
+			}
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +23,7 @@ import (
 	"flashcat.cloud/categraf/pkg/tls"
 	"flashcat.cloud/categraf/types"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/version"
 )
 
@@ -40,12 +43,19 @@ type (
 	Instance struct {
 		config.InstanceConfig
 
-		Local                 bool            `toml:"local"`
-		Servers               []string        `toml:"servers"`
-		UserName              string          `toml:"username"`
-		Password              string          `toml:"password"`
-		ApiKey                string          `toml:"api_key"`
-		HTTPTimeout           config.Duration `toml:"http_timeout"`
+		Local             bool            `toml:"local"`
+		Servers           []string        `toml:"servers"`
+		Failover          bool            `toml:"failover"`
+		ElectedMasterOnly bool            `toml:"elected_master_only"`
+		UserName          string          `toml:"username"`
+		Password          string          `toml:"password"`
+		ApiKey            string          `toml:"api_key"`
+		HTTPTimeout       config.Duration `toml:"http_timeout"`
+		GatherTimeout     config.Duration `toml:"gather_timeout"`
+		// retries of the GET requests failing with 429, 502, 503, 504 or a network error within
+		// the gather, negative disables them
+		MaxRetries            int             `toml:"max_retries"`
+		RetryBackoff          config.Duration `toml:"retry_backoff"`
 		AllNodes              bool            `toml:"all_nodes"`
 		Node                  string          `toml:"node"`
 		NodeStats             []string        `toml:"node_stats"`
@@ -57,17 +67,47 @@ type (
 		ExportIndicesSettings bool            `toml:"export_indices_settings"`
 		ExportIndicesMappings bool            `toml:"export_indices_mappings"`
 		ExportIndexAliases    bool            `toml:"export_index_aliases"`
+		GatherAliases         bool            `toml:"gather_aliases"`
 		ExportILM             bool            `toml:"export_ilm"`
 		ExportShards          bool            `toml:"export_shards"`
 		ExportSLM             bool            `toml:"export_slm"`
 		ExportDataStream      bool            `toml:"export_data_stream"`
 		ExportSnapshots       bool            `toml:"export_snapshots"`
+		// queried instead of the repositories listed by /_snapshot, which needs cluster:admin/repository/get on all repositories,
+		// with globs or /regexps/ the repositories listed by /_snapshot are filtered instead
+		SnapshotRepositories  []string        `toml:"snapshot_repositories"`
+		VerifyRepositories    bool            `toml:"verify_repositories"`
+		VerifyInterval        config.Duration `toml:"verify_interval"`
+		VerifyTimeout         config.Duration `toml:"verify_timeout"`
 		ExportClusterSettings bool            `toml:"export_cluster_settings"`
 		ExportClusterInfo     bool            `toml:"export_cluster_info"`
 		ClusterInfoInterval   config.Duration `toml:"cluster_info_interval"`
+		IncludeClusterLabel   bool            `toml:"include_cluster_label"`
+		RolloverAliases       []string        `toml:"rollover_aliases"`
+		IndexAgePatterns      []string        `toml:"index_age_patterns"`
+		IndexAgeThreshold     string          `toml:"index_age_threshold"`
+		DisableIndexBlocks    bool            `toml:"disable_index_blocks"`
+		IndexBlocksPatterns   []string        `toml:"index_blocks_patterns"`
+		ExportClusterTasks    bool            `toml:"export_cluster_tasks"`
+		TaskActions           []string        `toml:"task_actions"`
+		ClusterInfoCacheTTL   config.Duration `toml:"cluster_info_cache_ttl"`
 		AwsRegion             string          `toml:"aws_region"`
 		AwsRoleArn            string          `toml:"aws_role_arn"`
 
+		// derives the average search and indexing latencies of the nodes over the interval
+		// from the indices node stats
+		ExportNodeLatency bool `toml:"export_node_latency"`
+
+		// the cluster wide totals of the capacity and cost dashboards, one /_cluster/stats and one
+		// filtered /_nodes/stats per interval, so they are collected unless disabled
+		DisableClusterRollups bool `toml:"disable_cluster_rollups"`
+		// the node attribute holding the tier of the nodes, e.g. box_type, the data roles otherwise
+		RollupTierAttribute string `toml:"rollup_tier_attribute"`
+
+		// samples /_nodes/hot_threads once per interval, 0 disables it
+		GatherHotThreadsInterval config.Duration `toml:"gather_hot_threads_interval"`
+		HotThreadsCount          int             `toml:"hot_threads_count"`
+
 		EsURL *url.URL
 		*http.Client
 		tls.ClientConfig
@@ -75,6 +115,21 @@ type (
 		serverInfo      map[string]serverInfo
 		hasRunBefore    bool
 		serverInfoMutex sync.Mutex
+
+		clusterInfoCaches   map[string]*collector.ClusterInfoCache
+		backends            *backendPool
+		repositoryVerifiers map[string]*collector.SnapshotRepositoryVerify
+		hotThreads          map[string]*collector.HotThreads
+		nodeLatency         map[string]*collector.NodeLatency
+		ingestRollups       map[string]*collector.IngestRollup
+		indexAgeThreshold   time.Duration
+		aliasIndexFilter    filter.Filter
+		deprecationWarnings *collector.DeprecationWarnings
+		// the static snapshot repositories, or the filter of the discovered ones
+		snapshotRepositories     []string
+		snapshotRepositoryFilter *filter.Matcher
+		// records the requests of the collectors, see Describe
+		recordScrapes bool
 	}
 
 	transportWithAPIKey struct {
@@ -114,12 +169,40 @@ func (ins *Instance) Init() error {
 	if len(ins.Servers) == 0 {
 		return types.ErrInstancesEmpty
 	}
+	if ins.ElectedMasterOnly {
+		if ins.Failover {
+			return fmt.Errorf("elected_master_only and failover are mutually exclusive")
+		}
+		// the nodes collectors of the agents of the cluster must not overlap
+		ins.Local = true
+	}
 	if ins.HTTPTimeout <= 0 {
 		ins.HTTPTimeout = config.Duration(5 * time.Second)
 	}
+	if ins.GatherTimeout <= 0 {
+		ins.GatherTimeout = config.Duration(config.GetInterval())
+	}
+	if ins.MaxRetries == 0 {
+		ins.MaxRetries = 2
+	}
+	if ins.RetryBackoff <= 0 {
+		ins.RetryBackoff = config.Duration(500 * time.Millisecond)
+	}
 	if ins.ClusterInfoInterval == 0 {
 		ins.ClusterInfoInterval = config.Duration(5 * time.Minute)
 	}
+	if ins.VerifyInterval <= 0 {
+		ins.VerifyInterval = config.Duration(time.Hour)
+	}
+	if ins.VerifyTimeout <= 0 {
+		ins.VerifyTimeout = config.Duration(10 * time.Second)
+	}
+	if ins.ClusterInfoCacheTTL <= 0 {
+		ins.ClusterInfoCacheTTL = config.Duration(time.Hour)
+	}
+	if ins.HotThreadsCount <= 0 {
+		ins.HotThreadsCount = 5
+	}
 	if ins.UserName == "" {
 		ins.UserName = os.Getenv("ES_USERNAME")
 	}
@@ -129,7 +212,37 @@ func (ins *Instance) Init() error {
 	if ins.ApiKey == "" {
 		ins.ApiKey = os.Getenv("ES_API_KEY")
 	}
+	if ins.IndexAgeThreshold == "" {
+		ins.IndexAgeThreshold = "30d"
+	}
+	if slices.ContainsFunc(ins.SnapshotRepositories, filter.IsPattern) {
+		m, err := filter.NewMatcher(ins.SnapshotRepositories)
+		if err != nil {
+			return fmt.Errorf("failed to compile snapshot_repositories: %v", err)
+		}
+		ins.snapshotRepositoryFilter = m
+	} else {
+		ins.snapshotRepositories = ins.SnapshotRepositories
+	}
+	if ins.ExportSnapshots || ins.VerifyRepositories {
+		if len(ins.snapshotRepositories) > 0 {
+			log.Println("I! elasticsearch: snapshot repositories", ins.SnapshotRepositories, "from snapshot_repositories, /_snapshot discovery disabled")
+		} else if ins.snapshotRepositoryFilter != nil {
+			log.Println("I! elasticsearch: snapshot repositories discovered with /_snapshot and filtered by", ins.SnapshotRepositories)
+		} else {
+			log.Println("I! elasticsearch: snapshot repositories discovered with /_snapshot")
+		}
+	}
 	ins.hasRunBefore = false
+	ins.clusterInfoCaches = make(map[string]*collector.ClusterInfoCache)
+	ins.backends = newBackendPool(ins.Servers)
+	ins.repositoryVerifiers = make(map[string]*collector.SnapshotRepositoryVerify)
+	ins.hotThreads = make(map[string]*collector.HotThreads)
+	ins.nodeLatency = make(map[string]*collector.NodeLatency)
+	ins.ingestRollups = make(map[string]*collector.IngestRollup)
+	if ins.ExportNodeLatency && !slices.Contains(ins.NodeStats, "indices") {
+		log.Println("W! elasticsearch: export_node_latency requires indices in node_stats, the latencies are not reported")
+	}
 
 	// Compile the configured indexes to match for sorting.
 	indexMatchers, err := ins.compileIndexMatchers()
@@ -138,6 +251,16 @@ func (ins *Instance) Init() error {
 	}
 	ins.indexMatchers = indexMatchers
 
+	ins.aliasIndexFilter, err = ins.compileAliasIndexFilter()
+	if err != nil {
+		return err
+	}
+
+	ins.indexAgeThreshold, err = collector.TimeValueToDuration(ins.IndexAgeThreshold)
+	if err != nil {
+		return fmt.Errorf("invalid index_age_threshold %s: %v", ins.IndexAgeThreshold, err)
+	}
+
 	ins.Client, err = ins.createHTTPClient()
 	if err != nil {
 		return err
@@ -147,11 +270,25 @@ func (ins *Instance) Init() error {
 }
 
 func (ins *Instance) Gather(slist *types.SampleList) {
+	deadline := time.Now().Add(time.Duration(ins.GatherTimeout))
 	// version metric
 	if err := inputs.Collect(version.NewCollector(inputName), slist); err != nil {
 		log.Println("E! failed to collect version metric:", err)
 	}
-	if ins.ClusterStats || len(ins.IndicesInclude) > 0 {
+	defer func() {
+		if ins.deprecationWarnings == nil {
+			return
+		}
+		if err := inputs.Collect(ins.deprecationWarnings, slist); err != nil {
+			log.Println("E! failed to collect deprecation warning metrics:", err)
+		}
+	}()
+	if ins.Failover {
+		ins.gatherFailover(slist, deadline)
+		return
+	}
+
+	if ins.ClusterStats || len(ins.IndicesInclude) > 0 || ins.ElectedMasterOnly {
 		var wgC sync.WaitGroup
 		wgC.Add(len(ins.Servers))
 
@@ -159,28 +296,7 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		for _, serv := range ins.Servers {
 			go func(s string, slist *types.SampleList) {
 				defer wgC.Done()
-				info := serverInfo{}
-				var err error
-
-				// Gather node ID
-				if info.nodeID, err = collector.GetNodeID(ins.Client, ins.UserName, ins.Password, s); err != nil {
-					slist.PushSample("elasticsearch", "up", 0, map[string]string{"address": s})
-					log.Println("E! failed to gather node id:", err)
-					return
-				}
-
-				// get cat/master information here so NodeStats can determine
-				// whether this node is the Master
-				if info.masterID, err = collector.GetCatMaster(ins.Client, ins.UserName, ins.Password, s); err != nil {
-					slist.PushSample("elasticsearch", "up", 0, map[string]string{"address": s})
-					log.Println("E! failed to get cat master:", err)
-					return
-				}
-
-				slist.PushSample("elasticsearch", "up", 1, map[string]string{"address": s})
-				ins.serverInfoMutex.Lock()
-				ins.serverInfo[s] = info
-				ins.serverInfoMutex.Unlock()
+				ins.gatherServerInfo(s, slist)
 			}(serv, slist)
 		}
 		wgC.Wait()
@@ -193,147 +309,350 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	for _, serv := range ins.Servers {
 		go func(s string, slist *types.SampleList) {
 			defer wg.Done()
-			EsUrl, err := url.Parse(s)
-			if err != nil {
-				log.Println("failed to parse es_uri, err: ", err)
-				return
-			}
-			if ins.UserName != "" && ins.Password != "" {
-				EsUrl.User = url.UserPassword(ins.UserName, ins.Password)
-			}
-			exporter, err := collector.NewElasticsearchCollector(
-				[]string{},
-				collector.WithElasticsearchURL(EsUrl),
-				collector.WithHTTPClient(ins.Client),
-			)
-			if err != nil {
-				log.Println("E! failed to create Elasticsearch collector, err: ", err)
-				return
-			}
-			if err := inputs.Collect(exporter, slist); err != nil {
-				log.Println("E! failed to collect metrics:", err)
-			}
+			ins.gatherServer(s, slist, deadline)
+		}(serv, slist)
+	}
 
-			// Always gather node stats
-			if err := inputs.Collect(collector.NewNodes(ins.Client, EsUrl, ins.AllNodes, ins.Node, ins.Local, ins.NodeStats), slist); err != nil {
-				log.Println("E! failed to collect nodes metrics:", err)
-			}
+	wg.Wait()
+}
 
-			clusterInfoRetriever := clusterinfo.New(ins.Client, EsUrl, time.Duration(ins.ClusterInfoInterval))
-
-			if ins.ClusterHealth {
-				if ins.ClusterHealthLevel == "indices" {
-					if err := inputs.Collect(collector.NewClusterHealthIndices(ins.Client, EsUrl), slist); err != nil {
-						log.Println("E! failed to collect cluster health indices metrics:", err)
-					}
-				} else {
-					if err := inputs.Collect(collector.NewClusterHealth(ins.Client, EsUrl), slist); err != nil {
-						log.Println("E! failed to collect cluster health metrics:", err)
-					}
-				}
-			}
+// gatherFailover queries only one backend per interval, the servers are tried in order
+// starting from the last healthy one so cluster level metrics are never duplicated
+func (ins *Instance) gatherFailover(slist *types.SampleList, deadline time.Time) {
+	s, ok := ins.backends.pick(ins.probe)
+	for _, serv := range ins.Servers {
+		v := 0
+		if ok && serv == s {
+			v = 1
+		}
+		slist.PushSample("elasticsearch", "scrape_backend", v, map[string]string{"url": redactURL(serv)})
+	}
+	if !ok {
+		slist.PushSample("elasticsearch", "up", 0, map[string]string{"address": redactURL(ins.Servers[0])})
+		log.Println("E! none of the elasticsearch servers is reachable:", redactServers(ins.Servers))
+		return
+	}
 
-			if ins.ClusterStats && (ins.serverInfo[s].isMaster() || !ins.Local) {
-				if err := inputs.Collect(collector.NewClusterStats(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect cluster stats metrics:", err)
-				}
-			}
+	if ins.ClusterStats || len(ins.IndicesInclude) > 0 {
+		ins.serverInfo = make(map[string]serverInfo)
+		ins.gatherServerInfo(s, slist)
+	}
+	ins.gatherServer(s, slist, deadline)
+}
 
-			if (ins.ExportIndices || ins.ExportShards) && (ins.serverInfo[s].isMaster() || !ins.Local) {
-				sC := collector.NewShards(ins.Client, EsUrl)
-				if err := inputs.Collect(sC, slist); err != nil {
-					log.Println("E! failed to collect shards metrics:", err)
-				}
-				iC := collector.NewIndices(ins.Client, EsUrl, ins.ExportShards, ins.ExportIndexAliases, ins.IndicesInclude)
-				if err := inputs.Collect(iC, slist); err != nil {
-					log.Println("E! failed to collect indices metrics:", err)
-				}
-				if registerErr := clusterInfoRetriever.RegisterConsumer(iC); registerErr != nil {
-					log.Println("failed to register indices collector in cluster info")
-				}
-				if registerErr := clusterInfoRetriever.RegisterConsumer(sC); registerErr != nil {
-					log.Println("failed to register shards collector in cluster info")
-				}
-			}
+// gatherServerInfo gets the node id and the master node id of the server
+// so the collectors can determine whether this node is the master
+func (ins *Instance) gatherServerInfo(s string, slist *types.SampleList) {
+	info := serverInfo{}
+	var err error
 
-			if ins.ExportSLM {
-				if err := inputs.Collect(collector.NewSLM(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect SLM metrics:", err)
-				}
-			}
+	// Gather node ID
+	if info.nodeID, err = collector.GetNodeID(ins.Client, ins.UserName, ins.Password, s); err != nil {
+		slist.PushSample("elasticsearch", "up", 0, map[string]string{"address": s})
+		log.Println("E! failed to gather node id:", err)
+		return
+	}
 
-			if ins.ExportDataStream {
-				if err := inputs.Collect(collector.NewDataStream(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect data stream metrics:", err)
-				}
-			}
+	// get cat/master information here so NodeStats can determine
+	// whether this node is the Master
+	if info.masterID, err = collector.GetCatMaster(ins.Client, ins.UserName, ins.Password, s); err != nil {
+		slist.PushSample("elasticsearch", "up", 0, map[string]string{"address": s})
+		log.Println("E! failed to get cat master:", err)
+		return
+	}
 
-			if ins.ExportIndicesSettings {
-				if err := inputs.Collect(collector.NewIndicesSettings(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect indices settings metrics:", err)
-				}
-			}
+	slist.PushSample("elasticsearch", "up", 1, map[string]string{"address": s})
+	ins.serverInfoMutex.Lock()
+	ins.serverInfo[s] = info
+	ins.serverInfoMutex.Unlock()
+}
 
-			if ins.ExportIndicesMappings {
-				if err := inputs.Collect(collector.NewIndicesMappings(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect indices mappings metrics:", err)
-				}
-			}
+// gatherServer returns the scrape group of the collectors of the server, nil if none started
+func (ins *Instance) gatherServer(s string, slist *types.SampleList, deadline time.Time) *scrapeGroup {
+	EsUrl, err := url.Parse(s)
+	if err != nil {
+		log.Println("failed to parse es_uri, err: ", err)
+		return nil
+	}
+	if ins.UserName != "" && ins.Password != "" {
+		EsUrl.User = url.UserPassword(ins.UserName, ins.Password)
+	}
 
-			if ins.ExportSnapshots {
-				if err := inputs.Collect(collector.NewSnapshots(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect snapshot metrics:", err)
-				}
-			}
+	// the / endpoint is requested once per cluster_info_cache_ttl for elasticsearch_version
+	// and the cluster label
+	clusterInfoCache := ins.getClusterInfoCache(s, EsUrl)
+	constLabels := map[string]string{}
+	if ins.IncludeClusterLabel {
+		if name := clusterInfoCache.ClusterName(); name != "" {
+			constLabels["cluster"] = name
+		}
+	}
 
-			if ins.ExportILM {
-				if err := inputs.Collect(collector.NewIlmStatus(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect ilm status metrics:", err)
-				}
-				if err := inputs.Collect(collector.NewIlmIndicies(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect ilm indices metrics:", err)
-				}
-			}
+	exporter, err := collector.NewElasticsearchCollector(
+		[]string{},
+		collector.WithElasticsearchURL(EsUrl),
+		collector.WithHTTPClient(ins.Client),
+		collector.WithClusterInfo(clusterInfoCache.Get),
+	)
+	if err != nil {
+		log.Println("E! failed to create Elasticsearch collector, err: ", err)
+		return nil
+	}
+	if err := inputs.Collect(exporter, slist, constLabels); err != nil {
+		log.Println("E! failed to collect metrics:", err)
+	}
 
-			if ins.ExportClusterSettings {
-				if err := inputs.Collect(collector.NewClusterSettings(ins.Client, EsUrl), slist); err != nil {
-					log.Println("E! failed to collect cluster settings metrics:", err)
-				}
-			}
+	// the master is checked on every gather, so the cluster level collectors move to the
+	// agent of the new master on failover
+	owner := ins.ownsClusterMetrics(s)
+	if ins.ElectedMasterOnly {
+		v := 0
+		if owner {
+			v = 1
+		}
+		slist.PushSample("elasticsearch", "cluster_metrics_owner", v, map[string]string{"address": redactURL(s)})
+	}
 
-			if ins.ExportClusterInfo && !ins.hasRunBefore {
-				// Create a context that is cancelled on SIGKILL or SIGINT.
-				ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
-
-				// start the cluster info retriever
-				switch runErr := clusterInfoRetriever.Run(ctx); {
-				case runErr == nil:
-					if ins.DebugMod {
-						log.Println("started cluster info retriever, interval: ", ins.ClusterInfoInterval)
-					}
-				case errors.Is(runErr, clusterinfo.ErrInitialCallTimeout):
-					if ins.DebugMod {
-						log.Println("initial cluster info call timed out")
-					}
-				default:
-					log.Println("failed to run cluster info retriever, err: ", err)
-					return
-				}
-
-				// register cluster info retriever as prometheus collector
-				if err := inputs.Collect(clusterInfoRetriever, slist); err != nil {
-					log.Println("E! failed to collect cluster info metrics:", err)
-				}
-				ins.serverInfoMutex.Lock()
-				ins.hasRunBefore = true
-				ins.serverInfoMutex.Unlock()
+	// the collectors run concurrently, each one until the deadline of the gather
+	g := ins.newScrapeGroup(constLabels, deadline)
+
+	// Always gather node stats
+	g.collect("nodes", func(client *http.Client) prometheus.Collector {
+		c := collector.NewNodes(client, EsUrl, ins.AllNodes, ins.Node, ins.Local, ins.NodeStats)
+		if ins.ExportNodeLatency {
+			c.WithLatency(ins.getNodeLatency(s))
+		}
+		return c
+	})
+
+	if ins.GatherHotThreadsInterval > 0 {
+		// the sampler is cached across gathers with the client of the instance, so only collect errors fail its scrape
+		g.collectCached("hot_threads", ins.getHotThreads(s, EsUrl))
+	}
+
+	clusterInfoRetriever := clusterinfo.New(ins.Client, EsUrl, time.Duration(ins.ClusterInfoInterval))
+
+	if ins.ClusterHealth && owner {
+		if ins.ClusterHealthLevel == "indices" {
+			g.collect("cluster_health_indices", func(client *http.Client) prometheus.Collector {
+				return collector.NewClusterHealthIndices(client, EsUrl)
+			})
+		} else {
+			g.collect("cluster_health", func(client *http.Client) prometheus.Collector {
+				return collector.NewClusterHealth(client, EsUrl)
+			})
+		}
+	}
+
+	if ins.ClusterStats && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		g.collect("cluster_stats", func(client *http.Client) prometheus.Collector {
+			return collector.NewClusterStats(client, EsUrl)
+		})
+	}
+
+	if !ins.DisableClusterRollups && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		g.collect("cluster_rollups", func(client *http.Client) prometheus.Collector {
+			return collector.NewClusterRollups(client, EsUrl, ins.RollupTierAttribute, ins.getIngestRollup(s))
+		})
+	}
+
+	if (ins.ExportIndices || ins.ExportShards) && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		var sC *collector.Shards
+		g.collect("shards", func(client *http.Client) prometheus.Collector {
+			sC = collector.NewShards(client, EsUrl)
+			return sC
+		})
+		var iC *collector.Indices
+		g.collect("indices", func(client *http.Client) prometheus.Collector {
+			iC = collector.NewIndices(client, EsUrl, ins.ExportShards, ins.ExportIndexAliases, ins.IndicesInclude)
+			return iC
+		})
+		if registerErr := clusterInfoRetriever.RegisterConsumer(iC); registerErr != nil {
+			log.Println("failed to register indices collector in cluster info")
+		}
+		if registerErr := clusterInfoRetriever.RegisterConsumer(sC); registerErr != nil {
+			log.Println("failed to register shards collector in cluster info")
+		}
+	}
+
+	if ins.ExportSLM && owner {
+		g.collect("slm", func(client *http.Client) prometheus.Collector {
+			return collector.NewSLM(client, EsUrl)
+		})
+	}
+
+	if ins.ExportClusterTasks && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		g.collect("cluster_tasks", func(client *http.Client) prometheus.Collector {
+			return collector.NewClusterTasks(client, EsUrl, ins.TaskActions)
+		})
+	}
+
+	if len(ins.RolloverAliases) > 0 && owner {
+		g.collect("alias_rollover", func(client *http.Client) prometheus.Collector {
+			return collector.NewAliasRollover(client, EsUrl, ins.RolloverAliases)
+		})
+	}
+
+	if len(ins.IndexAgePatterns) > 0 && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		g.collect("index_age", func(client *http.Client) prometheus.Collector {
+			return collector.NewIndexAge(client, EsUrl, ins.IndexAgePatterns, ins.indexAgeThreshold)
+		})
+	}
+
+	// only aggregated counts are exported, so the blocks are checked unless disabled
+	if !ins.DisableIndexBlocks && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		g.collect("index_blocks", func(client *http.Client) prometheus.Collector {
+			return collector.NewIndexBlocks(client, EsUrl, ins.IndexBlocksPatterns)
+		})
+	}
+
+	if ins.GatherAliases && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		g.collect("index_aliases", func(client *http.Client) prometheus.Collector {
+			return collector.NewIndexAliases(client, EsUrl, ins.aliasIndexFilter)
+		})
+	}
+
+	if ins.ExportDataStream && owner {
+		g.collect("data_stream", func(client *http.Client) prometheus.Collector {
+			return collector.NewDataStream(client, EsUrl)
+		})
+	}
+
+	if ins.ExportIndicesSettings && owner {
+		g.collect("indices_settings", func(client *http.Client) prometheus.Collector {
+			return collector.NewIndicesSettings(client, EsUrl)
+		})
+	}
+
+	if ins.ExportIndicesMappings && owner {
+		g.collect("indices_mappings", func(client *http.Client) prometheus.Collector {
+			return collector.NewIndicesMappings(client, EsUrl)
+		})
+	}
+
+	if ins.ExportSnapshots && owner {
+		g.collect("snapshots", func(client *http.Client) prometheus.Collector {
+			return collector.NewSnapshots(client, EsUrl, ins.snapshotRepositories).WithRepositoryFilter(ins.snapshotRepositoryFilter)
+		})
+	}
+
+	// _verify writes to the repositories, it is opt-in and separate from export_snapshots
+	if ins.VerifyRepositories && (ins.serverInfo[s].isMaster() || !ins.Local) && owner {
+		// the verifier is cached across gathers with the client of the instance, so only collect errors fail its scrape
+		g.collectCached("snapshot_repository_verify", ins.getRepositoryVerify(s, EsUrl))
+	}
+
+	if ins.ExportILM && owner {
+		g.collect("ilm_status", func(client *http.Client) prometheus.Collector {
+			return collector.NewIlmStatus(client, EsUrl)
+		})
+		g.collect("ilm_indices", func(client *http.Client) prometheus.Collector {
+			return collector.NewIlmIndicies(client, EsUrl)
+		})
+	}
+
+	if ins.ExportClusterSettings && owner {
+		g.collect("cluster_settings", func(client *http.Client) prometheus.Collector {
+			return collector.NewClusterSettings(client, EsUrl)
+		})
+	}
+
+	g.wait(slist)
+
+	if ins.ExportClusterInfo && !ins.hasRunBefore {
+		// Create a context that is cancelled on SIGKILL or SIGINT.
+		ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+
+		// start the cluster info retriever
+		switch runErr := clusterInfoRetriever.Run(ctx); {
+		case runErr == nil:
+			if ins.DebugMod {
+				log.Println("started cluster info retriever, interval: ", ins.ClusterInfoInterval)
+			}
+		case errors.Is(runErr, clusterinfo.ErrInitialCallTimeout):
+			if ins.DebugMod {
+				log.Println("initial cluster info call timed out")
 			}
+		default:
+			log.Println("failed to run cluster info retriever, err: ", err)
+			return g
+		}
 
-		}(serv, slist)
+		// register cluster info retriever as prometheus collector
+		if err := inputs.Collect(clusterInfoRetriever, slist, constLabels); err != nil {
+			log.Println("E! failed to collect cluster info metrics:", err)
+		}
+		ins.serverInfoMutex.Lock()
+		ins.hasRunBefore = true
+		ins.serverInfoMutex.Unlock()
 	}
+	return g
+}
 
-	wg.Wait()
-	return
+// getClusterInfoCache returns the cluster info cache of the server, the cache is
+// kept across gathers so the cluster label survives failures of the / endpoint
+func (ins *Instance) getClusterInfoCache(server string, u *url.URL) *collector.ClusterInfoCache {
+	ins.serverInfoMutex.Lock()
+	defer ins.serverInfoMutex.Unlock()
+	c, ok := ins.clusterInfoCaches[server]
+	if !ok {
+		c = collector.NewClusterInfoCache(ins.Client, u, time.Duration(ins.ClusterInfoCacheTTL))
+		ins.clusterInfoCaches[server] = c
+	}
+	return c
+}
+
+// getRepositoryVerify returns the repository verifier of the server, the verifier is
+// kept across gathers so the repositories are only verified once per verify_interval
+func (ins *Instance) getRepositoryVerify(server string, u *url.URL) *collector.SnapshotRepositoryVerify {
+	ins.serverInfoMutex.Lock()
+	defer ins.serverInfoMutex.Unlock()
+	c, ok := ins.repositoryVerifiers[server]
+	if !ok {
+		c = collector.NewSnapshotRepositoryVerify(ins.Client, u, ins.snapshotRepositories, time.Duration(ins.VerifyInterval), time.Duration(ins.VerifyTimeout)).
+			WithRepositoryFilter(ins.snapshotRepositoryFilter)
+		ins.repositoryVerifiers[server] = c
+	}
+	return c
+}
+
+// getHotThreads returns the hot threads sampler of the server, the sampler is kept
+// across gathers so the hot threads are only sampled once per gather_hot_threads_interval
+func (ins *Instance) getHotThreads(server string, u *url.URL) *collector.HotThreads {
+	ins.serverInfoMutex.Lock()
+	defer ins.serverInfoMutex.Unlock()
+	c, ok := ins.hotThreads[server]
+	if !ok {
+		c = collector.NewHotThreads(ins.Client, u, ins.AllNodes, ins.Node, ins.Local, ins.HotThreadsCount, time.Duration(ins.GatherHotThreadsInterval))
+		ins.hotThreads[server] = c
+	}
+	return c
+}
+
+// getNodeLatency returns the latencies of the nodes of the server, kept across gathers since
+// they are derived from the node stats of the previous gather
+func (ins *Instance) getNodeLatency(server string) *collector.NodeLatency {
+	ins.serverInfoMutex.Lock()
+	defer ins.serverInfoMutex.Unlock()
+	l, ok := ins.nodeLatency[server]
+	if !ok {
+		l = collector.NewNodeLatency()
+		ins.nodeLatency[server] = l
+	}
+	return l
+}
+
+// getIngestRollup returns the ingested bytes of the cluster of the server, kept across gathers
+// since they are accumulated from the node stats of the previous gathers
+func (ins *Instance) getIngestRollup(server string) *collector.IngestRollup {
+	ins.serverInfoMutex.Lock()
+	defer ins.serverInfoMutex.Unlock()
+	r, ok := ins.ingestRollups[server]
+	if !ok {
+		r = collector.NewIngestRollup()
+		ins.ingestRollups[server] = r
+	}
+	return r
 }
 
 func (ins *Instance) createHTTPClient() (*http.Client, error) {
@@ -362,9 +681,11 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 		}
 	}
 
+	// counts the deprecation warnings of every response, whichever collector sent the request
+	ins.deprecationWarnings = collector.NewDeprecationWarnings(httpTransport)
 	client := &http.Client{
 		Timeout:   time.Duration(ins.HTTPTimeout),
-		Transport: httpTransport,
+		Transport: ins.deprecationWarnings,
 	}
 	if ins.AwsRegion != "" {
 		ins.Client.Transport, err = roundtripper.NewAWSSigningTransport(httpTransport, ins.AwsRegion, ins.AwsRoleArn)
@@ -376,6 +697,29 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 	return client, nil
 }
 
+// compileAliasIndexFilter turns indices_include into the index filter of gather_aliases,
+// entries prefixed with - are exclusions as in elasticsearch index expressions
+func (ins *Instance) compileAliasIndexFilter() (filter.Filter, error) {
+	var include, exclude []string
+	for _, configuredIndex := range ins.IndicesInclude {
+		for _, expr := range strings.Split(configuredIndex, ",") {
+			expr = strings.TrimSpace(expr)
+			switch {
+			case expr == "" || expr == "_all":
+			case strings.HasPrefix(expr, "-"):
+				exclude = append(exclude, strings.TrimPrefix(expr, "-"))
+			default:
+				include = append(include, expr)
+			}
+		}
+	}
+	f, err := filter.NewIncludeExcludeFilter(include, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid indices_include %v: %v", ins.IndicesInclude, err)
+	}
+	return f, nil
+}
+
 func (ins *Instance) compileIndexMatchers() (map[string]filter.Filter, error) {
 	indexMatchers := map[string]filter.Filter{}
 	var err error
@@ -401,3 +745,15 @@ func (t *transportWithAPIKey) RoundTrip(req *http.Request) (*http.Response, erro
 func (i serverInfo) isMaster() bool {
 	return i.nodeID == i.masterID
 }
+
+// ownsClusterMetrics reports whether the cluster level collectors run for the server s, with
+// elected_master_only only the agent of the elected master collects them
+func (ins *Instance) ownsClusterMetrics(s string) bool {
+	if !ins.ElectedMasterOnly {
+		return true
+	}
+	ins.serverInfoMutex.Lock()
+	defer ins.serverInfoMutex.Unlock()
+	info, ok := ins.serverInfo[s]
+	return ok && info.isMaster()
+}
@@ -0,0 +1,69 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestElectedMasterOnly(t *testing.T) {
+	master := "node1"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_nodes/_local/name":
+			fmt.Fprintln(w, `{"nodes":{"node1":{"name":"es1"}}}`)
+		case "/_cat/master":
+			fmt.Fprintf(w, "%s 127.0.0.1 127.0.0.1 es\n", master)
+		case "/_cluster/health":
+			fmt.Fprintln(w, `{"cluster_name":"es","status":"green","number_of_nodes":1}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	ins := &Instance{
+		Servers:            []string{ts.URL},
+		ElectedMasterOnly:  true,
+		ClusterHealth:      true,
+		ClusterHealthLevel: "cluster",
+		GatherTimeout:      config.Duration(5 * time.Second),
+	}
+	if err := ins.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !ins.Local {
+		t.Fatal("expected elected_master_only to imply local")
+	}
+
+	gather := func() (owner float64, clusterHealth bool) {
+		slist := types.NewSampleList()
+		ins.Gather(slist)
+		owner = -1
+		for _, s := range slist.PopBackAll() {
+			switch s.Metric {
+			case "elasticsearch_cluster_metrics_owner":
+				owner, _ = conv.ToFloat64(s.Value)
+			case "elasticsearch_cluster_health_status":
+				clusterHealth = true
+			}
+		}
+		return
+	}
+
+	if owner, clusterHealth := gather(); owner != 1 || !clusterHealth {
+		t.Fatalf("expected the master agent to own the cluster metrics, got owner %v, cluster health %v", owner, clusterHealth)
+	}
+
+	// failover to another node, the responsibility moves on the next gather
+	master = "node2"
+	if owner, clusterHealth := gather(); owner != 0 || clusterHealth {
+		t.Fatalf("expected a non master agent not to own the cluster metrics, got owner %v, cluster health %v", owner, clusterHealth)
+	}
+}
@@ -0,0 +1,79 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// backendPool remembers the last healthy server of an instance with failover enabled
+type backendPool struct {
+	sync.Mutex
+	servers []string
+	current int
+}
+
+func newBackendPool(servers []string) *backendPool {
+	return &backendPool{servers: servers}
+}
+
+// pick returns the first healthy server, starting from the last healthy one
+// and trying the others in the configured order
+func (p *backendPool) pick(probe func(string) error) (string, bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i := 0; i < len(p.servers); i++ {
+		idx := (p.current + i) % len(p.servers)
+		if err := probe(p.servers[idx]); err != nil {
+			continue
+		}
+		p.current = idx
+		return p.servers[idx], true
+	}
+	return "", false
+}
+
+// probe checks whether the server answers the root endpoint
+func (ins *Instance) probe(server string) error {
+	req, err := http.NewRequest(http.MethodGet, server, nil)
+	if err != nil {
+		return err
+	}
+	if ins.UserName != "" && ins.Password != "" {
+		req.SetBasicAuth(ins.UserName, ins.Password)
+	}
+
+	resp, err := ins.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP Request failed with code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// redactURL drops the user info of the server so credentials never end up in labels or logs
+func redactURL(server string) string {
+	u, err := url.Parse(server)
+	if err != nil {
+		return server
+	}
+	u.User = nil
+	return u.String()
+}
+
+func redactServers(servers []string) string {
+	ret := make([]string, len(servers))
+	for i := range servers {
+		ret[i] = redactURL(servers[i])
+	}
+	return strings.Join(ret, ",")
+}
@@ -0,0 +1,92 @@
+package elasticsearch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// retryTransport retries the idempotent requests of a collector failing with a transient
+// error, e.g. the 429 and 503 of an overloaded master, so a single rejected stats call does
+// not leave a gap for the whole interval. The retries wait for the backoff, doubled on every
+// retry or the Retry-After of the response when longer, and are not attempted when the wait
+// would pass the deadline of the gather
+type retryTransport struct {
+	next       http.RoundTripper
+	maxRetries int
+	backoff    time.Duration
+	deadline   time.Time
+	retries    *atomic.Int64
+}
+
+// retriableStatus are the status codes of an overloaded or restarting cluster
+func retriableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.maxRetries <= 0 || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return t.next.RoundTrip(req)
+	}
+
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		res, err := t.next.RoundTrip(req)
+		if attempt >= t.maxRetries {
+			return res, err
+		}
+		wait := backoff
+		switch {
+		case err != nil:
+			// the requests cancelled by the timeout of the client are not retried
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || req.Context().Err() != nil {
+				return res, err
+			}
+		case retriableStatus(res.StatusCode):
+			if after := retryAfter(res); after > wait {
+				wait = after
+			}
+		default:
+			return res, err
+		}
+		if !t.deadline.IsZero() && time.Now().Add(wait).After(t.deadline) {
+			return res, err
+		}
+		if res != nil {
+			// the connection is reused once the body is read
+			io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		t.retries.Add(1)
+		backoff *= 2
+	}
+}
+
+// retryAfter returns the delay of the Retry-After header in seconds, 0 without or with a date
+func retryAfter(res *http.Response) time.Duration {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,221 @@
+package elasticsearch
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/types"
+)
+
+// collectorScrape times one collector and reports whether it succeeded. The collectors log
+// their failures and return, so a failure is seen through the responses of the HTTP client
+// handed to the collector and through the _up gauges the collector emits
+type collectorScrape struct {
+	name    string
+	client  *http.Client
+	failed  atomic.Bool
+	retries atomic.Int64
+
+	begin    time.Time
+	duration time.Duration
+	samples  []*types.Sample
+	done     chan struct{}
+
+	// the requests and the size of the responses, recorded for Describe only
+	record    bool
+	mu        sync.Mutex
+	requests  []string
+	respBytes atomic.Int64
+}
+
+type scrapeTransport struct {
+	next   http.RoundTripper
+	scrape *collectorScrape
+}
+
+func (t *scrapeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	// 404 is left to the collectors, some of them treat a missing resource as empty
+	if err != nil || (res.StatusCode >= 400 && res.StatusCode != http.StatusNotFound) {
+		t.scrape.failed.Store(true)
+	}
+	if t.scrape.record {
+		t.scrape.mu.Lock()
+		t.scrape.requests = append(t.scrape.requests, req.Method+" "+req.URL.Path)
+		t.scrape.mu.Unlock()
+		if err == nil {
+			res.Body = &countingBody{ReadCloser: res.Body, n: &t.scrape.respBytes}
+		}
+	}
+	return res, err
+}
+
+// countingBody counts the bytes of a response read by the collector
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// newScrape returns the scrape of the collector, the collector should be created with its client.
+// A positive timeout shortens the timeout of the client of the instance, the transient failures
+// of the requests are retried until the deadline.
+func (ins *Instance) newScrape(name string, timeout time.Duration, deadline time.Time) *collectorScrape {
+	s := &collectorScrape{name: name, record: ins.recordScrapes}
+
+	next := ins.Client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	client := *ins.Client
+	// the scrape sees the outcome of the last attempt only
+	client.Transport = &scrapeTransport{
+		next: &retryTransport{
+			next:       next,
+			maxRetries: ins.MaxRetries,
+			backoff:    time.Duration(ins.RetryBackoff),
+			deadline:   deadline,
+			retries:    &s.retries,
+		},
+		scrape: s,
+	}
+	if timeout > 0 && (client.Timeout <= 0 || timeout < client.Timeout) {
+		client.Timeout = timeout
+	}
+	s.client = &client
+	return s
+}
+
+// scrapeGroup runs the collectors of one server concurrently until the deadline of the gather,
+// so the metrics of the fast collectors are delivered even when a slow one overruns
+type scrapeGroup struct {
+	ins         *Instance
+	constLabels map[string]string
+	deadline    time.Time
+	scrapes     []*collectorScrape
+}
+
+func (ins *Instance) newScrapeGroup(constLabels map[string]string, deadline time.Time) *scrapeGroup {
+	return &scrapeGroup{ins: ins, constLabels: constLabels, deadline: deadline}
+}
+
+// collect creates the collector with the client of a new scrape and starts collecting it,
+// the requests of the collector time out at the deadline
+func (g *scrapeGroup) collect(name string, newCollector func(client *http.Client) prometheus.Collector) {
+	timeout := time.Until(g.deadline)
+	if timeout <= 0 {
+		timeout = time.Millisecond
+	}
+	s := g.ins.newScrape(name, timeout, g.deadline)
+	g.start(s, newCollector(s.client))
+}
+
+// collectCached starts collecting a collector kept across gathers, which holds the client of the instance
+func (g *scrapeGroup) collectCached(name string, c prometheus.Collector) {
+	g.start(g.ins.newScrape(name, 0, g.deadline), c)
+}
+
+func (g *scrapeGroup) start(s *collectorScrape, c prometheus.Collector) {
+	s.begin = time.Now()
+	s.done = make(chan struct{})
+	g.scrapes = append(g.scrapes, s)
+	go s.run(c, g.constLabels)
+}
+
+// wait pushes the metrics of the collectors done by the deadline along with
+// elasticsearch_collector_scrape_duration_seconds, elasticsearch_collector_scrape_success and
+// elasticsearch_collector_scrape_retries,
+// the metrics of the overrunning collectors are dropped and their scrape fails
+func (g *scrapeGroup) wait(slist *types.SampleList) {
+	ctx, cancel := context.WithDeadline(context.Background(), g.deadline)
+	defer cancel()
+
+	for _, s := range g.scrapes {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			select {
+			case <-s.done:
+			default:
+				log.Printf("W! elasticsearch collector %s overran the gather_timeout, its metrics are dropped", s.name)
+				s.push(slist, g.constLabels, time.Since(s.begin), true)
+				continue
+			}
+		}
+		slist.PushFrontN(s.samples)
+		s.push(slist, g.constLabels, s.duration, s.failed.Load())
+	}
+}
+
+func (s *collectorScrape) run(c prometheus.Collector, constLabels map[string]string) {
+	defer close(s.done)
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("E! elasticsearch collector %s panicked: %v\n%s", s.name, r, debug.Stack())
+			s.failed.Store(true)
+			s.samples = nil
+			s.duration = time.Since(s.begin)
+		}
+	}()
+
+	collected := types.NewSampleList()
+	if err := inputs.Collect(&safeCollector{Collector: c, scrape: s}, collected, constLabels); err != nil {
+		log.Println("E! failed to collect", s.name, "metrics:", err)
+		s.failed.Store(true)
+	}
+	s.duration = time.Since(s.begin)
+
+	samples := collected.PopBackAll()
+	for _, sample := range samples {
+		if !strings.HasSuffix(sample.Metric, "_up") {
+			continue
+		}
+		if v, err := conv.ToFloat64(sample.Value); err == nil && v == 0 {
+			s.failed.Store(true)
+		}
+	}
+	s.samples = samples
+}
+
+func (s *collectorScrape) push(slist *types.SampleList, constLabels map[string]string, duration time.Duration, failed bool) {
+	success := 1
+	if failed {
+		success = 0
+	}
+	labels := map[string]string{"collector": s.name}
+	slist.PushSample(inputName, "collector_scrape_duration_seconds", duration.Seconds(), constLabels, labels)
+	slist.PushSample(inputName, "collector_scrape_success", success, constLabels, labels)
+	slist.PushSample(inputName, "collector_scrape_retries", s.retries.Load(), constLabels, labels)
+}
+
+// safeCollector recovers the panics of Collect, which runs in the goroutine of inputs.Collect
+type safeCollector struct {
+	prometheus.Collector
+	scrape *collectorScrape
+}
+
+func (c *safeCollector) Collect(ch chan<- prometheus.Metric) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("E! elasticsearch collector %s panicked: %v\n%s", c.scrape.name, r, debug.Stack())
+			c.scrape.failed.Store(true)
+		}
+	}()
+	c.Collector.Collect(ch)
+}
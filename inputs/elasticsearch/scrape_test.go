@@ -0,0 +1,227 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs/elasticsearch/collector"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestCollectorScrape(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_cluster/health":
+			fmt.Fprintln(w, `{"cluster_name":"es","status":"green","number_of_nodes":1}`)
+		case "/_cluster/settings":
+			fmt.Fprintln(w, `{"persistent":{},"transient":{}}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := &Instance{}
+	ins.Client = http.DefaultClient
+
+	cases := []struct {
+		name         string
+		newCollector func(client *http.Client) prometheus.Collector
+		success      int
+	}{
+		{"cluster_health", func(client *http.Client) prometheus.Collector {
+			return collector.NewClusterHealth(client, u)
+		}, 1},
+		// fails without an _up gauge, seen through the response status
+		{"cluster_stats", func(client *http.Client) prometheus.Collector {
+			return collector.NewClusterStats(client, u)
+		}, 0},
+		// fails with an _up gauge
+		{"slm", func(client *http.Client) prometheus.Collector {
+			return collector.NewSLM(client, u)
+		}, 0},
+	}
+
+	for _, c := range cases {
+		slist := types.NewSampleList()
+		g := ins.newScrapeGroup(map[string]string{"cluster": "es"}, time.Now().Add(5*time.Second))
+		g.collect(c.name, c.newCollector)
+		g.wait(slist)
+
+		var success *types.Sample
+		var duration bool
+		for _, s := range slist.PopBackAll() {
+			if s.Labels["collector"] != c.name {
+				continue
+			}
+			switch s.Metric {
+			case "elasticsearch_collector_scrape_success":
+				success = s
+			case "elasticsearch_collector_scrape_duration_seconds":
+				duration = s.Labels["cluster"] == "es"
+			}
+		}
+		if success == nil || !duration {
+			t.Fatalf("%s: scrape metrics are missing", c.name)
+		}
+		if success.Value != c.success {
+			t.Errorf("%s: expected scrape success %v, got %v", c.name, c.success, success.Value)
+		}
+	}
+}
+
+type panicCollector struct{}
+
+func (panicCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (panicCollector) Collect(chan<- prometheus.Metric) {
+	panic("boom")
+}
+
+func TestScrapeGroupDeadline(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_cluster/health":
+			fmt.Fprintln(w, `{"cluster_name":"es","status":"green","number_of_nodes":1}`)
+		case "/_snapshot":
+			<-release
+			fmt.Fprintln(w, `{}`)
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := &Instance{}
+	ins.Client = &http.Client{Timeout: time.Minute}
+
+	slist := types.NewSampleList()
+	begin := time.Now()
+	g := ins.newScrapeGroup(nil, begin.Add(500*time.Millisecond))
+	g.collect("snapshots", func(client *http.Client) prometheus.Collector {
+		return collector.NewSnapshots(client, u, nil)
+	})
+	g.collect("cluster_health", func(client *http.Client) prometheus.Collector {
+		return collector.NewClusterHealth(client, u)
+	})
+	g.collectCached("panic", panicCollector{})
+	g.wait(slist)
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("the gather waited %s for the slow collector", elapsed)
+	}
+
+	success := map[string]interface{}{}
+	var health bool
+	for _, s := range slist.PopBackAll() {
+		switch {
+		case s.Metric == "elasticsearch_collector_scrape_success":
+			success[s.Labels["collector"]] = s.Value
+		case s.Metric == "elasticsearch_cluster_health_number_of_nodes":
+			health = true
+		}
+	}
+	if !health {
+		t.Error("the metrics of cluster_health are missing")
+	}
+	expected := map[string]interface{}{"cluster_health": 1, "snapshots": 0, "panic": 0}
+	for name, v := range expected {
+		if success[name] != v {
+			t.Errorf("%s: expected scrape success %v, got %v", name, v, success[name])
+		}
+	}
+}
+
+func TestScrapeRetries(t *testing.T) {
+	var health, stats, settings atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/_cluster/health":
+			// the overloaded master rejects the first two calls
+			if health.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintln(w, `{"cluster_name":"es","status":"green","number_of_nodes":1}`)
+		case "/_cluster/stats":
+			// waiting for the Retry-After would pass the deadline
+			stats.Add(1)
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/_cluster/settings":
+			settings.Add(1)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := &Instance{MaxRetries: 2, RetryBackoff: config.Duration(10 * time.Millisecond)}
+	ins.Client = &http.Client{Timeout: time.Minute}
+
+	slist := types.NewSampleList()
+	begin := time.Now()
+	g := ins.newScrapeGroup(nil, begin.Add(2*time.Second))
+	g.collect("cluster_health", func(client *http.Client) prometheus.Collector {
+		return collector.NewClusterHealth(client, u)
+	})
+	g.collect("cluster_stats", func(client *http.Client) prometheus.Collector {
+		return collector.NewClusterStats(client, u)
+	})
+	g.collect("cluster_settings", func(client *http.Client) prometheus.Collector {
+		return collector.NewClusterSettings(client, u)
+	})
+	g.wait(slist)
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Fatalf("the retries stretched the gather to %s", elapsed)
+	}
+
+	success := map[string]interface{}{}
+	retries := map[string]interface{}{}
+	for _, s := range slist.PopBackAll() {
+		switch s.Metric {
+		case "elasticsearch_collector_scrape_success":
+			success[s.Labels["collector"]] = s.Value
+		case "elasticsearch_collector_scrape_retries":
+			retries[s.Labels["collector"]] = s.Value
+		}
+	}
+	expected := []struct {
+		name     string
+		success  interface{}
+		retries  interface{}
+		requests int64
+		got      *atomic.Int64
+	}{
+		{"cluster_health", 1, int64(2), 3, &health},
+		{"cluster_stats", 0, int64(0), 1, &stats},
+		// 4xx but 429 are not retried
+		{"cluster_settings", 0, int64(0), 1, &settings},
+	}
+	for _, e := range expected {
+		if success[e.name] != e.success || retries[e.name] != e.retries {
+			t.Errorf("%s: expected scrape success %v and %v retries, got %v and %v", e.name, e.success, e.retries, success[e.name], retries[e.name])
+		}
+		if n := e.got.Load(); n != e.requests {
+			t.Errorf("%s: expected %d requests, got %d", e.name, e.requests, n)
+		}
+	}
+}
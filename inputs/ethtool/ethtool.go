@@ -0,0 +1,146 @@
+package ethtool
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/filter"
+)
+
+const inputName = "ethtool"
+
+// defaultStatsInclude covers drops, errors and per-queue packets of the common drivers,
+// patterns are matched against both the raw and the normalized stat name
+var defaultStatsInclude = []string{
+	"*drop*",
+	"*discard*",
+	"*err*",
+	"*missed*",
+	"*queue_*packets",
+	"rx[0-9]*_packets",
+	"tx[0-9]*_packets",
+}
+
+var defaultInterfaceExclude = []string{"lo"}
+
+type Ethtool struct {
+	config.PluginConfig
+
+	InterfaceInclude []string `toml:"interface_include"`
+	InterfaceExclude []string `toml:"interface_exclude"`
+	StatsInclude     []string `toml:"stats_include"`
+	StatsExclude     []string `toml:"stats_exclude"`
+	// disable the driver specific rename rules and push the stat names as reported by the driver
+	DisableNormalize bool     `toml:"disable_normalize"`
+	OffloadFeatures  []string `toml:"offload_features"`
+
+	interfaceFilter filter.Filter
+	statsFilter     filter.Filter
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Ethtool{}
+	})
+}
+
+func (e *Ethtool) Clone() inputs.Input {
+	return &Ethtool{}
+}
+
+func (e *Ethtool) Name() string {
+	return inputName
+}
+
+func (e *Ethtool) Init() error {
+	var err error
+
+	if len(e.InterfaceExclude) == 0 {
+		e.InterfaceExclude = defaultInterfaceExclude
+	}
+	e.interfaceFilter, err = filter.NewIncludeExcludeFilter(e.InterfaceInclude, e.InterfaceExclude)
+	if err != nil {
+		return fmt.Errorf("error compiling interface filter: %s", err)
+	}
+
+	if len(e.StatsInclude) == 0 {
+		e.StatsInclude = defaultStatsInclude
+	}
+	e.statsFilter, err = filter.NewIncludeExcludeFilter(e.StatsInclude, e.StatsExclude)
+	if err != nil {
+		return fmt.Errorf("error compiling stats filter: %s", err)
+	}
+
+	return nil
+}
+
+// normalizeRule rewrites a per-queue stat name, e.g. mlx5 rx3_packets, to
+// <direction>_queue_<counter> and moves the queue number to a label
+type normalizeRule struct {
+	pattern *regexp.Regexp
+	// renames of counters to the names used by the other drivers
+	counters map[string]string
+}
+
+var (
+	// ixgbe and virtio_net both report rx_queue_0_packets, tx_queue_0_bytes, rx_queue_0_drops...
+	queueUnderscoreRule = &normalizeRule{
+		pattern: regexp.MustCompile(`^(rx|tx)_queue_(\d+)_(\w+)$`),
+	}
+
+	mlx5Rule = &normalizeRule{
+		pattern: regexp.MustCompile(`^(rx|tx)(\d+)_(\w+)$`),
+		counters: map[string]string{
+			"dropped": "drops",
+		},
+	}
+
+	normalizeRules = map[string]*normalizeRule{
+		"ixgbe":      queueUnderscoreRule,
+		"ixgbevf":    queueUnderscoreRule,
+		"virtio_net": queueUnderscoreRule,
+		"mlx5_core":  mlx5Rule,
+	}
+
+	// device level stats with the same meaning but a driver specific name
+	normalizeRenames = map[string]map[string]string{
+		"mlx5_core": {
+			"rx_out_of_buffer": "rx_missed_errors",
+		},
+	}
+
+	invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// normalize returns the stat name and the queue the stat belongs to,
+// queue is empty for device level stats and for drivers without rules
+func normalize(driver, stat string) (string, string) {
+	if renames, has := normalizeRenames[driver]; has {
+		if name, has := renames[stat]; has {
+			return name, ""
+		}
+	}
+
+	rule, has := normalizeRules[driver]
+	if !has {
+		return stat, ""
+	}
+
+	m := rule.pattern.FindStringSubmatch(stat)
+	if m == nil {
+		return stat, ""
+	}
+
+	counter := m[3]
+	if name, has := rule.counters[counter]; has {
+		counter = name
+	}
+	return m[1] + "_queue_" + counter, m[2]
+}
+
+func sanitize(name string) string {
+	return strings.ToLower(invalidMetricChars.ReplaceAllString(name, "_"))
+}
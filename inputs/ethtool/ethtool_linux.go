@@ -0,0 +1,159 @@
+//go:build linux
+
+package ethtool
+
+import (
+	"log"
+	"net"
+	"unsafe"
+
+	"github.com/safchain/ethtool"
+	"golang.org/x/sys/unix"
+
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	ethtoolGRingParam = 0x00000010
+	// SPEED_UNKNOWN in include/uapi/linux/ethtool.h
+	speedUnknown = 0xffffffff
+)
+
+// ringParam is struct ethtool_ringparam
+type ringParam struct {
+	cmd               uint32
+	rxMaxPending      uint32
+	rxMiniMaxPending  uint32
+	rxJumboMaxPending uint32
+	txMaxPending      uint32
+	rxPending         uint32
+	rxMiniPending     uint32
+	rxJumboPending    uint32
+	txPending         uint32
+}
+
+type ifreq struct {
+	name [unix.IFNAMSIZ]byte
+	data uintptr
+	_    [16]byte
+}
+
+func (e *Ethtool) Gather(slist *types.SampleList) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Println("E! failed to list interfaces:", err)
+		return
+	}
+
+	et, err := ethtool.NewEthtool()
+	if err != nil {
+		log.Println("E! failed to init ethtool:", err)
+		return
+	}
+	defer et.Close()
+
+	for _, iface := range interfaces {
+		if !e.interfaceFilter.Match(iface.Name) {
+			continue
+		}
+		e.gatherInterface(et, iface.Name, slist)
+	}
+}
+
+func (e *Ethtool) gatherInterface(et *ethtool.Ethtool, intf string, slist *types.SampleList) {
+	driver, err := et.DriverName(intf)
+	if err != nil {
+		// virtual interfaces like bridges do not implement the driver info ioctl
+		if e.DebugMod {
+			log.Println("D! failed to get driver of interface", intf, "error:", err)
+		}
+		return
+	}
+
+	tags := map[string]string{
+		"interface": intf,
+		"driver":    driver,
+	}
+
+	stats, err := et.Stats(intf)
+	if err != nil {
+		log.Println("E! failed to get stats of interface", intf, "error:", err)
+	}
+
+	fields := make(map[string]interface{})
+	for raw, value := range stats {
+		name, queue := raw, ""
+		if !e.DisableNormalize {
+			name, queue = normalize(driver, raw)
+		}
+
+		if !e.statsFilter.Match(raw) && !e.statsFilter.Match(name) {
+			continue
+		}
+
+		if queue == "" {
+			fields[sanitize(name)] = value
+			continue
+		}
+		slist.PushSample(inputName, sanitize(name), value, tags, map[string]string{"queue": queue})
+	}
+
+	var cmd ethtool.EthtoolCmd
+	if speed, err := et.CmdGet(&cmd, intf); err == nil && speed != speedUnknown {
+		fields["link_speed_mbps"] = speed
+	}
+
+	if state, err := et.LinkState(intf); err == nil {
+		fields["link_up"] = state
+	}
+
+	if ring, err := getRingParam(intf); err == nil {
+		fields["ring_rx_pending"] = ring.rxPending
+		fields["ring_rx_max_pending"] = ring.rxMaxPending
+		fields["ring_tx_pending"] = ring.txPending
+		fields["ring_tx_max_pending"] = ring.txMaxPending
+	} else if e.DebugMod {
+		log.Println("D! failed to get ring params of interface", intf, "error:", err)
+	}
+
+	if len(e.OffloadFeatures) > 0 {
+		features, err := et.Features(intf)
+		if err != nil {
+			log.Println("E! failed to get features of interface", intf, "error:", err)
+		}
+		for _, name := range e.OffloadFeatures {
+			enabled, has := features[name]
+			if !has {
+				continue
+			}
+			var v int
+			if enabled {
+				v = 1
+			}
+			slist.PushSample(inputName, "offload_enabled", v, tags, map[string]string{"feature": name})
+		}
+	}
+
+	slist.PushSamples(inputName, fields, tags)
+}
+
+// getRingParam reads the ring buffer sizes, safchain/ethtool does not expose ETHTOOL_GRINGPARAM
+func getRingParam(intf string) (ringParam, error) {
+	ring := ringParam{cmd: ethtoolGRingParam}
+
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, unix.IPPROTO_IP)
+	if err != nil {
+		return ring, err
+	}
+	defer unix.Close(fd)
+
+	var ifr ifreq
+	copy(ifr.name[:], intf)
+	ifr.data = uintptr(unsafe.Pointer(&ring))
+
+	_, _, ep := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr)))
+	if ep != 0 {
+		return ring, ep
+	}
+	return ring, nil
+}
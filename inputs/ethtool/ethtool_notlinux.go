@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ethtool
+
+import (
+	"flashcat.cloud/categraf/types"
+)
+
+func (e *Ethtool) Gather(slist *types.SampleList) {
+}
@@ -0,0 +1,180 @@
+package filestat
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/globpath"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName = "filestat"
+
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+type FileStat struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &FileStat{}
+	})
+}
+
+func (fs *FileStat) Clone() inputs.Input {
+	return &FileStat{}
+}
+
+func (fs *FileStat) Name() string {
+	return inputName
+}
+
+func (fs *FileStat) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(fs.Instances))
+	for i := 0; i < len(fs.Instances); i++ {
+		ret[i] = fs.Instances[i]
+	}
+	return ret
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// Files are the paths of the metrics files
+	Files []string `toml:"files"`
+	// WatchGlob is matched on every gather, so that the files created after the start are read
+	WatchGlob string `toml:"watch_glob"`
+	// Format is json or csv, guessed from the extension of the file if empty
+	Format string `toml:"format"`
+	// the fields of the records holding the name and the value of the metric
+	MetricKey string `toml:"metric_key"`
+	ValueKey  string `toml:"value_key"`
+	// the files not modified for MaxAge are skipped, 0 disables the check
+	MaxAge config.Duration `toml:"max_age"`
+
+	watch *globpath.GlobPath
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Files) == 0 && len(ins.WatchGlob) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	switch ins.Format {
+	case "", formatJSON, formatCSV:
+	default:
+		return fmt.Errorf("unknown format %q, should be json or csv", ins.Format)
+	}
+	if ins.MetricKey == "" {
+		ins.MetricKey = "metric"
+	}
+	if ins.ValueKey == "" {
+		ins.ValueKey = "value"
+	}
+	if ins.WatchGlob != "" {
+		g, err := globpath.Compile(ins.WatchGlob)
+		if err != nil {
+			return fmt.Errorf("failed to compile watch_glob %s: %v", ins.WatchGlob, err)
+		}
+		ins.watch = g
+	}
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	for _, path := range ins.paths() {
+		ins.gatherFile(path, slist)
+	}
+}
+
+// paths returns the configured files and the ones matching watch_glob, without duplicates
+func (ins *Instance) paths() []string {
+	seen := make(map[string]struct{})
+	var paths []string
+	add := func(p string) {
+		p = filepath.Clean(p)
+		if _, has := seen[p]; !has {
+			seen[p] = struct{}{}
+			paths = append(paths, p)
+		}
+	}
+	for _, p := range ins.Files {
+		add(p)
+	}
+	if ins.watch != nil {
+		for _, p := range ins.watch.Match() {
+			add(p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (ins *Instance) gatherFile(path string, slist *types.SampleList) {
+	fileLabels := map[string]string{"file": path}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		log.Println("E! failed to stat metrics file:", path, "error:", err)
+		slist.PushSample(inputName, "file_error", 1, fileLabels)
+		return
+	}
+	if fi.IsDir() {
+		return
+	}
+	slist.PushSample(inputName, "file_mtime_seconds", fi.ModTime().Unix(), fileLabels)
+	if ins.MaxAge > 0 && time.Since(fi.ModTime()) > time.Duration(ins.MaxAge) {
+		if ins.DebugMod {
+			log.Println("D! skip the metrics file not modified for max_age:", path)
+		}
+		slist.PushSample(inputName, "file_error", 0, fileLabels)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Println("E! failed to read metrics file:", path, "error:", err)
+		slist.PushSample(inputName, "file_error", 1, fileLabels)
+		return
+	}
+
+	var records []record
+	switch ins.format(path) {
+	case formatCSV:
+		records, err = parseCSV(data, ins.MetricKey, ins.ValueKey)
+	default:
+		records, err = parseJSON(data, ins.MetricKey, ins.ValueKey)
+	}
+	if err != nil {
+		// the records before the malformed one are still pushed
+		log.Println("E! failed to parse metrics file:", path, "error:", err)
+		slist.PushSample(inputName, "file_error", 1, fileLabels)
+	} else {
+		slist.PushSample(inputName, "file_error", 0, fileLabels)
+	}
+
+	for _, r := range records {
+		slist.PushSample("", r.metric, r.value, r.labels)
+	}
+}
+
+func (ins *Instance) format(path string) string {
+	if ins.Format != "" {
+		return ins.Format
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return formatCSV
+	}
+	return formatJSON
+}
@@ -0,0 +1,208 @@
+package filestat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"flashcat.cloud/categraf/pkg/conv"
+)
+
+// labelsKey is the field of the JSON records holding the labels as an object
+const labelsKey = "labels"
+
+type record struct {
+	metric string
+	value  float64
+	labels map[string]string
+}
+
+// parseJSON parses either a JSON array of records or JSON lines, one record per line, e.g.
+// {"metric": "backup_duration_seconds", "value": 12.5, "job": "db", "labels": {"env": "prod"}}
+// The scalar fields other than the metric and the value become labels along with the ones
+// of the labels object. The malformed records are skipped, the first error is returned.
+func parseJSON(data []byte, metricKey, valueKey string) ([]record, error) {
+	var (
+		objects  []map[string]interface{}
+		firstErr error
+	)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if err := dec.Decode(&objects); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %v", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for n := 1; scanner.Scan(); n++ {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 || line[0] == '#' {
+				continue
+			}
+			var obj map[string]interface{}
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			if err := dec.Decode(&obj); err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("line %d: invalid JSON: %v", n, err)
+				}
+				continue
+			}
+			objects = append(objects, obj)
+		}
+		if err := scanner.Err(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	records := make([]record, 0, len(objects))
+	for i, obj := range objects {
+		r, err := jsonRecord(obj, metricKey, valueKey)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("record %d: %v", i+1, err)
+			}
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, firstErr
+}
+
+func jsonRecord(obj map[string]interface{}, metricKey, valueKey string) (record, error) {
+	r := record{labels: make(map[string]string)}
+	metric, ok := obj[metricKey].(string)
+	if !ok || metric == "" {
+		return r, fmt.Errorf("missing %s", metricKey)
+	}
+	r.metric = metric
+
+	raw, has := obj[valueKey]
+	if !has {
+		return r, fmt.Errorf("missing %s of %s", valueKey, metric)
+	}
+	if n, ok := raw.(json.Number); ok {
+		raw = n.String()
+	}
+	v, err := conv.ToFloat64(raw)
+	if err != nil {
+		return r, fmt.Errorf("invalid %s of %s: %v", valueKey, metric, err)
+	}
+	r.value = v
+
+	for k, v := range obj {
+		if k == metricKey || k == valueKey {
+			continue
+		}
+		if k == labelsKey {
+			if labels, ok := v.(map[string]interface{}); ok {
+				for lk, lv := range labels {
+					if s, ok := labelValue(lv); ok {
+						r.labels[lk] = s
+					}
+				}
+				continue
+			}
+		}
+		if s, ok := labelValue(v); ok {
+			r.labels[k] = s
+		}
+	}
+	return r, nil
+}
+
+// labelValue returns the scalar values as strings, the objects, arrays and nulls are ignored
+func labelValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, v != ""
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// parseCSV parses CSV records with a header, the columns other than the metric and the
+// value become labels, e.g.
+// metric,value,job
+// backup_duration_seconds,12.5,db
+func parseCSV(data []byte, metricKey, valueKey string) ([]record, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %v", err)
+	}
+	metricIdx, valueIdx := -1, -1
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		switch header[i] {
+		case metricKey:
+			metricIdx = i
+		case valueKey:
+			valueIdx = i
+		}
+	}
+	if metricIdx < 0 || valueIdx < 0 {
+		return nil, fmt.Errorf("the CSV header should have the %s and %s columns", metricKey, valueKey)
+	}
+
+	var (
+		records  []record
+		firstErr error
+	)
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the reader resumes on the next line after a malformed one
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if len(fields) != len(header) {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %d: %d fields, the header has %d", line, len(fields), len(header))
+			}
+			continue
+		}
+
+		r := record{metric: fields[metricIdx], labels: make(map[string]string)}
+		if r.metric == "" {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %d: missing %s", line, metricKey)
+			}
+			continue
+		}
+		if r.value, err = conv.ToFloat64(strings.TrimSpace(fields[valueIdx])); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %d: invalid %s of %s: %v", line, valueKey, r.metric, err)
+			}
+			continue
+		}
+		for i, f := range fields {
+			if i != metricIdx && i != valueIdx && f != "" && header[i] != "" {
+				r.labels[header[i]] = f
+			}
+		}
+		records = append(records, r)
+	}
+	return records, firstErr
+}
@@ -0,0 +1,61 @@
+package filestat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	lines := `{"metric": "backup_duration_seconds", "value": 12.5, "job": "db", "port": 5432, "labels": {"env": "prod"}, "extra": {"a": 1}}
+# a comment
+{"metric": "backup_ok", "value": "true"}
+{"metric": "broken", "value": 
+{"metric": "no_value"}
+`
+	records, err := parseJSON([]byte(lines), "metric", "value")
+	if err == nil {
+		t.Error("expected the error of the malformed lines")
+	}
+	want := []record{
+		{metric: "backup_duration_seconds", value: 12.5, labels: map[string]string{"job": "db", "port": "5432", "env": "prod"}},
+		{metric: "backup_ok", value: 1, labels: map[string]string{}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %+v, want %+v", records, want)
+	}
+
+	array := `[{"name": "queue_size", "v": 3, "queue": "mail"}]`
+	records, err = parseJSON([]byte(array), "name", "v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []record{{metric: "queue_size", value: 3, labels: map[string]string{"queue": "mail"}}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %+v, want %+v", records, want)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	data := `metric, value, job, env
+# a comment
+backup_duration_seconds,12.5,db,prod
+backup_size_bytes,1024,db,
+backup_ok,x,db,prod
+short,1
+`
+	records, err := parseCSV([]byte(data), "metric", "value")
+	if err == nil {
+		t.Error("expected the error of the malformed lines")
+	}
+	want := []record{
+		{metric: "backup_duration_seconds", value: 12.5, labels: map[string]string{"job": "db", "env": "prod"}},
+		{metric: "backup_size_bytes", value: 1024, labels: map[string]string{"job": "db"}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %+v, want %+v", records, want)
+	}
+
+	if _, err := parseCSV([]byte("name,value\nx,1\n"), "metric", "value"); err == nil {
+		t.Error("expected the error of the missing metric column")
+	}
+}
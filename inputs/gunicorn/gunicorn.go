@@ -0,0 +1,127 @@
+package gunicorn
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName = "gunicorn"
+
+	modeStatsd       = "statsd"
+	modeMultiprocess = "multiprocess"
+)
+
+type Gunicorn struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Gunicorn)
+var _ inputs.InstancesGetter = new(Gunicorn)
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Gunicorn{}
+	})
+}
+
+func (g *Gunicorn) Clone() inputs.Input {
+	return &Gunicorn{}
+}
+
+func (g *Gunicorn) Name() string {
+	return inputName
+}
+
+func (g *Gunicorn) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(g.Instances))
+	for i := 0; i < len(g.Instances); i++ {
+		ret[i] = g.Instances[i]
+	}
+	return ret
+}
+
+func (g *Gunicorn) Drop() {
+	for i := 0; i < len(g.Instances); i++ {
+		g.Instances[i].Drop()
+	}
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// Mode is statsd (default), receiving the metrics gunicorn sends with --statsd-host, or
+	// multiprocess, reading the files of the prometheus_client multiprocess mode
+	Mode string `toml:"mode"`
+
+	// udp address to listen on in statsd mode, e.g. ":8125"
+	UDPAddress string `toml:"udp_address"`
+
+	// the PROMETHEUS_MULTIPROC_DIR of the workers in multiprocess mode
+	MultiprocessDir string `toml:"multiprocess_dir"`
+	// skip the live gauges of the workers which are not running anymore, for the
+	// applications not calling mark_process_dead in the child_exit hook of gunicorn,
+	// categraf must run in the pid namespace of gunicorn
+	SkipDeadWorkers bool `toml:"skip_dead_workers"`
+
+	agg  *aggregator
+	conn net.PacketConn
+	wg   sync.WaitGroup
+}
+
+func (ins *Instance) Init() error {
+	switch ins.Mode {
+	case "", modeStatsd:
+		ins.Mode = modeStatsd
+		if len(ins.UDPAddress) == 0 {
+			return types.ErrInstancesEmpty
+		}
+		ins.agg = newAggregator()
+		return ins.listen()
+	case modeMultiprocess:
+		if len(ins.MultiprocessDir) == 0 {
+			return types.ErrInstancesEmpty
+		}
+		if fi, err := os.Stat(ins.MultiprocessDir); err != nil {
+			return fmt.Errorf("failed to stat multiprocess_dir: %v", err)
+		} else if !fi.IsDir() {
+			return fmt.Errorf("multiprocess_dir %s is not a directory", ins.MultiprocessDir)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q, should be statsd or multiprocess", ins.Mode)
+	}
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	switch ins.Mode {
+	case modeStatsd:
+		ins.agg.gather(slist)
+	case modeMultiprocess:
+		samples, err := readMultiprocessDir(ins.MultiprocessDir, ins.SkipDeadWorkers)
+		if err != nil {
+			log.Println("E! failed to read multiprocess_dir:", ins.MultiprocessDir, "error:", err)
+			slist.PushSample(inputName, "up", 0)
+			return
+		}
+		slist.PushSample(inputName, "up", 1)
+		slist.PushFrontN(samples)
+	}
+}
+
+func (ins *Instance) Drop() {
+	if ins.conn == nil {
+		return
+	}
+	ins.conn.Close()
+	ins.wg.Wait()
+}
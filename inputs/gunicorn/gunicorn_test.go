@@ -0,0 +1,168 @@
+package gunicorn
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flashcat.cloud/categraf/types"
+)
+
+type testEntry struct {
+	key   string
+	value float64
+	ts    float64
+}
+
+// writeFile writes the entries like the MmapedDict of prometheus_client
+func writeFile(t *testing.T, path string, entries ...testEntry) {
+	data := make([]byte, 8)
+	for _, e := range entries {
+		padded := []byte(e.key)
+		for n := 8 - (len(e.key)+4)%8; n > 0; n-- {
+			padded = append(padded, ' ')
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(e.key)))
+		data = append(data, padded...)
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(e.value))
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(e.ts))
+	}
+	binary.LittleEndian.PutUint32(data, uint32(len(data)))
+	// the files are allocated by pages, the rest is zeroed
+	data = append(data, make([]byte, 64)...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func values(samples []*types.Sample) map[string]float64 {
+	m := make(map[string]float64)
+	for _, s := range samples {
+		key := s.Metric
+		for _, l := range []string{"le", "pid", "path"} {
+			if v, has := s.Labels[l]; has {
+				key += "," + l + "=" + v
+			}
+		}
+		m[key] = s.Value.(float64)
+	}
+	return m
+}
+
+func TestReadMultiprocessDir(t *testing.T) {
+	dir := t.TempDir()
+	// the worker 100 was stopped by a reload and replaced by the worker 200
+	writeFile(t, filepath.Join(dir, "counter_100.db"),
+		testEntry{key: `["requests", "requests_total", {"path": "/"}, "Requests"]`, value: 3},
+		testEntry{key: `["requests", "requests_created", {"path": "/"}, "Requests"]`, value: 1700000000},
+	)
+	writeFile(t, filepath.Join(dir, "counter_200.db"),
+		testEntry{key: `["requests", "requests_total", ["path"], ["/"]]`, value: 2},
+	)
+	writeFile(t, filepath.Join(dir, "histogram_200.db"),
+		testEntry{key: `["latency", "latency_bucket", {"le": "0.1"}, "Latency"]`, value: 4},
+		testEntry{key: `["latency", "latency_bucket", {"le": "+Inf"}, "Latency"]`, value: 1},
+		testEntry{key: `["latency", "latency_bucket", {"le": "1.0"}, "Latency"]`, value: 2},
+		testEntry{key: `["latency", "latency_sum", {}, "Latency"]`, value: 3.5},
+	)
+	writeFile(t, filepath.Join(dir, "gauge_all_100.db"),
+		testEntry{key: `["memory", "memory", {}, "Memory"]`, value: 10},
+	)
+	writeFile(t, filepath.Join(dir, "gauge_all_200.db"),
+		testEntry{key: `["memory", "memory", {}, "Memory"]`, value: 20},
+	)
+	writeFile(t, filepath.Join(dir, "gauge_max_100.db"),
+		testEntry{key: `["peak", "peak", {}, "Peak"]`, value: 7},
+	)
+	writeFile(t, filepath.Join(dir, "gauge_max_200.db"),
+		testEntry{key: `["peak", "peak", {}, "Peak"]`, value: 5},
+	)
+	writeFile(t, filepath.Join(dir, "gauge_mostrecent_100.db"),
+		testEntry{key: `["last", "last", {}, "Last"]`, value: 1, ts: 20},
+	)
+	writeFile(t, filepath.Join(dir, "gauge_mostrecent_200.db"),
+		testEntry{key: `["last", "last", {}, "Last"]`, value: 2, ts: 10},
+	)
+
+	samples, err := readMultiprocessDir(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := values(samples)
+	want := map[string]float64{
+		"requests_total,path=/":  5,
+		"latency_bucket,le=0.1":  4,
+		"latency_bucket,le=1.0":  6,
+		"latency_bucket,le=+Inf": 7,
+		"latency_count":          7,
+		"latency_sum":            3.5,
+		"memory,pid=100":         10,
+		"memory,pid=200":         20,
+		"peak":                   7,
+		"last":                   1,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d samples, want %d: %v", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	a := newAggregator()
+	for _, line := range []string{
+		"myapp.gunicorn.requests:1|c|@1.0",
+		"myapp.gunicorn.requests:1|c|@0.5",
+		"myapp.gunicorn.request.status.200:1|c|@1.0",
+		"myapp.gunicorn.request.duration:250|ms",
+		"myapp.gunicorn.request.duration:50|ms",
+		"myapp.gunicorn.workers:4|g",
+		"gunicorn.log.error:1|c|@1.0|#env:prod",
+	} {
+		if err := a.add(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.add("gunicorn.requests:x|c"); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+
+	slist := types.NewSampleList()
+	a.gather(slist)
+	got := make(map[string]*types.Sample)
+	for _, s := range slist.PopBackAll() {
+		got[s.Metric] = s
+	}
+	for metric, want := range map[string]float64{
+		"gunicorn_requests_total":                 3,
+		"gunicorn_request_status_total":           1,
+		"gunicorn_request_duration_seconds_count": 2,
+		"gunicorn_request_duration_seconds_sum":   0.3,
+		"gunicorn_request_duration_seconds_max":   0.25,
+		"gunicorn_workers":                        4,
+		"gunicorn_log_total":                      1,
+	} {
+		s, has := got[metric]
+		if !has {
+			t.Errorf("missing %s", metric)
+			continue
+		}
+		if v := s.Value.(float64); math.Abs(v-want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", metric, v, want)
+		}
+	}
+	if l := got["gunicorn_workers"].Labels["app"]; l != "myapp" {
+		t.Errorf("app label = %q, want myapp", l)
+	}
+	if l := got["gunicorn_request_status_total"].Labels["status"]; l != "200" {
+		t.Errorf("status label = %q, want 200", l)
+	}
+	if l := got["gunicorn_log_total"].Labels; l["level"] != "error" || l["env"] != "prod" {
+		t.Errorf("log labels = %v", l)
+	}
+}
@@ -0,0 +1,276 @@
+package gunicorn
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// fileEntry is a value of a file written by the multiprocess mode of prometheus_client
+type fileEntry struct {
+	metric    string
+	sample    string
+	labels    map[string]string
+	value     float64
+	timestamp float64
+}
+
+// readFile reads the mmap file of prometheus_client. The file starts with the used size as
+// uint32 and 4 bytes of padding, followed by the entries: the length of the key as uint32,
+// the JSON key padded with 1 to 8 spaces to a multiple of 8 bytes, then the value and the
+// timestamp as float64.
+func readFile(path string) ([]fileEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		// a file just created by a worker which hasn't written the header yet
+		return nil, nil
+	}
+	used := int(binary.LittleEndian.Uint32(data))
+	if used > len(data) {
+		return nil, fmt.Errorf("%s: used size %d exceeds the file size %d", path, used, len(data))
+	}
+
+	var entries []fileEntry
+	for pos := 8; pos+4 <= used; {
+		keyLen := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if pos+keyLen > used {
+			return nil, fmt.Errorf("%s: key of %d bytes at %d exceeds the used size", path, keyLen, pos)
+		}
+		key := data[pos : pos+keyLen]
+		pos += keyLen + 8 - (keyLen+4)%8
+		if pos+16 > used {
+			return nil, fmt.Errorf("%s: value at %d exceeds the used size", path, pos)
+		}
+		e, err := parseKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		e.value = math.Float64frombits(binary.LittleEndian.Uint64(data[pos:]))
+		e.timestamp = math.Float64frombits(binary.LittleEndian.Uint64(data[pos+8:]))
+		pos += 16
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// parseKey parses the key [metric_name, sample_name, {labels}, help] of prometheus_client,
+// the older versions store [metric_name, sample_name, [label_names], [label_values]]
+func parseKey(key []byte) (fileEntry, error) {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(key, &parts); err != nil || len(parts) < 3 {
+		return fileEntry{}, fmt.Errorf("invalid key %q", key)
+	}
+	var e fileEntry
+	if err := json.Unmarshal(parts[0], &e.metric); err != nil {
+		return fileEntry{}, fmt.Errorf("invalid metric name of key %q", key)
+	}
+	if err := json.Unmarshal(parts[1], &e.sample); err != nil {
+		return fileEntry{}, fmt.Errorf("invalid sample name of key %q", key)
+	}
+	if err := json.Unmarshal(parts[2], &e.labels); err == nil {
+		return e, nil
+	}
+	var names, values []string
+	if len(parts) < 4 || json.Unmarshal(parts[2], &names) != nil || json.Unmarshal(parts[3], &values) != nil || len(names) != len(values) {
+		return fileEntry{}, fmt.Errorf("invalid labels of key %q", key)
+	}
+	e.labels = make(map[string]string, len(names))
+	for i := range names {
+		e.labels[names[i]] = values[i]
+	}
+	return e, nil
+}
+
+// mpFile is a file of the multiprocess dir, named <type>_<pid>.db or gauge_<mode>_<pid>.db
+type mpFile struct {
+	path string
+	typ  string
+	mode string
+	pid  string
+}
+
+func parseFileName(path string) (mpFile, bool) {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(path), ".db"), "_")
+	f := mpFile{path: path, typ: parts[0]}
+	switch {
+	case f.typ == "gauge" && len(parts) == 3:
+		f.mode, f.pid = parts[1], parts[2]
+	case f.typ != "gauge" && len(parts) == 2:
+		f.pid = parts[1]
+	default:
+		return f, false
+	}
+	return f, true
+}
+
+// sampleKey identifies a sample merged across the files of the workers
+type sampleKey struct {
+	name   string
+	labels string
+}
+
+type merged struct {
+	name      string
+	labels    map[string]string
+	value     float64
+	timestamp float64
+	// the histogram bucket counts are stored per bucket, le is the upper bound
+	le float64
+}
+
+// readMultiprocessDir merges the values of the workers like the MultiProcessCollector of
+// prometheus_client: the counters, histograms and summaries are summed, so that the values
+// of the workers stopped by a reload are kept, and the gauges are merged by their
+// multiprocess_mode, the all modes keeping the pid label.
+func readMultiprocessDir(dir string, skipDead bool) ([]*types.Sample, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[sampleKey]*merged)
+	alive := make(map[string]bool)
+	for _, path := range paths {
+		f, ok := parseFileName(path)
+		if !ok {
+			continue
+		}
+		if skipDead && strings.HasPrefix(f.mode, "live") && !isAlive(alive, f.pid) {
+			continue
+		}
+		entries, err := readFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// removed by mark_process_dead since the glob
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			mergeEntry(values, f, e)
+		}
+	}
+	return histogramSamples(values), nil
+}
+
+func isAlive(cache map[string]bool, pid string) bool {
+	if v, has := cache[pid]; has {
+		return v
+	}
+	n, err := strconv.ParseInt(pid, 10, 32)
+	alive := err != nil
+	if !alive {
+		exists, err := process.PidExists(int32(n))
+		// keep the file when the pid can't be checked
+		alive = exists || err != nil
+	}
+	cache[pid] = alive
+	return alive
+}
+
+func mergeEntry(values map[sampleKey]*merged, f mpFile, e fileEntry) {
+	labels := e.labels
+	if f.typ == "gauge" && (f.mode == "all" || f.mode == "liveall") {
+		labels = make(map[string]string, len(e.labels)+1)
+		for k, v := range e.labels {
+			labels[k] = v
+		}
+		labels["pid"] = f.pid
+	}
+	key := sampleKey{name: e.sample, labels: labelsKey(labels)}
+	m, has := values[key]
+	if !has {
+		m = &merged{name: e.sample, labels: labels, value: e.value, timestamp: e.timestamp}
+		if f.typ == "histogram" && strings.HasSuffix(e.sample, "_bucket") {
+			m.le, _ = strconv.ParseFloat(labels["le"], 64)
+		}
+		values[key] = m
+		return
+	}
+
+	switch {
+	case f.typ != "gauge":
+		m.value += e.value
+	case strings.HasSuffix(f.mode, "min"):
+		m.value = math.Min(m.value, e.value)
+	case strings.HasSuffix(f.mode, "max"):
+		m.value = math.Max(m.value, e.value)
+	case strings.HasSuffix(f.mode, "mostrecent"):
+		if e.timestamp > m.timestamp {
+			m.value, m.timestamp = e.value, e.timestamp
+		}
+	default:
+		// sum and livesum, all and liveall have a series per pid
+		m.value += e.value
+	}
+}
+
+// histogramSamples returns the samples, the buckets being accumulated and _count being
+// added for the histograms, which only store the count of each bucket and the sum
+func histogramSamples(values map[sampleKey]*merged) []*types.Sample {
+	buckets := make(map[sampleKey][]*merged)
+	samples := make([]*types.Sample, 0, len(values))
+	for _, m := range values {
+		if !strings.HasSuffix(m.name, "_bucket") || m.labels["le"] == "" {
+			if !strings.HasSuffix(m.name, "_created") {
+				samples = append(samples, types.NewSample("", m.name, m.value, m.labels))
+			}
+			continue
+		}
+		labels := make(map[string]string, len(m.labels))
+		for k, v := range m.labels {
+			if k != "le" {
+				labels[k] = v
+			}
+		}
+		key := sampleKey{name: strings.TrimSuffix(m.name, "_bucket"), labels: labelsKey(labels)}
+		buckets[key] = append(buckets[key], m)
+	}
+
+	for key, bs := range buckets {
+		sort.Slice(bs, func(i, j int) bool { return bs[i].le < bs[j].le })
+		var acc float64
+		for _, b := range bs {
+			acc += b.value
+			samples = append(samples, types.NewSample("", b.name, acc, b.labels))
+		}
+		labels := make(map[string]string, len(bs[0].labels))
+		for k, v := range bs[0].labels {
+			if k != "le" {
+				labels[k] = v
+			}
+		}
+		samples = append(samples, types.NewSample("", key.name+"_count", acc, labels))
+	}
+	return samples
+}
+
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(labels[k])
+		sb.WriteByte(',')
+	}
+	return sb.String()
+}
@@ -0,0 +1,188 @@
+package gunicorn
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func (ins *Instance) listen() error {
+	conn, err := net.ListenPacket("udp", ins.UDPAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on udp %s: %v", ins.UDPAddress, err)
+	}
+	ins.conn = conn
+	ins.wg.Add(1)
+	go func() {
+		defer ins.wg.Done()
+		buf := make([]byte, 64*1024)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Println("E! failed to read gunicorn statsd packet:", err)
+					continue
+				}
+				return
+			}
+			for _, line := range strings.Split(string(buf[:n]), "\n") {
+				if err := ins.agg.add(line); err != nil && ins.DebugMod {
+					log.Println("D!", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// seriesKey identifies a series of the aggregator, app is the --statsd-prefix of gunicorn
+// and tags the sorted DogStatsD tags sent with --dogstatsd-tags
+type seriesKey struct {
+	app  string
+	name string
+	tags string
+}
+
+type timer struct {
+	count float64
+	sum   float64
+	// max is the largest duration since the last gather
+	max float64
+}
+
+// aggregator keeps the counters and the timers as cumulative values, the series of the
+// gunicorn metrics survive the restart of the workers as they are sent by every worker
+type aggregator struct {
+	sync.Mutex
+	counters map[seriesKey]float64
+	gauges   map[seriesKey]float64
+	timers   map[seriesKey]*timer
+}
+
+func newAggregator() *aggregator {
+	return &aggregator{
+		counters: make(map[seriesKey]float64),
+		gauges:   make(map[seriesKey]float64),
+		timers:   make(map[seriesKey]*timer),
+	}
+}
+
+// add parses a line sent by the statsd logger of gunicorn, <name>:<value>|<type>[|@<rate>][|#<tags>]
+func (a *aggregator) add(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	idx := strings.IndexByte(line, ':')
+	if idx <= 0 {
+		return fmt.Errorf("malformed line %q: missing metric name", line)
+	}
+	fields := strings.Split(line[idx+1:], "|")
+	if len(fields) < 2 {
+		return fmt.Errorf("malformed line %q: missing metric type", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return fmt.Errorf("malformed line %q: invalid value: %v", line, err)
+	}
+
+	rate := 1.0
+	var tags []string
+	for _, field := range fields[2:] {
+		if field == "" {
+			continue
+		}
+		switch field[0] {
+		case '@':
+			if r, err := strconv.ParseFloat(field[1:], 64); err == nil && r > 0 && r <= 1 {
+				rate = r
+			}
+		case '#':
+			tags = strings.Split(field[1:], ",")
+		}
+	}
+	sort.Strings(tags)
+
+	key := seriesKey{tags: strings.Join(tags, ",")}
+	key.name = line[:idx]
+	if i := strings.LastIndex(key.name, "gunicorn."); i > 0 {
+		key.app = strings.TrimSuffix(key.name[:i], ".")
+		key.name = key.name[i:]
+	}
+
+	a.Lock()
+	defer a.Unlock()
+	switch fields[1] {
+	case "c":
+		a.counters[key] += value / rate
+	case "g":
+		a.gauges[key] = value
+	case "ms", "h":
+		t := a.timers[key]
+		if t == nil {
+			t = &timer{}
+			a.timers[key] = t
+		}
+		t.count += 1 / rate
+		t.sum += value
+		if value > t.max {
+			t.max = value
+		}
+	default:
+		return fmt.Errorf("malformed line %q: unsupported metric type %q", line, fields[1])
+	}
+	return nil
+}
+
+func (a *aggregator) gather(slist *types.SampleList) {
+	a.Lock()
+	defer a.Unlock()
+
+	for key, v := range a.counters {
+		name, labels := key.series()
+		slist.PushSample("", name+"_total", v, labels)
+	}
+	for key, v := range a.gauges {
+		name, labels := key.series()
+		slist.PushSample("", name, v, labels)
+	}
+	for key, t := range a.timers {
+		// gunicorn sends the durations in milliseconds
+		name, labels := key.series()
+		slist.PushSample("", name+"_seconds_count", t.count, labels)
+		slist.PushSample("", name+"_seconds_sum", t.sum/1000, labels)
+		slist.PushSample("", name+"_seconds_max", t.max/1000, labels)
+		t.max = 0
+	}
+}
+
+// series returns the name and the labels of the series, the status code of
+// gunicorn.request.status.<code> and the level of gunicorn.log.<level> become labels
+func (k seriesKey) series() (string, map[string]string) {
+	labels := make(map[string]string)
+	if k.app != "" {
+		labels["app"] = k.app
+	}
+	for _, tag := range strings.Split(k.tags, ",") {
+		if n, v, found := strings.Cut(tag, ":"); found && n != "" {
+			labels[n] = v
+		}
+	}
+
+	name := k.name
+	if code, found := strings.CutPrefix(name, "gunicorn.request.status."); found {
+		name = "gunicorn.request.status"
+		labels["status"] = code
+	} else if level, found := strings.CutPrefix(name, "gunicorn.log."); found {
+		name = "gunicorn.log"
+		labels["level"] = level
+	}
+	return name, labels
+}
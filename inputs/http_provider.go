@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"flashcat.cloud/categraf/agent/events"
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/pkg/cfg"
 	"flashcat.cloud/categraf/pkg/set"
@@ -275,6 +276,7 @@ func (hrp *HTTPProvider) StartReloader() {
 							for _, conf := range cm {
 								hrp.op.RegisterInput(FormatInputName(hrp.Name(), inputKey), []cfg.ConfigWithFormat{conf})
 							}
+							events.Publish(events.InputReloaded, map[string]string{"provider": hrp.Name(), "input": inputKey})
 						}
 					}
 
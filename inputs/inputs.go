@@ -21,6 +21,11 @@ type InstancesGetter interface {
 	GetInstances() []Instance
 }
 
+// Describer reports what an instance collects and what it costs, printed as json by categraf -describe
+type Describer interface {
+	Describe() (interface{}, error)
+}
+
 func MayInit(t interface{}) error {
 	if initializer, ok := t.(Initializer); ok {
 		return initializer.Init()
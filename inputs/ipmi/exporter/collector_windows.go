@@ -8,7 +8,10 @@ import (
 )
 
 type IPMIConfig struct {
-	Timeout uint32
+	User      string `toml:"user"`
+	Password  string `toml:"pass"`
+	Privilege string `toml:"privilege"`
+	Timeout   uint32 `toml:"timeout"`
 }
 
 func Collect(ch chan<- prometheus.Metric, host, binPath string, config IPMIConfig) {
@@ -19,12 +19,24 @@ type Instance struct {
 	Target string `toml:"target"`
 	Path   string `toml:"path"`
 	exporter.IPMIConfig
+
+	// UseIpmitool reads the sensors with ipmitool sdr elist full instead of freeipmi,
+	// the local machine when target is localhost, a BMC via IPMI over LAN otherwise
+	UseIpmitool       bool            `toml:"use_ipmitool"`
+	IpmitoolPath      string          `toml:"ipmitool_path"`
+	IpmitoolInterface string          `toml:"ipmitool_interface"`
+	IpmitoolCSV       bool            `toml:"ipmitool_csv"`
+	IpmitoolTimeout   config.Duration `toml:"ipmitool_timeout"`
+	UseSudo           bool            `toml:"use_sudo"`
 }
 
 func (m *Instance) Init() error {
 	if len(m.Target) == 0 {
 		return types.ErrInstancesEmpty
 	}
+	if m.UseIpmitool {
+		return m.initIpmitool()
+	}
 	// Set defaults
 	if m.Timeout == 0 {
 		m.Timeout = 20
@@ -35,6 +47,11 @@ func (m *Instance) Init() error {
 
 // Gather is the main execution function for the plugin
 func (m *Instance) Gather(slist *types.SampleList) {
+	if m.UseIpmitool {
+		m.gatherIpmitool(slist)
+		return
+	}
+
 	constLabels := m.GetLabels()
 	metricChan := make(chan prometheus.Metric, 500)
 
@@ -0,0 +1,230 @@
+package ipmi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/cmdx"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	defaultIpmitoolPath      = "ipmitool"
+	defaultIpmitoolInterface = "lan"
+	defaultIpmitoolTimeout   = 20 * time.Second
+)
+
+// sensorReading is a record of ipmitool sdr elist full, e.g.
+// CPU Temp         | 30h | ok  |  3.1 | 40 degrees C
+type sensorReading struct {
+	name   string
+	value  float64
+	unit   string
+	status string
+}
+
+// isLocalTarget tells whether the sensors of the local machine are read, through the
+// ipmi driver, instead of a BMC via IPMI over LAN
+func isLocalTarget(target string) bool {
+	return target == "localhost" || target == "127.0.0.1" || target == "local"
+}
+
+// ipmitoolArgs returns the arguments of the ipmitool sdr elist full command, with the
+// connection arguments of the BMC for the remote targets
+func (m *Instance) ipmitoolArgs() []string {
+	var args []string
+	if !isLocalTarget(m.Target) {
+		args = append(args, "-I", m.IpmitoolInterface, "-H", m.Target)
+		if m.User != "" {
+			args = append(args, "-U", m.User)
+		}
+		if m.Password != "" {
+			args = append(args, "-P", m.Password)
+		}
+		if m.Privilege != "" {
+			args = append(args, "-L", strings.ToUpper(m.Privilege))
+		}
+	}
+	if m.IpmitoolCSV {
+		args = append(args, "-c")
+	}
+	return append(args, "sdr", "elist", "full")
+}
+
+// gatherIpmitool reads the sensor data records with ipmitool and emits ipmi_sensor_value
+func (m *Instance) gatherIpmitool(slist *types.SampleList) {
+	name, args := m.IpmitoolPath, m.ipmitoolArgs()
+	if m.UseSudo {
+		name, args = "sudo", append([]string{"-n", m.IpmitoolPath}, args...)
+	}
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// the command line holding the password is not logged
+	target := m.Target
+	err, timeout := cmdx.RunTimeout(cmd, time.Duration(m.IpmitoolTimeout))
+	if timeout {
+		log.Printf("E! run ipmitool of target %s timeout", target)
+		return
+	}
+	if err != nil {
+		log.Printf("E! failed to run ipmitool of target %s | error: %v | stderr: %s", target, err, stderr.String())
+		return
+	}
+
+	var readings []sensorReading
+	if m.IpmitoolCSV {
+		readings, err = parseSensorsCSV(&stdout)
+	} else {
+		readings, err = parseSensors(&stdout)
+	}
+	if err != nil {
+		log.Printf("E! failed to parse ipmitool output of target %s: %v", target, err)
+		return
+	}
+
+	for _, r := range readings {
+		labels := map[string]string{
+			"sensor_name": r.name,
+			"sensor_type": sensorType(r.unit),
+			"unit":        r.unit,
+			"status":      r.status,
+		}
+		if !isLocalTarget(target) {
+			labels["target"] = target
+		}
+		slist.PushSample(inputName, "sensor_value", r.value, labels)
+	}
+}
+
+// parseSensors parses the pipe separated output: name | id | status | entity | reading
+func parseSensors(r io.Reader) ([]sensorReading, error) {
+	var readings []sensorReading
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) < 5 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		value, unit, ok := parseReading(fields[4])
+		if !ok {
+			continue
+		}
+		status, ok := sensorStatus(fields[2])
+		if !ok {
+			continue
+		}
+		readings = append(readings, sensorReading{name: fields[0], value: value, unit: unit, status: status})
+	}
+	return readings, scanner.Err()
+}
+
+// parseSensorsCSV parses the output of ipmitool -c: name,reading,unit,status[,entity,...]
+func parseSensorsCSV(r io.Reader) ([]sensorReading, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var readings []sensorReading
+	for _, fields := range records {
+		if len(fields) < 4 {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil {
+			// no reading or a discrete sensor
+			continue
+		}
+		status, ok := sensorStatus(fields[3])
+		if !ok {
+			continue
+		}
+		readings = append(readings, sensorReading{
+			name:   strings.TrimSpace(fields[0]),
+			value:  value,
+			unit:   strings.TrimSpace(fields[2]),
+			status: status,
+		})
+	}
+	return readings, nil
+}
+
+// parseReading parses an analog reading, e.g. 40 degrees C or 4200 RPM, the discrete
+// readings and the sensors without reading are skipped
+func parseReading(s string) (float64, string, bool) {
+	raw, unit, _ := strings.Cut(s, " ")
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return value, strings.TrimSpace(unit), true
+}
+
+// sensorStatus maps the status of the sensor to ok, warning or critical, false when the
+// sensor is not available (ns)
+func sensorStatus(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "ok":
+		return "ok", true
+	case "nc", "lnc", "unc":
+		return "warning", true
+	case "cr", "lcr", "ucr", "nr", "lnr", "unr":
+		return "critical", true
+	}
+	return "", false
+}
+
+// sensorType derives the type of the sensor from the unit, the sdr elist records
+// not holding the sensor type
+func sensorType(unit string) string {
+	u := strings.ToLower(unit)
+	switch {
+	case strings.Contains(u, "degrees"):
+		return "temperature"
+	case u == "rpm":
+		return "fan"
+	case strings.HasPrefix(u, "watt"):
+		return "power"
+	case strings.HasPrefix(u, "volt"):
+		return "voltage"
+	case strings.HasPrefix(u, "amp"):
+		return "current"
+	case u == "percent" || u == "%":
+		return "utilization"
+	}
+	return "other"
+}
+
+func (m *Instance) initIpmitool() error {
+	if m.IpmitoolPath == "" {
+		m.IpmitoolPath = defaultIpmitoolPath
+	}
+	if m.IpmitoolInterface == "" {
+		m.IpmitoolInterface = defaultIpmitoolInterface
+	}
+	if m.IpmitoolInterface != "lan" && m.IpmitoolInterface != "lanplus" {
+		return fmt.Errorf("ipmitool_interface %s is not supported, must be lan or lanplus", m.IpmitoolInterface)
+	}
+	if m.IpmitoolTimeout == 0 {
+		m.IpmitoolTimeout = config.Duration(defaultIpmitoolTimeout)
+	}
+	return nil
+}
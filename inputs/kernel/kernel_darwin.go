@@ -0,0 +1,59 @@
+//go:build darwin
+// +build darwin
+
+package kernel
+
+import (
+	"log"
+
+	"golang.org/x/sys/unix"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "kernel"
+
+// KernelStats reads the darwin sysctl counterparts of /proc/stat,
+// interrupts and entropy are not exposed by xnu
+type KernelStats struct {
+	config.PluginConfig
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &KernelStats{}
+	})
+}
+
+func (s *KernelStats) Clone() inputs.Input {
+	return &KernelStats{}
+}
+
+func (s *KernelStats) Name() string {
+	return inputName
+}
+
+func (s *KernelStats) Gather(slist *types.SampleList) {
+	fields := make(map[string]interface{})
+
+	tv, err := unix.SysctlTimeval("kern.boottime")
+	if err != nil {
+		log.Println("E! failed to read sysctl kern.boottime:", err)
+	} else {
+		fields["boot_time"] = tv.Sec
+	}
+
+	if maxproc, err := unix.SysctlUint32("kern.maxproc"); err == nil {
+		fields["maxproc"] = maxproc
+	}
+	if maxfiles, err := unix.SysctlUint32("kern.maxfiles"); err == nil {
+		fields["maxfiles"] = maxfiles
+	}
+	if numfiles, err := unix.SysctlUint32("kern.num_files"); err == nil {
+		fields["num_files"] = numfiles
+	}
+
+	slist.PushSamples(inputName, fields)
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/url"
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
@@ -27,11 +26,14 @@ type Instance struct {
 	config.InstanceConfig
 	Server            string        `toml:"server"`
 	Dialect           string        `toml:"dialect"`
+	BindMethod        string        `toml:"bind_method"`
 	BindDn            string        `toml:"bind_dn"`
 	BindPassword      config.Secret `toml:"bind_password"`
 	ReverseFieldNames bool          `toml:"reverse_field_names"`
 	commontls.ClientConfig
 
+	Replication []*ReplicationPair `toml:"replication"`
+
 	tlsCfg   *tls.Config
 	requests []request
 	mode     string
@@ -39,6 +41,11 @@ type Instance struct {
 	port     string
 }
 
+const (
+	bindSimple   = "simple"
+	bindExternal = "external"
+)
+
 type request struct {
 	query   *ldap.SearchRequest
 	convert func(*ldap.SearchResult, time.Time) []types.Metric
@@ -50,34 +57,46 @@ func (ins *Instance) Init() error {
 		//ins.Server = "ldap://localhost:389"
 	}
 
-	u, err := url.Parse(ins.Server)
+	// Verify the server setting and set the defaults
+	e, u, err := parseEndpoint(ins.Server)
 	if err != nil {
-		return fmt.Errorf("parsing server failed: %w", err)
+		return err
 	}
+	ins.UseTLS = e.mode != "ldap"
+	ins.mode = e.mode
+	ins.Server = e.addr
+	ins.host, ins.port = u.Hostname(), u.Port()
 
-	// Verify the server setting and set the defaults
-	switch u.Scheme {
-	case "ldap":
-		if u.Port() == "" {
-			u.Host = u.Host + ":389"
+	switch ins.BindMethod {
+	case "":
+		ins.BindMethod = bindSimple
+	case bindSimple:
+	case bindExternal:
+		// SASL EXTERNAL authenticates with the TLS client certificate
+		if ins.TLSCert == "" || ins.TLSKey == "" {
+			return errors.New("bind_method external requires tls_cert and tls_key")
 		}
-		ins.UseTLS = false
-	case "starttls":
-		if u.Port() == "" {
-			u.Host = u.Host + ":389"
+	default:
+		return fmt.Errorf("invalid bind_method %q", ins.BindMethod)
+	}
+
+	for _, p := range ins.Replication {
+		if p.BaseDN == "" {
+			return fmt.Errorf("replication of %s and %s requires base_dn", p.Provider, p.Consumer)
 		}
-		ins.UseTLS = true
-	case "ldaps":
-		if u.Port() == "" {
-			u.Host = u.Host + ":636"
+		if p.provider, _, err = parseEndpoint(p.Provider); err != nil {
+			return fmt.Errorf("invalid replication provider: %w", err)
+		}
+		if p.consumer, _, err = parseEndpoint(p.Consumer); err != nil {
+			return fmt.Errorf("invalid replication consumer: %w", err)
+		}
+		if p.provider.mode != "ldap" || p.consumer.mode != "ldap" {
+			ins.UseTLS = true
 		}
-		ins.UseTLS = true
-	default:
-		return fmt.Errorf("invalid scheme: %q", u.Scheme)
 	}
-	ins.mode = u.Scheme
-	ins.Server = u.Host
-	ins.host, ins.port = u.Hostname(), u.Port()
+	if ins.BindMethod == bindExternal && !ins.UseTLS {
+		return errors.New("bind_method external requires a ldaps:// or starttls:// server")
+	}
 
 	// Setup TLS configuration
 	tlsCfg, err := ins.ClientConfig.TLSConfig()
@@ -100,16 +119,38 @@ func (ins *Instance) Init() error {
 	return nil
 }
 
+// Gather pushes ldap_up, ldap_bind_failed and ldap_monitor_missing so that a server which
+// can't be reached, rejects the credentials or has no monitor backend can be told apart
 func (ins *Instance) Gather(slist *types.SampleList) {
-	conn, err := ins.connect()
+	tags := map[string]string{
+		"server": ins.host,
+		"port":   ins.port,
+	}
+
+	conn, err := ins.dial(endpoint{mode: ins.mode, addr: ins.Server})
 	if err != nil {
 		log.Println("E! failed to connect the server:", ins.Server, "error:", err)
+		slist.PushSample(inputName, "up", 0, tags)
 		return
 	}
 	defer conn.Close()
+	slist.PushSample(inputName, "up", 1, tags)
+
+	if err := ins.bind(conn); err != nil {
+		log.Println("E! failed to bind the server:", ins.Server, "error:", err)
+		slist.PushSample(inputName, "bind_failed", 1, tags)
+		return
+	}
+	slist.PushSample(inputName, "bind_failed", 0, tags)
 
+	missing := 0
 	for _, req := range ins.requests {
 		result, err := conn.Search(req.query)
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) || (err == nil && len(result.Entries) == 0) {
+			log.Println("E! the monitor backend is not enabled on the server:", ins.Server)
+			missing = 1
+			continue
+		}
 		if err != nil {
 			log.Println("E! failed to search the server:", ins.Server, "error:", err)
 			continue
@@ -121,6 +162,9 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		}
 		slist.PushFrontN(s)
 	}
+	slist.PushSample(inputName, "monitor_missing", missing, tags)
+
+	ins.gatherReplication(slist)
 }
 
 func (ins *Instance) gather(req request, result *ldap.SearchResult) ([]*types.Sample, error) {
@@ -141,50 +185,61 @@ func (ins *Instance) gather(req request, result *ldap.SearchResult) ([]*types.Sa
 	return samples, nil
 }
 
-func (ins *Instance) connect() (*ldap.Conn, error) {
+func (ins *Instance) dial(e endpoint) (*ldap.Conn, error) {
 	var conn *ldap.Conn
-	switch ins.mode {
+	switch e.mode {
 	case "ldap":
 		var err error
-		conn, err = ldap.Dial("tcp", ins.Server)
+		conn, err = ldap.Dial("tcp", e.addr)
 		if err != nil {
 			return nil, err
 		}
 	case "ldaps":
 		var err error
-		conn, err = ldap.DialTLS("tcp", ins.Server, ins.tlsCfg)
+		conn, err = ldap.DialTLS("tcp", e.addr, ins.tlsCfg)
 		if err != nil {
 			return nil, err
 		}
 	case "starttls":
 		var err error
-		conn, err = ldap.Dial("tcp", ins.Server)
+		conn, err = ldap.Dial("tcp", e.addr)
 		if err != nil {
 			return nil, err
 		}
 		if err := conn.StartTLS(ins.tlsCfg); err != nil {
+			conn.Close()
 			return nil, err
 		}
 	default:
-		return nil, fmt.Errorf("invalid tls_mode: %s", ins.mode)
+		return nil, fmt.Errorf("invalid tls_mode: %s", e.mode)
+	}
+	return conn, nil
+}
+
+func (ins *Instance) bind(conn *ldap.Conn) error {
+	if ins.BindMethod == bindExternal {
+		if err := conn.ExternalBind(); err != nil {
+			return fmt.Errorf("binding with the client certificate failed: %w", err)
+		}
+		return nil
 	}
 
 	if ins.BindDn == "" && ins.BindPassword.Empty() {
-		return conn, nil
+		return nil
 	}
 
 	// Bind username and password
 	passwd, err := ins.BindPassword.Get()
 	if err != nil {
-		return nil, fmt.Errorf("getting password failed: %w", err)
+		return fmt.Errorf("getting password failed: %w", err)
 	}
 	defer passwd.Destroy()
 
 	if err := conn.Bind(ins.BindDn, passwd.String()); err != nil {
-		return nil, fmt.Errorf("binding credentials failed: %w", err)
+		return fmt.Errorf("binding credentials failed: %w", err)
 	}
 
-	return conn, nil
+	return nil
 }
 
 func init() {
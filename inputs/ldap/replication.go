@@ -0,0 +1,147 @@
+package ldap
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// csnTimeLayout is the timestamp of a CSN, the fractional seconds of OpenLDAP 2.4+ are optional when parsing
+const csnTimeLayout = "20060102150405Z"
+
+// ReplicationPair is a provider and one of its consumers, the lag is the difference of the
+// contextCSN of the suffix on both servers
+type ReplicationPair struct {
+	Provider string `toml:"provider"`
+	Consumer string `toml:"consumer"`
+	BaseDN   string `toml:"base_dn"`
+
+	provider endpoint
+	consumer endpoint
+}
+
+type endpoint struct {
+	mode string
+	addr string
+}
+
+// parseEndpoint parses a ldap://, ldaps:// or starttls:// url, adding the default port
+func parseEndpoint(server string) (endpoint, *url.URL, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return endpoint{}, nil, fmt.Errorf("parsing server failed: %w", err)
+	}
+	switch u.Scheme {
+	case "ldap", "starttls":
+		if u.Port() == "" {
+			u.Host = u.Host + ":389"
+		}
+	case "ldaps":
+		if u.Port() == "" {
+			u.Host = u.Host + ":636"
+		}
+	default:
+		return endpoint{}, nil, fmt.Errorf("invalid scheme: %q", u.Scheme)
+	}
+	return endpoint{mode: u.Scheme, addr: u.Host}, u, nil
+}
+
+// parseCSN parses a change sequence number, e.g. 20231120081245.123456Z#000000#001#000000,
+// into its timestamp and the server id which made the change
+func parseCSN(csn string) (time.Time, string, error) {
+	parts := strings.Split(csn, "#")
+	if len(parts) != 4 {
+		return time.Time{}, "", fmt.Errorf("invalid csn %q", csn)
+	}
+	ts, err := time.Parse(csnTimeLayout, parts[0])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid csn timestamp %q: %v", csn, err)
+	}
+	return ts, parts[2], nil
+}
+
+// contextCSNs returns the latest change of each server id on the suffix
+func (ins *Instance) contextCSNs(e endpoint, baseDN string) (map[string]time.Time, error) {
+	conn, err := ins.dial(e)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := ins.bind(conn); err != nil {
+		return nil, err
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectClass=*)",
+		[]string{"contextCSN"},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	csns := make(map[string]time.Time)
+	for _, entry := range result.Entries {
+		for _, v := range entry.GetAttributeValues("contextCSN") {
+			ts, sid, err := parseCSN(v)
+			if err != nil {
+				return nil, err
+			}
+			if ts.After(csns[sid]) {
+				csns[sid] = ts
+			}
+		}
+	}
+	if len(csns) == 0 {
+		return nil, fmt.Errorf("no contextCSN on %s", baseDN)
+	}
+	return csns, nil
+}
+
+// gatherReplication pushes the lag of the consumer for each server id of the provider
+func (ins *Instance) gatherReplication(slist *types.SampleList) {
+	for _, p := range ins.Replication {
+		tags := map[string]string{
+			"provider": p.Provider,
+			"consumer": p.Consumer,
+			"base_dn":  p.BaseDN,
+		}
+		providerCSNs, err := ins.contextCSNs(p.provider, p.BaseDN)
+		if err != nil {
+			log.Println("E! failed to get the contextCSN of provider:", p.Provider, "error:", err)
+			slist.PushSample(inputName, "replication_up", 0, tags)
+			continue
+		}
+		consumerCSNs, err := ins.contextCSNs(p.consumer, p.BaseDN)
+		if err != nil {
+			log.Println("E! failed to get the contextCSN of consumer:", p.Consumer, "error:", err)
+			slist.PushSample(inputName, "replication_up", 0, tags)
+			continue
+		}
+		slist.PushSample(inputName, "replication_up", 1, tags)
+
+		for sid, pts := range providerCSNs {
+			cts, has := consumerCSNs[sid]
+			if !has {
+				log.Printf("W! consumer %s has no contextCSN of server id %s on %s", p.Consumer, sid, p.BaseDN)
+				continue
+			}
+			lag := pts.Sub(cts).Seconds()
+			if lag < 0 {
+				lag = 0
+			}
+			slist.PushSample(inputName, "replication_lag_seconds", lag, tags, map[string]string{"sid": sid})
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCSN(t *testing.T) {
+	cases := []struct {
+		csn  string
+		ts   time.Time
+		sid  string
+		fail bool
+	}{
+		{"20231120081245.123456Z#000000#001#000000", time.Date(2023, 11, 20, 8, 12, 45, 123456000, time.UTC), "001", false},
+		// OpenLDAP 2.3 has no fractional seconds
+		{"20070301200000Z#000001#00#000000", time.Date(2007, 3, 1, 20, 0, 0, 0, time.UTC), "00", false},
+		{"20231120081245.123456Z#000000#001", time.Time{}, "", true},
+		{"2023-11-20T08:12:45Z#000000#001#000000", time.Time{}, "", true},
+		{"", time.Time{}, "", true},
+	}
+	for _, c := range cases {
+		ts, sid, err := parseCSN(c.csn)
+		if c.fail {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.csn)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.csn, err)
+			continue
+		}
+		if !ts.Equal(c.ts) || sid != c.sid {
+			t.Errorf("%q: expected %s sid %s, got %s sid %s", c.csn, c.ts, c.sid, ts, sid)
+		}
+	}
+
+	// the lag is the difference of the timestamps
+	provider, _, _ := parseCSN("20231120081245.500000Z#000000#001#000000")
+	consumer, _, _ := parseCSN("20231120081240.250000Z#000003#001#000000")
+	if lag := provider.Sub(consumer); lag != 5250*time.Millisecond {
+		t.Errorf("expected a lag of 5.25s, got %s", lag)
+	}
+}
@@ -58,6 +58,7 @@ type Opts struct {
 	CollectAll                    bool
 	EnableDBStats                 bool
 	EnableDiagnosticData          bool
+	EnableServerStatus            bool
 	EnableReplicasetStatus        bool
 	EnableTopMetrics              bool
 	EnableIndexStats              bool
@@ -168,6 +169,11 @@ func (e *Exporter) initCollectors(ctx context.Context, client *mongo.Client) err
 		ddc := newDiagnosticDataCollector(ctx, client, e.opts.Logger,
 			e.opts.CompatibleMode, topologyInfo)
 		e.cs = append(e.cs, ddc)
+	} else if e.opts.EnableServerStatus {
+		// getDiagnosticData already includes the serverStatus metrics
+		ssc := newServerStatusCollector(ctx, client, e.opts.Logger,
+			e.opts.CompatibleMode, topologyInfo)
+		e.cs = append(e.cs, ssc)
 	}
 
 	// If we manually set the collection names we want or auto discovery is set.
@@ -208,6 +214,9 @@ func (e *Exporter) initCollectors(ctx context.Context, client *mongo.Client) err
 		rsgsc := newReplicationSetStatusCollector(ctx, client, e.opts.Logger,
 			e.opts.CompatibleMode, topologyInfo)
 		e.cs = append(e.cs, rsgsc)
+
+		rlc := newReplicationLagCollector(ctx, client, e.opts.Logger, topologyInfo)
+		e.cs = append(e.cs, rlc)
 	}
 
 	return nil
@@ -0,0 +1,173 @@
+package exporter
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	memberStatePrimary = 1
+	memberStateArbiter = 7
+)
+
+// replMember is a member of the members array of replSetGetStatus
+type replMember struct {
+	Name       string    `bson:"name"`
+	Health     float64   `bson:"health"`
+	State      int       `bson:"state"`
+	StateStr   string    `bson:"stateStr"`
+	OptimeDate time.Time `bson:"optimeDate"`
+}
+
+type oplogStats struct {
+	Size    float64 `bson:"size"`
+	MaxSize float64 `bson:"maxSize"`
+}
+
+type oplogEntry struct {
+	Timestamp primitive.Timestamp `bson:"ts"`
+}
+
+// replicationLagCollector computes the lag of the replica set members from replSetGetStatus
+// and the oplog window of the monitored node.
+type replicationLagCollector struct {
+	ctx  context.Context
+	base *baseCollector
+
+	topologyInfo labelsGetter
+}
+
+// newReplicationLagCollector creates a collector for the replication lag and the oplog window.
+func newReplicationLagCollector(ctx context.Context, client *mongo.Client, logger *logrus.Logger, topology labelsGetter) *replicationLagCollector {
+	return &replicationLagCollector{
+		ctx:  ctx,
+		base: newBaseCollector(client, logger),
+
+		topologyInfo: topology,
+	}
+}
+
+func (d *replicationLagCollector) Describe(ch chan<- *prometheus.Desc) {
+	d.base.Describe(d.ctx, ch, d.collect)
+}
+
+func (d *replicationLagCollector) Collect(ch chan<- prometheus.Metric) {
+	d.base.Collect(ch, d.collect)
+}
+
+func (d *replicationLagCollector) collect(ch chan<- prometheus.Metric) {
+	logger := d.base.logger
+	client := d.base.client
+
+	var status struct {
+		Members []replMember `bson:"members"`
+	}
+	cmd := bson.D{{Key: "replSetGetStatus", Value: "1"}}
+	if err := client.Database("admin").RunCommand(d.ctx, cmd).Decode(&status); err != nil {
+		if e, ok := err.(mongo.CommandError); ok {
+			if e.Code == replicationNotYetInitialized || e.Code == replicationNotEnabled {
+				return
+			}
+		}
+		logger.Errorf("cannot get replSetGetStatus: %s", err)
+		return
+	}
+
+	lagDesc := prometheus.NewDesc("mongodb_repl_lag_seconds",
+		"Replication lag of the member behind the primary, computed from the optimeDate of both members.",
+		[]string{"member", "member_state"}, d.topologyInfo.baseLabels())
+	lags := replicationLags(status.Members)
+	for _, m := range status.Members {
+		if lag, ok := lags[m.Name]; ok {
+			ch <- prometheus.MustNewConstMetric(lagDesc, prometheus.GaugeValue, lag, m.Name, m.StateStr)
+		}
+	}
+
+	window, err := d.oplogWindow()
+	if err != nil {
+		logger.Errorf("cannot get the oplog window: %s", err)
+		return
+	}
+	windowDesc := prometheus.NewDesc("mongodb_oplog_window_seconds",
+		"Time range the oplog covers once full at the current write rate of the oplog.",
+		nil, d.topologyInfo.baseLabels())
+	ch <- prometheus.MustNewConstMetric(windowDesc, prometheus.GaugeValue, window)
+}
+
+// oplogWindow reads the first and last entries and the size of local.oplog.rs
+func (d *replicationLagCollector) oplogWindow() (float64, error) {
+	local := d.base.client.Database("local")
+
+	var stats oplogStats
+	if err := local.RunCommand(d.ctx, bson.D{{Key: "collStats", Value: "oplog.rs"}}).Decode(&stats); err != nil {
+		return 0, err
+	}
+
+	var first, last oplogEntry
+	coll := local.Collection("oplog.rs")
+	projection := bson.D{{Key: "ts", Value: 1}}
+	firstOpts := options.FindOne().SetProjection(projection).SetSort(bson.D{{Key: "$natural", Value: 1}})
+	if err := coll.FindOne(d.ctx, bson.D{}, firstOpts).Decode(&first); err != nil {
+		return 0, err
+	}
+	lastOpts := options.FindOne().SetProjection(projection).SetSort(bson.D{{Key: "$natural", Value: -1}})
+	if err := coll.FindOne(d.ctx, bson.D{}, lastOpts).Decode(&last); err != nil {
+		return 0, err
+	}
+
+	return estimateOplogWindow(float64(last.Timestamp.T)-float64(first.Timestamp.T), stats.Size, stats.MaxSize), nil
+}
+
+// replicationLags returns the lag in seconds of the healthy data bearing members behind the
+// primary by member name. The lag is the difference between the optimeDate of the primary and
+// the one of the member, both reported by the same node, rather than the difference with the
+// clock of the monitored node, which would count the idle time of the replica set as lag.
+// It returns nil while the replica set has no primary.
+func replicationLags(members []replMember) map[string]float64 {
+	var primary *replMember
+	for i := range members {
+		if members[i].State == memberStatePrimary {
+			primary = &members[i]
+			break
+		}
+	}
+	if primary == nil {
+		return nil
+	}
+
+	lags := make(map[string]float64, len(members))
+	for _, m := range members {
+		// the optime of an unreachable member is the last one heard of, arbiters hold no data
+		if m.State == memberStateArbiter || m.Health != 1 {
+			continue
+		}
+		lag := primary.OptimeDate.Sub(m.OptimeDate).Seconds()
+		if lag < 0 {
+			// the member applied writes the primary has not reported yet
+			lag = 0
+		}
+		lags[m.Name] = lag
+	}
+	return lags
+}
+
+// estimateOplogWindow returns the time range the oplog covers once it reaches maxSize, as long
+// as the oplog is not full the write rate of the current entries is extrapolated to maxSize
+func estimateOplogWindow(timeDiff, size, maxSize float64) float64 {
+	if timeDiff <= 0 {
+		return 0
+	}
+	if size <= 0 || maxSize <= size {
+		return timeDiff
+	}
+	return timeDiff * maxSize / size
+}
+
+var _ prometheus.Collector = (*replicationLagCollector)(nil)
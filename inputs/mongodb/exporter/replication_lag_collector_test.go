@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplicationLags(t *testing.T) {
+	now := time.Date(2023, 11, 20, 8, 0, 0, 0, time.UTC)
+	members := []replMember{
+		{Name: "rs0:27017", Health: 1, State: memberStatePrimary, OptimeDate: now},
+		{Name: "rs1:27017", Health: 1, State: 2, OptimeDate: now.Add(-5 * time.Second)},
+		{Name: "rs2:27017", Health: 1, State: 2, OptimeDate: now.Add(time.Second)},
+		{Name: "rs3:27017", Health: 0, State: 8, OptimeDate: now.Add(-time.Hour)},
+		{Name: "arbiter:27017", Health: 1, State: memberStateArbiter},
+	}
+
+	lags := replicationLags(members)
+	expected := map[string]float64{"rs0:27017": 0, "rs1:27017": 5, "rs2:27017": 0}
+	if len(lags) != len(expected) {
+		t.Fatalf("expected lags %v, got %v", expected, lags)
+	}
+	for name, lag := range expected {
+		if got, ok := lags[name]; !ok || got != lag {
+			t.Errorf("expected lag %v of %s, got %v", lag, name, got)
+		}
+	}
+
+	if lags := replicationLags(members[1:]); lags != nil {
+		t.Errorf("expected no lags without primary, got %v", lags)
+	}
+}
+
+func TestEstimateOplogWindow(t *testing.T) {
+	tests := []struct {
+		timeDiff, size, maxSize float64
+		expected                float64
+	}{
+		{3600, 512, 1024, 7200},
+		{3600, 1024, 1024, 3600},
+		{3600, 1100, 1024, 3600},
+		{0, 512, 1024, 0},
+	}
+	for _, tt := range tests {
+		if got := estimateOplogWindow(tt.timeDiff, tt.size, tt.maxSize); got != tt.expected {
+			t.Errorf("estimateOplogWindow(%v, %v, %v) = %v, expected %v", tt.timeDiff, tt.size, tt.maxSize, got, tt.expected)
+		}
+	}
+}
@@ -71,6 +71,7 @@ type Instance struct {
 	CollectAll                    bool     `toml:"collect_all,omitempty"`
 	EnableDBStats                 bool     `toml:"enable_db_stats,omitempty"`
 	EnableDiagnosticData          bool     `toml:"enable_diagnostic_data,omitempty"`
+	EnableServerStatus            bool     `toml:"enable_server_status,omitempty"`
 	EnableReplicasetStatus        bool     `toml:"enable_replicaset_status,omitempty"`
 	EnableTopMetrics              bool     `toml:"enable_top_metrics,omitempty"`
 	EnableIndexStats              bool     `toml:"enable_index_stats,omitempty"`
@@ -109,6 +110,7 @@ func (ins *Instance) Init() error {
 		CollectAll:                    ins.CollectAll,
 		EnableDBStats:                 ins.EnableDBStats,
 		EnableDiagnosticData:          ins.EnableDiagnosticData,
+		EnableServerStatus:            ins.EnableServerStatus,
 		EnableReplicasetStatus:        ins.EnableReplicasetStatus,
 		EnableTopMetrics:              ins.EnableTopMetrics,
 		EnableIndexStats:              ins.EnableIndexStats,
@@ -1,9 +1,12 @@
 package net
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
+	"os/exec"
+	"time"
 
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/inputs"
@@ -25,6 +28,12 @@ type NetIOStats struct {
 
 	EnableLoopbackStats bool `toml:"enable_loopback_stats"`
 	EnableLinkDownStats bool `toml:"enable_link_down_stats"`
+
+	// the counters of the drivers read with ethtool -S, selected by nic_stats
+	CollectNICStats bool     `toml:"collect_nic_stats"`
+	NICStats        []string `toml:"nic_stats"`
+
+	nicStats *filter.Matcher
 }
 
 func init() {
@@ -56,6 +65,20 @@ func (s *NetIOStats) Init() error {
 		}
 	}
 
+	if s.CollectNICStats {
+		if _, err := exec.LookPath("ethtool"); err != nil {
+			log.Println("W! collect_nic_stats is ignored, ethtool is not found:", err)
+			s.CollectNICStats = false
+		}
+		if len(s.NICStats) == 0 {
+			s.NICStats = defaultNICStats
+		}
+		s.nicStats, err = filter.NewMatcher(s.NICStats)
+		if err != nil {
+			return fmt.Errorf("error compiling nic_stats: %s", err)
+		}
+	}
+
 	return nil
 }
 
@@ -128,5 +151,36 @@ func (s *NetIOStats) Gather(slist *types.SampleList) {
 		}
 
 		slist.PushSamples(inputName, fields, tags)
+
+		// the speed and the duplex are unknown when the link is down
+		if err == nil && speed > 0 {
+			slist.PushSample(inputName, "interface_speed_mbps", speed, tags)
+		}
+		if duplex, err := Duplex(iface.Name); err == nil {
+			if v, ok := duplexValue(duplex); ok {
+				slist.PushSample(inputName, "interface_duplex", v, tags)
+			}
+		}
+		if s.CollectNICStats {
+			s.gatherNICStats(slist, iface.Name, tags)
+		}
+	}
+}
+
+func (s *NetIOStats) gatherNICStats(slist *types.SampleList, iface string, tags map[string]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stats, err := NICStats(ctx, iface)
+	if err != nil {
+		// the virtual interfaces have no driver counters
+		if config.Config.DebugMode {
+			log.Println("D! failed to run ethtool -S", iface, ":", err)
+		}
+		return
+	}
+	for name, v := range stats {
+		if s.nicStats.Match(name) {
+			slist.PushSample(inputName, "interface_"+nicStatName(name)+"_total", v, tags)
+		}
 	}
 }
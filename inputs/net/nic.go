@@ -0,0 +1,65 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// defaultNICStats are the error counters of `ethtool -S` collected by default, they are
+// invisible from the counters of the kernel of the interface
+var defaultNICStats = []string{
+	"rx_missed_errors",
+	"rx_crc_errors",
+	"rx_fifo_errors",
+	"rx_over_errors",
+	"rx_length_errors",
+	"rx_dropped",
+	"tx_dropped",
+	"tx_errors",
+	"tx_carrier_errors",
+	"tx_fifo_errors",
+}
+
+// parseNICStats parses the output of `ethtool -S <iface>`, the lines after the
+// "NIC statistics:" header are "name: value"
+func parseNICStats(out []byte) map[string]uint64 {
+	stats := make(map[string]uint64)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if name == "" || err != nil {
+			continue
+		}
+		stats[name] = v
+	}
+	return stats
+}
+
+// duplexValue returns 1 for a full duplex link, 0 for half duplex, false if unknown,
+// e.g. for a link down or a virtual interface
+func duplexValue(duplex string) (int, bool) {
+	switch strings.TrimSpace(duplex) {
+	case "full":
+		return 1, true
+	case "half":
+		return 0, true
+	}
+	return 0, false
+}
+
+// nicStatName sanitizes the name of a driver counter of ethtool, e.g. tx-0.packets
+func nicStatName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
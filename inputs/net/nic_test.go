@@ -0,0 +1,34 @@
+package net
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNICStats(t *testing.T) {
+	out := []byte(`NIC statistics:
+     rx_packets: 1234567
+     tx_dropped: 0
+     rx_missed_errors: 42
+     tx-0.packets: 7
+     rx_queue_0_bytes: not a number
+`)
+	want := map[string]uint64{"rx_packets": 1234567, "tx_dropped": 0, "rx_missed_errors": 42, "tx-0.packets": 7}
+	if got := parseNICStats(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if name := nicStatName("tx-0.packets"); name != "tx_0_packets" {
+		t.Fatalf("unexpected name %s", name)
+	}
+}
+
+func TestDuplexValue(t *testing.T) {
+	for duplex, want := range map[string]int{"full\n": 1, "half": 0} {
+		if v, ok := duplexValue(duplex); !ok || v != want {
+			t.Errorf("%q: got %v %v, want %v", duplex, v, ok, want)
+		}
+	}
+	if _, ok := duplexValue("unknown"); ok {
+		t.Error("expected the unknown duplex to be skipped")
+	}
+}
@@ -5,6 +5,7 @@ package net
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -66,3 +67,22 @@ func readLine(r *bufio.Reader) ([]byte, error) {
 
 	return line, err
 }
+
+// Duplex returns the duplex of the link of the interface, full or half, unknown when the
+// link is down
+func Duplex(iface string) (string, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/sys/class/net/%s/duplex", iface))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// NICStats returns the counters of the driver of the interface, from `ethtool -S`
+func NICStats(ctx context.Context, iface string) (map[string]uint64, error) {
+	out, err := exec.CommandContext(ctx, "ethtool", "-S", iface).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseNICStats(out), nil
+}
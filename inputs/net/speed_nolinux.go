@@ -2,6 +2,19 @@
 
 package net
 
+import (
+	"context"
+	"errors"
+)
+
 func Speed(iface string) (int64, error) {
 	return 0, nil
 }
+
+func Duplex(iface string) (string, error) {
+	return "", errors.New("duplex is only supported on linux")
+}
+
+func NICStats(ctx context.Context, iface string) (map[string]uint64, error) {
+	return nil, errors.New("ethtool is only supported on linux")
+}
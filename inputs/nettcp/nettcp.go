@@ -0,0 +1,166 @@
+package nettcp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/osx"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "nettcp"
+
+// tcpStates are the states of include/net/tcp_states.h by their value in the st column
+// of /proc/net/tcp, the request sockets are listed as SYN_RECV
+var tcpStates = [...]string{
+	1:  "ESTABLISHED",
+	2:  "SYN_SENT",
+	3:  "SYN_RECV",
+	4:  "FIN_WAIT1",
+	5:  "FIN_WAIT2",
+	6:  "TIME_WAIT",
+	7:  "CLOSE",
+	8:  "CLOSE_WAIT",
+	9:  "LAST_ACK",
+	10: "LISTEN",
+	11: "CLOSING",
+}
+
+type NetTCP struct {
+	config.PluginConfig
+
+	// also count the connections of these local ports by state
+	TrackPorts []int `toml:"track_ports"`
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &NetTCP{}
+	})
+}
+
+func (n *NetTCP) Clone() inputs.Input {
+	return &NetTCP{}
+}
+
+func (n *NetTCP) Name() string {
+	return inputName
+}
+
+func (n *NetTCP) Init() error {
+	for _, port := range n.TrackPorts {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d of track_ports", port)
+		}
+	}
+	return nil
+}
+
+// connCounts are the connections by state, and by local port and state for the tracked ports
+type connCounts struct {
+	states [len(tcpStates)]uint64
+	ports  map[uint16]*[len(tcpStates)]uint64
+}
+
+func newConnCounts(trackPorts []int) *connCounts {
+	c := &connCounts{ports: make(map[uint16]*[len(tcpStates)]uint64, len(trackPorts))}
+	for _, port := range trackPorts {
+		c.ports[uint16(port)] = new([len(tcpStates)]uint64)
+	}
+	return c
+}
+
+func (n *NetTCP) Gather(slist *types.SampleList) {
+	counts := newConnCounts(n.TrackPorts)
+	for _, name := range []string{"tcp", "tcp6"} {
+		err := readConnections(filepath.Join(osx.GetHostProc(), "net", name), counts)
+		if err == nil {
+			continue
+		}
+		// /proc/net/tcp6 is missing with ipv6 disabled
+		if name == "tcp6" && errors.Is(err, os.ErrNotExist) {
+			if n.DebugMod {
+				log.Println("D! nettcp: skip /proc/net/tcp6:", err)
+			}
+			continue
+		}
+		log.Println("E! nettcp: failed to read connections:", err)
+		return
+	}
+
+	for st, state := range tcpStates {
+		if state == "" {
+			continue
+		}
+		slist.PushSample(inputName, "connections_by_state", counts.states[st], map[string]string{"state": state})
+	}
+	for port, states := range counts.ports {
+		p := strconv.Itoa(int(port))
+		for st, state := range tcpStates {
+			if state == "" {
+				continue
+			}
+			slist.PushSample(inputName, "port_connections_by_state", states[st], map[string]string{"port": p, "state": state})
+		}
+	}
+}
+
+func readConnections(path string, counts *connCounts) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := parseConnections(f, counts); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// parseConnections counts the connections of /proc/net/tcp or /proc/net/tcp6:
+//
+//	sl  local_address rem_address   st tx_queue rx_queue ...
+//	 0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 ...
+//
+// the tables are large on busy hosts, so only the local port and the state are parsed
+func parseConnections(r io.Reader, counts *connCounts) error {
+	scanner := bufio.NewScanner(r)
+	// skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		st, err := strconv.ParseUint(fields[3], 16, 8)
+		if err != nil || st >= uint64(len(tcpStates)) || tcpStates[st] == "" {
+			continue
+		}
+		counts.states[st]++
+
+		if len(counts.ports) == 0 {
+			continue
+		}
+		i := strings.LastIndexByte(fields[1], ':')
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(fields[1][i+1:], 16, 16)
+		if err != nil {
+			continue
+		}
+		if states, ok := counts.ports[uint16(port)]; ok {
+			states[st]++
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,41 @@
+package nettcp
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseConnections(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 20425 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 31337 1 0000000000000000 100 0 0 10 0
+   2: 0202000A:0016 0101000A:D6D8 01 00000000:00000000 02:0009C9E1 00000000     0        0 40125 4 0000000000000000 20 4 31 10 -1
+   3: 0202000A:0016 0101000A:D6DA 03 00000000:00000000 02:0009C9E1 00000000     0        0 0 0 0000000000000000 20 4 31 10 -1
+   4: 0202000A:C350 0301000A:0050 06 00000000:00000000 03:00001770 00000000     0        0 0 3 0000000000000000
+   5: 0202000A:0016 0101000A:D6DC 0F 00000000:00000000 00:00000000 00000000     0        0 0 0
+`
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 20427 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000202000A:0016 0000000000000000FFFF00000101000A:D6E0 08 00000000:00000000 00:00000000 00000000     0        0 40130 1 0000000000000000 20 4 30 10 -1
+`
+	counts := newConnCounts([]int{22, 8080})
+	for _, s := range []string{tcp, tcp6} {
+		if err := parseConnections(strings.NewReader(s), counts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]uint64{"LISTEN": 3, "ESTABLISHED": 1, "SYN_RECV": 1, "TIME_WAIT": 1, "CLOSE_WAIT": 1}
+	for st, state := range tcpStates {
+		if counts.states[st] != want[state] {
+			t.Errorf("%s: expected %d connections, got %d", state, want[state], counts.states[st])
+		}
+	}
+	ssh := counts.ports[22]
+	if ssh[10] != 2 || ssh[1] != 1 || ssh[3] != 1 || ssh[8] != 1 || ssh[6] != 0 {
+		t.Errorf("unexpected connections of port 22: %v", ssh)
+	}
+	if *counts.ports[8080] != [len(tcpStates)]uint64{} {
+		t.Errorf("expected no connection of port 8080, got %v", counts.ports[8080])
+	}
+}
@@ -72,6 +72,8 @@ type Instance struct {
 	Username        string          `toml:"username"`
 	Password        string          `toml:"password"`
 	Headers         []string        `toml:"headers"`
+	// Mode is stub_status, plus or auto (default), auto probes the Plus API and the stub status page
+	Mode string `toml:"mode"`
 
 	// Mappings Set the mapping of extra tags in batches
 	Mappings map[string]map[string]string `toml:"mappings"`
@@ -79,6 +81,21 @@ type Instance struct {
 	tls.ClientConfig
 
 	client *http.Client
+
+	// detected endpoints by url, the Plus API url or the stub status url
+	sync.Mutex
+	endpoints map[string]endpoint
+}
+
+const (
+	modeAuto       = "auto"
+	modeStubStatus = "stub_status"
+	modePlus       = "plus"
+)
+
+type endpoint struct {
+	mode string
+	url  string
 }
 
 func (ins *Instance) Init() error {
@@ -86,6 +103,15 @@ func (ins *Instance) Init() error {
 		return types.ErrInstancesEmpty
 	}
 
+	switch ins.Mode {
+	case "":
+		ins.Mode = modeAuto
+	case modeAuto, modeStubStatus, modePlus:
+	default:
+		return fmt.Errorf("unknown mode %q, should be auto, stub_status or plus", ins.Mode)
+	}
+	ins.endpoints = make(map[string]endpoint)
+
 	if ins.ResponseTimeout < config.Duration(time.Second) {
 		ins.ResponseTimeout = config.Duration(time.Second * 5)
 	}
@@ -162,15 +188,10 @@ func (ins *Instance) createHTTPClient() (*http.Client, error) {
 	return client, nil
 }
 
-func (ins *Instance) gather(addr *url.URL, slist *types.SampleList) error {
-	if ins.DebugMod {
-		log.Println("D! nginx... url:", addr)
-	}
-
-	var body io.Reader
-	request, err := http.NewRequest("GET", addr.String(), body)
+func (ins *Instance) newRequest(u string) (*http.Request, error) {
+	request, err := http.NewRequest("GET", u, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create an HTTP request, url: %s, error: %s", addr.String(), err)
+		return nil, fmt.Errorf("failed to create an HTTP request, url: %s, error: %s", u, err)
 	}
 
 	for i := 0; i < len(ins.Headers); i += 2 {
@@ -183,6 +204,72 @@ func (ins *Instance) gather(addr *url.URL, slist *types.SampleList) error {
 	if ins.Username != "" || ins.Password != "" {
 		request.SetBasicAuth(ins.Username, ins.Password)
 	}
+	return request, nil
+}
+
+// detect returns the endpoint of addr, in auto mode the Plus API is probed first and then the
+// stub status page, an url without path is probed at /api/8, at itself and at /nginx_status
+func (ins *Instance) detect(addr *url.URL) (endpoint, error) {
+	ins.Lock()
+	ep, has := ins.endpoints[addr.String()]
+	ins.Unlock()
+	if has {
+		return ep, nil
+	}
+
+	// an url without path may serve the stub status itself, as with "location / { stub_status; }"
+	stubURLs := []string{addr.String()}
+	if addr.Path == "" || addr.Path == "/" {
+		stubURLs = append(stubURLs, strings.TrimRight(addr.String(), "/")+"/nginx_status")
+	}
+
+	switch ins.Mode {
+	case modeStubStatus:
+		ep = endpoint{mode: modeStubStatus, url: addr.String()}
+	case modePlus:
+		base, ok := plusAPIBase(addr)
+		if !ok {
+			base = strings.TrimRight(addr.String(), "/")
+		}
+		ep = endpoint{mode: modePlus, url: base}
+	default:
+		var errs []string
+		if base, ok := plusAPIBase(addr); ok {
+			var info interface{}
+			if err := ins.getPlusJSON(base+"/nginx", &info); err == nil {
+				ep = endpoint{mode: modePlus, url: base}
+			} else {
+				errs = append(errs, err.Error())
+			}
+		}
+		for _, u := range stubURLs {
+			if ep.mode != "" {
+				break
+			}
+			if err := ins.gatherStubStatus(u, map[string]interface{}{}); err == nil {
+				ep = endpoint{mode: modeStubStatus, url: u}
+			} else {
+				errs = append(errs, err.Error())
+			}
+		}
+		if ep.mode == "" {
+			return ep, fmt.Errorf("failed to detect the nginx status of %s: %s", addr, strings.Join(errs, "; "))
+		}
+		if ins.DebugMod {
+			log.Println("D! nginx url:", addr, "detected mode:", ep.mode, "endpoint:", ep.url)
+		}
+	}
+
+	ins.Lock()
+	ins.endpoints[addr.String()] = ep
+	ins.Unlock()
+	return ep, nil
+}
+
+func (ins *Instance) gather(addr *url.URL, slist *types.SampleList) error {
+	if ins.DebugMod {
+		log.Println("D! nginx... url:", addr)
+	}
 
 	fields := map[string]interface{}{
 		"up": 1,
@@ -195,11 +282,30 @@ func (ins *Instance) gather(addr *url.URL, slist *types.SampleList) error {
 		}
 	}
 
+	ep, err := ins.detect(addr)
+	if err == nil {
+		if ep.mode == modePlus {
+			err = ins.gatherPlus(ep.url, slist, targetTags(addr, withLabels(tags)))
+		} else {
+			err = ins.gatherStubStatus(ep.url, fields)
+		}
+	}
+	if err != nil {
+		fields = map[string]interface{}{"up": 0}
+	}
+	pushList(addr, slist, fields, tags)
+	return err
+}
+
+func (ins *Instance) gatherStubStatus(u string, fields map[string]interface{}) error {
+	request, err := ins.newRequest(u)
+	if err != nil {
+		return err
+	}
+
 	resp, err := ins.client.Do(request)
 	if err != nil {
-		fields["up"] = 0
-		pushList(addr, slist, fields, tags)
-		return fmt.Errorf("failed to request the url: %s, error: %s", addr.String(), err)
+		return fmt.Errorf("failed to request the url: %s, error: %s", u, err)
 	}
 
 	defer func(Body io.ReadCloser) {
@@ -210,20 +316,18 @@ func (ins *Instance) gather(addr *url.URL, slist *types.SampleList) error {
 	}(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
-		fields["up"] = 0
-		pushList(addr, slist, fields, tags)
-		return fmt.Errorf("the HTTP response status exception, url: %s, status: %s", addr.String(), resp.Status)
+		return fmt.Errorf("the HTTP response status exception, url: %s, status: %s", u, resp.Status)
 	}
 
-	err = parseResponseBody(resp.Body, fields)
-	if err != nil {
-		fields["up"] = 0
-	}
-	pushList(addr, slist, fields, tags)
-	return err
+	return parseResponseBody(resp.Body, fields)
 }
 
 func pushList(addr *url.URL, slist *types.SampleList, fields map[string]interface{}, tags map[string]string) {
+	slist.PushSamples(inputName, fields, targetTags(addr, tags))
+}
+
+// targetTags adds the server, port and target of addr to tags
+func targetTags(addr *url.URL, tags map[string]string) map[string]string {
 	host, port, err := net.SplitHostPort(addr.Host)
 	if err != nil {
 		host = addr.Host
@@ -239,8 +343,7 @@ func pushList(addr *url.URL, slist *types.SampleList, fields map[string]interfac
 	tags["server"] = host
 	tags["port"] = port
 	tags["target"] = addr.String()
-
-	slist.PushSamples(inputName, fields, tags)
+	return tags
 }
 
 func parseResponseBody(body io.ReadCloser, fields map[string]interface{}) error {
@@ -260,6 +363,7 @@ func parseResponseBody(body io.ReadCloser, fields map[string]interface{}) error
 		return fmt.Errorf("failed to parse the response, error: %s", err)
 	} else {
 		fields["active"] = active
+		fields["active_connections"] = active
 	}
 
 	// Server accepts handled requests
@@ -290,6 +394,7 @@ func parseResponseBody(body io.ReadCloser, fields map[string]interface{}) error
 		return fmt.Errorf("failed to parse the response, error: %s", err)
 	} else {
 		fields["requests"] = requests
+		fields["requests_total"] = requests
 	}
 
 	// Reading/Writing/Waiting
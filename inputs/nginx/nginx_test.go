@@ -0,0 +1,122 @@
+package nginx
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/types"
+)
+
+const stubStatus = `Active connections: 291
+server accepts handled requests
+ 16630948 16630948 31070465
+Reading: 6 Writing: 179 Waiting: 106
+`
+
+var plusAPI = map[string]string{
+	"/api/8/nginx":         `{"version":"1.25.1","build":"nginx-plus-r30"}`,
+	"/api/8/connections":   `{"accepted":4968119,"dropped":0,"active":5,"idle":117}`,
+	"/api/8/http/requests": `{"total":10624511,"current":4}`,
+	"/api/8/http/server_zones": `{"hg.nginx.org":{"processing":0,"requests":175276,
+		"responses":{"1xx":0,"2xx":162948,"3xx":10117,"4xx":2125,"5xx":86,"codes":{"200":162948,"404":2125,"502":86},"total":175276},
+		"discarded":15,"received":48403340,"sent":6107030118}}`,
+	"/api/8/http/upstreams": `{"trac-backend":{"peers":[
+		{"id":0,"server":"10.0.0.1:8080","backup":false,"state":"up","active":0,"requests":667231,
+		 "responses":{"1xx":0,"2xx":666310,"3xx":0,"4xx":915,"5xx":6,"total":667231},
+		 "sent":251946292,"received":19222475454,"fails":0,"unavail":0,"health_checks":{"checks":26214,"fails":0,"unhealthy":0},
+		 "header_time":23,"response_time":26},
+		{"id":1,"server":"10.0.0.2:8080","backup":true,"state":"unhealthy","active":0,"requests":0,
+		 "responses":{"total":0},"health_checks":{"checks":26284,"fails":26284,"unhealthy":1}}],
+		"keepalive":0,"zombies":0,"zone":"trac-backend"}}`,
+	"/api/8/http/caches": `{"http_cache":{"size":530915328,"max_size":536870912,"cold":false,
+		"hit":{"responses":254032,"bytes":6685627875},"stale":{"responses":0,"bytes":0},"updating":{"responses":0,"bytes":0},
+		"revalidated":{"responses":0,"bytes":0},"miss":{"responses":1619201,"bytes":53841943822},
+		"expired":{"responses":45859,"bytes":1656847080},"bypass":{"responses":109196,"bytes":3049353972}}}`,
+}
+
+func gatherSamples(t *testing.T, u string) map[string]float64 {
+	ins := &Instance{Urls: []string{u}, Username: "admin", Password: "secret"}
+	if err := ins.Init(); err != nil {
+		t.Fatal(err)
+	}
+	slist := types.NewSampleList()
+	ins.Gather(slist)
+
+	samples := make(map[string]float64)
+	for _, s := range slist.PopBackAll() {
+		key := s.Metric
+		for _, l := range []string{"code", "peer", "status", "cache"} {
+			if v, has := s.Labels[l]; has {
+				key += fmt.Sprintf(",%s=%s", l, v)
+			}
+		}
+		samples[key], _ = conv.ToFloat64(s.Value)
+	}
+	return samples
+}
+
+func TestGatherStubStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nginx_status" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, stubStatus)
+	}))
+	defer ts.Close()
+
+	samples := gatherSamples(t, ts.URL)
+	for metric, value := range map[string]float64{
+		"nginx_up":                 1,
+		"nginx_active_connections": 291,
+		"nginx_requests_total":     31070465,
+		"nginx_reading":            6,
+		"nginx_writing":            179,
+		"nginx_waiting":            106,
+	} {
+		if samples[metric] != value {
+			t.Errorf("%s: expected %v, got %v", metric, value, samples[metric])
+		}
+	}
+}
+
+func TestGatherPlus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, has := plusAPI[r.URL.Path]
+		if !has {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	samples := gatherSamples(t, ts.URL+"/api")
+	for metric, value := range map[string]float64{
+		"nginx_up":                                                       1,
+		"nginx_plus_connections_active":                                  5,
+		"nginx_plus_http_requests_total":                                 10624511,
+		"nginx_plus_server_zone_responses,code=5xx":                      86,
+		"nginx_plus_server_zone_responses_codes,code=404":                2125,
+		"nginx_plus_upstream_peer_state,peer=10.0.0.1:8080":              1,
+		"nginx_plus_upstream_peer_state,peer=10.0.0.2:8080":              6,
+		"nginx_plus_upstream_peer_responses,code=4xx,peer=10.0.0.1:8080": 915,
+		"nginx_plus_cache_responses,status=hit,cache=http_cache":         254032,
+	} {
+		if samples[metric] != value {
+			t.Errorf("%s: expected %v, got %v", metric, value, samples[metric])
+		}
+	}
+	ratio := samples["nginx_plus_cache_hit_ratio,cache=http_cache"]
+	if ratio < 0.12 || ratio > 0.13 {
+		t.Errorf("unexpected cache hit ratio %v", ratio)
+	}
+}
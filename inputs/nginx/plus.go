@@ -0,0 +1,277 @@
+package nginx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"flashcat.cloud/categraf/types"
+)
+
+const defaultPlusAPIVersion = 8
+
+var plusAPIPathRegexp = regexp.MustCompile(`/api(/\d+)?/?$`)
+
+// peerStates maps the state of an upstream peer to the value of nginx_plus_upstream_peer_state
+var peerStates = map[string]int{
+	"up":        1,
+	"draining":  2,
+	"down":      3,
+	"unavail":   4,
+	"checking":  5,
+	"unhealthy": 6,
+}
+
+type plusResponses struct {
+	Code1xx uint64            `json:"1xx"`
+	Code2xx uint64            `json:"2xx"`
+	Code3xx uint64            `json:"3xx"`
+	Code4xx uint64            `json:"4xx"`
+	Code5xx uint64            `json:"5xx"`
+	Codes   map[string]uint64 `json:"codes"`
+	Total   uint64            `json:"total"`
+}
+
+type plusConnections struct {
+	Accepted uint64 `json:"accepted"`
+	Dropped  uint64 `json:"dropped"`
+	Active   uint64 `json:"active"`
+	Idle     uint64 `json:"idle"`
+}
+
+type plusRequests struct {
+	Total   uint64 `json:"total"`
+	Current uint64 `json:"current"`
+}
+
+type plusServerZone struct {
+	Processing uint64        `json:"processing"`
+	Requests   uint64        `json:"requests"`
+	Responses  plusResponses `json:"responses"`
+	Discarded  uint64        `json:"discarded"`
+	Received   uint64        `json:"received"`
+	Sent       uint64        `json:"sent"`
+}
+
+type plusPeer struct {
+	Server       string        `json:"server"`
+	Backup       bool          `json:"backup"`
+	State        string        `json:"state"`
+	Active       uint64        `json:"active"`
+	Requests     uint64        `json:"requests"`
+	Responses    plusResponses `json:"responses"`
+	Sent         uint64        `json:"sent"`
+	Received     uint64        `json:"received"`
+	Fails        uint64        `json:"fails"`
+	Unavail      uint64        `json:"unavail"`
+	HeaderTime   uint64        `json:"header_time"`
+	ResponseTime uint64        `json:"response_time"`
+	HealthChecks struct {
+		Checks    uint64 `json:"checks"`
+		Fails     uint64 `json:"fails"`
+		Unhealthy uint64 `json:"unhealthy"`
+	} `json:"health_checks"`
+}
+
+type plusUpstream struct {
+	Peers     []plusPeer `json:"peers"`
+	Keepalive uint64     `json:"keepalive"`
+	Zombies   uint64     `json:"zombies"`
+}
+
+type plusCacheStats struct {
+	Responses uint64 `json:"responses"`
+	Bytes     uint64 `json:"bytes"`
+}
+
+type plusCache struct {
+	Size        uint64         `json:"size"`
+	MaxSize     uint64         `json:"max_size"`
+	Cold        bool           `json:"cold"`
+	Hit         plusCacheStats `json:"hit"`
+	Stale       plusCacheStats `json:"stale"`
+	Updating    plusCacheStats `json:"updating"`
+	Revalidated plusCacheStats `json:"revalidated"`
+	Miss        plusCacheStats `json:"miss"`
+	Expired     plusCacheStats `json:"expired"`
+	Bypass      plusCacheStats `json:"bypass"`
+}
+
+// plusAPIBase returns the versioned api url of an url pointing to the api, e.g. http://host/api
+// becomes http://host/api/8, and false if the url doesn't point to the api
+func plusAPIBase(addr *url.URL) (string, bool) {
+	if addr.Path == "" || addr.Path == "/" {
+		return strings.TrimRight(addr.String(), "/") + fmt.Sprintf("/api/%d", defaultPlusAPIVersion), true
+	}
+	m := plusAPIPathRegexp.FindStringSubmatch(addr.Path)
+	if m == nil {
+		return "", false
+	}
+	base := *addr
+	base.Path = strings.TrimRight(addr.Path, "/")
+	if m[1] == "" {
+		base.Path += fmt.Sprintf("/%d", defaultPlusAPIVersion)
+	}
+	return base.String(), true
+}
+
+func (ins *Instance) getPlusJSON(u string, v interface{}) error {
+	request, err := ins.newRequest(u)
+	if err != nil {
+		return err
+	}
+	resp, err := ins.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to request the url: %s, error: %s", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("the HTTP response status exception, url: %s, status: %s", u, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode the response of %s: %v", u, err)
+	}
+	return nil
+}
+
+// gatherPlus collects the connections, requests, server zones, upstreams and caches of the
+// Nginx Plus API, the zones without status_zone or zone directive are simply absent
+func (ins *Instance) gatherPlus(base string, slist *types.SampleList, tags map[string]string) error {
+	var connections plusConnections
+	if err := ins.getPlusJSON(base+"/connections", &connections); err != nil {
+		return err
+	}
+	var requests plusRequests
+	if err := ins.getPlusJSON(base+"/http/requests", &requests); err != nil {
+		return err
+	}
+
+	slist.PushSamples(inputName, map[string]interface{}{
+		"plus_connections_accepted":  connections.Accepted,
+		"plus_connections_dropped":   connections.Dropped,
+		"plus_connections_active":    connections.Active,
+		"plus_connections_idle":      connections.Idle,
+		"plus_http_requests_total":   requests.Total,
+		"plus_http_requests_current": requests.Current,
+	}, tags)
+
+	var zones map[string]plusServerZone
+	if err := ins.getPlusJSON(base+"/http/server_zones", &zones); err != nil {
+		return err
+	}
+	for name, zone := range zones {
+		labels := withLabels(tags, "server_zone", name)
+		slist.PushSamples(inputName, map[string]interface{}{
+			"plus_server_zone_processing": zone.Processing,
+			"plus_server_zone_requests":   zone.Requests,
+			"plus_server_zone_discarded":  zone.Discarded,
+			"plus_server_zone_received":   zone.Received,
+			"plus_server_zone_sent":       zone.Sent,
+		}, labels)
+		pushResponses(slist, "plus_server_zone_responses", zone.Responses, labels)
+	}
+
+	var upstreams map[string]plusUpstream
+	if err := ins.getPlusJSON(base+"/http/upstreams", &upstreams); err != nil {
+		return err
+	}
+	for name, upstream := range upstreams {
+		labels := withLabels(tags, "upstream", name)
+		slist.PushSamples(inputName, map[string]interface{}{
+			"plus_upstream_keepalive": upstream.Keepalive,
+			"plus_upstream_zombies":   upstream.Zombies,
+		}, labels)
+		for _, peer := range upstream.Peers {
+			peerLabels := withLabels(labels, "peer", peer.Server)
+			peerLabels["backup"] = fmt.Sprint(peer.Backup)
+			slist.PushSamples(inputName, map[string]interface{}{
+				"plus_upstream_peer_state":                   peerStates[peer.State],
+				"plus_upstream_peer_active":                  peer.Active,
+				"plus_upstream_peer_requests":                peer.Requests,
+				"plus_upstream_peer_sent":                    peer.Sent,
+				"plus_upstream_peer_received":                peer.Received,
+				"plus_upstream_peer_fails":                   peer.Fails,
+				"plus_upstream_peer_unavail":                 peer.Unavail,
+				"plus_upstream_peer_header_time":             peer.HeaderTime,
+				"plus_upstream_peer_response_time":           peer.ResponseTime,
+				"plus_upstream_peer_health_checks_checks":    peer.HealthChecks.Checks,
+				"plus_upstream_peer_health_checks_fails":     peer.HealthChecks.Fails,
+				"plus_upstream_peer_health_checks_unhealthy": peer.HealthChecks.Unhealthy,
+			}, peerLabels)
+			pushResponses(slist, "plus_upstream_peer_responses", peer.Responses, peerLabels)
+		}
+	}
+
+	var caches map[string]plusCache
+	if err := ins.getPlusJSON(base+"/http/caches", &caches); err != nil {
+		return err
+	}
+	for name, cache := range caches {
+		labels := withLabels(tags, "cache", name)
+		cold := 0
+		if cache.Cold {
+			cold = 1
+		}
+		slist.PushSamples(inputName, map[string]interface{}{
+			"plus_cache_size":     cache.Size,
+			"plus_cache_max_size": cache.MaxSize,
+			"plus_cache_cold":     cold,
+		}, labels)
+
+		statuses := map[string]plusCacheStats{
+			"hit":         cache.Hit,
+			"stale":       cache.Stale,
+			"updating":    cache.Updating,
+			"revalidated": cache.Revalidated,
+			"miss":        cache.Miss,
+			"expired":     cache.Expired,
+			"bypass":      cache.Bypass,
+		}
+		var total uint64
+		for status, stats := range statuses {
+			statusLabels := withLabels(labels, "status", status)
+			slist.PushSample(inputName, "plus_cache_responses", stats.Responses, statusLabels)
+			slist.PushSample(inputName, "plus_cache_bytes", stats.Bytes, statusLabels)
+			total += stats.Responses
+		}
+		// the responses served from the cache, over the lifetime of the cache
+		if total > 0 {
+			served := cache.Hit.Responses + cache.Stale.Responses + cache.Updating.Responses + cache.Revalidated.Responses
+			slist.PushSample(inputName, "plus_cache_hit_ratio", float64(served)/float64(total), labels)
+		}
+	}
+
+	return nil
+}
+
+// pushResponses pushes the responses by code class and, when the api reports them, by code
+func pushResponses(slist *types.SampleList, metric string, responses plusResponses, labels map[string]string) {
+	for class, count := range map[string]uint64{
+		"1xx": responses.Code1xx,
+		"2xx": responses.Code2xx,
+		"3xx": responses.Code3xx,
+		"4xx": responses.Code4xx,
+		"5xx": responses.Code5xx,
+	} {
+		slist.PushSample(inputName, metric, count, withLabels(labels, "code", class))
+	}
+	for code, count := range responses.Codes {
+		slist.PushSample(inputName, metric+"_codes", count, withLabels(labels, "code", code))
+	}
+	slist.PushSample(inputName, metric+"_total", responses.Total, labels)
+}
+
+func withLabels(tags map[string]string, kv ...string) map[string]string {
+	labels := make(map[string]string, len(tags)+len(kv)/2)
+	for k, v := range tags {
+		labels[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		labels[kv[i]] = kv[i+1]
+	}
+	return labels
+}
@@ -3,8 +3,12 @@ package oracle
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,7 +23,13 @@ import (
 	"flashcat.cloud/categraf/types"
 )
 
-const inputName = "oracle"
+const (
+	inputName = "oracle"
+
+	// sessionTimeout bounds the statements switching the container of a session
+	sessionTimeout = 10 * time.Second
+	rootContainer  = "CDB$ROOT"
+)
 
 type Instance struct {
 	config.InstanceConfig
@@ -33,7 +43,17 @@ type Instance struct {
 	MaxOpenConnections    int            `toml:"max_open_connections"`
 	Metrics               []MetricConfig `toml:"metrics"`
 	GlobalMetrics         []MetricConfig `toml:"-"`
-	client                *sql.DB
+
+	// TNSAdmin is the directory of tnsnames.ora, address may then be a net service name.
+	// Without wallet_location, the wallet is also looked up in this directory
+	TNSAdmin       string `toml:"tns_admin"`
+	WalletLocation string `toml:"wallet_location"`
+	// WalletPassword is only needed for ewallet.p12, cwallet.sso is an auto-login wallet
+	WalletPassword string `toml:"wallet_password"`
+	// CDBMode connects to the CDB root and runs the metrics in every open PDB with a pdb label
+	CDBMode bool `toml:"cdb_mode"`
+
+	client *sql.DB
 }
 
 type MetricConfig struct {
@@ -153,16 +173,14 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		slist.PushFront(types.NewSample(inputName, "up", 1, tags))
 	}
 
-	waitMetrics := new(sync.WaitGroup)
-
-	for i := 0; i < len(ins.Metrics); i++ {
-		m := ins.Metrics[i]
-		waitMetrics.Add(1)
-		go ins.scrapeMetric(waitMetrics, slist, m, tags)
+	if ins.CDBMode {
+		ins.gatherPDBs(slist, tags)
+		return
 	}
 
-	for i := 0; i < len(ins.GlobalMetrics); i++ {
-		m := ins.GlobalMetrics[i]
+	waitMetrics := new(sync.WaitGroup)
+
+	for _, m := range ins.allMetrics() {
 		waitMetrics.Add(1)
 		go ins.scrapeMetric(waitMetrics, slist, m, tags)
 	}
@@ -170,24 +188,146 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 	waitMetrics.Wait()
 }
 
+func (ins *Instance) allMetrics() []MetricConfig {
+	metrics := make([]MetricConfig, 0, len(ins.Metrics)+len(ins.GlobalMetrics))
+	metrics = append(metrics, ins.Metrics...)
+	return append(metrics, ins.GlobalMetrics...)
+}
+
 func (ins *Instance) scrapeMetric(waitMetrics *sync.WaitGroup, slist *types.SampleList, metricConf MetricConfig, tags map[string]string) {
 	defer waitMetrics.Done()
 
+	conn, err := ins.conn()
+	if err != nil {
+		log.Println("E! failed to get oracle connection:", ins.Address, "error:", err)
+		return
+	}
+	releaseConn(conn, ins.scrapeRows(conn, slist, metricConf, tags))
+}
+
+// gatherPDBs runs the metrics in every open PDB, on one session per PDB switched to the PDB container
+func (ins *Instance) gatherPDBs(slist *types.SampleList, tags map[string]string) {
+	pdbs, err := ins.openPDBs()
+	if err != nil {
+		log.Println("E! failed to list the open pdbs of", ins.Address, "error:", err)
+		return
+	}
+	if ins.DebugMod {
+		log.Println("D! open pdbs of", ins.Address, ":", pdbs)
+	}
+
+	wg := new(sync.WaitGroup)
+	for _, pdb := range pdbs {
+		wg.Add(1)
+		go ins.scrapePDB(wg, slist, pdb, tags)
+	}
+	wg.Wait()
+}
+
+// openPDBs returns the PDBs open read write or read only, mounted PDBs can't be queried
+func (ins *Instance) openPDBs() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionTimeout)
+	defer cancel()
+
+	rows, err := ins.client.QueryContext(ctx, "SELECT name FROM v$pdbs WHERE open_mode IN ('READ WRITE', 'READ ONLY') AND name <> 'PDB$SEED'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pdbs []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		pdbs = append(pdbs, name)
+	}
+	return pdbs, rows.Err()
+}
+
+func (ins *Instance) scrapePDB(wg *sync.WaitGroup, slist *types.SampleList, pdb string, tags map[string]string) {
+	defer wg.Done()
+
+	conn, err := ins.conn()
+	if err != nil {
+		log.Println("E! failed to get oracle connection:", ins.Address, "error:", err)
+		return
+	}
+	// the session goes back to the pool only when switched back to the root
+	reuse := false
+	defer func() {
+		releaseConn(conn, reuse)
+	}()
+
+	if err := setContainer(conn, pdb); err != nil {
+		// the pdb may have been closed since it was listed
+		log.Println("E! failed to switch to pdb", pdb, "of", ins.Address, "error:", err)
+		return
+	}
+
+	pdbTags := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		pdbTags[k] = v
+	}
+	pdbTags["pdb"] = pdb
+
+	for _, m := range ins.allMetrics() {
+		if !ins.scrapeRows(conn, slist, m, pdbTags) {
+			return
+		}
+	}
+
+	if err := setContainer(conn, rootContainer); err != nil {
+		log.Println("E! failed to switch back to", rootContainer, "of", ins.Address, "error:", err)
+		return
+	}
+	reuse = true
+}
+
+func setContainer(conn *sql.Conn, container string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionTimeout)
+	defer cancel()
+	_, err := conn.ExecContext(ctx, `ALTER SESSION SET CONTAINER = "`+container+`"`)
+	return err
+}
+
+func (ins *Instance) conn() (*sql.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionTimeout)
+	defer cancel()
+	return ins.client.Conn(ctx)
+}
+
+// releaseConn returns conn to the pool, or closes it if it may still run a timed out query or
+// be attached to a PDB. Closing the connection ends the session on the server, otherwise the
+// abandoned sessions pile up against the sessions limit of the database
+func releaseConn(conn *sql.Conn, reuse bool) {
+	if !reuse {
+		conn.Raw(func(interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+	conn.Close()
+}
+
+// scrapeRows runs the request of metricConf on conn, it returns false if the query timed out
+// and the session of conn should not be reused
+func (ins *Instance) scrapeRows(conn *sql.Conn, slist *types.SampleList, metricConf MetricConfig, tags map[string]string) bool {
 	timeout := time.Duration(metricConf.Timeout)
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	rows, err := ins.client.QueryContext(ctx, metricConf.Request)
+	rows, err := conn.QueryContext(ctx, metricConf.Request)
 
 	if ctx.Err() == context.DeadlineExceeded {
 		log.Printf("E! %s oracle query timeout (more than %d seconds), request: %s", ins.Address, metricConf.Timeout/(1000*1000*1000),
 			strings.ReplaceAll(strings.ReplaceAll(metricConf.Request, "\n", " "), "\r", " "))
-		return
+		return false
 	}
 
 	if err != nil {
 		log.Println("E! failed to query:", err)
-		return
+		return !errors.Is(err, driver.ErrBadConn)
 	}
 
 	defer rows.Close()
@@ -195,7 +335,7 @@ func (ins *Instance) scrapeMetric(waitMetrics *sync.WaitGroup, slist *types.Samp
 	cols, err := rows.Columns()
 	if err != nil {
 		log.Println("E! failed to get columns:", err)
-		return
+		return true
 	}
 
 	if ins.DebugMod {
@@ -212,7 +352,7 @@ func (ins *Instance) scrapeMetric(waitMetrics *sync.WaitGroup, slist *types.Samp
 		// Scan the result into the column pointers...
 		if err := rows.Scan(columnPointers...); err != nil {
 			log.Println("E! failed to scan:", err)
-			return
+			return ctx.Err() == nil
 		}
 
 		// Create our map, and retrieve the value for each column from the pointers slice,
@@ -235,6 +375,9 @@ func (ins *Instance) scrapeMetric(waitMetrics *sync.WaitGroup, slist *types.Samp
 			log.Println("E! no metrics found while parsing")
 		}
 	}
+
+	// the fetch of the rows may time out as well
+	return ctx.Err() == nil
 }
 
 func (ins *Instance) parseRow(row map[string]string, metricConf MetricConfig, slist *types.SampleList, tags map[string]string) error {
@@ -289,6 +432,13 @@ func isTNSFormat(connStr string) bool {
 
 func (ins *Instance) getConnectionString() (string, error) {
 	opts := make(map[string]string)
+	if wallet := ins.walletLocation(); wallet != "" {
+		// the password of username is read from the wallet when password is empty
+		opts["wallet"] = wallet
+		if ins.WalletPassword != "" {
+			opts["wallet password"] = ins.WalletPassword
+		}
+	}
 	if ins.IsSysOper {
 		opts["dba privilege"] = "sysoper"
 	}
@@ -303,6 +453,14 @@ func (ins *Instance) getConnectionString() (string, error) {
 		return go_ora.BuildJDBC(ins.Username, ins.Password, ins.Address, opts), nil
 	}
 
+	if tnsAdmin := ins.tnsAdmin(); tnsAdmin != "" && !strings.Contains(ins.Address, "/") {
+		descriptor, err := lookupTNSAlias(filepath.Join(tnsAdmin, "tnsnames.ora"), ins.Address)
+		if err != nil {
+			return "", err
+		}
+		return go_ora.BuildJDBC(ins.Username, ins.Password, descriptor, opts), nil
+	}
+
 	ip, port, service, err := explode(ins.Address)
 	if err != nil {
 		log.Println("E! oracle address format error:", err)
@@ -311,6 +469,91 @@ func (ins *Instance) getConnectionString() (string, error) {
 	return go_ora.BuildUrl(ip, port, service, ins.Username, ins.Password, opts), nil
 }
 
+// tnsAdmin returns tns_admin of the instance, falling back to the TNS_ADMIN environment variable
+func (ins *Instance) tnsAdmin() string {
+	if ins.TNSAdmin != "" {
+		return ins.TNSAdmin
+	}
+	return os.Getenv("TNS_ADMIN")
+}
+
+func (ins *Instance) walletLocation() string {
+	if ins.WalletLocation != "" {
+		return ins.WalletLocation
+	}
+	if ins.TNSAdmin == "" {
+		return ""
+	}
+	for _, name := range []string{"cwallet.sso", "ewallet.p12"} {
+		if _, err := os.Stat(filepath.Join(ins.TNSAdmin, name)); err == nil {
+			return ins.TNSAdmin
+		}
+	}
+	return ""
+}
+
+// lookupTNSAlias returns the connect descriptor of the net service name alias in a tnsnames.ora file,
+// e.g. "orcl" matches "ORCL = (DESCRIPTION = ...)" and "orcl.world, orcl_alt = (DESCRIPTION = ...)"
+func lookupTNSAlias(file, alias string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	content := b.String()
+
+	for i := 0; i < len(content); {
+		eq := strings.IndexByte(content[i:], '=')
+		if eq < 0 {
+			break
+		}
+		// the names start a line, skip what's left of a previous parameter like IFILE
+		names := strings.TrimSpace(content[i : i+eq])
+		if nl := strings.LastIndexByte(names, '\n'); nl >= 0 {
+			names = names[nl+1:]
+		}
+		start := i + eq + 1
+		for start < len(content) && strings.IndexByte(" \t\r\n", content[start]) >= 0 {
+			start++
+		}
+		if start >= len(content) || content[start] != '(' {
+			i = start
+			continue
+		}
+		end, depth := start, 0
+		for ; end < len(content); end++ {
+			if content[end] == '(' {
+				depth++
+			} else if content[end] == ')' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		if depth != 0 {
+			return "", fmt.Errorf("unbalanced parentheses in %s", file)
+		}
+
+		for _, name := range strings.Split(names, ",") {
+			name = strings.TrimSpace(name)
+			if strings.EqualFold(name, alias) || strings.EqualFold(strings.SplitN(name, ".", 2)[0], alias) {
+				return content[start : end+1], nil
+			}
+		}
+		i = end + 1
+	}
+	return "", fmt.Errorf("net service name %s not found in %s", alias, file)
+}
+
 func explode(target string) (ip string, port int, service string, err error) {
 	ErrInvalidTarget := fmt.Errorf("invalid target: %s", target)
 
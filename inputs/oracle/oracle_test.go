@@ -0,0 +1,44 @@
+package oracle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const tnsnames = `# test tnsnames.ora
+IFILE = /etc/oracle/common.ora
+
+ORCL.WORLD, ORCL_ALT =
+  (DESCRIPTION =
+    (ADDRESS = (PROTOCOL = TCP)(HOST = 10.1.2.3)(PORT = 1521))
+    (CONNECT_DATA = (SERVICE_NAME = orcl)) # comment (
+  )
+
+cdb1=(DESCRIPTION=(ADDRESS=(PROTOCOL=TCPS)(HOST=db1)(PORT=2484))(CONNECT_DATA=(SERVICE_NAME=cdb1)))
+`
+
+func TestLookupTNSAlias(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tnsnames.ora")
+	if err := os.WriteFile(file, []byte(tnsnames), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for alias, host := range map[string]string{"orcl": "10.1.2.3", "ORCL_ALT": "10.1.2.3", "orcl.world": "10.1.2.3", "CDB1": "db1"} {
+		descriptor, err := lookupTNSAlias(file, alias)
+		if err != nil {
+			t.Fatalf("%s: %v", alias, err)
+		}
+		if !isTNSFormat(descriptor) {
+			t.Fatalf("%s: unexpected descriptor %q", alias, descriptor)
+		}
+		if !strings.Contains(strings.ReplaceAll(descriptor, " ", ""), "HOST="+host) {
+			t.Fatalf("%s: expected host %s in %q", alias, host, descriptor)
+		}
+	}
+
+	if _, err := lookupTNSAlias(file, "common"); err == nil {
+		t.Fatal("expected an error for an unknown alias")
+	}
+}
@@ -0,0 +1,190 @@
+package otlp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// converter turns the OTLP metrics into samples, keeping the allowed resource attributes
+// as labels. The delta sums and histograms are not converted: the samples are written as
+// is and the deltas would not be cumulative series.
+type converter struct {
+	resourceAttributes map[string]struct{}
+	labels             map[string]string
+	now                func() time.Time
+
+	// count of the data points skipped, for a one time warning
+	skipped int
+}
+
+func (c *converter) convert(rms []*metricspb.ResourceMetrics) []*types.Sample {
+	var samples []*types.Sample
+	for _, rm := range rms {
+		resourceLabels := make(map[string]string)
+		if rm.Resource != nil {
+			for _, kv := range rm.Resource.Attributes {
+				if _, has := c.resourceAttributes[kv.Key]; has {
+					resourceLabels[sanitizeName(kv.Key)] = anyValueString(kv.Value)
+				}
+			}
+		}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				samples = c.convertMetric(samples, m, resourceLabels)
+			}
+		}
+	}
+	return samples
+}
+
+func (c *converter) convertMetric(samples []*types.Sample, m *metricspb.Metric, resourceLabels map[string]string) []*types.Sample {
+	name := sanitizeName(m.Name)
+	switch data := m.Data.(type) {
+	case *metricspb.Metric_Gauge:
+		for _, dp := range data.Gauge.DataPoints {
+			samples = append(samples, c.sample(name, numberValue(dp), dp.TimeUnixNano, dp.Attributes, resourceLabels))
+		}
+	case *metricspb.Metric_Sum:
+		if data.Sum.AggregationTemporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+			c.skipped += len(data.Sum.DataPoints)
+			break
+		}
+		for _, dp := range data.Sum.DataPoints {
+			samples = append(samples, c.sample(name, numberValue(dp), dp.TimeUnixNano, dp.Attributes, resourceLabels))
+		}
+	case *metricspb.Metric_Histogram:
+		if data.Histogram.AggregationTemporality == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA {
+			c.skipped += len(data.Histogram.DataPoints)
+			break
+		}
+		for _, dp := range data.Histogram.DataPoints {
+			samples = c.histogram(samples, name, dp, resourceLabels)
+		}
+	case *metricspb.Metric_Summary:
+		for _, dp := range data.Summary.DataPoints {
+			for _, q := range dp.QuantileValues {
+				s := c.sample(name, q.Value, dp.TimeUnixNano, dp.Attributes, resourceLabels)
+				s.Labels["quantile"] = strconv.FormatFloat(q.Quantile, 'f', -1, 64)
+				samples = append(samples, s)
+			}
+			samples = append(samples,
+				c.sample(name+"_sum", dp.Sum, dp.TimeUnixNano, dp.Attributes, resourceLabels),
+				c.sample(name+"_count", float64(dp.Count), dp.TimeUnixNano, dp.Attributes, resourceLabels),
+			)
+		}
+	default:
+		// exponential histograms
+		c.skipped++
+	}
+	return samples
+}
+
+// histogram emits the cumulative _bucket series with the le label, _sum and _count
+func (c *converter) histogram(samples []*types.Sample, name string, dp *metricspb.HistogramDataPoint, resourceLabels map[string]string) []*types.Sample {
+	var cumulative uint64
+	for i, count := range dp.BucketCounts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(dp.ExplicitBounds) {
+			le = strconv.FormatFloat(dp.ExplicitBounds[i], 'f', -1, 64)
+		}
+		s := c.sample(name+"_bucket", float64(cumulative), dp.TimeUnixNano, dp.Attributes, resourceLabels)
+		s.Labels["le"] = le
+		samples = append(samples, s)
+	}
+	// the bucket_counts of a histogram without buckets are empty
+	if len(dp.BucketCounts) <= len(dp.ExplicitBounds) {
+		s := c.sample(name+"_bucket", float64(dp.Count), dp.TimeUnixNano, dp.Attributes, resourceLabels)
+		s.Labels["le"] = "+Inf"
+		samples = append(samples, s)
+	}
+	if dp.Sum != nil {
+		samples = append(samples, c.sample(name+"_sum", *dp.Sum, dp.TimeUnixNano, dp.Attributes, resourceLabels))
+	}
+	return append(samples, c.sample(name+"_count", float64(dp.Count), dp.TimeUnixNano, dp.Attributes, resourceLabels))
+}
+
+// sample builds the sample of a data point, the attributes of the data point override the
+// resource attributes, which override the labels of the instance and the global labels
+func (c *converter) sample(name string, value float64, ts uint64, attributes []*commonpb.KeyValue, resourceLabels map[string]string) *types.Sample {
+	labels := make(map[string]string, len(c.labels)+len(resourceLabels)+len(attributes)+1)
+	for k, v := range c.labels {
+		labels[k] = v
+	}
+	for k, v := range resourceLabels {
+		labels[k] = v
+	}
+	for _, kv := range attributes {
+		labels[sanitizeName(kv.Key)] = anyValueString(kv.Value)
+	}
+	s := types.NewSample("", name, value, labels)
+	if ts > 0 {
+		s.Timestamp = time.Unix(0, int64(ts))
+	} else {
+		s.Timestamp = c.now()
+	}
+	return s
+}
+
+func numberValue(dp *metricspb.NumberDataPoint) float64 {
+	switch v := dp.Value.(type) {
+	case *metricspb.NumberDataPoint_AsDouble:
+		return v.AsDouble
+	case *metricspb.NumberDataPoint_AsInt:
+		return float64(v.AsInt)
+	}
+	return math.NaN()
+}
+
+func anyValueString(v *commonpb.AnyValue) string {
+	if v == nil {
+		return ""
+	}
+	switch v := v.Value.(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return string(v.BytesValue)
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]string, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			values = append(values, anyValueString(item))
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	}
+	return ""
+}
+
+// sanitizeName replaces the characters not allowed in the metric and label names, e.g.
+// http.server.duration becomes http_server_duration
+func sanitizeName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package otlp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func kv(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}}
+}
+
+func format(samples []*types.Sample) []string {
+	ret := make([]string, 0, len(samples))
+	for _, s := range samples {
+		labels := make([]string, 0, len(s.Labels))
+		for k, v := range s.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		ret = append(ret, fmt.Sprintf("%s{%s} %v", s.Metric, strings.Join(labels, ","), s.Value))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestConvert(t *testing.T) {
+	sum := 12.5
+	rms := []*metricspb.ResourceMetrics{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			kv("service.name", "checkout"),
+			kv("host.arch", "amd64"),
+		}},
+		ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: []*metricspb.Metric{
+			{Name: "process.threads", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+				DataPoints: []*metricspb.NumberDataPoint{{
+					TimeUnixNano: 1700000000e9,
+					Value:        &metricspb.NumberDataPoint_AsInt{AsInt: 12},
+				}},
+			}}},
+			{Name: "http.requests", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+				DataPoints: []*metricspb.NumberDataPoint{{
+					Attributes: []*commonpb.KeyValue{kv("http.method", "GET"), kv("service.name", "override")},
+					Value:      &metricspb.NumberDataPoint_AsDouble{AsDouble: 42},
+				}},
+			}}},
+			{Name: "http.deltas", Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				DataPoints:             []*metricspb.NumberDataPoint{{Value: &metricspb.NumberDataPoint_AsInt{AsInt: 1}}},
+			}}},
+			{Name: "http.duration", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				DataPoints: []*metricspb.HistogramDataPoint{{
+					Count:          6,
+					Sum:            &sum,
+					BucketCounts:   []uint64{1, 2, 3},
+					ExplicitBounds: []float64{0.1, 1},
+				}},
+			}}},
+		}}},
+	}}
+
+	c := &converter{
+		resourceAttributes: map[string]struct{}{"service.name": {}},
+		labels:             map[string]string{"agent_hostname": "h1", "service_name": "global"},
+		now:                func() time.Time { return time.Unix(1700000001, 0) },
+	}
+	samples := c.convert(rms)
+
+	want := []string{
+		"http_duration_bucket{agent_hostname=h1,le=+Inf,service_name=checkout} 6",
+		"http_duration_bucket{agent_hostname=h1,le=0.1,service_name=checkout} 1",
+		"http_duration_bucket{agent_hostname=h1,le=1,service_name=checkout} 3",
+		"http_duration_count{agent_hostname=h1,service_name=checkout} 6",
+		"http_duration_sum{agent_hostname=h1,service_name=checkout} 12.5",
+		"http_requests{agent_hostname=h1,http_method=GET,service_name=override} 42",
+		"process_threads{agent_hostname=h1,service_name=checkout} 12",
+	}
+	got := format(samples)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if c.skipped != 1 {
+		t.Fatalf("expected the delta sum to be skipped, got %d", c.skipped)
+	}
+	for _, s := range samples {
+		switch s.Metric {
+		case "process_threads":
+			if !s.Timestamp.Equal(time.Unix(1700000000, 0)) {
+				t.Fatalf("unexpected timestamp %v", s.Timestamp)
+			}
+		default:
+			if !s.Timestamp.Equal(time.Unix(1700000001, 0)) {
+				t.Fatalf("unexpected timestamp %v of %s", s.Timestamp, s.Metric)
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+	"flashcat.cloud/categraf/writer"
+)
+
+const (
+	inputName = "otlp"
+
+	defaultMaxRecvMsgSize = 4 * 1024 * 1024
+	// the delay the clients are asked to wait before retrying when the queue is full
+	retryDelay = 5 * time.Second
+)
+
+var defaultResourceAttributes = []string{"service.name", "service.namespace", "service.instance.id"}
+
+type OTLP struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &OTLP{}
+	})
+}
+
+func (o *OTLP) Clone() inputs.Input {
+	return &OTLP{}
+}
+
+func (o *OTLP) Name() string {
+	return inputName
+}
+
+func (o *OTLP) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(o.Instances))
+	for i := 0; i < len(o.Instances); i++ {
+		ret[i] = o.Instances[i]
+	}
+	return ret
+}
+
+func (o *OTLP) Drop() {
+	for i := 0; i < len(o.Instances); i++ {
+		o.Instances[i].Drop()
+	}
+}
+
+// Instance receives the OTLP metrics pushed by the applications, the samples are written
+// to the writer queue when received, Gather has nothing to collect
+type Instance struct {
+	config.InstanceConfig
+
+	// e.g. 127.0.0.1:4317, the gRPC receiver is disabled when empty
+	GrpcAddress string `toml:"grpc_address"`
+	// e.g. 127.0.0.1:4318, the receiver of POST /v1/metrics is disabled when empty
+	HTTPAddress string `toml:"http_address"`
+	// the resource attributes kept as labels, the names are sanitized, e.g. service_name
+	ResourceAttributes []string `toml:"resource_attributes"`
+	MaxRecvMsgSize     int      `toml:"max_recv_msg_size"`
+
+	grpcServer *grpc.Server
+	httpServer *http.Server
+
+	convLock sync.Mutex
+	warned   bool
+	colmetricspb.UnimplementedMetricsServiceServer
+}
+
+func (ins *Instance) Init() error {
+	if ins.GrpcAddress == "" && ins.HTTPAddress == "" {
+		return types.ErrInstancesEmpty
+	}
+	if ins.MaxRecvMsgSize <= 0 {
+		ins.MaxRecvMsgSize = defaultMaxRecvMsgSize
+	}
+	if ins.ResourceAttributes == nil {
+		ins.ResourceAttributes = defaultResourceAttributes
+	}
+
+	if ins.GrpcAddress != "" {
+		lis, err := net.Listen("tcp", ins.GrpcAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", ins.GrpcAddress, err)
+		}
+		ins.grpcServer = grpc.NewServer(grpc.MaxRecvMsgSize(ins.MaxRecvMsgSize))
+		colmetricspb.RegisterMetricsServiceServer(ins.grpcServer, ins)
+		go func() {
+			if err := ins.grpcServer.Serve(lis); err != nil {
+				log.Println("E! otlp grpc receiver stopped:", err)
+			}
+		}()
+		log.Println("I! otlp grpc receiver listening on:", ins.GrpcAddress)
+	}
+
+	if ins.HTTPAddress != "" {
+		lis, err := net.Listen("tcp", ins.HTTPAddress)
+		if err != nil {
+			ins.Drop()
+			return fmt.Errorf("failed to listen on %s: %v", ins.HTTPAddress, err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/v1/metrics", ins.serveHTTP)
+		ins.httpServer = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := ins.httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+				log.Println("E! otlp http receiver stopped:", err)
+			}
+		}()
+		log.Println("I! otlp http receiver listening on:", ins.HTTPAddress)
+	}
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {}
+
+func (ins *Instance) Drop() {
+	if ins.grpcServer != nil {
+		ins.grpcServer.GracefulStop()
+	}
+	if ins.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := ins.httpServer.Shutdown(ctx); err != nil {
+			log.Println("E! failed to stop otlp http receiver:", err)
+		}
+	}
+}
+
+var errQueueFull = errors.New("the writer queue is full")
+
+// Export implements the OTLP MetricsService
+func (ins *Instance) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	if err := ins.write(req); err != nil {
+		// the clients retry RESOURCE_EXHAUSTED after the delay of RetryInfo
+		st, derr := status.New(codes.ResourceExhausted, err.Error()).
+			WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryDelay)})
+		if derr != nil {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, st.Err()
+	}
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+// serveHTTP serves the OTLP/HTTP POST /v1/metrics, protobuf or JSON encoded
+func (ins *Instance) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	bs, err := io.ReadAll(io.LimitReader(body, int64(ins.MaxRecvMsgSize)+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(bs) > ins.MaxRecvMsgSize {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	req := &colmetricspb.ExportMetricsServiceRequest{}
+	isJSON := r.Header.Get("Content-Type") == "application/json"
+	if isJSON {
+		err = protojson.Unmarshal(bs, req)
+	} else {
+		err = proto.Unmarshal(bs, req)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := ins.write(req); err != nil {
+		w.Header().Set("Retry-After", fmt.Sprint(int(retryDelay.Seconds())))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	var resp []byte
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		resp, err = protojson.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		resp, err = proto.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(resp)
+}
+
+// write converts the metrics and pushes the samples to the writer queue, the samples are
+// not buffered when the queue is full
+func (ins *Instance) write(req *colmetricspb.ExportMetricsServiceRequest) error {
+	c := &converter{
+		resourceAttributes: make(map[string]struct{}, len(ins.ResourceAttributes)),
+		labels:             ins.commonLabels(),
+		now:                time.Now,
+	}
+	for _, attr := range ins.ResourceAttributes {
+		c.resourceAttributes[attr] = struct{}{}
+	}
+	samples := c.convert(req.ResourceMetrics)
+
+	if c.skipped > 0 {
+		ins.convLock.Lock()
+		if !ins.warned {
+			ins.warned = true
+			log.Println("W! otlp receiver skips the delta sums and histograms and the exponential histograms, configure the cumulative temporality of the exporters")
+		}
+		ins.convLock.Unlock()
+	}
+
+	if !writer.PushSamples(inputName, samples) {
+		return errQueueFull
+	}
+	return nil
+}
+
+// commonLabels returns the global labels, agent_hostname and the labels of the instance,
+// the samples do not go through the gather of the inputs which adds them
+func (ins *Instance) commonLabels() map[string]string {
+	labels := make(map[string]string)
+	for k, v := range config.GlobalLabels() {
+		labels[k] = v
+	}
+	if !config.Config.Global.OmitHostname {
+		labels["agent_hostname"] = config.Config.GetHostname()
+	}
+	for k, v := range ins.GetLabels() {
+		labels[k] = v
+	}
+	return labels
+}
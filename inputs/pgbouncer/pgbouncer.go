@@ -0,0 +1,247 @@
+package pgbouncer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "pgbouncer"
+
+type PgBouncer struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// dsn of the pgbouncer admin console, e.g. host=127.0.0.1 port=6432 dbname=pgbouncer
+	Address string `toml:"address"`
+	// a user of stats_users or admin_users of pgbouncer, override the user and password of address
+	Username string          `toml:"username"`
+	Password string          `toml:"password"`
+	Timeout  config.Duration `toml:"timeout"`
+
+	connConfig string
+	server     string
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(PgBouncer)
+var _ inputs.InstancesGetter = new(PgBouncer)
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &PgBouncer{}
+	})
+}
+
+func (p *PgBouncer) Clone() inputs.Input {
+	return &PgBouncer{}
+}
+
+func (p *PgBouncer) Name() string {
+	return inputName
+}
+
+func (p *PgBouncer) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(p.Instances))
+	for i := 0; i < len(p.Instances); i++ {
+		ret[i] = p.Instances[i]
+	}
+	return ret
+}
+
+func (ins *Instance) Init() error {
+	if ins.Address == "" {
+		return types.ErrInstancesEmpty
+	}
+	if ins.Timeout <= 0 {
+		ins.Timeout = config.Duration(5 * time.Second)
+	}
+
+	connConfig, err := pgx.ParseConfig(ins.Address)
+	if err != nil {
+		return fmt.Errorf("failed to parse address: %v", err)
+	}
+	if ins.Username != "" {
+		connConfig.User = ins.Username
+	}
+	if ins.Password != "" {
+		connConfig.Password = ins.Password
+	}
+	// the admin console is the pgbouncer database, it only speaks the simple query protocol
+	if connConfig.Database == "" {
+		connConfig.Database = "pgbouncer"
+	}
+	connConfig.PreferSimpleProtocol = true
+
+	ins.server = fmt.Sprintf("%s:%d", connConfig.Host, connConfig.Port)
+	ins.connConfig = stdlib.RegisterConnConfig(connConfig)
+	return nil
+}
+
+// showCommand describes the columns of the result of a SHOW command of the admin console
+type showCommand struct {
+	command string
+	// the prefix of the metrics of the columns
+	prefix string
+	// the columns which are labels, by label name
+	labels map[string]string
+	// the text columns exported as one gauge per state, 1 for the current state
+	enums map[string][]string
+	// the columns in microseconds, exported in seconds
+	micros func(column string) bool
+}
+
+var poolModes = []string{"session", "transaction", "statement"}
+
+var commands = []showCommand{
+	{
+		command: "SHOW POOLS",
+		prefix:  "pools",
+		labels:  map[string]string{"database": "database", "user": "user"},
+		enums:   map[string][]string{"pool_mode": poolModes},
+	},
+	{
+		command: "SHOW STATS",
+		prefix:  "stats",
+		labels:  map[string]string{"database": "database"},
+		// the times and the average times of the queries, transactions and waits
+		micros: func(column string) bool { return strings.HasSuffix(column, "_time") },
+	},
+	{
+		command: "SHOW DATABASES",
+		prefix:  "databases",
+		labels:  map[string]string{"name": "database", "database": "backend_database", "host": "host", "port": "port", "force_user": "force_user"},
+		enums:   map[string][]string{"pool_mode": poolModes},
+	},
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	tags := map[string]string{"server": ins.server}
+
+	db, err := sql.Open("pgx", ins.connConfig)
+	if err != nil {
+		slist.PushSample(inputName, "up", 0, tags)
+		log.Println("E! failed to open pgbouncer:", err)
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ins.Timeout))
+	defer cancel()
+	// the admin console only accepts its own commands, so the first one stands for the ping
+	for i, c := range commands {
+		if err := ins.gatherCommand(ctx, db, c, slist, tags); err != nil {
+			log.Println("E! failed to run", c.command, "on pgbouncer", ins.server, ":", err)
+			if i == 0 {
+				slist.PushSample(inputName, "up", 0, tags)
+				return
+			}
+		}
+	}
+	slist.PushSample(inputName, "up", 1, tags)
+}
+
+func (ins *Instance) gatherCommand(ctx context.Context, db *sql.DB, c showCommand, slist *types.SampleList, tags map[string]string) error {
+	rows, err := db.QueryContext(ctx, c.command)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		pushRow(slist, c, columns, values, tags)
+	}
+	return rows.Err()
+}
+
+// pushRow pushes the numeric and the enum columns of a row, the columns of the newer
+// pgbouncer versions are exported as they appear
+func pushRow(slist *types.SampleList, c showCommand, columns []string, values []sql.NullString, tags map[string]string) {
+	labels := make(map[string]string, len(tags)+len(c.labels))
+	for k, v := range tags {
+		labels[k] = v
+	}
+	byName := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		byName[column] = values[i]
+		if label, ok := c.labels[column]; ok && values[i].Valid {
+			labels[label] = values[i].String
+		}
+	}
+
+	for i, column := range columns {
+		if _, ok := c.labels[column]; ok || !values[i].Valid {
+			continue
+		}
+		if states, ok := c.enums[column]; ok {
+			pushEnum(slist, c.prefix+"_"+column, states, values[i].String, labels)
+			continue
+		}
+		// maxwait is in seconds and maxwait_us holds the microseconds
+		if column == "maxwait_us" {
+			continue
+		}
+		v, err := strconv.ParseFloat(values[i].String, 64)
+		if err != nil {
+			continue
+		}
+		name := column
+		switch {
+		case column == "maxwait":
+			if us, err := strconv.ParseFloat(byName["maxwait_us"].String, 64); err == nil {
+				v += us / 1e6
+			}
+			name += "_seconds"
+		case c.micros != nil && c.micros(column):
+			v /= 1e6
+			name += "_seconds"
+		}
+		slist.PushSample(inputName, c.prefix+"_"+name, v, labels)
+	}
+}
+
+// pushEnum pushes one gauge per state labeled by state, 1 for the current state and 0 for
+// the others, an unknown state is pushed as well
+func pushEnum(slist *types.SampleList, metric string, states []string, current string, labels map[string]string) {
+	current = strings.ToLower(current)
+	known := false
+	for _, state := range states {
+		v := 0
+		if state == current {
+			v = 1
+			known = true
+		}
+		slist.PushSample(inputName, metric, v, labels, map[string]string{"state": state})
+	}
+	if !known && current != "" {
+		slist.PushSample(inputName, metric, 1, labels, map[string]string{"state": current})
+	}
+}
@@ -0,0 +1,75 @@
+package pgbouncer
+
+import (
+	"database/sql"
+	"testing"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func nullStrings(ss ...string) []sql.NullString {
+	values := make([]sql.NullString, len(ss))
+	for i, s := range ss {
+		values[i] = sql.NullString{String: s, Valid: s != "NULL"}
+	}
+	return values
+}
+
+func samples(slist *types.SampleList) map[string]*types.Sample {
+	ret := make(map[string]*types.Sample)
+	for _, s := range slist.PopBackAll() {
+		key := s.Metric
+		if state, ok := s.Labels["state"]; ok {
+			key += "/" + state
+		}
+		ret[key] = s
+	}
+	return ret
+}
+
+func TestPushRowPools(t *testing.T) {
+	slist := types.NewSampleList()
+	columns := []string{"database", "user", "cl_active", "cl_waiting", "sv_active", "maxwait", "maxwait_us", "pool_mode"}
+	pushRow(slist, commands[0], columns, nullStrings("app", "app_user", "12", "3", "4", "2", "500000", "transaction"), map[string]string{"server": "127.0.0.1:6432"})
+
+	got := samples(slist)
+	if s := got["pgbouncer_pools_cl_waiting"]; s == nil || s.Value.(float64) != 3 ||
+		s.Labels["database"] != "app" || s.Labels["user"] != "app_user" || s.Labels["server"] != "127.0.0.1:6432" {
+		t.Fatalf("unexpected cl_waiting: %+v", s)
+	}
+	if s := got["pgbouncer_pools_maxwait_seconds"]; s == nil || s.Value.(float64) != 2.5 {
+		t.Fatalf("unexpected maxwait_seconds: %+v", s)
+	}
+	if _, ok := got["pgbouncer_pools_maxwait_us"]; ok {
+		t.Fatal("expected maxwait_us to be merged into maxwait_seconds")
+	}
+	if got["pgbouncer_pools_pool_mode/transaction"].Value != 1 || got["pgbouncer_pools_pool_mode/session"].Value != 0 {
+		t.Fatal("unexpected pool_mode states")
+	}
+}
+
+func TestPushRowStats(t *testing.T) {
+	slist := types.NewSampleList()
+	columns := []string{"database", "total_query_count", "total_query_time", "avg_wait_time"}
+	pushRow(slist, commands[1], columns, nullStrings("app", "100", "2500000", "NULL"), nil)
+
+	got := samples(slist)
+	if s := got["pgbouncer_stats_total_query_time_seconds"]; s == nil || s.Value.(float64) != 2.5 {
+		t.Fatalf("unexpected total_query_time_seconds: %+v", s)
+	}
+	if s := got["pgbouncer_stats_total_query_count"]; s == nil || s.Value.(float64) != 100 {
+		t.Fatalf("unexpected total_query_count: %+v", s)
+	}
+	if _, ok := got["pgbouncer_stats_avg_wait_time_seconds"]; ok {
+		t.Fatal("expected the NULL column to be skipped")
+	}
+}
+
+func TestPushEnumUnknownState(t *testing.T) {
+	slist := types.NewSampleList()
+	pushEnum(slist, "databases_pool_mode", poolModes, "Custom", nil)
+	got := samples(slist)
+	if len(got) != len(poolModes)+1 || got["pgbouncer_databases_pool_mode/custom"].Value != 1 {
+		t.Fatalf("unexpected states: %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+package powermetrics
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "powermetrics"
+
+// Powermetrics collects power and thermal metrics of macOS. powermetrics requires root,
+// without it only the metrics of pmset, which any user may run, are reported
+type Powermetrics struct {
+	config.PluginConfig
+
+	// sampling window of powermetrics
+	SampleRate config.Duration `toml:"sample_rate"`
+	// powermetrics samplers, defaults to cpu_power,gpu_power,thermal on Apple Silicon
+	// and smc,cpu_power,thermal on Intel
+	Samplers []string        `toml:"samplers"`
+	Timeout  config.Duration `toml:"timeout"`
+
+	warned bool
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Powermetrics{}
+	})
+}
+
+func (p *Powermetrics) Clone() inputs.Input {
+	return &Powermetrics{}
+}
+
+func (p *Powermetrics) Name() string {
+	return inputName
+}
+
+func (p *Powermetrics) Init() error {
+	if p.SampleRate == 0 {
+		p.SampleRate = config.Duration(time.Second)
+	}
+	if p.Timeout == 0 {
+		p.Timeout = config.Duration(10 * time.Second)
+	}
+	return nil
+}
+
+var (
+	// E-Cluster HW active frequency: 1020 MHz, P0-Cluster HW active residency:  40.03% (...)
+	clusterRe = regexp.MustCompile(`^([A-Z][0-9]*)-Cluster HW active (frequency|residency):\s+([0-9.]+)`)
+	// CPU Power: 123 mW, Combined Power (CPU + GPU + ANE): 133 mW
+	powerRe = regexp.MustCompile(`^(CPU|GPU|ANE|Combined) Power[^:]*:\s+([0-9.]+)\s*mW`)
+	// Intel energy model derived package power (CPUs+GT+SA): 3.12W
+	packagePowerRe = regexp.MustCompile(`package power[^:]*:\s+([0-9.]+)\s*W`)
+	// CPU die temperature: 52.38 C
+	temperatureRe = regexp.MustCompile(`^(CPU|GPU) die temperature:\s+([0-9.]+)\s*C`)
+	// Fan: 1798.58 rpm
+	fanRe = regexp.MustCompile(`^Fan:\s+([0-9.]+)\s*rpm`)
+	// CPU Thermal level: 0
+	thermalLevelRe = regexp.MustCompile(`^(CPU|GPU|IO) Thermal level:\s+([0-9]+)`)
+	// GPU HW active frequency: 389 MHz, GPU HW active residency:   2.51% (...)
+	gpuRe = regexp.MustCompile(`^GPU HW active (frequency|residency):\s+([0-9.]+)`)
+)
+
+// thermalPressure maps the pressure levels of the thermal sampler, Apple Silicon reports
+// Nominal/Fair/Serious/Critical and Intel reports Nominal/Moderate/Heavy/Trapping/Sleeping
+var thermalPressure = map[string]int{
+	"nominal":  0,
+	"fair":     1,
+	"moderate": 1,
+	"serious":  2,
+	"heavy":    2,
+	"trapping": 3,
+	"critical": 3,
+	"sleeping": 4,
+}
+
+// parsePowermetrics parses the text output of powermetrics, only the lines the
+// samplers actually printed become samples
+func parsePowermetrics(out []byte, slist *types.SampleList) {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if m := clusterRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[3], 64)
+			if m[2] == "frequency" {
+				slist.PushSample(inputName, "cluster_active_frequency_mhz", v, map[string]string{"cluster": m[1]})
+			} else {
+				slist.PushSample(inputName, "cluster_active_residency_percent", v, map[string]string{"cluster": m[1]})
+			}
+			continue
+		}
+		if m := powerRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[2], 64)
+			slist.PushSample(inputName, strings.ToLower(m[1])+"_power_watts", v/1000)
+			continue
+		}
+		if m := packagePowerRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[1], 64)
+			slist.PushSample(inputName, "package_power_watts", v)
+			continue
+		}
+		if m := temperatureRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[2], 64)
+			slist.PushSample(inputName, strings.ToLower(m[1])+"_die_temperature_celsius", v)
+			continue
+		}
+		if m := fanRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[1], 64)
+			slist.PushSample(inputName, "fan_rpm", v)
+			continue
+		}
+		if m := thermalLevelRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[2], 64)
+			slist.PushSample(inputName, "thermal_level", v, map[string]string{"component": strings.ToLower(m[1])})
+			continue
+		}
+		if m := gpuRe.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[2], 64)
+			if m[1] == "frequency" {
+				slist.PushSample(inputName, "gpu_active_frequency_mhz", v)
+			} else {
+				slist.PushSample(inputName, "gpu_active_residency_percent", v)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "Current pressure level:") {
+			level := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, "Current pressure level:")))
+			if v, ok := thermalPressure[level]; ok {
+				slist.PushSample(inputName, "thermal_pressure", v)
+			}
+		}
+	}
+}
+
+// parsePmsetTherm parses pmset -g therm, Apple Silicon prints notes only and
+// produces no samples
+func parsePmsetTherm(out []byte, slist *types.SampleList) {
+	fields := map[string]string{
+		"CPU_Speed_Limit":     "cpu_speed_limit_percent",
+		"CPU_Scheduler_Limit": "cpu_scheduler_limit_percent",
+		"CPU_Available_CPUs":  "cpu_available_cpus",
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		k, v, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		name, ok := fields[strings.TrimSpace(k)]
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			continue
+		}
+		slist.PushSample(inputName, name, value)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build darwin
+
+package powermetrics
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/pkg/cmdx"
+	"flashcat.cloud/categraf/types"
+)
+
+func (p *Powermetrics) samplers() []string {
+	if len(p.Samplers) > 0 {
+		return p.Samplers
+	}
+	// the smc sampler only exists on Intel
+	if runtime.GOARCH == "arm64" {
+		return []string{"cpu_power", "gpu_power", "thermal"}
+	}
+	return []string{"smc", "cpu_power", "thermal"}
+}
+
+func (p *Powermetrics) Gather(slist *types.SampleList) {
+	// every source degrades on its own, a failure of powermetrics keeps the pmset metrics
+	p.gatherPmset(slist)
+	p.gatherPowermetrics(slist)
+}
+
+func (p *Powermetrics) gatherPmset(slist *types.SampleList) {
+	out, err := p.run("pmset", "-g", "therm")
+	if err != nil {
+		log.Println("E! failed to run pmset -g therm:", err)
+		slist.PushSample(inputName, "up", 0, map[string]string{"source": "pmset"})
+		return
+	}
+	slist.PushSample(inputName, "up", 1, map[string]string{"source": "pmset"})
+	parsePmsetTherm(out, slist)
+}
+
+func (p *Powermetrics) gatherPowermetrics(slist *types.SampleList) {
+	if os.Geteuid() != 0 {
+		if !p.warned {
+			log.Println("W! powermetrics requires root, power and temperature metrics are skipped")
+			p.warned = true
+		}
+		slist.PushSample(inputName, "up", 0, map[string]string{"source": "powermetrics"})
+		return
+	}
+
+	out, err := p.run("powermetrics",
+		"--samplers", strings.Join(p.samplers(), ","),
+		"-n", "1",
+		"-i", strconv.FormatInt(time.Duration(p.SampleRate).Milliseconds(), 10))
+	if err != nil {
+		log.Println("E! failed to run powermetrics:", err)
+		slist.PushSample(inputName, "up", 0, map[string]string{"source": "powermetrics"})
+		return
+	}
+	slist.PushSample(inputName, "up", 1, map[string]string{"source": "powermetrics"})
+	parsePowermetrics(out, slist)
+}
+
+func (p *Powermetrics) run(name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err, timeout := cmdx.RunTimeout(cmd, time.Duration(p.Timeout))
+	if timeout {
+		return nil, fmt.Errorf("run command: %s timeout", cmd)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run command: %s error: %v stderr: %s", cmd, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,10 @@
+//go:build !darwin
+
+package powermetrics
+
+import (
+	"flashcat.cloud/categraf/types"
+)
+
+func (p *Powermetrics) Gather(slist *types.SampleList) {
+}
@@ -27,7 +27,10 @@ type Instance struct {
 	SearchExecSubstring    string   `toml:"search_exec_substring"`
 	SearchCmdlineSubstring string   `toml:"search_cmdline_substring"`
 	SearchWinService       string   `toml:"search_win_service"`
+	SearchPidFile          string   `toml:"search_pid_file"`
+	SearchSystemdUnit      string   `toml:"search_systemd_unit"`
 	SearchUser             string   `toml:"search_user"`
+	ProcessName            string   `toml:"process_name"`
 	Mode                   string   `toml:"mode"`
 	GatherTotal            bool     `toml:"gather_total"`
 	GatherPerPid           bool     `toml:"gather_per_pid"`
@@ -70,8 +73,14 @@ func (ins *Instance) Init() error {
 		ins.searchCmdLineRegexp = regexp.MustCompile(ins.SearchCmdLineRegexp)
 		ins.searchString = ins.SearchCmdLineRegexp
 		log.Println("I! procstat: search_cmdline_regexp:", ins.SearchCmdLineRegexp)
+	} else if ins.SearchPidFile != "" {
+		ins.searchString = ins.SearchPidFile
+		log.Println("I! procstat: search_pid_file:", ins.SearchPidFile)
+	} else if ins.SearchSystemdUnit != "" {
+		ins.searchString = ins.SearchSystemdUnit
+		log.Println("I! procstat: search_systemd_unit:", ins.SearchSystemdUnit)
 	} else {
-		return errors.New("the fields should not be all blank: search_exec_substring, search_cmdline_substring, search_win_service")
+		return errors.New("the fields should not be all blank: search_exec_substring, search_cmdline_substring, search_win_service, search_exec_regexp, search_cmdline_regexp, search_pid_file, search_systemd_unit")
 	}
 
 	return nil
@@ -140,6 +149,9 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		tags = map[string]string{"search_string": ins.searchString}
 		opts = []Filter{}
 	)
+	if ins.ProcessName != "" {
+		tags["process_name"] = ins.ProcessName
+	}
 
 	if ins.SearchUser != "" {
 		opts = append(opts, UserFilter(ins.SearchUser))
@@ -158,6 +170,10 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		pids, err = pg.FullPattern(ins.SearchCmdlineSubstring, opts...)
 	} else if ins.SearchWinService != "" {
 		pids, err = ins.winServicePIDs()
+	} else if ins.SearchPidFile != "" {
+		pids, err = pg.PidFile(ins.SearchPidFile)
+	} else if ins.SearchSystemdUnit != "" {
+		pids, err = systemdUnitPIDs(ins.SearchSystemdUnit)
 	} else {
 		log.Println("E! Oops... search string not found")
 		return
@@ -184,6 +200,8 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		switch field {
 		case "threads":
 			ins.gatherThreads(slist, ins.procs, tags)
+		case "ctx_switches":
+			ins.gatherCtxSwitches(slist, ins.procs, tags)
 		case "fd":
 			ins.gatherFD(slist, ins.procs, tags)
 		case "io":
@@ -255,7 +273,7 @@ func (ins *Instance) gatherThreads(slist *types.SampleList, procs map[PID]Proces
 		if err == nil {
 			val += v
 			if ins.GatherPerPid {
-				slist.PushFront(types.NewSample(inputName, "num_threads", val, ins.makeProcTag(procs[pid]), tags))
+				slist.PushFront(types.NewSample(inputName, "num_threads", v, ins.makeProcTag(procs[pid]), tags))
 			}
 		}
 	}
@@ -265,6 +283,26 @@ func (ins *Instance) gatherThreads(slist *types.SampleList, procs map[PID]Proces
 	}
 }
 
+func (ins *Instance) gatherCtxSwitches(slist *types.SampleList, procs map[PID]Process, tags map[string]string) {
+	var voluntary, involuntary int64
+	for pid := range procs {
+		cs, err := procs[pid].NumCtxSwitches()
+		if err == nil {
+			voluntary += cs.Voluntary
+			involuntary += cs.Involuntary
+			if ins.GatherPerPid {
+				slist.PushFront(types.NewSample(inputName, "voluntary_context_switches", cs.Voluntary, ins.makeProcTag(procs[pid]), tags))
+				slist.PushFront(types.NewSample(inputName, "involuntary_context_switches", cs.Involuntary, ins.makeProcTag(procs[pid]), tags))
+			}
+		}
+	}
+
+	if ins.GatherTotal {
+		slist.PushFront(types.NewSample(inputName, "voluntary_context_switches_total", voluntary, tags))
+		slist.PushFront(types.NewSample(inputName, "involuntary_context_switches_total", involuntary, tags))
+	}
+}
+
 func (ins *Instance) gatherFD(slist *types.SampleList, procs map[PID]Process, tags map[string]string) {
 	var val int32
 	for pid := range procs {
@@ -272,7 +310,7 @@ func (ins *Instance) gatherFD(slist *types.SampleList, procs map[PID]Process, ta
 		if err == nil {
 			val += v
 			if ins.GatherPerPid {
-				slist.PushFront(types.NewSample(inputName, "num_fds", val, ins.makeProcTag(procs[pid]), tags))
+				slist.PushFront(types.NewSample(inputName, "num_fds", v, ins.makeProcTag(procs[pid]), tags))
 			}
 		}
 	}
@@ -298,10 +336,10 @@ func (ins *Instance) gatherIO(slist *types.SampleList, procs map[PID]Process, ta
 			readBytes += io.ReadBytes
 			writeBytes += io.WriteBytes
 			if ins.GatherPerPid {
-				slist.PushFront(types.NewSample(inputName, "read_count", readCount, ins.makeProcTag(procs[pid]), tags))
-				slist.PushFront(types.NewSample(inputName, "write_count", writeCount, ins.makeProcTag(procs[pid]), tags))
-				slist.PushFront(types.NewSample(inputName, "read_bytes", readBytes, ins.makeProcTag(procs[pid]), tags))
-				slist.PushFront(types.NewSample(inputName, "write_bytes", writeBytes, ins.makeProcTag(procs[pid]), tags))
+				slist.PushFront(types.NewSample(inputName, "read_count", io.ReadCount, ins.makeProcTag(procs[pid]), tags))
+				slist.PushFront(types.NewSample(inputName, "write_count", io.WriteCount, ins.makeProcTag(procs[pid]), tags))
+				slist.PushFront(types.NewSample(inputName, "read_bytes", io.ReadBytes, ins.makeProcTag(procs[pid]), tags))
+				slist.PushFront(types.NewSample(inputName, "write_bytes", io.WriteBytes, ins.makeProcTag(procs[pid]), tags))
 			}
 		}
 	}
@@ -0,0 +1,49 @@
+package procstat
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// systemdUnitPIDs returns the pids of all the processes of the control group of the unit,
+// not only its main pid, so the workers of a forking service are included
+func systemdUnitPIDs(unit string) ([]PID, error) {
+	bin, err := exec.LookPath("systemctl")
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.Command(bin, "show", "--property=ControlGroup", "--value", unit).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the control group of unit %s: %v", unit, err)
+	}
+	cgroup := strings.TrimSpace(string(out))
+	if cgroup == "" {
+		// the unit is not running
+		return nil, nil
+	}
+
+	// cgroup v2 (unified) first, then the systemd hierarchy of cgroup v1
+	var content []byte
+	for _, dir := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/systemd", "/sys/fs/cgroup/unified"} {
+		if content, err = os.ReadFile(filepath.Join(dir, cgroup, "cgroup.procs")); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the processes of unit %s: %v", unit, err)
+	}
+
+	var pids []PID
+	for _, line := range strings.Fields(string(content)) {
+		pid, err := strconv.ParseInt(line, 10, 32)
+		if err != nil {
+			continue
+		}
+		pids = append(pids, PID(pid))
+	}
+	return pids, nil
+}
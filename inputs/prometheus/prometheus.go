@@ -20,6 +20,7 @@ import (
 
 const inputName = "prometheus"
 const acceptHeader = `application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.7,text/plain;version=0.0.4;q=0.3,*/*;q=0.1`
+const acceptHeaderOpenMetrics = `application/openmetrics-text;version=1.0.0;q=0.8,` + acceptHeader
 
 type Instance struct {
 	config.InstanceConfig
@@ -37,6 +38,9 @@ type Instance struct {
 	Headers           []string        `toml:"headers"`
 
 	DuplicationAllowed bool `toml:"duplication_allowed"`
+	// prefer the OpenMetrics format when the target supports it, OpenMetrics
+	// responses are parsed regardless of this option
+	OpenMetrics bool `toml:"openmetrics"`
 
 	config.UrlLabel
 
@@ -256,7 +260,11 @@ func (ins *Instance) setHeaders(req *http.Request) {
 		req.Header.Set("Authorization", "Bearer "+ins.BearerTokenString)
 	}
 
-	req.Header.Set("Accept", acceptHeader)
+	if ins.OpenMetrics {
+		req.Header.Set("Accept", acceptHeaderOpenMetrics)
+	} else {
+		req.Header.Set("Accept", acceptHeader)
+	}
 
 	for i := 0; i < len(ins.Headers); i += 2 {
 		req.Header.Set(ins.Headers[i], ins.Headers[i+1])
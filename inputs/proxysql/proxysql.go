@@ -0,0 +1,219 @@
+package proxysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/stringx"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "proxysql"
+
+type ProxySQL struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// address of the admin interface of proxysql, host:port
+	Address string `toml:"address"`
+	// a user of admin-admin_credentials or admin-stats_credentials of proxysql
+	Username string          `toml:"username"`
+	Password string          `toml:"password"`
+	Timeout  config.Duration `toml:"timeout"`
+
+	dsn string
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(ProxySQL)
+var _ inputs.InstancesGetter = new(ProxySQL)
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &ProxySQL{}
+	})
+}
+
+func (p *ProxySQL) Clone() inputs.Input {
+	return &ProxySQL{}
+}
+
+func (p *ProxySQL) Name() string {
+	return inputName
+}
+
+func (p *ProxySQL) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(p.Instances))
+	for i := 0; i < len(p.Instances); i++ {
+		ret[i] = p.Instances[i]
+	}
+	return ret
+}
+
+func (ins *Instance) Init() error {
+	if ins.Address == "" {
+		return types.ErrInstancesEmpty
+	}
+	if ins.Timeout <= 0 {
+		ins.Timeout = config.Duration(5 * time.Second)
+	}
+
+	conf := mysql.NewConfig()
+	conf.User = ins.Username
+	conf.Passwd = ins.Password
+	conf.Net = "tcp"
+	if strings.HasSuffix(ins.Address, ".sock") {
+		conf.Net = "unix"
+	}
+	conf.Addr = ins.Address
+	conf.Timeout = time.Duration(ins.Timeout)
+	ins.dsn = conf.FormatDSN()
+	return nil
+}
+
+// the status of the backends in stats_mysql_connection_pool
+var backendStatuses = []string{"ONLINE", "SHUNNED", "OFFLINE_SOFT", "OFFLINE_HARD", "SHUNNED_REPLICATION_LAG"}
+
+// the columns of stats_mysql_connection_pool which are labels, by label name
+var poolLabels = map[string]string{"hostgroup": "hostgroup", "srv_host": "host", "srv_port": "port"}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	tags := map[string]string{"address": ins.Address}
+
+	db, err := sql.Open("mysql", ins.dsn)
+	if err != nil {
+		slist.PushSample(inputName, "up", 0, tags)
+		log.Println("E! failed to open proxysql:", err)
+		return
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(ins.Timeout))
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		slist.PushSample(inputName, "up", 0, tags)
+		log.Println("E! failed to ping proxysql", ins.Address, ":", err)
+		return
+	}
+	slist.PushSample(inputName, "up", 1, tags)
+
+	if err := gatherConnectionPool(ctx, db, slist, tags); err != nil {
+		log.Println("E! failed to query stats_mysql_connection_pool of proxysql", ins.Address, ":", err)
+	}
+	if err := gatherGlobal(ctx, db, slist, tags); err != nil {
+		log.Println("E! failed to query stats_mysql_global of proxysql", ins.Address, ":", err)
+	}
+}
+
+func gatherConnectionPool(ctx context.Context, db *sql.DB, slist *types.SampleList, tags map[string]string) error {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM stats_mysql_connection_pool")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		pushBackend(slist, columns, values, tags)
+	}
+	return rows.Err()
+}
+
+// pushBackend pushes the status and the numeric columns of a row of stats_mysql_connection_pool,
+// the columns of the newer proxysql versions are exported as they appear
+func pushBackend(slist *types.SampleList, columns []string, values []sql.NullString, tags map[string]string) {
+	labels := make(map[string]string, len(tags)+len(poolLabels))
+	for k, v := range tags {
+		labels[k] = v
+	}
+	for i, column := range columns {
+		if label, ok := poolLabels[column]; ok && values[i].Valid {
+			labels[label] = values[i].String
+		}
+	}
+
+	for i, column := range columns {
+		if _, ok := poolLabels[column]; ok || !values[i].Valid {
+			continue
+		}
+		if column == "status" {
+			pushStatus(slist, values[i].String, labels)
+			continue
+		}
+		v, err := strconv.ParseFloat(values[i].String, 64)
+		if err != nil {
+			continue
+		}
+		name := stringx.SnakeCase(column)
+		if strings.HasSuffix(name, "_us") {
+			v /= 1e6
+			name = strings.TrimSuffix(name, "_us") + "_seconds"
+		}
+		slist.PushSample(inputName, "connection_pool_"+name, v, labels)
+	}
+}
+
+// pushStatus pushes one gauge per status labeled by status, 1 for the current status and 0
+// for the others, an unknown status is pushed as well
+func pushStatus(slist *types.SampleList, current string, labels map[string]string) {
+	current = strings.ToUpper(current)
+	known := false
+	for _, status := range backendStatuses {
+		v := 0
+		if status == current {
+			v = 1
+			known = true
+		}
+		slist.PushSample(inputName, "connection_pool_status", v, labels, map[string]string{"status": status})
+	}
+	if !known && current != "" {
+		slist.PushSample(inputName, "connection_pool_status", 1, labels, map[string]string{"status": current})
+	}
+}
+
+func gatherGlobal(ctx context.Context, db *sql.DB, slist *types.SampleList, tags map[string]string) error {
+	rows, err := db.QueryContext(ctx, "SELECT Variable_Name, Variable_Value FROM stats_mysql_global")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(value.String, 64)
+		if !value.Valid || err != nil {
+			continue
+		}
+		slist.PushSample(inputName, fmt.Sprintf("global_%s", stringx.SnakeCase(name)), v, tags)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,45 @@
+package proxysql
+
+import (
+	"database/sql"
+	"testing"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestPushBackend(t *testing.T) {
+	columns := []string{"hostgroup", "srv_host", "srv_port", "status", "ConnUsed", "ConnFree", "ConnERR", "Latency_us"}
+	row := []string{"10", "10.0.0.1", "3306", "SHUNNED", "4", "6", "2", "1500"}
+	values := make([]sql.NullString, len(row))
+	for i, s := range row {
+		values[i] = sql.NullString{String: s, Valid: true}
+	}
+
+	slist := types.NewSampleList()
+	pushBackend(slist, columns, values, map[string]string{"address": "127.0.0.1:6032"})
+
+	got := make(map[string]*types.Sample)
+	for _, s := range slist.PopBackAll() {
+		key := s.Metric
+		if status, ok := s.Labels["status"]; ok {
+			key += "/" + status
+		}
+		got[key] = s
+	}
+	if s := got["proxysql_connection_pool_conn_used"]; s == nil || s.Value.(float64) != 4 ||
+		s.Labels["hostgroup"] != "10" || s.Labels["host"] != "10.0.0.1" || s.Labels["port"] != "3306" {
+		t.Fatalf("unexpected conn_used: %+v", s)
+	}
+	if s := got["proxysql_connection_pool_conn_err"]; s == nil || s.Value.(float64) != 2 {
+		t.Fatalf("unexpected conn_err: %+v", s)
+	}
+	if s := got["proxysql_connection_pool_latency_seconds"]; s == nil || s.Value.(float64) != 0.0015 {
+		t.Fatalf("unexpected latency_seconds: %+v", s)
+	}
+	if got["proxysql_connection_pool_status/SHUNNED"].Value != 1 || got["proxysql_connection_pool_status/ONLINE"].Value != 0 {
+		t.Fatal("unexpected status states")
+	}
+	if len(got) != 4+len(backendStatuses) {
+		t.Fatalf("unexpected samples: %v", got)
+	}
+}
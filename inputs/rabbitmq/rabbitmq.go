@@ -62,6 +62,8 @@ type Instance struct {
 	MetricExclude             []string `toml:"metric_exclude"`
 	QueueInclude              []string `toml:"queue_name_include"`
 	QueueExclude              []string `toml:"queue_name_exclude"`
+	VhostInclude              []string `toml:"vhost_include"`
+	VhostExclude              []string `toml:"vhost_exclude"`
 	FederationUpstreamInclude []string `toml:"federation_upstream_include"`
 	FederationUpstreamExclude []string `toml:"federation_upstream_exclude"`
 
@@ -70,6 +72,7 @@ type Instance struct {
 
 	metricFilter   filter.Filter
 	queueFilter    filter.Filter
+	vhostFilter    filter.Filter
 	upstreamFilter filter.Filter
 
 	excludeEveryQueue bool
@@ -86,6 +89,10 @@ func (ins *Instance) Init() error {
 		return err
 	}
 
+	if ins.vhostFilter, err = filter.NewIncludeExcludeFilter(ins.VhostInclude, ins.VhostExclude); err != nil {
+		return err
+	}
+
 	if ins.upstreamFilter, err = filter.NewIncludeExcludeFilter(ins.FederationUpstreamInclude, ins.FederationUpstreamExclude); err != nil {
 		return err
 	}
@@ -485,7 +492,7 @@ func gatherExchanges(ins *Instance, slist *types.SampleList) {
 	}
 
 	for _, exchange := range exchanges {
-		if !ins.shouldGatherExchange(exchange.Name) {
+		if !ins.vhostFilter.Match(exchange.Vhost) || !ins.shouldGatherExchange(exchange.Name) {
 			continue
 		}
 		tags := map[string]string{
@@ -568,7 +575,7 @@ func gatherFederationLinks(ins *Instance, slist *types.SampleList) {
 }
 
 func (ins *Instance) shouldGatherFederationLink(link FederationLink) bool {
-	if !ins.upstreamFilter.Match(link.Upstream) {
+	if !ins.vhostFilter.Match(link.Vhost) || !ins.upstreamFilter.Match(link.Upstream) {
 		return false
 	}
 
@@ -735,7 +742,7 @@ func gatherQueues(ins *Instance, slist *types.SampleList) {
 	}
 
 	for _, queue := range queues {
-		if !ins.queueFilter.Match(queue.Name) {
+		if !ins.vhostFilter.Match(queue.Vhost) || !ins.queueFilter.Match(queue.Name) {
 			continue
 		}
 
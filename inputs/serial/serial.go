@@ -0,0 +1,315 @@
+package serial
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/parser"
+	"flashcat.cloud/categraf/parser/falcon"
+	"flashcat.cloud/categraf/parser/influx"
+	jsonparser "flashcat.cloud/categraf/parser/json"
+	"flashcat.cloud/categraf/parser/prometheus"
+	"flashcat.cloud/categraf/parser/regex"
+	"flashcat.cloud/categraf/parser/value"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "serial"
+
+type Serial struct {
+	config.PluginConfig
+
+	Instances []*Instance `toml:"instances"`
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// e.g. /dev/ttyUSB0
+	Device   string `toml:"device"`
+	BaudRate int    `toml:"baud_rate"`
+	DataBits int    `toml:"data_bits"`
+	// none, odd or even
+	Parity   string `toml:"parity"`
+	StopBits int    `toml:"stop_bits"`
+	// the device is reopened when nothing is read for read_timeout
+	ReadTimeout       config.Duration `toml:"read_timeout"`
+	ReconnectInterval config.Duration `toml:"reconnect_interval"`
+	// the longer lines are dropped
+	MaxLineLength int `toml:"max_line_length"`
+
+	// value, regex, json, influx, falcon or prometheus
+	DataFormat string `toml:"data_format"`
+	// the metric of the value format and the prefix of the regex and json formats
+	MetricName string `toml:"metric_name"`
+	Regex      string `toml:"regex"`
+	// the capture groups of regex or the fields of json which are labels
+	TagKeys []string `toml:"tag_keys"`
+
+	parser parser.Parser
+	slist  *types.SampleList
+
+	connected   atomic.Bool
+	lines       atomic.Uint64
+	parseErrors atomic.Uint64
+	dropped     atomic.Uint64
+	reconnects  atomic.Uint64
+
+	mu   sync.Mutex
+	port *os.File
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Serial)
+var _ inputs.InstancesGetter = new(Serial)
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Serial{}
+	})
+}
+
+func (s *Serial) Clone() inputs.Input {
+	return &Serial{}
+}
+
+func (s *Serial) Name() string {
+	return inputName
+}
+
+func (s *Serial) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(s.Instances))
+	for i := 0; i < len(s.Instances); i++ {
+		ret[i] = s.Instances[i]
+	}
+	return ret
+}
+
+func (s *Serial) Drop() {
+	for i := 0; i < len(s.Instances); i++ {
+		s.Instances[i].Drop()
+	}
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Device) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.BaudRate == 0 {
+		ins.BaudRate = 9600
+	}
+	if ins.DataBits == 0 {
+		ins.DataBits = 8
+	}
+	if ins.StopBits == 0 {
+		ins.StopBits = 1
+	}
+	if ins.ReadTimeout <= 0 {
+		ins.ReadTimeout = config.Duration(time.Minute)
+	}
+	if ins.ReconnectInterval <= 0 {
+		ins.ReconnectInterval = config.Duration(5 * time.Second)
+	}
+	if ins.MaxLineLength <= 0 {
+		ins.MaxLineLength = 4096
+	}
+	if ins.MetricName == "" {
+		ins.MetricName = inputName
+	}
+	if err := validateSettings(ins.BaudRate, ins.DataBits, ins.Parity, ins.StopBits); err != nil {
+		return err
+	}
+
+	var err error
+	switch ins.DataFormat {
+	case "", "value":
+		ins.parser = value.NewParser(ins.MetricName)
+	case "regex":
+		ins.parser, err = regex.NewParser(ins.Regex, ins.MetricName, ins.TagKeys)
+	case "json":
+		ins.parser = jsonparser.NewParser(ins.MetricName, ins.TagKeys)
+	case "influx":
+		ins.parser = influx.NewParser()
+	case "falcon":
+		ins.parser = falcon.NewParser()
+	case "prometheus":
+		ins.parser = prometheus.EmptyParser()
+	default:
+		err = fmt.Errorf("data_format(%s) not supported", ins.DataFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	ins.slist = types.NewSampleList()
+	ins.done = make(chan struct{})
+	ins.wg.Add(1)
+	go ins.run()
+	return nil
+}
+
+// run reads the device until the instance is dropped, the device is reopened every
+// reconnect_interval when it fails, e.g. when the usb adapter is unplugged
+func (ins *Instance) run() {
+	defer ins.wg.Done()
+	for {
+		err := ins.readDevice()
+		ins.connected.Store(false)
+		select {
+		case <-ins.done:
+			return
+		default:
+		}
+		if err != nil {
+			log.Println("E! failed to read serial device", ins.Device, "reconnecting in", time.Duration(ins.ReconnectInterval), ":", err)
+		}
+		select {
+		case <-ins.done:
+			return
+		case <-time.After(time.Duration(ins.ReconnectInterval)):
+			ins.reconnects.Add(1)
+		}
+	}
+}
+
+func (ins *Instance) readDevice() error {
+	port, err := openPort(ins.Device, ins.BaudRate, ins.DataBits, ins.Parity, ins.StopBits)
+	if err != nil {
+		return err
+	}
+	ins.mu.Lock()
+	select {
+	case <-ins.done:
+		ins.mu.Unlock()
+		port.Close()
+		return nil
+	default:
+	}
+	ins.port = port
+	ins.mu.Unlock()
+	defer func() {
+		ins.mu.Lock()
+		ins.port = nil
+		ins.mu.Unlock()
+		port.Close()
+	}()
+
+	ins.connected.Store(true)
+	if ins.DebugMod {
+		log.Println("D! serial device", ins.Device, "opened")
+	}
+	return ins.readLines(&deadlineReader{f: port, timeout: time.Duration(ins.ReadTimeout)})
+}
+
+// readLines parses the lines of r, the lines longer than max_line_length are dropped
+func (ins *Instance) readLines(r io.Reader) error {
+	reader := bufio.NewReaderSize(r, ins.MaxLineLength)
+	tooLong := false
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			if !tooLong {
+				ins.dropped.Add(1)
+				log.Println("W! serial device", ins.Device, "line longer than", ins.MaxLineLength, "bytes dropped")
+			}
+			tooLong = true
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return fmt.Errorf("nothing read for %s", time.Duration(ins.ReadTimeout))
+			}
+			if err == io.EOF {
+				return errors.New("device closed")
+			}
+			return err
+		}
+		if tooLong {
+			// the end of the dropped line
+			tooLong = false
+			continue
+		}
+		ins.handleLine(bytes.TrimRight(line, "\r\n"))
+	}
+}
+
+func (ins *Instance) handleLine(line []byte) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	ins.lines.Add(1)
+	slist := types.NewSampleList()
+	if err := ins.parser.Parse(line, slist); err != nil {
+		ins.parseErrors.Add(1)
+		if ins.DebugMod {
+			log.Println("D! failed to parse serial line", strings.TrimSpace(string(line)), ":", err)
+		}
+		return
+	}
+	now := time.Now()
+	samples := slist.PopBackAll()
+	for _, s := range samples {
+		s.Labels["device"] = ins.Device
+		if s.Timestamp.IsZero() {
+			s.SetTime(now)
+		}
+	}
+	ins.slist.PushFrontN(samples)
+}
+
+// Gather hands over the samples read since the last gather
+func (ins *Instance) Gather(slist *types.SampleList) {
+	labels := map[string]string{"device": ins.Device}
+	up := 0
+	if ins.connected.Load() {
+		up = 1
+	}
+	slist.PushSample(inputName, "up", up, labels)
+	slist.PushSamples(inputName, map[string]interface{}{
+		"lines_total":         ins.lines.Load(),
+		"parse_errors_total":  ins.parseErrors.Load(),
+		"dropped_lines_total": ins.dropped.Load(),
+		"reconnects_total":    ins.reconnects.Load(),
+	}, labels)
+	slist.PushFrontN(ins.slist.PopBackAll())
+}
+
+func (ins *Instance) Drop() {
+	if ins.done == nil {
+		return
+	}
+	ins.mu.Lock()
+	close(ins.done)
+	if ins.port != nil {
+		// unblocks the read
+		ins.port.Close()
+	}
+	ins.mu.Unlock()
+	ins.wg.Wait()
+}
+
+// deadlineReader fails the reads waiting longer than timeout
+type deadlineReader struct {
+	f       *os.File
+	timeout time.Duration
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if err := r.f.SetReadDeadline(time.Now().Add(r.timeout)); err != nil {
+		return 0, err
+	}
+	return r.f.Read(p)
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package serial
+
+import (
+	"errors"
+	"os"
+)
+
+var errNotSupported = errors.New("the serial input is only supported on linux and darwin")
+
+func validateSettings(baudRate, dataBits int, parity string, stopBits int) error {
+	return errNotSupported
+}
+
+func openPort(device string, baudRate, dataBits int, parity string, stopBits int) (*os.File, error) {
+	return nil, errNotSupported
+}
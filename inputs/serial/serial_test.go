@@ -0,0 +1,44 @@
+package serial
+
+import (
+	"strings"
+	"testing"
+
+	"flashcat.cloud/categraf/parser/regex"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestReadLines(t *testing.T) {
+	p, err := regex.NewParser(`^T=(?P<temperature>[0-9.]+) ID=(?P<sensor>\w+)$`, "serial", []string{"sensor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := &Instance{Device: "/dev/ttyUSB0", MaxLineLength: 32, parser: p, slist: types.NewSampleList()}
+
+	input := "T=21.5 ID=s1\r\n" +
+		"garbage\n" +
+		"T=" + strings.Repeat("9", 64) + " ID=s2\n" +
+		"\n" +
+		"T=22 ID=s3\n" +
+		"T=23 ID=s4"
+	if err := ins.readLines(strings.NewReader(input)); err == nil || err.Error() != "device closed" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	samples := ins.slist.PopBackAll()
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+	for i, want := range []struct {
+		sensor string
+		value  float64
+	}{{"s1", 21.5}, {"s3", 22}} {
+		s := samples[i]
+		if s.Metric != "serial_temperature" || s.Labels["sensor"] != want.sensor || s.Labels["device"] != "/dev/ttyUSB0" || s.Value != want.value {
+			t.Fatalf("unexpected sample %+v", s)
+		}
+	}
+	if ins.lines.Load() != 3 || ins.parseErrors.Load() != 1 || ins.dropped.Load() != 1 {
+		t.Fatalf("unexpected counters lines=%d parse_errors=%d dropped=%d", ins.lines.Load(), ins.parseErrors.Load(), ins.dropped.Load())
+	}
+}
@@ -0,0 +1,90 @@
+//go:build linux || darwin
+
+package serial
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func validateSettings(baudRate, dataBits int, parity string, stopBits int) error {
+	if !supportedBaudRate(baudRate) {
+		return fmt.Errorf("baud_rate %d not supported", baudRate)
+	}
+	if dataBits < 5 || dataBits > 8 {
+		return fmt.Errorf("invalid data_bits %d, must be 5 to 8", dataBits)
+	}
+	switch parity {
+	case "", "none", "odd", "even":
+	default:
+		return fmt.Errorf("invalid parity %q, must be none, odd or even", parity)
+	}
+	if stopBits != 1 && stopBits != 2 {
+		return fmt.Errorf("invalid stop_bits %d, must be 1 or 2", stopBits)
+	}
+	return nil
+}
+
+// openPort opens the device in raw mode, non blocking so that the reads have deadlines
+func openPort(device string, baudRate, dataBits int, parity string, stopBits int) (*os.File, error) {
+	f, err := os.OpenFile(device, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	var termiosErr error
+	err = rc.Control(func(fd uintptr) {
+		termiosErr = setTermios(int(fd), baudRate, dataBits, parity, stopBits)
+	})
+	if err == nil {
+		err = termiosErr
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to configure %s: %v", device, err)
+	}
+	return f, nil
+}
+
+func setTermios(fd, baudRate, dataBits int, parity string, stopBits int) error {
+	t, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF | unix.IXANY | unix.INPCK
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CRTSCTS
+	t.Cflag |= unix.CREAD | unix.CLOCAL
+	switch dataBits {
+	case 5:
+		t.Cflag |= unix.CS5
+	case 6:
+		t.Cflag |= unix.CS6
+	case 7:
+		t.Cflag |= unix.CS7
+	default:
+		t.Cflag |= unix.CS8
+	}
+	switch parity {
+	case "odd":
+		t.Cflag |= unix.PARENB | unix.PARODD
+		t.Iflag |= unix.INPCK
+	case "even":
+		t.Cflag |= unix.PARENB
+		t.Iflag |= unix.INPCK
+	}
+	if stopBits == 2 {
+		t.Cflag |= unix.CSTOPB
+	}
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	setBaudRate(t, baudRate)
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, t)
+}
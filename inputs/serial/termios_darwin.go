@@ -0,0 +1,20 @@
+//go:build darwin
+
+package serial
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TIOCGETA
+	ioctlSetTermios = unix.TIOCSETA
+)
+
+// the speeds of darwin are the baud rates
+func supportedBaudRate(baudRate int) bool {
+	return baudRate > 0
+}
+
+func setBaudRate(t *unix.Termios, baudRate int) {
+	t.Ispeed = uint64(baudRate)
+	t.Ospeed = uint64(baudRate)
+}
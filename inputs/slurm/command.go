@@ -0,0 +1,179 @@
+package slurm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/pkg/cmdx"
+)
+
+const (
+	// the jobs of every partition, including the hidden ones, one line per job of the arrays
+	squeueFormat = "%P|%T"
+	// sinfo -o has no allocated memory, the widths of -O are large enough for the values
+	sinfoFormat = "NodeList:256|,PartitionName:64|,StateLong:32|,CPUsState:48|,Memory:16|,AllocMem:16|"
+)
+
+func (ins *Instance) queryCommands() (*snapshot, error) {
+	out, err := ins.run(ins.SqueuePath, "-h", "-a", "-r", "-o", squeueFormat)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := parseSqueue(out)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = ins.run(ins.SinfoPath, "-h", "-a", "-N", "-O", sinfoFormat)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := parseSinfo(out)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = ins.run(ins.SdiagPath)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot{jobs: jobs, nodes: nodes, diag: parseSdiag(out)}, nil
+}
+
+func (ins *Instance) run(name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err, timeout := cmdx.RunTimeout(cmd, time.Duration(ins.Timeout))
+	if timeout {
+		return nil, fmt.Errorf("run command: %s timeout", strings.Join(cmd.Args, " "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run command: %s | error: %v | stderr: %s",
+			strings.Join(cmd.Args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// parseSqueue parses the partition|state lines of squeue, the partitions of the pending
+// jobs are separated by commas
+func parseSqueue(out []byte) ([]job, error) {
+	var jobs []job
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		partitions, state, ok := strings.Cut(line, "|")
+		if !ok {
+			return nil, fmt.Errorf("unexpected output from squeue, expected partition|state in %q", line)
+		}
+		jobs = append(jobs, job{
+			partitions: strings.Split(partitions, ","),
+			state:      strings.ToLower(strings.TrimSpace(state)),
+		})
+	}
+	return jobs, scanner.Err()
+}
+
+// parseSinfo parses the lines of sinfo -N -O sinfoFormat, the fields are padded with spaces
+func parseSinfo(out []byte) ([]node, error) {
+	var nodes []node
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("unexpected output from sinfo, expected 6 fields in %q", scanner.Text())
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		n := node{
+			name:      fields[0],
+			partition: strings.TrimSuffix(fields[1], "*"),
+			state:     nodeState(fields[2]),
+		}
+		// allocated/idle/other/total
+		cpus := strings.Split(fields[3], "/")
+		if len(cpus) != 4 {
+			return nil, fmt.Errorf("unexpected cpus state %q of node %s", fields[3], n.name)
+		}
+		for i, p := range []*float64{&n.cpusAllocated, &n.cpusIdle, &n.cpusOther, &n.cpusTotal} {
+			*p = parseNumber(cpus[i])
+		}
+		n.memory = parseNumber(fields[4])
+		n.memoryAllocated = parseNumber(fields[5])
+		if n.partition == "n/a" {
+			n.partition = ""
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, scanner.Err()
+}
+
+// parseNumber returns 0 for the values sinfo doesn't know, e.g. N/A
+func parseNumber(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "+"), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// parseSdiag parses the numeric statistics of sdiag, e.g. "Jobs submitted: 10" becomes
+// sdiag_jobs_submitted and "Last cycle: 1234" in the backfilling section becomes
+// scheduler_backfill_last_cycle, the cycles are in microseconds
+func parseSdiag(out []byte) map[string]float64 {
+	stats := make(map[string]float64)
+	prefix := "sdiag_"
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Main schedule statistics"):
+			prefix = "scheduler_main_"
+			continue
+		case strings.HasPrefix(line, "Backfilling stats"):
+			prefix = "scheduler_backfill_"
+			continue
+		case strings.HasPrefix(line, "Latency for"), strings.HasPrefix(line, "Remote Procedure Call"):
+			// the rpc statistics are per message type and per user
+			prefix = ""
+		}
+		if prefix == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			// e.g. the timestamps
+			continue
+		}
+		stats[prefix+sdiagKey(key)] = v
+	}
+	return stats
+}
+
+// sdiagKey returns depth_mean_try_depth for "Depth Mean (try depth)"
+func sdiagKey(s string) string {
+	s = strings.NewReplacer("(", " ", ")", " ", "-", " ").Replace(strings.ToLower(s))
+	return strings.Join(strings.Fields(s), "_")
+}
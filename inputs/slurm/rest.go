@@ -0,0 +1,233 @@
+package slurm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// diagKeys maps the statistics of the diag endpoint of slurmrestd to the statistics of sdiag
+var diagKeys = map[string]string{
+	"server_thread_count":       "sdiag_server_thread_count",
+	"agent_queue_size":          "sdiag_agent_queue_size",
+	"agent_count":               "sdiag_agent_count",
+	"agent_thread_count":        "sdiag_agent_thread_count",
+	"dbd_agent_queue_size":      "sdiag_dbd_agent_queue_size",
+	"jobs_submitted":            "sdiag_jobs_submitted",
+	"jobs_started":              "sdiag_jobs_started",
+	"jobs_completed":            "sdiag_jobs_completed",
+	"jobs_canceled":             "sdiag_jobs_canceled",
+	"jobs_failed":               "sdiag_jobs_failed",
+	"jobs_pending":              "sdiag_jobs_pending",
+	"jobs_running":              "sdiag_jobs_running",
+	"schedule_cycle_last":       "scheduler_main_last_cycle",
+	"schedule_cycle_max":        "scheduler_main_max_cycle",
+	"schedule_cycle_total":      "scheduler_main_total_cycles",
+	"schedule_cycle_mean":       "scheduler_main_mean_cycle",
+	"schedule_cycle_mean_depth": "scheduler_main_mean_depth_cycle",
+	"schedule_cycle_per_minute": "scheduler_main_cycles_per_minute",
+	"schedule_queue_length":     "scheduler_main_last_queue_length",
+	"bf_backfilled_jobs":        "scheduler_backfill_total_backfilled_jobs_since_last_slurm_start",
+	"bf_last_backfilled_jobs":   "scheduler_backfill_total_backfilled_jobs_since_last_stats_cycle_start",
+	"bf_backfilled_het_jobs":    "scheduler_backfill_total_backfilled_heterogeneous_job_components",
+	"bf_cycle_counter":          "scheduler_backfill_total_cycles",
+	"bf_cycle_last":             "scheduler_backfill_last_cycle",
+	"bf_cycle_max":              "scheduler_backfill_max_cycle",
+	"bf_cycle_mean":             "scheduler_backfill_mean_cycle",
+	"bf_last_depth":             "scheduler_backfill_last_depth_cycle",
+	"bf_last_depth_try":         "scheduler_backfill_last_depth_cycle_try_sched",
+	"bf_depth_mean":             "scheduler_backfill_depth_mean",
+	"bf_depth_mean_try":         "scheduler_backfill_depth_mean_try_depth",
+	"bf_queue_len":              "scheduler_backfill_last_queue_length",
+	"bf_queue_len_mean":         "scheduler_backfill_queue_length_mean",
+	"bf_table_size":             "scheduler_backfill_last_table_size",
+	"bf_table_size_mean":        "scheduler_backfill_mean_table_size",
+}
+
+// flexStrings decodes the strings of the old versions of slurmrestd and the lists of
+// strings of the new versions, e.g. the states
+type flexStrings []string
+
+func (s *flexStrings) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '"' {
+		var v string
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		*s = flexStrings{v}
+		return nil
+	}
+	var v []string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// flexNumber decodes the numbers of the old versions of slurmrestd and the
+// {"set": true, "infinite": false, "number": 1} of the new versions
+type flexNumber float64
+
+func (n *flexNumber) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '{' {
+		var v struct {
+			Set    bool    `json:"set"`
+			Number float64 `json:"number"`
+		}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return err
+		}
+		if v.Set {
+			*n = flexNumber(v.Number)
+		}
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(b, &v); err != nil {
+		// e.g. null
+		return nil
+	}
+	*n = flexNumber(v)
+	return nil
+}
+
+type restResult interface {
+	errors() error
+}
+
+type restResponse struct {
+	Errors []struct {
+		Error       string `json:"error"`
+		Description string `json:"description"`
+	} `json:"errors"`
+}
+
+type restJobs struct {
+	restResponse
+	Jobs []struct {
+		Partition string      `json:"partition"`
+		JobState  flexStrings `json:"job_state"`
+	} `json:"jobs"`
+}
+
+type restNodes struct {
+	restResponse
+	Nodes []struct {
+		Name          string      `json:"name"`
+		State         flexStrings `json:"state"`
+		Partitions    []string    `json:"partitions"`
+		CPUs          flexNumber  `json:"cpus"`
+		AllocCPUs     flexNumber  `json:"alloc_cpus"`
+		AllocIdleCPUs flexNumber  `json:"alloc_idle_cpus"`
+		RealMemory    flexNumber  `json:"real_memory"`
+		AllocMemory   flexNumber  `json:"alloc_memory"`
+	} `json:"nodes"`
+}
+
+type restDiag struct {
+	restResponse
+	Statistics map[string]json.RawMessage `json:"statistics"`
+}
+
+func (ins *Instance) queryREST() (*snapshot, error) {
+	var jobs restJobs
+	if err := ins.get("jobs", &jobs); err != nil {
+		return nil, err
+	}
+	var nodes restNodes
+	if err := ins.get("nodes", &nodes); err != nil {
+		return nil, err
+	}
+	var diag restDiag
+	if err := ins.get("diag", &diag); err != nil {
+		return nil, err
+	}
+
+	s := &snapshot{diag: make(map[string]float64)}
+	for _, j := range jobs.Jobs {
+		state := ""
+		if len(j.JobState) > 0 {
+			state = strings.ToLower(j.JobState[0])
+		}
+		s.jobs = append(s.jobs, job{partitions: strings.Split(j.Partition, ","), state: state})
+	}
+	for _, n := range nodes.Nodes {
+		base := node{
+			name:            n.Name,
+			state:           nodeState(strings.Join(n.State, "+")),
+			cpusAllocated:   float64(n.AllocCPUs),
+			cpusIdle:        float64(n.AllocIdleCPUs),
+			cpusTotal:       float64(n.CPUs),
+			memory:          float64(n.RealMemory),
+			memoryAllocated: float64(n.AllocMemory),
+		}
+		if other := base.cpusTotal - base.cpusAllocated - base.cpusIdle; other > 0 {
+			base.cpusOther = other
+		}
+		if len(n.Partitions) == 0 {
+			s.nodes = append(s.nodes, base)
+		}
+		for _, partition := range n.Partitions {
+			n := base
+			n.partition = partition
+			s.nodes = append(s.nodes, n)
+		}
+	}
+	for key, raw := range diag.Statistics {
+		name, ok := diagKeys[key]
+		if !ok {
+			continue
+		}
+		var v flexNumber
+		if err := json.Unmarshal(raw, &v); err == nil {
+			s.diag[name] = float64(v)
+		}
+	}
+	return s, nil
+}
+
+// get decodes the response of the endpoint of slurmrestd, e.g. /slurm/v0.0.39/jobs
+func (ins *Instance) get(endpoint string, v restResult) error {
+	u := fmt.Sprintf("%s/slurm/%s/%s", ins.URL, ins.APIVersion, endpoint)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if ins.User != "" {
+		req.Header.Set("X-SLURM-USER-NAME", ins.User)
+	}
+	if ins.Token != "" {
+		req.Header.Set("X-SLURM-USER-TOKEN", ins.Token)
+	}
+	resp, err := ins.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the response of %s: %v", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status code %d: %s", u, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode the response of %s: %v", u, err)
+	}
+	return v.errors()
+}
+
+func (r *restResponse) errors() error {
+	for _, e := range r.Errors {
+		if e.Error != "" || e.Description != "" {
+			return fmt.Errorf("slurmrestd error: %s %s", e.Error, e.Description)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package slurm
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/tls"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "slurm"
+
+type Slurm struct {
+	config.PluginConfig
+
+	Instances []*Instance `toml:"instances"`
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// the commands, looked up in PATH when not absolute
+	SqueuePath string `toml:"squeue_path"`
+	SinfoPath  string `toml:"sinfo_path"`
+	SdiagPath  string `toml:"sdiag_path"`
+	// timeout of each command or request
+	Timeout config.Duration `toml:"timeout"`
+
+	// slurmrestd is queried instead of the commands when url is set, e.g. http://slurmctld:6820
+	URL        string `toml:"url"`
+	APIVersion string `toml:"api_version"`
+	// the X-SLURM-USER-NAME and X-SLURM-USER-TOKEN headers, token is a JWT, e.g. from scontrol token
+	User  string `toml:"user"`
+	Token string `toml:"token"`
+	tls.ClientConfig
+
+	client *http.Client
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Slurm)
+var _ inputs.InstancesGetter = new(Slurm)
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Slurm{}
+	})
+}
+
+func (s *Slurm) Clone() inputs.Input {
+	return &Slurm{}
+}
+
+func (s *Slurm) Name() string {
+	return inputName
+}
+
+func (s *Slurm) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(s.Instances))
+	for i := 0; i < len(s.Instances); i++ {
+		ret[i] = s.Instances[i]
+	}
+	return ret
+}
+
+func (ins *Instance) Init() error {
+	if ins.SqueuePath == "" {
+		ins.SqueuePath = "squeue"
+	}
+	if ins.SinfoPath == "" {
+		ins.SinfoPath = "sinfo"
+	}
+	if ins.SdiagPath == "" {
+		ins.SdiagPath = "sdiag"
+	}
+	if ins.Timeout <= 0 {
+		ins.Timeout = config.Duration(10 * time.Second)
+	}
+	if ins.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(ins.URL)
+	if err != nil {
+		return fmt.Errorf("failed to parse the url: %s, error: %v", ins.URL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("only http and https are supported, url: %s", ins.URL)
+	}
+	ins.URL = strings.TrimRight(ins.URL, "/")
+	if ins.APIVersion == "" {
+		ins.APIVersion = "v0.0.39"
+	}
+	tlsCfg, err := ins.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+	ins.client = &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsCfg},
+		Timeout:   time.Duration(ins.Timeout),
+	}
+	return nil
+}
+
+// Gather pushes slurm_scheduler_up 0 and nothing else when a command or a request fails,
+// e.g. when the controller is down
+func (ins *Instance) Gather(slist *types.SampleList) {
+	var (
+		s   *snapshot
+		err error
+	)
+	if ins.URL != "" {
+		s, err = ins.queryREST()
+	} else {
+		s, err = ins.queryCommands()
+	}
+	if err != nil {
+		log.Println("E! failed to query slurm:", err)
+		slist.PushSample(inputName, "scheduler_up", 0)
+		return
+	}
+	slist.PushSample(inputName, "scheduler_up", 1)
+	s.push(slist)
+}
+
+// snapshot is the state of the cluster returned either by the commands or by slurmrestd
+type snapshot struct {
+	jobs  []job
+	nodes []node
+	// the statistics of sdiag, e.g. scheduler_backfill_last_cycle
+	diag map[string]float64
+}
+
+type job struct {
+	// the pending jobs may wait for several partitions
+	partitions []string
+	state      string
+}
+
+// node is a node in a partition, the nodes in several partitions are listed once per partition
+type node struct {
+	name      string
+	partition string
+	state     string
+
+	cpusAllocated float64
+	cpusIdle      float64
+	cpusOther     float64
+	cpusTotal     float64
+	// in MB
+	memory          float64
+	memoryAllocated float64
+}
+
+// the states always pushed, zero when unused
+var (
+	jobStates  = []string{"pending", "running"}
+	nodeStates = []string{"alloc", "mixed", "idle", "drain", "down"}
+)
+
+type partitionStats struct {
+	jobs  map[string]int
+	nodes map[string]int
+
+	cpusAllocated   float64
+	cpusIdle        float64
+	cpusOther       float64
+	cpusTotal       float64
+	memory          float64
+	memoryAllocated float64
+}
+
+func newCounts(states []string) map[string]int {
+	m := make(map[string]int, len(states))
+	for _, state := range states {
+		m[state] = 0
+	}
+	return m
+}
+
+func (s *snapshot) push(slist *types.SampleList) {
+	partitions := make(map[string]*partitionStats)
+	partition := func(name string) *partitionStats {
+		p, has := partitions[name]
+		if !has {
+			p = &partitionStats{jobs: newCounts(jobStates), nodes: newCounts(nodeStates)}
+			partitions[name] = p
+		}
+		return p
+	}
+
+	jobs := newCounts(jobStates)
+	for _, j := range s.jobs {
+		jobs[j.state]++
+		for _, name := range j.partitions {
+			partition(name).jobs[j.state]++
+		}
+	}
+
+	nodes := newCounts(nodeStates)
+	seen := make(map[string]struct{})
+	for _, n := range s.nodes {
+		if _, has := seen[n.name]; !has {
+			seen[n.name] = struct{}{}
+			nodes[n.state]++
+		}
+		if n.partition == "" {
+			continue
+		}
+		p := partition(n.partition)
+		p.nodes[n.state]++
+		p.cpusAllocated += n.cpusAllocated
+		p.cpusIdle += n.cpusIdle
+		p.cpusOther += n.cpusOther
+		p.cpusTotal += n.cpusTotal
+		p.memory += n.memory
+		p.memoryAllocated += n.memoryAllocated
+	}
+
+	for state, count := range jobs {
+		slist.PushSample(inputName, "jobs", count, map[string]string{"state": state})
+	}
+	for state, count := range nodes {
+		slist.PushSample(inputName, "nodes", count, map[string]string{"state": state})
+	}
+
+	names := make([]string, 0, len(partitions))
+	for name := range partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := partitions[name]
+		for state, count := range p.jobs {
+			slist.PushSample(inputName, "partition_jobs", count, map[string]string{"partition": name, "state": state})
+		}
+		for state, count := range p.nodes {
+			slist.PushSample(inputName, "partition_nodes", count, map[string]string{"partition": name, "state": state})
+		}
+		fields := map[string]interface{}{
+			"partition_cpus_allocated":         p.cpusAllocated,
+			"partition_cpus_idle":              p.cpusIdle,
+			"partition_cpus_other":             p.cpusOther,
+			"partition_cpus_total":             p.cpusTotal,
+			"partition_memory_bytes":           p.memory * 1024 * 1024,
+			"partition_memory_allocated_bytes": p.memoryAllocated * 1024 * 1024,
+		}
+		if p.cpusTotal > 0 {
+			fields["partition_cpu_allocation_ratio"] = p.cpusAllocated / p.cpusTotal
+		}
+		if p.memory > 0 {
+			fields["partition_memory_allocation_ratio"] = p.memoryAllocated / p.memory
+		}
+		slist.PushSamples(inputName, fields, map[string]string{"partition": name})
+	}
+
+	if len(s.diag) > 0 {
+		fields := make(map[string]interface{}, len(s.diag))
+		for k, v := range s.diag {
+			fields[k] = v
+		}
+		slist.PushSamples(inputName, fields)
+	}
+}
+
+// nodeState returns the base state of a node, e.g. alloc for allocated+ and drain for
+// idle+drain or draining, the flags after '+' other than drain are ignored
+func nodeState(state string) string {
+	parts := strings.Split(strings.ToLower(state), "+")
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, "drain") {
+			return "drain"
+		}
+	}
+	base := strings.TrimRightFunc(parts[0], func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	switch {
+	case base == "allocated" || base == "alloc":
+		return "alloc"
+	case base == "mix":
+		return "mixed"
+	case strings.HasPrefix(base, "drain"):
+		return "drain"
+	case base == "":
+		return "unknown"
+	}
+	return base
+}
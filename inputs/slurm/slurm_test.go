@@ -0,0 +1,100 @@
+package slurm
+
+import (
+	"testing"
+
+	"flashcat.cloud/categraf/pkg/conv"
+	"flashcat.cloud/categraf/types"
+)
+
+const sinfoOutput = `node01    |batch*   |allocated   |32/0/0/32    |128000  |96000   |
+node02    |batch*   |mixed       |16/16/0/32   |128000  |32000   |
+node02    |gpu      |mixed       |16/16/0/32   |128000  |32000   |
+node03    |batch*   |idle+drain  |0/0/32/32    |128000  |0       |
+node04    |gpu      |down*       |0/0/64/64    |256000  |0       |
+`
+
+const sdiagOutput = `*******************************************************
+sdiag output at Thu Mar 07 10:00:00 2024 (1709805600)
+Data since      Thu Mar 07 00:00:00 2024 (1709769600)
+*******************************************************
+Server thread count:  3
+Agent queue size:     0
+
+Jobs submitted: 100
+Jobs failed:    1
+
+Main schedule statistics (microseconds):
+	Last cycle:   1234
+	Mean cycle:   900
+
+Backfilling stats
+	Total backfilled jobs (since last slurm start): 12
+	Last cycle when: Thu Mar 07 09:59:00 2024 (1709805540)
+	Last cycle: 2000
+	Depth Mean (try depth): 12
+
+Remote Procedure Call statistics by message type
+	REQUEST_PARTITION_INFO  ( 2009) count:100 ave_time:200 total_time:20000
+`
+
+func TestSnapshot(t *testing.T) {
+	jobs, err := parseSqueue([]byte("batch|RUNNING\nbatch,gpu|PENDING\ngpu|RUNNING\nbatch|COMPLETING\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := parseSinfo([]byte(sinfoOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &snapshot{jobs: jobs, nodes: nodes, diag: parseSdiag([]byte(sdiagOutput))}
+
+	slist := types.NewSampleList()
+	s.push(slist)
+	values := make(map[string]float64)
+	for _, sample := range slist.PopBackAll() {
+		key := sample.Metric
+		for _, label := range []string{"partition", "state"} {
+			if v, has := sample.Labels[label]; has {
+				key += "," + v
+			}
+		}
+		values[key], _ = conv.ToFloat64(sample.Value)
+	}
+
+	for key, want := range map[string]float64{
+		"slurm_jobs,running":                                                    2,
+		"slurm_jobs,completing":                                                 1,
+		"slurm_partition_jobs,batch,pending":                                    1,
+		"slurm_partition_jobs,gpu,pending":                                      1,
+		"slurm_partition_jobs,gpu,running":                                      1,
+		"slurm_nodes,mixed":                                                     1,
+		"slurm_nodes,drain":                                                     1,
+		"slurm_nodes,down":                                                      1,
+		"slurm_partition_nodes,batch,alloc":                                     1,
+		"slurm_partition_nodes,batch,idle":                                      0,
+		"slurm_partition_nodes,gpu,down":                                        1,
+		"slurm_partition_cpus_total,batch":                                      96,
+		"slurm_partition_cpu_allocation_ratio,batch":                            0.5,
+		"slurm_partition_memory_allocation_ratio,gpu":                           32000.0 / 384000,
+		"slurm_sdiag_jobs_submitted":                                            100,
+		"slurm_scheduler_main_mean_cycle":                                       900,
+		"slurm_scheduler_backfill_last_cycle":                                   2000,
+		"slurm_scheduler_backfill_total_backfilled_jobs_since_last_slurm_start": 12,
+		"slurm_scheduler_backfill_depth_mean_try_depth":                         12,
+	} {
+		got, has := values[key]
+		if !has {
+			t.Errorf("%s missing", key)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s = %v, want %v", key, got, want)
+		}
+	}
+	for key := range values {
+		if key == "slurm_sdiag_request_partition_info" || key == "slurm_scheduler_backfill_last_cycle_when" {
+			t.Errorf("unexpected %s", key)
+		}
+	}
+}
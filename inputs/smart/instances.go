@@ -37,6 +37,12 @@ type Instance struct {
 	UseSudo          bool            `toml:"use_sudo"`
 	Timeout          config.Duration `toml:"timeout"`
 	ReadMethod       string          `toml:"read_method"`
+	// UseJSON parses smartctl --json --all, the devices are the disks of /dev matching
+	// DeviceRegex unless devices is set
+	UseJSON     bool   `toml:"use_json"`
+	DeviceRegex string `toml:"device_regex"`
+
+	deviceRegex *regexp.Regexp
 }
 
 type nvmeDevice struct {
@@ -48,9 +54,16 @@ type nvmeDevice struct {
 
 // Init performs one time setup of the plugin and returns an error if the configuration is invalid.
 func (m *Instance) Init() error {
-	if len(m.Devices) == 0 && !m.UseSudo {
+	if len(m.Devices) == 0 && !m.UseSudo && !m.UseJSON {
 		return types.ErrInstancesEmpty
 	}
+	if m.UseJSON {
+		re, err := compileDeviceRegex(m.DeviceRegex)
+		if err != nil {
+			return err
+		}
+		m.deviceRegex = re
+	}
 	if m.Timeout == config.Duration(0) {
 		m.Timeout = config.Duration(time.Second * 30)
 	}
@@ -101,6 +114,11 @@ func (m *Instance) Init() error {
 
 // Gather takes in an accumulator and adds the metrics that the SMART tools gather.
 func (m *Instance) Gather(slist *types.SampleList) {
+	if m.UseJSON {
+		m.gatherJSON(slist)
+		return
+	}
+
 	var err error
 	var scannedNVMeDevices []string
 	var scannedNonNVMeDevices []string
@@ -0,0 +1,188 @@
+package smart
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// defaultDeviceRegex matches the whole disks of /dev, not their partitions
+const defaultDeviceRegex = `^(sd[a-z]+|hd[a-z]+|nvme[0-9]+n[0-9]+)$`
+
+// ATA attributes of the json mode, by attribute id
+const (
+	ataRawReadErrorRate    = 1
+	ataReallocatedSectorCt = 5
+)
+
+// smartctlJSON is the part of the output of smartctl --json --all used by the json mode
+type smartctlJSON struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+	} `json:"smartctl"`
+	Device struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Protocol string `json:"protocol"`
+	} `json:"device"`
+	ModelName    string `json:"model_name"`
+	SerialNumber string `json:"serial_number"`
+	SmartStatus  *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature *struct {
+		Current float64 `json:"current"`
+	} `json:"temperature"`
+	PowerOnTime *struct {
+		Hours float64 `json:"hours"`
+	} `json:"power_on_time"`
+	ATASmartAttributes *struct {
+		Table []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+			Raw  struct {
+				Value float64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	SCSIGrownDefectList *float64 `json:"scsi_grown_defect_list"`
+	NVMeSmartHealth     *struct {
+		CriticalWarning float64 `json:"critical_warning"`
+		AvailableSpare  float64 `json:"available_spare"`
+		PercentageUsed  float64 `json:"percentage_used"`
+		MediaErrors     float64 `json:"media_errors"`
+		UnsafeShutdowns float64 `json:"unsafe_shutdowns"`
+		NumErrLogs      float64 `json:"num_err_log_entries"`
+	} `json:"nvme_smart_health_information_log"`
+}
+
+// jsonDevices returns the devices of the config, or the disks of /dev matching device_regex
+func (m *Instance) jsonDevices() []string {
+	if len(m.Devices) != 0 {
+		return m.Devices
+	}
+	entries, err := os.ReadDir("/dev")
+	if err != nil {
+		log.Println("E! failed to read /dev:", err)
+		return nil
+	}
+	var devices []string
+	for _, entry := range entries {
+		if !m.deviceRegex.MatchString(entry.Name()) {
+			continue
+		}
+		device := "/dev/" + entry.Name()
+		if excludedDev(m.Excludes, device) {
+			continue
+		}
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+func (m *Instance) gatherJSON(slist *types.SampleList) {
+	devices := m.jsonDevices()
+	var wg sync.WaitGroup
+	wg.Add(len(devices))
+	for _, device := range devices {
+		if m.ReadMethod == "sequential" {
+			m.gatherDiskJSON(slist, device, &wg)
+		} else {
+			go m.gatherDiskJSON(slist, device, &wg)
+		}
+	}
+	wg.Wait()
+}
+
+// gatherDiskJSON runs smartctl --json --all on the device, a device given with its type,
+// e.g. "/dev/sda -d sat", is passed as is
+func (m *Instance) gatherDiskJSON(slist *types.SampleList, device string, wg *sync.WaitGroup) {
+	defer wg.Done()
+	args := []string{"--json", "--all", "-n", m.Nocheck}
+	args = append(args, strings.Split(device, " ")...)
+	out, e := runCmd(m.Timeout, m.UseSudo, m.PathSmartctl, args...)
+
+	// the exit status is a bit mask, the json is printed along with the disk failures
+	if _, er := exitStatus(e); er != nil {
+		log.Printf("E! failed to run command '%s %s': %v - %s", m.PathSmartctl, strings.Join(args, " "), e, string(out))
+		return
+	}
+
+	var data smartctlJSON
+	if err := json.Unmarshal(out, &data); err != nil {
+		log.Printf("E! failed to parse the json output of smartctl for device %s: %v", device, err)
+		return
+	}
+	pushJSONSamples(slist, strings.Split(device, " ")[0], &data)
+}
+
+func pushJSONSamples(slist *types.SampleList, deviceNode string, data *smartctlJSON) {
+	tags := map[string]string{"device": path.Base(deviceNode)}
+	if data.ModelName != "" {
+		tags["model"] = strings.Join(strings.Fields(data.ModelName), "_")
+	}
+	if data.SerialNumber != "" {
+		tags["serial_no"] = data.SerialNumber
+	}
+	if data.Device.Protocol != "" {
+		tags["protocol"] = strings.ToLower(data.Device.Protocol)
+	}
+
+	slist.PushSample(inputName, "exit_status", data.Smartctl.ExitStatus, tags)
+	if data.SmartStatus != nil {
+		healthOK := 0
+		if data.SmartStatus.Passed {
+			healthOK = 1
+		}
+		slist.PushSample(inputName, "health_ok", healthOK, tags)
+	}
+	if data.Temperature != nil {
+		slist.PushSample(inputName, "temperature_celsius", data.Temperature.Current, tags)
+	}
+	if data.PowerOnTime != nil {
+		slist.PushSample(inputName, "power_on_hours", data.PowerOnTime.Hours, tags)
+	}
+	if data.ATASmartAttributes != nil {
+		for _, attr := range data.ATASmartAttributes.Table {
+			switch attr.ID {
+			case ataReallocatedSectorCt:
+				slist.PushSample(inputName, "reallocated_sectors_count", attr.Raw.Value, tags)
+			case ataRawReadErrorRate:
+				slist.PushSample(inputName, "raw_read_error_rate", attr.Raw.Value, tags)
+			}
+		}
+	}
+	if data.SCSIGrownDefectList != nil {
+		slist.PushSample(inputName, "reallocated_sectors_count", *data.SCSIGrownDefectList, tags)
+	}
+	if nvme := data.NVMeSmartHealth; nvme != nil {
+		slist.PushSamples(inputName+"_nvme", map[string]interface{}{
+			"critical_warning":    nvme.CriticalWarning,
+			"available_spare":     nvme.AvailableSpare,
+			"percentage_used":     nvme.PercentageUsed,
+			"media_errors":        nvme.MediaErrors,
+			"unsafe_shutdowns":    nvme.UnsafeShutdowns,
+			"num_err_log_entries": nvme.NumErrLogs,
+		}, tags)
+	}
+}
+
+func compileDeviceRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		expr = defaultDeviceRegex
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device_regex %s: %v", expr, err)
+	}
+	return re, nil
+}
@@ -42,6 +42,8 @@ type Instance struct {
 	translator Translator
 
 	Mappings map[string]map[string]string `toml:"mappings"`
+
+	rates *counterRates
 }
 
 func (ins *Instance) Init() error {
@@ -66,6 +68,7 @@ func (ins *Instance) Init() error {
 	}
 
 	ins.connectionCache = make([]snmpConnection, len(ins.Agents))
+	ins.rates = newCounterRates()
 
 	for i := range ins.Tables {
 		if err := ins.Tables[i].Init(ins.translator); err != nil {
@@ -172,6 +175,7 @@ func (ins *Instance) Gather(slist *types.SampleList) {
 		}(i, agent)
 	}
 	wg.Wait()
+	ins.rates.prune(time.Hour)
 }
 
 func (ins *Instance) gatherTable(slist *types.SampleList, gs snmpConnection, t Table, topTags, extraTags map[string]string, walk bool) error {
@@ -204,6 +208,17 @@ func (ins *Instance) gatherTable(slist *types.SampleList, gs snmpConnection, t T
 		for k, v := range extraTags {
 			tr.Tags[k] = v
 		}
+		for k, v := range tr.Fields {
+			cv, ok := v.(counterValue)
+			if !ok {
+				continue
+			}
+			if rate, ok := ins.rates.rate(counterKey(prefix, k, tr.Tags), cv, rt.Time); ok {
+				tr.Fields[k] = rate
+			} else {
+				delete(tr.Fields, k)
+			}
+		}
 		slist.PushSamples(prefix, tr.Fields, tr.Tags)
 	}
 
@@ -0,0 +1,84 @@
+package snmp
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// counterRates keeps the previous values of counter fields to convert them to rates
+type counterRates struct {
+	sync.Mutex
+	last map[string]counterSample
+}
+
+type counterSample struct {
+	value uint64
+	ts    time.Time
+}
+
+func newCounterRates() *counterRates {
+	return &counterRates{last: make(map[string]counterSample)}
+}
+
+// rate returns the per-second rate since the previous value of the same key.
+// The first value of a key only initializes the state and returns false.
+func (r *counterRates) rate(key string, cv counterValue, now time.Time) (float64, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	prev, ok := r.last[key]
+	r.last[key] = counterSample{value: cv.value, ts: now}
+	if !ok {
+		return 0, false
+	}
+
+	dt := now.Sub(prev.ts).Seconds()
+	if dt <= 0 {
+		return 0, false
+	}
+
+	// unsigned subtraction handles the counter wrapping, 32 bits counters wrap at 2^32.
+	// A 64 bits counter does not wrap in practice, it went down because the device restarted
+	if cv.bits != 32 && cv.value < prev.value {
+		return 0, false
+	}
+	delta := cv.value - prev.value
+	if cv.bits == 32 {
+		delta &= 0xffffffff
+	}
+	return float64(delta) / dt, true
+}
+
+// prune removes the state of counters which were not seen for the given duration
+func (r *counterRates) prune(maxAge time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	for k, v := range r.last {
+		if now.Sub(v.ts) > maxAge {
+			delete(r.last, k)
+		}
+	}
+}
+
+func counterKey(prefix, field string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	sb.WriteString("_")
+	sb.WriteString(field)
+	for _, k := range keys {
+		sb.WriteString(",")
+		sb.WriteString(k)
+		sb.WriteString("=")
+		sb.WriteString(tags[k])
+	}
+	return sb.String()
+}
@@ -0,0 +1,71 @@
+package snmp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterRates(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name  string
+		bits  int
+		prev  uint64
+		cur   uint64
+		dt    time.Duration
+		want  float64
+		valid bool
+	}{
+		{name: "counter32", bits: 32, prev: 1000, cur: 3000, dt: 10 * time.Second, want: 200, valid: true},
+		{name: "counter32 wrap", bits: 32, prev: 0xffffff00, cur: 0x100, dt: 2 * time.Second, want: 256, valid: true},
+		{name: "counter64", bits: 64, prev: 1 << 40, cur: 1<<40 + 500, dt: 5 * time.Second, want: 100, valid: true},
+		{name: "counter64 reset", bits: 64, prev: 1 << 40, cur: 100, dt: 5 * time.Second},
+		{name: "same time", bits: 64, prev: 1000, cur: 2000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newCounterRates()
+			// the first gather only records the value
+			if _, ok := r.rate("ifInOctets,ifIndex=1", counterValue{value: tt.prev, bits: tt.bits}, start); ok {
+				t.Fatal("expected no rate on the first gather")
+			}
+			got, ok := r.rate("ifInOctets,ifIndex=1", counterValue{value: tt.cur, bits: tt.bits}, start.Add(tt.dt))
+			if ok != tt.valid || got != tt.want {
+				t.Errorf("expected (%v, %v), got (%v, %v)", tt.want, tt.valid, got, ok)
+			}
+		})
+	}
+}
+
+func TestCounterRatesReset(t *testing.T) {
+	r := newCounterRates()
+	start := time.Now()
+	r.rate("k", counterValue{value: 1 << 40, bits: 64}, start)
+	r.rate("k", counterValue{value: 100, bits: 64}, start.Add(time.Second))
+	// the rate is computed from the value after the reset
+	if got, ok := r.rate("k", counterValue{value: 300, bits: 64}, start.Add(2*time.Second)); !ok || got != 200 {
+		t.Errorf("expected (200, true), got (%v, %v)", got, ok)
+	}
+
+	// the keys have their own state
+	if _, ok := r.rate("other", counterValue{value: 300, bits: 64}, start.Add(2*time.Second)); ok {
+		t.Error("expected no rate on the first value of another key")
+	}
+
+	r.last["stale"] = counterSample{value: 1, ts: time.Now().Add(-2 * time.Hour)}
+	r.prune(time.Hour)
+	if _, has := r.last["stale"]; has || len(r.last) != 2 {
+		t.Errorf("expected only the stale state to be pruned, got %v", r.last)
+	}
+}
+
+func TestCounterKey(t *testing.T) {
+	a := counterKey("interface", "ifInOctets", map[string]string{"ifIndex": "1", "agent_host": "10.0.0.1"})
+	b := counterKey("interface", "ifInOctets", map[string]string{"agent_host": "10.0.0.1", "ifIndex": "1"})
+	if a != b || a != "interface_ifInOctets,agent_host=10.0.0.1,ifIndex=1" {
+		t.Errorf("unexpected keys %q and %q", a, b)
+	}
+	if a == counterKey("interface", "ifInOctets", map[string]string{"ifIndex": "2", "agent_host": "10.0.0.1"}) {
+		t.Error("expected different keys for different tags")
+	}
+}
@@ -209,10 +209,33 @@ type Field struct {
 	// Can be set per field or globally with SecondaryIndexTable, global true overrides
 	//  per field false.
 	SecondaryOuterJoin bool `toml:"secondary_outer_join"`
+	// Rate converts Counter32/Counter64 values to a per-second rate between two gathers,
+	// counter wrapping is handled according to the width of the counter.
+	Rate bool `toml:"rate"`
 
 	initialized bool `toml:"initialized"`
 }
 
+// counterValue is a raw Counter32/Counter64 value of a field with rate enabled,
+// it is converted to a rate by the instance which keeps the previous values.
+type counterValue struct {
+	value uint64
+	bits  int
+}
+
+// convert converts the value of the PDU according to the field specification
+func (f *Field) convert(ent gosnmp.SnmpPDU) (interface{}, error) {
+	if f.Rate {
+		switch ent.Type {
+		case gosnmp.Counter32:
+			return counterValue{value: gosnmp.ToBigInt(ent.Value).Uint64(), bits: 32}, nil
+		case gosnmp.Counter64:
+			return counterValue{value: gosnmp.ToBigInt(ent.Value).Uint64(), bits: 64}, nil
+		}
+	}
+	return fieldConvert(f.Conversion, ent.Value)
+}
+
 // init() converts OID names to numbers, and sets the .Name attribute if unset.
 func (f *Field) init(tr Translator) error {
 	if f.initialized {
@@ -339,7 +362,7 @@ func (t Table) Build(gs snmpConnection, walk bool, tr Translator) (*RTable, erro
 				if ent.Type == gosnmp.NoSuchObject || ent.Type == gosnmp.NoSuchInstance {
 					return nil, fmt.Errorf("get info for oid %s error %v", oid, ent.Type)
 				}
-				fv, err := fieldConvert(f.Conversion, ent.Value)
+				fv, err := f.convert(ent)
 				if err != nil {
 					return nil, fmt.Errorf("converting %q (OID %s) for field %s: %w", ent.Value, ent.Name, f.Name, err)
 				}
@@ -387,7 +410,7 @@ func (t Table) Build(gs snmpConnection, walk bool, tr Translator) (*RTable, erro
 					}
 				}
 
-				fv, err := fieldConvert(f.Conversion, ent.Value)
+				fv, err := f.convert(ent)
 				if err != nil {
 					return &walkError{
 						msg: fmt.Sprintf("converting %q (OID %s) for field %s", ent.Value, ent.Name, f.Name),
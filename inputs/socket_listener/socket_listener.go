@@ -0,0 +1,367 @@
+package socket_listener
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/parser"
+	"flashcat.cloud/categraf/parser/falcon"
+	"flashcat.cloud/categraf/parser/influx"
+	jsonparser "flashcat.cloud/categraf/parser/json"
+	"flashcat.cloud/categraf/parser/prometheus"
+	"flashcat.cloud/categraf/parser/regex"
+	"flashcat.cloud/categraf/parser/value"
+	tlsx "flashcat.cloud/categraf/pkg/tls"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "socket_listener"
+
+type SocketListener struct {
+	config.PluginConfig
+
+	Instances []*Instance `toml:"instances"`
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// tcp address to listen on, e.g. ":8094", the tcp listener is disabled if empty
+	TCPAddress        string `toml:"tcp_address"`
+	MaxTCPConnections int    `toml:"max_tcp_connections"`
+	// a tcp connection is closed when no line is read for read_timeout
+	ReadTimeout config.Duration `toml:"read_timeout"`
+	// udp address to listen on, the udp listener is disabled if empty
+	UDPAddress string `toml:"udp_address"`
+	// the longer lines are dropped
+	MaxLineLength int `toml:"max_line_length"`
+
+	// influx, value, regex, json, falcon or prometheus
+	DataFormat string `toml:"data_format"`
+	// the metric of the value format and the prefix of the regex and json formats
+	MetricName string `toml:"metric_name"`
+	Regex      string `toml:"regex"`
+	// the capture groups of regex or the fields of json which are labels
+	TagKeys []string `toml:"tag_keys"`
+
+	// the tcp listener uses tls when tls_cert and tls_key are set
+	tlsx.ServerConfig
+
+	parser parser.Parser
+	slist  *types.SampleList
+
+	tcpStats listenerStats
+	udpStats listenerStats
+
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+	conns       map[net.Conn]struct{}
+	connsLock   sync.Mutex
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// listenerStats are the counters of a listener
+type listenerStats struct {
+	accepted    atomic.Uint64
+	refused     atomic.Uint64
+	lines       atomic.Uint64
+	parseErrors atomic.Uint64
+	dropped     atomic.Uint64
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(SocketListener)
+var _ inputs.InstancesGetter = new(SocketListener)
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &SocketListener{}
+	})
+}
+
+func (s *SocketListener) Clone() inputs.Input {
+	return &SocketListener{}
+}
+
+func (s *SocketListener) Name() string {
+	return inputName
+}
+
+func (s *SocketListener) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(s.Instances))
+	for i := 0; i < len(s.Instances); i++ {
+		ret[i] = s.Instances[i]
+	}
+	return ret
+}
+
+func (s *SocketListener) Drop() {
+	for i := 0; i < len(s.Instances); i++ {
+		s.Instances[i].Drop()
+	}
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.TCPAddress) == 0 && len(ins.UDPAddress) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.MaxTCPConnections <= 0 {
+		ins.MaxTCPConnections = 250
+	}
+	if ins.ReadTimeout <= 0 {
+		ins.ReadTimeout = config.Duration(5 * time.Minute)
+	}
+	if ins.MaxLineLength <= 0 {
+		ins.MaxLineLength = 64 * 1024
+	}
+	if ins.MetricName == "" {
+		ins.MetricName = inputName
+	}
+
+	var err error
+	switch ins.DataFormat {
+	case "", "influx":
+		ins.parser = influx.NewParser()
+	case "value":
+		ins.parser = value.NewParser(ins.MetricName)
+	case "regex":
+		ins.parser, err = regex.NewParser(ins.Regex, ins.MetricName, ins.TagKeys)
+	case "json":
+		ins.parser = jsonparser.NewParser(ins.MetricName, ins.TagKeys)
+	case "falcon":
+		ins.parser = falcon.NewParser()
+	case "prometheus":
+		ins.parser = prometheus.EmptyParser()
+	default:
+		err = fmt.Errorf("data_format(%s) not supported", ins.DataFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	tlsConfig, err := ins.ServerConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	ins.slist = types.NewSampleList()
+	ins.conns = make(map[net.Conn]struct{})
+	ins.done = make(chan struct{})
+	return ins.start(tlsConfig)
+}
+
+func (ins *Instance) start(tlsConfig *tls.Config) error {
+	if len(ins.UDPAddress) > 0 {
+		conn, err := net.ListenPacket("udp", ins.UDPAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen on udp %s: %v", ins.UDPAddress, err)
+		}
+		ins.udpConn = conn
+		ins.wg.Add(1)
+		go ins.serveUDP()
+	}
+
+	if len(ins.TCPAddress) > 0 {
+		listener, err := net.Listen("tcp", ins.TCPAddress)
+		if err != nil {
+			if ins.udpConn != nil {
+				ins.udpConn.Close()
+			}
+			return fmt.Errorf("failed to listen on tcp %s: %v", ins.TCPAddress, err)
+		}
+		if tlsConfig != nil {
+			listener = tls.NewListener(listener, tlsConfig)
+		}
+		ins.tcpListener = listener
+		ins.wg.Add(1)
+		go ins.serveTCP()
+	}
+	return nil
+}
+
+// serveUDP parses the lines of every datagram, a line can't span datagrams
+func (ins *Instance) serveUDP() {
+	defer ins.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := ins.udpConn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("E! failed to read socket_listener udp packet:", err)
+				continue
+			}
+			return
+		}
+		for _, line := range bytes.Split(buf[:n], []byte("\n")) {
+			if len(line) > ins.MaxLineLength {
+				ins.udpStats.dropped.Add(1)
+				continue
+			}
+			ins.handleLine(&ins.udpStats, bytes.TrimRight(line, "\r"))
+		}
+	}
+}
+
+func (ins *Instance) serveTCP() {
+	defer ins.wg.Done()
+
+	sem := make(chan struct{}, ins.MaxTCPConnections)
+	for {
+		conn, err := ins.tcpListener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("E! failed to accept socket_listener tcp connection:", err)
+				continue
+			}
+			return
+		}
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			ins.tcpStats.refused.Add(1)
+			log.Println("W! socket_listener tcp connections exceed max_tcp_connections, refused", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		ins.tcpStats.accepted.Add(1)
+
+		ins.connsLock.Lock()
+		ins.conns[conn] = struct{}{}
+		ins.connsLock.Unlock()
+
+		ins.wg.Add(1)
+		go func() {
+			defer func() {
+				ins.connsLock.Lock()
+				delete(ins.conns, conn)
+				ins.connsLock.Unlock()
+				conn.Close()
+				<-sem
+				ins.wg.Done()
+			}()
+
+			if err := ins.readLines(conn); err != nil && ins.DebugMod {
+				log.Println("D! socket_listener tcp connection", conn.RemoteAddr(), "closed:", err)
+			}
+		}()
+	}
+}
+
+// readLines parses the lines of a connection until it is closed or idle for read_timeout,
+// the lines longer than max_line_length are dropped
+func (ins *Instance) readLines(conn net.Conn) error {
+	reader := bufio.NewReaderSize(conn, ins.MaxLineLength)
+	tooLong := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(ins.ReadTimeout)))
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			if !tooLong {
+				ins.tcpStats.dropped.Add(1)
+			}
+			tooLong = true
+			continue
+		}
+		if err != nil {
+			// the last line of the connection may miss its newline
+			if err == io.EOF && len(line) > 0 && !tooLong {
+				ins.handleLine(&ins.tcpStats, bytes.TrimRight(line, "\r"))
+			}
+			return err
+		}
+		if tooLong {
+			// the end of the dropped line
+			tooLong = false
+			continue
+		}
+		ins.handleLine(&ins.tcpStats, bytes.TrimRight(line, "\r\n"))
+	}
+}
+
+// handleLine parses a line, a line without any sample is a parse error since the influx
+// parser logs its errors without returning them
+func (ins *Instance) handleLine(stats *listenerStats, line []byte) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	stats.lines.Add(1)
+	slist := types.NewSampleList()
+	err := ins.parser.Parse(line, slist)
+	samples := slist.PopBackAll()
+	if err != nil || len(samples) == 0 {
+		stats.parseErrors.Add(1)
+		if ins.DebugMod {
+			log.Println("D! failed to parse socket_listener line", strings.TrimSpace(string(line)), ":", err)
+		}
+		return
+	}
+	now := time.Now()
+	for _, s := range samples {
+		if s.Timestamp.IsZero() {
+			s.SetTime(now)
+		}
+	}
+	ins.slist.PushFrontN(samples)
+}
+
+// Gather hands over the samples received since the last gather
+func (ins *Instance) Gather(slist *types.SampleList) {
+	if len(ins.TCPAddress) > 0 {
+		labels := map[string]string{"protocol": "tcp", "address": ins.TCPAddress}
+		ins.connsLock.Lock()
+		active := len(ins.conns)
+		ins.connsLock.Unlock()
+		slist.PushSamples(inputName, map[string]interface{}{
+			"connections":                active,
+			"connections_accepted_total": ins.tcpStats.accepted.Load(),
+			"connections_refused_total":  ins.tcpStats.refused.Load(),
+			"lines_total":                ins.tcpStats.lines.Load(),
+			"parse_errors_total":         ins.tcpStats.parseErrors.Load(),
+			"dropped_lines_total":        ins.tcpStats.dropped.Load(),
+		}, labels)
+	}
+	if len(ins.UDPAddress) > 0 {
+		labels := map[string]string{"protocol": "udp", "address": ins.UDPAddress}
+		slist.PushSamples(inputName, map[string]interface{}{
+			"lines_total":         ins.udpStats.lines.Load(),
+			"parse_errors_total":  ins.udpStats.parseErrors.Load(),
+			"dropped_lines_total": ins.udpStats.dropped.Load(),
+		}, labels)
+	}
+	slist.PushFrontN(ins.slist.PopBackAll())
+}
+
+func (ins *Instance) Drop() {
+	if ins.done == nil {
+		return
+	}
+	close(ins.done)
+	if ins.udpConn != nil {
+		ins.udpConn.Close()
+	}
+	if ins.tcpListener != nil {
+		ins.tcpListener.Close()
+	}
+	ins.connsLock.Lock()
+	for conn := range ins.conns {
+		conn.Close()
+	}
+	ins.connsLock.Unlock()
+	ins.wg.Wait()
+}
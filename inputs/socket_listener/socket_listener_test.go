@@ -0,0 +1,72 @@
+package socket_listener
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+func TestListener(t *testing.T) {
+	ins := &Instance{
+		TCPAddress:        "127.0.0.1:0",
+		UDPAddress:        "127.0.0.1:0",
+		MaxTCPConnections: 2,
+		MaxLineLength:     64,
+		ReadTimeout:       config.Duration(time.Second),
+	}
+	if err := ins.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer ins.Drop()
+
+	// a client which never sends a line doesn't block the others
+	slow, err := net.Dial("tcp", ins.tcpListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+
+	conn, err := net.Dial("tcp", ins.tcpListener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("cpu,host=a usage=1.5 1700000000000000000\n" + strings.Repeat("x", 100) + "\nnot a line\r\ncpu,host=b usage=2"))
+	conn.Close()
+
+	udp, err := net.Dial("udp", ins.udpConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	udp.Write([]byte("mem,host=a used=3\n"))
+	udp.Close()
+
+	samples := map[string]float64{}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(samples) < 3 && time.Now().Before(deadline) {
+		slist := types.NewSampleList()
+		ins.Gather(slist)
+		for _, s := range slist.PopBackAll() {
+			switch s.Metric {
+			case "cpu_usage", "mem_used":
+				samples[s.Metric+"/"+s.Labels["host"]] = s.Value.(float64)
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if samples["cpu_usage/a"] != 1.5 || samples["cpu_usage/b"] != 2 || samples["mem_used/a"] != 3 {
+		t.Fatalf("unexpected samples %v", samples)
+	}
+	if v := ins.tcpStats.dropped.Load(); v != 1 {
+		t.Errorf("expected 1 dropped line, got %d", v)
+	}
+	if v := ins.tcpStats.parseErrors.Load(); v != 1 {
+		t.Errorf("expected 1 parse error, got %d", v)
+	}
+	if v := ins.tcpStats.accepted.Load(); v != 2 {
+		t.Errorf("expected 2 accepted connections, got %d", v)
+	}
+}
@@ -0,0 +1,182 @@
+//go:build linux
+
+package sockstat
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ParseFileNr retrieves the allocated and the maximum file handles from /proc/sys/fs/file-nr,
+// the second field is always 0 since linux 2.6
+func ParseFileNr() (*FileNr, error) {
+	b, err := ReadFileNoStat("/proc/sys/fs/file-nr")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("malformed file-nr: %q", string(b))
+	}
+	allocated, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	max, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &FileNr{Allocated: allocated, Max: max}, nil
+}
+
+// ParseTCPMem retrieves the min, pressure and max thresholds of /proc/sys/net/ipv4/tcp_mem, in pages
+func ParseTCPMem() ([]uint64, error) {
+	b, err := ReadFileNoStat("/proc/sys/net/ipv4/tcp_mem")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("malformed tcp_mem: %q", string(b))
+	}
+	ret := make([]uint64, 0, 3)
+	for _, f := range fields {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// TopFDProcesses counts the open fds of every process until the budget is spent and returns
+// the n processes with the most fds. The entries of /proc/<pid>/fd are counted from the raw
+// dirents, nothing is stat-ed or readlink-ed. Processes which can not be read, e.g. of other
+// users when not running as root, are skipped.
+func TopFDProcesses(n int, budget time.Duration) ([]ProcessFDs, bool, error) {
+	deadline := time.Now().Add(budget)
+
+	proc, err := os.Open("/proc")
+	if err != nil {
+		return nil, false, err
+	}
+	names, err := proc.Readdirnames(-1)
+	proc.Close()
+	if err != nil {
+		return nil, false, err
+	}
+
+	buf := make([]byte, 32*1024)
+	var ret []ProcessFDs
+	truncated := false
+	for _, name := range names {
+		pid, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		if time.Now().After(deadline) {
+			truncated = true
+			break
+		}
+		count, err := countFDs(pid, buf)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, ProcessFDs{Pid: pid, FDs: count})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].FDs > ret[j].FDs })
+	if len(ret) > n {
+		ret = ret[:n]
+	}
+	for i := range ret {
+		ret[i].Comm = readComm(ret[i].Pid)
+		ret[i].Limit = readFDLimit(ret[i].Pid)
+	}
+	return ret, truncated, nil
+}
+
+func countFDs(pid int, buf []byte) (int, error) {
+	fd, err := unix.Open("/proc/"+strconv.Itoa(pid)+"/fd", unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer unix.Close(fd)
+
+	count := 0
+	for {
+		n, err := unix.ReadDirent(fd, buf)
+		if err != nil {
+			return 0, err
+		}
+		if n <= 0 {
+			return count, nil
+		}
+		count += countDirents(buf[:n])
+	}
+}
+
+// countDirents counts the linux_dirent64 records of buf, "." and ".." excluded
+func countDirents(buf []byte) int {
+	const (
+		reclenOffset = unsafe.Offsetof(unix.Dirent{}.Reclen)
+		nameOffset   = unsafe.Offsetof(unix.Dirent{}.Name)
+	)
+
+	count := 0
+	for len(buf) > int(nameOffset) {
+		reclen := int(*(*uint16)(unsafe.Pointer(&buf[reclenOffset])))
+		if reclen == 0 || reclen > len(buf) {
+			break
+		}
+		name := buf[nameOffset:reclen]
+		if !(name[0] == '.' && (name[1] == 0 || (name[1] == '.' && name[2] == 0))) {
+			count++
+		}
+		buf = buf[reclen:]
+	}
+	return count
+}
+
+func readComm(pid int) string {
+	b, err := ReadFileNoStat("/proc/" + strconv.Itoa(pid) + "/comm")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readFDLimit reads the soft limit of open files, 0 if unlimited or unknown
+func readFDLimit(pid int) uint64 {
+	b, err := ReadFileNoStat("/proc/" + strconv.Itoa(pid) + "/limits")
+	if err != nil {
+		return 0
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 {
+			return 0
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return v
+	}
+	return 0
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/toolkits/pkg/slice"
 
@@ -29,10 +31,37 @@ func (s *SockStat) Name() string {
 	return inputName
 }
 
+func (s *SockStat) Init() error {
+	if s.TopFDTimeBudget <= 0 {
+		s.TopFDTimeBudget = config.Duration(time.Second)
+	}
+	return nil
+}
+
 type SockStat struct {
 	config.PluginConfig
 
 	Protocols []string `toml:"protocols"`
+	// collect /proc/sys/fs/file-nr and /proc/sys/net/ipv4/tcp_mem
+	FileNr bool `toml:"file_nr"`
+	// report the open fds of the n processes with the most fds, 0 disables the scan of /proc/*/fd
+	TopFDProcesses int `toml:"top_fd_processes"`
+	// the scan stops when the budget is spent, the processes counted so far are reported
+	TopFDTimeBudget config.Duration `toml:"top_fd_time_budget"`
+}
+
+// FileNr contains the output of /proc/sys/fs/file-nr.
+type FileNr struct {
+	Allocated uint64
+	Max       uint64
+}
+
+// ProcessFDs contains the open fds and the soft limit of open files of a process.
+type ProcessFDs struct {
+	Pid   int
+	Comm  string
+	FDs   int
+	Limit uint64
 }
 
 // A NetSockstat contains the output of /proc/net/sockstat{,6} for IPv4 or IPv6,
@@ -57,6 +86,13 @@ type NetSockstatProtocol struct {
 }
 
 func (ss *SockStat) Gather(slist *types.SampleList) {
+	if ss.FileNr {
+		ss.gatherFileNr(slist)
+	}
+	if ss.TopFDProcesses > 0 {
+		ss.gatherTopFDProcesses(slist)
+	}
+
 	ns, err := ParseNetSockstat()
 	if err != nil {
 		log.Println("E! failed to get net sockstat: ", err)
@@ -111,3 +147,59 @@ func (ss *SockStat) parse(ns *NetSockstat, slist *types.SampleList) {
 	}
 	slist.PushSamples(inputName, samples)
 }
+
+func (ss *SockStat) gatherFileNr(slist *types.SampleList) {
+	fnr, err := ParseFileNr()
+	if err != nil {
+		log.Println("E! failed to get file-nr: ", err)
+	} else if fnr != nil {
+		fields := map[string]interface{}{
+			"files_allocated": fnr.Allocated,
+			"files_max":       fnr.Max,
+		}
+		if fnr.Max > 0 {
+			fields["files_used_percent"] = float64(fnr.Allocated) / float64(fnr.Max) * 100
+		}
+		slist.PushSamples(inputName, fields)
+	}
+
+	tcpMem, err := ParseTCPMem()
+	if err != nil {
+		log.Println("E! failed to get tcp_mem: ", err)
+		return
+	}
+	if len(tcpMem) == 3 {
+		slist.PushSamples(inputName, map[string]interface{}{
+			"tcp_mem_min":      tcpMem[0],
+			"tcp_mem_pressure": tcpMem[1],
+			"tcp_mem_max":      tcpMem[2],
+		})
+	}
+}
+
+func (ss *SockStat) gatherTopFDProcesses(slist *types.SampleList) {
+	start := time.Now()
+	procs, truncated, err := TopFDProcesses(ss.TopFDProcesses, time.Duration(ss.TopFDTimeBudget))
+	if err != nil {
+		log.Println("E! failed to scan process fds: ", err)
+		return
+	}
+
+	scanTruncated := 0
+	if truncated {
+		scanTruncated = 1
+		if ss.DebugMod {
+			log.Println("D! scan of process fds stopped after", ss.TopFDTimeBudget, "budget")
+		}
+	}
+	slist.PushSample(inputName, "fd_scan_duration_seconds", time.Since(start).Seconds())
+	slist.PushSample(inputName, "fd_scan_truncated", scanTruncated)
+
+	for _, p := range procs {
+		labels := map[string]string{"pid": strconv.Itoa(p.Pid), "comm": p.Comm}
+		slist.PushSample(inputName, "process_open_fds", p.FDs, labels)
+		if p.Limit > 0 {
+			slist.PushSample(inputName, "process_max_fds", p.Limit, labels)
+		}
+	}
+}
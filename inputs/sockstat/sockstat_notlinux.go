@@ -2,6 +2,8 @@
 
 package sockstat
 
+import "time"
+
 // ParseNetSockstat retrieves IPv4 socket statistics.
 func ParseNetSockstat() (*NetSockstat, error) {
 	return nil, nil
@@ -14,3 +16,18 @@ func ParseNetSockstat() (*NetSockstat, error) {
 func ParseNetSockstat6() (*NetSockstat, error) {
 	return nil, nil
 }
+
+// ParseFileNr retrieves the allocated and the maximum file handles.
+func ParseFileNr() (*FileNr, error) {
+	return nil, nil
+}
+
+// ParseTCPMem retrieves the thresholds of tcp memory, in pages.
+func ParseTCPMem() ([]uint64, error) {
+	return nil, nil
+}
+
+// TopFDProcesses returns the processes with the most open fds.
+func TopFDProcesses(n int, budget time.Duration) ([]ProcessFDs, bool, error) {
+	return nil, false, nil
+}
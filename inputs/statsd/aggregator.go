@@ -0,0 +1,205 @@
+package statsd
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/types"
+)
+
+type counter struct {
+	tags  map[string]string
+	value float64
+}
+
+type gauge struct {
+	tags  map[string]string
+	value float64
+}
+
+// timer keeps at most limit values by reservoir sampling, count and sum cover every value
+type timer struct {
+	tags   map[string]string
+	values []float64
+	seen   int
+	count  float64
+	sum    float64
+}
+
+type set struct {
+	tags   map[string]string
+	values map[string]struct{}
+}
+
+type aggregator struct {
+	sync.Mutex
+
+	percentiles  []float64
+	timerLimit   int
+	deleteGauges bool
+
+	counters map[string]*counter
+	gauges   map[string]*gauge
+	timers   map[string]*timer
+	sets     map[string]*set
+	names    map[string]string
+
+	parseErrors uint64
+}
+
+func newAggregator(percentiles []float64, timerLimit int, deleteGauges bool) *aggregator {
+	return &aggregator{
+		percentiles:  percentiles,
+		timerLimit:   timerLimit,
+		deleteGauges: deleteGauges,
+		counters:     make(map[string]*counter),
+		gauges:       make(map[string]*gauge),
+		timers:       make(map[string]*timer),
+		sets:         make(map[string]*set),
+		names:        make(map[string]string),
+	}
+}
+
+// seriesKey identifies a series by the metric name and the sorted tags
+func seriesKey(name string, tags map[string]string) string {
+	if len(tags) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+	}
+	return b.String()
+}
+
+func (a *aggregator) add(m *metric) {
+	key := seriesKey(m.name, m.tags)
+
+	a.Lock()
+	defer a.Unlock()
+
+	a.names[key] = m.name
+	switch m.typ {
+	case typeCounter:
+		c, has := a.counters[key]
+		if !has {
+			c = &counter{tags: m.tags}
+			a.counters[key] = c
+		}
+		c.value += m.value / m.rate
+	case typeGauge:
+		g, has := a.gauges[key]
+		if !has {
+			g = &gauge{tags: m.tags}
+			a.gauges[key] = g
+		}
+		// a signed value adjusts the current value of the gauge, statsd has no negative gauges
+		if strings.HasPrefix(m.raw, "+") || strings.HasPrefix(m.raw, "-") {
+			g.value += m.value
+		} else {
+			g.value = m.value
+		}
+	case typeTimer:
+		t, has := a.timers[key]
+		if !has {
+			t = &timer{tags: m.tags}
+			a.timers[key] = t
+		}
+		t.count += 1 / m.rate
+		t.sum += m.value / m.rate
+		t.seen++
+		if len(t.values) < a.timerLimit {
+			t.values = append(t.values, m.value)
+		} else if i := rand.Intn(t.seen); i < a.timerLimit {
+			t.values[i] = m.value
+		}
+	case typeSet:
+		s, has := a.sets[key]
+		if !has {
+			s = &set{tags: m.tags, values: make(map[string]struct{})}
+			a.sets[key] = s
+		}
+		s.values[m.raw] = struct{}{}
+	}
+}
+
+func (a *aggregator) parseError() {
+	a.Lock()
+	a.parseErrors++
+	a.Unlock()
+}
+
+// flush pushes the aggregated values to slist and resets counters, timers and sets,
+// gauges keep their last value unless delete_gauges is set
+func (a *aggregator) flush(slist *types.SampleList, now time.Time) {
+	a.Lock()
+	defer a.Unlock()
+
+	push := func(metric string, value float64, labels ...map[string]string) {
+		sample := types.NewSample("", metric, value, labels...)
+		sample.SetTime(now)
+		slist.PushFront(sample)
+	}
+
+	for key, c := range a.counters {
+		push(a.names[key], c.value, c.tags)
+	}
+	for key, g := range a.gauges {
+		push(a.names[key], g.value, g.tags)
+	}
+	for key, t := range a.timers {
+		name := a.names[key]
+		push(name+"_count", t.count, t.tags)
+		push(name+"_sum", t.sum, t.tags)
+
+		sort.Float64s(t.values)
+		push(name+"_lower", t.values[0], t.tags)
+		push(name+"_upper", t.values[len(t.values)-1], t.tags)
+		for _, p := range a.percentiles {
+			push(name, percentile(t.values, p), t.tags, map[string]string{"quantile": strconv.FormatFloat(p/100, 'f', -1, 64)})
+		}
+	}
+	for key, s := range a.sets {
+		push(a.names[key], float64(len(s.values)), s.tags)
+	}
+	push(inputName+"_parse_errors_total", float64(a.parseErrors))
+
+	a.counters = make(map[string]*counter)
+	a.timers = make(map[string]*timer)
+	a.sets = make(map[string]*set)
+	if a.deleteGauges {
+		a.gauges = make(map[string]*gauge)
+	}
+	names := make(map[string]string, len(a.gauges))
+	for key := range a.gauges {
+		names[key] = a.names[key]
+	}
+	a.names = names
+}
+
+// percentile returns the nearest-rank percentile p (0-100] of the sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,110 @@
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	typeCounter = "c"
+	typeGauge   = "g"
+	typeTimer   = "ms"
+	typeSet     = "s"
+)
+
+// metric is one parsed statsd line, <metric>:<value>|<type>|@<sample_rate>|#<tags>
+type metric struct {
+	name  string
+	typ   string
+	value float64
+	// the raw value of sets, and of gauges to tell "+1" from "1"
+	raw  string
+	rate float64
+	tags map[string]string
+}
+
+var nameReplacer = strings.NewReplacer(":", "_", "/", "_", "\\", "_", ",", "_", "=", "_", "#", "_", "@", "_", "|", "_")
+
+// parseLine parses a statsd line, the DogStatsD tags extension included. nil is returned for
+// empty lines and for the DogStatsD events and service checks, which are not metrics
+func parseLine(line string) (*metric, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		return nil, nil
+	}
+
+	idx := strings.IndexByte(line, ':')
+	if idx <= 0 {
+		return nil, fmt.Errorf("malformed line %q: missing metric name", line)
+	}
+	m := &metric{
+		name: nameReplacer.Replace(line[:idx]),
+		rate: 1,
+	}
+
+	fields := strings.Split(line[idx+1:], "|")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("malformed line %q: missing metric type", line)
+	}
+
+	m.raw = fields[0]
+	switch fields[1] {
+	case typeCounter, typeGauge, typeSet:
+		m.typ = fields[1]
+	case typeTimer, "h", "d":
+		// DogStatsD histograms and distributions are aggregated as timers
+		m.typ = typeTimer
+	default:
+		return nil, fmt.Errorf("malformed line %q: unsupported metric type %q", line, fields[1])
+	}
+
+	if m.typ != typeSet {
+		v, err := strconv.ParseFloat(m.raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed line %q: invalid value: %v", line, err)
+		}
+		m.value = v
+	} else if m.raw == "" {
+		return nil, fmt.Errorf("malformed line %q: empty set value", line)
+	}
+
+	for _, field := range fields[2:] {
+		if field == "" {
+			continue
+		}
+		switch field[0] {
+		case '@':
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("malformed line %q: invalid sample rate %q", line, field[1:])
+			}
+			m.rate = rate
+		case '#':
+			m.tags = parseTags(field[1:])
+		}
+		// other DogStatsD fields, e.g. the container id c: or the timestamp T, are ignored
+	}
+
+	return m, nil
+}
+
+// parseTags parses the DogStatsD tags, tag1:value1,tag2. A tag without value gets "true"
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		k, v, found := strings.Cut(tag, ":")
+		if k == "" {
+			continue
+		}
+		if !found || v == "" {
+			v = "true"
+		}
+		tags[k] = v
+	}
+	return tags
+}
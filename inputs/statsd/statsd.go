@@ -0,0 +1,257 @@
+package statsd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "statsd"
+
+type Statsd struct {
+	config.PluginConfig
+
+	Instances []*Instance `toml:"instances"`
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// udp address to listen on, e.g. ":8125"
+	UDPAddress string `toml:"udp_address"`
+	// tcp address to listen on, the tcp listener is disabled if empty
+	TCPAddress        string          `toml:"tcp_address"`
+	MaxTCPConnections int             `toml:"max_tcp_connections"`
+	FlushInterval     config.Duration `toml:"flush_interval"`
+	Percentiles       []float64       `toml:"percentiles"`
+	// the values kept per timer and flush interval to compute the percentiles
+	PercentileLimit int  `toml:"percentile_limit"`
+	DeleteGauges    bool `toml:"delete_gauges"`
+
+	agg   *aggregator
+	slist *types.SampleList
+
+	udpConn     net.PacketConn
+	tcpListener net.Listener
+	conns       map[net.Conn]struct{}
+	connsLock   sync.Mutex
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+var _ inputs.SampleGatherer = new(Instance)
+var _ inputs.Input = new(Statsd)
+var _ inputs.InstancesGetter = new(Statsd)
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Statsd{}
+	})
+}
+
+func (s *Statsd) Clone() inputs.Input {
+	return &Statsd{}
+}
+
+func (s *Statsd) Name() string {
+	return inputName
+}
+
+func (s *Statsd) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(s.Instances))
+	for i := 0; i < len(s.Instances); i++ {
+		ret[i] = s.Instances[i]
+	}
+	return ret
+}
+
+func (s *Statsd) Drop() {
+	for i := 0; i < len(s.Instances); i++ {
+		s.Instances[i].Drop()
+	}
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.UDPAddress) == 0 && len(ins.TCPAddress) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.MaxTCPConnections <= 0 {
+		ins.MaxTCPConnections = 250
+	}
+	if ins.FlushInterval <= 0 {
+		ins.FlushInterval = config.Duration(10 * time.Second)
+	}
+	if len(ins.Percentiles) == 0 {
+		ins.Percentiles = []float64{50, 90, 99}
+	}
+	for _, p := range ins.Percentiles {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("invalid percentile %v, should be in (0, 100]", p)
+		}
+	}
+	if ins.PercentileLimit <= 0 {
+		ins.PercentileLimit = 1000
+	}
+
+	ins.agg = newAggregator(ins.Percentiles, ins.PercentileLimit, ins.DeleteGauges)
+	ins.slist = types.NewSampleList()
+	ins.conns = make(map[net.Conn]struct{})
+	ins.done = make(chan struct{})
+	return ins.start()
+}
+
+func (ins *Instance) start() error {
+	if len(ins.UDPAddress) > 0 {
+		conn, err := net.ListenPacket("udp", ins.UDPAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen on udp %s: %v", ins.UDPAddress, err)
+		}
+		ins.udpConn = conn
+		ins.wg.Add(1)
+		go ins.serveUDP()
+	}
+
+	if len(ins.TCPAddress) > 0 {
+		listener, err := net.Listen("tcp", ins.TCPAddress)
+		if err != nil {
+			if ins.udpConn != nil {
+				ins.udpConn.Close()
+			}
+			return fmt.Errorf("failed to listen on tcp %s: %v", ins.TCPAddress, err)
+		}
+		ins.tcpListener = listener
+		ins.wg.Add(1)
+		go ins.serveTCP()
+	}
+
+	ins.wg.Add(1)
+	go ins.flushLoop()
+	return nil
+}
+
+func (ins *Instance) serveUDP() {
+	defer ins.wg.Done()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := ins.udpConn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("E! failed to read statsd udp packet:", err)
+				continue
+			}
+			return
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			ins.handleLine(line)
+		}
+	}
+}
+
+func (ins *Instance) serveTCP() {
+	defer ins.wg.Done()
+
+	sem := make(chan struct{}, ins.MaxTCPConnections)
+	for {
+		conn, err := ins.tcpListener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("E! failed to accept statsd tcp connection:", err)
+				continue
+			}
+			return
+		}
+
+		select {
+		case sem <- struct{}{}:
+		default:
+			log.Println("W! statsd tcp connections exceed max_tcp_connections, refused", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		ins.connsLock.Lock()
+		ins.conns[conn] = struct{}{}
+		ins.connsLock.Unlock()
+
+		ins.wg.Add(1)
+		go func() {
+			defer func() {
+				ins.connsLock.Lock()
+				delete(ins.conns, conn)
+				ins.connsLock.Unlock()
+				conn.Close()
+				<-sem
+				ins.wg.Done()
+			}()
+
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				ins.handleLine(scanner.Text())
+			}
+		}()
+	}
+}
+
+func (ins *Instance) handleLine(line string) {
+	m, err := parseLine(line)
+	if err != nil {
+		ins.agg.parseError()
+		if ins.DebugMod {
+			log.Println("D!", err)
+		}
+		return
+	}
+	if m != nil {
+		ins.agg.add(m)
+	}
+}
+
+func (ins *Instance) flushLoop() {
+	defer ins.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(ins.FlushInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ins.done:
+			return
+		case now := <-ticker.C:
+			ins.agg.flush(ins.slist, now)
+		}
+	}
+}
+
+// Gather hands over the values flushed since the last gather
+func (ins *Instance) Gather(slist *types.SampleList) {
+	slist.PushFrontN(ins.slist.PopBackAll())
+}
+
+func (ins *Instance) Drop() {
+	if ins.done == nil {
+		return
+	}
+	close(ins.done)
+	if ins.udpConn != nil {
+		ins.udpConn.Close()
+	}
+	if ins.tcpListener != nil {
+		ins.tcpListener.Close()
+	}
+	ins.connsLock.Lock()
+	for conn := range ins.conns {
+		conn.Close()
+	}
+	ins.connsLock.Unlock()
+	ins.wg.Wait()
+}
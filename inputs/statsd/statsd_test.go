@@ -0,0 +1,113 @@
+package statsd
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/types"
+)
+
+func TestParseLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want *metric
+		err  bool
+	}{
+		{line: "api.requests:1|c", want: &metric{name: "api.requests", typ: typeCounter, value: 1, raw: "1", rate: 1}},
+		{line: "api.requests:2|c|@0.5|#env:prod,canary", want: &metric{name: "api.requests", typ: typeCounter, value: 2, raw: "2", rate: 0.5,
+			tags: map[string]string{"env": "prod", "canary": "true"}}},
+		{line: "queue.size:-3|g", want: &metric{name: "queue.size", typ: typeGauge, value: -3, raw: "-3", rate: 1}},
+		{line: "db.query:12.5|ms|#db:users", want: &metric{name: "db.query", typ: typeTimer, value: 12.5, raw: "12.5", rate: 1,
+			tags: map[string]string{"db": "users"}}},
+		{line: "payload:512|h|c:abc123", want: &metric{name: "payload", typ: typeTimer, value: 512, raw: "512", rate: 1}},
+		{line: "users.uniques:alice|s", want: &metric{name: "users.uniques", typ: typeSet, raw: "alice", rate: 1}},
+		{line: ""},
+		{line: "_sc|redis.can_connect|0"},
+		{line: "no_value", err: true},
+		{line: "no_type:1", err: true},
+		{line: "bad_type:1|x", err: true},
+		{line: "bad_value:abc|c", err: true},
+		{line: "bad_rate:1|c|@2", err: true},
+	}
+
+	for _, c := range cases {
+		got, err := parseLine(c.line)
+		if c.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", c.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", c.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: expected %+v, got %+v", c.line, c.want, got)
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	agg := newAggregator([]float64{50, 90}, 1000, false)
+	lines := []string{
+		"hits:1|c|#path:/a",
+		"hits:1|c|@0.1|#path:/a",
+		"temp:20|g",
+		"temp:+5|g",
+		"uniques:a|s",
+		"uniques:b|s",
+		"uniques:a|s",
+	}
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, "latency:"+strconv.Itoa(i)+"|ms")
+	}
+	for _, line := range lines {
+		m, err := parseLine(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		agg.add(m)
+	}
+
+	got := flushed(agg)
+	want := map[string]float64{
+		"hits{path=/a}":             11,
+		"temp":                      25,
+		"uniques":                   2,
+		"latency_count":             10,
+		"latency_sum":               55,
+		"latency_lower":             1,
+		"latency_upper":             10,
+		"latency{quantile=0.5}":     5,
+		"latency{quantile=0.9}":     9,
+		"statsd_parse_errors_total": 0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// only the gauges survive a flush
+	got = flushed(agg)
+	want = map[string]float64{"temp": 25, "statsd_parse_errors_total": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func flushed(agg *aggregator) map[string]float64 {
+	slist := types.NewSampleList()
+	agg.flush(slist, time.Now())
+
+	ret := make(map[string]float64)
+	for _, s := range slist.PopBackAll() {
+		key := s.Metric
+		for k, v := range s.Labels {
+			key += "{" + k + "=" + v + "}"
+		}
+		ret[key] = s.Value.(float64)
+	}
+	return ret
+}
@@ -0,0 +1,111 @@
+//go:build darwin
+
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+
+	"flashcat.cloud/categraf/pkg/cmdx"
+)
+
+var (
+	ioregStatisticRe = regexp.MustCompile(`"([^"]+)"=(\d+)`)
+	ioregBSDNameRe   = regexp.MustCompile(`"BSD Name" = "([^"]+)"`)
+)
+
+// diskIOFallback reads the IOKit registry with ioreg, every IOBlockStorageDriver
+// carries a Statistics dictionary and its first IOMedia child carries the BSD name
+func diskIOFallback(names []string) (map[string]disk.IOCountersStat, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("ioreg", "-r", "-c", "IOBlockStorageDriver", "-l", "-w0")
+	cmd.Stdout = &stdout
+	err, timeout := cmdx.RunTimeout(cmd, 5*time.Second)
+	if timeout {
+		return nil, fmt.Errorf("run command: %s timeout", cmd)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseIoreg(stdout.Bytes(), names), nil
+}
+
+func parseIoreg(out []byte, names []string) map[string]disk.IOCountersStat {
+	ret := make(map[string]disk.IOCountersStat)
+
+	var current *disk.IOCountersStat
+	flush := func() {
+		if current == nil || current.Name == "" {
+			return
+		}
+		if len(names) > 0 && !contains(names, current.Name) {
+			return
+		}
+		ret[current.Name] = *current
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "+-o IOBlockStorageDriver") {
+			flush()
+			current = &disk.IOCountersStat{}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		if strings.Contains(line, `"Statistics" = {`) {
+			for _, m := range ioregStatisticRe.FindAllStringSubmatch(line, -1) {
+				v, err := strconv.ParseUint(m[2], 10, 64)
+				if err != nil {
+					continue
+				}
+				switch m[1] {
+				case "Operations (Read)":
+					current.ReadCount = v
+				case "Operations (Write)":
+					current.WriteCount = v
+				case "Bytes (Read)":
+					current.ReadBytes = v
+				case "Bytes (Write)":
+					current.WriteBytes = v
+				case "Total Time (Read)":
+					// nanoseconds, gopsutil reports milliseconds
+					current.ReadTime = v / uint64(time.Millisecond)
+				case "Total Time (Write)":
+					current.WriteTime = v / uint64(time.Millisecond)
+				}
+			}
+			continue
+		}
+
+		if current.Name == "" {
+			if m := ioregBSDNameRe.FindStringSubmatch(line); m != nil {
+				current.Name = m[1]
+			}
+		}
+	}
+	flush()
+
+	return ret
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -185,7 +185,8 @@ func (s *SystemPS) NetConnections() ([]net.ConnectionStat, error) {
 func (s *SystemPS) DiskIO(names []string) (map[string]disk.IOCountersStat, error) {
 	m, err := disk.IOCounters(names...)
 	if err != nil && strings.Contains(err.Error(), "not implemented") {
-		return nil, nil
+		// gopsutil reads IOKit through cgo on darwin, pure go builds fall back to ioreg
+		return diskIOFallback(names)
 	}
 
 	return m, err
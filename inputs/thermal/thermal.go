@@ -0,0 +1,216 @@
+package thermal
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	osExec "os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/pkg/cmdx"
+	"flashcat.cloud/categraf/types"
+)
+
+const (
+	inputName = "thermal"
+
+	defaultThermalPath = "/sys/class/thermal"
+)
+
+// throttledBits are the bits of vcgencmd get_throttled, the low bits are the current state,
+// the same bits shifted by 16 tell whether the state occurred since boot
+var throttledBits = []struct {
+	bit  uint
+	name string
+}{
+	{0, "under_voltage"},
+	{1, "frequency_capped"},
+	{2, "throttled"},
+	{3, "soft_temp_limit"},
+}
+
+var clockPattern = regexp.MustCompile(`^frequency\(\d+\)=(\d+)$`)
+
+type Thermal struct {
+	config.PluginConfig
+
+	// the thermal zones are read from <thermal_path>/thermal_zone*/temp
+	ThermalPath string `toml:"thermal_path"`
+	// vcgencmd of the raspberry pi, looked up in the PATH when empty, not called when not found
+	VcgencmdPath string `toml:"vcgencmd_path"`
+	// disable the calls to vcgencmd even when it's found
+	DisableVcgencmd bool            `toml:"disable_vcgencmd"`
+	VcgencmdTimeout config.Duration `toml:"vcgencmd_timeout"`
+	// clocks of vcgencmd measure_clock
+	Clocks []string `toml:"clocks"`
+
+	vcgencmd string
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Thermal{}
+	})
+}
+
+func (t *Thermal) Clone() inputs.Input {
+	return &Thermal{}
+}
+
+func (t *Thermal) Name() string {
+	return inputName
+}
+
+func (t *Thermal) Init() error {
+	if t.ThermalPath == "" {
+		t.ThermalPath = defaultThermalPath
+	}
+	if t.VcgencmdTimeout <= 0 {
+		t.VcgencmdTimeout = config.Duration(3 * time.Second)
+	}
+	if len(t.Clocks) == 0 {
+		t.Clocks = []string{"arm"}
+	}
+	if t.DisableVcgencmd {
+		return nil
+	}
+	if t.VcgencmdPath != "" {
+		if _, err := os.Stat(t.VcgencmdPath); err != nil {
+			return fmt.Errorf("vcgencmd_path: %v", err)
+		}
+		t.vcgencmd = t.VcgencmdPath
+	} else if p, err := osExec.LookPath("vcgencmd"); err == nil {
+		t.vcgencmd = p
+	}
+	return nil
+}
+
+func (t *Thermal) Gather(slist *types.SampleList) {
+	zones, err := ReadThermalZones(t.ThermalPath)
+	if err != nil {
+		log.Println("E! failed to read thermal zones:", err)
+	}
+	for _, z := range zones {
+		slist.PushSample(inputName, "zone_temp_celsius", z.Temp, map[string]string{"zone": z.Zone, "type": z.Type})
+	}
+
+	if t.vcgencmd == "" {
+		return
+	}
+	t.gatherThrottled(slist)
+	for _, clock := range t.Clocks {
+		t.gatherClock(slist, clock)
+	}
+}
+
+func (t *Thermal) gatherThrottled(slist *types.SampleList) {
+	out, err := t.run("get_throttled")
+	if err != nil {
+		log.Println("E! failed to get throttled state:", err)
+		return
+	}
+	throttled, err := ParseThrottled(out)
+	if err != nil {
+		log.Println("E! failed to parse throttled state:", err)
+		return
+	}
+	fields := map[string]interface{}{
+		"throttled_raw": throttled,
+	}
+	for _, b := range throttledBits {
+		fields[b.name] = throttled >> b.bit & 1
+		fields[b.name+"_occurred"] = throttled >> (b.bit + 16) & 1
+	}
+	slist.PushSamples(inputName, fields)
+}
+
+func (t *Thermal) gatherClock(slist *types.SampleList, clock string) {
+	out, err := t.run("measure_clock", clock)
+	if err != nil {
+		log.Println("E! failed to measure clock", clock, ":", err)
+		return
+	}
+	hz, err := ParseClock(out)
+	if err != nil {
+		log.Println("E! failed to parse clock", clock, ":", err)
+		return
+	}
+	slist.PushSample(inputName, "clock_hz", hz, map[string]string{"clock": clock})
+}
+
+func (t *Thermal) run(args ...string) (string, error) {
+	cmd := osExec.Command(t.vcgencmd, args...)
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	err, timeout := cmdx.RunTimeout(cmd, time.Duration(t.VcgencmdTimeout))
+	if timeout {
+		return "", fmt.Errorf("%s %s timeout", t.vcgencmd, strings.Join(args, " "))
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %v %s", t.vcgencmd, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out.String(), nil
+}
+
+// Zone is the temperature of a thermal zone, in degrees celsius
+type Zone struct {
+	Zone string
+	Type string
+	Temp float64
+}
+
+// ReadThermalZones reads the temperature and the type of the thermal zones of the sysfs,
+// the zones without sensor, e.g. whose temp can't be read, are skipped
+func ReadThermalZones(path string) ([]Zone, error) {
+	dirs, err := filepath.Glob(filepath.Join(path, "thermal_zone*"))
+	if err != nil {
+		return nil, err
+	}
+	var zones []Zone
+	for _, dir := range dirs {
+		b, err := os.ReadFile(filepath.Join(dir, "temp"))
+		if err != nil {
+			continue
+		}
+		// millidegree celsius
+		milli, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			continue
+		}
+		z := Zone{Zone: filepath.Base(dir), Temp: float64(milli) / 1000}
+		if b, err := os.ReadFile(filepath.Join(dir, "type")); err == nil {
+			z.Type = strings.TrimSpace(string(b))
+		}
+		zones = append(zones, z)
+	}
+	return zones, nil
+}
+
+// ParseThrottled parses the output of vcgencmd get_throttled, e.g. throttled=0x50005
+func ParseThrottled(out string) (uint64, error) {
+	out = strings.TrimSpace(out)
+	v, found := strings.CutPrefix(out, "throttled=")
+	if !found {
+		return 0, fmt.Errorf("unexpected output: %q", out)
+	}
+	return strconv.ParseUint(v, 0, 64)
+}
+
+// ParseClock parses the output of vcgencmd measure_clock, e.g. frequency(48)=1500398464
+func ParseClock(out string) (uint64, error) {
+	out = strings.TrimSpace(out)
+	m := clockPattern.FindStringSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("unexpected output: %q", out)
+	}
+	return strconv.ParseUint(m[1], 10, 64)
+}
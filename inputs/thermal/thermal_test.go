@@ -0,0 +1,58 @@
+package thermal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadThermalZones(t *testing.T) {
+	dir := t.TempDir()
+	write := func(zone, name, content string) {
+		if err := os.MkdirAll(filepath.Join(dir, zone), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, zone, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("thermal_zone0", "temp", "48312\n")
+	write("thermal_zone0", "type", "cpu-thermal\n")
+	write("thermal_zone1", "type", "no-sensor\n")
+	write("cooling_device0", "type", "fan\n")
+
+	zones, err := ReadThermalZones(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 {
+		t.Fatalf("expected 1 zone, got %+v", zones)
+	}
+	if z := zones[0]; z.Zone != "thermal_zone0" || z.Type != "cpu-thermal" || z.Temp != 48.312 {
+		t.Errorf("unexpected zone %+v", z)
+	}
+}
+
+func TestParseThrottled(t *testing.T) {
+	v, err := ParseThrottled("throttled=0x50005\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// under-voltage and throttled now, under-voltage and throttling occurred
+	if v != 0x50005 || v&1 != 1 || v>>2&1 != 1 || v>>1&1 != 0 || v>>16&1 != 1 || v>>18&1 != 1 {
+		t.Errorf("unexpected throttled %#x", v)
+	}
+	if _, err := ParseThrottled("error=1"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	hz, err := ParseClock("frequency(48)=1500398464\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hz != 1500398464 {
+		t.Errorf("unexpected clock %d", hz)
+	}
+}
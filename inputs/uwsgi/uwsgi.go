@@ -0,0 +1,293 @@
+package uwsgi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "uwsgi"
+
+type Uwsgi struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &Uwsgi{}
+	})
+}
+
+func (u *Uwsgi) Clone() inputs.Input {
+	return &Uwsgi{}
+}
+
+func (u *Uwsgi) Name() string {
+	return inputName
+}
+
+func (u *Uwsgi) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(u.Instances))
+	for i := 0; i < len(u.Instances); i++ {
+		ret[i] = u.Instances[i]
+	}
+	return ret
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// Servers are the addresses of the stats servers, tcp://host:port, unix:///path or
+	// http://host:port for the stats server started with --stats-http
+	Servers []string        `toml:"servers"`
+	Timeout config.Duration `toml:"timeout"`
+
+	client *http.Client
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Servers) == 0 {
+		return types.ErrInstancesEmpty
+	}
+	if ins.Timeout <= 0 {
+		ins.Timeout = config.Duration(5 * time.Second)
+	}
+	for _, s := range ins.Servers {
+		u, err := url.Parse(s)
+		if err != nil {
+			return fmt.Errorf("failed to parse the server: %s, error: %v", s, err)
+		}
+		switch u.Scheme {
+		case "tcp", "unix", "http", "https":
+		default:
+			return fmt.Errorf("unsupported scheme %q of server %s, should be tcp, unix, http or https", u.Scheme, s)
+		}
+	}
+	ins.client = &http.Client{Timeout: time.Duration(ins.Timeout)}
+	return nil
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	var wg sync.WaitGroup
+	for _, server := range ins.expandServers() {
+		wg.Add(1)
+		go func(server string) {
+			defer wg.Done()
+			labels := map[string]string{"server": server}
+			st, err := ins.fetch(server)
+			if err != nil {
+				log.Println("E! failed to gather uwsgi stats of", server, "error:", err)
+				slist.PushSample(inputName, "up", 0, labels)
+				return
+			}
+			slist.PushSample(inputName, "up", 1, labels)
+			gatherStats(st, slist, labels)
+		}(server)
+	}
+	wg.Wait()
+}
+
+// expandServers returns the servers with the glob patterns of the unix sockets resolved,
+// a socket removed by a reload of uwsgi is just missing from the result
+func (ins *Instance) expandServers() []string {
+	servers := make([]string, 0, len(ins.Servers))
+	for _, s := range ins.Servers {
+		u, err := url.Parse(s)
+		if err != nil || u.Scheme != "unix" || !strings.ContainsAny(u.Path, "*?[") {
+			servers = append(servers, s)
+			continue
+		}
+		paths, err := filepath.Glob(u.Path)
+		if err != nil {
+			log.Println("E! failed to expand the server:", s, "error:", err)
+			continue
+		}
+		for _, p := range paths {
+			servers = append(servers, "unix://"+p)
+		}
+	}
+	return servers
+}
+
+// fetch reads the JSON document the stats server writes before closing the connection
+func (ins *Instance) fetch(server string) (*stats, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader
+	switch u.Scheme {
+	case "http", "https":
+		resp, err := ins.client.Get(server)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+		r = resp.Body
+	default:
+		addr := u.Host
+		if u.Scheme == "unix" {
+			addr = u.Path
+		}
+		conn, err := net.DialTimeout(u.Scheme, addr, time.Duration(ins.Timeout))
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if err := conn.SetDeadline(time.Now().Add(time.Duration(ins.Timeout))); err != nil {
+			return nil, err
+		}
+		r = conn
+	}
+
+	var st stats
+	if err := json.NewDecoder(r).Decode(&st); err != nil {
+		return nil, fmt.Errorf("failed to decode the stats: %v", err)
+	}
+	return &st, nil
+}
+
+// gatherStats pushes the metrics of the master, the sockets and the workers. The workers are
+// labeled with their id, which is kept by the worker respawned in place of another, so that
+// a reload or the harakiri of a worker doesn't create new series.
+func gatherStats(st *stats, slist *types.SampleList, labels map[string]string) {
+	slist.PushSamples(inputName, map[string]interface{}{
+		"listen_queue":        st.ListenQueue,
+		"listen_queue_errors": st.ListenQueueErrors,
+		"signal_queue":        st.SignalQueue,
+		"load":                st.Load,
+	}, labels)
+
+	for _, s := range st.Sockets {
+		slist.PushSamples(inputName, map[string]interface{}{
+			"socket_queue":     s.Queue,
+			"socket_max_queue": s.MaxQueue,
+		}, labels, map[string]string{"socket": s.Name, "proto": s.Proto})
+	}
+
+	var running, busy int
+	apps := make(map[appKey]*appStats)
+	for _, w := range st.Workers {
+		// the workers not spawned yet by the cheaper subsystem or during a reload have no pid
+		if w.Pid == 0 {
+			continue
+		}
+		running++
+		isBusy := 0
+		if w.Status == "busy" {
+			busy++
+			isBusy = 1
+		}
+		workerLabels := map[string]string{"worker_id": strconv.Itoa(w.ID)}
+		slist.PushSamples(inputName, map[string]interface{}{
+			"worker_requests":                  w.Requests,
+			"worker_exceptions":                w.Exceptions,
+			"worker_harakiri_count":            w.HarakiriCount,
+			"worker_signals":                   w.Signals,
+			"worker_respawn_count":             w.RespawnCount,
+			"worker_accepting":                 w.Accepting,
+			"worker_busy":                      isBusy,
+			"worker_avg_response_time_seconds": float64(w.AvgRt) / 1e6,
+			"worker_running_time_seconds":      float64(w.RunningTime) / 1e6,
+			"worker_rss_bytes":                 w.Rss,
+			"worker_vsz_bytes":                 w.Vsz,
+			"worker_tx_bytes":                  w.Tx,
+		}, labels, workerLabels)
+
+		for _, a := range w.Apps {
+			key := appKey{id: a.ID, mountpoint: a.MountPoint}
+			if apps[key] == nil {
+				apps[key] = &appStats{}
+			}
+			apps[key].requests += a.Requests
+			apps[key].exceptions += a.Exceptions
+		}
+	}
+
+	slist.PushSamples(inputName, map[string]interface{}{
+		"workers":      running,
+		"busy_workers": busy,
+		"idle_workers": running - busy,
+	}, labels)
+
+	// the apps are loaded in every worker, their counters are the sum of the ones of the workers
+	for key, a := range apps {
+		appLabels := map[string]string{"app_id": strconv.Itoa(key.id), "mountpoint": key.mountpoint}
+		slist.PushSamples(inputName, map[string]interface{}{
+			"app_requests":   a.requests,
+			"app_exceptions": a.exceptions,
+		}, labels, appLabels)
+	}
+}
+
+type appKey struct {
+	id         int
+	mountpoint string
+}
+
+type appStats struct {
+	requests   uint64
+	exceptions uint64
+}
+
+// stats is the document of the uwsgi stats server
+type stats struct {
+	Version           string   `json:"version"`
+	ListenQueue       uint64   `json:"listen_queue"`
+	ListenQueueErrors uint64   `json:"listen_queue_errors"`
+	SignalQueue       uint64   `json:"signal_queue"`
+	Load              uint64   `json:"load"`
+	Pid               int      `json:"pid"`
+	Sockets           []socket `json:"sockets"`
+	Workers           []worker `json:"workers"`
+}
+
+type socket struct {
+	Name     string `json:"name"`
+	Proto    string `json:"proto"`
+	Queue    uint64 `json:"queue"`
+	MaxQueue uint64 `json:"max_queue"`
+}
+
+type worker struct {
+	ID            int    `json:"id"`
+	Pid           int    `json:"pid"`
+	Accepting     int    `json:"accepting"`
+	Requests      uint64 `json:"requests"`
+	Exceptions    uint64 `json:"exceptions"`
+	HarakiriCount uint64 `json:"harakiri_count"`
+	Signals       uint64 `json:"signals"`
+	Status        string `json:"status"`
+	Rss           uint64 `json:"rss"`
+	Vsz           uint64 `json:"vsz"`
+	RunningTime   uint64 `json:"running_time"`
+	RespawnCount  uint64 `json:"respawn_count"`
+	Tx            uint64 `json:"tx"`
+	AvgRt         uint64 `json:"avg_rt"`
+	Apps          []app  `json:"apps"`
+}
+
+type app struct {
+	ID         int    `json:"id"`
+	MountPoint string `json:"mountpoint"`
+	Requests   uint64 `json:"requests"`
+	Exceptions uint64 `json:"exceptions"`
+}
@@ -0,0 +1,208 @@
+//go:build windows
+
+package winperfcounters
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// error and status codes from pdhmsg.h
+const (
+	pdhCstatusValidData        = 0x00000000
+	pdhCstatusNewData          = 0x00000001
+	pdhMoreData                = 0x800007D2
+	pdhNoData                  = 0x800007D5
+	pdhCalcNegativeDenominator = 0x800007D6
+	pdhCalcNegativeValue       = 0x800007D8
+	pdhCstatusInvalidData      = 0xC0000BBA
+	pdhInvalidData             = 0xC0000BC6
+	pdhCstatusNoInstance       = 0x800007D1
+)
+
+// formats of PdhGetFormattedCounterValue
+const (
+	pdhFmtLong     = 0x00000100
+	pdhFmtDouble   = 0x00000200
+	pdhFmtLarge    = 0x00000400
+	pdhFmtNoCap100 = 0x00008000
+)
+
+// counter types from winperf.h which are not calculated and keep their integer format
+const (
+	perfCounterRawcount      = 0x00010000
+	perfCounterLargeRawcount = 0x00010100
+)
+
+var (
+	libPdh = windows.NewLazySystemDLL("pdh.dll")
+
+	procPdhOpenQuery                = libPdh.NewProc("PdhOpenQuery")
+	procPdhCloseQuery               = libPdh.NewProc("PdhCloseQuery")
+	procPdhAddEnglishCounterW       = libPdh.NewProc("PdhAddEnglishCounterW")
+	procPdhAddCounterW              = libPdh.NewProc("PdhAddCounterW")
+	procPdhCollectQueryData         = libPdh.NewProc("PdhCollectQueryData")
+	procPdhGetCounterInfoW          = libPdh.NewProc("PdhGetCounterInfoW")
+	procPdhGetFormattedCounterValue = libPdh.NewProc("PdhGetFormattedCounterValue")
+	procPdhGetFormattedCounterArray = libPdh.NewProc("PdhGetFormattedCounterArrayW")
+)
+
+type pdhError uint32
+
+func (e pdhError) Error() string {
+	return fmt.Sprintf("pdh error 0x%08X", uint32(e))
+}
+
+func pdhErr(ret uintptr) error {
+	if uint32(ret) == pdhCstatusValidData {
+		return nil
+	}
+	return pdhError(uint32(ret))
+}
+
+// pdhFmtCounterValue is PDH_FMT_COUNTERVALUE, the union is kept as raw 8 bytes
+type pdhFmtCounterValue struct {
+	CStatus uint32
+	_       uint32
+	Value   uint64
+}
+
+// PDH_FMT_COUNTERVALUE_ITEM_W is { LPWSTR szName; PDH_FMT_COUNTERVALUE FmtValue; },
+// FmtValue is 8 byte aligned on both 32 and 64 bit windows
+const (
+	pdhFmtItemSize        = 24
+	pdhFmtItemValueOffset = 8
+)
+
+func pdhOpenQuery() (windows.Handle, error) {
+	var query windows.Handle
+	ret, _, _ := procPdhOpenQuery.Call(0, 0, uintptr(unsafe.Pointer(&query)))
+	return query, pdhErr(ret)
+}
+
+func pdhCloseQuery(query windows.Handle) error {
+	ret, _, _ := procPdhCloseQuery.Call(uintptr(query))
+	return pdhErr(ret)
+}
+
+// pdhAddCounter prefers the english counter names so the configured paths
+// keep working on localized windows, PdhAddEnglishCounterW requires vista or later
+func pdhAddCounter(query windows.Handle, path string) (windows.Handle, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	proc := procPdhAddEnglishCounterW
+	if proc.Find() != nil {
+		proc = procPdhAddCounterW
+	}
+
+	var counter windows.Handle
+	ret, _, _ := proc.Call(uintptr(query), uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&counter)))
+	return counter, pdhErr(ret)
+}
+
+func pdhCollectQueryData(query windows.Handle) error {
+	ret, _, _ := procPdhCollectQueryData.Call(uintptr(query))
+	return pdhErr(ret)
+}
+
+// pdhCounterType returns dwType of PDH_COUNTER_INFO, the second DWORD of the struct
+func pdhCounterType(counter windows.Handle) (uint32, error) {
+	var size uint32
+	ret, _, _ := procPdhGetCounterInfoW.Call(uintptr(counter), 0, uintptr(unsafe.Pointer(&size)), 0)
+	if uint32(ret) != pdhMoreData {
+		return 0, pdhErr(ret)
+	}
+	if size < 8 {
+		return 0, fmt.Errorf("unexpected counter info size %d", size)
+	}
+
+	buf := make([]byte, size)
+	ret, _, _ = procPdhGetCounterInfoW.Call(uintptr(counter), 0, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&buf[0])))
+	if err := pdhErr(ret); err != nil {
+		return 0, err
+	}
+	return *(*uint32)(unsafe.Pointer(&buf[4])), nil
+}
+
+// detectFormat picks the integer formats for raw counters and double for calculated ones
+func detectFormat(counterType uint32) uint32 {
+	switch counterType {
+	case perfCounterRawcount:
+		return pdhFmtLong
+	case perfCounterLargeRawcount:
+		return pdhFmtLarge
+	default:
+		return pdhFmtDouble
+	}
+}
+
+func decodeValue(format uint32, raw uint64) float64 {
+	switch format {
+	case pdhFmtLong:
+		return float64(int32(uint32(raw)))
+	case pdhFmtLarge:
+		return float64(int64(raw))
+	default:
+		return math.Float64frombits(raw)
+	}
+}
+
+func checkStatus(status uint32) error {
+	if status == pdhCstatusValidData || status == pdhCstatusNewData {
+		return nil
+	}
+	return pdhError(status)
+}
+
+func pdhGetFormattedCounterValue(counter windows.Handle, format uint32) (float64, error) {
+	var value pdhFmtCounterValue
+	ret, _, _ := procPdhGetFormattedCounterValue.Call(uintptr(counter), uintptr(format|pdhFmtNoCap100), 0, uintptr(unsafe.Pointer(&value)))
+	if err := pdhErr(ret); err != nil {
+		return 0, err
+	}
+	if err := checkStatus(value.CStatus); err != nil {
+		return 0, err
+	}
+	return decodeValue(format, value.Value), nil
+}
+
+// pdhGetFormattedCounterArray returns the values of a wildcard counter by instance name,
+// instances with an invalid status are reported in the errs map
+func pdhGetFormattedCounterArray(counter windows.Handle, format uint32) (map[string]float64, map[string]error, error) {
+	var size, count uint32
+	ret, _, _ := procPdhGetFormattedCounterArray.Call(uintptr(counter), uintptr(format|pdhFmtNoCap100),
+		uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), 0)
+	if uint32(ret) != pdhMoreData {
+		return nil, nil, pdhErr(ret)
+	}
+	if size == 0 || count == 0 {
+		return nil, nil, pdhError(pdhNoData)
+	}
+
+	buf := make([]byte, size)
+	ret, _, _ = procPdhGetFormattedCounterArray.Call(uintptr(counter), uintptr(format|pdhFmtNoCap100),
+		uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&buf[0])))
+	if err := pdhErr(ret); err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]float64, count)
+	errs := make(map[string]error)
+	for i := uint32(0); i < count; i++ {
+		item := unsafe.Pointer(&buf[i*pdhFmtItemSize])
+		name := windows.UTF16PtrToString(*(**uint16)(item))
+		value := (*pdhFmtCounterValue)(unsafe.Add(item, pdhFmtItemValueOffset))
+		if err := checkStatus(value.CStatus); err != nil {
+			errs[name] = err
+			continue
+		}
+		values[name] = decodeValue(format, value.Value)
+	}
+	return values, errs, nil
+}
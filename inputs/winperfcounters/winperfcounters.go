@@ -0,0 +1,228 @@
+//go:build windows
+
+package winperfcounters
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sys/windows"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/inputs"
+	"flashcat.cloud/categraf/types"
+)
+
+const inputName = "winperfcounters"
+
+type WinPerfCounters struct {
+	config.PluginConfig
+	Instances []*Instance `toml:"instances"`
+}
+
+func init() {
+	inputs.Add(inputName, func() inputs.Input {
+		return &WinPerfCounters{}
+	})
+}
+
+func (w *WinPerfCounters) Clone() inputs.Input {
+	return &WinPerfCounters{}
+}
+
+func (w *WinPerfCounters) Name() string {
+	return inputName
+}
+
+func (w *WinPerfCounters) GetInstances() []inputs.Instance {
+	ret := make([]inputs.Instance, len(w.Instances))
+	for i := 0; i < len(w.Instances); i++ {
+		ret[i] = w.Instances[i]
+	}
+	return ret
+}
+
+func (w *WinPerfCounters) Drop() {
+	for i := 0; i < len(w.Instances); i++ {
+		w.Instances[i].Drop()
+	}
+}
+
+type Instance struct {
+	config.InstanceConfig
+
+	// counter paths like \Processor(_Total)\% Processor Time or \LogicalDisk(*)\Free Megabytes
+	Counters []string `toml:"counters"`
+
+	query    windows.Handle
+	counters []*counter
+}
+
+type counter struct {
+	path     string
+	object   string
+	instance string
+	name     string
+	metric   string
+	wildcard bool
+
+	handle windows.Handle
+	format uint32
+
+	// last valid value by instance name, pushed again on PDH_CALC_NEGATIVE_DENOMINATOR
+	last map[string]float64
+}
+
+func (ins *Instance) Init() error {
+	if len(ins.Counters) == 0 {
+		return types.ErrInstancesEmpty
+	}
+
+	query, err := pdhOpenQuery()
+	if err != nil {
+		return fmt.Errorf("failed to open pdh query: %v", err)
+	}
+	ins.query = query
+
+	for _, path := range ins.Counters {
+		c, err := parseCounterPath(path)
+		if err != nil {
+			log.Println("E! invalid counter path:", path, "error:", err)
+			continue
+		}
+
+		c.handle, err = pdhAddCounter(query, path)
+		if err != nil {
+			log.Println("E! failed to add counter:", path, "error:", err)
+			continue
+		}
+
+		counterType, err := pdhCounterType(c.handle)
+		if err != nil {
+			log.Println("W! failed to get type of counter:", path, "error:", err, ", use double format")
+		}
+		c.format = detectFormat(counterType)
+		ins.counters = append(ins.counters, c)
+	}
+
+	if len(ins.counters) == 0 {
+		pdhCloseQuery(query)
+		return fmt.Errorf("none of the counters %v is available", ins.Counters)
+	}
+
+	// rate counters need two samples, collect the first one now
+	if err := pdhCollectQueryData(query); err != nil && ins.DebugMod {
+		log.Println("D! failed to collect initial pdh query data:", err)
+	}
+
+	return nil
+}
+
+func (ins *Instance) Drop() {
+	if ins.query != 0 {
+		pdhCloseQuery(ins.query)
+		ins.query = 0
+	}
+}
+
+func (ins *Instance) Gather(slist *types.SampleList) {
+	if err := pdhCollectQueryData(ins.query); err != nil {
+		log.Println("E! failed to collect pdh query data:", err)
+		return
+	}
+
+	for _, c := range ins.counters {
+		if !c.wildcard {
+			v, err := pdhGetFormattedCounterValue(c.handle, c.format)
+			ins.push(slist, c, c.instance, v, err)
+			continue
+		}
+
+		values, errs, err := pdhGetFormattedCounterArray(c.handle, c.format)
+		if err != nil {
+			if !isNoData(err) {
+				log.Println("E! failed to get counter:", c.path, "error:", err)
+			}
+			continue
+		}
+		for instance, v := range values {
+			ins.push(slist, c, instance, v, nil)
+		}
+		for instance, err := range errs {
+			ins.push(slist, c, instance, 0, err)
+		}
+	}
+}
+
+func (ins *Instance) push(slist *types.SampleList, c *counter, instance string, v float64, err error) {
+	if err != nil {
+		var pe pdhError
+		if errors.As(err, &pe) && uint32(pe) == pdhCalcNegativeDenominator {
+			last, has := c.last[instance]
+			if !has {
+				return
+			}
+			v = last
+		} else {
+			if !isNoData(err) {
+				log.Println("E! failed to get counter:", c.path, "instance:", instance, "error:", err)
+			}
+			return
+		}
+	}
+	c.last[instance] = v
+
+	labels := map[string]string{"object": c.object}
+	if instance != "" {
+		labels["instance"] = instance
+	}
+	slist.PushSample(inputName, c.metric, v, labels)
+}
+
+func isNoData(err error) bool {
+	var pe pdhError
+	if !errors.As(err, &pe) {
+		return false
+	}
+	switch uint32(pe) {
+	case pdhNoData, pdhCstatusNoInstance, pdhCstatusInvalidData, pdhInvalidData, pdhCalcNegativeValue:
+		return true
+	}
+	return false
+}
+
+// counterPathRegex matches [\\computer]\object[(parent/instance#index)]\counter
+var counterPathRegex = regexp.MustCompile(`^(?:\\\\[^\\]+)?\\([^\\(]+)(?:\((.+)\))?\\([^\\]+)$`)
+
+func parseCounterPath(path string) (*counter, error) {
+	m := counterPathRegex.FindStringSubmatch(path)
+	if m == nil {
+		return nil, errors.New(`expected \object(instance)\counter`)
+	}
+
+	c := &counter{
+		path:     path,
+		object:   m[1],
+		instance: m[2],
+		name:     m[3],
+		wildcard: strings.Contains(m[2], "*"),
+		last:     make(map[string]float64),
+	}
+	c.metric = sanitize(c.object + "_" + c.name)
+	return c, nil
+}
+
+var (
+	metricReplacer = strings.NewReplacer("%", "percent", "/", "_per_", "#", "num")
+	invalidChars   = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// sanitize turns Processor and % Processor Time into processor_percent_processor_time
+func sanitize(name string) string {
+	name = metricReplacer.Replace(strings.ToLower(name))
+	name = invalidChars.ReplaceAllString(name, "_")
+	return strings.Trim(name, "_")
+}
@@ -0,0 +1,5 @@
+//go:build !windows
+
+// Package winperfcounters reads Windows Performance Counters through the PDH API,
+// the input is only registered on windows.
+package winperfcounters
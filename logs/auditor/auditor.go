@@ -13,6 +13,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,6 +27,9 @@ const DefaultRegistryFilename = "registry.json"
 const defaultFlushPeriod = 1 * time.Second
 const defaultCleanupPeriod = 300 * time.Second
 
+// fileIdentifierPrefix is the prefix of the identifiers of the file tailers
+const fileIdentifierPrefix = "file:"
+
 // latest version of the API used by the auditor to retrieve the registry from disk.
 const registryAPIVersion = 2
 
@@ -161,6 +165,10 @@ func (a *RegistryAuditor) run() {
 				// inputChan has been closed, no need to update the registry anymore
 				return
 			}
+			if msg.Origin == nil {
+				// replayed from the disk buffer, acknowledged when spooled
+				continue
+			}
 			// update the registry with new entry
 			a.updateRegistry(msg.Origin.Identifier, msg.Origin.Offset, msg.Origin.LogSource.Config.TailingMode)
 		case <-cleanUpTicker.C:
@@ -201,15 +209,22 @@ func (a *RegistryAuditor) recoverRegistry() map[string]*RegistryEntry {
 	return r
 }
 
-// cleanupRegistry removes expired entries from the registry
+// cleanupRegistry removes expired entries from the registry, the offsets of the files
+// which still exist are kept so that an idle file is not read again from the beginning
 func (a *RegistryAuditor) cleanupRegistry() {
 	a.registryMutex.Lock()
 	defer a.registryMutex.Unlock()
 	expireBefore := time.Now().UTC().Add(-a.entryTTL)
-	for path, entry := range a.registry {
-		if entry.LastUpdated.Before(expireBefore) {
-			delete(a.registry, path)
+	for identifier, entry := range a.registry {
+		if !entry.LastUpdated.Before(expireBefore) {
+			continue
+		}
+		if path, isFile := strings.CutPrefix(identifier, fileIdentifierPrefix); isFile {
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
 		}
+		delete(a.registry, identifier)
 	}
 }
 
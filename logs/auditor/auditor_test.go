@@ -0,0 +1,60 @@
+//go:build !no_logs
+
+package auditor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanupRegistry(t *testing.T) {
+	dir := t.TempDir()
+	idle := filepath.Join(dir, "idle.log")
+	if err := os.WriteFile(idle, []byte("line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := New(dir, DefaultRegistryFilename, time.Hour)
+	expired := time.Now().UTC().Add(-2 * time.Hour)
+	a.registry = map[string]*RegistryEntry{
+		// recently updated
+		"file:" + filepath.Join(dir, "active.log"): {LastUpdated: time.Now().UTC(), Offset: "10"},
+		// expired, the file still exists
+		"file:" + idle: {LastUpdated: expired, Offset: "5"},
+		// expired, the file was deleted
+		"file:" + filepath.Join(dir, "deleted.log"): {LastUpdated: expired, Offset: "7"},
+		// expired, not a file
+		"docker:0123456789abcdef": {LastUpdated: expired, Offset: "2024-03-01T00:00:00Z"},
+	}
+
+	a.cleanupRegistry()
+	if len(a.registry) != 2 {
+		t.Fatalf("expected 2 entries, got %v", a.readOnlyRegistryCopy())
+	}
+	if a.GetOffset("file:"+filepath.Join(dir, "active.log")) != "10" {
+		t.Error("expected the offset of the recently updated file to be kept")
+	}
+	if a.GetOffset("file:"+idle) != "5" {
+		t.Error("expected the offset of the idle file to be kept")
+	}
+}
+
+func TestRegistryRecovery(t *testing.T) {
+	dir := t.TempDir()
+	a := New(dir, DefaultRegistryFilename, time.Hour)
+	a.registry = make(map[string]*RegistryEntry)
+	a.updateRegistry("file:/var/log/app.log", "42", "beginning")
+	// the entries without identifier are not tracked
+	a.updateRegistry("", "1", "end")
+	if err := a.flushRegistry(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(dir, DefaultRegistryFilename, time.Hour)
+	b.registry = b.recoverRegistry()
+	if len(b.registry) != 1 || b.GetOffset("file:/var/log/app.log") != "42" || b.GetTailingMode("file:/var/log/app.log") != "beginning" {
+		t.Errorf("unexpected recovered registry %v", b.readOnlyRegistryCopy())
+	}
+}
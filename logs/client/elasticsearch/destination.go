@@ -0,0 +1,398 @@
+//go:build !no_logs
+
+package elasticsearch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	coreconfig "flashcat.cloud/categraf/config"
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/client"
+	"flashcat.cloud/categraf/pkg/backoff"
+	httputils "flashcat.cloud/categraf/pkg/httpx"
+)
+
+const (
+	// NDJSONContentType is the content type of the bulk requests
+	NDJSONContentType = "application/x-ndjson"
+
+	defaultMaxRetries = 3
+	defaultTimeout    = 10 * time.Second
+	// sampleInterval is the minimum delay between the logged samples of the dropped documents
+	sampleInterval = 30 * time.Second
+	// sampleMaxSize bounds the size of the logged samples
+	sampleMaxSize = 512
+)
+
+// Elasticsearch errors.
+var (
+	errClient = errors.New("client error")
+	errServer = errors.New("server error")
+)
+
+var droppedDocuments = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "logs_elasticsearch_dropped_documents_total",
+	Help: "Number of log documents rejected by elasticsearch and dropped, by error type.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(droppedDocuments)
+}
+
+// Destination sends the bulk requests built by the bulk serializer to elasticsearch.
+// The whole requests rejected with 429 or 5xx are retried by the sender, the documents
+// rejected with 429 are sent again up to max_retries times, the others, e.g. mapping
+// errors, are dropped.
+type Destination struct {
+	hosts               []string
+	current             atomic.Int32
+	username            string
+	password            string
+	apiKey              string
+	useCompression      bool
+	compressionLevel    int
+	maxRetries          int
+	client              *httputils.ResetClient
+	destinationsContext *client.DestinationsContext
+	once                sync.Once
+	payloadChan         chan []byte
+	climit              chan struct{} // semaphore for limiting concurrent background sends
+	backoff             backoff.Policy
+	nbErrors            int
+	blockedUntil        time.Time
+	lastSample          atomic.Int64
+}
+
+// NewDestination returns a new Destination sending to the hosts of [logs.elasticsearch].
+// If `maxConcurrentBackgroundSends` > 0, then at most that many background payloads will be sent concurrently, else
+// there is no concurrency and the background sending pipeline will block while sending each payload.
+func NewDestination(endpoint logsconfig.Endpoint, destinationsContext *client.DestinationsContext, maxConcurrentBackgroundSends int) *Destination {
+	cfg := coreconfig.Config.Logs.Elasticsearch
+	if cfg == nil {
+		cfg = &coreconfig.LogsElasticsearch{}
+	}
+	return newDestination(endpoint, cfg, destinationsContext, maxConcurrentBackgroundSends)
+}
+
+func newDestination(endpoint logsconfig.Endpoint, cfg *coreconfig.LogsElasticsearch, destinationsContext *client.DestinationsContext, maxConcurrentBackgroundSends int) *Destination {
+	if maxConcurrentBackgroundSends < 0 {
+		maxConcurrentBackgroundSends = 0
+	}
+
+	policy := backoff.NewPolicy(
+		endpoint.BackoffFactor,
+		endpoint.BackoffBase,
+		endpoint.BackoffMax,
+		endpoint.RecoveryInterval,
+		endpoint.RecoveryReset,
+	)
+
+	maxRetries := cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+	timeout := time.Duration(cfg.Timeout)
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	tlsConfig, err := cfg.ClientConfig.TLSConfig()
+	if err != nil {
+		log.Println("E! failed to init tls config of the elasticsearch logs destination:", err)
+	}
+
+	return &Destination{
+		hosts:               buildURLs(endpoint),
+		username:            cfg.Username,
+		password:            cfg.Password,
+		apiKey:              strings.TrimSpace(cfg.APIKey),
+		useCompression:      endpoint.UseCompression,
+		compressionLevel:    endpoint.CompressionLevel,
+		maxRetries:          maxRetries,
+		client:              httputils.NewResetClient(endpoint.ConnectionResetInterval, httpClientFactory(timeout, tlsConfig)),
+		destinationsContext: destinationsContext,
+		climit:              make(chan struct{}, maxConcurrentBackgroundSends),
+		backoff:             policy,
+	}
+}
+
+func (d *Destination) Close() {
+}
+
+// Send sends a bulk request,
+// the error returned can be retryable and it is the responsibility of the callee to retry.
+func (d *Destination) Send(payload []byte) error {
+	if d.blockedUntil.After(time.Now()) {
+		d.waitUntil(d.blockedUntil)
+	}
+
+	err := d.unconditionalSend(payload)
+
+	if _, ok := err.(*client.RetryableError); ok {
+		d.nbErrors = d.backoff.IncError(d.nbErrors)
+	} else {
+		d.nbErrors = d.backoff.DecError(d.nbErrors)
+	}
+
+	d.blockedUntil = time.Now().Add(d.backoff.GetBackoffDuration(d.nbErrors))
+
+	return err
+}
+
+// unconditionalSend sends the payload, then sends again the documents rejected with 429
+func (d *Destination) unconditionalSend(payload []byte) error {
+	for retries := 0; ; retries++ {
+		resp, err := d.post(payload)
+		if err != nil || !resp.Errors {
+			return err
+		}
+		payload = d.handleItems(payload, resp.Items)
+		if len(payload) == 0 {
+			return nil
+		}
+		if retries >= d.maxRetries {
+			n := bytes.Count(payload, []byte{'\n'}) / 2
+			droppedDocuments.WithLabelValues("too_many_requests").Add(float64(n))
+			log.Printf("W! %d log documents dropped, still rejected by elasticsearch after %d retries\n", n, d.maxRetries)
+			return nil
+		}
+		if !d.waitUntil(time.Now().Add(d.backoff.GetBackoffDuration(retries + 1))) {
+			return d.destinationsContext.Context().Err()
+		}
+	}
+}
+
+// bulkResponse is the response of the bulk api, the items are in the order of the request
+type bulkResponse struct {
+	Errors bool                  `json:"errors"`
+	Items  []map[string]bulkItem `json:"items"`
+}
+
+type bulkItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// handleItems counts and drops the documents failing for good and returns the actions and
+// documents rejected with 429 to send them again
+func (d *Destination) handleItems(payload []byte, items []map[string]bulkItem) []byte {
+	var retry bytes.Buffer
+	lines := bytes.Split(bytes.TrimSuffix(payload, []byte{'\n'}), []byte{'\n'})
+	for i, response := range items {
+		if 2*i+1 >= len(lines) {
+			break
+		}
+		for _, item := range response {
+			if item.Status < 300 {
+				continue
+			}
+			if item.Status == http.StatusTooManyRequests {
+				retry.Write(lines[2*i])
+				retry.WriteByte('\n')
+				retry.Write(lines[2*i+1])
+				retry.WriteByte('\n')
+				continue
+			}
+			reason, detail := "unknown", ""
+			if item.Error != nil {
+				reason, detail = item.Error.Type, item.Error.Reason
+			}
+			droppedDocuments.WithLabelValues(reason).Inc()
+			d.logSample(item.Status, reason, detail, lines[2*i+1])
+		}
+	}
+	return retry.Bytes()
+}
+
+// logSample logs a rejected document at most every sampleInterval
+func (d *Destination) logSample(status int, reason, detail string, doc []byte) {
+	now := time.Now().UnixNano()
+	last := d.lastSample.Load()
+	if now-last < int64(sampleInterval) || !d.lastSample.CompareAndSwap(last, now) {
+		return
+	}
+	if len(doc) > sampleMaxSize {
+		doc = doc[:sampleMaxSize]
+	}
+	log.Printf("W! log document dropped by elasticsearch. status=%d reason=%s: %s document=%s\n", status, reason, detail, doc)
+}
+
+// post sends the payload to the current host, the next hosts are tried after the network errors
+func (d *Destination) post(payload []byte) (*bulkResponse, error) {
+	ctx := d.destinationsContext.Context()
+
+	body, encoding, err := d.encode(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	current := int(d.current.Load())
+	host := d.hosts[current%len(d.hosts)]
+	req, err := http.NewRequestWithContext(ctx, "POST", host+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "categraf")
+	req.Header.Set("Content-Type", NDJSONContentType)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if d.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+d.apiKey)
+	} else if d.username != "" {
+		req.SetBasicAuth(d.username, d.password)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return nil, ctx.Err()
+		}
+		if len(d.hosts) > 1 {
+			d.current.CompareAndSwap(int32(current), int32((current+1)%len(d.hosts)))
+		}
+		// most likely a network or a connect error, the callee should retry.
+		return nil, client.NewRetryableError(err)
+	}
+
+	defer resp.Body.Close()
+	response, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		log.Printf("W! failed to post elasticsearch bulk request. code=%d host=%s response=%s\n", resp.StatusCode, host, string(response))
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, client.NewRetryableError(errServer)
+	} else if resp.StatusCode >= 400 {
+		// the credentials or the index may be wrong
+		droppedDocuments.WithLabelValues(fmt.Sprintf("http_%d", resp.StatusCode)).Add(float64(bytes.Count(payload, []byte{'\n'}) / 2))
+		return nil, errClient
+	}
+
+	var result bulkResponse
+	if err := json.Unmarshal(response, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode elasticsearch bulk response: %v", err)
+	}
+	return &result, nil
+}
+
+func (d *Destination) encode(payload []byte) ([]byte, string, error) {
+	if !d.useCompression {
+		return payload, "", nil
+	}
+	level := d.compressionLevel
+	if level < gzip.NoCompression || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	var compressed bytes.Buffer
+	w, err := gzip.NewWriterLevel(&compressed, level)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return compressed.Bytes(), "gzip", nil
+}
+
+// SendAsync sends a payload in background.
+func (d *Destination) SendAsync(payload []byte) {
+	d.once.Do(func() {
+		payloadChan := make(chan []byte, coreconfig.ChanSize())
+		d.sendInBackground(payloadChan)
+		d.payloadChan = payloadChan
+	})
+	d.payloadChan <- payload
+}
+
+// sendInBackground sends all payloads from payloadChan in background.
+func (d *Destination) sendInBackground(payloadChan chan []byte) {
+	ctx := d.destinationsContext.Context()
+	go func() {
+		for {
+			select {
+			case payload := <-payloadChan:
+				// if the channel is non-buffered then there is no concurrency and we block on sending each payload
+				if cap(d.climit) == 0 {
+					d.unconditionalSend(payload) //nolint:errcheck
+					break
+				}
+				d.climit <- struct{}{}
+				go func() {
+					d.unconditionalSend(payload) //nolint:errcheck
+					<-d.climit
+				}()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func httpClientFactory(timeout time.Duration, tlsConfig *tls.Config) func() *http.Client {
+	return func() *http.Client {
+		// reusing core agent HTTP transport to benefit from proxy settings.
+		transport := httputils.CreateHTTPTransport()
+		if tlsConfig != nil {
+			transport.TLSClientConfig = tlsConfig
+		}
+		return &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		}
+	}
+}
+
+// buildURLs returns the urls of the comma separated hosts, http or https is added to the
+// hosts without scheme
+func buildURLs(endpoint logsconfig.Endpoint) []string {
+	scheme := "http://"
+	if endpoint.UseSSL {
+		scheme = "https://"
+	}
+	var urls []string
+	for _, host := range strings.Split(endpoint.Addr, ",") {
+		host = strings.TrimSuffix(strings.TrimSpace(host), "/")
+		if host == "" {
+			continue
+		}
+		if !strings.Contains(host, "://") {
+			host = scheme + host
+		}
+		urls = append(urls, host)
+	}
+	if len(urls) == 0 {
+		urls = append(urls, scheme+"127.0.0.1:9200")
+	}
+	return urls
+}
+
+// waitUntil waits until the deadline, it returns false when the destinations are stopped
+func (d *Destination) waitUntil(deadline time.Time) bool {
+	ctx, cancel := context.WithDeadline(d.destinationsContext.Context(), deadline)
+	defer cancel()
+	<-ctx.Done()
+	return d.destinationsContext.Context().Err() == nil
+}
@@ -0,0 +1,112 @@
+//go:build !no_logs
+
+package elasticsearch
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	coreconfig "flashcat.cloud/categraf/config"
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/client"
+	"flashcat.cloud/categraf/logs/message"
+	"flashcat.cloud/categraf/logs/sender"
+)
+
+func TestBulkItems(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Authorization") != "ApiKey a2V5" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Write([]byte(`{"errors": true, "items": [
+				{"create": {"status": 201}},
+				{"create": {"status": 429, "error": {"type": "es_rejected_execution_exception", "reason": "rejected execution"}}},
+				{"create": {"status": 400, "error": {"type": "document_parsing_exception", "reason": "failed to parse field [status]"}}}
+			]}`))
+			return
+		}
+		w.Write([]byte(`{"errors": false, "items": [{"create": {"status": 201}}]}`))
+	}))
+	defer server.Close()
+
+	ctx := client.NewDestinationsContext()
+	ctx.Start()
+	defer ctx.Stop()
+	d := newDestination(logsconfig.Endpoint{
+		Addr:           server.URL,
+		UseCompression: true,
+		BackoffFactor:  2,
+		BackoffBase:    0.01,
+		BackoffMax:     0.01,
+	}, &coreconfig.LogsElasticsearch{APIKey: "a2V5"}, ctx, 0)
+
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var messages []*message.Message
+	for _, doc := range []string{`{"message":"a"}`, `{"message":"b"}`, `{"message":"c"}`} {
+		messages = append(messages, &message.Message{Content: []byte(doc), Timestamp: ts})
+	}
+	payload := sender.NewBulkSerializer("logs-%Y.%m.%d").Serialize(messages)
+
+	before := testutil.ToFloat64(droppedDocuments.WithLabelValues("document_parsing_exception"))
+	if err := d.Send(payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("expected the rejected document to be sent again, got %d requests", len(bodies))
+	}
+	want := `{"create":{"_index":"logs-2024.03.01"}}` + "\n" + `{"message":"b"}` + "\n"
+	if bodies[1] != want {
+		t.Fatalf("got %q, want %q", bodies[1], want)
+	}
+	if n := testutil.ToFloat64(droppedDocuments.WithLabelValues("document_parsing_exception")) - before; n != 1 {
+		t.Fatalf("expected the mapping error to be counted, got %v", n)
+	}
+}
+
+func TestBulkRetriesExhausted(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"errors": true, "items": [{"index": {"status": 429}}]}`))
+	}))
+	defer server.Close()
+
+	ctx := client.NewDestinationsContext()
+	ctx.Start()
+	defer ctx.Stop()
+	d := newDestination(logsconfig.Endpoint{
+		Addr:          strings.TrimPrefix(server.URL, "http://"),
+		BackoffFactor: 2,
+		BackoffBase:   0.01,
+		BackoffMax:    0.01,
+	}, &coreconfig.LogsElasticsearch{MaxRetries: 2}, ctx, 0)
+
+	before := testutil.ToFloat64(droppedDocuments.WithLabelValues("too_many_requests"))
+	payload := []byte(`{"create":{"_index":"logs"}}` + "\n" + `{"message":"a"}` + "\n")
+	if err := d.Send(payload); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Fatalf("expected 1 request and 2 retries, got %d requests", requests)
+	}
+	if n := testutil.ToFloat64(droppedDocuments.WithLabelValues("too_many_requests")) - before; n != 1 {
+		t.Fatalf("expected the document to be dropped, got %v", n)
+	}
+}
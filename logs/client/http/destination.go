@@ -5,15 +5,19 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	coreconfig "flashcat.cloud/categraf/config"
 	logsconfig "flashcat.cloud/categraf/config/logs"
 	"flashcat.cloud/categraf/logs/client"
@@ -36,6 +40,15 @@ var (
 // emptyPayload is an empty payload used to check HTTP connectivity without sending logs.
 var emptyPayload []byte
 
+var responsesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "logs_http_responses_total",
+	Help: "Number of responses to the log batches posted over HTTP, by status code, error for the network errors.",
+}, []string{"code"})
+
+func init() {
+	prometheus.MustRegister(responsesTotal)
+}
+
 // Destination sends a payload over HTTP.
 type Destination struct {
 	url                 string
@@ -43,6 +56,8 @@ type Destination struct {
 	contentType         string
 	host                string
 	contentEncoding     ContentEncoding
+	compressionMinBytes int
+	headers             map[string]string
 	client              *httputils.ResetClient
 	destinationsContext *client.DestinationsContext
 	once                sync.Once
@@ -60,14 +75,27 @@ type Destination struct {
 // there is no concurrency and the background sending pipeline will block while sending each payload.
 // TODO: add support for SOCKS5
 func NewDestination(endpoint logsconfig.Endpoint, contentType string, destinationsContext *client.DestinationsContext, maxConcurrentBackgroundSends int) *Destination {
-	return newDestination(endpoint, contentType, destinationsContext, time.Second*10, maxConcurrentBackgroundSends)
+	return newDestination(endpoint, httpConfig(), contentType, destinationsContext, time.Second*10, maxConcurrentBackgroundSends)
+}
+
+// httpConfig returns the [logs.http] settings
+func httpConfig() *coreconfig.LogsHTTP {
+	if coreconfig.Config == nil || coreconfig.Config.Logs.HTTP == nil {
+		return &coreconfig.LogsHTTP{}
+	}
+	return coreconfig.Config.Logs.HTTP
 }
 
-func newDestination(endpoint logsconfig.Endpoint, contentType string, destinationsContext *client.DestinationsContext, timeout time.Duration, maxConcurrentBackgroundSends int) *Destination {
+func newDestination(endpoint logsconfig.Endpoint, cfg *coreconfig.LogsHTTP, contentType string, destinationsContext *client.DestinationsContext, timeout time.Duration, maxConcurrentBackgroundSends int) *Destination {
 	if maxConcurrentBackgroundSends < 0 {
 		maxConcurrentBackgroundSends = 0
 	}
 
+	tlsConfig, err := cfg.ClientConfig.TLSConfig()
+	if err != nil {
+		log.Println("E! failed to init tls config of the http logs destination:", err)
+	}
+
 	policy := backoff.NewPolicy(
 		endpoint.BackoffFactor,
 		endpoint.BackoffBase,
@@ -82,7 +110,9 @@ func newDestination(endpoint logsconfig.Endpoint, contentType string, destinatio
 		apiKey:              endpoint.APIKey,
 		contentType:         contentType,
 		contentEncoding:     buildContentEncoding(endpoint),
-		client:              httputils.NewResetClient(endpoint.ConnectionResetInterval, httpClientFactory(timeout)),
+		compressionMinBytes: cfg.CompressionMinBytes,
+		headers:             cfg.Headers,
+		client:              httputils.NewResetClient(endpoint.ConnectionResetInterval, httpClientFactory(timeout, tlsConfig)),
 		destinationsContext: destinationsContext,
 		climit:              make(chan struct{}, maxConcurrentBackgroundSends),
 		backoff:             policy,
@@ -128,7 +158,12 @@ func (d *Destination) Send(payload []byte) error {
 func (d *Destination) unconditionalSend(payload []byte) (err error) {
 	ctx := d.destinationsContext.Context()
 
-	encodedPayload, err := d.contentEncoding.encode(payload)
+	contentEncoding := d.contentEncoding
+	if len(payload) < d.compressionMinBytes {
+		// too small to be worth compressing
+		contentEncoding = IdentityContentType
+	}
+	encodedPayload, err := contentEncoding.encode(payload)
 	if err != nil {
 		return err
 	}
@@ -142,7 +177,7 @@ func (d *Destination) unconditionalSend(payload []byte) (err error) {
 	req.Header.Set("User-Agent", "categraf")
 	req.Header.Set("CATEGRAF-API-KEY", d.apiKey)
 	req.Header.Set("Content-Type", d.contentType)
-	req.Header.Set("Content-Encoding", d.contentEncoding.name())
+	req.Header.Set("Content-Encoding", contentEncoding.name())
 	if d.protocol != "" {
 		req.Header.Set("CATEGRAF-PROTOCOL", string(d.protocol))
 	}
@@ -151,6 +186,9 @@ func (d *Destination) unconditionalSend(payload []byte) (err error) {
 		// TODO agentversion
 		req.Header.Set("CATEGRAF-ORIGIN-VERSION", "0.0.1")
 	}
+	for k, v := range d.headers {
+		req.Header.Set(k, v)
+	}
 	req = req.WithContext(ctx)
 
 	resp, err := d.client.Do(req)
@@ -159,9 +197,11 @@ func (d *Destination) unconditionalSend(payload []byte) (err error) {
 		if ctx.Err() == context.Canceled {
 			return ctx.Err()
 		}
+		responsesTotal.WithLabelValues("error").Inc()
 		// most likely a network or a connect error, the callee should retry.
 		return client.NewRetryableError(err)
 	}
+	responsesTotal.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 
 	defer resp.Body.Close()
 	response, err := ioutil.ReadAll(resp.Body)
@@ -220,12 +260,16 @@ func (d *Destination) sendInBackground(payloadChan chan []byte) {
 	}()
 }
 
-func httpClientFactory(timeout time.Duration) func() *http.Client {
+func httpClientFactory(timeout time.Duration, tlsConfig *tls.Config) func() *http.Client {
 	return func() *http.Client {
+		// reusing core agent HTTP transport to benefit from proxy settings.
+		transport := httputils.CreateHTTPTransport()
+		if tlsConfig != nil {
+			transport.TLSClientConfig = tlsConfig
+		}
 		return &http.Client{
-			Timeout: timeout,
-			// reusing core agent HTTP transport to benefit from proxy settings.
-			Transport: httputils.CreateHTTPTransport(),
+			Timeout:   timeout,
+			Transport: transport,
 		}
 	}
 }
@@ -270,7 +314,7 @@ func CheckConnectivity(endpoint logsconfig.Endpoint) logsconfig.HTTPConnectivity
 	ctx.Start()
 	defer ctx.Stop()
 	// Lower the timeout to 5s because HTTP connectivity test is done synchronously during the agent bootstrap sequence
-	destination := newDestination(endpoint, JSONContentType, ctx, time.Second*5, 0)
+	destination := newDestination(endpoint, httpConfig(), JSONContentType, ctx, time.Second*5, 0)
 	log.Println("I! Sending HTTP connectivity request to", destination.url)
 	err := destination.unconditionalSend(emptyPayload)
 	if err != nil {
@@ -0,0 +1,80 @@
+//go:build !no_logs
+
+package http
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	coreconfig "flashcat.cloud/categraf/config"
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/client"
+)
+
+func TestSendHeadersAndCompression(t *testing.T) {
+	type request struct {
+		encoding, orgID, body string
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		requests = append(requests, request{r.Header.Get("Content-Encoding"), r.Header.Get("X-Scope-OrgID"), string(data)})
+		if len(requests) == 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	ctx := client.NewDestinationsContext()
+	ctx.Start()
+	defer ctx.Stop()
+	host, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "http://"), ":")
+	p, _ := strconv.Atoi(port)
+	endpoint := logsconfig.Endpoint{Host: host, Port: p, UseCompression: true, CompressionLevel: 6}
+	d := newDestination(endpoint, &coreconfig.LogsHTTP{
+		CompressionMinBytes: 16,
+		Headers:             map[string]string{"X-Scope-OrgID": "tenant1"},
+	}, JSONContentType, ctx, time.Second, 0)
+
+	throttled := testutil.ToFloat64(responsesTotal.WithLabelValues("429"))
+	if err := d.unconditionalSend([]byte(`["small"]`)); err != nil {
+		t.Fatal(err)
+	}
+	err := d.unconditionalSend([]byte(`["a batch larger than the threshold"]`))
+	if _, ok := err.(*client.RetryableError); !ok {
+		t.Fatalf("expected a retryable error for 429, got %v", err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %v", requests)
+	}
+	if requests[0].encoding != "identity" || requests[0].body != `["small"]` {
+		t.Fatalf("expected the small batch uncompressed, got %v", requests[0])
+	}
+	if requests[1].encoding != "gzip" || requests[1].body != `["a batch larger than the threshold"]` {
+		t.Fatalf("expected the large batch gzipped, got %v", requests[1])
+	}
+	if requests[0].orgID != "tenant1" || requests[1].orgID != "tenant1" {
+		t.Fatalf("expected the headers on every request, got %v", requests)
+	}
+	if n := testutil.ToFloat64(responsesTotal.WithLabelValues("429")) - throttled; n != 1 {
+		t.Fatalf("expected the 429 to be counted, got %v", n)
+	}
+}
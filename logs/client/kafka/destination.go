@@ -115,6 +115,17 @@ func newDestination(endpoint logsconfig.Endpoint, contentType string, destinatio
 		coreconfig.Config.Logs.Producer.CompressionLevel = coreconfig.Config.Logs.CompressionLevel
 	}
 
+	if coreconfig.Config.Logs.FlushMaxMessages > 0 {
+		coreconfig.Config.Logs.Config.Producer.Flush.Messages = coreconfig.Config.Logs.FlushMaxMessages
+		coreconfig.Config.Logs.Config.Producer.Flush.MaxMessages = coreconfig.Config.Logs.FlushMaxMessages
+	}
+	if coreconfig.Config.Logs.FlushMaxBytes > 0 {
+		coreconfig.Config.Logs.Config.Producer.Flush.Bytes = coreconfig.Config.Logs.FlushMaxBytes
+	}
+	if coreconfig.Config.Logs.Linger > 0 {
+		coreconfig.Config.Logs.Config.Producer.Flush.Frequency = time.Duration(coreconfig.Config.Logs.Linger)
+	}
+
 	if coreconfig.BatchConcurrence() > 0 {
 		typ = AsyncProducer
 	}
@@ -155,7 +166,7 @@ func newDestination(endpoint logsconfig.Endpoint, contentType string, destinatio
 	}
 
 	brokers := strings.Split(endpoint.Addr, ",")
-	c, err := New(typ, brokers, coreconfig.Config.Logs.Config)
+	c, err := New(typ, brokers, coreconfig.Config.Logs.Config, policy, coreconfig.BatchMaxSize())
 	if err != nil {
 		panic(err)
 	}
@@ -230,12 +241,20 @@ func (d *Destination) unconditionalSend(payload []byte) (err error) {
 	if data.MsgKey != "" {
 		msgKey = data.MsgKey
 	}
-	err = NewBuilder().WithMessage(msgKey, encodedPayload).WithTopic(topic).Send(d.client)
+	msg, err := NewBuilder().WithMessage(msgKey, encodedPayload).WithTopic(topic).build()
+	if err != nil {
+		log.Printf("E! drop invalid kafka message, topic:%s, error:%s", topic, err)
+		return err
+	}
+	err = d.client.Send(msg)
 	if err != nil {
 		log.Printf("W! send message to kafka error %s, topic:%s", err, topic)
 		if errors.Is(ctx.Err(), context.Canceled) {
 			return ctx.Err()
 		}
+		if !IsRetryable(err) {
+			return err
+		}
 		// most likely a network or a connect error, the callee should retry.
 		return client.NewRetryableError(err)
 	}
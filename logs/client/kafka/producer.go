@@ -1,12 +1,16 @@
 package kafka
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
 
 	"flashcat.cloud/categraf/logs/util"
+	"flashcat.cloud/categraf/pkg/backoff"
 )
 
 const (
@@ -24,6 +28,14 @@ type (
 		asyncProducer sarama.AsyncProducer
 		stop          chan struct{}
 		counter       int64
+
+		// inflight bounds the messages not acknowledged yet, including the ones waiting
+		// for a retry, Send blocks when it is full
+		inflight chan struct{}
+		backoff  backoff.Policy
+		nbErrors int
+		mu       sync.RWMutex
+		closed   bool
 	}
 
 	SyncProducerWrapper struct {
@@ -33,7 +45,9 @@ type (
 	}
 )
 
-func New(typ string, brokers []string, config *sarama.Config) (Producer, error) {
+// New returns a producer of the type typ, the async producer retries the failed messages with
+// the backoff policy, at most maxInflight messages are buffered
+func New(typ string, brokers []string, config *sarama.Config, policy backoff.Policy, maxInflight int) (Producer, error) {
 	stop := make(chan struct{})
 	switch typ {
 	case AsyncProducer:
@@ -41,27 +55,55 @@ func New(typ string, brokers []string, config *sarama.Config) (Producer, error)
 		if err != nil {
 			return nil, err
 		}
+		if maxInflight <= 0 {
+			maxInflight = config.ChannelBufferSize
+		}
 		apw := &AsyncProducerWrapper{
 			asyncProducer: p,
 			stop:          stop,
+			inflight:      make(chan struct{}, maxInflight),
+			backoff:       policy,
 		}
 		go apw.errorWorker()
 		go apw.successWorker()
 		return apw, nil
 	case SyncProducer:
 		p, err := sarama.NewSyncProducer(brokers, config)
-		return &SyncProducerWrapper{syncProducer: p}, err
+		return &SyncProducerWrapper{syncProducer: p, stop: stop}, err
 	default:
 		return nil, fmt.Errorf("unknown producer type: %s", typ)
 	}
 }
 
+// Send blocks while maxInflight messages are not acknowledged, so a kafka outage slows down
+// the pipeline instead of buffering the logs without limit
 func (p *AsyncProducerWrapper) Send(msg *sarama.ProducerMessage) error {
-	p.asyncProducer.Input() <- msg
+	select {
+	case p.inflight <- struct{}{}:
+	case <-p.stop:
+		return errors.New("kafka producer is closed")
+	}
+	if !p.input(msg) {
+		<-p.inflight
+		return errors.New("kafka producer is closed")
+	}
 	return nil
 }
 
+func (p *AsyncProducerWrapper) input(msg *sarama.ProducerMessage) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	p.asyncProducer.Input() <- msg
+	return true
+}
+
 func (p *AsyncProducerWrapper) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
 	close(p.stop)
 	return p.asyncProducer.Close()
 }
@@ -70,20 +112,48 @@ func (p *AsyncProducerWrapper) errorWorker() {
 	for {
 		select {
 		case err := <-p.asyncProducer.Errors():
-			log.Println("E! kafka producer error", err)
+			if !IsRetryable(err.Err) {
+				log.Printf("E! kafka producer error, drop the message of topic %s: %v", err.Msg.Topic, err.Err)
+				<-p.inflight
+				continue
+			}
+			p.mu.Lock()
+			p.nbErrors = p.backoff.IncError(p.nbErrors)
+			delay := p.backoff.GetBackoffDuration(p.nbErrors)
+			p.mu.Unlock()
+			log.Printf("W! kafka producer error, retry the message of topic %s in %s: %v", err.Msg.Topic, delay, err.Err)
+			// the message keeps its inflight slot until it is acknowledged
+			go p.retry(err.Msg, delay)
 		case <-p.stop:
 			return
 		}
 	}
 }
 
+func (p *AsyncProducerWrapper) retry(msg *sarama.ProducerMessage, delay time.Duration) {
+	select {
+	case <-time.After(delay):
+	case <-p.stop:
+		return
+	}
+	retried := &sarama.ProducerMessage{Topic: msg.Topic, Key: msg.Key, Value: msg.Value, Headers: msg.Headers}
+	if !p.input(retried) {
+		log.Printf("W! kafka producer is closed, drop the message of topic %s", msg.Topic)
+	}
+}
+
 func (p *AsyncProducerWrapper) successWorker() {
 	for {
 		select {
 		case <-p.asyncProducer.Successes():
+			<-p.inflight
+			p.mu.Lock()
+			p.nbErrors = p.backoff.DecError(p.nbErrors)
 			p.counter++
+			counter := p.counter
+			p.mu.Unlock()
 			if util.Debug() {
-				log.Printf("D! kafka producer message success, total:%d", p.counter)
+				log.Printf("D! kafka producer message success, total:%d", counter)
 			}
 		case <-p.stop:
 			return
@@ -91,6 +161,21 @@ func (p *AsyncProducerWrapper) successWorker() {
 	}
 }
 
+// IsRetryable returns false for the errors of the messages kafka will never accept, e.g. too
+// large messages, and true for the others, most likely network or broker errors
+func IsRetryable(err error) bool {
+	var kerr sarama.KError
+	if errors.As(err, &kerr) {
+		switch kerr {
+		case sarama.ErrMessageSizeTooLarge, sarama.ErrMessageSetSizeTooLarge, sarama.ErrInvalidMessage,
+			sarama.ErrInvalidRecord, sarama.ErrInvalidTopic:
+			return false
+		}
+	}
+	var cerr sarama.ConfigurationError
+	return !errors.As(err, &cerr)
+}
+
 func (p *SyncProducerWrapper) Send(msg *sarama.ProducerMessage) error {
 	_, _, err := p.syncProducer.SendMessage(msg)
 	if err == nil {
@@ -22,6 +22,9 @@ import (
 // if a line is bigger than this limit, it will be truncated.
 const defaultContentLenLimit = 256 * 1000
 
+// TruncatedTag is added by the tailers to the messages cut off by the multiline aggregation
+const TruncatedTag = "multiline_truncated=true"
+
 // Input represents a chunk of line.
 type Input struct {
 	content []byte
@@ -55,6 +58,8 @@ type Message struct {
 	RawDataLen         int
 	Timestamp          string
 	IngestionTimestamp int64
+	// Truncated is true when the aggregated content exceeded the limit and was cut off
+	Truncated bool
 }
 
 // NewMessage returns a new output.
@@ -104,20 +109,16 @@ func NewDecoderWithEndLineMatcher(source *config.LogSource, parser parser.Parser
 	var lineParser LineParser
 	detectedPattern := &DetectedPattern{}
 
+	if m := source.Config.Multiline; m != nil && m.Regexp() != nil {
+		lh := NewMultiLineHandler(outputChan, m.Regexp(), m.FlushTimeout(), m.Limit())
+		lh.negate = m.Negate
+		registerCountInfo(source, lh)
+		lineHandler = lh
+	}
 	for _, rule := range source.Config.ProcessingRules {
-		if rule.Type == config.MultiLine {
+		if lineHandler == nil && rule.Type == config.MultiLine {
 			lh := NewMultiLineHandler(outputChan, rule.Regex, config.AggregationTimeout(), lineLimit)
-
-			// Since a single source can have multiple file tailers - each with their own decoder instance,
-			// Make sure we keep track of the multiline match count info from all of the decoders so the
-			// status page displays it correctly.
-			if existingInfo, ok := source.GetInfo(lh.countInfo.InfoKey()).(*config.CountInfo); ok {
-				// override the new decoders info to the instance we are already using
-				lh.countInfo = existingInfo
-			} else {
-				// this is the first decoder we have seen for this source - use it's count info
-				source.RegisterInfo(lh.countInfo)
-			}
+			registerCountInfo(source, lh)
 			lineHandler = lh
 		}
 	}
@@ -150,6 +151,20 @@ func NewDecoderWithEndLineMatcher(source *config.LogSource, parser parser.Parser
 	return New(inputChan, outputChan, lineParser, lineLimit, matcher, detectedPattern)
 }
 
+// registerCountInfo shares the multiline match count of the decoders of a source.
+// Since a single source can have multiple file tailers - each with their own decoder instance,
+// Make sure we keep track of the multiline match count info from all of the decoders so the
+// status page displays it correctly.
+func registerCountInfo(source *config.LogSource, lh *MultiLineHandler) {
+	if existingInfo, ok := source.GetInfo(lh.countInfo.InfoKey()).(*config.CountInfo); ok {
+		// override the new decoders info to the instance we are already using
+		lh.countInfo = existingInfo
+	} else {
+		// this is the first decoder we have seen for this source - use it's count info
+		source.RegisterInfo(lh.countInfo)
+	}
+}
+
 func buildAutoMultilineHandlerFromConfig(outputChan chan *Message, lineLimit int, source *config.LogSource, detectedPattern *DetectedPattern) *AutoMultilineHandler {
 	linesToSample := source.Config.AutoMultiLineSampleSize
 	if linesToSample <= 0 {
@@ -226,6 +241,8 @@ func (d *Decoder) run() {
 	for data := range d.InputChan {
 		d.decodeIncomingData(data.content)
 	}
+	// the last line may not be terminated, send it so that nothing is lost on shutdown
+	d.flush()
 	// finish to stop decoder
 	d.lineParser.Stop()
 }
@@ -257,6 +274,19 @@ func (d *Decoder) decodeIncomingData(inBuf []byte) {
 	d.rawDataLen += (j - i)
 }
 
+// flush sends the content left in lineBuffer without end of line
+func (d *Decoder) flush() {
+	if d.lineBuffer.Len() == 0 {
+		return
+	}
+	content := make([]byte, d.lineBuffer.Len())
+	copy(content, d.lineBuffer.Bytes())
+	d.lineBuffer.Reset()
+	d.lineParser.Handle(NewDecodedInput(content, d.rawDataLen))
+	d.rawDataLen = 0
+	atomic.AddInt64(&d.linesDecoded, 1)
+}
+
 // sendLine copies content from lineBuffer which is passed to lineHandler
 func (d *Decoder) sendLine() {
 	// Account for longer-than-1-byte line separator
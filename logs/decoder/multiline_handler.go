@@ -21,10 +21,12 @@ type MultiLineHandler struct {
 	inputChan      chan *Message
 	outputChan     chan *Message
 	newContentRe   *regexp.Regexp
+	negate         bool
 	buffer         *bytes.Buffer
 	flushTimeout   time.Duration
 	lineLimit      int
 	shouldTruncate bool
+	truncated      bool
 	linesLen       int
 	status         string
 	timestamp      string
@@ -108,7 +110,8 @@ func (h *MultiLineHandler) run() {
 // so that the agent restarts tailing from the right place.
 func (h *MultiLineHandler) process(message *Message) {
 
-	if h.newContentRe.Match(message.Content) {
+	// with negate, the lines not matching the pattern start a new message
+	if h.newContentRe.Match(message.Content) != h.negate {
 		h.countInfo.Add(1)
 		// the current line is part of a new message,
 		// send the buffer
@@ -135,6 +138,7 @@ func (h *MultiLineHandler) process(message *Message) {
 		// the new line is just a remainder,
 		// adding the truncated flag at the beginning of the content
 		h.buffer.Write(truncatedFlag)
+		h.truncated = true
 	}
 
 	h.buffer.Write(message.Content)
@@ -143,6 +147,7 @@ func (h *MultiLineHandler) process(message *Message) {
 		// the multiline message is too long, it needs to be cut off and send,
 		// adding the truncated flag the end of the content
 		h.buffer.Write(truncatedFlag)
+		h.truncated = true
 		h.sendBuffer()
 		h.shouldTruncate = true
 	}
@@ -155,6 +160,7 @@ func (h *MultiLineHandler) sendBuffer() {
 		h.buffer.Reset()
 		h.linesLen = 0
 		h.shouldTruncate = false
+		h.truncated = false
 	}()
 
 	data := bytes.TrimSpace(h.buffer.Bytes())
@@ -162,6 +168,8 @@ func (h *MultiLineHandler) sendBuffer() {
 	copy(content, data)
 
 	if len(content) > 0 || h.linesLen > 0 {
-		h.outputChan <- NewMessage(content, h.status, h.linesLen, h.timestamp)
+		msg := NewMessage(content, h.status, h.linesLen, h.timestamp)
+		msg.Truncated = h.truncated
+		h.outputChan <- msg
 	}
 }
@@ -35,7 +35,7 @@ func (l *Launcher) Start() {
 }
 
 func (l *Launcher) startNewTailer(source *logsconfig.LogSource) {
-	outputChan := l.pipelineProvider.NextPipelineChan()
+	outputChan := l.pipelineProvider.PipelineChanFor(source.Name)
 	tailer := NewTailer(source, source.Config.Channel, outputChan)
 	l.tailers = append(l.tailers, tailer)
 	tailer.Start()
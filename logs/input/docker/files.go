@@ -0,0 +1,338 @@
+//go:build !no_logs
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/input/kubernetes"
+	"flashcat.cloud/categraf/logs/restart"
+	"flashcat.cloud/categraf/logs/util"
+	"flashcat.cloud/categraf/logs/util/containers"
+	"flashcat.cloud/categraf/logs/util/kubernetes/kubelet"
+	kube "flashcat.cloud/categraf/pkg/kubernetes"
+	"flashcat.cloud/categraf/pkg/retry"
+)
+
+var (
+	// criLogsPath holds the symlinks named <pod>_<namespace>_<container>-<id>.log created by the kubelet
+	criLogsPath = "/var/log/containers"
+	// jsonLogsPath holds the <id>/<id>-json.log files of the json-file logging driver of docker
+	jsonLogsPath = "/var/lib/docker/containers"
+)
+
+// containerFile is a log file of a container found on the disk
+type containerFile struct {
+	id        string
+	path      string
+	name      string
+	pod       string
+	namespace string
+}
+
+// FilesLauncher tails the log files of the containers without the docker API, the
+// metadata of the containers are resolved from the pod list of the kubelet when it is
+// reachable.
+type FilesLauncher struct {
+	sources    *logsconfig.LogSources
+	filter     *containers.Filter
+	collectAll bool
+
+	// tailed holds the source of each tailed container, skipped the excluded containers
+	tailed  map[string]*logsconfig.LogSource
+	skipped map[string]struct{}
+	// annotations holds the kubernetes.AnnotationConfigKey annotation of the pod of each
+	// tailed container, the source is reloaded when the annotation changes
+	annotations map[string]string
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// FilesAvailable returns true if one of the directories of the container log files exists
+func FilesAvailable() (bool, *retry.Retrier) {
+	for _, dir := range []string{hostPath(criLogsPath), hostPath(jsonLogsPath)} {
+		if _, err := os.Stat(dir); err == nil {
+			log.Println("I! Container log files launcher is available, reading", dir)
+			return true, nil
+		}
+	}
+	log.Printf("W! Container log files launcher is not available: neither %s nor %s exists", criLogsPath, jsonLogsPath)
+	return false, nil
+}
+
+// NewFilesLauncher returns a new launcher of the container log files, nil if the
+// container filter can't be set up
+func NewFilesLauncher(sources *logsconfig.LogSources, collectAll bool) restart.Restartable {
+	filter, err := containers.NewAutodiscoveryFilter(containers.LogsFilter)
+	if err != nil {
+		log.Println("E! invalid container_include/container_exclude, failed to create container log files launcher:", err)
+		return nil
+	}
+	return &FilesLauncher{
+		sources:     sources,
+		filter:      filter,
+		collectAll:  collectAll,
+		tailed:      make(map[string]*logsconfig.LogSource),
+		skipped:     make(map[string]struct{}),
+		annotations: make(map[string]string),
+	}
+}
+
+// Start starts the launcher
+func (l *FilesLauncher) Start() {
+	log.Println("I! Starting container log files launcher")
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.run()
+}
+
+// Stop stops the launcher and removes the sources of the containers
+func (l *FilesLauncher) Stop() {
+	log.Println("I! Stopping container log files launcher")
+	close(l.stop)
+	<-l.done
+	for id, source := range l.tailed {
+		l.sources.RemoveSource(source)
+		delete(l.tailed, id)
+		delete(l.annotations, id)
+	}
+}
+
+func (l *FilesLauncher) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(scanPeriod)
+	defer ticker.Stop()
+	for {
+		l.scan()
+		select {
+		case <-ticker.C:
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// scan adds a file source per new container log file and removes the sources of the
+// deleted files, the file scanner tails the files of the sources
+func (l *FilesLauncher) scan() {
+	files := findContainerFiles()
+
+	var (
+		pods    map[string]podContainer
+		kubeErr error
+	)
+	if len(l.annotations) > 0 {
+		if pods, kubeErr = localPodContainers(); kubeErr != nil && util.Debug() {
+			log.Println("D! container metadata from the kubelet not available:", kubeErr)
+		}
+		// the changed sources are removed here and added again with the new annotation below
+		for id, annotation := range l.annotations {
+			meta, found := pods[id]
+			if !found || meta.pod.Metadata.Annotations[kubernetes.AnnotationConfigKey] == annotation {
+				continue
+			}
+			log.Printf("I! annotation %s of pod %s/%s changed, reloading the source of container %s",
+				kubernetes.AnnotationConfigKey, meta.pod.Metadata.Namespace, meta.pod.Metadata.Name, meta.container.Name)
+			l.sources.RemoveSource(l.tailed[id])
+			delete(l.tailed, id)
+			delete(l.annotations, id)
+		}
+	}
+	for id, file := range files {
+		if _, has := l.tailed[id]; has {
+			continue
+		}
+		if _, has := l.skipped[id]; has {
+			continue
+		}
+		if pods == nil && kubeErr == nil {
+			if pods, kubeErr = localPodContainers(); kubeErr != nil && util.Debug() {
+				log.Println("D! container metadata from the kubelet not available:", kubeErr)
+			}
+		}
+
+		meta, found := pods[id]
+		if !found && kubeErr == nil {
+			// the pod list either lags behind the runtime or excludes the pod, try on next scan
+			continue
+		}
+		source, err := l.getSource(file, meta, found)
+		if err != nil {
+			if util.Debug() {
+				log.Printf("D! skip the log file %s: %v", file.path, err)
+			}
+			l.skipped[id] = struct{}{}
+			continue
+		}
+		l.tailed[id] = source
+		if found {
+			l.annotations[id] = meta.pod.Metadata.Annotations[kubernetes.AnnotationConfigKey]
+		}
+		l.sources.AddSource(source)
+	}
+
+	for id, source := range l.tailed {
+		if _, has := files[id]; !has {
+			l.sources.RemoveSource(source)
+			delete(l.tailed, id)
+			delete(l.annotations, id)
+		}
+	}
+	for id := range l.skipped {
+		if _, has := files[id]; !has {
+			delete(l.skipped, id)
+		}
+	}
+}
+
+// getSource returns the file source of the container, an error if its logs should not be collected
+func (l *FilesLauncher) getSource(file containerFile, meta podContainer, found bool) (*logsconfig.LogSource, error) {
+	name, image, namespace := file.name, "", file.namespace
+	if found {
+		name, image, namespace = meta.container.Name, meta.container.Image, meta.pod.Metadata.Namespace
+		if !l.collectAll && meta.pod.Metadata.Annotations[kubernetes.AnnotationCollectKey] != "true" {
+			return nil, fmt.Errorf("collect_container_all is disabled and %s is not true", kubernetes.AnnotationCollectKey)
+		}
+	} else if !l.collectAll {
+		return nil, errCollectDisabled
+	}
+	if l.filter.IsExcluded(name, image, namespace) {
+		return nil, fmt.Errorf("excluded by container_include/container_exclude")
+	}
+
+	logsSource := dockerIntegration
+	if _, shortName, _, err := containers.SplitImageName(image); err == nil && shortName != "" {
+		logsSource = shortName
+	}
+	tags := []string{
+		fmt.Sprintf("docker.container_id=%s", file.id),
+		fmt.Sprintf("docker.container_name=%s", name),
+		fmt.Sprintf("docker.container_image=%s", image),
+	}
+	pod := file.pod
+	if found {
+		pod = meta.pod.Metadata.Name
+	}
+	if pod != "" {
+		tags = append(tags,
+			fmt.Sprintf("kubernetes.namespace_name=%s", namespace),
+			fmt.Sprintf("kubernetes.pod_name=%s", pod),
+		)
+	}
+	cfg := &logsconfig.LogsConfig{
+		Type:       logsconfig.FileType,
+		Identifier: file.id,
+		Path:       file.path,
+		Source:     logsSource,
+		Service:    logsSource,
+		Tags:       tags,
+	}
+	if found {
+		if v := meta.pod.Metadata.Annotations[kubernetes.AnnotationMultilineKey]; v != "" {
+			if m, err := logsconfig.ParseMultilineConfig(v); err != nil {
+				log.Printf("W! ignore the annotation %s of pod %s: %v", kubernetes.AnnotationMultilineKey, pod, err)
+			} else {
+				cfg.Multiline = m
+			}
+		}
+		kubernetes.ApplyAnnotationConfig(meta.pod, meta.container.Name, cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	sourceName := name
+	if pod != "" {
+		sourceName = fmt.Sprintf("%s/%s/%s", namespace, pod, name)
+	}
+	source := logsconfig.NewLogSource(sourceName, cfg)
+	// the file tailer parses the lines of the docker sources with FileParser
+	source.SetSourceType(logsconfig.DockerSourceType)
+	return source, nil
+}
+
+// findContainerFiles returns the container log files by container id, the files of
+// /var/log/containers take precedence as they also point to the json-file logs of docker
+// on the nodes using dockershim
+func findContainerFiles() map[string]containerFile {
+	files := make(map[string]containerFile)
+
+	paths, _ := filepath.Glob(filepath.Join(hostPath(jsonLogsPath), "*", "*-json.log"))
+	for _, path := range paths {
+		id := filepath.Base(filepath.Dir(path))
+		if filepath.Base(path) != id+"-json.log" {
+			continue
+		}
+		files[id] = containerFile{id: id, path: path, name: id[:min(12, len(id))]}
+	}
+
+	paths, _ = filepath.Glob(filepath.Join(hostPath(criLogsPath), "*.log"))
+	for _, path := range paths {
+		if file, ok := parseCRIFileName(path); ok {
+			files[file.id] = file
+		}
+	}
+	return files
+}
+
+// parseCRIFileName parses the <pod>_<namespace>_<container>-<id>.log name of the files of
+// /var/log/containers, neither the pod, the namespace nor the container name contains '_'
+func parseCRIFileName(path string) (containerFile, bool) {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(path), ".log"), "_")
+	if len(parts) != 3 {
+		return containerFile{}, false
+	}
+	idx := strings.LastIndex(parts[2], "-")
+	if idx <= 0 || idx == len(parts[2])-1 {
+		return containerFile{}, false
+	}
+	return containerFile{
+		id:        parts[2][idx+1:],
+		path:      path,
+		name:      parts[2][:idx],
+		pod:       parts[0],
+		namespace: parts[1],
+	}, true
+}
+
+type podContainer struct {
+	pod       *kube.Pod
+	container kube.ContainerStatus
+}
+
+// localPodContainers returns the containers of the pods of the node by container id
+func localPodContainers() (map[string]podContainer, error) {
+	ku, err := kubelet.GetKubeUtil()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), scanPeriod)
+	defer cancel()
+	pods, err := ku.GetLocalPodList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]podContainer)
+	for _, pod := range pods {
+		for _, c := range pod.Status.GetAllContainers() {
+			if c.ID != "" {
+				result[kubelet.TrimRuntimeFromCID(c.ID)] = podContainer{pod: pod, container: c}
+			}
+		}
+	}
+	return result, nil
+}
+
+func hostPath(path string) string {
+	if v := os.Getenv("HOST_MOUNT_PREFIX"); v != "" && !strings.HasPrefix(path, v) {
+		return filepath.Join(v, path)
+	}
+	return path
+}
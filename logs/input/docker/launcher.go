@@ -0,0 +1,416 @@
+//go:build !no_logs
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	coreconfig "flashcat.cloud/categraf/config"
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/auditor"
+	"flashcat.cloud/categraf/logs/pipeline"
+	"flashcat.cloud/categraf/logs/restart"
+	"flashcat.cloud/categraf/logs/util"
+	"flashcat.cloud/categraf/logs/util/containers"
+	"flashcat.cloud/categraf/logs/util/docker"
+	"flashcat.cloud/categraf/pkg/retry"
+)
+
+const (
+	// LabelEnabledKey set to "false" skips the container, set to "true" collects it
+	// when collect_container_all is disabled
+	LabelEnabledKey = "categraf.logs.enabled"
+	// LabelSourceKey and LabelServiceKey override the source and service of the logs
+	LabelSourceKey  = "categraf.logs.source"
+	LabelServiceKey = "categraf.logs.service"
+	// LabelMultilineKey holds the JSON multiline config of the logs, e.g. {"pattern":"^\\d{4}-"}
+	LabelMultilineKey = "categraf.logs.multiline"
+
+	scanPeriod = 10 * time.Second
+	// drainedRetention is how long a dead container whose logs were read is remembered
+	drainedRetention = time.Hour
+)
+
+// Launcher lists the running containers and starts one tailer per container
+// whose labels and image are not excluded
+type Launcher struct {
+	sources          *logsconfig.LogSources
+	pipelineProvider pipeline.Provider
+	registry         auditor.Registry
+	du               *docker.DockerUtil
+	filter           *docker.ContainerFilter
+	collectAll       bool
+	startedAt        time.Time
+
+	tailers map[string]*Tailer
+	// skipped holds the start time of the excluded containers, they are evaluated again on restart
+	skipped map[string]string
+
+	// the logs of the containers dying before they are tailed are read once by the draining
+	// tailers, up to maxDeadLogBytes, drained remembers the containers read or tailed to their end
+	maxDeadLogBytes int64
+	deadSince       time.Duration
+	draining        map[string]*Tailer
+	drained         map[string]drainedContainer
+	died            chan string
+	stopWatch       context.CancelFunc
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type drainedContainer struct {
+	startedAt string
+	at        time.Time
+}
+
+// IsAvailable returns true if the docker API is reachable and a retrier otherwise
+func IsAvailable() (bool, *retry.Retrier) {
+	du, retrier := docker.GetDockerUtilWithRetrier()
+	if du != nil {
+		log.Println("I! Docker launcher is available")
+		return true, nil
+	}
+	log.Println("W! Docker launcher is not available:", retrier.LastError())
+	return false, retrier
+}
+
+// NewLauncher returns a new launcher, nil if the docker API or the container filter can't be set up
+func NewLauncher(sources *logsconfig.LogSources, pipelineProvider pipeline.Provider, registry auditor.Registry, collectAll bool) restart.Restartable {
+	du, err := docker.GetDockerUtil()
+	if err != nil {
+		log.Println("E! DockerUtil not available, failed to create launcher:", err)
+		return nil
+	}
+	filter, err := docker.NewContainerFilter()
+	if err != nil {
+		log.Println("E! invalid container_include/container_exclude, failed to create docker launcher:", err)
+		return nil
+	}
+	return &Launcher{
+		sources:          sources,
+		pipelineProvider: pipelineProvider,
+		registry:         registry,
+		du:               du,
+		filter:           filter,
+		collectAll:       collectAll,
+		tailers:          make(map[string]*Tailer),
+		skipped:          make(map[string]string),
+		maxDeadLogBytes:  coreconfig.MaxDeadContainerLogBytes(),
+		deadSince:        coreconfig.CollectDeadContainersSince(),
+		draining:         make(map[string]*Tailer),
+		drained:          make(map[string]drainedContainer),
+	}
+}
+
+// Start starts the launcher
+func (l *Launcher) Start() {
+	log.Println("I! Starting docker launcher")
+	l.startedAt = time.Now()
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	var ctx context.Context
+	ctx, l.stopWatch = context.WithCancel(context.Background())
+	if l.maxDeadLogBytes >= 0 {
+		l.died = make(chan string, 16)
+		go l.du.WatchDiedContainers(ctx, l.startedAt, l.died)
+	}
+	go l.run()
+}
+
+// Stop stops the launcher and all the tailers
+func (l *Launcher) Stop() {
+	log.Println("I! Stopping docker launcher")
+	l.stopWatch()
+	close(l.stop)
+	<-l.done
+	stopper := restart.NewParallelStopper()
+	for id, tailer := range l.tailers {
+		stopper.Add(tailer)
+		l.sources.RemoveSource(tailer.source)
+		delete(l.tailers, id)
+	}
+	for id, tailer := range l.draining {
+		stopper.Add(tailer)
+		l.sources.RemoveSource(tailer.source)
+		delete(l.draining, id)
+	}
+	stopper.Stop()
+}
+
+func (l *Launcher) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(scanPeriod)
+	defer ticker.Stop()
+	if l.maxDeadLogBytes >= 0 && l.deadSince > 0 {
+		l.drainExited(l.startedAt.Add(-l.deadSince))
+	}
+	for {
+		l.scan()
+	WAIT:
+		for {
+			select {
+			case <-ticker.C:
+				break WAIT
+			case id := <-l.died:
+				ctx, cancel := context.WithTimeout(context.Background(), scanPeriod)
+				l.drainDead(ctx, id)
+				cancel()
+			case <-l.stop:
+				return
+			}
+		}
+	}
+}
+
+// scan starts the tailers of the new and restarted containers and stops the ones of the
+// removed containers. The labels are read again when a container restarts, so a changed
+// label applies without restarting categraf.
+func (l *Launcher) scan() {
+	ctx, cancel := context.WithTimeout(context.Background(), scanPeriod)
+	defer cancel()
+
+	list, err := l.du.RawContainerList(ctx, l.filter.ListOptions())
+	if err != nil {
+		log.Println("E! failed to list containers:", err)
+		return
+	}
+
+	running := make(map[string]struct{}, len(list))
+	for _, c := range list {
+		running[c.ID] = struct{}{}
+
+		co, err := l.du.Inspect(ctx, c.ID, false)
+		if err != nil {
+			log.Printf("W! failed to inspect container %s: %v", c.ID[:12], err)
+			continue
+		}
+		startedAt := ""
+		if co.State != nil {
+			startedAt = co.State.StartedAt
+		}
+
+		if tailer, has := l.tailers[c.ID]; has {
+			if tailer.StartedAt == startedAt && !tailer.Done() {
+				continue
+			}
+			l.stopTailer(tailer)
+		}
+		if skippedAt, has := l.skipped[c.ID]; has && skippedAt == startedAt {
+			continue
+		}
+		delete(l.skipped, c.ID)
+
+		// the cached inspect may predate the restart
+		if co, err = l.du.InspectNoCache(ctx, c.ID, false); err != nil {
+			log.Printf("W! failed to inspect container %s: %v", c.ID[:12], err)
+			continue
+		}
+		if co.State != nil {
+			startedAt = co.State.StartedAt
+		}
+		source, err := l.getSource(co)
+		if err != nil {
+			if util.Debug() {
+				log.Printf("D! skip the logs of container %s: %v", co.Name, err)
+			}
+			l.skipped[c.ID] = startedAt
+			continue
+		}
+		l.startTailer(co, startedAt, source)
+	}
+
+	for id, tailer := range l.tailers {
+		if _, has := running[id]; !has {
+			// the tailer read the logs up to the end of the stream, they are not read again on die
+			l.drained[id] = drainedContainer{startedAt: tailer.StartedAt, at: time.Now()}
+			l.stopTailer(tailer)
+		}
+	}
+	for id, tailer := range l.draining {
+		if tailer.Done() {
+			tailer.Stop()
+			l.sources.RemoveSource(tailer.source)
+			delete(l.draining, id)
+		}
+	}
+	for id, drained := range l.drained {
+		if time.Since(drained.at) > drainedRetention {
+			delete(l.drained, id)
+		}
+	}
+	for id := range l.skipped {
+		if _, has := running[id]; !has {
+			delete(l.skipped, id)
+		}
+	}
+}
+
+func (l *Launcher) startTailer(co types.ContainerJSON, startedAt string, source *logsconfig.LogSource) {
+	tty := co.Config != nil && co.Config.Tty
+	tailer := NewTailer(l.du, co.ID, startedAt, tty, source, l.pipelineProvider.PipelineChanFor(co.ID))
+	if err := tailer.Start(l.since(tailer, startedAt)); err != nil {
+		log.Printf("E! failed to tail the logs of container %s: %v", co.Name, err)
+		return
+	}
+	l.sources.AddSource(source)
+	l.tailers[co.ID] = tailer
+}
+
+func (l *Launcher) stopTailer(tailer *Tailer) {
+	tailer.Stop()
+	l.sources.RemoveSource(tailer.source)
+	delete(l.tailers, tailer.ContainerID)
+}
+
+// drainExited reads once the logs of the containers which exited after since
+func (l *Launcher) drainExited(since time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), scanPeriod)
+	defer cancel()
+
+	list, err := l.du.RawContainerList(ctx, l.filter.ExitedListOptions())
+	if err != nil {
+		log.Println("E! failed to list the exited containers:", err)
+		return
+	}
+	for _, c := range list {
+		co, err := l.du.Inspect(ctx, c.ID, false)
+		if err != nil || co.State == nil {
+			continue
+		}
+		if finishedAt, err := time.Parse(time.RFC3339Nano, co.State.FinishedAt); err == nil && finishedAt.After(since) {
+			l.drainDead(ctx, c.ID)
+		}
+	}
+}
+
+// drainDead reads once the logs of a dead container which was not tailed up to its end,
+// from its start or after the last committed line, up to max_dead_container_log_bytes
+func (l *Launcher) drainDead(ctx context.Context, id string) {
+	if _, has := l.tailers[id]; has {
+		// the tailer reads the logs up to the end of the stream by itself
+		return
+	}
+	if _, has := l.draining[id]; has {
+		return
+	}
+	co, err := l.du.InspectNoCache(ctx, id, false)
+	if err != nil {
+		log.Printf("W! failed to inspect the dead container %s: %v", id[:12], err)
+		return
+	}
+	if co.State == nil || co.State.Running {
+		// restarted, the scan tails it
+		return
+	}
+	startedAt := co.State.StartedAt
+	if drained, has := l.drained[id]; has && drained.startedAt == startedAt {
+		return
+	}
+	l.drained[id] = drainedContainer{startedAt: startedAt, at: time.Now()}
+
+	source, err := l.getSource(co)
+	if err != nil {
+		if util.Debug() {
+			log.Printf("D! skip the logs of the dead container %s: %v", co.Name, err)
+		}
+		return
+	}
+	tty := co.Config != nil && co.Config.Tty
+	tailer := NewTailer(l.du, co.ID, startedAt, tty, source, l.pipelineProvider.PipelineChanFor(co.ID))
+	since := startedAt
+	if offset := l.registry.GetOffset(tailer.Identifier()); offset != "" {
+		if next := nextSince(offset); next != "" {
+			since = next
+		}
+	}
+	if err := tailer.StartOnce(since, l.maxDeadLogBytes); err != nil {
+		log.Printf("E! failed to read the logs of the dead container %s: %v", co.Name, err)
+		return
+	}
+	log.Printf("I! reading the logs of the dead container %s", co.Name)
+	l.sources.AddSource(source)
+	l.draining[co.ID] = tailer
+}
+
+// since returns the timestamp to read the logs from: after the last committed line,
+// from the start of containers started after categraf, or from now
+func (l *Launcher) since(tailer *Tailer, startedAt string) string {
+	if offset := l.registry.GetOffset(tailer.Identifier()); offset != "" {
+		if since := nextSince(offset); since != "" {
+			return since
+		}
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, startedAt); err == nil && ts.After(l.startedAt) {
+		return startedAt
+	}
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// dockerIntegration is the fallback source of the logs
+const dockerIntegration = "docker"
+
+var errCollectDisabled = fmt.Errorf("collect_container_all is disabled and %s is not true", LabelEnabledKey)
+
+// getSource returns the source of the container, an error if its logs should not be collected
+func (l *Launcher) getSource(co types.ContainerJSON) (*logsconfig.LogSource, error) {
+	if co.Config == nil {
+		return nil, fmt.Errorf("container %s has no config", co.ID[:12])
+	}
+	labels := co.Config.Labels
+	switch strings.ToLower(labels[LabelEnabledKey]) {
+	case "false":
+		return nil, fmt.Errorf("label %s=false", LabelEnabledKey)
+	case "true":
+	default:
+		if !l.collectAll {
+			return nil, errCollectDisabled
+		}
+	}
+	if l.filter.IsExcluded(co) {
+		return nil, fmt.Errorf("excluded by container_include/container_exclude")
+	}
+
+	name := strings.TrimPrefix(co.Name, "/")
+	logsSource := dockerIntegration
+	if _, shortName, _, err := containers.SplitImageName(co.Config.Image); err == nil && shortName != "" {
+		logsSource = shortName
+	}
+	cfg := &logsconfig.LogsConfig{
+		Type:       logsconfig.DockerType,
+		Identifier: co.ID,
+		Source:     logsSource,
+		Service:    logsSource,
+		Tags: []string{
+			fmt.Sprintf("docker.container_id=%s", co.ID),
+			fmt.Sprintf("docker.container_name=%s", name),
+			fmt.Sprintf("docker.container_image=%s", co.Config.Image),
+		},
+	}
+	if v := labels[LabelSourceKey]; v != "" {
+		cfg.Source = v
+	}
+	if v := labels[LabelServiceKey]; v != "" {
+		cfg.Service = v
+	}
+	if v := labels[LabelMultilineKey]; v != "" {
+		if m, err := logsconfig.ParseMultilineConfig(v); err != nil {
+			log.Printf("W! ignore the label %s of container %s: %v", LabelMultilineKey, name, err)
+		} else {
+			cfg.Multiline = m
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	source := logsconfig.NewLogSource(name, cfg)
+	source.SetSourceType(logsconfig.DockerSourceType)
+	return source, nil
+}
@@ -0,0 +1,99 @@
+//go:build !no_logs
+
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"flashcat.cloud/categraf/logs/input/kubernetes"
+	"flashcat.cloud/categraf/logs/message"
+	logparser "flashcat.cloud/categraf/logs/parser"
+)
+
+// streamParser parses the lines of one stream of the docker logs API called with timestamps,
+// the lines follow the pattern '<timestamp> <content>', e.g.
+// 2023-11-20T08:12:45.123456789Z This is my message
+type streamParser struct {
+	status string
+}
+
+var (
+	stdoutParser = &streamParser{status: message.StatusInfo}
+	stderrParser = &streamParser{status: message.StatusError}
+)
+
+func (p *streamParser) Parse(msg []byte) ([]byte, string, string, bool, error) {
+	idx := bytes.IndexByte(msg, ' ')
+	if idx < 0 {
+		if len(msg) == 0 {
+			return msg, p.status, "", false, nil
+		}
+		return msg, p.status, "", false, errors.New("cannot parse the docker log line")
+	}
+	return msg[idx+1:], p.status, string(msg[:idx]), false, nil
+}
+
+func (p *streamParser) SupportsPartialLine() bool {
+	return false
+}
+
+// JSONParser parses the lines of the json-file logging driver of docker, e.g.
+// {"log":"This is my message\n","stream":"stderr","time":"2023-11-20T08:12:45.123456789Z"}
+// returns "This is my message", "error", "2023-11-20T08:12:45.123456789Z", a line
+// without the trailing newline being partial.
+var JSONParser logparser.Parser = &jsonParser{}
+
+// FileParser parses the container log files, whose lines are either in the json-file
+// format of docker or in the CRI format '<timestamp> <stream> <flag> <content>'
+var FileParser logparser.Parser = &fileParser{}
+
+type jsonLine struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+type jsonParser struct{}
+
+func (p *jsonParser) Parse(data []byte) ([]byte, string, string, bool, error) {
+	var line jsonLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return data, message.StatusInfo, "", false, fmt.Errorf("cannot parse docker message, invalid JSON: %v", err)
+	}
+
+	status := message.StatusInfo
+	if line.Stream == "stderr" {
+		status = message.StatusError
+	}
+
+	content := []byte(line.Log)
+	partial := false
+	if n := len(content); n > 0 {
+		if content[n-1] == '\n' {
+			content = content[:n-1]
+		} else {
+			partial = true
+		}
+	}
+	return content, status, line.Time, partial, nil
+}
+
+func (p *jsonParser) SupportsPartialLine() bool {
+	return true
+}
+
+type fileParser struct{}
+
+func (p *fileParser) Parse(data []byte) ([]byte, string, string, bool, error) {
+	if trimmed := bytes.TrimLeft(data, " \t"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return JSONParser.Parse(data)
+	}
+	return kubernetes.Parser.Parse(data)
+}
+
+func (p *fileParser) SupportsPartialLine() bool {
+	return true
+}
@@ -0,0 +1,227 @@
+//go:build !no_logs
+
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/decoder"
+	"flashcat.cloud/categraf/logs/message"
+	"flashcat.cloud/categraf/logs/util/docker"
+)
+
+const (
+	// stream types of the header of the multiplexed docker logs stream
+	stdinStream  = 0
+	stdoutStream = 1
+	stderrStream = 2
+
+	headerLen = 8
+	readSize  = 32 * 1024
+)
+
+// Tailer reads the logs of one container from the docker API
+type Tailer struct {
+	ContainerID string
+	StartedAt   string
+
+	source     *logsconfig.LogSource
+	outputChan chan *message.Message
+	du         *docker.DockerUtil
+	tty        bool
+	stdout     *decoder.Decoder
+	stderr     *decoder.Decoder
+	// maxBytes bounds the logs read by StartOnce, 0 is unbounded
+	maxBytes int64
+
+	ctx        context.Context
+	cancel     context.CancelFunc
+	readerDone chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewTailer returns a new Tailer, tty is the Tty setting of the container, the logs
+// of a container with a tty are not multiplexed by docker
+func NewTailer(du *docker.DockerUtil, containerID, startedAt string, tty bool, source *logsconfig.LogSource, outputChan chan *message.Message) *Tailer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Tailer{
+		ContainerID: containerID,
+		StartedAt:   startedAt,
+		source:      source,
+		outputChan:  outputChan,
+		du:          du,
+		tty:         tty,
+		stdout:      decoder.InitializeDecoder(source, stdoutParser),
+		stderr:      decoder.InitializeDecoder(source, stderrParser),
+		ctx:         ctx,
+		cancel:      cancel,
+		readerDone:  make(chan struct{}),
+	}
+}
+
+// Identifier returns the identifier of the container in the registry
+func (t *Tailer) Identifier() string {
+	return fmt.Sprintf("%s:%s", logsconfig.DockerType, t.ContainerID)
+}
+
+// Start starts reading the logs written after since, a RFC3339Nano timestamp
+func (t *Tailer) Start(since string) error {
+	return t.start(since, true)
+}
+
+// StartOnce starts reading the logs written after since up to the end of the logs of the
+// container, or up to maxBytes when it's positive, e.g. for a container which already exited
+func (t *Tailer) StartOnce(since string, maxBytes int64) error {
+	t.maxBytes = maxBytes
+	return t.start(since, false)
+}
+
+func (t *Tailer) start(since string, follow bool) error {
+	reader, err := t.du.ContainerLogs(t.ctx, t.ContainerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+		Timestamps: true,
+		Since:      since,
+	})
+	if err != nil {
+		t.source.Status.Error(err)
+		return err
+	}
+	t.source.Status.Success()
+	t.source.AddInput(t.ContainerID)
+
+	t.stdout.Start()
+	t.stderr.Start()
+	t.wg.Add(2)
+	go t.forwardMessages(t.stdout)
+	go t.forwardMessages(t.stderr)
+	go t.readForever(reader)
+	return nil
+}
+
+// Stop stops the tailer and waits for the decoders to be flushed
+func (t *Tailer) Stop() {
+	t.cancel()
+	<-t.readerDone
+	t.wg.Wait()
+	t.source.RemoveInput(t.ContainerID)
+}
+
+// Done reports whether the log stream is closed, e.g. because the container stopped
+func (t *Tailer) Done() bool {
+	select {
+	case <-t.readerDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// readForever demultiplexes the stdout and stderr streams until the stream is closed
+func (t *Tailer) readForever(reader io.ReadCloser) {
+	defer func() {
+		reader.Close()
+		t.stdout.Stop()
+		t.stderr.Stop()
+		close(t.readerDone)
+	}()
+
+	var read int64
+	if t.tty {
+		for {
+			buf := make([]byte, readSize)
+			n, err := reader.Read(buf)
+			if n > 0 {
+				read += int64(n)
+				t.source.BytesRead.Add(int64(n))
+				t.stdout.InputChan <- decoder.NewInput(buf[:n])
+			}
+			if err != nil {
+				t.logReadError(err)
+				return
+			}
+			if t.overLimit(read) {
+				return
+			}
+		}
+	}
+
+	header := make([]byte, headerLen)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			t.logReadError(err)
+			return
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			t.logReadError(err)
+			return
+		}
+		read += int64(len(frame))
+		t.source.BytesRead.Add(int64(len(frame)))
+		switch header[0] {
+		case stdoutStream, stdinStream:
+			t.stdout.InputChan <- decoder.NewInput(frame)
+		case stderrStream:
+			t.stderr.InputChan <- decoder.NewInput(frame)
+		}
+		if t.overLimit(read) {
+			return
+		}
+	}
+}
+
+// overLimit reports whether read reached the bytes limit of StartOnce
+func (t *Tailer) overLimit(read int64) bool {
+	if t.maxBytes <= 0 || read < t.maxBytes {
+		return false
+	}
+	log.Printf("W! stop reading the logs of container %s after %d bytes, the limit is reached", t.ContainerID[:12], read)
+	return true
+}
+
+func (t *Tailer) logReadError(err error) {
+	if err == io.EOF || t.ctx.Err() != nil {
+		return
+	}
+	log.Printf("W! stop reading the logs of container %s: %v", t.ContainerID[:12], err)
+	t.source.Status.Error(err)
+}
+
+// forwardMessages sends the decoded lines to the output channel, the offset is the
+// timestamp of the line so that the tailer of a restarted categraf resumes after it
+func (t *Tailer) forwardMessages(d *decoder.Decoder) {
+	defer t.wg.Done()
+	for output := range d.OutputChan {
+		if len(output.Content) == 0 {
+			continue
+		}
+		origin := message.NewOrigin(t.source)
+		origin.Identifier = t.Identifier()
+		origin.Offset = output.Timestamp
+		if output.Truncated {
+			origin.SetTags([]string{decoder.TruncatedTag})
+		}
+		t.outputChan <- message.NewMessage(output.Content, origin, output.Status, output.IngestionTimestamp)
+	}
+}
+
+// nextSince returns the timestamp following offset, the docker API includes the logs
+// written at since
+func nextSince(offset string) string {
+	ts, err := time.Parse(time.RFC3339Nano, offset)
+	if err != nil {
+		return ""
+	}
+	return ts.Add(time.Nanosecond).Format(time.RFC3339Nano)
+}
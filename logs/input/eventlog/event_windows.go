@@ -0,0 +1,152 @@
+//go:build !no_logs && windows
+
+package eventlog
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strconv"
+	"strings"
+
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// event is the xml rendering of an event, see the event schema
+// https://learn.microsoft.com/en-us/windows/win32/wes/eventschema-schema
+type event struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+		} `xml:"Provider"`
+		EventID     int    `xml:"EventID"`
+		Level       int    `xml:"Level"`
+		Task        int    `xml:"Task"`
+		Opcode      int    `xml:"Opcode"`
+		Keywords    string `xml:"Keywords"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID uint64 `xml:"EventRecordID"`
+		Channel       string `xml:"Channel"`
+		Computer      string `xml:"Computer"`
+		Security      struct {
+			UserID string `xml:"UserID,attr"`
+		} `xml:"Security"`
+	} `xml:"System"`
+	EventData struct {
+		Data []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"EventData"`
+	UserData struct {
+		InnerXML string `xml:",innerxml"`
+	} `xml:"UserData"`
+}
+
+func parseEvent(raw string) (*event, error) {
+	e := &event{}
+	if err := xml.Unmarshal([]byte(raw), e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// levelStatuses maps the standard levels of the events to the statuses of the messages,
+// the events of level 0 (LogAlways) and of the levels defined by the providers are info
+var levelStatuses = map[int]string{
+	1: message.StatusCritical,
+	2: message.StatusError,
+	3: message.StatusWarning,
+	4: message.StatusInfo,
+	5: message.StatusDebug,
+}
+
+var levelNames = map[int]string{
+	1: "critical",
+	2: "error",
+	3: "warning",
+	4: "information",
+	5: "verbose",
+}
+
+func (e *event) status() string {
+	if status, has := levelStatuses[e.System.Level]; has {
+		return status
+	}
+	return message.StatusInfo
+}
+
+func (e *event) levelName() string {
+	if name, has := levelNames[e.System.Level]; has {
+		return name
+	}
+	return strconv.Itoa(e.System.Level)
+}
+
+// eventData returns the data of the event, the unnamed data are named after their
+// position, e.g. param1
+func (e *event) eventData() map[string]string {
+	if len(e.EventData.Data) == 0 {
+		return nil
+	}
+	data := make(map[string]string, len(e.EventData.Data))
+	for i, d := range e.EventData.Data {
+		name := d.Name
+		if name == "" {
+			name = "param" + strconv.Itoa(i+1)
+		}
+		data[name] = d.Value
+	}
+	return data
+}
+
+// content returns the event as json, bundling the fields of the event in an "eventlog"
+// attribute like the journald tailer does with the journal fields, e.g.
+//
+//	{
+//	  "message": "The Windows Update service entered the running state.",
+//	  "eventlog": {"provider": "Service Control Manager", "event_id": 7036, "level": "information", ...}
+//	}
+func (e *event) content(msg string) []byte {
+	fields := map[string]interface{}{
+		"provider":     e.System.Provider.Name,
+		"event_id":     e.System.EventID,
+		"level":        e.levelName(),
+		"task":         e.System.Task,
+		"opcode":       e.System.Opcode,
+		"keywords":     e.System.Keywords,
+		"time_created": e.System.TimeCreated.SystemTime,
+		"record_id":    e.System.EventRecordID,
+		"channel":      e.System.Channel,
+		"computer":     e.System.Computer,
+	}
+	if e.System.Security.UserID != "" {
+		fields["user_id"] = e.System.Security.UserID
+	}
+	if data := e.eventData(); data != nil {
+		fields["event_data"] = data
+	}
+	if userData := strings.TrimSpace(e.UserData.InnerXML); userData != "" {
+		fields["user_data"] = userData
+	}
+	payload := map[string]interface{}{
+		"message":  msg,
+		"eventlog": fields,
+	}
+	content, err := json.Marshal(payload)
+	if err != nil {
+		// ensure the message has some content if the json encoding failed
+		return []byte(msg)
+	}
+	return content
+}
+
+func (e *event) tags() []string {
+	return []string{
+		"channel=" + e.System.Channel,
+		"provider=" + e.System.Provider.Name,
+		"event_id=" + strconv.Itoa(e.System.EventID),
+		"level=" + e.levelName(),
+	}
+}
@@ -0,0 +1,52 @@
+//go:build !no_logs && !windows
+
+package eventlog
+
+import (
+	"errors"
+	"log"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/auditor"
+	"flashcat.cloud/categraf/logs/pipeline"
+)
+
+var errNotSupported = errors.New("eventlog sources are only supported on windows")
+
+// Launcher is not supported on the other systems, it marks the eventlog sources as failed.
+type Launcher struct {
+	sources chan *logsconfig.LogSource
+	stop    chan struct{}
+}
+
+// NewLauncher returns a new Launcher
+func NewLauncher(sources *logsconfig.LogSources, pipelineProvider pipeline.Provider, registry auditor.Registry) *Launcher {
+	return &Launcher{
+		sources: sources.GetAddedForType(logsconfig.EventLogType),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start starts reporting the eventlog sources as not supported
+func (l *Launcher) Start() {
+	go func() {
+		logged := false
+		for {
+			select {
+			case source := <-l.sources:
+				if !logged {
+					log.Println("W!", errNotSupported)
+					logged = true
+				}
+				source.Status.Error(errNotSupported)
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the launcher
+func (l *Launcher) Stop() {
+	l.stop <- struct{}{}
+}
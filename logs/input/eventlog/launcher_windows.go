@@ -0,0 +1,77 @@
+//go:build !no_logs && windows
+
+package eventlog
+
+import (
+	"log"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/auditor"
+	"flashcat.cloud/categraf/logs/pipeline"
+	"flashcat.cloud/categraf/logs/restart"
+)
+
+// Launcher starts a tailer per channel and query of the eventlog sources
+type Launcher struct {
+	sources          chan *logsconfig.LogSource
+	pipelineProvider pipeline.Provider
+	registry         auditor.Registry
+	tailers          map[string]*Tailer
+	// failed records the channels failing to start, e.g. missing or denied, so that
+	// their error is logged once instead of at every reload
+	failed map[string]bool
+	stop   chan struct{}
+}
+
+// NewLauncher returns a new Launcher
+func NewLauncher(sources *logsconfig.LogSources, pipelineProvider pipeline.Provider, registry auditor.Registry) *Launcher {
+	return &Launcher{
+		sources:          sources.GetAddedForType(logsconfig.EventLogType),
+		pipelineProvider: pipelineProvider,
+		registry:         registry,
+		tailers:          make(map[string]*Tailer),
+		failed:           make(map[string]bool),
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start starts the launcher
+func (l *Launcher) Start() {
+	go l.run()
+}
+
+func (l *Launcher) run() {
+	for {
+		select {
+		case source := <-l.sources:
+			tailer := NewTailer(source, l.pipelineProvider.PipelineChanFor(source.Name))
+			identifier := tailer.Identifier()
+			if _, exists := l.tailers[identifier]; exists {
+				// set up only one tailer per channel and query
+				continue
+			}
+			if err := tailer.Start(l.registry.GetOffset(identifier)); err != nil {
+				if !l.failed[identifier] {
+					log.Println("E!", err)
+					l.failed[identifier] = true
+				}
+				continue
+			}
+			delete(l.failed, identifier)
+			l.tailers[identifier] = tailer
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Stop stops all active tailers
+func (l *Launcher) Stop() {
+	l.stop <- struct{}{}
+	stopper := restart.NewParallelStopper()
+	for identifier, tailer := range l.tailers {
+		stopper.Add(tailer)
+		delete(l.tailers, identifier)
+	}
+	stopper.Stop()
+}
@@ -0,0 +1,274 @@
+//go:build !no_logs && windows
+
+package eventlog
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+const (
+	// eventLogIntegration is the prefix of the identifiers the bookmarks are stored with
+	eventLogIntegration = "eventlog"
+	// batchSize is the number of events read per EvtNext
+	batchSize = 64
+	// waitTimeout is the delay in milliseconds before checking again for new events or the stop
+	waitTimeout = 1000
+)
+
+var (
+	errChannelNotFound = errors.New("event log channel does not exist")
+	errAccessDenied    = errors.New("access to the event log channel is denied")
+)
+
+// Tailer subscribes to a channel of the event log, the bookmark of the last event sent is
+// the offset of the messages so that the events are not read again after a restart
+type Tailer struct {
+	source     *logsconfig.LogSource
+	outputChan chan *message.Message
+	channel    string
+	query      string
+
+	signal       windows.Handle
+	subscription evtHandle
+	bookmark     evtHandle
+	// publisher metadata by provider, to format the messages
+	publishers map[string]evtHandle
+	renderBuf  []uint16
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewTailer returns a new tailer
+func NewTailer(source *logsconfig.LogSource, outputChan chan *message.Message) *Tailer {
+	return &Tailer{
+		source:     source,
+		outputChan: outputChan,
+		channel:    source.Config.EventLogChannelName(),
+		query:      source.Config.Query,
+		publishers: make(map[string]evtHandle),
+		stop:       make(chan struct{}, 1),
+		done:       make(chan struct{}, 1),
+	}
+}
+
+// Identifier returns the identifier of the channel and query tailed
+func (t *Tailer) Identifier() string {
+	if t.query == "" {
+		return eventLogIntegration + ":" + t.channel
+	}
+	return eventLogIntegration + ":" + t.channel + ":" + t.query
+}
+
+// Start subscribes to the channel after the bookmark, or from the start_position of the
+// source when there's no bookmark
+func (t *Tailer) Start(bookmark string) error {
+	if err := t.subscribe(bookmark); err != nil {
+		t.close()
+		t.source.Status.Error(err)
+		return err
+	}
+	t.source.Status.Success()
+	t.source.AddInput(t.channel)
+	log.Println("I! start tailing event log channel", t.channel)
+	go t.tail()
+	return nil
+}
+
+// Stop stops the tailer
+func (t *Tailer) Stop() {
+	log.Println("I! stop tailing event log channel", t.channel)
+	t.stop <- struct{}{}
+	t.source.RemoveInput(t.channel)
+	<-t.done
+}
+
+func (t *Tailer) subscribe(bookmark string) error {
+	var err error
+	if t.signal == 0 {
+		// manual reset, reset once all the available events are read
+		if t.signal, err = windows.CreateEvent(nil, 1, 1, nil); err != nil {
+			return fmt.Errorf("could not create the signal of event log channel %s: %v", t.channel, err)
+		}
+	}
+
+	flags := uint32(evtSubscribeToFutureEvents)
+	if t.source.Config.TailingMode == "beginning" {
+		flags = evtSubscribeStartAtOldestRecord
+	}
+	if bookmark != "" {
+		if t.bookmark, err = evtCreateBookmark(bookmark); err != nil {
+			log.Printf("W! invalid bookmark of event log channel %s, ignored: %v", t.channel, err)
+		} else {
+			flags = evtSubscribeStartAfterBookmark
+		}
+	}
+	if t.bookmark == 0 {
+		if t.bookmark, err = evtCreateBookmark(""); err != nil {
+			return fmt.Errorf("could not create the bookmark of event log channel %s: %v", t.channel, err)
+		}
+	}
+
+	after := evtHandle(0)
+	if flags == evtSubscribeStartAfterBookmark {
+		after = t.bookmark
+	}
+	t.subscription, err = evtSubscribe(t.signal, t.channel, t.query, after, flags)
+	if err != nil {
+		return t.describe(err)
+	}
+	return nil
+}
+
+// resubscribe subscribes again after the last event sent, once the subscription is stale,
+// e.g. the channel was cleared
+func (t *Tailer) resubscribe() error {
+	bookmark, buf, err := evtRender(t.bookmark, evtRenderBookmark, t.renderBuf)
+	t.renderBuf = buf
+	if err != nil {
+		bookmark = ""
+	}
+	evtClose(t.subscription)
+	evtClose(t.bookmark)
+	t.subscription, t.bookmark = 0, 0
+	return t.subscribe(bookmark)
+}
+
+// describe returns the error of the channel, with the remedy of the common ones
+func (t *Tailer) describe(err error) error {
+	switch err {
+	case errorEvtChannelNotFound:
+		return fmt.Errorf("%w: %s, check the channel name with wevtutil el", errChannelNotFound, t.channel)
+	case windows.ERROR_ACCESS_DENIED:
+		return fmt.Errorf("%w: %s, run categraf as LocalSystem or as a member of the Event Log Readers group", errAccessDenied, t.channel)
+	case errorEvtInvalidQuery:
+		return fmt.Errorf("invalid query of event log channel %s: %s", t.channel, t.query)
+	}
+	return fmt.Errorf("could not read event log channel %s: %v", t.channel, err)
+}
+
+func (t *Tailer) tail() {
+	defer func() {
+		t.close()
+		t.done <- struct{}{}
+	}()
+	events := make([]evtHandle, batchSize)
+	for {
+		select {
+		case <-t.stop:
+			return
+		default:
+		}
+
+		n, err := evtNext(t.subscription, events)
+		switch err {
+		case nil:
+		case errorNoMoreItems:
+			windows.ResetEvent(t.signal)
+			windows.WaitForSingleObject(t.signal, waitTimeout)
+			continue
+		case errorEvtQueryResultStale:
+			log.Printf("W! subscription of event log channel %s is stale, subscribe again", t.channel)
+			if err := t.resubscribe(); err != nil {
+				t.source.Status.Error(err)
+				log.Println("E!", err)
+				return
+			}
+			continue
+		default:
+			err = t.describe(err)
+			t.source.Status.Error(err)
+			log.Println("E!", err)
+			return
+		}
+
+		for _, h := range events[:n] {
+			t.send(h)
+			evtClose(h)
+		}
+	}
+}
+
+// send renders the event and sends its message with the bookmark of the event as offset
+func (t *Tailer) send(h evtHandle) {
+	raw, buf, err := evtRender(h, evtRenderEventXml, t.renderBuf)
+	t.renderBuf = buf
+	if err != nil {
+		log.Printf("E! could not render event of channel %s: %v", t.channel, err)
+		return
+	}
+	e, err := parseEvent(raw)
+	if err != nil {
+		log.Printf("E! could not parse event of channel %s: %v", t.channel, err)
+		return
+	}
+
+	msg := t.formatMessage(e.System.Provider.Name, h)
+	if msg == "" {
+		// the provider is not installed on this host or has no message for the event
+		values := make([]string, 0, len(e.EventData.Data))
+		for _, d := range e.EventData.Data {
+			values = append(values, d.Value)
+		}
+		msg = strings.Join(values, " ")
+	}
+
+	origin := message.NewOrigin(t.source)
+	origin.Identifier = t.Identifier()
+	if err := evtUpdateBookmark(t.bookmark, h); err != nil {
+		log.Printf("W! could not update the bookmark of event log channel %s: %v", t.channel, err)
+	} else if bookmark, buf, err := evtRender(t.bookmark, evtRenderBookmark, t.renderBuf); err == nil {
+		origin.Offset = bookmark
+		t.renderBuf = buf
+	}
+	// set the service and the source attributes of the message,
+	// those values are still overridden by the integration logsconfig when defined
+	origin.SetSource(e.System.Provider.Name)
+	origin.SetService(e.System.Provider.Name)
+	origin.SetTags(e.tags())
+
+	content := e.content(msg)
+	t.source.BytesRead.Add(int64(len(content)))
+	t.outputChan <- message.NewMessage(content, origin, e.status(), time.Now().UnixNano())
+}
+
+func (t *Tailer) formatMessage(provider string, h evtHandle) string {
+	metadata, has := t.publishers[provider]
+	if !has {
+		var err error
+		if metadata, err = evtOpenPublisherMetadata(provider); err != nil {
+			log.Printf("D! could not open the metadata of event provider %s: %v", provider, err)
+		}
+		t.publishers[provider] = metadata
+	}
+	if metadata == 0 {
+		return ""
+	}
+	msg, err := evtFormatMessage(metadata, h)
+	if err != nil && err != errorEvtMessageNotFound && err != errorEvtMessageIdNotFound {
+		log.Printf("D! could not format the message of event provider %s: %v", provider, err)
+	}
+	return strings.TrimSpace(msg)
+}
+
+func (t *Tailer) close() {
+	evtClose(t.subscription)
+	evtClose(t.bookmark)
+	for provider, metadata := range t.publishers {
+		evtClose(metadata)
+		delete(t.publishers, provider)
+	}
+	if t.signal != 0 {
+		windows.CloseHandle(t.signal)
+	}
+	t.subscription, t.bookmark, t.signal = 0, 0, 0
+}
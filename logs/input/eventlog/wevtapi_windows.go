@@ -0,0 +1,172 @@
+//go:build !no_logs && windows
+
+package eventlog
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// evtHandle is an EVT_HANDLE of the wevtapi
+type evtHandle uintptr
+
+// flags and errors of the wevtapi, see winevt.h and winerror.h
+const (
+	evtSubscribeToFutureEvents      = 1
+	evtSubscribeStartAtOldestRecord = 2
+	evtSubscribeStartAfterBookmark  = 3
+	evtSubscribeStrict              = 0x10000
+
+	evtRenderEventXml = 1
+	evtRenderBookmark = 2
+
+	evtFormatMessageEvent = 1
+
+	errorInsufficientBuffer   syscall.Errno = 122
+	errorNoMoreItems          syscall.Errno = 259
+	errorEvtInvalidQuery      syscall.Errno = 15001
+	errorEvtChannelNotFound   syscall.Errno = 15007
+	errorEvtQueryResultStale  syscall.Errno = 15011
+	errorEvtMessageNotFound   syscall.Errno = 15027
+	errorEvtMessageIdNotFound syscall.Errno = 15028
+)
+
+var (
+	modwevtapi = windows.NewLazySystemDLL("wevtapi.dll")
+
+	procEvtSubscribe             = modwevtapi.NewProc("EvtSubscribe")
+	procEvtNext                  = modwevtapi.NewProc("EvtNext")
+	procEvtRender                = modwevtapi.NewProc("EvtRender")
+	procEvtClose                 = modwevtapi.NewProc("EvtClose")
+	procEvtCreateBookmark        = modwevtapi.NewProc("EvtCreateBookmark")
+	procEvtUpdateBookmark        = modwevtapi.NewProc("EvtUpdateBookmark")
+	procEvtOpenPublisherMetadata = modwevtapi.NewProc("EvtOpenPublisherMetadata")
+	procEvtFormatMessage         = modwevtapi.NewProc("EvtFormatMessage")
+)
+
+// callErr returns the error of a failed call, the last error is not always set
+func callErr(err error) error {
+	var errno syscall.Errno
+	if errors.As(err, &errno) && errno != 0 {
+		return errno
+	}
+	return syscall.EINVAL
+}
+
+func evtSubscribe(signal windows.Handle, channel, query string, bookmark evtHandle, flags uint32) (evtHandle, error) {
+	channelPtr, err := windows.UTF16PtrFromString(channel)
+	if err != nil {
+		return 0, err
+	}
+	var queryPtr *uint16
+	if query != "" {
+		if queryPtr, err = windows.UTF16PtrFromString(query); err != nil {
+			return 0, err
+		}
+	}
+	r, _, err := procEvtSubscribe.Call(0, uintptr(signal),
+		uintptr(unsafe.Pointer(channelPtr)), uintptr(unsafe.Pointer(queryPtr)),
+		uintptr(bookmark), 0, 0, uintptr(flags))
+	if r == 0 {
+		return 0, callErr(err)
+	}
+	return evtHandle(r), nil
+}
+
+// evtNext returns the next events of the subscription, errorNoMoreItems when none is available
+func evtNext(subscription evtHandle, events []evtHandle) (int, error) {
+	var returned uint32
+	r, _, err := procEvtNext.Call(uintptr(subscription), uintptr(len(events)),
+		uintptr(unsafe.Pointer(&events[0])), 0, 0, uintptr(unsafe.Pointer(&returned)))
+	if r == 0 {
+		return 0, callErr(err)
+	}
+	return int(returned), nil
+}
+
+// evtRender renders the event or the bookmark as xml
+func evtRender(fragment evtHandle, flags uint32, buf []uint16) (string, []uint16, error) {
+	for {
+		var used, count uint32
+		var ptr unsafe.Pointer
+		if len(buf) > 0 {
+			ptr = unsafe.Pointer(&buf[0])
+		}
+		r, _, err := procEvtRender.Call(0, uintptr(fragment), uintptr(flags),
+			uintptr(len(buf)*2), uintptr(ptr), uintptr(unsafe.Pointer(&used)), uintptr(unsafe.Pointer(&count)))
+		if r != 0 {
+			return windows.UTF16ToString(buf[:used/2]), buf, nil
+		}
+		if err = callErr(err); err != errorInsufficientBuffer {
+			return "", buf, err
+		}
+		buf = make([]uint16, used/2+1)
+	}
+}
+
+func evtClose(h evtHandle) {
+	if h != 0 {
+		procEvtClose.Call(uintptr(h))
+	}
+}
+
+// evtCreateBookmark creates a bookmark from its xml, an empty bookmark when xml is empty
+func evtCreateBookmark(xml string) (evtHandle, error) {
+	var xmlPtr *uint16
+	if xml != "" {
+		var err error
+		if xmlPtr, err = windows.UTF16PtrFromString(xml); err != nil {
+			return 0, err
+		}
+	}
+	r, _, err := procEvtCreateBookmark.Call(uintptr(unsafe.Pointer(xmlPtr)))
+	if r == 0 {
+		return 0, callErr(err)
+	}
+	return evtHandle(r), nil
+}
+
+func evtUpdateBookmark(bookmark, event evtHandle) error {
+	r, _, err := procEvtUpdateBookmark.Call(uintptr(bookmark), uintptr(event))
+	if r == 0 {
+		return callErr(err)
+	}
+	return nil
+}
+
+func evtOpenPublisherMetadata(provider string) (evtHandle, error) {
+	providerPtr, err := windows.UTF16PtrFromString(provider)
+	if err != nil {
+		return 0, err
+	}
+	r, _, err := procEvtOpenPublisherMetadata.Call(0, uintptr(unsafe.Pointer(providerPtr)), 0, 0, 0)
+	if r == 0 {
+		return 0, callErr(err)
+	}
+	return evtHandle(r), nil
+}
+
+// evtFormatMessage returns the message of the event in the locale of the system
+func evtFormatMessage(metadata, event evtHandle) (string, error) {
+	var used uint32
+	r, _, err := procEvtFormatMessage.Call(uintptr(metadata), uintptr(event), 0, 0, 0,
+		evtFormatMessageEvent, 0, 0, uintptr(unsafe.Pointer(&used)))
+	if r == 0 {
+		if err = callErr(err); err != errorInsufficientBuffer {
+			return "", err
+		}
+	}
+	if used == 0 {
+		return "", nil
+	}
+	buf := make([]uint16, used)
+	r, _, err = procEvtFormatMessage.Call(uintptr(metadata), uintptr(event), 0, 0, 0,
+		evtFormatMessageEvent, uintptr(len(buf)), uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&used)))
+	if r == 0 {
+		return "", callErr(err)
+	}
+	return windows.UTF16ToString(buf), nil
+}
@@ -13,6 +13,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	logsconfig "flashcat.cloud/categraf/config/logs"
 	"flashcat.cloud/categraf/logs/status"
@@ -66,59 +67,73 @@ func NewProvider(filesLimit int) *Provider {
 
 // FilesToTail returns all the Files matching paths in sources,
 // it cannot return more than filesLimit Files.
-// For now, there is no way to prioritize specific Files over others,
-// they are just returned in reverse lexicographical order, see `searchFiles`
+// When more files match, the most recently modified ones are returned, the files of the same
+// modification time being kept in the reverse lexicographical order of `searchFiles`
 func (p *Provider) FilesToTail(sources []*logsconfig.LogSource) []*File {
 	var filesToTail []*File
 	shouldLogErrors := p.shouldLogErrors
 	p.shouldLogErrors = false // Let's log errors on first run only
 
+	matching := make(map[*logsconfig.LogSource]int, len(sources))
 	for i := 0; i < len(sources); i++ {
 		source := sources[i]
-		tailedFileCounter := 0
 		files, err := p.CollectFiles(source)
-		isWildcardPath := logsconfig.ContainsWildcard(source.Config.Path)
 		if err != nil {
 			source.Status.Error(err)
-			if isWildcardPath {
-				source.Messages.AddMessage(source.Config.Path, fmt.Sprintf("%d files tailed out of %d files matching", tailedFileCounter, len(files)))
+			if logsconfig.ContainsWildcard(source.Config.Path) {
+				source.Messages.AddMessage(source.Config.Path, fmt.Sprintf("%d files tailed out of %d files matching", 0, len(files)))
 			}
 			if shouldLogErrors {
 				log.Println("W! Could not collect files:", err)
 			}
 			continue
 		}
-		for j := 0; j < len(files) && len(filesToTail) < p.filesLimit; j++ {
-			file := files[j]
-			filesToTail = append(filesToTail, file)
-			tailedFileCounter++
-		}
-
-		if len(filesToTail) >= p.filesLimit {
-			status.AddGlobalWarning(
-				openFilesLimitWarningType,
-				fmt.Sprintf(
-					"The limit on the maximum number of files in use (%d) has been reached. Increase this limit (thanks to the attribute logs_config.open_files_limit in datadog.yaml) or decrease the number of tailed file.",
-					p.filesLimit,
-				),
-			)
-		} else {
-			status.RemoveGlobalWarning(openFilesLimitWarningType)
-		}
-
-		if isWildcardPath {
-			source.Messages.AddMessage(source.Config.Path, fmt.Sprintf("%d files tailed out of %d files matching", tailedFileCounter, len(files)))
-		}
+		matching[source] = len(files)
+		filesToTail = append(filesToTail, files...)
 	}
 
-	if len(filesToTail) == p.filesLimit {
+	if len(filesToTail) > p.filesLimit {
+		filesToTail = mostRecentlyModified(filesToTail, p.filesLimit)
+		status.AddGlobalWarning(
+			openFilesLimitWarningType,
+			fmt.Sprintf(
+				"The limit on the maximum number of files in use (%d) has been reached. Increase this limit (thanks to the attribute open_files_limit of logs) or decrease the number of tailed file.",
+				p.filesLimit,
+			),
+		)
 		log.Println("W! Reached the limit on the maximum number of files in use: ", p.filesLimit)
-		return filesToTail
+	} else {
+		status.RemoveGlobalWarning(openFilesLimitWarningType)
+	}
+
+	tailed := make(map[*logsconfig.LogSource]int, len(sources))
+	for _, file := range filesToTail {
+		tailed[file.Source]++
+	}
+	for source, count := range matching {
+		if logsconfig.ContainsWildcard(source.Config.Path) {
+			source.Messages.AddMessage(source.Config.Path, fmt.Sprintf("%d files tailed out of %d files matching", tailed[source], count))
+		}
 	}
 
 	return filesToTail
 }
 
+// mostRecentlyModified returns the limit files most recently modified, the files which
+// cannot be stat'ed coming last
+func mostRecentlyModified(files []*File, limit int) []*File {
+	modTimes := make(map[*File]time.Time, len(files))
+	for _, file := range files {
+		if fi, err := os.Stat(file.Path); err == nil {
+			modTimes[file] = fi.ModTime()
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return modTimes[files[i]].After(modTimes[files[j]])
+	})
+	return files[:limit]
+}
+
 // CollectFiles returns all the files matching the source path.
 func (p *Provider) CollectFiles(source *logsconfig.LogSource) ([]*File, error) {
 	path := source.Config.Path
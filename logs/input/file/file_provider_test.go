@@ -0,0 +1,75 @@
+//go:build !no_logs
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+)
+
+// touch creates the file at path modified at mtime
+func touch(t *testing.T, path string, mtime time.Time) {
+	if err := os.WriteFile(path, []byte("line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func paths(files []*File) []string {
+	var p []string
+	for _, file := range files {
+		p = append(p, filepath.Base(file.Path))
+	}
+	return p
+}
+
+func TestMostRecentlyModified(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	touch(t, filepath.Join(dir, "a.log"), now.Add(-3*time.Hour))
+	touch(t, filepath.Join(dir, "b.log"), now.Add(-time.Hour))
+	touch(t, filepath.Join(dir, "c.log"), now.Add(-2*time.Hour))
+	touch(t, filepath.Join(dir, "d.log"), now.Add(-time.Hour))
+
+	var files []*File
+	for _, name := range []string{"gone.log", "d.log", "c.log", "b.log", "a.log"} {
+		files = append(files, NewFile(filepath.Join(dir, name), nil, true))
+	}
+	// the files of the same modification time keep their order, the missing file comes last
+	if got, want := paths(mostRecentlyModified(files, 5)), []string{"d.log", "b.log", "c.log", "a.log", "gone.log"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got, want := paths(mostRecentlyModified(files, 2)), []string{"d.log", "b.log"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFilesToTailLimit(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	// the most recent file is not the last one in the reverse lexicographical order
+	touch(t, filepath.Join(dir, "1.log"), now)
+	touch(t, filepath.Join(dir, "2.log"), now.Add(-2*time.Hour))
+	touch(t, filepath.Join(dir, "3.log"), now.Add(-time.Hour))
+	source := logsconfig.NewLogSource("test", &logsconfig.LogsConfig{Type: logsconfig.FileType, Path: filepath.Join(dir, "*.log")})
+
+	files := NewProvider(2).FilesToTail([]*logsconfig.LogSource{source})
+	if got, want := paths(files), []string{"1.log", "3.log"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if got, want := source.Messages.GetMessages(), []string{"2 files tailed out of 3 files matching"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the messages %v, got %v", want, got)
+	}
+
+	files = NewProvider(5).FilesToTail([]*logsconfig.LogSource{source})
+	if got, want := paths(files), []string{"3.log", "2.log", "1.log"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -119,11 +119,12 @@ func (s *Scanner) cleanup() {
 // and triggeres the required updates.
 // For instance, when a file is logrotated, its tailer will keep tailing the rotated file.
 // The Scanner needs to stop that previous tailer, and start a new one for the new file.
+// The globs of the sources are expanded again on each scan: the tailers of the files which
+// no longer match, were deleted or are no longer among the most recently written files when
+// the tailing limit is reached are stopped first, so that the new files are tailed at once.
 func (s *Scanner) scan() {
 	files := s.fileProvider.FilesToTail(s.activeSources)
-	filesTailed := make(map[string]bool)
-	tailersLen := len(s.tailers)
-
+	filesToTail := make(map[string]bool, len(files))
 	for _, file := range files {
 		// We're using generated key here: in case this file has been found while
 		// scanning files for container, the key will use the format:
@@ -134,6 +135,18 @@ func (s *Scanner) scan() {
 		// It is a hack to let two tailers tail the same file (it's happening
 		// when a tailer for a dead container is still tailing the file, and another
 		// tailer is tailing the file for the new container).
+		filesToTail[file.GetScanKey()] = true
+	}
+
+	for _, tailer := range s.tailers {
+		// stop all tailers which have not been selected
+		if !filesToTail[tailer.file.GetScanKey()] {
+			s.stopTailer(tailer)
+		}
+	}
+
+	tailersLen := len(s.tailers)
+	for _, file := range files {
 		tailerKey := file.GetScanKey()
 		tailer, isTailed := s.tailers[tailerKey]
 		if isTailed && atomic.LoadInt32(&tailer.shouldStop) != 0 {
@@ -145,7 +158,7 @@ func (s *Scanner) scan() {
 			continue
 		}
 
-		if !isTailed && tailersLen < s.tailingLimit {
+		if !isTailed {
 			// create a new tailer tailing from the beginning of the file if no offset has been recorded
 			succeeded := s.startNewTailer(file, logsconfig.Beginning)
 			if !succeeded {
@@ -153,7 +166,6 @@ func (s *Scanner) scan() {
 				continue
 			}
 			tailersLen++
-			filesTailed[tailerKey] = true
 			continue
 		}
 
@@ -162,23 +174,13 @@ func (s *Scanner) scan() {
 			continue
 		}
 		if didRotate {
-			// restart tailer because of file-rotation on file
-			succeeded := s.restartTailerAfterFileRotation(tailer, file)
-			if !succeeded {
+			// restart tailer because of file-rotation on file, the rotated file is read
+			// to the end by the previous tailer
+			if !s.restartTailerAfterFileRotation(tailer, file) {
 				// the setup failed, let's try to tail this file in the next scan
-				continue
+				delete(s.tailers, tailerKey)
 			}
 		}
-
-		filesTailed[tailerKey] = true
-	}
-
-	for _, tailer := range s.tailers {
-		// stop all tailers which have not been selected
-		_, shouldTail := filesTailed[tailer.file.GetScanKey()]
-		if !shouldTail {
-			s.stopTailer(tailer)
-		}
 	}
 }
 
@@ -223,6 +225,9 @@ func (s *Scanner) launchTailers(source *logsconfig.LogSource) {
 			// FIXME: better detect a source that has been generated from a service discovery.
 			mode = logsconfig.Beginning
 		}
+		if m, ok := source.Config.TailFromMode(); ok {
+			mode = m
+		}
 
 		s.startNewTailer(file, mode)
 	}
@@ -252,7 +257,7 @@ func (s *Scanner) startNewTailer(file *File, m logsconfig.TailingMode) bool {
 		return false
 	}
 
-	tailer := s.createTailer(file, s.pipelineProvider.NextPipelineChan())
+	tailer := s.createTailer(file, s.pipelineProvider.PipelineChanFor(file.Path))
 
 	var offset int64
 	var whence int
@@ -262,6 +267,12 @@ func (s *Scanner) startNewTailer(file *File, m logsconfig.TailingMode) bool {
 	if err != nil {
 		log.Println("W! Could not recover offset for file with path", file.Path, err)
 	}
+	if file.Source != nil && mode == logsconfig.Beginning && s.registry.GetOffset(tailer.Identifier()) == "" {
+		// tail_from = "since:<duration>" applies only to the files without a registered offset
+		if since, ok := file.Source.Config.TailFromSince(); ok {
+			offset, whence = sinceOffset(file.Path, file.Source.Config, since)
+		}
+	}
 
 	if util.Debug() {
 		log.Printf("Starting a new tailer for: %s (offset: %d, whence: %d) for tailer key %s\n", file.Path, offset, whence, file.GetScanKey())
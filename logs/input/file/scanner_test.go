@@ -0,0 +1,79 @@
+//go:build !no_logs
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/auditor"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// mockProvider sends the messages of all the pipelines to the same channel
+type mockProvider struct {
+	outputChan chan *message.Message
+}
+
+func (p *mockProvider) Start()                                       {}
+func (p *mockProvider) Stop()                                        {}
+func (p *mockProvider) NextPipelineChan() chan *message.Message      { return p.outputChan }
+func (p *mockProvider) PipelineChanFor(string) chan *message.Message { return p.outputChan }
+func (p *mockProvider) Flush(context.Context)                        {}
+
+func tailedFiles(s *Scanner) []string {
+	var tailed []string
+	for key := range s.tailers {
+		tailed = append(tailed, filepath.Base(key))
+	}
+	sort.Strings(tailed)
+	return tailed
+}
+
+func TestScanRescansGlobs(t *testing.T) {
+	testConfig(t)
+	dir := t.TempDir()
+	now := time.Now()
+	touch(t, filepath.Join(dir, "a.log"), now.Add(-2*time.Hour))
+	source := logsconfig.NewLogSource("test", &logsconfig.LogsConfig{Type: logsconfig.FileType, Path: filepath.Join(dir, "*.log")})
+
+	sources := logsconfig.NewLogSources()
+	s := NewScanner(sources, 2, &mockProvider{outputChan: make(chan *message.Message, 100)}, auditor.NewNullAuditor(),
+		10*time.Millisecond, false, time.Second)
+	defer s.cleanup()
+	s.activeSources = append(s.activeSources, source)
+
+	s.scan()
+	if got, want := tailedFiles(s), []string{"a.log"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the tailed files %v, got %v", want, got)
+	}
+
+	// the new file matching the glob is tailed
+	touch(t, filepath.Join(dir, "b.log"), now.Add(-time.Hour))
+	s.scan()
+	if got, want := tailedFiles(s), []string{"a.log", "b.log"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the tailed files %v, got %v", want, got)
+	}
+
+	// the limit is reached, the tailer of the least recently modified file is replaced at once
+	touch(t, filepath.Join(dir, "c.log"), now)
+	s.scan()
+	if got, want := tailedFiles(s), []string{"b.log", "c.log"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the tailed files %v, got %v", want, got)
+	}
+
+	// the tailer of the deleted file is stopped
+	if err := os.Remove(filepath.Join(dir, "b.log")); err != nil {
+		t.Fatal(err)
+	}
+	s.scan()
+	if got, want := tailedFiles(s), []string{"a.log", "c.log"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the tailed files %v, got %v", want, got)
+	}
+}
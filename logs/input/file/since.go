@@ -0,0 +1,219 @@
+//go:build !no_logs
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/processor"
+)
+
+const (
+	// sinceMaxScan bounds the bytes read from an offset to find a line with a timestamp,
+	// e.g. over the continuation lines of a stack trace
+	sinceMaxScan = 1 << 20
+	// sinceMaxLine is the part of a line parsed, the longer JSON lines are not parsed
+	sinceMaxLine = 64 << 10
+	// sincePrefix is the part of a text line searched for a timestamp
+	sincePrefix = 256
+)
+
+// jsonTimestampKeys are the usual keys of the timestamps of the JSON logs, e.g. of the
+// json-file logs of docker
+var jsonTimestampKeys = []string{"time", "timestamp", "ts", "@timestamp"}
+
+// the layouts of the timestamps at the beginning of the lines or after the first '['
+var (
+	oneFieldLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02T15:04:05.999999999",
+	}
+	twoFieldsLayouts = []string{
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02 15:04:05,999999999",
+		"2006/01/02 15:04:05.999999999",
+		"02/Jan/2006:15:04:05 -0700",
+	}
+	// syslog, without the year
+	threeFieldsLayouts = []string{
+		"Jan 2 15:04:05",
+	}
+)
+
+// timestampParser returns the timestamp of a line of the source, with the timestamp_format of
+// the source if any, the JSON logs are parsed with the timestamp_key of the source or the usual
+// keys, the text logs with the usual layouts
+func timestampParser(cfg *logsconfig.LogsConfig, now time.Time) func(line []byte) (time.Time, bool) {
+	keys := jsonTimestampKeys
+	if cfg.TimestampKey != "" {
+		keys = []string{cfg.TimestampKey}
+	}
+	custom := cfg.TimestampParser()
+	return func(line []byte) (time.Time, bool) {
+		if custom != nil {
+			if ts, ok := custom.Parse(line, now); ok {
+				return ts, true
+			}
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] == '{' {
+			return processor.JSONTimestamp(line, keys...)
+		}
+		return textTimestamp(line, now)
+	}
+}
+
+// textTimestamp parses the timestamp at the beginning of the line, or after the first '['
+// of the line, e.g. in the access logs of nginx
+func textTimestamp(line []byte, now time.Time) (time.Time, bool) {
+	if len(line) > sincePrefix {
+		line = line[:sincePrefix]
+	}
+	s := string(line)
+	if ts, ok := parseTimestampFields(strings.TrimPrefix(s, "["), now); ok {
+		return ts, true
+	}
+	if i := strings.IndexByte(s, '['); i > 0 {
+		return parseTimestampFields(s[i+1:], now)
+	}
+	return time.Time{}, false
+}
+
+func parseTimestampFields(s string, now time.Time) (time.Time, bool) {
+	fields := strings.Fields(s)
+	for n, layouts := range [][]string{oneFieldLayouts, twoFieldsLayouts, threeFieldsLayouts} {
+		if len(fields) <= n {
+			break
+		}
+		v := strings.TrimRight(strings.Join(fields[:n+1], " "), "]:,")
+		for _, layout := range layouts {
+			ts, err := time.ParseInLocation(layout, v, time.Local)
+			if err != nil {
+				continue
+			}
+			if ts.Year() == 0 {
+				// the syslog timestamps are of the last 12 months
+				ts = ts.AddDate(now.Year(), 0, 0)
+				if ts.After(now.Add(24 * time.Hour)) {
+					ts = ts.AddDate(-1, 0, 0)
+				}
+			}
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// seekSince returns the offset of the first line of the file with a timestamp not before
+// cutoff, the size of the file if all the lines are older. The lines are assumed in the
+// order of their timestamps: the offset is searched by bisection over the byte offsets
+// and a seek reads only a few lines at each step. ok is false if no line has a timestamp.
+func seekSince(f io.ReaderAt, size int64, cutoff time.Time, parse func([]byte) (time.Time, bool)) (int64, bool) {
+	first, ts, found := timestampedLine(f, 0, size, parse)
+	if !found {
+		return 0, false
+	}
+	if !ts.Before(cutoff) {
+		return first, true
+	}
+
+	// the first line at or after lo is older than cutoff, the first line at or after hi is not,
+	// or hi is the end of the file
+	lo, hi := first+1, size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, ts, found := timestampedLine(f, mid, size, parse)
+		if !found || !ts.Before(cutoff) {
+			hi = mid
+		} else {
+			lo = start + 1
+		}
+	}
+	start, _, found := timestampedLine(f, lo, size, parse)
+	if !found {
+		return size, true
+	}
+	return start, true
+}
+
+// timestampedLine returns the start and the timestamp of the first line with a timestamp
+// starting at or after offset, the lines after sinceMaxScan bytes are not read
+func timestampedLine(f io.ReaderAt, offset, size int64, parse func([]byte) (time.Time, bool)) (int64, time.Time, bool) {
+	pos := offset
+	if offset > 0 {
+		// the line starts at offset if the previous byte is a newline
+		pos = offset - 1
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, pos, min(size-pos, sinceMaxScan)))
+	skip := offset > 0
+	for {
+		n, head, err := readLine(r, sinceMaxLine)
+		start := pos
+		pos += int64(n)
+		// the last line of the file may miss its newline
+		complete := err == nil || err == io.EOF && n > 0 && pos == size
+		if skip {
+			skip = false
+		} else if complete {
+			if ts, ok := parse(head); ok {
+				return start, ts, true
+			}
+		}
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+	}
+}
+
+// readLine returns the length of the next line, its newline included, and its first maxLen bytes
+func readLine(r *bufio.Reader, maxLen int) (int, []byte, error) {
+	var (
+		n    int
+		head []byte
+	)
+	for {
+		chunk, err := r.ReadSlice('\n')
+		n += len(chunk)
+		if len(head) < maxLen {
+			head = append(head, chunk[:min(len(chunk), maxLen-len(head))]...)
+		}
+		if err != bufio.ErrBufferFull {
+			return n, head, err
+		}
+	}
+}
+
+// sinceOffset returns the position of the first line of the file more recent than since, the
+// end of the file if it was not written since then or if its lines have no timestamp
+func sinceOffset(path string, cfg *logsconfig.LogsConfig, since time.Duration) (int64, int) {
+	f, err := openFile(path)
+	if err != nil {
+		// the tailer reports the error
+		return 0, io.SeekEnd
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, io.SeekEnd
+	}
+	now := time.Now()
+	cutoff := now.Add(-since)
+	if fi.ModTime().Before(cutoff) {
+		// not written since cutoff, all the lines are older
+		return 0, io.SeekEnd
+	}
+	offset, ok := seekSince(f, fi.Size(), cutoff, timestampParser(cfg, now))
+	if !ok {
+		log.Printf("W! no timestamp found in the lines of %s, tailing from the end for tail_from %s", path, cfg.TailFrom)
+		return 0, io.SeekEnd
+	}
+	return offset, io.SeekStart
+}
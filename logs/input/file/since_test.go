@@ -0,0 +1,88 @@
+//go:build !no_logs
+
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+)
+
+// countingReaderAt counts the bytes read
+type countingReaderAt struct {
+	r    io.ReaderAt
+	read int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.read += int64(n)
+	return n, err
+}
+
+func TestSeekSinceLargeFile(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	offsets := make(map[int]int64)
+	for i := 0; i < 200000; i++ {
+		offsets[i] = int64(buf.Len())
+		fmt.Fprintf(&buf, "%s INFO request %d served\n", start.Add(time.Duration(i)*time.Second).Format(time.RFC3339), i)
+		if i%7 == 0 {
+			// continuation lines without timestamp
+			buf.WriteString("\tat com.example.Handler.serve(Handler.java:42)\n\tat java.lang.Thread.run(Thread.java:750)\n")
+		}
+	}
+	data := buf.Bytes()
+	parse := timestampParser(&logsconfig.LogsConfig{}, start)
+
+	for _, tc := range []struct {
+		cutoff time.Time
+		want   int64
+	}{
+		{start.Add(150000 * time.Second), offsets[150000]},
+		{start.Add(150000*time.Second + 500*time.Millisecond), offsets[150001]},
+		{start.Add(-time.Hour), 0},
+		{start.Add(300000 * time.Second), int64(len(data))},
+	} {
+		r := &countingReaderAt{r: bytes.NewReader(data)}
+		got, ok := seekSince(r, int64(len(data)), tc.cutoff, parse)
+		if !ok || got != tc.want {
+			t.Fatalf("cutoff %v: got %d %v, want %d", tc.cutoff, got, ok, tc.want)
+		}
+		if r.read > int64(len(data))/10 {
+			t.Fatalf("cutoff %v: read %d of %d bytes, expected a bisection", tc.cutoff, r.read, len(data))
+		}
+	}
+
+	if _, ok := seekSince(bytes.NewReader([]byte("no\ntimestamps\n")), 15, start, parse); ok {
+		t.Fatal("expected no timestamp to be found")
+	}
+}
+
+func TestLineTimestamps(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	parse := timestampParser(&logsconfig.LogsConfig{}, now)
+	want := time.Date(2024, 2, 29, 10, 30, 0, 0, time.Local)
+	for _, line := range []string{
+		"2024-02-29 10:30:00,123 ERROR failed",
+		"[2024-02-29 10:30:00] local.ERROR: failed",
+		"2024/02/29 10:30:00 failed",
+		"Feb 29 10:30:00 host01 sshd[42]: accepted",
+		`10.0.0.1 - - [29/Feb/2024:10:30:00 ` + now.Format("-0700") + `] "GET / HTTP/1.1" 200`,
+		`{"log":"failed\n","stream":"stderr","time":"` + want.Format(time.RFC3339Nano) + `"}`,
+	} {
+		ts, ok := parse([]byte(line))
+		if !ok || !ts.Truncate(time.Second).Equal(want) {
+			t.Errorf("%q: got %v %v, want %v", line, ts, ok, want)
+		}
+	}
+
+	ts, ok := timestampParser(&logsconfig.LogsConfig{TimestampKey: "at"}, now)([]byte(`{"time":"x","at":1709116200}`))
+	if !ok || !ts.Equal(time.Unix(1709116200, 0)) {
+		t.Errorf("got %v %v with timestamp_key", ts, ok)
+	}
+}
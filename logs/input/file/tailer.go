@@ -22,6 +22,7 @@ import (
 
 	logsconfig "flashcat.cloud/categraf/config/logs"
 	"flashcat.cloud/categraf/logs/decoder"
+	"flashcat.cloud/categraf/logs/input/docker"
 	"flashcat.cloud/categraf/logs/input/kubernetes"
 	"flashcat.cloud/categraf/logs/message"
 	"flashcat.cloud/categraf/logs/parser"
@@ -54,8 +55,10 @@ type Tailer struct {
 	closeTimeout  time.Duration
 	shouldStop    int32
 	didFileRotate int32
-	stop          chan struct{}
-	done          chan struct{}
+	// set once the close timeout elapsed after a rotation, the file is closed at its end
+	drainRotated int32
+	stop         chan struct{}
+	done         chan struct{}
 
 	forwardContext context.Context
 	stopForward    context.CancelFunc
@@ -77,9 +80,9 @@ func NewDecoderFromSourceWithPattern(source *logsconfig.LogSource, multiLinePatt
 	case logsconfig.KubernetesSourceType:
 		lineParser = kubernetes.JSONParser
 		matcher = &decoder.NewLineMatcher{}
-	// case logsconfig.DockerSourceType:
-	//  lineParser = docker.JSONParser
-	//  matcher = &decoder.NewLineMatcher{}
+	case logsconfig.DockerSourceType:
+		lineParser = docker.FileParser
+		matcher = &decoder.NewLineMatcher{}
 	default:
 		switch strings.ToLower(source.Config.Encoding) {
 		case logsconfig.UTF16BE:
@@ -181,6 +184,10 @@ func (t *Tailer) readForever() {
 			return
 		default:
 			if n == 0 {
+				if atomic.LoadInt32(&t.drainRotated) == 1 {
+					// the rotated file has been read to the end
+					return
+				}
 				// wait for new data to come
 				t.wait()
 			}
@@ -220,12 +227,22 @@ func (t *Tailer) StopAfterFileRotation() {
 	t.file.Source.RemoveInput(t.file.Path)
 }
 
-// startStopTimer initializes and starts a timer to stop the tailor after the timeout
+// startStopTimer lets the tailer read the lines still written to the rotated file during
+// the timeout, then read it to the end before closing it. The tailer is stopped when the
+// file is not drained and its lines forwarded after another timeout, the pipeline being stuck.
 func (t *Tailer) startStopTimer() {
 	stopTimer := time.NewTimer(t.closeTimeout)
+	defer stopTimer.Stop()
 	<-stopTimer.C
-	t.stopForward()
-	t.stop <- struct{}{}
+	atomic.StoreInt32(&t.drainRotated, 1)
+
+	stopTimer.Reset(t.closeTimeout)
+	select {
+	case <-t.done:
+	case <-stopTimer.C:
+		t.stopForward()
+		t.stop <- struct{}{}
+	}
 }
 
 // onStop finishes to stop the tailer
@@ -253,7 +270,11 @@ func (t *Tailer) forwardMessages() {
 		origin := message.NewOrigin(t.file.Source)
 		origin.Identifier = identifier
 		origin.Offset = strconv.FormatInt(offset, 10)
-		origin.SetTags(append(t.tags, t.tagProvider.GetTags()...))
+		tags := append(t.tags, t.tagProvider.GetTags()...)
+		if output.Truncated {
+			tags = append(tags[:len(tags):len(tags)], decoder.TruncatedTag)
+		}
+		origin.SetTags(tags)
 		// Ignore empty lines once the registry offset is updated
 		if len(output.Content) == 0 {
 			continue
@@ -0,0 +1,100 @@
+//go:build !no_logs
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coreconfig "flashcat.cloud/categraf/config"
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// testConfig sets the config read by the tailers and restores it after the test
+func testConfig(t *testing.T) {
+	old := coreconfig.Config
+	coreconfig.Config = &coreconfig.ConfigType{}
+	t.Cleanup(func() {
+		coreconfig.Config = old
+	})
+}
+
+// newTestTailer returns a tailer of path with short sleep and close timeout
+func newTestTailer(path string, outputChan chan *message.Message) *Tailer {
+	source := logsconfig.NewLogSource("test", &logsconfig.LogsConfig{Type: logsconfig.FileType, Path: path})
+	tailer := NewTailer(outputChan, NewFile(path, source, false), 10*time.Millisecond, NewDecoderFromSource(source))
+	tailer.closeTimeout = 100 * time.Millisecond
+	return tailer
+}
+
+func appendLines(t *testing.T, path string, from, to int) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for i := from; i < to; i++ {
+		fmt.Fprintf(f, "line %d\n", i)
+	}
+}
+
+func waitClosed(t *testing.T, ch chan struct{}, timeout time.Duration, what string) {
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+		t.Fatalf("%s was not closed after %v", what, timeout)
+	}
+}
+
+func TestTailerDrainsRotatedFile(t *testing.T) {
+	testConfig(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLines(t, path, 0, 3)
+	outputChan := make(chan *message.Message, 100)
+	tailer := newTestTailer(path, outputChan)
+	if err := tailer.StartFromBeginning(); err != nil {
+		t.Fatal(err)
+	}
+
+	tailer.StopAfterFileRotation()
+	// the lines written to the rotated file during the close timeout are read
+	appendLines(t, path, 3, 6)
+	waitClosed(t, tailer.done, 2*time.Second, "done")
+
+	close(outputChan)
+	var got []string
+	for msg := range outputChan {
+		got = append(got, string(msg.Content))
+	}
+	if len(got) != 6 {
+		t.Fatalf("expected 6 lines, got %q", got)
+	}
+	for i, line := range got {
+		if want := fmt.Sprintf("line %d", i); line != want {
+			t.Errorf("line %d: expected %q, got %q", i, want, line)
+		}
+	}
+}
+
+func TestTailerStopsStuckRotatedFile(t *testing.T) {
+	testConfig(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendLines(t, path, 0, 3)
+	// nobody reads the output, the tailer is stuck on the first line
+	tailer := newTestTailer(path, make(chan *message.Message))
+	if err := tailer.StartFromBeginning(); err != nil {
+		t.Fatal(err)
+	}
+
+	tailer.StopAfterFileRotation()
+	// stopped after a second close timeout although the file was read to the end
+	start := time.Now()
+	waitClosed(t, tailer.done, 2*time.Second, "done")
+	if elapsed := time.Since(start); elapsed < 2*tailer.closeTimeout {
+		t.Errorf("stopped after %v, before the two close timeouts", elapsed)
+	}
+}
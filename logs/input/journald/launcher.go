@@ -8,6 +8,7 @@
 package journald
 
 import (
+	"errors"
 	"log"
 
 	config "flashcat.cloud/categraf/config/logs"
@@ -22,7 +23,10 @@ type Launcher struct {
 	pipelineProvider pipeline.Provider
 	registry         auditor.Registry
 	tailers          map[string]*Tailer
-	stop             chan struct{}
+	// unavailable is set once the default journal failed to open, the journald
+	// sources are then ignored instead of failing one by one
+	unavailable error
+	stop        chan struct{}
 }
 
 // NewLauncher returns a new Launcher.
@@ -51,11 +55,19 @@ func (l *Launcher) run() {
 				// set up only one tailer per journal
 				continue
 			}
+			if l.unavailable != nil {
+				source.Status.Error(l.unavailable)
+				continue
+			}
 			tailer, err := l.setupTailer(source)
-			if err != nil {
-				log.Println("Could not set up journald tailer: ", err)
-			} else {
+			switch {
+			case err == nil:
 				l.tailers[identifier] = tailer
+			case errors.Is(err, errJournalUnavailable) && identifier == "":
+				log.Println("W! journald is not available on this system, journald sources are disabled:", err)
+				l.unavailable = err
+			default:
+				log.Println("E! could not set up journald tailer:", err)
 			}
 		case <-l.stop:
 			return
@@ -77,7 +89,7 @@ func (l *Launcher) Stop() {
 // setupTailer configures and starts a new tailer,
 // returns the tailer or an error.
 func (l *Launcher) setupTailer(source *config.LogSource) (*Tailer, error) {
-	tailer := NewTailer(source, l.pipelineProvider.NextPipelineChan())
+	tailer := NewTailer(source, l.pipelineProvider.PipelineChanFor(source.Name))
 	cursor := l.registry.GetOffset(tailer.Identifier())
 	err := tailer.Start(cursor)
 	if err != nil {
@@ -8,21 +8,50 @@
 package journald
 
 import (
+	"errors"
+	"log"
+
 	logsconfig "flashcat.cloud/categraf/config/logs"
 	"flashcat.cloud/categraf/logs/auditor"
 	"flashcat.cloud/categraf/logs/pipeline"
 )
 
-// Launcher is not supported on no systemd environment.
-type Launcher struct{}
+var errNotSupported = errors.New("journald is not supported, categraf is built without the systemd tag")
+
+// Launcher is not supported on no systemd environment, it marks the journald sources as failed.
+type Launcher struct {
+	sources chan *logsconfig.LogSource
+	stop    chan struct{}
+}
 
 // NewLauncher returns a new Launcher
 func NewLauncher(sources *logsconfig.LogSources, pipelineProvider pipeline.Provider, registry auditor.Registry) *Launcher {
-	return &Launcher{}
+	return &Launcher{
+		sources: sources.GetAddedForType(logsconfig.JournaldType),
+		stop:    make(chan struct{}),
+	}
 }
 
-// Start does nothing
-func (l *Launcher) Start() {}
+// Start starts reporting the journald sources as not supported
+func (l *Launcher) Start() {
+	go func() {
+		logged := false
+		for {
+			select {
+			case source := <-l.sources:
+				if !logged {
+					log.Println("W!", errNotSupported)
+					logged = true
+				}
+				source.Status.Error(errNotSupported)
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
 
-// Stop does nothing
-func (l *Launcher) Stop() {}
+// Stop stops the launcher
+func (l *Launcher) Stop() {
+	l.stop <- struct{}{}
+}
@@ -9,6 +9,7 @@ package journald
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -26,6 +27,10 @@ const (
 	defaultApplicationName = "docker"
 )
 
+// errJournalUnavailable is returned by Start when the journal can't be opened, e.g. on a
+// system without systemd-journald or libsystemd
+var errJournalUnavailable = errors.New("journal is not available")
+
 // Tailer collects logs from a journal.
 type Tailer struct {
 	source     *logsconfig.LogSource
@@ -76,8 +81,6 @@ func (t *Tailer) setup() error {
 	config := t.source.Config
 	var err error
 
-	t.initializeTagger()
-
 	if config.Path == "" {
 		// open the default journal
 		t.journal, err = sdjournal.NewJournal()
@@ -85,10 +88,10 @@ func (t *Tailer) setup() error {
 		t.journal, err = sdjournal.NewJournalFromDir(config.Path)
 	}
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", errJournalUnavailable, err)
 	}
 
-	for _, unit := range config.IncludeUnits {
+	for _, unit := range config.JournaldUnits() {
 		// add filters to collect only the logs of the units defined in the configuration,
 		// if no units are defined, collect all the logs of the journal by default.
 		match := sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT + "=" + unit
@@ -98,6 +101,15 @@ func (t *Tailer) setup() error {
 		}
 	}
 
+	for _, p := range config.IncludePriorities {
+		// matches of different fields are combined with AND, the ones of the same field with OR
+		priority, _ := logsconfig.JournaldPriority(p)
+		match := sdjournal.SD_JOURNAL_FIELD_PRIORITY + "=" + priority
+		if err := t.journal.AddMatch(match); err != nil {
+			return fmt.Errorf("could not add filter %s: %s", match, err)
+		}
+	}
+
 	t.blacklist = make(map[string]bool)
 	for _, unit := range config.ExcludeUnits {
 		// add filters to drop all the logs related to units to exclude.
@@ -259,11 +271,26 @@ func (t *Tailer) getApplicationName(entry *sdjournal.JournalEntry, tags []string
 	return ""
 }
 
+// fieldTags maps the journal fields set as tags of the entries to the tag names
+var fieldTags = []struct {
+	field string
+	tag   string
+}{
+	{sdjournal.SD_JOURNAL_FIELD_SYSLOG_IDENTIFIER, "syslog_identifier"},
+	{sdjournal.SD_JOURNAL_FIELD_SYSTEMD_UNIT, "systemd_unit"},
+	{sdjournal.SD_JOURNAL_FIELD_PRIORITY, "priority"},
+}
+
 // getTags returns a list of tags matching with the journal entry.
 func (t *Tailer) getTags(entry *sdjournal.JournalEntry) []string {
 	var tags []string
 	if t.isContainerEntry(entry) {
-		tags = t.getContainerTags(t.getContainerID(entry))
+		tags = t.getContainerTags(entry)
+	}
+	for _, ft := range fieldTags {
+		if value, exists := entry.Fields[ft.field]; exists && value != "" {
+			tags = append(tags, ft.tag+"="+value)
+		}
 	}
 	return tags
 }
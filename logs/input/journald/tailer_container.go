@@ -0,0 +1,41 @@
+//go:build !no_logs && systemd
+
+package journald
+
+import (
+	"fmt"
+
+	"github.com/coreos/go-systemd/sdjournal"
+)
+
+// fields set by the journald logging driver of docker, see
+// https://docs.docker.com/config/containers/logging/journald/
+const (
+	containerIDKey   = "CONTAINER_ID_FULL"
+	containerNameKey = "CONTAINER_NAME"
+	imageNameKey     = "IMAGE_NAME"
+)
+
+// isContainerEntry returns true if the entry comes from a docker container.
+func (t *Tailer) isContainerEntry(entry *sdjournal.JournalEntry) bool {
+	_, exists := entry.Fields[containerIDKey]
+	return exists
+}
+
+// getContainerID returns the container identifier of the journal entry.
+func (t *Tailer) getContainerID(entry *sdjournal.JournalEntry) string {
+	return entry.Fields[containerIDKey]
+}
+
+// getContainerTags returns the docker tags of the journal entry, named like the tags of
+// the docker launcher.
+func (t *Tailer) getContainerTags(entry *sdjournal.JournalEntry) []string {
+	tags := []string{fmt.Sprintf("docker.container_id=%s", t.getContainerID(entry))}
+	if name, exists := entry.Fields[containerNameKey]; exists {
+		tags = append(tags, fmt.Sprintf("docker.container_name=%s", name))
+	}
+	if image, exists := entry.Fields[imageNameKey]; exists {
+		tags = append(tags, imageTagKey+image)
+	}
+	return tags
+}
@@ -13,17 +13,17 @@ import (
 	"strings"
 	"time"
 
+	"flashcat.cloud/categraf/logs/util/containers"
 	"flashcat.cloud/categraf/pkg/cache"
 )
 
 const (
-	tagSeparator            = ":"
-	imageTagKey             = "short_image" + tagSeparator
+	imageTagKey             = "docker.container_image="
 	baseCacheExpiration     = 20 * time.Minute
 	expirationSpreadSeconds = 120
 )
 
-// Get docker image short from dockerId and list of tags
+// Get docker image short name from dockerId and list of tags
 // In case of miss in cache, we parse from tag list.
 // We could use the util/Docker.Inspect + ImageID resolution or Regexp on config.Image
 // But this way is simple and probably fast enough (~300ns for 100 items) on miss
@@ -41,9 +41,8 @@ func getDockerImageShortName(containerID string, tags []string) (string, bool) {
 	var shortName string
 	for _, tag := range tags {
 		if strings.HasPrefix(tag, imageTagKey) {
-			tagParts := strings.Split(tag, tagSeparator)
-			if len(tagParts) == 2 {
-				shortName = tagParts[1]
+			if _, name, _, err := containers.SplitImageName(strings.TrimPrefix(tag, imageTagKey)); err == nil {
+				shortName = name
 			}
 			break
 		}
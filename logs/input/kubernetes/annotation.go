@@ -0,0 +1,126 @@
+//go:build !no_logs
+
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/pkg/kubernetes"
+)
+
+// AnnotationConfigKey configures the logs of the containers of a pod with a JSON array,
+// e.g. [{"source":"nginx","service":"frontend","multiline":{"pattern":"^\\d{4}-"}}].
+// A config with a container applies to that container, the first config without
+// container applies to the other containers of the pod.
+const AnnotationConfigKey = "categraf.logs/config"
+
+// AnnotationConfig is the part of a logs config a pod may set, the type and the path
+// of the source are always set by the launcher
+type AnnotationConfig struct {
+	Container       string                       `json:"container"`
+	Source          string                       `json:"source"`
+	Service         string                       `json:"service"`
+	SourceCategory  string                       `json:"source_category"`
+	Topic           string                       `json:"topic"`
+	Tags            []string                     `json:"tags"`
+	ProcessingRules []*logsconfig.ProcessingRule `json:"log_processing_rules"`
+	MetricRules     []*logsconfig.MetricRule     `json:"log_metric_rules"`
+	Multiline       *logsconfig.MultilineConfig  `json:"multiline"`
+	RateLimit       *logsconfig.RateLimit        `json:"rate_limit"`
+
+	AutoParseJSON bool     `json:"auto_parse_json"`
+	TimestampKey  string   `json:"timestamp_key"`
+	LevelKey      string   `json:"level_key"`
+	MessageKey    string   `json:"message_key"`
+	TagKeys       []string `json:"tag_keys"`
+	JSONMaxSize   int      `json:"json_max_size"`
+}
+
+// ParseAnnotationConfig returns the config of the container in the AnnotationConfigKey
+// annotation, nil if the pod has no config for it
+func ParseAnnotationConfig(annotations map[string]string, container string) (*AnnotationConfig, error) {
+	v, ok := annotations[AnnotationConfigKey]
+	if !ok || v == "" {
+		return nil, nil
+	}
+	var configs []*AnnotationConfig
+	if err := json.Unmarshal([]byte(v), &configs); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %v", AnnotationConfigKey, err)
+	}
+	var found *AnnotationConfig
+	for _, c := range configs {
+		if c == nil {
+			continue
+		}
+		if c.Container == container {
+			found = c
+			break
+		}
+		if c.Container == "" && found == nil {
+			found = c
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+	if found.Multiline != nil {
+		if err := found.Multiline.Compile(); err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %v", AnnotationConfigKey, err)
+		}
+	}
+	return found, nil
+}
+
+// Apply merges the annotation config into the config built by the launcher, the fields
+// set by the annotation take precedence, the tags and the rules are appended
+func (a *AnnotationConfig) Apply(cfg *logsconfig.LogsConfig) {
+	if a.Source != "" {
+		cfg.Source = a.Source
+	}
+	if a.Service != "" {
+		cfg.Service = a.Service
+	}
+	if a.SourceCategory != "" {
+		cfg.SourceCategory = a.SourceCategory
+	}
+	if a.Topic != "" {
+		cfg.Topic = a.Topic
+	}
+	cfg.Tags = append(cfg.Tags, a.Tags...)
+	cfg.ProcessingRules = append(cfg.ProcessingRules, a.ProcessingRules...)
+	cfg.MetricRules = append(cfg.MetricRules, a.MetricRules...)
+	if a.Multiline != nil {
+		cfg.Multiline = a.Multiline
+	}
+	if a.RateLimit != nil {
+		cfg.RateLimit = a.RateLimit
+	}
+	if a.AutoParseJSON {
+		cfg.AutoParseJSON = true
+		cfg.TimestampKey = a.TimestampKey
+		cfg.LevelKey = a.LevelKey
+		cfg.MessageKey = a.MessageKey
+		cfg.TagKeys = a.TagKeys
+		cfg.JSONMaxSize = a.JSONMaxSize
+	}
+}
+
+// ApplyAnnotationConfig applies the AnnotationConfigKey annotation of the pod to the config of
+// the container, an invalid annotation is logged and ignored so the container is still
+// collected with the config of the launcher
+func ApplyAnnotationConfig(pod *kubernetes.Pod, container string, cfg *logsconfig.LogsConfig) {
+	a, err := ParseAnnotationConfig(pod.Metadata.Annotations, container)
+	if err == nil && a != nil {
+		merged := *cfg
+		a.Apply(&merged)
+		if err = merged.Validate(); err == nil {
+			*cfg = merged
+		}
+	}
+	if err != nil {
+		log.Printf("W! ignore the annotation %s of pod %s/%s: %v", AnnotationConfigKey, pod.Metadata.Namespace, pod.Metadata.Name, err)
+	}
+}
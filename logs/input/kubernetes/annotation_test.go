@@ -0,0 +1,43 @@
+//go:build !no_logs
+
+package kubernetes
+
+import (
+	"testing"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/pkg/kubernetes"
+)
+
+func TestApplyAnnotationConfig(t *testing.T) {
+	pod := &kubernetes.Pod{}
+	pod.Metadata.Name = "web"
+	pod.Metadata.Annotations = map[string]string{
+		AnnotationConfigKey: `[
+			{"source":"nginx","service":"frontend","tags":["team=web"],"multiline":{"pattern":"^\\d{4}-"}},
+			{"container":"sidecar","source":"envoy"}
+		]`,
+	}
+
+	cfg := &logsconfig.LogsConfig{Type: logsconfig.FileType, Path: "/var/log/pods/web", Source: "nginx:1.25", Service: "nginx:1.25", Tags: []string{"pod=web"}}
+	ApplyAnnotationConfig(pod, "nginx", cfg)
+	if cfg.Source != "nginx" || cfg.Service != "frontend" || cfg.Multiline == nil {
+		t.Fatalf("annotation not applied: %+v", cfg)
+	}
+	if len(cfg.Tags) != 2 || cfg.Tags[1] != "team=web" {
+		t.Fatalf("expected the tags to be appended, got %v", cfg.Tags)
+	}
+
+	cfg = &logsconfig.LogsConfig{Type: logsconfig.FileType, Path: "/var/log/pods/web", Source: "envoy:1.29", Service: "envoy:1.29"}
+	ApplyAnnotationConfig(pod, "sidecar", cfg)
+	if cfg.Source != "envoy" || cfg.Service != "envoy:1.29" || cfg.Multiline != nil {
+		t.Fatalf("expected the config of the container, got %+v", cfg)
+	}
+
+	pod.Metadata.Annotations[AnnotationConfigKey] = `[{"source":`
+	cfg = &logsconfig.LogsConfig{Type: logsconfig.FileType, Path: "/var/log/pods/web", Source: "nginx:1.25", Service: "nginx:1.25"}
+	ApplyAnnotationConfig(pod, "nginx", cfg)
+	if cfg.Source != "nginx:1.25" {
+		t.Fatalf("expected the invalid annotation to be ignored, got %+v", cfg)
+	}
+}
@@ -35,10 +35,14 @@ const (
 	AnnotationTopicKey     = "categraf/logs.stdout.topic"
 	AnnotationTagPrefixKey = "categraf/tags.prefix"
 	AnnotationCollectKey   = "categraf/logs.stdout.collect"
+	AnnotationMultilineKey = "categraf/logs.stdout.multiline"
 )
 
 var (
 	basePath = "/var/log/pods"
+	// annotationCheckPeriod is the period of the checks of the AnnotationConfigKey annotations
+	// of the pods of the tailed containers
+	annotationCheckPeriod = 10 * time.Second
 )
 
 var errCollectAllDisabled = fmt.Errorf("%s disabled", logsconfig.ContainerCollectAll)
@@ -53,14 +57,18 @@ type retryOps struct {
 type Launcher struct {
 	sources            *logsconfig.LogSources
 	sourcesByContainer map[string]*logsconfig.LogSource
-	stopped            chan struct{}
-	kubeutil           kubelet.KubeUtilInterface
-	addedServices      chan *service.Service
-	removedServices    chan *service.Service
-	retryOperations    chan *retryOps
-	collectAll         bool
-	pendingRetries     map[string]*retryOps
-	serviceNameFunc    func(string, string) string // serviceNameFunc gets the service name from the tagger, it is in a separate field for testing purpose
+	// servicesByContainer and annotationsByContainer hold the service and the AnnotationConfigKey
+	// annotation of the pod of each tailed container, the source is reloaded when the annotation changes
+	servicesByContainer    map[string]*service.Service
+	annotationsByContainer map[string]string
+	stopped                chan struct{}
+	kubeutil               kubelet.KubeUtilInterface
+	addedServices          chan *service.Service
+	removedServices        chan *service.Service
+	retryOperations        chan *retryOps
+	collectAll             bool
+	pendingRetries         map[string]*retryOps
+	serviceNameFunc        func(string, string) string // serviceNameFunc gets the service name from the tagger, it is in a separate field for testing purpose
 }
 
 // IsAvailable retrues true if the launcher is available and a retrier otherwise
@@ -83,14 +91,16 @@ func NewLauncher(sources *logsconfig.LogSources, services *service.Services, col
 		return nil
 	}
 	launcher := &Launcher{
-		sources:            sources,
-		sourcesByContainer: make(map[string]*logsconfig.LogSource),
-		stopped:            make(chan struct{}),
-		kubeutil:           kubeutil,
-		collectAll:         collectAll,
-		pendingRetries:     make(map[string]*retryOps),
-		retryOperations:    make(chan *retryOps),
-		serviceNameFunc:    ServiceNameFromTags,
+		sources:                sources,
+		sourcesByContainer:     make(map[string]*logsconfig.LogSource),
+		servicesByContainer:    make(map[string]*service.Service),
+		annotationsByContainer: make(map[string]string),
+		stopped:                make(chan struct{}),
+		kubeutil:               kubeutil,
+		collectAll:             collectAll,
+		pendingRetries:         make(map[string]*retryOps),
+		retryOperations:        make(chan *retryOps),
+		serviceNameFunc:        ServiceNameFromTags,
 	}
 	launcher.addedServices = services.GetAllAddedServices()
 	launcher.removedServices = services.GetAllRemovedServices()
@@ -112,6 +122,8 @@ func (l *Launcher) Stop() {
 // run handles new and deleted pods,
 // the kubernetes launcher consumes new and deleted services pushed by the autodiscovery
 func (l *Launcher) run() {
+	ticker := time.NewTicker(annotationCheckPeriod)
+	defer ticker.Stop()
 	for {
 		select {
 		case service := <-l.addedServices:
@@ -120,6 +132,8 @@ func (l *Launcher) run() {
 			l.removeSource(service)
 		case ops := <-l.retryOperations:
 			l.addSource(ops.service)
+		case <-ticker.C:
+			l.reloadChangedAnnotations()
 		case <-l.stopped:
 			log.Println("Kubernetes launcher stopped")
 			return
@@ -201,6 +215,8 @@ func (l *Launcher) addSource(svc *service.Service) {
 	source.SetSourceType(logsconfig.KubernetesSourceType)
 
 	l.sourcesByContainer[svc.GetEntityID()] = source
+	l.servicesByContainer[svc.GetEntityID()] = svc
+	l.annotationsByContainer[svc.GetEntityID()] = pod.Metadata.Annotations[AnnotationConfigKey]
 	l.sources.AddSource(source)
 
 	// Clean-up retry logic
@@ -223,10 +239,44 @@ func (l *Launcher) removeSource(service *service.Service) {
 	}
 	if source, exists := l.sourcesByContainer[containerID]; exists {
 		delete(l.sourcesByContainer, containerID)
+		delete(l.servicesByContainer, containerID)
+		delete(l.annotationsByContainer, containerID)
 		l.sources.RemoveSource(source)
 	}
 }
 
+// reloadChangedAnnotations replaces the sources of the containers whose pod has a new
+// AnnotationConfigKey annotation, the containers of the deleted pods are left to removeSource
+func (l *Launcher) reloadChangedAnnotations() {
+	if len(l.servicesByContainer) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), annotationCheckPeriod)
+	defer cancel()
+	pods, err := l.kubeutil.GetLocalPodList(ctx)
+	if err != nil {
+		log.Printf("Failed to fetch the pods to check the annotation %s: %v", AnnotationConfigKey, err)
+		return
+	}
+	annotations := make(map[string]string)
+	for _, pod := range pods {
+		for _, container := range pod.Status.GetAllContainers() {
+			if container.ID != "" {
+				annotations[containers.ContainerIDForEntity(container.ID)] = pod.Metadata.Annotations[AnnotationConfigKey]
+			}
+		}
+	}
+	for containerID, svc := range l.servicesByContainer {
+		annotation, found := annotations[containers.ContainerIDForEntity(containerID)]
+		if !found || annotation == l.annotationsByContainer[containerID] {
+			continue
+		}
+		log.Printf("Annotation %s of the pod of container %v changed, reloading its source", AnnotationConfigKey, svc.Identifier)
+		l.removeSource(svc)
+		l.addSource(svc)
+	}
+}
+
 // kubernetesIntegration represents the name of the integration.
 const kubernetesIntegration = "kubernetes"
 
@@ -275,6 +325,13 @@ func (l *Launcher) getSource(pod *kubernetes.Pod, container kubernetes.Container
 			}
 		}
 	}
+	if v := pod.Metadata.Annotations[AnnotationMultilineKey]; v != "" {
+		if m, err := logsconfig.ParseMultilineConfig(v); err != nil {
+			log.Printf("pod multiline %s of %s ignored: %v", v, pod.Metadata.Name, err)
+		} else {
+			cfg.Multiline = m
+		}
+	}
 	if cfg.Service == "" && standardService != "" {
 		cfg.Service = standardService
 	}
@@ -284,6 +341,7 @@ func (l *Launcher) getSource(pod *kubernetes.Pod, container kubernetes.Container
 	}
 	cfg.Path = l.getPath(basePath, pod, container)
 	cfg.Identifier = kubelet.TrimRuntimeFromCID(container.ID)
+	ApplyAnnotationConfig(pod, container.Name, cfg)
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid kubernetes annotation: %v", err)
 	}
@@ -0,0 +1,122 @@
+//go:build !no_logs
+
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+const (
+	// defaultMaxMessageSize is the default size of the largest message of the tcp and udp sources
+	defaultMaxMessageSize = 256 * 1000
+	// lengthPrefixSize is the size of the big-endian length before each message of the
+	// length_prefix framing
+	lengthPrefixSize = 4
+)
+
+func maxMessageSize(source *logsconfig.LogSource) int {
+	if source.Config.MaxMessageSize > 0 {
+		return source.Config.MaxMessageSize
+	}
+	return defaultMaxMessageSize
+}
+
+func lengthPrefixed(source *logsconfig.LogSource) bool {
+	return source.Config.Framing == logsconfig.LengthPrefixFraming
+}
+
+// remoteTags returns the tag of the address of the client, without the port
+func remoteTags(addr net.Addr) []string {
+	if addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return []string{"remote_addr=" + host}
+}
+
+func newMessage(source *logsconfig.LogSource, content []byte, tags []string) *message.Message {
+	origin := message.NewOrigin(source)
+	origin.SetTags(tags)
+	return message.NewMessage(content, origin, message.StatusInfo, time.Now().UnixNano())
+}
+
+// readLengthPrefixed reads a message preceded by its 4-byte big-endian length, the bytes
+// beyond maxSize are discarded
+func readLengthPrefixed(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	var prefix [lengthPrefixSize]byte
+	if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+		return nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(prefix[:]))
+	size := min(length, int64(maxSize))
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+	if size < length {
+		if _, err := io.CopyN(io.Discard, reader, length-size); err != nil {
+			return frame, err
+		}
+	}
+	return frame, nil
+}
+
+// readLine reads a line terminated by a line feed, without the line feed, the rest of a line
+// longer than maxSize is discarded
+func readLine(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	var line []byte
+	truncated := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !truncated {
+			if room := maxSize - len(line); len(bytes.TrimRight(chunk, "\n")) > room {
+				line = append(line, chunk[:room]...)
+				truncated = true
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if err == io.EOF && len(line) > 0 {
+			// the last line of a connection closed by the client
+			return line, nil
+		}
+		return line, err
+	}
+}
+
+// splitDatagram returns the messages of a udp datagram, the datagrams of the newline framing
+// may hold several lines
+func splitDatagram(data []byte, prefixed bool, maxSize int) [][]byte {
+	var frames [][]byte
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		var frame []byte
+		var err error
+		if prefixed {
+			frame, err = readLengthPrefixed(reader, maxSize)
+		} else {
+			frame, err = readLine(reader, maxSize)
+		}
+		if len(frame) > 0 {
+			frames = append(frames, frame)
+		}
+		if err != nil {
+			return frames
+		}
+	}
+}
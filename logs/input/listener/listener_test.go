@@ -0,0 +1,200 @@
+//go:build !no_logs
+
+package listener
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// chanProvider hands out a single pipeline channel
+type chanProvider struct {
+	ch chan *message.Message
+}
+
+func (p *chanProvider) Start()                                           {}
+func (p *chanProvider) Stop()                                            {}
+func (p *chanProvider) Flush(ctx context.Context)                        {}
+func (p *chanProvider) NextPipelineChan() chan *message.Message          { return p.ch }
+func (p *chanProvider) PipelineChanFor(key string) chan *message.Message { return p.ch }
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func dial(t *testing.T, network string, port int) net.Conn {
+	var err error
+	for i := 0; i < 50; i++ {
+		var conn net.Conn
+		if conn, err = net.Dial(network, fmt.Sprintf("127.0.0.1:%d", port)); err == nil {
+			return conn
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal(err)
+	return nil
+}
+
+func receive(t *testing.T, ch chan *message.Message) *message.Message {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func prefixed(s string) []byte {
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(s)))
+	return append(frame, s...)
+}
+
+func TestTCPListenerFraming(t *testing.T) {
+	for _, framing := range []string{logsconfig.NewlineFraming, logsconfig.LengthPrefixFraming} {
+		t.Run(framing, func(t *testing.T) {
+			port := freePort(t)
+			source := logsconfig.NewLogSource("appliance", &logsconfig.LogsConfig{
+				Type: logsconfig.TCPType, Port: port, Framing: framing, MaxMessageSize: 8,
+			})
+			provider := &chanProvider{ch: make(chan *message.Message, 10)}
+			listener := NewTCPListener(provider, source, 4096)
+			listener.Start()
+			defer listener.Stop()
+
+			conn := dial(t, "tcp", port)
+			if framing == logsconfig.LengthPrefixFraming {
+				conn.Write(append(prefixed("multi\nline"), prefixed("second")...))
+			} else {
+				conn.Write([]byte("multi line\nsecond\n"))
+			}
+
+			first, second := receive(t, provider.ch), receive(t, provider.ch)
+			want := "multi li"
+			if framing == logsconfig.LengthPrefixFraming {
+				want = "multi\nli"
+			}
+			if string(first.Content) != want || string(second.Content) != "second" {
+				t.Fatalf("unexpected messages %q %q", first.Content, second.Content)
+			}
+			if tags := second.Origin.Tags(); !reflect.DeepEqual(tags, []string{"remote_addr=127.0.0.1"}) {
+				t.Fatalf("unexpected tags %v", tags)
+			}
+			conn.Close()
+		})
+	}
+}
+
+// a full pipeline blocks the reads of the tcp connections, nothing is dropped
+func TestTCPListenerBackPressure(t *testing.T) {
+	port := freePort(t)
+	source := logsconfig.NewLogSource("slow", &logsconfig.LogsConfig{
+		Type: logsconfig.TCPType, Port: port, Framing: logsconfig.LengthPrefixFraming,
+	})
+	provider := &chanProvider{ch: make(chan *message.Message)}
+	listener := NewTCPListener(provider, source, 4096)
+	listener.Start()
+
+	conn := dial(t, "tcp", port)
+	defer conn.Close()
+	for i := 0; i < 100; i++ {
+		conn.Write(prefixed(fmt.Sprintf("line %d", i)))
+	}
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		if msg := receive(t, provider.ch); string(msg.Content) != fmt.Sprintf("line %d", i) {
+			t.Fatalf("expected line %d, got %q", i, msg.Content)
+		}
+	}
+	go func() {
+		// the tailers flush their last messages on stop
+		for range provider.ch {
+		}
+	}()
+	listener.Stop()
+}
+
+func TestUDPListenerDrops(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	source := logsconfig.NewLogSource("udp_appliance", &logsconfig.LogsConfig{Type: logsconfig.UDPType, Port: port})
+	provider := &chanProvider{ch: make(chan *message.Message, 1)}
+	listener := NewUDPListener(provider, source, 9000)
+	listener.Start()
+	defer listener.Stop()
+
+	client := dial(t, "udp", port)
+	defer client.Close()
+	before := dropped(t, "udp_appliance")
+	// three lines in a datagram, the last two don't fit in the pipeline
+	client.Write([]byte("first\nsecond\nthird\n"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for dropped(t, "udp_appliance") != before+2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the last lines to be counted as dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if msg := receive(t, provider.ch); string(msg.Content) != "first" || msg.Origin.Tags()[0] != "remote_addr=127.0.0.1" {
+		t.Fatalf("unexpected message %q %v", msg.Content, msg.Origin.Tags())
+	}
+}
+
+// dropped returns the logs_dropped_total of the source for a full pipeline
+func dropped(t *testing.T, source string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "logs_dropped_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["source"] == source && labels["reason"] == "pipeline_full" {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestSplitDatagram(t *testing.T) {
+	frames := splitDatagram(append(prefixed("a\nb"), prefixed("toolong")...), true, 4)
+	if len(frames) != 2 || string(frames[0]) != "a\nb" || string(frames[1]) != "tool" {
+		t.Fatalf("unexpected frames %q", frames)
+	}
+	frames = splitDatagram([]byte("a\r\n\nb"), false, 4)
+	if len(frames) != 2 || string(frames[0]) != "a" || string(frames[1]) != "b" {
+		t.Fatalf("unexpected frames %q", frames)
+	}
+	// a truncated prefix is dropped
+	if frames := splitDatagram([]byte{0, 0, 0, 9, 'a'}, true, 4); len(frames) != 0 {
+		t.Fatalf("unexpected frames %q", frames)
+	}
+}
@@ -8,6 +8,7 @@
 package listener
 
 import (
+	"bufio"
 	"io"
 	"log"
 	"net"
@@ -18,15 +19,20 @@ import (
 	"flashcat.cloud/categraf/logs/parser"
 )
 
-// Tailer reads data from a connection
+// Tailer reads data from a connection, the lines of the newline framing are split by the
+// decoder, the messages of the length_prefix framing are forwarded as they are read
 type Tailer struct {
 	source     *logsconfig.LogSource
 	conn       net.Conn
+	reader     *bufio.Reader
 	outputChan chan *message.Message
 	read       func(*Tailer) ([]byte, error)
 	decoder    *decoder.Decoder
 	stop       chan struct{}
 	done       chan struct{}
+	// the tags of the messages, the address of the client
+	tags   []string
+	framed bool
 }
 
 // NewTailer returns a new Tailer
@@ -39,6 +45,8 @@ func NewTailer(source *logsconfig.LogSource, conn net.Conn, outputChan chan *mes
 		decoder:    decoder.InitializeDecoder(source, parser.NoopParser),
 		stop:       make(chan struct{}, 1),
 		done:       make(chan struct{}, 1),
+		tags:       remoteTags(conn.RemoteAddr()),
+		framed:     lengthPrefixed(source),
 	}
 }
 
@@ -64,7 +72,9 @@ func (t *Tailer) forwardMessages() {
 	}()
 	for output := range t.decoder.OutputChan {
 		if len(output.Content) > 0 {
-			t.outputChan <- message.NewMessageWithSource(output.Content, message.StatusInfo, t.source, output.IngestionTimestamp)
+			msg := message.NewMessageWithSource(output.Content, message.StatusInfo, t.source, output.IngestionTimestamp)
+			msg.Origin.SetTags(t.tags)
+			t.outputChan <- msg
 		}
 	}
 }
@@ -92,6 +102,12 @@ func (t *Tailer) readForever() {
 				return
 			}
 			t.source.BytesRead.Add(int64(len(data)))
+			// the sends block while the pipeline is full, so that the clients are slowed down
+			// by the tcp flow control instead of the logs being dropped
+			if t.framed {
+				t.outputChan <- newMessage(t.source, data, t.tags)
+				continue
+			}
 			t.decoder.InputChan <- decoder.NewInput(data)
 		}
 	}
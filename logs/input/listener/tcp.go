@@ -8,6 +8,8 @@
 package listener
 
 import (
+	"bufio"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
@@ -25,6 +27,7 @@ type TCPListener struct {
 	source           *logsconfig.LogSource
 	idleTimeout      time.Duration
 	frameSize        int
+	maxMessageSize   int
 	listener         net.Listener
 	tailers          []*Tailer
 	mu               sync.Mutex
@@ -48,6 +51,7 @@ func NewTCPListener(pipelineProvider pipeline.Provider, source *logsconfig.LogSo
 		source:           source,
 		idleTimeout:      idleTimeout,
 		frameSize:        frameSize,
+		maxMessageSize:   maxMessageSize(source),
 		tailers:          []*Tailer{},
 		stop:             make(chan struct{}, 1),
 	}
@@ -113,9 +117,20 @@ func (l *TCPListener) run() {
 	}
 }
 
-// startListener starts a new listener, returns an error if it failed.
+// startListener starts a new listener, over tls when the source has a certificate, returns
+// an error if it failed.
 func (l *TCPListener) startListener() error {
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", l.source.Config.Port))
+	tlsConfig, err := l.source.Config.ServerConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf(":%d", l.source.Config.Port)
+	var listener net.Listener
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", addr, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", addr)
+	}
 	if err != nil {
 		return err
 	}
@@ -123,19 +138,29 @@ func (l *TCPListener) startListener() error {
 	return nil
 }
 
-// read reads data from connection, returns an error if it failed and stop the tailer.
+// read reads a message of the length_prefix framing or a line from connection, the messages
+// are truncated to max_message_size, returns an error if it failed and stop the tailer.
 func (l *TCPListener) read(tailer *Tailer) ([]byte, error) {
 	if l.idleTimeout > 0 {
 		tailer.conn.SetReadDeadline(time.Now().Add(l.idleTimeout)) //nolint:errcheck
 	}
-	frame := make([]byte, l.frameSize)
-	n, err := tailer.conn.Read(frame)
+	if tailer.reader == nil {
+		tailer.reader = bufio.NewReaderSize(tailer.conn, l.frameSize)
+	}
+	var frame []byte
+	var err error
+	if tailer.framed {
+		frame, err = readLengthPrefixed(tailer.reader, l.maxMessageSize)
+	} else if frame, err = readLine(tailer.reader, l.maxMessageSize); err == nil {
+		// the decoder splits the lines again, e.g. to aggregate the multiline events
+		frame = append(frame, '\n')
+	}
 	if err != nil {
 		l.source.Status.Error(err)
 		go l.stopTailer(tailer)
 		return nil, err
 	}
-	return frame[:n], nil
+	return frame, nil
 }
 
 // startTailer creates and starts a new tailer that reads from the connection.
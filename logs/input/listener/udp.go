@@ -8,12 +8,17 @@
 package listener
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"sync"
+	"time"
 
 	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
 	"flashcat.cloud/categraf/logs/pipeline"
+	"flashcat.cloud/categraf/logs/processor"
 )
 
 // The UDP listener is limited by the size of its read buffer,
@@ -27,12 +32,17 @@ import (
 // sending: |MSG|
 // would result in sending TRUNC(|F1|+|F2|) to the logs-backend.
 
-// A UDPListener opens a new UDP connection, keeps it alive and delegates the read operations to a tailer.
+// A UDPListener reads the datagrams of the clients, a datagram holds the lines of the newline
+// framing or the messages of the length_prefix framing. The clients can't be slowed down, the
+// messages received while the pipeline is full are dropped and counted in logs_dropped_total.
 type UDPListener struct {
 	pipelineProvider pipeline.Provider
 	source           *logsconfig.LogSource
 	frameSize        int
-	tailer           *Tailer
+	maxMessageSize   int
+	conn             *net.UDPConn
+	done             chan struct{}
+	wg               sync.WaitGroup
 }
 
 // NewUDPListener returns an initialized UDPListener
@@ -41,41 +51,40 @@ func NewUDPListener(pipelineProvider pipeline.Provider, source *logsconfig.LogSo
 		pipelineProvider: pipelineProvider,
 		source:           source,
 		frameSize:        frameSize,
+		maxMessageSize:   maxMessageSize(source),
+		done:             make(chan struct{}),
 	}
 }
 
-// Start opens a new UDP connection and starts a tailer.
+// Start opens a new UDP connection and reads its datagrams.
 func (l *UDPListener) Start() {
 	log.Printf("Starting UDP forwarder on port: %d, with read buffer size: %d\n", l.source.Config.Port, l.frameSize)
-	err := l.startNewTailer()
+	conn, err := l.newUDPConnection()
 	if err != nil {
 		log.Printf("Can't start UDP forwarder on port %d: %v\n", l.source.Config.Port, err)
 		l.source.Status.Error(err)
 		return
 	}
+	l.conn = conn
 	l.source.Status.Success()
+	l.wg.Add(1)
+	go l.run()
 }
 
-// Stop stops the tailer.
+// Stop closes the connection.
 func (l *UDPListener) Stop() {
 	log.Printf("Stopping UDP forwarder on port: %d\n", l.source.Config.Port)
-	l.tailer.Stop()
-}
-
-// startNewTailer starts a new Tailer
-func (l *UDPListener) startNewTailer() error {
-	conn, err := l.newUDPConnection()
-	if err != nil {
-		return err
+	if l.conn == nil {
+		return
 	}
-	l.tailer = NewTailer(l.source, conn, l.pipelineProvider.NextPipelineChan(), l.read)
-	l.tailer.Start()
-	return nil
+	close(l.done)
+	l.conn.Close()
+	l.wg.Wait()
 }
 
 // newUDPConnection returns a new UDP connection,
 // returns an error if the creation failed.
-func (l *UDPListener) newUDPConnection() (net.Conn, error) {
+func (l *UDPListener) newUDPConnection() (*net.UDPConn, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", l.source.Config.Port))
 	if err != nil {
 		return nil, err
@@ -83,39 +92,38 @@ func (l *UDPListener) newUDPConnection() (net.Conn, error) {
 	return net.ListenUDP("udp", udpAddr)
 }
 
-// read reads data from the tailer connection, returns an error if it failed and reset the tailer.
-func (l *UDPListener) read(tailer *Tailer) ([]byte, error) {
-	frame := make([]byte, l.frameSize+1)
-	n, err := tailer.conn.Read(frame)
-	switch {
-	case err != nil && isClosedConnError(err):
-		return nil, err
-	case err != nil:
-		go l.resetTailer()
-		return nil, err
-	default:
-		// make sure all logs are separated by line feeds, otherwise they don't get properly split downstream
-		if n > l.frameSize {
-			// the message is bigger than the length of the read buffer,
-			// the trailing part of the content will be dropped.
-			frame[l.frameSize] = '\n'
-		} else if n > 0 && frame[n-1] != '\n' {
-			frame[n] = '\n'
-			n++
+func (l *UDPListener) run() {
+	defer l.wg.Done()
+	outputChan := l.pipelineProvider.NextPipelineChan()
+	prefixed := lengthPrefixed(l.source)
+	datagram := make([]byte, l.frameSize)
+	for {
+		n, addr, err := l.conn.ReadFromUDP(datagram)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("W! failed to read udp datagram on port %d: %v", l.source.Config.Port, err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-l.done:
+				return
+			}
+		}
+		l.source.BytesRead.Add(int64(n))
+		tags := remoteTags(addr)
+		for _, frame := range splitDatagram(datagram[:n], prefixed, l.maxMessageSize) {
+			l.forward(outputChan, newMessage(l.source, frame, tags))
 		}
-		return frame[:n], nil
 	}
 }
 
-// resetTailer creates a new tailer.
-func (l *UDPListener) resetTailer() {
-	log.Printf("Resetting the UDP connection on port: %d\n", l.source.Config.Port)
-	l.tailer.Stop()
-	err := l.startNewTailer()
-	if err != nil {
-		log.Printf("Could not reset the UDP connection on port %d: %v\n", l.source.Config.Port, err)
-		l.source.Status.Error(err)
-		return
+// forward sends the message to the pipeline unless it is full
+func (l *UDPListener) forward(outputChan chan *message.Message, msg *message.Message) {
+	select {
+	case outputChan <- msg:
+	default:
+		processor.CountDropped(l.source.Name, "pipeline_full")
 	}
-	l.source.Status.Success()
 }
@@ -0,0 +1,107 @@
+//go:build !no_logs
+
+package syslog
+
+import (
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+	"flashcat.cloud/categraf/logs/pipeline"
+	"flashcat.cloud/categraf/logs/restart"
+)
+
+const (
+	// defaultMaxMessageSize is the max size of a message, RFC5425 requires at least 8KB
+	defaultMaxMessageSize = 64 * 1024
+	// defaultReadTimeout closes the tcp connections idle for longer, a client stuck in the
+	// middle of a message doesn't hold its connection forever
+	defaultReadTimeout = 5 * time.Minute
+
+	parseErrorTag = "parse_error=true"
+	truncatedTag  = "syslog_truncated=true"
+)
+
+// Launcher starts a syslog listener per syslog source
+type Launcher struct {
+	pipelineProvider pipeline.Provider
+	sources          chan *logsconfig.LogSource
+	listeners        []restart.Restartable
+	stop             chan struct{}
+}
+
+// NewLauncher returns an initialized Launcher
+func NewLauncher(sources *logsconfig.LogSources, pipelineProvider pipeline.Provider) *Launcher {
+	return &Launcher{
+		pipelineProvider: pipelineProvider,
+		sources:          sources.GetAddedForType(logsconfig.SyslogType),
+		stop:             make(chan struct{}),
+	}
+}
+
+// Start starts the launcher
+func (l *Launcher) Start() {
+	go l.run()
+}
+
+func (l *Launcher) run() {
+	for {
+		select {
+		case source := <-l.sources:
+			var listener restart.Restartable
+			if source.Config.Protocol == logsconfig.TCPType {
+				listener = NewTCPListener(l.pipelineProvider, source)
+			} else {
+				listener = NewUDPListener(l.pipelineProvider, source)
+			}
+			listener.Start()
+			l.listeners = append(l.listeners, listener)
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Stop stops all the listeners
+func (l *Launcher) Stop() {
+	l.stop <- struct{}{}
+	stopper := restart.NewParallelStopper()
+	for _, listener := range l.listeners {
+		stopper.Add(listener)
+	}
+	stopper.Stop()
+}
+
+func maxMessageSize(source *logsconfig.LogSource) int {
+	if source.Config.MaxMessageSize > 0 {
+		return source.Config.MaxMessageSize
+	}
+	return defaultMaxMessageSize
+}
+
+// newMessage returns the message of a syslog frame, the frames which can't be parsed are
+// forwarded as is with the parse_error tag
+func newMessage(source *logsconfig.LogSource, frame []byte, truncated bool) *message.Message {
+	now := time.Now()
+	origin := message.NewOrigin(source)
+	m, err := parse(frame, now)
+	if err != nil {
+		tags := []string{parseErrorTag}
+		if truncated {
+			tags = append(tags, truncatedTag)
+		}
+		origin.SetTags(tags)
+		return message.NewMessage(frame, origin, message.StatusInfo, now.UnixNano())
+	}
+
+	tags := m.tags()
+	if truncated {
+		tags = append(tags, truncatedTag)
+	}
+	origin.SetTags(tags)
+	msg := message.NewMessage(m.content, origin, m.status(), now.UnixNano())
+	if !m.timestamp.IsZero() {
+		msg.Timestamp = m.timestamp.UTC()
+	}
+	return msg
+}
@@ -0,0 +1,271 @@
+//go:build !no_logs
+
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// syslogMessage is a parsed RFC3164 or RFC5424 message, the empty fields were either
+// missing or set to the nil value "-", the content starts with the structured data if any
+type syslogMessage struct {
+	facility  int
+	severity  int
+	timestamp time.Time
+	hostname  string
+	appname   string
+	procid    string
+	msgid     string
+	content   []byte
+}
+
+var (
+	errNoPriority = errors.New("missing or invalid priority")
+	nilValue      = []byte("-")
+	utf8BOM       = []byte("\xef\xbb\xbf")
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var severityStatuses = []string{
+	message.StatusEmergency,
+	message.StatusAlert,
+	message.StatusCritical,
+	message.StatusError,
+	message.StatusWarning,
+	message.StatusNotice,
+	message.StatusInfo,
+	message.StatusDebug,
+}
+
+// parse parses a message in either format, the format being told by the version 1 following
+// the priority of RFC5424, e.g.
+// <34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed
+// <34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed
+func parse(data []byte, now time.Time) (*syslogMessage, error) {
+	pri, rest, err := parsePriority(data)
+	if err != nil {
+		return nil, err
+	}
+	m := &syslogMessage{facility: pri / 8, severity: pri % 8}
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		return m, m.parse5424(rest[2:])
+	}
+	m.parse3164(rest, now)
+	return m, nil
+}
+
+func parsePriority(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, errNoPriority
+	}
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, nil, errNoPriority
+	}
+	pri, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, nil, errNoPriority
+	}
+	return pri, data[end+1:], nil
+}
+
+// parse5424 parses TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func (m *syslogMessage) parse5424(data []byte) error {
+	fields := make([][]byte, 5)
+	for i := range fields {
+		idx := bytes.IndexByte(data, ' ')
+		if idx <= 0 {
+			return fmt.Errorf("truncated RFC5424 header")
+		}
+		fields[i], data = data[:idx], data[idx+1:]
+	}
+	if !bytes.Equal(fields[0], nilValue) {
+		ts, err := time.Parse(time.RFC3339Nano, string(fields[0]))
+		if err != nil {
+			return fmt.Errorf("invalid RFC5424 timestamp: %v", err)
+		}
+		m.timestamp = ts
+	}
+	m.hostname = nilOrString(fields[1])
+	m.appname = nilOrString(fields[2])
+	m.procid = nilOrString(fields[3])
+	m.msgid = nilOrString(fields[4])
+
+	sd, rest, err := splitStructuredData(data)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 && rest[0] == ' ' {
+		rest = rest[1:]
+	}
+	rest = bytes.TrimPrefix(rest, utf8BOM)
+	if bytes.Equal(sd, nilValue) {
+		m.content = rest
+	} else {
+		// the structured data is kept in the content, its params don't fit in the tags
+		m.content = bytes.Join([][]byte{sd, rest}, []byte(" "))
+	}
+	return nil
+}
+
+// splitStructuredData returns the structured data, either "-" or a sequence of
+// [id param="value" ...] elements whose values escape '"', '\' and ']' with '\'
+func splitStructuredData(data []byte) ([]byte, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("missing RFC5424 structured data")
+	}
+	if data[0] == '-' {
+		return data[:1], data[1:], nil
+	}
+	i := 0
+	for i < len(data) && data[i] == '[' {
+		inValue := false
+		for i++; ; i++ {
+			if i >= len(data) {
+				return nil, nil, fmt.Errorf("unterminated RFC5424 structured data")
+			}
+			c := data[i]
+			if inValue && c == '\\' {
+				i++
+				continue
+			}
+			if c == '"' {
+				inValue = !inValue
+			} else if c == ']' && !inValue {
+				i++
+				break
+			}
+		}
+	}
+	if i == 0 {
+		return nil, nil, fmt.Errorf("invalid RFC5424 structured data")
+	}
+	return data[:i], data[i:], nil
+}
+
+func nilOrString(b []byte) string {
+	if bytes.Equal(b, nilValue) {
+		return ""
+	}
+	return string(b)
+}
+
+const maxTagLen = 64
+
+// rfc3164 timestamps, some devices send the year or a RFC3339 timestamp
+var rfc3164Layouts = []string{time.StampMilli, "Jan _2 2006 15:04:05", time.Stamp}
+
+// parse3164 parses TIMESTAMP HOSTNAME TAG[PID]: MSG. The format loosely followed by the
+// devices is parsed as far as possible, the remaining data being the content.
+func (m *syslogMessage) parse3164(data []byte, now time.Time) {
+	rest := data
+	if ts, n, ok := parse3164Timestamp(data, now); ok {
+		m.timestamp = ts
+		rest = data[n:]
+		if len(rest) > 0 && rest[0] == ' ' {
+			rest = rest[1:]
+		}
+		// the hostname is missing when the next field is already the tag
+		if idx := bytes.IndexByte(rest, ' '); idx > 0 && !bytes.ContainsAny(rest[:idx], ":[") {
+			m.hostname = string(rest[:idx])
+			rest = rest[idx+1:]
+		}
+	}
+
+	// the tag, at most 32 characters in RFC3164 but longer in practice, is followed by [pid] or ':'
+	end := 0
+	for end < len(rest) && end < maxTagLen && isTagChar(rest[end]) {
+		end++
+	}
+	if end > 0 && end < len(rest) && (rest[end] == ':' || rest[end] == '[') {
+		m.appname = string(rest[:end])
+		rest = rest[end:]
+		if rest[0] == '[' {
+			if idx := bytes.IndexByte(rest, ']'); idx > 0 {
+				m.procid = string(rest[1:idx])
+				rest = rest[idx+1:]
+			}
+		}
+		rest = bytes.TrimPrefix(rest, []byte(":"))
+		if len(rest) > 0 && rest[0] == ' ' {
+			rest = rest[1:]
+		}
+	}
+	m.content = rest
+}
+
+func isTagChar(c byte) bool {
+	return c > ' ' && c != ':' && c != '[' && c != ']' && c < utf8.RuneSelf
+}
+
+// parse3164Timestamp returns the timestamp and its length, the timestamps without year are
+// in the current year unless they would be more than a day in the future, e.g. on new year
+func parse3164Timestamp(data []byte, now time.Time) (time.Time, int, bool) {
+	for _, layout := range rfc3164Layouts {
+		n := len(layout)
+		if len(data) < n {
+			continue
+		}
+		ts, err := time.ParseInLocation(layout, string(data[:n]), now.Location())
+		if err != nil {
+			continue
+		}
+		if ts.Year() == 0 {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.AddDate(0, 0, 1)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+		}
+		return ts, n, true
+	}
+	if idx := bytes.IndexByte(data, ' '); idx > 0 {
+		if ts, err := time.Parse(time.RFC3339Nano, string(data[:idx])); err == nil {
+			return ts, idx, true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// tags returns the tags of the message
+func (m *syslogMessage) tags() []string {
+	tags := []string{
+		"syslog_facility=" + facilityName(m.facility),
+		"syslog_severity=" + severities[m.severity],
+	}
+	for _, t := range []struct{ key, value string }{
+		{"syslog_hostname", m.hostname},
+		{"syslog_appname", m.appname},
+		{"syslog_procid", m.procid},
+		{"syslog_msgid", m.msgid},
+	} {
+		if t.value != "" {
+			tags = append(tags, t.key+"="+t.value)
+		}
+	}
+	return tags
+}
+
+func (m *syslogMessage) status() string {
+	return severityStatuses[m.severity]
+}
+
+func facilityName(f int) string {
+	if f < len(facilities) {
+		return facilities[f]
+	}
+	return strconv.Itoa(f)
+}
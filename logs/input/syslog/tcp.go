@@ -0,0 +1,261 @@
+//go:build !no_logs
+
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+	"flashcat.cloud/categraf/logs/pipeline"
+)
+
+// TCPListener accepts the tcp connections of the syslog clients, optionally over tls, RFC5425.
+// The frames are either octet counted, '<length> <message>', or terminated by a line feed,
+// RFC6587, the framing being told by the first byte of each frame.
+type TCPListener struct {
+	pipelineProvider pipeline.Provider
+	source           *logsconfig.LogSource
+	maxMessageSize   int
+	readTimeout      time.Duration
+	listener         net.Listener
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewTCPListener returns an initialized TCPListener
+func NewTCPListener(pipelineProvider pipeline.Provider, source *logsconfig.LogSource) *TCPListener {
+	readTimeout := defaultReadTimeout
+	if source.Config.IdleTimeout != "" {
+		if d, err := time.ParseDuration(source.Config.IdleTimeout); err != nil {
+			log.Printf("W! invalid idle_timeout of syslog source on port %d, using %s: %v", source.Config.Port, readTimeout, err)
+		} else {
+			readTimeout = d
+		}
+	}
+	return &TCPListener{
+		pipelineProvider: pipelineProvider,
+		source:           source,
+		maxMessageSize:   maxMessageSize(source),
+		readTimeout:      readTimeout,
+		conns:            make(map[net.Conn]struct{}),
+		done:             make(chan struct{}),
+	}
+}
+
+// Start starts accepting the connections
+func (l *TCPListener) Start() {
+	log.Printf("I! Starting syslog tcp listener on port %d, max message size: %d", l.source.Config.Port, l.maxMessageSize)
+	if err := l.listen(); err != nil {
+		log.Printf("E! Can't start syslog tcp listener on port %d: %v", l.source.Config.Port, err)
+		l.source.Status.Error(err)
+		return
+	}
+	l.source.Status.Success()
+	l.wg.Add(1)
+	go l.run()
+}
+
+func (l *TCPListener) listen() error {
+	tlsConfig, err := l.source.Config.ServerConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf(":%d", l.source.Config.Port)
+	if tlsConfig != nil {
+		l.listener, err = tls.Listen("tcp", addr, tlsConfig)
+	} else {
+		l.listener, err = net.Listen("tcp", addr)
+	}
+	return err
+}
+
+// Stop stops accepting the connections and closes the open ones
+func (l *TCPListener) Stop() {
+	log.Printf("I! Stopping syslog tcp listener on port %d", l.source.Config.Port)
+	if l.listener == nil {
+		return
+	}
+	close(l.done)
+	l.listener.Close()
+	l.mu.Lock()
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+}
+
+func (l *TCPListener) run() {
+	defer l.wg.Done()
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("W! failed to accept syslog connection on port %d: %v", l.source.Config.Port, err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-l.done:
+				return
+			}
+		}
+		l.mu.Lock()
+		l.conns[conn] = struct{}{}
+		l.mu.Unlock()
+		l.wg.Add(1)
+		go l.serve(conn)
+	}
+}
+
+// serve reads the frames of the connection until it is closed, the connection is also closed
+// when a frame isn't received within the read timeout
+func (l *TCPListener) serve(conn net.Conn) {
+	remote := conn.RemoteAddr().String()
+	l.source.AddInput(remote)
+	defer func() {
+		conn.Close()
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		l.source.RemoveInput(remote)
+		l.wg.Done()
+	}()
+
+	outputChan := l.pipelineProvider.NextPipelineChan()
+	reader := bufio.NewReaderSize(conn, 16*1024)
+	for {
+		if l.readTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(l.readTimeout)) //nolint:errcheck
+		}
+		frame, truncated, err := l.readFrame(reader)
+		if len(frame) > 0 {
+			l.source.BytesRead.Add(int64(len(frame)))
+			if !l.forward(outputChan, newMessage(l.source, frame, truncated)) {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("W! close syslog connection from %s: %v", remote, err)
+			}
+			return
+		}
+	}
+}
+
+func (l *TCPListener) forward(outputChan chan *message.Message, msg *message.Message) bool {
+	select {
+	case outputChan <- msg:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// readFrame returns the next frame, truncated to the max message size
+func (l *TCPListener) readFrame(reader *bufio.Reader) ([]byte, bool, error) {
+	// skip the line feeds left between the frames
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return nil, false, err
+		}
+		if c != '\n' && c != '\r' && c != 0 {
+			reader.UnreadByte() //nolint:errcheck
+			break
+		}
+	}
+
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, false, err
+	}
+	if first[0] >= '1' && first[0] <= '9' {
+		return l.readOctetCounted(reader)
+	}
+	return l.readLine(reader)
+}
+
+// readOctetCounted reads a '<length> <message>' frame, the octets beyond the max message
+// size are discarded. A frame starting with a digit without a valid length is read as a line.
+func (l *TCPListener) readOctetCounted(reader *bufio.Reader) ([]byte, bool, error) {
+	var length int
+	for digits := 0; ; digits++ {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return nil, false, err
+		}
+		if c == ' ' && digits > 0 {
+			break
+		}
+		if c < '0' || c > '9' || digits >= 10 {
+			reader.UnreadByte() //nolint:errcheck
+			line, truncated, err := l.readLine(reader)
+			prefix := strconv.Itoa(length)
+			if length == 0 {
+				prefix = ""
+			}
+			return append([]byte(prefix), line...), truncated, err
+		}
+		length = length*10 + int(c-'0')
+	}
+
+	size := length
+	truncated := size > l.maxMessageSize
+	if truncated {
+		size = l.maxMessageSize
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, false, err
+	}
+	if truncated {
+		if _, err := reader.Discard(length - size); err != nil {
+			return frame, true, err
+		}
+	}
+	return frame, truncated, nil
+}
+
+// readLine reads a frame terminated by a line feed, the rest of a line longer than the max
+// message size is discarded
+func (l *TCPListener) readLine(reader *bufio.Reader) ([]byte, bool, error) {
+	var frame []byte
+	truncated := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !truncated {
+			if room := l.maxMessageSize - len(frame); len(chunk) > room {
+				frame = append(frame, chunk[:room]...)
+				truncated = true
+			} else {
+				frame = append(frame, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		frame = bytes.TrimRight(frame, "\r\n")
+		if err == io.EOF && len(frame) > 0 {
+			// the last frame of a connection closed by the client
+			return frame, truncated, nil
+		}
+		return frame, truncated, err
+	}
+}
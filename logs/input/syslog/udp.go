@@ -0,0 +1,95 @@
+//go:build !no_logs
+
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/pipeline"
+)
+
+// UDPListener reads one syslog message per datagram, RFC5426
+type UDPListener struct {
+	pipelineProvider pipeline.Provider
+	source           *logsconfig.LogSource
+	maxMessageSize   int
+	conn             net.PacketConn
+	done             chan struct{}
+	wg               sync.WaitGroup
+}
+
+// NewUDPListener returns an initialized UDPListener
+func NewUDPListener(pipelineProvider pipeline.Provider, source *logsconfig.LogSource) *UDPListener {
+	return &UDPListener{
+		pipelineProvider: pipelineProvider,
+		source:           source,
+		maxMessageSize:   maxMessageSize(source),
+		done:             make(chan struct{}),
+	}
+}
+
+// Start starts reading the datagrams
+func (l *UDPListener) Start() {
+	log.Printf("I! Starting syslog udp listener on port %d, max message size: %d", l.source.Config.Port, l.maxMessageSize)
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", l.source.Config.Port))
+	if err != nil {
+		log.Printf("E! Can't start syslog udp listener on port %d: %v", l.source.Config.Port, err)
+		l.source.Status.Error(err)
+		return
+	}
+	l.conn = conn
+	l.source.Status.Success()
+	l.source.AddInput(conn.LocalAddr().String())
+	l.wg.Add(1)
+	go l.run()
+}
+
+// Stop stops the listener
+func (l *UDPListener) Stop() {
+	log.Printf("I! Stopping syslog udp listener on port %d", l.source.Config.Port)
+	if l.conn == nil {
+		return
+	}
+	close(l.done)
+	l.conn.Close()
+	l.wg.Wait()
+	l.source.RemoveInput(l.conn.LocalAddr().String())
+}
+
+func (l *UDPListener) run() {
+	defer l.wg.Done()
+	outputChan := l.pipelineProvider.NextPipelineChan()
+	// the extra byte tells the datagrams larger than the max message size, which are truncated
+	buf := make([]byte, l.maxMessageSize+1)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("W! failed to read syslog datagram on port %d: %v", l.source.Config.Port, err)
+			continue
+		}
+		truncated := n > l.maxMessageSize
+		if truncated {
+			n = l.maxMessageSize
+		}
+		frame := bytes.TrimRight(buf[:n], "\r\n\x00")
+		if len(frame) == 0 {
+			continue
+		}
+		l.source.BytesRead.Add(int64(n))
+		msg := newMessage(l.source, append([]byte(nil), frame...), truncated)
+		select {
+		case outputChan <- msg:
+		case <-l.done:
+			return
+		}
+	}
+}
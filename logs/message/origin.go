@@ -116,6 +116,11 @@ func (o *Origin) SetTags(tags []string) {
 	o.tags = tags
 }
 
+// AddTags appends tags to the tags of the origin.
+func (o *Origin) AddTags(tags ...string) {
+	o.tags = append(o.tags[:len(o.tags):len(o.tags)], tags...)
+}
+
 // SetSource sets the source of the origin.
 func (o *Origin) SetSource(source string) {
 	o.source = source
@@ -9,10 +9,14 @@ package pipeline
 
 import (
 	"context"
+	"log"
+	"path/filepath"
+	"strconv"
 
 	coreconfig "flashcat.cloud/categraf/config"
 	logsconfig "flashcat.cloud/categraf/config/logs"
 	"flashcat.cloud/categraf/logs/client"
+	"flashcat.cloud/categraf/logs/client/elasticsearch"
 	"flashcat.cloud/categraf/logs/client/http"
 	"flashcat.cloud/categraf/logs/client/kafka"
 	"flashcat.cloud/categraf/logs/client/tcp"
@@ -24,13 +28,14 @@ import (
 
 // Pipeline processes and sends messages to the backend
 type Pipeline struct {
-	InputChan chan *message.Message
-	processor *processor.Processor
-	sender    *sender.Sender
+	InputChan  chan *message.Message
+	processor  *processor.Processor
+	diskBuffer *sender.DiskBuffer
+	sender     *sender.Sender
 }
 
 // NewPipeline returns a new Pipeline
-func NewPipeline(outputChan chan *message.Message, processingRules []*logsconfig.ProcessingRule, endpoints *logsconfig.Endpoints, destinationsContext *client.DestinationsContext, diagnosticMessageReceiver diagnostic.MessageReceiver, serverless bool) *Pipeline {
+func NewPipeline(pipelineID int, outputChan chan *message.Message, processingRules []*logsconfig.ProcessingRule, endpoints *logsconfig.Endpoints, destinationsContext *client.DestinationsContext, diagnosticMessageReceiver diagnostic.MessageReceiver, serverless bool) *Pipeline {
 	var (
 		destinations *client.Destinations
 		strategy     sender.Strategy
@@ -55,6 +60,15 @@ func NewPipeline(outputChan chan *message.Message, processingRules []*logsconfig
 		destinations = client.NewDestinations(main, additionals)
 		strategy = sender.StreamStrategy
 		encoder = processor.JSONEncoder
+	case "elasticsearch":
+		main := elasticsearch.NewDestination(endpoints.Main, destinationsContext, endpoints.BatchMaxConcurrentSend)
+		additionals := []client.Destination{}
+		for _, endpoint := range endpoints.Additionals {
+			additionals = append(additionals, elasticsearch.NewDestination(endpoint, destinationsContext, endpoints.BatchMaxConcurrentSend))
+		}
+		destinations = client.NewDestinations(main, additionals)
+		strategy = sender.NewBatchStrategy(sender.NewBulkSerializer(coreconfig.ElasticsearchIndex()), endpoints.BatchWait, endpoints.BatchMaxConcurrentSend, endpoints.BatchMaxSize, endpoints.BatchMaxContentSize, "logs")
+		encoder = processor.ElasticsearchEncoder
 	case "tcp":
 		main := tcp.NewDestination(endpoints.Main, endpoints.UseProto, destinationsContext)
 		additionals := []client.Destination{}
@@ -67,6 +81,22 @@ func NewPipeline(outputChan chan *message.Message, processingRules []*logsconfig
 	}
 
 	senderChan := make(chan *message.Message, coreconfig.ChanSize())
+	// the disk buffer spools the messages of the processor when the sender falls behind
+	var diskBuffer *sender.DiskBuffer
+	processorChan := senderChan
+	if path := coreconfig.LogsDiskBufferPath(); path != "" {
+		bufferChan := make(chan *message.Message, coreconfig.ChanSize())
+		dir := filepath.Join(path, strconv.Itoa(pipelineID))
+		maxBytes := coreconfig.LogsDiskBufferMaxBytes() / int64(coreconfig.NumberOfPipelines())
+		var err error
+		diskBuffer, err = sender.NewDiskBuffer(dir, strconv.Itoa(pipelineID), maxBytes, bufferChan, senderChan, outputChan)
+		if err != nil {
+			log.Println("E! failed to open the logs disk buffer", dir, ", the logs are buffered in memory:", err)
+		} else {
+			processorChan = bufferChan
+		}
+	}
+
 	sender := sender.NewSender(senderChan, outputChan, destinations, strategy)
 
 	if endpoints.UseProto {
@@ -74,24 +104,31 @@ func NewPipeline(outputChan chan *message.Message, processingRules []*logsconfig
 	}
 
 	inputChan := make(chan *message.Message, coreconfig.ChanSize())
-	processor := processor.New(inputChan, senderChan, processingRules, encoder, diagnosticMessageReceiver)
+	processor := processor.New(inputChan, processorChan, processingRules, encoder, diagnosticMessageReceiver)
 
 	return &Pipeline{
-		InputChan: inputChan,
-		processor: processor,
-		sender:    sender,
+		InputChan:  inputChan,
+		processor:  processor,
+		diskBuffer: diskBuffer,
+		sender:     sender,
 	}
 }
 
 // Start launches the pipeline
 func (p *Pipeline) Start() {
 	p.sender.Start()
+	if p.diskBuffer != nil {
+		p.diskBuffer.Start()
+	}
 	p.processor.Start()
 }
 
 // Stop stops the pipeline
 func (p *Pipeline) Stop() {
 	p.processor.Stop()
+	if p.diskBuffer != nil {
+		p.diskBuffer.Stop()
+	}
 	p.sender.Stop()
 }
 
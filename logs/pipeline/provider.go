@@ -9,6 +9,7 @@ package pipeline
 
 import (
 	"context"
+	"hash/fnv"
 	"sync/atomic"
 
 	"flashcat.cloud/categraf/logs/diagnostic"
@@ -25,6 +26,9 @@ type Provider interface {
 	Start()
 	Stop()
 	NextPipelineChan() chan *message.Message
+	// PipelineChanFor returns the input channel of the pipeline of key, the messages of a key
+	// always go through the same pipeline so that their order is kept
+	PipelineChanFor(key string) chan *message.Message
 	// Flush flushes all pipeline contained in this Provider
 	Flush(ctx context.Context)
 }
@@ -74,7 +78,7 @@ func (p *provider) Start() {
 	p.outputChan = p.auditor.Channel()
 
 	for i := 0; i < p.numberOfPipelines; i++ {
-		pipeline := NewPipeline(p.outputChan, p.processingRules, p.endpoints, p.destinationsContext, p.diagnosticMessageReceiver, p.serverless)
+		pipeline := NewPipeline(i, p.outputChan, p.processingRules, p.endpoints, p.destinationsContext, p.diagnosticMessageReceiver, p.serverless)
 		pipeline.Start()
 		p.pipelines = append(p.pipelines, pipeline)
 	}
@@ -104,6 +108,17 @@ func (p *provider) NextPipelineChan() chan *message.Message {
 	return nextPipeline.InputChan
 }
 
+// PipelineChanFor returns the input channel of the pipeline key is hashed onto
+func (p *provider) PipelineChanFor(key string) chan *message.Message {
+	pipelinesLen := len(p.pipelines)
+	if pipelinesLen == 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return p.pipelines[h.Sum32()%uint32(pipelinesLen)].InputChan
+}
+
 // Flush flushes synchronously all the contained pipeline of this provider.
 func (p *provider) Flush(ctx context.Context) {
 	for _, p := range p.pipelines {
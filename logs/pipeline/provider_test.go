@@ -0,0 +1,177 @@
+//go:build !no_logs
+
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	coreconfig "flashcat.cloud/categraf/config"
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/auditor"
+	"flashcat.cloud/categraf/logs/client"
+	"flashcat.cloud/categraf/logs/diagnostic"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// startProvider starts a provider of n pipelines sending the logs to handler
+func startProvider(t testing.TB, n int, rules []*logsconfig.ProcessingRule, handler http.HandlerFunc) (Provider, func()) {
+	coreconfig.Config = &coreconfig.ConfigType{}
+	coreconfig.HostInfo = &coreconfig.HostInfoCache{}
+	server := httptest.NewServer(handler)
+	host, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "http://"), ":")
+	p, _ := strconv.Atoi(port)
+	endpoints := &logsconfig.Endpoints{
+		Main:                logsconfig.Endpoint{Host: host, Port: p},
+		Type:                "http",
+		BatchWait:           time.Second,
+		BatchMaxSize:        1000,
+		BatchMaxContentSize: 1000000,
+	}
+
+	a := auditor.NewNullAuditor()
+	a.Start()
+	ctx := client.NewDestinationsContext()
+	ctx.Start()
+	provider := NewProvider(n, a, &diagnostic.NoopMessageReceiver{}, rules, endpoints, ctx)
+	provider.Start()
+	return provider, func() {
+		ctx.Stop()
+		a.Stop()
+		server.Close()
+	}
+}
+
+func TestProviderKeepsOrderPerSource(t *testing.T) {
+	const sources, lines = 8, 500
+	var (
+		mu       sync.Mutex
+		received = map[string][]int{}
+	)
+	provider, stop := startProvider(t, 4, nil, func(w http.ResponseWriter, r *http.Request) {
+		var payloads []struct {
+			Message string `json:"message"`
+			Source  string `json:"fcsource"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payloads); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range payloads {
+			n, _ := strconv.Atoi(p.Message)
+			received[p.Source] = append(received[p.Source], n)
+		}
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < sources; i++ {
+		name := fmt.Sprintf("source%d", i)
+		source := logsconfig.NewLogSource(name, &logsconfig.LogsConfig{Source: name})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < lines; n++ {
+				provider.PipelineChanFor(name) <- message.NewMessageWithSource([]byte(strconv.Itoa(n)), message.StatusInfo, source, 0)
+			}
+		}()
+	}
+	wg.Wait()
+	// stopping the provider flushes the messages of all the pipelines
+	provider.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != sources {
+		t.Fatalf("expected the logs of %d sources, got %d", sources, len(received))
+	}
+	for name, ns := range received {
+		if len(ns) != lines {
+			t.Fatalf("%s: expected %d lines, got %d", name, lines, len(ns))
+		}
+		for i, n := range ns {
+			if n != i {
+				t.Fatalf("%s: line %d received at position %d", name, n, i)
+			}
+		}
+	}
+}
+
+func TestPipelineChanFor(t *testing.T) {
+	p := &provider{}
+	if p.PipelineChanFor("a") != nil {
+		t.Fatal("expected no channel without pipelines")
+	}
+	for i := 0; i < 4; i++ {
+		p.pipelines = append(p.pipelines, &Pipeline{InputChan: make(chan *message.Message)})
+	}
+	used := map[chan *message.Message]bool{}
+	for i := 0; i < 64; i++ {
+		key := fmt.Sprintf("/var/log/app%d.log", i)
+		ch := p.PipelineChanFor(key)
+		if p.PipelineChanFor(key) != ch {
+			t.Fatalf("%s: expected the same pipeline", key)
+		}
+		used[ch] = true
+	}
+	if len(used) != 4 {
+		t.Fatalf("expected the keys spread over the 4 pipelines, got %d", len(used))
+	}
+}
+
+// BenchmarkProvider measures the throughput of the logs of 16 sources with a masking rule,
+// the processing of a source is bound to one pipeline, so the throughput grows with the pipelines
+func BenchmarkProvider(b *testing.B) {
+	const sources = 16
+	rule := &logsconfig.ProcessingRule{Type: logsconfig.MaskSequences, Name: "mask_card", Pattern: `\b(?:\d[ -]?){13,16}\b`, ReplacePlaceholder: "[masked_card]"}
+	rules := []*logsconfig.ProcessingRule{rule}
+	if err := logsconfig.ValidateProcessingRules(rules); err != nil {
+		b.Fatal(err)
+	}
+	if err := logsconfig.CompileProcessingRules(rules); err != nil {
+		b.Fatal(err)
+	}
+	line := []byte("2024-03-09T00:00:00Z INFO payment accepted card=4111 1111 1111 1111 amount=42.00 user=alice id=0123456789")
+
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("pipelines=%d", n), func(b *testing.B) {
+			provider, stop := startProvider(b, n, rules, func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(io.Discard, r.Body)
+			})
+			defer stop()
+
+			b.ResetTimer()
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < sources; i++ {
+				name := fmt.Sprintf("source%d", i)
+				source := logsconfig.NewLogSource(name, &logsconfig.LogsConfig{Source: name})
+				count := b.N / sources
+				if i < b.N%sources {
+					count++
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ch := provider.PipelineChanFor(name)
+					for j := 0; j < count; j++ {
+						ch <- message.NewMessageWithSource(line, message.StatusInfo, source, 0)
+					}
+				}()
+			}
+			wg.Wait()
+			provider.Stop()
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "lines/s")
+		})
+	}
+}
@@ -0,0 +1,82 @@
+//go:build !no_logs
+
+package processor
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// ElasticsearchEncoder is a shared encoder of the elasticsearch documents.
+var ElasticsearchEncoder Encoder = &elasticsearchEncoder{}
+
+// elasticsearchEncoder transforms a message into an elasticsearch document, the tags of the
+// logs are top-level fields, e.g.
+//
+//	{"@timestamp": "2024-03-01T12:00:00.123Z", "message": "...", "status": "info",
+//	 "hostname": "web01", "service": "nginx", "source": "nginx", "env": "prod"}
+type elasticsearchEncoder struct{}
+
+// the fields of the documents, the tags don't override them
+var elasticsearchFields = map[string]struct{}{
+	"@timestamp": {},
+	"message":    {},
+	"status":     {},
+	"hostname":   {},
+	"service":    {},
+	"source":     {},
+	"tags":       {},
+}
+
+// Encode encodes a message into a JSON document.
+func (e *elasticsearchEncoder) Encode(msg *message.Message, redactedMsg []byte) ([]byte, error) {
+	ts := time.Now().UTC()
+	if !msg.Timestamp.IsZero() {
+		ts = msg.Timestamp.UTC()
+	}
+	doc := map[string]interface{}{
+		"@timestamp": ts.Format(time.RFC3339Nano),
+		"message":    toValidUtf8(redactedMsg),
+		"status":     msg.GetStatus(),
+		"hostname":   msg.GetHostname(),
+	}
+	if service := msg.Origin.Service(); service != "" {
+		doc["service"] = service
+	}
+	if source := msg.Origin.Source(); source != "" {
+		doc["source"] = source
+	}
+
+	var tags []string
+	for _, tag := range msg.Origin.Tags() {
+		i := strings.IndexAny(tag, "=:")
+		if i <= 0 {
+			// the tags without value are kept in the tags field
+			tags = append(tags, tag)
+			continue
+		}
+		name := ElasticsearchFieldName(tag[:i])
+		if name == "" {
+			continue
+		}
+		if _, reserved := elasticsearchFields[name]; reserved {
+			continue
+		}
+		doc[name] = tag[i+1:]
+	}
+	if len(tags) > 0 {
+		doc["tags"] = tags
+	}
+	return json.Marshal(doc)
+}
+
+// ElasticsearchFieldName returns a field name accepted by elasticsearch: the dots, which
+// would create objects conflicting with the fields of the same name, are replaced by _ and
+// the leading underscores, reserved to the metadata fields, are removed
+func ElasticsearchFieldName(name string) string {
+	name = strings.ReplaceAll(strings.TrimSpace(name), ".", "_")
+	return strings.TrimLeft(name, "_")
+}
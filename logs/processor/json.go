@@ -9,6 +9,7 @@ package processor
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 
 	"flashcat.cloud/categraf/config"
@@ -62,7 +63,7 @@ func (j *jsonEncoder) Encode(msg *message.Message, redactedMsg []byte) ([]byte,
 	}
 	msgKey := config.Config.Logs.APIKey
 	if config.Config.Logs.SendType == "kafka" {
-		msgKey = msg.GetHostname() + "/" + msg.Origin.GetIdentifier()
+		msgKey = kafkaMsgKey(msg, config.Config.Logs.PartitionKey)
 	}
 
 	return json.Marshal(jsonPayload{
@@ -77,3 +78,28 @@ func (j *jsonEncoder) Encode(msg *message.Message, redactedMsg []byte) ([]byte,
 		MsgKey:    msgKey,
 	})
 }
+
+// kafkaMsgKey returns the key of the kafka message, the messages with the same key go to the
+// same partition. The key is the hostname, the source or the value of a tag, e.g. tag:service,
+// and <hostname>/<identifier> by default or when the selected value is empty.
+func kafkaMsgKey(msg *message.Message, partitionKey string) string {
+	var key string
+	switch {
+	case partitionKey == "hostname":
+		key = msg.GetHostname()
+	case partitionKey == "source":
+		key = msg.Origin.Source()
+	case strings.HasPrefix(partitionKey, "tag:"):
+		name := strings.TrimPrefix(partitionKey, "tag:")
+		for _, tag := range msg.Origin.Tags() {
+			if v, found := strings.CutPrefix(tag, name); found && len(v) > 0 && (v[0] == '=' || v[0] == ':') {
+				key = v[1:]
+				break
+			}
+		}
+	}
+	if key == "" {
+		key = msg.GetHostname() + "/" + msg.Origin.GetIdentifier()
+	}
+	return key
+}
@@ -0,0 +1,155 @@
+//go:build !no_logs
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// statusByLevel maps the usual levels of the JSON logs to the statuses
+var statusByLevel = map[string]string{
+	"emerg":       message.StatusEmergency,
+	"emergency":   message.StatusEmergency,
+	"panic":       message.StatusEmergency,
+	"alert":       message.StatusAlert,
+	"crit":        message.StatusCritical,
+	"critical":    message.StatusCritical,
+	"fatal":       message.StatusCritical,
+	"err":         message.StatusError,
+	"error":       message.StatusError,
+	"warn":        message.StatusWarning,
+	"warning":     message.StatusWarning,
+	"notice":      message.StatusNotice,
+	"info":        message.StatusInfo,
+	"information": message.StatusInfo,
+	"debug":       message.StatusDebug,
+	"trace":       message.StatusDebug,
+}
+
+// parseJSON promotes the fields of the JSON messages of the sources with auto_parse_json to
+// the timestamp, the status, the content and the tags of the message. The messages which
+// are not JSON objects, larger than json_max_size or invalid are left untouched.
+func parseJSON(msg *message.Message) {
+	cfg := msg.Origin.LogSource.Config
+	if !cfg.AutoParseJSON {
+		return
+	}
+	content := bytes.TrimSpace(msg.Content)
+	if len(content) == 0 || content[0] != '{' || len(content) > cfg.GetJSONMaxSize() {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		// not logged, the sources may mix the JSON and the raw lines
+		return
+	}
+
+	if raw, ok := lookupJSON(fields, cfg.TimestampKey); ok {
+		if ts, ok := parseJSONTimestamp(raw); ok {
+			msg.Timestamp = ts.UTC()
+		}
+	}
+	if raw, ok := lookupJSON(fields, cfg.LevelKey); ok {
+		if status, ok := statusByLevel[strings.ToLower(jsonString(raw))]; ok {
+			msg.SetStatus(status)
+		}
+	}
+	var tags []string
+	for _, key := range cfg.TagKeys {
+		if raw, ok := lookupJSON(fields, key); ok {
+			if v := jsonString(raw); v != "" {
+				tags = append(tags, key+"="+v)
+			}
+		}
+	}
+	if len(tags) > 0 {
+		msg.Origin.AddTags(tags...)
+	}
+	if raw, ok := lookupJSON(fields, cfg.MessageKey); ok {
+		msg.Content = []byte(jsonString(raw))
+	}
+}
+
+// lookupJSON returns the field of the key, the keys containing dots are looked up as is,
+// then as the path of the nested objects
+func lookupJSON(fields map[string]json.RawMessage, key string) (json.RawMessage, bool) {
+	if key == "" {
+		return nil, false
+	}
+	if raw, ok := fields[key]; ok {
+		return raw, true
+	}
+	name, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		return nil, false
+	}
+	raw, ok := fields[name]
+	if !ok || len(raw) == 0 || raw[0] != '{' {
+		return nil, false
+	}
+	var children map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &children); err != nil {
+		return nil, false
+	}
+	return lookupJSON(children, rest)
+}
+
+// jsonString returns the string fields unquoted and the other fields as they are
+func jsonString(raw json.RawMessage) string {
+	if len(raw) > 0 && raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+	}
+	if bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+	return string(raw)
+}
+
+// parseJSONTimestamp parses the RFC 3339 timestamps and the unix timestamps in seconds,
+// milliseconds, microseconds or nanoseconds, told apart by their magnitude
+func parseJSONTimestamp(raw json.RawMessage) (time.Time, bool) {
+	s := jsonString(raw)
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f <= 0 {
+		return time.Time{}, false
+	}
+	switch {
+	case f < 1e11:
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	case f < 1e14:
+		return time.UnixMilli(int64(f)), true
+	case f < 1e17:
+		return time.UnixMicro(int64(f)), true
+	default:
+		return time.Unix(0, int64(f)), true
+	}
+}
+
+// JSONTimestamp returns the timestamp of the first of the keys found in the JSON message,
+// e.g. to seek a file to the messages of a time
+func JSONTimestamp(content []byte, keys ...string) (time.Time, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return time.Time{}, false
+	}
+	for _, key := range keys {
+		if raw, ok := lookupJSON(fields, key); ok {
+			return parseJSONTimestamp(raw)
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,230 @@
+//go:build !no_logs
+
+package processor
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+	"flashcat.cloud/categraf/types"
+)
+
+// maxMetricSeries bounds the series of the metric rules, the labels taken from the log lines
+// may have an unbounded number of values
+const maxMetricSeries = 10000
+
+var (
+	globalMetricRules atomic.Pointer[[]*logsconfig.MetricRule]
+	logMetrics        = newMetricsRegistry()
+)
+
+// SetGlobalMetricRules replaces the metric rules applied to all the logs, the rules must be
+// compiled
+func SetGlobalMetricRules(rules []*logsconfig.MetricRule) {
+	globalMetricRules.Store(&rules)
+}
+
+// GatherMetrics returns the current values of the counters and histograms of the metric rules
+func GatherMetrics() []*types.Sample {
+	return logMetrics.gather()
+}
+
+type seriesKey struct {
+	metric string
+	labels string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+type metricsRegistry struct {
+	mu         sync.Mutex
+	labels     map[seriesKey]map[string]string
+	counters   map[seriesKey]float64
+	histograms map[seriesKey]*histogram
+	full       bool
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		labels:     make(map[seriesKey]map[string]string),
+		counters:   make(map[seriesKey]float64),
+		histograms: make(map[seriesKey]*histogram),
+	}
+}
+
+// applyMetricRules updates the metrics of the rules matching the content and returns false
+// when a matching rule drops the line
+func applyMetricRules(msg *message.Message, content []byte) bool {
+	var rules []*logsconfig.MetricRule
+	if global := globalMetricRules.Load(); global != nil {
+		rules = *global
+	}
+	if len(msg.Origin.LogSource.Config.MetricRules) > 0 {
+		rules = append(rules[:len(rules):len(rules)], msg.Origin.LogSource.Config.MetricRules...)
+	}
+
+	forward := true
+	for _, rule := range rules {
+		match := rule.Regex.FindSubmatch(content)
+		if match == nil {
+			continue
+		}
+		if rule.DropAfterMatch {
+			forward = false
+		}
+		labels := ruleLabels(rule, match, msg.Origin)
+		if rule.Type == logsconfig.HistogramMetric {
+			v, err := strconv.ParseFloat(string(match[rule.ValueGroup]), 64)
+			if err != nil {
+				continue
+			}
+			logMetrics.observe(rule, labels, v)
+		} else {
+			logMetrics.inc(rule.Metric, labels)
+		}
+	}
+	return forward
+}
+
+// ruleLabels returns the labels_from of the rule, a capture group of the match first, then
+// the service, the source or a tag of the logs
+func ruleLabels(rule *logsconfig.MetricRule, match [][]byte, origin *message.Origin) map[string]string {
+	labels := make(map[string]string, len(rule.LabelsFrom))
+	for _, name := range rule.LabelsFrom {
+		if idx := rule.Regex.SubexpIndex(name); idx > 0 {
+			if len(match[idx]) > 0 {
+				labels[name] = string(match[idx])
+			}
+			continue
+		}
+		var value string
+		switch name {
+		case "service":
+			value = origin.Service()
+		case "source":
+			value = origin.Source()
+		default:
+			value = tagValue(origin, name)
+		}
+		if value != "" {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+// tagValue returns the value of a key=value or key:value tag
+func tagValue(origin *message.Origin, key string) string {
+	for _, tag := range origin.Tags() {
+		if len(tag) > len(key) && strings.HasPrefix(tag, key) && (tag[len(key)] == '=' || tag[len(key)] == ':') {
+			return tag[len(key)+1:]
+		}
+	}
+	return ""
+}
+
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// series returns the key of the series, false when the series is new and the registry full
+func (r *metricsRegistry) series(metric string, labels map[string]string) (seriesKey, bool) {
+	key := seriesKey{metric: metric, labels: labelsKey(labels)}
+	if _, has := r.labels[key]; has {
+		return key, true
+	}
+	if len(r.labels) >= maxMetricSeries {
+		if !r.full {
+			r.full = true
+			log.Printf("W! the log metric rules reached %d series, the new series are dropped", maxMetricSeries)
+		}
+		return key, false
+	}
+	r.labels[key] = labels
+	return key, true
+}
+
+func (r *metricsRegistry) inc(metric string, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if key, ok := r.series(metric, labels); ok {
+		r.counters[key]++
+	}
+}
+
+func (r *metricsRegistry) observe(rule *logsconfig.MetricRule, labels map[string]string, v float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.series(rule.Metric, labels)
+	if !ok {
+		return
+	}
+	h, has := r.histograms[key]
+	if !has {
+		h = &histogram{buckets: rule.Buckets, counts: make([]uint64, len(rule.Buckets))}
+		r.histograms[key] = h
+	}
+	if idx := sort.SearchFloat64s(h.buckets, v); idx < len(h.counts) {
+		h.counts[idx]++
+	}
+	h.sum += v
+	h.count++
+}
+
+func (r *metricsRegistry) gather() []*types.Sample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	samples := make([]*types.Sample, 0, len(r.counters)+len(r.histograms)*4)
+	for key, v := range r.counters {
+		samples = append(samples, types.NewSample("", key.metric, v, copyLabels(r.labels[key])))
+	}
+	for key, h := range r.histograms {
+		labels := r.labels[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += h.counts[i]
+			samples = append(samples, types.NewSample("", key.metric+"_bucket", cumulative,
+				copyLabels(labels, "le", strconv.FormatFloat(upper, 'f', -1, 64))))
+		}
+		samples = append(samples,
+			types.NewSample("", key.metric+"_bucket", h.count, copyLabels(labels, "le", "+Inf")),
+			types.NewSample("", key.metric+"_sum", h.sum, copyLabels(labels)),
+			types.NewSample("", key.metric+"_count", h.count, copyLabels(labels)),
+		)
+	}
+	return samples
+}
+
+func copyLabels(labels map[string]string, extra ...string) map[string]string {
+	ret := make(map[string]string, len(labels)+len(extra)/2)
+	for k, v := range labels {
+		ret[k] = v
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		ret[extra[i]] = extra[i+1]
+	}
+	return ret
+}
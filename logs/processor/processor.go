@@ -8,14 +8,18 @@
 package processor
 
 import (
+	"bytes"
 	"context"
 	"log"
 	"sync"
+	"time"
 
+	coreconfig "flashcat.cloud/categraf/config"
 	logsconfig "flashcat.cloud/categraf/config/logs"
 	"flashcat.cloud/categraf/logs/diagnostic"
 	"flashcat.cloud/categraf/logs/message"
 	"flashcat.cloud/categraf/logs/util"
+	"flashcat.cloud/categraf/logs/util/cloud"
 )
 
 // A Processor updates messages from an inputChan and pushes
@@ -28,11 +32,13 @@ type Processor struct {
 	done                      chan struct{}
 	diagnosticMessageReceiver diagnostic.MessageReceiver
 	mu                        sync.Mutex
+	// the tags of the host added to every message
+	hostTags []string
 }
 
 // New returns an initialized Processor.
 func New(inputChan, outputChan chan *message.Message, processingRules []*logsconfig.ProcessingRule, encoder Encoder, diagnosticMessageReceiver diagnostic.MessageReceiver) *Processor {
-	return &Processor{
+	p := &Processor{
 		inputChan:                 inputChan,
 		outputChan:                outputChan,
 		processingRules:           processingRules,
@@ -40,6 +46,11 @@ func New(inputChan, outputChan chan *message.Message, processingRules []*logscon
 		done:                      make(chan struct{}),
 		diagnosticMessageReceiver: diagnosticMessageReceiver,
 	}
+	if coreconfig.Config.Logs.IncludeCloudTags {
+		// resolved by the first pipeline, in less than a second out of a cloud
+		p.hostTags = cloud.Tags()
+	}
+	return p
 }
 
 // Start starts the Processor.
@@ -85,7 +96,22 @@ func (p *Processor) run() {
 }
 
 func (p *Processor) processMessage(msg *message.Message) {
+	// the timestamp is parsed out of the raw line, a timestamp_key of a JSON line takes precedence
+	parseTimestamp(msg, time.Now())
+	// the JSON fields are promoted before the processing rules, so that they apply to the message field
+	parseJSON(msg)
+	if len(p.hostTags) > 0 {
+		msg.Origin.AddTags(p.hostTags...)
+	}
 	if shouldProcess, redactedMsg := p.applyRedactingRules(msg); shouldProcess {
+		// the lines counted by a metric rule with drop_after_match aren't forwarded
+		if !applyMetricRules(msg, redactedMsg) {
+			return
+		}
+		// the lines over the rate limit are dropped, not buffered
+		if !rateLimiters.allow(msg, len(redactedMsg)) {
+			return
+		}
 
 		p.diagnosticMessageReceiver.HandleMessage(*msg, redactedMsg)
 
@@ -104,10 +130,16 @@ func (p *Processor) processMessage(msg *message.Message) {
 }
 
 // applyRedactingRules returns given a message if we should process it or not,
-// and a copy of the message with some fields redacted, depending on logsconfig
+// and a copy of the message with some fields redacted, depending on logsconfig.
+// The rules are applied to the aggregated multiline messages, the global rules first,
+// then the rules of the source.
 func (p *Processor) applyRedactingRules(msg *message.Message) (bool, []byte) {
 	content := msg.Content
-	rules := append(p.processingRules, msg.Origin.LogSource.Config.ProcessingRules...)
+	rules := p.processingRules
+	if len(msg.Origin.LogSource.Config.ProcessingRules) > 0 {
+		// the global rules are shared by the sources, never append in place
+		rules = append(rules[:len(rules):len(rules)], msg.Origin.LogSource.Config.ProcessingRules...)
+	}
 	for _, rule := range rules {
 		switch rule.Type {
 		case logsconfig.ExcludeAtMatch:
@@ -119,8 +151,41 @@ func (p *Processor) applyRedactingRules(msg *message.Message) (bool, []byte) {
 				return false, nil
 			}
 		case logsconfig.MaskSequences:
-			content = rule.Regex.ReplaceAll(content, rule.Placeholder)
+			content = maskSequences(rule, content)
 		}
 	}
 	return true, content
 }
+
+// maskSequences replaces the matches of the rule with its placeholder, the placeholder
+// may reference the capture groups as in regexp.Expand. The content is returned as is
+// when nothing matches, otherwise it is copied once.
+func maskSequences(rule *logsconfig.ProcessingRule, content []byte) []byte {
+	if bytes.IndexByte(rule.Placeholder, '$') < 0 {
+		matches := rule.Regex.FindAllIndex(content, -1)
+		if matches == nil {
+			return content
+		}
+		masked := make([]byte, 0, len(content)+len(matches)*len(rule.Placeholder))
+		last := 0
+		for _, m := range matches {
+			masked = append(masked, content[last:m[0]]...)
+			masked = append(masked, rule.Placeholder...)
+			last = m[1]
+		}
+		return append(masked, content[last:]...)
+	}
+
+	matches := rule.Regex.FindAllSubmatchIndex(content, -1)
+	if matches == nil {
+		return content
+	}
+	masked := make([]byte, 0, len(content)+len(matches)*len(rule.Placeholder))
+	last := 0
+	for _, m := range matches {
+		masked = append(masked, content[last:m[0]]...)
+		masked = rule.Regex.Expand(masked, rule.Placeholder, content, m)
+		last = m[1]
+	}
+	return append(masked, content[last:]...)
+}
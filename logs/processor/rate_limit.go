@@ -0,0 +1,154 @@
+//go:build !no_logs
+
+package processor
+
+import (
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+// dropWarnInterval is the minimum delay between the warnings summarizing the dropped lines
+const dropWarnInterval = 30 * time.Second
+
+var (
+	globalRateLimit atomic.Pointer[logsconfig.RateLimit]
+	rateLimiters    = newRateLimiterRegistry()
+
+	logsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "logs_dropped_total",
+		Help: "Number of log lines dropped before being sent.",
+	}, []string{"source", "reason"})
+)
+
+func init() {
+	prometheus.MustRegister(logsDropped)
+}
+
+// CountDropped counts the lines of the source dropped before the processor, e.g. the udp
+// datagrams received while the pipeline is full
+func CountDropped(source, reason string) {
+	logsDropped.WithLabelValues(source, reason).Inc()
+}
+
+// SetGlobalRateLimit replaces the rate limit of the sources without their own rate_limit,
+// nil or zero limits are unlimited
+func SetGlobalRateLimit(limit *logsconfig.RateLimit) {
+	globalRateLimit.Store(limit)
+}
+
+// sourceLimiter holds the token buckets of a source, nil buckets are unlimited
+type sourceLimiter struct {
+	limit logsconfig.RateLimit
+	lines *rate.Limiter
+	bytes *rate.Limiter
+}
+
+func newSourceLimiter(limit logsconfig.RateLimit) *sourceLimiter {
+	l := &sourceLimiter{limit: limit}
+	// the buckets hold a second of logs, so that the bursts shorter than a second pass
+	if limit.LinesPerSecond > 0 {
+		l.lines = rate.NewLimiter(rate.Limit(limit.LinesPerSecond), int(math.Max(1, math.Ceil(limit.LinesPerSecond))))
+	}
+	if limit.BytesPerSecond > 0 {
+		l.bytes = rate.NewLimiter(rate.Limit(limit.BytesPerSecond), int(math.Max(1, math.Ceil(limit.BytesPerSecond))))
+	}
+	return l
+}
+
+// allow takes a line of size bytes from the buckets, the line is allowed when both buckets
+// have enough tokens. The lines larger than the bytes bucket take the whole bucket.
+func (l *sourceLimiter) allow(now time.Time, size int) bool {
+	if l.bytes != nil && size > l.bytes.Burst() {
+		size = l.bytes.Burst()
+	}
+	if l.lines != nil {
+		r := l.lines.ReserveN(now, 1)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			return false
+		}
+		if l.bytes != nil && !l.bytes.AllowN(now, size) {
+			// give back the line token, the line is not sent
+			r.CancelAt(now)
+			return false
+		}
+		return true
+	}
+	return l.bytes == nil || l.bytes.AllowN(now, size)
+}
+
+// rateLimiterRegistry holds the limiters of the sources, shared by the processors of all
+// the pipelines since the lines of a source may be spread over them
+type rateLimiterRegistry struct {
+	mu       sync.Mutex
+	limiters map[string]*sourceLimiter
+	dropped  map[string]uint64
+	lastWarn time.Time
+}
+
+func newRateLimiterRegistry() *rateLimiterRegistry {
+	return &rateLimiterRegistry{
+		limiters: make(map[string]*sourceLimiter),
+		dropped:  make(map[string]uint64),
+		lastWarn: time.Now(),
+	}
+}
+
+// allow returns false when the line exceeds the rate limit of its source
+func (r *rateLimiterRegistry) allow(msg *message.Message, size int) bool {
+	source := msg.Origin.LogSource
+	limit := source.Config.RateLimit
+	if limit == nil {
+		limit = globalRateLimit.Load()
+	}
+	if limit == nil || (limit.LinesPerSecond <= 0 && limit.BytesPerSecond <= 0) {
+		return true
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	l, has := r.limiters[source.Name]
+	if !has || l.limit != *limit {
+		// new source or limit changed by a reload
+		l = newSourceLimiter(*limit)
+		r.limiters[source.Name] = l
+	}
+	r.mu.Unlock()
+
+	if l.allow(now, size) {
+		return true
+	}
+	logsDropped.WithLabelValues(source.Name, "rate_limit").Inc()
+	r.drop(source.Name, now)
+	return false
+}
+
+// drop counts the dropped line and logs a summary of the dropped lines every dropWarnInterval
+func (r *rateLimiterRegistry) drop(source string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dropped[source]++
+	if now.Sub(r.lastWarn) < dropWarnInterval {
+		return
+	}
+	summary := make([]string, 0, len(r.dropped))
+	for name, count := range r.dropped {
+		summary = append(summary, name+"="+strconv.FormatUint(count, 10))
+	}
+	sort.Strings(summary)
+	log.Printf("W! log lines over the rate limit dropped in the last %s: %s", now.Sub(r.lastWarn).Round(time.Second), strings.Join(summary, ", "))
+	r.dropped = make(map[string]uint64)
+	r.lastWarn = now
+}
@@ -0,0 +1,36 @@
+//go:build !no_logs
+
+package processor
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/logs/message"
+)
+
+var timestampParseFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "logs_timestamp_parse_failures_total",
+	Help: "Number of log lines without a timestamp matching the timestamp_regex and timestamp_format of their source, sent with their arrival time.",
+}, []string{"source"})
+
+func init() {
+	prometheus.MustRegister(timestampParseFailures)
+}
+
+// parseTimestamp sets the timestamp of the message to the time of the event parsed out of the
+// line with the timestamp_regex and timestamp_format of the source, the messages which don't
+// match keep their arrival time
+func parseTimestamp(msg *message.Message, now time.Time) {
+	parser := msg.Origin.LogSource.Config.TimestampParser()
+	if parser == nil {
+		return
+	}
+	ts, ok := parser.Parse(msg.Content, now)
+	if !ok {
+		timestampParseFailures.WithLabelValues(msg.Origin.LogSource.Name).Inc()
+		return
+	}
+	msg.Timestamp = ts.UTC()
+}
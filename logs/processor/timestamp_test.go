@@ -0,0 +1,117 @@
+//go:build !no_logs
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	logsconfig "flashcat.cloud/categraf/config/logs"
+	"flashcat.cloud/categraf/logs/message"
+)
+
+func TestTimestampParser(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		pattern, format, timezone, line string
+		want                            time.Time
+	}{
+		// an appliance logging in the local time of Shanghai
+		{`^\S+ \S+`, "%Y-%m-%d %H:%M:%S", "Asia/Shanghai", "2024-03-01 08:00:00 link down",
+			time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{`time=(?P<timestamp>\S+)`, "2006-01-02T15:04:05.999", "UTC", "level=info time=2024-03-01T08:00:00.250 msg=ok",
+			time.Date(2024, 3, 1, 8, 0, 0, 250e6, time.UTC)},
+		{`\[([^\]]+)\]`, "%d/%b/%Y:%H:%M:%S %z", "Asia/Shanghai", `1.2.3.4 - - [01/Mar/2024:08:00:00 +0000] "GET /"`,
+			time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)},
+		{`^\S+`, "%FT%T.%f", "UTC", "2024-03-01T08:00:00.123456 started",
+			time.Date(2024, 3, 1, 8, 0, 0, 123456e3, time.UTC)},
+		// syslog without the year, of the last 12 months
+		{`^\w+ +\d+ \S+`, "%b %e %H:%M:%S", "UTC", "Dec 31 23:59:59 host sshd[1]: accepted",
+			time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{`ts=(\d+)`, "%s", "", "ts=1709280000 done", time.Unix(1709280000, 0)},
+	}
+	for _, c := range cases {
+		p, err := logsconfig.NewTimestampParser(c.pattern, c.format, c.timezone)
+		if err != nil {
+			t.Fatalf("%s: %v", c.format, err)
+		}
+		got, ok := p.Parse([]byte(c.line), now)
+		if !ok || !got.Equal(c.want) {
+			t.Errorf("%s %q: got %v %v, want %v", c.format, c.line, got, ok, c.want)
+		}
+	}
+}
+
+// the local timestamps around the daylight saving time transitions follow the offset of the
+// time of the event, not the offset of the arrival time
+func TestTimestampParserDST(t *testing.T) {
+	p, err := logsconfig.NewTimestampParser(`^\S+ \S+`, "%Y-%m-%d %H:%M:%S", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parse := func(line string) time.Time {
+		ts, ok := p.Parse([]byte(line), time.Now())
+		if !ok {
+			t.Fatalf("failed to parse %s", line)
+		}
+		return ts
+	}
+
+	// spring forward: 02:00 EST is 03:00 EDT
+	before, after := parse("2024-03-10 01:59:59 a"), parse("2024-03-10 03:00:00 b")
+	if !before.Equal(time.Date(2024, 3, 10, 6, 59, 59, 0, time.UTC)) || after.Sub(before) != time.Second {
+		t.Fatalf("unexpected spring forward timestamps %v %v", before.UTC(), after.UTC())
+	}
+	// fall back: 01:30 happens twice, in EDT then in EST
+	ambiguous := parse("2024-11-03 01:30:00 c").UTC()
+	if edt, est := time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC), time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC); !ambiguous.Equal(edt) && !ambiguous.Equal(est) {
+		t.Fatalf("unexpected fall back timestamp %v", ambiguous)
+	}
+	if ts := parse("2024-11-03 02:00:00 d").UTC(); !ts.Equal(time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected timestamp after fall back %v", ts)
+	}
+}
+
+func TestTimestampParserInvalid(t *testing.T) {
+	for _, c := range [][3]string{
+		{`^\S+`, "", ""},
+		{`(unclosed`, "%Y", ""},
+		{`^\S+`, "%Y-%Q", ""},
+		{`^\S+`, "%Y-%m-%d", "Mars/Olympus"},
+	} {
+		if _, err := logsconfig.NewTimestampParser(c[0], c[1], c[2]); err == nil {
+			t.Errorf("%v: expected an error", c)
+		}
+	}
+}
+
+func TestParseTimestampFallback(t *testing.T) {
+	source := logsconfig.NewLogSource("appliance", &logsconfig.LogsConfig{
+		Type:            logsconfig.FileType,
+		Path:            "/var/log/appliance.log",
+		TimestampRegex:  `^\S+ \S+`,
+		TimestampFormat: "%Y-%m-%d %H:%M:%S",
+		Timezone:        "Asia/Shanghai",
+	})
+	if err := source.Config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := message.NewMessageWithSource([]byte("2024-03-01 08:00:00 link down"), message.StatusInfo, source, 0)
+	parseTimestamp(msg, time.Now())
+	if !msg.Timestamp.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected timestamp %v", msg.Timestamp)
+	}
+
+	before := testutil.ToFloat64(timestampParseFailures.WithLabelValues("appliance"))
+	msg = message.NewMessageWithSource([]byte("garbage"), message.StatusInfo, source, 0)
+	parseTimestamp(msg, time.Now())
+	if !msg.Timestamp.IsZero() {
+		t.Fatalf("expected the arrival time, got %v", msg.Timestamp)
+	}
+	if n := testutil.ToFloat64(timestampParseFailures.WithLabelValues("appliance")) - before; n != 1 {
+		t.Fatalf("expected 1 parse failure, got %v", n)
+	}
+}
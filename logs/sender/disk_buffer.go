@@ -0,0 +1,545 @@
+//go:build !no_logs
+
+package sender
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/logs/message"
+)
+
+const (
+	segmentSuffix      = ".seg"
+	checkpointFile     = "checkpoint"
+	maxSegmentSize     = 32 * 1024 * 1024
+	recordHeaderSize   = 8
+	recordMetadataSize = 16
+	checkpointInterval = time.Second
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errCorruptedRecord = errors.New("corrupted record")
+)
+
+// DiskBuffer sits between the processor and the sender of a pipeline. The messages are
+// handed to the sender while it keeps up, the messages overflowing its channel are appended
+// to the segment files of dir and replayed in order once the sender recovers, the new
+// messages are spooled as long as the spooled ones are not all replayed. The spooled
+// messages are acknowledged to the auditor once written, the segments left by a previous
+// run are replayed before the new messages.
+//
+// A record is framed as its length and its CRC-32C followed by the ingestion timestamp,
+// the timestamp and the encoded content of the message. The replay stops at the first
+// corrupted record of a segment and goes on with the next segment.
+type DiskBuffer struct {
+	dir         string
+	name        string
+	maxBytes    int64
+	segmentSize int64
+
+	inputChan   chan *message.Message
+	outputChan  chan *message.Message
+	auditorChan chan *message.Message
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	segments []*segment
+	writer   *os.File
+	nextSeq  uint64
+	size     int64
+	pending  int
+	stopping bool
+	stopped  bool
+
+	// ingestion timestamp of the record being replayed
+	replaying atomic.Int64
+	corrupted atomic.Uint64
+
+	// the replay position persisted in the checkpoint
+	reader         *bufio.Reader
+	readerFile     *os.File
+	readerSeg      *segment
+	lastCheckpoint time.Time
+
+	stop       chan struct{}
+	writerDone chan struct{}
+	readerDone chan struct{}
+}
+
+type segment struct {
+	seq  uint64
+	path string
+	size int64
+	// offset of the first record to replay
+	start   int64
+	offset  int64
+	records int
+	read    int
+}
+
+// NewDiskBuffer returns a disk buffer reading inputChan and writing outputChan, the sender
+// channel. The spooled messages are acknowledged on auditorChan
+func NewDiskBuffer(dir, name string, maxBytes int64, inputChan, outputChan, auditorChan chan *message.Message) (*DiskBuffer, error) {
+	segmentSize := maxBytes / 4
+	if segmentSize > maxSegmentSize {
+		segmentSize = maxSegmentSize
+	}
+	b := &DiskBuffer{
+		dir:         dir,
+		name:        name,
+		maxBytes:    maxBytes,
+		segmentSize: segmentSize,
+		inputChan:   inputChan,
+		outputChan:  outputChan,
+		auditorChan: auditorChan,
+		stop:        make(chan struct{}),
+		writerDone:  make(chan struct{}),
+		readerDone:  make(chan struct{}),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	if b.pending > 0 {
+		log.Println("I! replaying", b.pending, "logs left in the disk buffer", dir)
+	}
+	return b, nil
+}
+
+// Start starts the disk buffer
+func (b *DiskBuffer) Start() {
+	diskBuffers.add(b)
+	go b.write()
+	go b.replay()
+}
+
+// Stop stops the disk buffer after spooling the messages of inputChan, the messages
+// not replayed yet are kept on the disk for the next start
+func (b *DiskBuffer) Stop() {
+	b.mu.Lock()
+	b.stopping = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	close(b.inputChan)
+	<-b.writerDone
+
+	b.mu.Lock()
+	b.stopped = true
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	close(b.stop)
+	<-b.readerDone
+
+	b.mu.Lock()
+	if b.writer != nil {
+		b.writer.Close()
+	}
+	b.mu.Unlock()
+	diskBuffers.remove(b)
+}
+
+// load reads the segments and the checkpoint left by a previous run, the new records
+// are always appended to a new segment
+func (b *DiskBuffer) load() error {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+	cpSeq, cpOffset := b.readCheckpoint()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(b.dir, name)
+		if seq < cpSeq {
+			// replayed before the checkpoint was written
+			os.Remove(path)
+			continue
+		}
+		seg := &segment{seq: seq, path: path}
+		if seq == cpSeq {
+			seg.start = cpOffset
+		}
+		if err := scanSegment(seg); err != nil {
+			return err
+		}
+		b.segments = append(b.segments, seg)
+	}
+	sort.Slice(b.segments, func(i, j int) bool { return b.segments[i].seq < b.segments[j].seq })
+	for _, seg := range b.segments {
+		b.size += seg.size
+		b.pending += seg.records
+		seg.offset = seg.start
+		if seg.seq >= b.nextSeq {
+			b.nextSeq = seg.seq + 1
+		}
+	}
+	return b.rotate()
+}
+
+// scanSegment counts the valid records of seg after its start
+func scanSegment(seg *segment) error {
+	f, err := os.Open(seg.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	seg.size = info.Size()
+	if seg.start > seg.size {
+		seg.start = seg.size
+	}
+	if _, err := f.Seek(seg.start, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for {
+		_, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Println("W! disk buffer segment", seg.path, "is corrupted after", seg.records, "records:", err)
+			return nil
+		}
+		seg.records++
+	}
+}
+
+// rotate starts a new segment for the next records
+func (b *DiskBuffer) rotate() error {
+	if b.writer != nil {
+		b.writer.Close()
+		b.writer = nil
+	}
+	seg := &segment{seq: b.nextSeq, path: filepath.Join(b.dir, fmt.Sprintf("%020d%s", b.nextSeq, segmentSuffix))}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	b.nextSeq++
+	b.writer = f
+	b.segments = append(b.segments, seg)
+	return nil
+}
+
+// write hands the messages to the sender or spools them
+func (b *DiskBuffer) write() {
+	defer close(b.writerDone)
+	for m := range b.inputChan {
+		b.mu.Lock()
+		if b.pending == 0 {
+			select {
+			case b.outputChan <- m:
+				b.mu.Unlock()
+				continue
+			default:
+			}
+		}
+		err := b.spool(m)
+		b.mu.Unlock()
+		if err != nil {
+			log.Println("E! failed to write disk buffer", b.dir, ", dropped the log:", err)
+		}
+		if m.Origin != nil {
+			b.auditorChan <- m
+		}
+	}
+}
+
+// spool appends m to the last segment, it waits while the buffer is full and not
+// stopping, the caller must hold b.mu
+func (b *DiskBuffer) spool(m *message.Message) error {
+	record := encodeRecord(m)
+	for b.size+int64(len(record)) > b.maxBytes && b.pending > 0 && !b.stopping {
+		b.cond.Wait()
+	}
+	last := b.segments[len(b.segments)-1]
+	// the replayed segments are removed by the replay, rotation frees them when all are replayed
+	if b.writer == nil || last.size >= b.segmentSize || (b.pending == 0 && last.size > 0) {
+		if err := b.rotate(); err != nil {
+			return err
+		}
+		last = b.segments[len(b.segments)-1]
+	}
+	n, err := b.writer.Write(record)
+	last.size += int64(n)
+	b.size += int64(n)
+	if err != nil {
+		// the partial record is skipped by the replay of the next segments
+		b.rotate()
+		return err
+	}
+	last.records++
+	b.pending++
+	b.cond.Broadcast()
+	return nil
+}
+
+// replay sends the spooled records to the sender in order and removes the replayed segments
+func (b *DiskBuffer) replay() {
+	defer close(b.readerDone)
+	defer func() {
+		b.mu.Lock()
+		b.saveCheckpoint()
+		b.mu.Unlock()
+		if b.readerFile != nil {
+			b.readerFile.Close()
+		}
+	}()
+	for {
+		b.mu.Lock()
+		for b.pending == 0 && !b.removable() && !b.stopped {
+			b.replaying.Store(0)
+			b.cond.Wait()
+		}
+		if b.stopped {
+			b.mu.Unlock()
+			return
+		}
+		seg := b.segments[0]
+		if seg.read == seg.records {
+			if b.removable() {
+				b.removeHead()
+			}
+			b.mu.Unlock()
+			continue
+		}
+		b.mu.Unlock()
+
+		m, err := b.readNext(seg)
+		if err != nil {
+			log.Println("E! failed to replay disk buffer segment", seg.path, ", skipped", seg.records-seg.read, "records:", err)
+			b.mu.Lock()
+			b.corrupted.Add(uint64(seg.records - seg.read))
+			b.pending -= seg.records - seg.read
+			seg.read = seg.records
+			if seg == b.segments[len(b.segments)-1] {
+				// the next records are appended to a new segment
+				if err := b.rotate(); err != nil {
+					log.Println("E! failed to create disk buffer segment:", err)
+				}
+			}
+			b.mu.Unlock()
+			continue
+		}
+		b.replaying.Store(m.IngestionTimestamp)
+		select {
+		case b.outputChan <- m:
+		case <-b.stop:
+			// the record is replayed again by the next start
+			return
+		}
+
+		b.mu.Lock()
+		seg.read++
+		seg.offset += int64(recordHeaderSize + recordMetadataSize + len(m.Content))
+		b.pending--
+		if time.Since(b.lastCheckpoint) >= checkpointInterval {
+			b.saveCheckpoint()
+		}
+		b.mu.Unlock()
+	}
+}
+
+// removable reports whether the first segment is replayed and no longer written
+func (b *DiskBuffer) removable() bool {
+	return len(b.segments) > 1 && b.segments[0].read == b.segments[0].records
+}
+
+// removeHead removes the first segment, the caller must hold b.mu
+func (b *DiskBuffer) removeHead() {
+	seg := b.segments[0]
+	if b.readerSeg == seg {
+		b.readerFile.Close()
+		b.readerFile, b.reader, b.readerSeg = nil, nil, nil
+	}
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		log.Println("W! failed to remove disk buffer segment", seg.path, ":", err)
+	}
+	b.segments = b.segments[1:]
+	b.size -= seg.size
+	b.saveCheckpoint()
+	b.cond.Broadcast()
+}
+
+// readNext reads the next record of seg, only the complete records are read
+func (b *DiskBuffer) readNext(seg *segment) (*message.Message, error) {
+	if b.readerSeg != seg {
+		if b.readerFile != nil {
+			b.readerFile.Close()
+			b.readerFile, b.reader, b.readerSeg = nil, nil, nil
+		}
+		f, err := os.Open(seg.path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Seek(seg.offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		b.readerFile, b.reader, b.readerSeg = f, bufio.NewReader(f), seg
+	}
+	return readRecord(b.reader)
+}
+
+// readCheckpoint returns the segment and the offset of the next record to replay
+func (b *DiskBuffer) readCheckpoint() (uint64, int64) {
+	content, err := os.ReadFile(filepath.Join(b.dir, checkpointFile))
+	if err != nil {
+		return 0, 0
+	}
+	var seq uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(content), "%d %d", &seq, &offset); err != nil {
+		log.Println("W! ignored the invalid disk buffer checkpoint", filepath.Join(b.dir, checkpointFile))
+		return 0, 0
+	}
+	return seq, offset
+}
+
+// saveCheckpoint persists the replay position, a crash replays the records sent after
+// the last checkpoint again. The caller must hold b.mu
+func (b *DiskBuffer) saveCheckpoint() {
+	b.lastCheckpoint = time.Now()
+	seg := b.segments[0]
+	path := filepath.Join(b.dir, checkpointFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", seg.seq, seg.offset)), 0644); err != nil {
+		log.Println("W! failed to write disk buffer checkpoint:", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Println("W! failed to write disk buffer checkpoint:", err)
+	}
+}
+
+func encodeRecord(m *message.Message) []byte {
+	record := make([]byte, recordHeaderSize+recordMetadataSize+len(m.Content))
+	payload := record[recordHeaderSize:]
+	binary.BigEndian.PutUint64(payload[0:8], uint64(m.IngestionTimestamp))
+	var ts int64
+	if !m.Timestamp.IsZero() {
+		ts = m.Timestamp.UnixNano()
+	}
+	binary.BigEndian.PutUint64(payload[8:16], uint64(ts))
+	copy(payload[recordMetadataSize:], m.Content)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, crcTable))
+	return record
+}
+
+// readRecord returns io.EOF at the end of the segment
+func readRecord(r io.Reader) (*message.Message, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errCorruptedRecord
+		}
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length < recordMetadataSize || length > maxSegmentSize {
+		return nil, errCorruptedRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errCorruptedRecord
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errCorruptedRecord
+	}
+	m := &message.Message{
+		Content:            payload[recordMetadataSize:],
+		IngestionTimestamp: int64(binary.BigEndian.Uint64(payload[0:8])),
+	}
+	if ts := int64(binary.BigEndian.Uint64(payload[8:16])); ts != 0 {
+		m.Timestamp = time.Unix(0, ts).UTC()
+	}
+	return m, nil
+}
+
+// diskBufferSet exposes the size and the replay lag of the running disk buffers
+type diskBufferSet struct {
+	mu      sync.Mutex
+	buffers map[*DiskBuffer]struct{}
+}
+
+var (
+	diskBuffers = &diskBufferSet{buffers: make(map[*DiskBuffer]struct{})}
+
+	diskBufferSizeDesc      = prometheus.NewDesc("logs_disk_buffer_size_bytes", "Size of the segments of the logs disk buffer.", []string{"pipeline"}, nil)
+	diskBufferRecordsDesc   = prometheus.NewDesc("logs_disk_buffer_records", "Logs spooled to the disk buffer and not replayed yet.", []string{"pipeline"}, nil)
+	diskBufferLagDesc       = prometheus.NewDesc("logs_disk_buffer_replay_lag_seconds", "Age of the log being replayed from the disk buffer, 0 when nothing is spooled.", []string{"pipeline"}, nil)
+	diskBufferCorruptedDesc = prometheus.NewDesc("logs_disk_buffer_corrupted_records_total", "Spooled logs skipped because their segment is corrupted.", []string{"pipeline"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(diskBuffers)
+}
+
+func (s *diskBufferSet) add(b *DiskBuffer) {
+	s.mu.Lock()
+	s.buffers[b] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *diskBufferSet) remove(b *DiskBuffer) {
+	s.mu.Lock()
+	delete(s.buffers, b)
+	s.mu.Unlock()
+}
+
+func (s *diskBufferSet) Describe(ch chan<- *prometheus.Desc) {
+	ch <- diskBufferSizeDesc
+	ch <- diskBufferRecordsDesc
+	ch <- diskBufferLagDesc
+	ch <- diskBufferCorruptedDesc
+}
+
+func (s *diskBufferSet) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for b := range s.buffers {
+		b.mu.Lock()
+		size, pending := b.size, b.pending
+		b.mu.Unlock()
+		lag := 0.0
+		if ts := b.replaying.Load(); pending > 0 && ts > 0 {
+			lag = time.Since(time.Unix(0, ts)).Seconds()
+		}
+		ch <- prometheus.MustNewConstMetric(diskBufferSizeDesc, prometheus.GaugeValue, float64(size), b.name)
+		ch <- prometheus.MustNewConstMetric(diskBufferRecordsDesc, prometheus.GaugeValue, float64(pending), b.name)
+		ch <- prometheus.MustNewConstMetric(diskBufferLagDesc, prometheus.GaugeValue, lag, b.name)
+		ch <- prometheus.MustNewConstMetric(diskBufferCorruptedDesc, prometheus.CounterValue, float64(b.corrupted.Load()), b.name)
+	}
+}
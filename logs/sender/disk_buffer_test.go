@@ -0,0 +1,111 @@
+//go:build !no_logs
+
+package sender
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/logs/message"
+)
+
+func newTestMessage(i int) *message.Message {
+	return &message.Message{Content: []byte(fmt.Sprintf("log-%d", i)), IngestionTimestamp: time.Now().UnixNano()}
+}
+
+func waitPending(t *testing.T, b *DiskBuffer, n int) {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		b.mu.Lock()
+		pending := b.pending
+		b.mu.Unlock()
+		if pending == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d spooled logs", n)
+}
+
+func expectMessages(t *testing.T, out chan *message.Message, ids ...int) {
+	t.Helper()
+	for _, i := range ids {
+		select {
+		case m := <-out:
+			if want := fmt.Sprintf("log-%d", i); string(m.Content) != want {
+				t.Fatalf("got %s, want %s", m.Content, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for log-%d", i)
+		}
+	}
+}
+
+func TestDiskBufferReplay(t *testing.T) {
+	dir := t.TempDir()
+	in, out := make(chan *message.Message, 10), make(chan *message.Message)
+	b, err := NewDiskBuffer(dir, "0", 1<<20, in, out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Start()
+	for i := 0; i < 5; i++ {
+		in <- newTestMessage(i)
+	}
+	waitPending(t, b, 5)
+	expectMessages(t, out, 0, 1, 2)
+	b.Stop()
+
+	// the logs left by the previous run are replayed before the new ones
+	in, out = make(chan *message.Message, 10), make(chan *message.Message)
+	b, err = NewDiskBuffer(dir, "0", 1<<20, in, out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPending(t, b, 2)
+	b.Start()
+	in <- newTestMessage(5)
+	expectMessages(t, out, 3, 4, 5)
+	waitPending(t, b, 0)
+	b.Stop()
+}
+
+func TestDiskBufferCorruptedSegment(t *testing.T) {
+	dir := t.TempDir()
+	in, out := make(chan *message.Message, 10), make(chan *message.Message)
+	// 2 records of 29 bytes per segment
+	b, err := NewDiskBuffer(dir, "0", 232, in, out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Start()
+	for i := 0; i < 6; i++ {
+		in <- newTestMessage(i)
+	}
+	waitPending(t, b, 6)
+	b.Stop()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if len(segments) != 3 {
+		t.Fatalf("expected 3 segments, got %v", segments)
+	}
+	f, err := os.OpenFile(segments[1], os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff}, 12)
+	f.Close()
+
+	in, out = make(chan *message.Message, 10), make(chan *message.Message)
+	b, err = NewDiskBuffer(dir, "0", 232, in, out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Start()
+	expectMessages(t, out, 0, 1, 4, 5)
+	waitPending(t, b, 0)
+	b.Stop()
+}
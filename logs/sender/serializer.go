@@ -9,6 +9,9 @@ package sender
 
 import (
 	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
 
 	"flashcat.cloud/categraf/logs/message"
 )
@@ -64,3 +67,54 @@ func (s *arraySerializer) Serialize(messages []*message.Message) []byte {
 	buffer.WriteByte(']')
 	return buffer.Bytes()
 }
+
+// bulkSerializer transforms a message array into the body of an elasticsearch bulk request.
+type bulkSerializer struct {
+	index string
+}
+
+// NewBulkSerializer returns a serializer of the bulk requests indexing the messages into
+// index, whose %Y, %m, %d and %H are replaced by the date of the message in UTC
+func NewBulkSerializer(index string) Serializer {
+	return &bulkSerializer{index: index}
+}
+
+// Serialize precedes each message with its create action,
+// for example:
+// "{"message":"content1"}", "{"message":"content2"}"
+// returns, "{"create":{"_index":"logs-2024.03.01"}}\n{"message":"content1"}\n{"create":{"_index":"logs-2024.03.01"}}\n{"message":"content2"}\n"
+func (s *bulkSerializer) Serialize(messages []*message.Message) []byte {
+	var buffer bytes.Buffer
+	var last, action []byte
+	for _, message := range messages {
+		ts := message.Timestamp
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		index := FormatIndex(s.index, ts)
+		if action == nil || string(last) != index {
+			// the data streams only accept create
+			action, _ = json.Marshal(map[string]map[string]string{"create": {"_index": index}})
+			last = []byte(index)
+		}
+		buffer.Write(action)
+		buffer.WriteByte('\n')
+		buffer.Write(message.Content)
+		buffer.WriteByte('\n')
+	}
+	return buffer.Bytes()
+}
+
+// FormatIndex replaces %Y, %m, %d and %H of the index pattern by the date of t in UTC
+func FormatIndex(pattern string, t time.Time) string {
+	if !strings.Contains(pattern, "%") {
+		return pattern
+	}
+	t = t.UTC()
+	return strings.NewReplacer(
+		"%Y", t.Format("2006"),
+		"%m", t.Format("01"),
+		"%d", t.Format("02"),
+		"%H", t.Format("15"),
+	).Replace(pattern)
+}
@@ -104,6 +104,15 @@ func (b *Builder) formatEndpoint(endpoint logsconfig.Endpoint, prefix string) st
 		} else {
 			protocol = "TCP (to Kafka)"
 		}
+	case "elasticsearch":
+		if endpoint.UseSSL {
+			protocol = "HTTPS (to Elasticsearch)"
+		} else {
+			protocol = "HTTP (to Elasticsearch)"
+		}
+		if port == 0 {
+			port = 9200
+		}
 	case "tcp":
 		if endpoint.UseSSL {
 			protocol = "SSL encrypted TCP"
@@ -200,6 +209,9 @@ func (b *Builder) toDictionary(c *logsconfig.LogsConfig) map[string]interface{}
 	case logsconfig.WindowsEventType:
 		dictionary["ChannelPath"] = c.ChannelPath
 		dictionary["Query"] = c.Query
+	case logsconfig.EventLogType:
+		dictionary["Channel"] = c.EventLogChannelName()
+		dictionary["Query"] = c.Query
 	}
 	for k, v := range dictionary {
 		if v == "" {
@@ -0,0 +1,212 @@
+//go:build !no_logs
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the metadata endpoints, variables for the tests
+var (
+	ec2Endpoint     = "http://169.254.169.254"
+	aliyunEndpoint  = "http://100.100.100.200"
+	tencentEndpoint = "http://metadata.tencentyun.com"
+)
+
+// resolveTimeout bounds the whole detection, the endpoints are not reachable on bare metal and
+// the requests hang until the timeout
+const resolveTimeout = 800 * time.Millisecond
+
+// Metadata locates the host in its cloud
+type Metadata struct {
+	Provider   string
+	InstanceID string
+	Region     string
+	AZ         string
+}
+
+// Tags returns the cloud_provider, instance_id, region and az tags, the empty values are omitted
+func (m *Metadata) Tags() []string {
+	if m == nil {
+		return nil
+	}
+	var tags []string
+	for _, tag := range [][2]string{
+		{"cloud_provider", m.Provider},
+		{"instance_id", m.InstanceID},
+		{"region", m.Region},
+		{"az", m.AZ},
+	} {
+		if tag[1] != "" {
+			tags = append(tags, tag[0]+"="+tag[1])
+		}
+	}
+	return tags
+}
+
+type detector struct {
+	provider string
+	detect   func(ctx context.Context, client *http.Client) (*Metadata, error)
+}
+
+// detectors in the detection order, the first one succeeding wins
+var detectors = []detector{
+	{"aws", detectEC2},
+	{"aliyun", detectAliyun},
+	{"tencentcloud", detectTencent},
+}
+
+var (
+	once sync.Once
+	tags []string
+)
+
+// Tags returns the tags of the cloud of the host, resolved once for the process lifetime,
+// nil out of a cloud
+func Tags() []string {
+	once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+		defer cancel()
+		m := Resolve(ctx)
+		if m == nil {
+			log.Println("I! no cloud metadata found, the logs are not tagged with the cloud of the host")
+			return
+		}
+		tags = m.Tags()
+		log.Println("I! the logs are tagged with the cloud metadata:", strings.Join(tags, ","))
+	})
+	return tags
+}
+
+// Resolve queries the metadata endpoints concurrently and returns the metadata of the first
+// provider of the detection order which answers, nil when none answers before ctx is done
+func Resolve(ctx context.Context) *Metadata {
+	// the metadata endpoints are never behind a proxy
+	client := &http.Client{Transport: &http.Transport{Proxy: nil}}
+	defer client.CloseIdleConnections()
+
+	type result struct {
+		index int
+		m     *Metadata
+	}
+	results := make(chan result, len(detectors))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for i, d := range detectors {
+		go func(i int, d detector) {
+			m, err := d.detect(ctx, client)
+			if err != nil {
+				m = nil
+			} else {
+				m.Provider = d.provider
+			}
+			results <- result{index: i, m: m}
+		}(i, d)
+	}
+
+	done := make([]bool, len(detectors))
+	found := make([]*Metadata, len(detectors))
+	for range detectors {
+		r := <-results
+		done[r.index], found[r.index] = true, r.m
+		// the answer of a provider is final once the providers before it failed
+		for i := range detectors {
+			if !done[i] {
+				break
+			}
+			if found[i] != nil {
+				return found[i]
+			}
+		}
+	}
+	return nil
+}
+
+func get(ctx context.Context, client *http.Client, method, url string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// detectEC2 reads the instance identity document, with an IMDSv2 token when the instance
+// requires one
+func detectEC2(ctx context.Context, client *http.Client) (*Metadata, error) {
+	headers := map[string]string{}
+	token, err := get(ctx, client, http.MethodPut, ec2Endpoint+"/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err == nil {
+		headers["X-aws-ec2-metadata-token"] = token
+	} else if ctx.Err() != nil {
+		return nil, err
+	}
+	doc, err := get(ctx, client, http.MethodGet, ec2Endpoint+"/latest/dynamic/instance-identity/document", headers)
+	if err != nil {
+		return nil, err
+	}
+	var identity struct {
+		InstanceID       string `json:"instanceId"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+	}
+	if err := json.Unmarshal([]byte(doc), &identity); err != nil {
+		return nil, err
+	}
+	if identity.InstanceID == "" {
+		return nil, fmt.Errorf("no instance id in the instance identity document")
+	}
+	return &Metadata{InstanceID: identity.InstanceID, Region: identity.Region, AZ: identity.AvailabilityZone}, nil
+}
+
+// getAll reads the paths of the meta-data of the endpoint, in order
+func getAll(ctx context.Context, client *http.Client, endpoint string, paths ...string) ([]string, error) {
+	values := make([]string, len(paths))
+	for i, path := range paths {
+		v, err := get(ctx, client, http.MethodGet, endpoint+"/latest/meta-data/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func detectAliyun(ctx context.Context, client *http.Client) (*Metadata, error) {
+	values, err := getAll(ctx, client, aliyunEndpoint, "instance-id", "region-id", "zone-id")
+	if err != nil {
+		return nil, err
+	}
+	return &Metadata{InstanceID: values[0], Region: values[1], AZ: values[2]}, nil
+}
+
+func detectTencent(ctx context.Context, client *http.Client) (*Metadata, error) {
+	values, err := getAll(ctx, client, tencentEndpoint, "instance-id", "placement/region", "placement/zone")
+	if err != nil {
+		return nil, err
+	}
+	return &Metadata{InstanceID: values[0], Region: values[1], AZ: values[2]}, nil
+}
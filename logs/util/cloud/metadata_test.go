@@ -0,0 +1,104 @@
+//go:build !no_logs
+
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// withEndpoints points the detectors to the servers, an empty url is a closed port
+func withEndpoints(t *testing.T, ec2, aliyun, tencent string) {
+	old := []string{ec2Endpoint, aliyunEndpoint, tencentEndpoint}
+	closed := "http://127.0.0.1:1"
+	for _, p := range []*string{&ec2, &aliyun, &tencent} {
+		if *p == "" {
+			*p = closed
+		}
+	}
+	ec2Endpoint, aliyunEndpoint, tencentEndpoint = ec2, aliyun, tencent
+	t.Cleanup(func() {
+		ec2Endpoint, aliyunEndpoint, tencentEndpoint = old[0], old[1], old[2]
+	})
+}
+
+func metadataServer(t *testing.T, paths map[string]string) string {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := paths[r.Method+" "+r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/latest/dynamic/instance-identity/document" && r.Header.Get("X-aws-ec2-metadata-token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func TestResolveEC2(t *testing.T) {
+	ec2 := metadataServer(t, map[string]string{
+		"PUT /latest/api/token":                          "token",
+		"GET /latest/dynamic/instance-identity/document": `{"instanceId":"i-0abc","region":"us-east-1","availabilityZone":"us-east-1a"}`,
+	})
+	withEndpoints(t, ec2, "", "")
+
+	m := Resolve(context.Background())
+	want := []string{"cloud_provider=aws", "instance_id=i-0abc", "region=us-east-1", "az=us-east-1a"}
+	if got := m.Tags(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestResolveOrder(t *testing.T) {
+	aliyun := metadataServer(t, map[string]string{
+		"GET /latest/meta-data/instance-id": "i-bp1",
+		"GET /latest/meta-data/region-id":   "cn-hangzhou",
+		"GET /latest/meta-data/zone-id":     "cn-hangzhou-h",
+	})
+	tencent := metadataServer(t, map[string]string{
+		"GET /latest/meta-data/instance-id":      "ins-1",
+		"GET /latest/meta-data/placement/region": "ap-guangzhou",
+		"GET /latest/meta-data/placement/zone":   "ap-guangzhou-3",
+	})
+	withEndpoints(t, "", aliyun, tencent)
+
+	m := Resolve(context.Background())
+	if m == nil || m.Provider != "aliyun" || m.AZ != "cn-hangzhou-h" {
+		t.Fatalf("expected aliyun to win the detection order, got %+v", m)
+	}
+
+	withEndpoints(t, "", "", tencent)
+	if m := Resolve(context.Background()); m == nil || m.Provider != "tencentcloud" || m.Region != "ap-guangzhou" {
+		t.Fatalf("expected tencentcloud, got %+v", m)
+	}
+}
+
+func TestResolveBareMetal(t *testing.T) {
+	// an endpoint which never answers, like a link local address out of a cloud
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+	withEndpoints(t, hang.URL, "", hang.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	begun := time.Now()
+	if m := Resolve(ctx); m != nil {
+		t.Fatalf("expected no metadata, got %+v", m)
+	}
+	if elapsed := time.Since(begun); elapsed > time.Second {
+		t.Fatalf("expected the detection to give up in less than a second, took %v", elapsed)
+	}
+	if tags := (*Metadata)(nil).Tags(); tags != nil {
+		t.Fatalf("expected no tags, got %v", tags)
+	}
+}
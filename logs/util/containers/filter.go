@@ -16,6 +16,7 @@ import (
 
 	coreconfig "flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/logs/util"
+	"flashcat.cloud/categraf/pkg/filter"
 )
 
 const (
@@ -80,13 +81,17 @@ type Filter struct {
 	ImageExcludeList     []*regexp.Regexp
 	NameExcludeList      []*regexp.Regexp
 	NamespaceExcludeList []*regexp.Regexp
+	// ImageGlobIncludeList and ImageGlobExcludeList hold the entries without prefix,
+	// they are globs matched against the image name, e.g. "nginx:*" or "*/redis*"
+	ImageGlobIncludeList filter.Filter
+	ImageGlobExcludeList filter.Filter
 	Errors               map[string]struct{}
 }
 
 var sharedFilter *Filter
 
-func parseFilters(filters []string) (imageFilters, nameFilters, namespaceFilters []*regexp.Regexp, filterErrs []string, err error) {
-	var filterWarnings []string
+func parseFilters(filters []string) (imageFilters, nameFilters, namespaceFilters []*regexp.Regexp, imageGlobs filter.Filter, filterErrs []string, err error) {
+	var globs []string
 	for _, filter := range filters {
 		switch {
 		case strings.HasPrefix(filter, imageFilterPrefix):
@@ -111,16 +116,17 @@ func parseFilters(filters []string) (imageFilters, nameFilters, namespaceFilters
 			}
 			namespaceFilters = append(namespaceFilters, r)
 		default:
-			warnmsg := fmt.Sprintf("Container filter %q is unknown, ignoring it. The supported filters are 'image', 'name' and 'kube_namespace'", filter)
-			log.Printf(warnmsg)
-			filterWarnings = append(filterWarnings, warnmsg)
-
+			globs = append(globs, filter)
 		}
 	}
+	imageGlobs, globErr := filter.Compile(globs)
+	if globErr != nil {
+		filterErrs = append(filterErrs, fmt.Sprintf("invalid image glob in %v: %s", globs, globErr))
+	}
 	if len(filterErrs) > 0 {
-		return nil, nil, nil, append(filterErrs, filterWarnings...), errors.New(filterErrs[0])
+		return nil, nil, nil, nil, filterErrs, errors.New(filterErrs[0])
 	}
-	return imageFilters, nameFilters, namespaceFilters, filterWarnings, nil
+	return imageFilters, nameFilters, namespaceFilters, imageGlobs, nil, nil
 }
 
 // filterToRegex checks a filter's regex
@@ -167,10 +173,11 @@ func GetFilterErrors() map[string]struct{} {
 // NewFilter creates a new container filter from a two slices of
 // regexp patterns for a include list and exclude list. Each pattern should have
 // the following format: "field:pattern" where field can be: [image, name, kube_namespace].
+// A pattern without field is a glob matched against the image name.
 // An error is returned if any of the expression don't compile.
 func NewFilter(includeList, excludeList []string) (*Filter, error) {
-	imgIncl, nameIncl, nsIncl, filterErrsIncl, errIncl := parseFilters(includeList)
-	imgExcl, nameExcl, nsExcl, filterErrsExcl, errExcl := parseFilters(excludeList)
+	imgIncl, nameIncl, nsIncl, globIncl, filterErrsIncl, errIncl := parseFilters(includeList)
+	imgExcl, nameExcl, nsExcl, globExcl, filterErrsExcl, errExcl := parseFilters(excludeList)
 
 	errors := append(filterErrsIncl, filterErrsExcl...)
 	errorsMap := make(map[string]struct{})
@@ -195,6 +202,8 @@ func NewFilter(includeList, excludeList []string) (*Filter, error) {
 		ImageExcludeList:     imgExcl,
 		NameExcludeList:      nameExcl,
 		NamespaceExcludeList: nsExcl,
+		ImageGlobIncludeList: globIncl,
+		ImageGlobExcludeList: globExcl,
 		Errors:               errorsMap,
 	}, nil
 }
@@ -273,6 +282,9 @@ func (cf Filter) IsExcluded(containerName, containerImage, podNamespace string)
 			return false
 		}
 	}
+	if matchImageGlob(cf.ImageGlobIncludeList, containerImage) {
+		return false
+	}
 
 	// Check if excludeListed
 	for _, r := range cf.ImageExcludeList {
@@ -294,6 +306,25 @@ func (cf Filter) IsExcluded(containerName, containerImage, podNamespace string)
 			return true
 		}
 	}
+	if matchImageGlob(cf.ImageGlobExcludeList, containerImage) {
+		return true
+	}
 
 	return false
 }
+
+// matchImageGlob matches the image as configured and without registry nor tag,
+// so that "nginx" matches "docker.io/library/nginx:1.25"
+func matchImageGlob(f filter.Filter, image string) bool {
+	if f == nil || image == "" {
+		return false
+	}
+	if f.Match(image) {
+		return true
+	}
+	long, short, _, err := SplitImageName(image)
+	if err != nil {
+		return false
+	}
+	return f.Match(long) || f.Match(short)
+}
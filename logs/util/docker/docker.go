@@ -21,8 +21,10 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 
+	coreconfig "flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/logs/util/containers/providers"
 	"flashcat.cloud/categraf/pkg/cache"
+	"flashcat.cloud/categraf/pkg/dock"
 	"flashcat.cloud/categraf/pkg/retry"
 )
 
@@ -72,13 +74,14 @@ func (d *DockerUtil) init() error {
 	d.imageNameBySha = make(map[string]string)
 	d.lastInvalidate = time.Now()
 	d.eventState = newEventStreamState()
+	go d.watchInspectCache()
 
 	return nil
 }
 
 // ConnectToDocker connects to docker and negotiates the API version
 func ConnectToDocker(ctx context.Context) (*client.Client, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := dock.NewEnvClient()
 	if err != nil {
 		return nil, err
 	}
@@ -226,17 +229,19 @@ func (d *DockerUtil) Inspect(ctx context.Context, id string, withSize bool) (typ
 		if !ok {
 			log.Println("Invalid inspect cache format, forcing a cache miss")
 		} else {
+			inspectCacheHits.Inc()
 			return container, nil
 		}
 	}
+	inspectCacheMisses.Inc()
 
 	container, err := d.InspectNoCache(ctx, id, withSize)
 	if err != nil {
 		return container, err
 	}
 
-	// cache the inspect for 10 seconds to reduce pressure on the daemon
-	cache.Cache.Set(cacheKey, container, 10*time.Second)
+	// cache the inspect to reduce pressure on the daemon, it's invalidated by the container events
+	cache.Cache.Set(cacheKey, container, coreconfig.DockerInspectCacheTTL())
 
 	return container, nil
 }
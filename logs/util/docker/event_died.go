@@ -0,0 +1,53 @@
+//go:build !no_logs
+
+package docker
+
+import (
+	"context"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// WatchDiedContainers sends the ids of the containers dying after since to died until ctx is
+// done. The events stream is opened again after an error from the last event, so an id may
+// be sent twice.
+func (d *DockerUtil) WatchDiedContainers(ctx context.Context, since time.Time, died chan<- string) {
+	fltrs := filters.NewArgs()
+	fltrs.Add("type", "container")
+	fltrs.Add("event", ContainerEventActionDie)
+	fltrs.Add("event", ContainerEventActionDied)
+
+	latestTimestamp := since.Unix()
+	for ctx.Err() == nil {
+		messages, errs := d.cli.Events(ctx, types.EventsOptions{
+			Since:   strconv.FormatInt(latestTimestamp, 10),
+			Filters: fltrs,
+		})
+	RECEIVE:
+		for {
+			select {
+			case err := <-errs:
+				if err != io.EOF && ctx.Err() == nil {
+					log.Println("W! Got error from docker while watching the died containers, waiting for 10 seconds: ", err)
+					select {
+					case <-time.After(10 * time.Second):
+					case <-ctx.Done():
+					}
+				}
+				break RECEIVE
+			case msg := <-messages:
+				latestTimestamp = msg.Time
+				select {
+				case died <- msg.Actor.ID:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
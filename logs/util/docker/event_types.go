@@ -20,6 +20,8 @@ const (
 	ContainerEventActionDie = "die"
 	// ContainerEventActionDied is the action of stopping a podman container
 	ContainerEventActionDied = "died"
+	// ContainerEventActionDestroy is the action of removing a docker container
+	ContainerEventActionDestroy = "destroy"
 	// ContainerEventActionRename is the action of renaming a docker container
 	ContainerEventActionRename = "rename"
 )
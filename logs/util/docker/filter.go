@@ -0,0 +1,62 @@
+//go:build !no_logs
+
+package docker
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+
+	"flashcat.cloud/categraf/logs/util/containers"
+)
+
+// ContainerFilter selects the containers to collect logs from. The docker API only filters
+// on the container state, the image and name patterns of container_include/container_exclude
+// are checked by the caller once the container is inspected, together with its labels.
+type ContainerFilter struct {
+	listOptions       types.ContainerListOptions
+	exitedListOptions types.ContainerListOptions
+	filter            *containers.Filter
+}
+
+// NewContainerFilter builds a ContainerFilter from the container_include and container_exclude
+// lists of the logs config.
+func NewContainerFilter() (*ContainerFilter, error) {
+	args, err := buildDockerFilter("status", containers.ContainerRunningState)
+	if err != nil {
+		return nil, err
+	}
+	exited, err := buildDockerFilter("status", "exited")
+	if err != nil {
+		return nil, err
+	}
+	f, err := containers.NewAutodiscoveryFilter(containers.LogsFilter)
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerFilter{
+		listOptions:       types.ContainerListOptions{Filters: args.Filters},
+		exitedListOptions: types.ContainerListOptions{All: true, Filters: exited.Filters},
+		filter:            f,
+	}, nil
+}
+
+// ListOptions returns the options to list the running containers with RawContainerList
+func (f *ContainerFilter) ListOptions() types.ContainerListOptions {
+	return f.listOptions
+}
+
+// ExitedListOptions returns the options to list the exited containers with RawContainerList
+func (f *ContainerFilter) ExitedListOptions() types.ContainerListOptions {
+	return f.exitedListOptions
+}
+
+// IsExcluded returns true if the inspected container is excluded by its name or image
+func (f *ContainerFilter) IsExcluded(co types.ContainerJSON) bool {
+	var image, namespace string
+	if co.Config != nil {
+		image = co.Config.Image
+		namespace = co.Config.Labels["io.kubernetes.pod.namespace"]
+	}
+	return f.filter.IsExcluded(strings.TrimPrefix(co.Name, "/"), image, namespace)
+}
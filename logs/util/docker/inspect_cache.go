@@ -0,0 +1,73 @@
+//go:build !no_logs
+
+package docker
+
+import (
+	"context"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"flashcat.cloud/categraf/pkg/cache"
+)
+
+var (
+	inspectCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "logs_docker_inspect_cache_hits_total",
+		Help: "Number of docker inspects served from the inspect cache.",
+	})
+	inspectCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "logs_docker_inspect_cache_misses_total",
+		Help: "Number of docker inspects missing from the inspect cache, sent to the docker daemon.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(inspectCacheHits, inspectCacheMisses)
+}
+
+// invalidateInspect drops the cached inspects of a container
+func invalidateInspect(id string) {
+	cache.Cache.Delete(GetInspectCacheKey(id, false))
+	cache.Cache.Delete(GetInspectCacheKey(id, true))
+}
+
+// watchInspectCache drops the inspect of the containers from the cache as soon as they die,
+// are destroyed or renamed, so that a stale inspect is not served until the ttl expires
+func (d *DockerUtil) watchInspectCache() {
+	fltrs := filters.NewArgs()
+	fltrs.Add("type", "container")
+	fltrs.Add("event", ContainerEventActionDie)
+	fltrs.Add("event", ContainerEventActionDied)
+	fltrs.Add("event", ContainerEventActionDestroy)
+	fltrs.Add("event", ContainerEventActionRename)
+
+	latestTimestamp := time.Now().Unix()
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		messages, errs := d.cli.Events(ctx, types.EventsOptions{
+			Since:   strconv.FormatInt(latestTimestamp, 10),
+			Filters: fltrs,
+		})
+	RECEIVE:
+		for {
+			select {
+			case err := <-errs:
+				if err != io.EOF {
+					log.Println("W! Got error from docker while watching the inspect cache, waiting for 10 seconds: ", err)
+					time.Sleep(10 * time.Second)
+				}
+				break RECEIVE
+			case msg := <-messages:
+				latestTimestamp = msg.Time
+				invalidateInspect(msg.Actor.ID)
+			}
+		}
+		cancel()
+	}
+}
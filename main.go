@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/chai2010/winsvc"
 	"github.com/kardianos/service"
@@ -18,7 +19,10 @@ import (
 	"gopkg.in/natefinch/lumberjack.v2"
 
 	"flashcat.cloud/categraf/agent"
+	"flashcat.cloud/categraf/agent/alerting"
+	"flashcat.cloud/categraf/agent/events"
 	agentInstall "flashcat.cloud/categraf/agent/install"
+	"flashcat.cloud/categraf/agent/relabel"
 	agentUpdate "flashcat.cloud/categraf/agent/update"
 	"flashcat.cloud/categraf/api"
 	"flashcat.cloud/categraf/config"
@@ -36,6 +40,7 @@ var (
 	interval     = flag.Int64("interval", 0, "Global interval(unit:Second)")
 	showVersion  = flag.Bool("version", false, "Show version.")
 	inputFilters = flag.String("inputs", "", "e.g. cpu:mem:system")
+	describe     = flag.String("describe", "", "Print the collectors of the input and their cost as json, e.g. elasticsearch")
 	install      = flag.Bool("install", false, "Install categraf service")
 	remove       = flag.Bool("remove", false, "Remove categraf service")
 	start        = flag.Bool("start", false, "Start categraf service")
@@ -95,11 +100,23 @@ func main() {
 	if err := config.InitConfig(*configDir, *debugLevel, *debugMode, *testMode, *interval, *inputFilters); err != nil {
 		log.Fatalln("F! failed to init config:", err)
 	}
+	if *describe != "" {
+		if err := agent.DescribeInput(*describe, os.Stdout); err != nil {
+			log.Fatalln("F! failed to describe input:", err)
+		}
+		return
+	}
 
 	doOSsvc()
 	printEnv()
 
+	initEvents()
+	initRelabel()
+	initAlerting()
 	initWriters()
+	if config.Config.Events != nil && config.Config.Events.ForwardToWriters {
+		events.AddSink(writer.EventSink{})
+	}
 
 	go api.Start()
 	go heartbeat.Work()
@@ -110,9 +127,28 @@ func main() {
 		fmt.Println("F! failed to init agent:", err)
 		os.Exit(-1)
 	}
+	go api.StartAgentServer(ag.Health)
 	runAgent(ag)
 }
 
+func initEvents() {
+	if err := events.Init(config.Config.Events); err != nil {
+		log.Fatalln("F! failed to init events:", err)
+	}
+}
+
+func initAlerting() {
+	if err := alerting.Init(config.Config.Alerting); err != nil {
+		log.Fatalln("F! failed to init alerting:", err)
+	}
+}
+
+func initRelabel() {
+	if err := relabel.Init(config.Config.Relabel); err != nil {
+		log.Fatalln("F! failed to init relabel rules:", err)
+	}
+}
+
 func initWriters() {
 	if err := writer.InitWriters(); err != nil {
 		log.Fatalln("F! failed to init writer:", err)
@@ -141,6 +177,7 @@ EXIT:
 	}
 
 	ag.Stop()
+	writer.Shutdown(5 * time.Second)
 	log.Println("I! exited")
 }
 
@@ -0,0 +1,271 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	ch "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/outputs"
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+const outputName = "clickhouse"
+
+var identifierRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type ClickHouse struct {
+	// host:port of the native protocol, e.g. 127.0.0.1:9000, the connections are balanced over the addresses
+	Addresses []string `toml:"addresses"`
+	Database  string   `toml:"database"`
+	Table     string   `toml:"table"`
+	Username  string   `toml:"username"`
+	Password  string   `toml:"password"`
+	// "", "lz4" or "zstd"
+	Compression string          `toml:"compression"`
+	DialTimeout config.Duration `toml:"dial_timeout"`
+	Timeout     config.Duration `toml:"timeout"`
+	// max rows of an insert, the larger batches are split
+	BatchSize int `toml:"batch_size"`
+	// the table is created with CREATE TABLE IF NOT EXISTS before the first insert unless disabled
+	DisableCreateTable bool `toml:"disable_create_table"`
+	// async inserts of ClickHouse 21.12+, the server buffers the small inserts of many agents
+	AsyncInsert bool `toml:"async_insert"`
+	// the async inserts wait for the data to be flushed to the table, an error is returned otherwise
+	WaitForAsyncInsert *bool `toml:"wait_for_async_insert"`
+	// retries of an insert failed with a network error, the interval doubles after each retry
+	MaxRetries    int             `toml:"max_retries"`
+	RetryInterval config.Duration `toml:"retry_interval"`
+	// the deadline of the inserts of a batch with their retries, the rows not inserted are dropped beyond
+	RetryTimeout config.Duration `toml:"retry_timeout"`
+	tls.ClientConfig
+
+	conn       driver.Conn
+	table      string
+	tableReady atomic.Bool
+}
+
+func init() {
+	outputs.Add(outputName, func() outputs.Output {
+		return &ClickHouse{}
+	})
+}
+
+func (c *ClickHouse) Init() error {
+	if len(c.Addresses) == 0 {
+		return outputs.ErrDisabled
+	}
+	if c.Database == "" {
+		c.Database = "default"
+	}
+	if c.Table == "" {
+		c.Table = "categraf_metrics"
+	}
+	for _, name := range []string{c.Database, c.Table} {
+		if !identifierRegex.MatchString(name) {
+			return fmt.Errorf("invalid database or table name %q", name)
+		}
+	}
+	c.table = c.Database + "." + c.Table
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = config.Duration(5 * time.Second)
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = config.Duration(10 * time.Second)
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10000
+	}
+	if c.WaitForAsyncInsert == nil {
+		wait := true
+		c.WaitForAsyncInsert = &wait
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.RetryInterval <= 0 {
+		c.RetryInterval = config.Duration(time.Second)
+	}
+	if c.RetryTimeout <= 0 {
+		c.RetryTimeout = config.Duration(30 * time.Second)
+	}
+
+	opts := &ch.Options{
+		Addr: c.Addresses,
+		Auth: ch.Auth{
+			Database: c.Database,
+			Username: c.Username,
+			Password: c.Password,
+		},
+		DialTimeout: time.Duration(c.DialTimeout),
+		ReadTimeout: time.Duration(c.Timeout),
+		ClientInfo: ch.ClientInfo{
+			Products: []struct {
+				Name    string
+				Version string
+			}{{Name: "categraf", Version: config.Version}},
+		},
+	}
+	switch c.Compression {
+	case "", "none":
+	case "lz4":
+		opts.Compression = &ch.Compression{Method: ch.CompressionLZ4}
+	case "zstd":
+		opts.Compression = &ch.Compression{Method: ch.CompressionZSTD}
+	default:
+		return fmt.Errorf("unsupported compression %q, should be lz4 or zstd", c.Compression)
+	}
+	if c.UseTLS {
+		tlsConfig, err := c.TLSConfig()
+		if err != nil {
+			return err
+		}
+		opts.TLS = tlsConfig
+	}
+
+	// the connections are established on the first insert, an unreachable server does not block the agent
+	conn, err := ch.Open(opts)
+	if err != nil {
+		return fmt.Errorf("failed to open clickhouse %v: %v", c.Addresses, err)
+	}
+	c.conn = conn
+	return nil
+}
+
+func (c *ClickHouse) Write(items []prompb.TimeSeries) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.RetryTimeout))
+	defer cancel()
+
+	rows := toRows(items)
+	for start := 0; start < len(rows); start += c.BatchSize {
+		if ctx.Err() != nil {
+			log.Println("E! dropped", len(rows)-start, "rows not inserted into clickhouse table", c.table, "before retry_timeout")
+			return
+		}
+		end := start + c.BatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		c.writeBatch(ctx, rows[start:end])
+	}
+}
+
+func (c *ClickHouse) writeBatch(ctx context.Context, rows []row) {
+	deadline, _ := ctx.Deadline()
+	interval := time.Duration(c.RetryInterval)
+	for i := 0; ; i++ {
+		err := c.insert(ctx, rows)
+		if err == nil {
+			return
+		}
+		if i >= c.MaxRetries || !retryable(err) || ctx.Err() != nil {
+			log.Println("E! failed to insert", len(rows), "rows into clickhouse table", c.table, "error:", err)
+			return
+		}
+		if time.Until(deadline) < interval {
+			log.Println("E! failed to insert", len(rows), "rows into clickhouse table", c.table, "before retry_timeout, error:", err)
+			return
+		}
+		log.Println("W! failed to insert rows into clickhouse table", c.table, "retry in", interval, "error:", err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+func (c *ClickHouse) insert(ctx context.Context, rows []row) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.Timeout))
+	defer cancel()
+
+	if !c.DisableCreateTable && !c.tableReady.Load() {
+		if err := c.conn.Exec(ctx, createTableQuery(c.table)); err != nil {
+			return fmt.Errorf("failed to create table: %w", err)
+		}
+		c.tableReady.Store(true)
+	}
+
+	if c.AsyncInsert {
+		return c.conn.AsyncInsert(ctx, insertValuesQuery(c.table, rows), *c.WaitForAsyncInsert)
+	}
+
+	batch, err := c.conn.PrepareBatch(ctx, "INSERT INTO "+c.table+" (metric, timestamp, value, tags)")
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := batch.Append(r.metric, time.UnixMilli(r.timestamp), r.value, r.tags); err != nil {
+			batch.Abort()
+			return err
+		}
+	}
+	return batch.Send()
+}
+
+// createTableQuery returns the schema of the table, one row per sample partitioned by day
+func createTableQuery(table string) string {
+	return "CREATE TABLE IF NOT EXISTS " + table + ` (
+	metric LowCardinality(String),
+	timestamp DateTime64(3, 'UTC') CODEC(DoubleDelta, ZSTD),
+	value Float64 CODEC(Gorilla, ZSTD),
+	tags Map(LowCardinality(String), String)
+) ENGINE = MergeTree
+PARTITION BY toDate(timestamp)
+ORDER BY (metric, timestamp)`
+}
+
+// retryable reports whether the insert may succeed later, the exceptions of the server,
+// e.g. a wrong schema or authentication, fail again
+func retryable(err error) bool {
+	var exception *ch.Exception
+	return !errors.As(err, &exception)
+}
+
+// insertValuesQuery formats the rows in the VALUES format, the async inserts are sent as text
+func insertValuesQuery(table string, rows []row) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(table)
+	b.WriteString(" (metric, timestamp, value, tags) VALUES ")
+	for i, r := range rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('(')
+		writeString(&b, r.metric)
+		fmt.Fprintf(&b, ",%d.%03d,%s,{", r.timestamp/1000, r.timestamp%1000, formatFloat(r.value))
+		first := true
+		for _, k := range r.keys {
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			writeString(&b, k)
+			b.WriteByte(':')
+			writeString(&b, r.tags[k])
+		}
+		b.WriteString("})")
+	}
+	return b.String()
+}
+
+func writeString(b *strings.Builder, s string) {
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('\'')
+}
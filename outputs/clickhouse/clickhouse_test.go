@@ -0,0 +1,70 @@
+package clickhouse
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestInsertValuesQuery(t *testing.T) {
+	items := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "cpu_usage_idle"}, {Name: "ident", Value: "host's"}, {Name: "cpu", Value: `cpu\0`}},
+			Samples: []prompb.Sample{{Timestamp: 1700000000005, Value: 98.5}, {Timestamp: 1700000015000, Value: math.NaN()}},
+		},
+		{
+			// skipped without a name
+			Labels:  []prompb.Label{{Name: "cpu", Value: "cpu1"}},
+			Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 1}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "system_load1"}},
+			Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 2}},
+		},
+	}
+
+	rows := toRows(items)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	got := insertValuesQuery("default.categraf_metrics", rows)
+	want := `INSERT INTO default.categraf_metrics (metric, timestamp, value, tags) VALUES ` +
+		`('cpu_usage_idle',1700000000.005,98.5,{'cpu':'cpu\\0','ident':'host\'s'}),` +
+		`('cpu_usage_idle',1700000015.000,nan,{'cpu':'cpu\\0','ident':'host\'s'}),` +
+		`('system_load1',1700000000.000,2,{})`
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteRetryTimeout(t *testing.T) {
+	// nothing listens on the port, the inserts fail with a retryable network error
+	c := &ClickHouse{
+		Addresses:          []string{"127.0.0.1:1"},
+		DisableCreateTable: true,
+		BatchSize:          1,
+		MaxRetries:         100,
+		RetryInterval:      config.Duration(20 * time.Millisecond),
+		RetryTimeout:       config.Duration(100 * time.Millisecond),
+	}
+	if err := c.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.conn.Close()
+
+	items := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "system_load1"}},
+			Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 2}, {Timestamp: 1700000015000, Value: 3}},
+		},
+	}
+	start := time.Now()
+	c.Write(items)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the retries of the batches to stop at retry_timeout, took %v", elapsed)
+	}
+}
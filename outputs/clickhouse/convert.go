@@ -0,0 +1,58 @@
+package clickhouse
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+type row struct {
+	metric    string
+	timestamp int64
+	value     float64
+	tags      map[string]string
+	// sorted keys of tags
+	keys []string
+}
+
+// toRows converts every sample to a row, the labels other than the name become the tags,
+// the samples of one series share the tags
+func toRows(items []prompb.TimeSeries) []row {
+	rows := make([]row, 0, len(items))
+	for _, item := range items {
+		name := ""
+		tags := make(map[string]string, len(item.Labels))
+		keys := make([]string, 0, len(item.Labels))
+		for _, l := range item.Labels {
+			if l.Name == model.MetricNameLabel {
+				name = l.Value
+				continue
+			}
+			tags[l.Name] = l.Value
+			keys = append(keys, l.Name)
+		}
+		if name == "" {
+			continue
+		}
+		sort.Strings(keys)
+		for _, s := range item.Samples {
+			rows = append(rows, row{metric: name, timestamp: s.Timestamp, value: s.Value, tags: tags, keys: keys})
+		}
+	}
+	return rows
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,151 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// the reasons of the dropped samples
+const (
+	dropInvalidValue = "invalid_value"
+	dropRejected     = "rejected"
+	dropWriteFailed  = "write_failed"
+)
+
+// document is the document of a sample, the labels other than the name are the tags
+type document struct {
+	Timestamp string            `json:"@timestamp"`
+	Name      string            `json:"name"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// indexLayout converts the strftime-like verbs of index_name to a layout of time.Format,
+// e.g. metrics-%Y.%m.%d to metrics-, 2006, ., 01, ., 02, the other parts are written as they are
+// so they are never taken for a part of a layout
+type indexLayout struct {
+	parts []string
+	// verb marks the parts which are layouts
+	verb []bool
+}
+
+var indexVerbs = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'H': "15",
+	'j': "002",
+}
+
+func parseIndexName(name string) indexLayout {
+	var (
+		l   indexLayout
+		lit strings.Builder
+	)
+	flush := func() {
+		if lit.Len() > 0 {
+			l.parts = append(l.parts, lit.String())
+			l.verb = append(l.verb, false)
+			lit.Reset()
+		}
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '%' && i+1 < len(name) {
+			if layout, ok := indexVerbs[name[i+1]]; ok {
+				flush()
+				l.parts = append(l.parts, layout)
+				l.verb = append(l.verb, true)
+				i++
+				continue
+			}
+		}
+		lit.WriteByte(name[i])
+	}
+	flush()
+	return l
+}
+
+// format returns the index of a sample at t, the dates of the indices are in UTC
+func (l indexLayout) format(t time.Time) string {
+	if len(l.parts) == 1 && !l.verb[0] {
+		return l.parts[0]
+	}
+	t = t.UTC()
+	var b strings.Builder
+	for i, p := range l.parts {
+		if l.verb[i] {
+			b.WriteString(t.Format(p))
+		} else {
+			b.WriteString(p)
+		}
+	}
+	return b.String()
+}
+
+// pattern returns the index pattern of the index template, the parts from the first date to
+// the last one replaced with *, e.g. categraf-* for categraf-%Y.%m.%d
+func (l indexLayout) pattern() string {
+	first, last := -1, -1
+	for i := range l.parts {
+		if l.verb[i] {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return strings.Join(l.parts, "")
+	}
+	return strings.Join(l.parts[:first], "") + "*" + strings.Join(l.parts[last+1:], "")
+}
+
+// appendBulk appends the action and the document of every sample to the body of a bulk request
+// and returns the count of the documents, the NaN and Inf values, rejected by elasticsearch,
+// are dropped and counted
+func appendBulk(buf *bytes.Buffer, item prompb.TimeSeries, index indexLayout) (int, int) {
+	doc := document{Tags: make(map[string]string, len(item.Labels))}
+	for _, l := range item.Labels {
+		if l.Name == model.MetricNameLabel {
+			doc.Name = l.Value
+			continue
+		}
+		if l.Value != "" {
+			doc.Tags[l.Name] = l.Value
+		}
+	}
+	if doc.Name == "" {
+		return 0, 0
+	}
+
+	count, dropped := 0, 0
+	for _, s := range item.Samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			dropped++
+			continue
+		}
+		ts := time.UnixMilli(s.Timestamp)
+		doc.Timestamp = ts.UTC().Format("2006-01-02T15:04:05.000Z")
+		doc.Value = s.Value
+		data, err := json.Marshal(&doc)
+		if err != nil {
+			dropped++
+			continue
+		}
+		buf.WriteString(`{"index":{"_index":`)
+		indexName, _ := json.Marshal(index.format(ts))
+		buf.Write(indexName)
+		buf.WriteString("}}\n")
+		buf.Write(data)
+		buf.WriteByte('\n')
+		count++
+	}
+	return count, dropped
+}
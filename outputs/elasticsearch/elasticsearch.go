@@ -0,0 +1,351 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/outputs"
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+const outputName = "elasticsearch"
+
+var droppedSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "output_elasticsearch_dropped_samples_total",
+	Help: "Number of samples not indexed in Elasticsearch, by reason.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(droppedSamplesTotal)
+	outputs.Add(outputName, func() outputs.Output {
+		return &Elasticsearch{}
+	})
+}
+
+type Elasticsearch struct {
+	// e.g. http://127.0.0.1:9200, the next url is used after a network error
+	URLs []string `toml:"urls"`
+	// the index of the documents, %Y %y %m %d %H and %j are replaced with the date of the
+	// sample in UTC, e.g. categraf-%Y.%m.%d
+	IndexName string `toml:"index_name"`
+	// documents per bulk request
+	BatchSize int             `toml:"batch_size"`
+	Timeout   config.Duration `toml:"timeout"`
+	// retries of a request failed with a network error, a 429 or a 5xx, and of the documents
+	// rejected with a 429, the interval doubles after each retry
+	MaxRetries    int             `toml:"max_retries"`
+	RetryInterval config.Duration `toml:"retry_interval"`
+	// the deadline of the bulk requests of a batch with their retries, the documents not indexed
+	// are dropped beyond
+	RetryTimeout config.Duration `toml:"retry_timeout"`
+
+	Username   string            `toml:"username"`
+	Password   string            `toml:"password"`
+	APIKey     string            `toml:"api_key"`
+	Headers    map[string]string `toml:"headers"`
+	EnableGzip bool              `toml:"enable_gzip"`
+
+	// puts the index template of the indices of index_name before the first write, with the
+	// mappings of the documents and the ilm policy
+	ManageTemplate    bool   `toml:"manage_template"`
+	TemplateName      string `toml:"template_name"`
+	OverwriteTemplate bool   `toml:"overwrite_template"`
+	// the ilm policy attached to the indices on their creation, the policy must exist
+	ILMPolicy string `toml:"ilm_policy"`
+	tls.ClientConfig
+
+	index  indexLayout
+	client *http.Client
+	// the url of the requests, the next one after a network error
+	current atomic.Int32
+
+	templateMu    sync.Mutex
+	templateReady bool
+}
+
+func (e *Elasticsearch) Init() error {
+	if len(e.URLs) == 0 {
+		return outputs.ErrDisabled
+	}
+	for i, u := range e.URLs {
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return fmt.Errorf("invalid url %s, should be http:// or https://", u)
+		}
+		e.URLs[i] = strings.TrimRight(u, "/")
+	}
+	if e.IndexName == "" {
+		e.IndexName = "categraf-%Y.%m.%d"
+	}
+	e.index = parseIndexName(e.IndexName)
+	if name := e.index.format(time.Now()); name != strings.ToLower(name) {
+		return fmt.Errorf("invalid index_name %s, elasticsearch indices must be lowercase", e.IndexName)
+	}
+	if e.BatchSize <= 0 {
+		e.BatchSize = 1000
+	}
+	if e.Timeout <= 0 {
+		e.Timeout = config.Duration(10 * time.Second)
+	}
+	if e.MaxRetries < 0 {
+		e.MaxRetries = 0
+	} else if e.MaxRetries == 0 {
+		e.MaxRetries = 3
+	}
+	if e.RetryInterval <= 0 {
+		e.RetryInterval = config.Duration(time.Second)
+	}
+	if e.RetryTimeout <= 0 {
+		e.RetryTimeout = config.Duration(30 * time.Second)
+	}
+	if e.TemplateName == "" {
+		e.TemplateName = "categraf"
+	}
+	if e.ILMPolicy != "" && !e.ManageTemplate {
+		return fmt.Errorf("ilm_policy %s is attached by the index template, it requires manage_template = true", e.ILMPolicy)
+	}
+
+	trans := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if e.UseTLS {
+		tlsConfig, err := e.TLSConfig()
+		if err != nil {
+			return err
+		}
+		trans.TLSClientConfig = tlsConfig
+	}
+	e.client = &http.Client{Transport: trans, Timeout: time.Duration(e.Timeout)}
+
+	if e.ManageTemplate {
+		// elasticsearch may not be up yet, the template is put again before the writes
+		if err := e.ensureTemplate(); err != nil {
+			log.Println("W! failed to put elasticsearch index template", e.TemplateName, "error:", err)
+		}
+	}
+	return nil
+}
+
+func (e *Elasticsearch) Write(items []prompb.TimeSeries) {
+	if e.ManageTemplate {
+		if err := e.ensureTemplate(); err != nil {
+			// the indices created without the template would miss the mappings and the ilm policy
+			n := 0
+			for _, item := range items {
+				n += len(item.Samples)
+			}
+			log.Println("E! failed to put elasticsearch index template", e.TemplateName, "dropped", n, "samples, error:", err)
+			droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(n))
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.RetryTimeout))
+	defer cancel()
+
+	var (
+		buf     bytes.Buffer
+		count   int
+		dropped int
+	)
+	for _, item := range items {
+		n, d := appendBulk(&buf, item, e.index)
+		count += n
+		dropped += d
+		if count >= e.BatchSize {
+			e.bulk(ctx, buf.Bytes(), count)
+			buf.Reset()
+			count = 0
+		}
+	}
+	if count > 0 {
+		e.bulk(ctx, buf.Bytes(), count)
+	}
+	if dropped > 0 {
+		droppedSamplesTotal.WithLabelValues(dropInvalidValue).Add(float64(dropped))
+		log.Println("W! dropped", dropped, "samples not accepted by elasticsearch, reason:", dropInvalidValue)
+	}
+}
+
+// bulk sends the documents, the requests failed with a network error, a 429 or a 5xx are sent
+// again and then the documents rejected with a 429, the other rejected documents are dropped.
+// The documents still not indexed at the deadline of the context are dropped
+func (e *Elasticsearch) bulk(ctx context.Context, body []byte, count int) {
+	if ctx.Err() != nil {
+		log.Println("E! dropped", count, "documents not indexed to elasticsearch before retry_timeout")
+		droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(count))
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	interval := time.Duration(e.RetryInterval)
+	for i := 0; ; i++ {
+		retry, err := e.post(ctx, body)
+		if err == nil {
+			if len(retry) == 0 {
+				return
+			}
+			body, count = retry, bytes.Count(retry, []byte{'\n'})/2
+			err = fmt.Errorf("%d documents rejected with status code 429", count)
+		} else if _, ok := err.(*retryableError); !ok {
+			i = e.MaxRetries
+		}
+		if i >= e.MaxRetries || ctx.Err() != nil {
+			log.Println("E! failed to index", count, "documents to elasticsearch, error:", err)
+			droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(count))
+			return
+		}
+		if time.Until(deadline) < interval {
+			log.Println("E! failed to index", count, "documents to elasticsearch before retry_timeout, error:", err)
+			droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(count))
+			return
+		}
+		log.Println("W! failed to index documents to elasticsearch, retry in", interval, "error:", err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// bulkResponse is the response of the bulk api, the items are in the order of the request
+type bulkResponse struct {
+	Errors bool                  `json:"errors"`
+	Items  []map[string]bulkItem `json:"items"`
+}
+
+type bulkItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// retryableError is a failure of the whole request, which is sent again
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+// post sends a bulk request and returns the actions and the documents rejected with a 429,
+// the documents rejected for another reason, e.g. a mapping error, are counted and dropped
+func (e *Elasticsearch) post(ctx context.Context, body []byte) ([]byte, error) {
+	resp, err := e.request(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body)
+	if err != nil {
+		// the next url, unless the request was cut by the deadline
+		if ctx.Err() == nil {
+			e.current.Add(1)
+		}
+		return nil, &retryableError{err}
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &retryableError{err}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5 {
+		return nil, &retryableError{fmt.Errorf("status code %d: %s", resp.StatusCode, truncate(data))}
+	}
+	if resp.StatusCode/100 != 2 {
+		// not retried, e.g. the credentials are wrong
+		n := bytes.Count(body, []byte{'\n'}) / 2
+		log.Println("E! elasticsearch rejected", n, "documents, status code", resp.StatusCode, "response:", truncate(data))
+		droppedSamplesTotal.WithLabelValues(dropRejected).Add(float64(n))
+		return nil, nil
+	}
+
+	var result bulkResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode elasticsearch bulk response: %v", err)
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+
+	var (
+		retry    bytes.Buffer
+		rejected int
+		sample   string
+	)
+	lines := bytes.Split(bytes.TrimSuffix(body, []byte{'\n'}), []byte{'\n'})
+	for i, r := range result.Items {
+		if 2*i+1 >= len(lines) {
+			break
+		}
+		for _, item := range r {
+			if item.Status < 300 {
+				continue
+			}
+			if item.Status == http.StatusTooManyRequests {
+				retry.Write(lines[2*i])
+				retry.WriteByte('\n')
+				retry.Write(lines[2*i+1])
+				retry.WriteByte('\n')
+				continue
+			}
+			rejected++
+			if sample == "" && item.Error != nil {
+				sample = item.Error.Type + ": " + item.Error.Reason
+			}
+		}
+	}
+	if rejected > 0 {
+		droppedSamplesTotal.WithLabelValues(dropRejected).Add(float64(rejected))
+		log.Println("W! elasticsearch rejected", rejected, "documents:", sample)
+	}
+	return retry.Bytes(), nil
+}
+
+func (e *Elasticsearch) request(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	encoding := ""
+	if body != nil {
+		reader = bytes.NewReader(body)
+		if e.EnableGzip {
+			compressed, err := compress(body)
+			if err != nil {
+				return nil, err
+			}
+			reader, encoding = bytes.NewReader(compressed), "gzip"
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, e.URLs[int(e.current.Load())%len(e.URLs)]+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "categraf")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+e.APIKey)
+	} else if e.Username != "" || e.Password != "" {
+		req.SetBasicAuth(e.Username, e.Password)
+	}
+	return e.client.Do(req)
+}
+
+func truncate(data []byte) string {
+	s := strings.TrimSpace(string(data))
+	if len(s) > 512 {
+		s = s[:512]
+	}
+	return s
+}
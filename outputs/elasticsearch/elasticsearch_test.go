@@ -0,0 +1,164 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+func TestIndexName(t *testing.T) {
+	ts := time.Date(2024, 3, 9, 23, 30, 0, 0, time.UTC)
+	cases := []struct {
+		name, index, pattern string
+	}{
+		{"categraf-%Y.%m.%d", "categraf-2024.03.09", "categraf-*"},
+		{"metrics-%Y%m-%H-v1", "metrics-202403-23-v1", "metrics-*-v1"},
+		{"metrics", "metrics", "metrics"},
+		{"100%-%q", "100%-%q", "100%-%q"},
+	}
+	for _, c := range cases {
+		l := parseIndexName(c.name)
+		if got := l.format(ts); got != c.index {
+			t.Errorf("%s: got index %s, want %s", c.name, got, c.index)
+		}
+		if got := l.pattern(); got != c.pattern {
+			t.Errorf("%s: got pattern %s, want %s", c.name, got, c.pattern)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		template map[string]interface{}
+		bulks    [][]map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/_index_template/categraf":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut && r.URL.Path == "/_index_template/categraf":
+			json.NewDecoder(r.Body).Decode(&template)
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+			var lines []map[string]interface{}
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var line map[string]interface{}
+				json.Unmarshal(scanner.Bytes(), &line)
+				lines = append(lines, line)
+			}
+			bulks = append(bulks, lines)
+			if len(bulks) == 1 {
+				// the second document is throttled, the third one rejected
+				w.Write([]byte(`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`))
+				return
+			}
+			w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	e := &Elasticsearch{
+		URLs:           []string{server.URL},
+		ManageTemplate: true,
+		ILMPolicy:      "metrics-30d",
+		RetryInterval:  1,
+	}
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	rejected := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropRejected))
+	invalid := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropInvalidValue))
+
+	e.Write([]prompb.TimeSeries{{
+		Labels: []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}, {Name: "ident", Value: "host01"}, {Name: "empty", Value: ""}},
+		Samples: []prompb.Sample{
+			{Timestamp: 1709942400000, Value: 1.5},
+			{Timestamp: 1709942415000, Value: 2},
+			{Timestamp: 1709942430000, Value: 3},
+			{Timestamp: 1709942445000, Value: math.NaN()},
+		},
+	}})
+
+	mu.Lock()
+	defer mu.Unlock()
+	settings := template["template"].(map[string]interface{})["settings"].(map[string]interface{})
+	if template["index_patterns"].([]interface{})[0] != "categraf-*" || settings["index.lifecycle.name"] != "metrics-30d" {
+		t.Fatalf("unexpected template %v", template)
+	}
+	if len(bulks) != 2 || len(bulks[0]) != 6 || len(bulks[1]) != 2 {
+		t.Fatalf("unexpected bulk requests %v", bulks)
+	}
+	action := bulks[0][0]["index"].(map[string]interface{})
+	doc := bulks[0][1]
+	if action["_index"] != "categraf-2024.03.09" || doc["@timestamp"] != "2024-03-09T00:00:00.000Z" ||
+		doc["name"] != "mem_used_percent" || doc["value"] != 1.5 {
+		t.Fatalf("unexpected document %v %v", action, doc)
+	}
+	tags := doc["tags"].(map[string]interface{})
+	if len(tags) != 1 || tags["ident"] != "host01" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if bulks[1][1]["value"] != 2.0 {
+		t.Fatalf("expected the throttled document to be sent again, got %v", bulks[1])
+	}
+	if n := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropRejected)) - rejected; n != 1 {
+		t.Fatalf("expected the rejected document to be counted, got %v", n)
+	}
+	if n := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropInvalidValue)) - invalid; n != 1 {
+		t.Fatalf("expected the NaN to be counted, got %v", n)
+	}
+}
+
+func TestWriteRetryTimeout(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	e := &Elasticsearch{
+		URLs:          []string{server.URL},
+		BatchSize:     1,
+		MaxRetries:    100,
+		RetryInterval: config.Duration(20 * time.Millisecond),
+		RetryTimeout:  config.Duration(100 * time.Millisecond),
+	}
+	if err := e.Init(); err != nil {
+		t.Fatal(err)
+	}
+	failed := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropWriteFailed))
+
+	start := time.Now()
+	e.Write([]prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "mem_used_percent"}},
+		Samples: []prompb.Sample{{Timestamp: 1709942400000, Value: 1}, {Timestamp: 1709942415000, Value: 2}},
+	}})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the retries to stop at retry_timeout, took %v", elapsed)
+	}
+	// the first document is retried until the deadline, the second document is not sent
+	if n := requests.Load(); n < 2 || n > 4 {
+		t.Fatalf("expected 2 to 4 requests before the deadline, got %d", n)
+	}
+	if n := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropWriteFailed)) - failed; n != 2 {
+		t.Fatalf("expected the 2 documents to be counted as failed, got %v", n)
+	}
+}
@@ -0,0 +1,100 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+// indexTemplate returns the composable index template of the indices of index_name, the tags
+// are keywords and the ilm policy is attached to the indices when they are created
+func (e *Elasticsearch) indexTemplate() map[string]interface{} {
+	settings := map[string]interface{}{}
+	if e.ILMPolicy != "" {
+		settings["index.lifecycle.name"] = e.ILMPolicy
+	}
+	return map[string]interface{}{
+		"index_patterns": []string{e.index.pattern()},
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": map[string]interface{}{
+				"dynamic_templates": []interface{}{
+					map[string]interface{}{
+						"tags": map[string]interface{}{
+							"path_match":         "tags.*",
+							"match_mapping_type": "string",
+							"mapping":            map[string]string{"type": "keyword"},
+						},
+					},
+				},
+				"properties": map[string]interface{}{
+					"@timestamp": map[string]string{"type": "date"},
+					"name":       map[string]string{"type": "keyword"},
+					"value":      map[string]string{"type": "double"},
+				},
+			},
+		},
+		"_meta": map[string]string{"managed_by": "categraf"},
+	}
+}
+
+// ensureTemplate puts the index template once, an existing template is kept unless
+// overwrite_template is true
+func (e *Elasticsearch) ensureTemplate() error {
+	e.templateMu.Lock()
+	defer e.templateMu.Unlock()
+	if e.templateReady {
+		return nil
+	}
+
+	path := "/_index_template/" + e.TemplateName
+	if !e.OverwriteTemplate {
+		resp, err := e.request(context.Background(), http.MethodHead, path, "", nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		switch resp.StatusCode {
+		case http.StatusOK:
+			e.templateReady = true
+			return nil
+		case http.StatusNotFound:
+		default:
+			return fmt.Errorf("status code %d checking the index template", resp.StatusCode)
+		}
+	}
+
+	body, err := json.Marshal(e.indexTemplate())
+	if err != nil {
+		return err
+	}
+	resp, err := e.request(context.Background(), http.MethodPut, path, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, truncate(data))
+	}
+	log.Println("I! elasticsearch index template", e.TemplateName, "put for the indices", e.index.pattern())
+	e.templateReady = true
+	return nil
+}
+
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package graphite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// the reasons of the dropped samples
+const (
+	dropInvalidValue = "invalid_value"
+	dropWriteFailed  = "write_failed"
+)
+
+// metricPath returns the path of the series, e.g. prefix.name.value1.value2, the tag values are
+// in the order of tag_keys or of their keys, the tags without a value are skipped
+func (g *Graphite) metricPath(labels []prompb.Label) string {
+	name := ""
+	tags := make(map[string]string, len(labels))
+	for _, l := range labels {
+		if l.Name == model.MetricNameLabel {
+			name = g.sanitize(l.Value)
+			continue
+		}
+		if v := g.sanitize(l.Value); v != "" {
+			tags[l.Name] = v
+		}
+	}
+	if name == "" {
+		return ""
+	}
+
+	keys := g.TagKeys
+	if len(keys) == 0 {
+		keys = make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := tags[k]; ok {
+			values = append(values, v)
+		}
+	}
+
+	nodes := make([]string, 0, 3)
+	if g.Prefix != "" {
+		nodes = append(nodes, g.Prefix)
+	}
+	if g.TagsFirst && len(values) > 0 {
+		nodes = append(nodes, strings.Join(values, g.TagSeparator))
+	}
+	nodes = append(nodes, name)
+	if !g.TagsFirst && len(values) > 0 {
+		nodes = append(nodes, strings.Join(values, g.TagSeparator))
+	}
+	return strings.Join(nodes, g.Separator)
+}
+
+// sanitize replaces the separators, the whitespaces and the characters carbon does not
+// accept in the nodes of the paths, so a tag value is always one node of the path
+func (g *Graphite) sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r <= ' ' || r == 0x7f:
+			return '_'
+		case r == '(' || r == ')' || r == '"' || r == '\'' || r == '\\':
+			return '_'
+		}
+		return r
+	}, s)
+	for _, sep := range []string{g.Separator, g.TagSeparator} {
+		if sep != "" && strings.Contains(s, sep) {
+			s = strings.ReplaceAll(s, sep, "_")
+		}
+	}
+	return s
+}
+
+// plaintextLine returns the <path> <value> <timestamp> line of the sample, the timestamp in
+// seconds, ok is false for NaN and Inf which carbon stores as gaps at best
+func plaintextLine(path string, s prompb.Sample) (string, bool) {
+	if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+		return "", false
+	}
+	var b strings.Builder
+	b.Grow(len(path) + 32)
+	b.WriteString(path)
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(s.Value, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(s.Timestamp/1000, 10))
+	b.WriteByte('\n')
+	return b.String(), true
+}
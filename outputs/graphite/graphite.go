@@ -0,0 +1,221 @@
+package graphite
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/outputs"
+	tlsx "flashcat.cloud/categraf/pkg/tls"
+)
+
+const outputName = "graphite"
+
+var droppedSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "output_graphite_dropped_samples_total",
+	Help: "Number of samples not written to Graphite, by reason.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(droppedSamplesTotal)
+	outputs.Add(outputName, func() outputs.Output {
+		return &Graphite{}
+	})
+}
+
+type Graphite struct {
+	// host:port of the plaintext listeners of carbon, the servers are tried in a random
+	// order on every write so the writes are spread over a carbon-relay cluster
+	Servers []string `toml:"servers"`
+	// the first nodes of the metric paths, e.g. categraf
+	Prefix string `toml:"prefix"`
+	// joins the prefix, the metric name and the tag values
+	Separator string `toml:"separator"`
+	// joins the tag values, Separator when empty
+	TagSeparator string `toml:"tag_separator"`
+	// the tags of the metric paths in this order, all the tags sorted by key when empty
+	TagKeys []string `toml:"tag_keys"`
+	// the tag values are before the metric name, e.g. categraf.host01.cpu_usage_idle
+	TagsFirst bool            `toml:"tags_first"`
+	Timeout   config.Duration `toml:"timeout"`
+	// idle connections kept per server between the writes
+	MaxIdleConns int `toml:"max_idle_conns"`
+	tlsx.ClientConfig
+
+	tlsConfig *tls.Config
+	pools     []*connPool
+}
+
+func (g *Graphite) Init() error {
+	if len(g.Servers) == 0 {
+		return outputs.ErrDisabled
+	}
+	for _, s := range g.Servers {
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			return fmt.Errorf("invalid server %s, should be host:port: %v", s, err)
+		}
+	}
+	if g.Separator == "" {
+		g.Separator = "."
+	}
+	if g.TagSeparator == "" {
+		g.TagSeparator = g.Separator
+	}
+	if g.Timeout <= 0 {
+		g.Timeout = config.Duration(10 * time.Second)
+	}
+	if g.MaxIdleConns <= 0 {
+		g.MaxIdleConns = 2
+	}
+	if g.UseTLS {
+		tlsConfig, err := g.TLSConfig()
+		if err != nil {
+			return err
+		}
+		g.tlsConfig = tlsConfig
+	}
+	g.pools = make([]*connPool, 0, len(g.Servers))
+	for _, s := range g.Servers {
+		g.pools = append(g.pools, &connPool{addr: s, idle: make(chan net.Conn, g.MaxIdleConns), dial: g.dial})
+	}
+	return nil
+}
+
+func (g *Graphite) dial(addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: time.Duration(g.Timeout)}
+	if g.tlsConfig != nil {
+		return tls.DialWithDialer(d, "tcp", addr, g.tlsConfig)
+	}
+	return d.Dial("tcp", addr)
+}
+
+func (g *Graphite) Write(items []prompb.TimeSeries) {
+	buf, count, dropped := g.serialize(items)
+	if dropped > 0 {
+		droppedSamplesTotal.WithLabelValues(dropInvalidValue).Add(float64(dropped))
+	}
+	if count == 0 {
+		return
+	}
+
+	var err error
+	for _, i := range rand.Perm(len(g.pools)) {
+		if err = g.pools[i].write(buf.Bytes(), time.Duration(g.Timeout)); err == nil {
+			return
+		}
+		log.Println("W! failed to write to graphite server", g.pools[i].addr, "error:", err)
+	}
+	log.Println("E! failed to write", count, "samples to graphite servers", g.Servers, "error:", err)
+	droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(count))
+}
+
+// connPool keeps the idle connections of a server, a connection is taken for one write
+// and put back after it succeeded
+type connPool struct {
+	addr string
+	idle chan net.Conn
+	dial func(addr string) (net.Conn, error)
+}
+
+// write writes the lines on an idle connection or a new one, the write on an idle connection
+// is retried on a new one, e.g. after carbon restarted. The lines written again replace the
+// same points in carbon.
+func (p *connPool) write(b []byte, timeout time.Duration) error {
+	conn, reused, err := p.get()
+	if err != nil {
+		return err
+	}
+	if err = writeConn(conn, b, timeout); err == nil {
+		p.put(conn)
+		return nil
+	}
+	conn.Close()
+	if !reused {
+		return err
+	}
+
+	if conn, err = p.dial(p.addr); err != nil {
+		return err
+	}
+	if err = writeConn(conn, b, timeout); err != nil {
+		conn.Close()
+		return err
+	}
+	p.put(conn)
+	return nil
+}
+
+func writeConn(conn net.Conn, b []byte, timeout time.Duration) error {
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := conn.Write(b)
+	return err
+}
+
+func (p *connPool) get() (net.Conn, bool, error) {
+	for {
+		select {
+		case conn := <-p.idle:
+			if alive(conn) {
+				return conn, true, nil
+			}
+			conn.Close()
+		default:
+			conn, err := p.dial(p.addr)
+			return conn, false, err
+		}
+	}
+}
+
+func (p *connPool) put(conn net.Conn) {
+	select {
+	case p.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// alive reports whether the connection is still open, carbon never writes on the plaintext
+// connections so a read returns only when the connection was closed
+func alive(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	conn.SetReadDeadline(time.Time{})
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// serialize returns the plaintext lines of the samples, their count, and the count of the
+// samples dropped for their value
+func (g *Graphite) serialize(items []prompb.TimeSeries) (*bytes.Buffer, int, int) {
+	var (
+		buf     bytes.Buffer
+		count   int
+		dropped int
+	)
+	for _, item := range items {
+		path := g.metricPath(item.Labels)
+		if path == "" {
+			continue
+		}
+		for _, s := range item.Samples {
+			line, ok := plaintextLine(path, s)
+			if !ok {
+				dropped++
+				continue
+			}
+			buf.WriteString(line)
+			count++
+		}
+	}
+	return &buf, count, dropped
+}
@@ -0,0 +1,86 @@
+package graphite
+
+import (
+	"bufio"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestMetricPath(t *testing.T) {
+	labels := []prompb.Label{
+		{Name: "__name__", Value: "cpu_usage_idle"},
+		{Name: "ident", Value: "host.01"},
+		{Name: "cpu", Value: "cpu-total"},
+		{Name: "empty", Value: ""},
+	}
+	cases := []struct {
+		g    Graphite
+		want string
+	}{
+		{Graphite{Prefix: "categraf"}, "categraf.cpu_usage_idle.cpu-total.host_01"},
+		{Graphite{TagKeys: []string{"ident", "cpu"}, TagsFirst: true}, "host_01.cpu-total.cpu_usage_idle"},
+		{Graphite{Prefix: "categraf", Separator: ".", TagSeparator: "-", TagKeys: []string{"ident"}}, "categraf.cpu_usage_idle.host_01"},
+	}
+	for _, c := range cases {
+		g := c.g
+		g.Servers = []string{"127.0.0.1:2003"}
+		if err := g.Init(); err != nil {
+			t.Fatal(err)
+		}
+		if got := g.metricPath(labels); got != c.want {
+			t.Errorf("got %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestWriteReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+					// carbon restarted
+					return
+				}
+			}(conn)
+		}
+	}()
+
+	g := &Graphite{Servers: []string{ln.Addr().String()}}
+	if err := g.Init(); err != nil {
+		t.Fatal(err)
+	}
+	items := []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "system_load1"}, {Name: "ident", Value: "host01"}},
+		Samples: []prompb.Sample{{Timestamp: 1700000000500, Value: 1.5}, {Timestamp: 1700000015000, Value: math.NaN()}},
+	}}
+	for i := 0; i < 2; i++ {
+		g.Write(items)
+		select {
+		case line := <-lines:
+			if line != "system_load1.host01 1.5 1700000000" {
+				t.Fatalf("unexpected line %q", line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("write %d not received", i)
+		}
+		// let the server close the connection
+		time.Sleep(50 * time.Millisecond)
+	}
+}
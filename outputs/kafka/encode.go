@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// avroCanonicalSchema is the parsing canonical form of the schema of the avro messages,
+// fingerprinted by the single object encoding, the timestamp is a timestamp-millis
+const avroCanonicalSchema = `{"name":"categraf.Metric","type":"record","fields":[` +
+	`{"name":"name","type":"string"},` +
+	`{"name":"timestamp","type":"long"},` +
+	`{"name":"value","type":"double"},` +
+	`{"name":"tags","type":{"type":"map","values":"string"}}]}`
+
+var avroFingerprint = fingerprint64([]byte(avroCanonicalSchema))
+
+type metric struct {
+	name      string
+	timestamp int64
+	value     float64
+	tags      map[string]string
+	// sorted names of tags
+	keys []string
+}
+
+// tagValue returns the value of the tag, or of the first tag when name is empty
+func (m *metric) tagValue(name string) string {
+	if name != "" {
+		return m.tags[name]
+	}
+	if len(m.keys) == 0 {
+		return ""
+	}
+	return m.tags[m.keys[0]]
+}
+
+// toMetrics converts every sample to a metric, the labels other than the name become the tags
+func toMetrics(items []prompb.TimeSeries) []metric {
+	metrics := make([]metric, 0, len(items))
+	for _, item := range items {
+		name := ""
+		tags := make(map[string]string, len(item.Labels))
+		keys := make([]string, 0, len(item.Labels))
+		for _, l := range item.Labels {
+			if l.Name == model.MetricNameLabel {
+				name = l.Value
+				continue
+			}
+			tags[l.Name] = l.Value
+			keys = append(keys, l.Name)
+		}
+		if name == "" {
+			continue
+		}
+		sort.Strings(keys)
+		for _, s := range item.Samples {
+			metrics = append(metrics, metric{name: name, timestamp: s.Timestamp, value: s.Value, tags: tags, keys: keys})
+		}
+	}
+	return metrics
+}
+
+type jsonMetric struct {
+	Name      string            `json:"name"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// encodeJSON fails on NaN and Inf
+func encodeJSON(m *metric) ([]byte, error) {
+	return json.Marshal(jsonMetric{Name: m.name, Timestamp: m.timestamp, Value: m.value, Tags: m.tags})
+}
+
+// encodeAvro encodes m in the avro binary encoding, framed with the magic byte and the id of
+// the schema registry when schemaID is set, with the single object encoding otherwise
+func encodeAvro(m *metric, schemaID int) []byte {
+	buf := make([]byte, 0, 64+len(m.name)+len(m.keys)*32)
+	if schemaID > 0 {
+		buf = append(buf, 0)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(schemaID))
+	} else {
+		buf = append(buf, 0xc3, 0x01)
+		buf = binary.LittleEndian.AppendUint64(buf, avroFingerprint)
+	}
+	buf = appendAvroString(buf, m.name)
+	buf = binary.AppendVarint(buf, m.timestamp)
+	buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(m.value))
+	if len(m.keys) > 0 {
+		buf = binary.AppendVarint(buf, int64(len(m.keys)))
+		for _, k := range m.keys {
+			buf = appendAvroString(buf, k)
+			buf = appendAvroString(buf, m.tags[k])
+		}
+	}
+	// the end of the map blocks
+	return binary.AppendVarint(buf, 0)
+}
+
+func appendAvroString(buf []byte, s string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(s)))
+	return append(buf, s...)
+}
+
+// fingerprint64 is the CRC-64-AVRO fingerprint of the avro specification
+func fingerprint64(buf []byte) uint64 {
+	const empty = 0xc15d213aa4d7a795
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (empty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+	fp := uint64(empty)
+	for _, b := range buf {
+		fp = (fp >> 8) ^ table[byte(fp)^b]
+	}
+	return fp
+}
@@ -0,0 +1,263 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/xdg/scram"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/outputs"
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+const outputName = "kafka"
+
+type Kafka struct {
+	Brokers []string `toml:"brokers"`
+	Topic   string   `toml:"topic"`
+	// e.g. 3.3.2, defaults to the version of sarama
+	KafkaVersion string `toml:"kafka_version"`
+	ClientID     string `toml:"client_id"`
+	// json or avro, a message per sample
+	Format string `toml:"format"`
+	// the avro messages are framed with the schema id of the confluent schema registry when set,
+	// with the single object encoding of avro otherwise
+	AvroSchemaID int `toml:"avro_schema_id"`
+	// round_robin, metric (hash by the name) or tag (hash by the value of partition_tag,
+	// the first tag in the order of the names when empty)
+	PartitionStrategy string `toml:"partition_strategy"`
+	PartitionTag      string `toml:"partition_tag"`
+	// 0: no response, 1: the leader, -1: all the in-sync replicas
+	RequiredAcks *int `toml:"required_acks"`
+	// "", gzip, snappy, lz4 or zstd
+	CompressionCodec string          `toml:"compression_codec"`
+	Timeout          config.Duration `toml:"timeout"`
+	MaxRetry         int             `toml:"max_retry"`
+	MaxMessageBytes  int             `toml:"max_message_bytes"`
+
+	// PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512
+	SaslMechanism string `toml:"sasl_mechanism"`
+	SaslUsername  string `toml:"sasl_username"`
+	SaslPassword  string `toml:"sasl_password"`
+	// 0 or 1, 1 for the recent brokers
+	SaslVersion *int16 `toml:"sasl_version"`
+	tls.ClientConfig
+
+	config   *sarama.Config
+	mu       sync.Mutex
+	producer sarama.SyncProducer
+	encode   func(m *metric) ([]byte, error)
+	key      func(m *metric) sarama.Encoder
+}
+
+func init() {
+	outputs.Add(outputName, func() outputs.Output {
+		return &Kafka{}
+	})
+}
+
+func (k *Kafka) Init() error {
+	if len(k.Brokers) == 0 {
+		return outputs.ErrDisabled
+	}
+	if k.Topic == "" {
+		k.Topic = "categraf_metrics"
+	}
+	if k.ClientID == "" {
+		k.ClientID = "categraf"
+	}
+	if k.Timeout <= 0 {
+		k.Timeout = config.Duration(10 * time.Second)
+	}
+
+	cfg := sarama.NewConfig()
+	cfg.ClientID = k.ClientID
+	cfg.Producer.Return.Successes = true
+	cfg.Producer.Timeout = time.Duration(k.Timeout)
+	cfg.Net.DialTimeout = time.Duration(k.Timeout)
+	cfg.Producer.RequiredAcks = sarama.WaitForAll
+	if k.RequiredAcks != nil {
+		switch *k.RequiredAcks {
+		case 0, 1, -1:
+			cfg.Producer.RequiredAcks = sarama.RequiredAcks(*k.RequiredAcks)
+		default:
+			return fmt.Errorf("invalid required_acks %d, should be 0, 1 or -1", *k.RequiredAcks)
+		}
+	}
+	if k.MaxRetry > 0 {
+		cfg.Producer.Retry.Max = k.MaxRetry
+	}
+	if k.MaxMessageBytes > 0 {
+		cfg.Producer.MaxMessageBytes = k.MaxMessageBytes
+	}
+	if k.KafkaVersion != "" {
+		version, err := sarama.ParseKafkaVersion(k.KafkaVersion)
+		if err != nil {
+			return err
+		}
+		cfg.Version = version
+	}
+
+	switch k.CompressionCodec {
+	case "", "none":
+	case "gzip":
+		cfg.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		cfg.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		cfg.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		cfg.Producer.Compression = sarama.CompressionZSTD
+		if !cfg.Version.IsAtLeast(sarama.V2_1_0_0) {
+			// zstd needs the produce request v7
+			cfg.Version = sarama.V2_1_0_0
+		}
+	default:
+		return fmt.Errorf("unsupported compression_codec %q, should be gzip, snappy, lz4 or zstd", k.CompressionCodec)
+	}
+
+	switch k.PartitionStrategy {
+	case "", "round_robin":
+		cfg.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+		k.key = func(*metric) sarama.Encoder { return nil }
+	case "metric":
+		cfg.Producer.Partitioner = sarama.NewHashPartitioner
+		k.key = func(m *metric) sarama.Encoder { return sarama.StringEncoder(m.name) }
+	case "tag":
+		cfg.Producer.Partitioner = sarama.NewHashPartitioner
+		k.key = func(m *metric) sarama.Encoder { return sarama.StringEncoder(m.tagValue(k.PartitionTag)) }
+	default:
+		return fmt.Errorf("unsupported partition_strategy %q, should be round_robin, metric or tag", k.PartitionStrategy)
+	}
+
+	switch k.Format {
+	case "", "json":
+		k.encode = encodeJSON
+	case "avro":
+		k.encode = func(m *metric) ([]byte, error) { return encodeAvro(m, k.AvroSchemaID), nil }
+	default:
+		return fmt.Errorf("unsupported format %q, should be json or avro", k.Format)
+	}
+
+	if err := k.setSASL(cfg); err != nil {
+		return err
+	}
+	if k.UseTLS {
+		tlsConfig, err := k.TLSConfig()
+		if err != nil {
+			return err
+		}
+		cfg.Net.TLS.Enable = true
+		cfg.Net.TLS.Config = tlsConfig
+	}
+
+	// the producer connects on the first write, an unreachable cluster does not block the agent
+	k.config = cfg
+	return nil
+}
+
+// getProducer returns the producer, connected again after a failure
+func (k *Kafka) getProducer() (sarama.SyncProducer, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.producer != nil {
+		return k.producer, nil
+	}
+	producer, err := sarama.NewSyncProducer(k.Brokers, k.config)
+	if err != nil {
+		return nil, err
+	}
+	k.producer = producer
+	return producer, nil
+}
+
+func (k *Kafka) setSASL(cfg *sarama.Config) error {
+	if k.SaslMechanism == "" {
+		return nil
+	}
+	cfg.Net.SASL.Enable = true
+	cfg.Net.SASL.User = k.SaslUsername
+	cfg.Net.SASL.Password = k.SaslPassword
+	cfg.Net.SASL.Handshake = true
+	if k.SaslVersion != nil {
+		cfg.Net.SASL.Version = *k.SaslVersion
+	}
+	switch strings.ToUpper(k.SaslMechanism) {
+	case sarama.SASLTypePlaintext:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case sarama.SASLTypeSCRAMSHA256:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: func() hash.Hash { return sha256.New() }}
+		}
+	case sarama.SASLTypeSCRAMSHA512:
+		cfg.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		cfg.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hashGenerator: func() hash.Hash { return sha512.New() }}
+		}
+	default:
+		return fmt.Errorf("unsupported sasl_mechanism %q, should be PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", k.SaslMechanism)
+	}
+	return nil
+}
+
+func (k *Kafka) Write(items []prompb.TimeSeries) {
+	metrics := toMetrics(items)
+	if len(metrics) == 0 {
+		return
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, len(metrics))
+	for i := range metrics {
+		value, err := k.encode(&metrics[i])
+		if err != nil {
+			// e.g. NaN, which json does not represent
+			continue
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic: k.Topic,
+			Key:   k.key(&metrics[i]),
+			Value: sarama.ByteEncoder(value),
+		})
+	}
+
+	producer, err := k.getProducer()
+	if err != nil {
+		log.Println("E! failed to connect to kafka", k.Brokers, ", dropped", len(msgs), "samples, error:", err)
+		return
+	}
+	err = producer.SendMessages(msgs)
+	if err == nil {
+		return
+	}
+	var errs sarama.ProducerErrors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		log.Println("E! failed to produce", len(errs), "of", len(msgs), "samples to kafka topic", k.Topic, "error:", errs[0].Err)
+		return
+	}
+	log.Println("E! failed to produce", len(msgs), "samples to kafka topic", k.Topic, "error:", err)
+}
+
+// scramClient implements sarama.SCRAMClient
+type scramClient struct {
+	*scram.ClientConversation
+	hashGenerator scram.HashGeneratorFcn
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hashGenerator.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.ClientConversation = client.NewConversation()
+	return nil
+}
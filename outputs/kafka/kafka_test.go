@@ -0,0 +1,83 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+var testSeries = []prompb.TimeSeries{
+	{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "cpu_usage_idle"}, {Name: "ident", Value: "host01"}, {Name: "cpu", Value: "cpu0"}},
+		Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 98.5}},
+	},
+	{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "system_load1"}},
+		Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: math.NaN()}},
+	},
+}
+
+func TestEncode(t *testing.T) {
+	metrics := toMetrics(testSeries)
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 metrics, got %d", len(metrics))
+	}
+	if v := metrics[0].tagValue(""); v != "cpu0" {
+		t.Fatalf("expected the value of the first tag cpu, got %s", v)
+	}
+
+	b, err := encodeJSON(&metrics[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"name":"cpu_usage_idle","timestamp":1700000000000,"value":98.5,"tags":{"cpu":"cpu0","ident":"host01"}}`
+	if string(b) != want {
+		t.Fatalf("got %s, want %s", b, want)
+	}
+	if _, err := encodeJSON(&metrics[1]); err == nil {
+		t.Fatal("expected NaN to fail in json")
+	}
+
+	b = encodeAvro(&metrics[0], 0)
+	if !bytes.HasPrefix(b, []byte{0xc3, 0x01}) || binary.LittleEndian.Uint64(b[2:10]) != avroFingerprint {
+		t.Fatalf("expected the single object header, got %x", b[:10])
+	}
+	r := bytes.NewReader(b[10:])
+	readString := func() string {
+		n, _ := binary.ReadVarint(r)
+		s := make([]byte, n)
+		r.Read(s)
+		return string(s)
+	}
+	if name := readString(); name != "cpu_usage_idle" {
+		t.Fatalf("unexpected name %s", name)
+	}
+	if ts, _ := binary.ReadVarint(r); ts != 1700000000000 {
+		t.Fatalf("unexpected timestamp %d", ts)
+	}
+	var value float64
+	binary.Read(r, binary.LittleEndian, &value)
+	if value != 98.5 {
+		t.Fatalf("unexpected value %v", value)
+	}
+	if n, _ := binary.ReadVarint(r); n != 2 {
+		t.Fatalf("expected a block of 2 tags, got %d", n)
+	}
+	if k, v := readString(), readString(); k != "cpu" || v != "cpu0" {
+		t.Fatalf("unexpected tag %s=%s", k, v)
+	}
+	if k, v := readString(), readString(); k != "ident" || v != "host01" {
+		t.Fatalf("unexpected tag %s=%s", k, v)
+	}
+	if n, _ := binary.ReadVarint(r); n != 0 || r.Len() != 0 {
+		t.Fatalf("expected the end of the tags")
+	}
+
+	b = encodeAvro(&metrics[1], 7)
+	if !bytes.Equal(b[:5], []byte{0, 0, 0, 0, 7}) {
+		t.Fatalf("expected the schema registry header, got %x", b[:5])
+	}
+}
@@ -0,0 +1,125 @@
+package opentsdb
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// the reasons of the dropped samples
+const (
+	dropNoTags       = "no_tags"
+	dropInvalidValue = "invalid_value"
+	dropRejected     = "rejected"
+	dropWriteFailed  = "write_failed"
+)
+
+type dataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// toDataPoints converts every sample to a data point in milliseconds, the labels other than
+// the name become the tags. The samples OpenTSDB rejects are dropped and counted by reason:
+// the series without any tag and the NaN and Inf values.
+func toDataPoints(items []prompb.TimeSeries, replacement string) ([]dataPoint, map[string]int) {
+	points := make([]dataPoint, 0, len(items))
+	dropped := make(map[string]int)
+	for _, item := range items {
+		name := ""
+		tags := make(map[string]string, len(item.Labels))
+		for _, l := range item.Labels {
+			if l.Name == model.MetricNameLabel {
+				name = sanitize(l.Value, replacement)
+				continue
+			}
+			k, v := sanitize(l.Name, replacement), sanitize(l.Value, replacement)
+			if k == "" || v == "" {
+				// OpenTSDB rejects the empty tag keys and values
+				continue
+			}
+			tags[k] = v
+		}
+		if name == "" {
+			continue
+		}
+		for _, s := range item.Samples {
+			switch {
+			case len(tags) == 0:
+				dropped[dropNoTags]++
+			case math.IsNaN(s.Value) || math.IsInf(s.Value, 0):
+				dropped[dropInvalidValue]++
+			default:
+				points = append(points, dataPoint{Metric: name, Timestamp: s.Timestamp, Value: s.Value, Tags: tags})
+			}
+		}
+	}
+	return points, dropped
+}
+
+// sanitize replaces the characters OpenTSDB does not allow in the metrics and the tags,
+// the allowed ones are the letters, the digits and - _ . /
+func sanitize(s, replacement string) string {
+	ok := true
+	for _, r := range s {
+		if !validRune(r) {
+			ok = false
+			break
+		}
+	}
+	if ok {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if validRune(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteString(replacement)
+		}
+	}
+	return b.String()
+}
+
+func validRune(r rune) bool {
+	switch {
+	case r == '-' || r == '_' || r == '.' || r == '/':
+		return true
+	case r < 0x80:
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+	}
+	return unicode.IsLetter(r)
+}
+
+// telnetLine returns the put command of the telnet API, the tags are sorted by key
+func telnetLine(p *dataPoint) string {
+	keys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("put ")
+	b.WriteString(p.Metric)
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(p.Timestamp, 10))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(p.Value, 'f', -1, 64))
+	for _, k := range keys {
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(p.Tags[k])
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
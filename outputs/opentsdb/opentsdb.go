@@ -0,0 +1,254 @@
+package opentsdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/outputs"
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+const outputName = "opentsdb"
+
+var droppedSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "output_opentsdb_dropped_samples_total",
+	Help: "Number of samples not written to OpenTSDB, by reason.",
+}, []string{"reason"})
+
+func init() {
+	prometheus.MustRegister(droppedSamplesTotal)
+	outputs.Add(outputName, func() outputs.Output {
+		return &OpenTSDB{}
+	})
+}
+
+type OpenTSDB struct {
+	// http://host:4242 or https://host:4242 for the HTTP API, tcp://host:4242 for the telnet API
+	Address string `toml:"address"`
+	// the path of the HTTP API
+	Path string `toml:"path"`
+	// data points per request of the HTTP API, OpenTSDB rejects the requests larger than
+	// tsd.http.request.max_chunk without tsd.http.request.enable_chunked
+	BatchSize int             `toml:"batch_size"`
+	Timeout   config.Duration `toml:"timeout"`
+	// retries of a request failed with a network error or a 5xx, the interval doubles after each retry
+	MaxRetries    int               `toml:"max_retries"`
+	RetryInterval config.Duration   `toml:"retry_interval"`
+	Username      string            `toml:"username"`
+	Password      string            `toml:"password"`
+	Headers       map[string]string `toml:"headers"`
+	// the characters OpenTSDB does not allow in the metrics and the tags are replaced with _,
+	// or removed with strip_illegal_chars
+	StripIllegalChars bool `toml:"strip_illegal_chars"`
+	tls.ClientConfig
+
+	telnet      bool
+	replacement string
+	client      *http.Client
+
+	// the connection of the telnet API, dialed on the first write and after a failure
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (o *OpenTSDB) Init() error {
+	if o.Address == "" {
+		return outputs.ErrDisabled
+	}
+	u, err := url.Parse(o.Address)
+	if err != nil {
+		return fmt.Errorf("failed to parse address %s: %v", o.Address, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "tcp", "telnet":
+		o.telnet = true
+	default:
+		return fmt.Errorf("unsupported address %s, should be http://, https:// or tcp://", o.Address)
+	}
+	if o.Path == "" {
+		o.Path = "/api/put"
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 50
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = config.Duration(10 * time.Second)
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	} else if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = config.Duration(time.Second)
+	}
+	o.replacement = "_"
+	if o.StripIllegalChars {
+		o.replacement = ""
+	}
+
+	if o.telnet {
+		o.Address = u.Host
+		return nil
+	}
+	o.Address = strings.TrimRight(o.Address, "/")
+	trans := &http.Transport{}
+	if o.UseTLS {
+		tlsConfig, err := o.TLSConfig()
+		if err != nil {
+			return err
+		}
+		trans.TLSClientConfig = tlsConfig
+	}
+	o.client = &http.Client{Transport: trans, Timeout: time.Duration(o.Timeout)}
+	return nil
+}
+
+func (o *OpenTSDB) Write(items []prompb.TimeSeries) {
+	points, dropped := toDataPoints(items, o.replacement)
+	for reason, n := range dropped {
+		droppedSamplesTotal.WithLabelValues(reason).Add(float64(n))
+		log.Println("W! dropped", n, "samples not accepted by opentsdb, reason:", reason)
+	}
+	if len(points) == 0 {
+		return
+	}
+	if o.telnet {
+		o.writeTelnet(points)
+		return
+	}
+	for start := 0; start < len(points); start += o.BatchSize {
+		o.writeHTTP(points[start:min(start+o.BatchSize, len(points))])
+	}
+}
+
+// putResponse is the response of /api/put?details
+type putResponse struct {
+	Failed  int `json:"failed"`
+	Success int `json:"success"`
+	Errors  []struct {
+		Error string `json:"error"`
+	} `json:"errors"`
+}
+
+func (o *OpenTSDB) writeHTTP(points []dataPoint) {
+	body, err := json.Marshal(points)
+	if err != nil {
+		log.Println("E! failed to marshal", len(points), "opentsdb data points:", err)
+		droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(len(points)))
+		return
+	}
+
+	interval := time.Duration(o.RetryInterval)
+	for i := 0; ; i++ {
+		retry, err := o.put(body, len(points))
+		if err == nil {
+			return
+		}
+		if i >= o.MaxRetries || !retry {
+			log.Println("E! failed to put", len(points), "data points to", o.Address, "error:", err)
+			droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(len(points)))
+			return
+		}
+		log.Println("W! failed to put data points to", o.Address, "retry in", interval, "error:", err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// put sends a batch, the data points rejected one by one are counted and not retried
+func (o *OpenTSDB) put(body []byte, count int) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, o.Address+o.Path+"?details", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+	if o.Username != "" || o.Password != "" {
+		req.SetBasicAuth(o.Username, o.Password)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode == http.StatusBadRequest:
+		// with details, some of the data points may have been stored
+		var pr putResponse
+		if err := json.Unmarshal(data, &pr); err == nil && pr.Failed > 0 {
+			droppedSamplesTotal.WithLabelValues(dropRejected).Add(float64(pr.Failed))
+			msg := ""
+			if len(pr.Errors) > 0 {
+				msg = pr.Errors[0].Error
+			}
+			log.Println("W! opentsdb", o.Address, "rejected", pr.Failed, "of", count, "data points:", msg)
+			return false, nil
+		}
+	case resp.StatusCode/100 == 5:
+		return true, fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return false, fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+// writeTelnet writes the put commands, the telnet API does not acknowledge them, the
+// connection is dialed again once when the write fails
+func (o *OpenTSDB) writeTelnet(points []dataPoint) {
+	var buf bytes.Buffer
+	for i := range points {
+		buf.WriteString(telnetLine(&points[i]))
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var err error
+	for i := 0; i < 2; i++ {
+		if o.conn == nil {
+			o.conn, err = net.DialTimeout("tcp", o.Address, time.Duration(o.Timeout))
+			if err != nil {
+				break
+			}
+			go o.readErrors(o.conn)
+		}
+		o.conn.SetWriteDeadline(time.Now().Add(time.Duration(o.Timeout)))
+		if _, err = o.conn.Write(buf.Bytes()); err == nil {
+			return
+		}
+		o.conn.Close()
+		o.conn = nil
+	}
+	log.Println("E! failed to put", len(points), "data points to", o.Address, "error:", err)
+	droppedSamplesTotal.WithLabelValues(dropWriteFailed).Add(float64(len(points)))
+}
+
+// readErrors logs the errors OpenTSDB writes back on the telnet connection, e.g.
+// "put: illegal argument: ...", until the connection is closed
+func (o *OpenTSDB) readErrors(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		droppedSamplesTotal.WithLabelValues(dropRejected).Inc()
+		log.Println("W! opentsdb", o.Address, "rejected a data point:", scanner.Text())
+	}
+}
@@ -0,0 +1,71 @@
+package opentsdb
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestToDataPoints(t *testing.T) {
+	items := []prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "node:cpu_usage"}, {Name: "ident", Value: "host 01"}, {Name: "empty", Value: ""}},
+			Samples: []prompb.Sample{{Timestamp: 1700000000005, Value: 98.5}, {Timestamp: 1700000015000, Value: math.NaN()}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "system_load1"}},
+			Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 2}},
+		},
+	}
+
+	points, dropped := toDataPoints(items, "_")
+	if len(points) != 1 || dropped[dropInvalidValue] != 1 || dropped[dropNoTags] != 1 {
+		t.Fatalf("unexpected points %v, dropped %v", points, dropped)
+	}
+	want := "put node_cpu_usage 1700000000005 98.5 ident=host_01\n"
+	if got := telnetLine(&points[0]); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	points, _ = toDataPoints(items[:1], "")
+	if points[0].Metric != "nodecpu_usage" || points[0].Tags["ident"] != "host01" {
+		t.Fatalf("expected the illegal chars to be stripped, got %v", points[0])
+	}
+}
+
+func TestPutDetails(t *testing.T) {
+	var got []dataPoint
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/put" || r.URL.RawQuery != "details" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"failed":1,"success":1,"errors":[{"error":"Unable to parse value to a number"}]}`))
+	}))
+	defer server.Close()
+
+	o := &OpenTSDB{Address: server.URL}
+	if err := o.Init(); err != nil {
+		t.Fatal(err)
+	}
+	before := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropRejected))
+	o.Write([]prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "mem_used"}, {Name: "ident", Value: "host01"}},
+		Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 1}, {Timestamp: 1700000015000, Value: 2}},
+	}})
+	if len(got) != 2 || got[1].Timestamp != 1700000015000 || got[1].Tags["ident"] != "host01" {
+		t.Fatalf("unexpected request %v", got)
+	}
+	if n := testutil.ToFloat64(droppedSamplesTotal.WithLabelValues(dropRejected)) - before; n != 1 {
+		t.Fatalf("expected the rejected data point to be counted, got %v", n)
+	}
+}
@@ -0,0 +1,112 @@
+package otlp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// toMetricsData converts the time series to one resource with one scope, the series of the
+// same name become the data points of one metric. The type of the samples is not known, the
+// series named *_total are exported as cumulative monotonic sums and the others as gauges
+func toMetricsData(items []prompb.TimeSeries, resourceAttributes map[string]string) *metricspb.MetricsData {
+	metrics := make(map[string]*metricspb.Metric)
+	names := make([]string, 0)
+
+	for _, item := range items {
+		name := ""
+		attributes := make([]*commonpb.KeyValue, 0, len(item.Labels))
+		for _, l := range item.Labels {
+			if l.Name == model.MetricNameLabel {
+				name = l.Value
+				continue
+			}
+			attributes = append(attributes, stringKeyValue(l.Name, l.Value))
+		}
+		if name == "" {
+			continue
+		}
+
+		m, has := metrics[name]
+		if !has {
+			m = newMetric(name)
+			metrics[name] = m
+			names = append(names, name)
+		}
+
+		for _, s := range item.Samples {
+			dp := &metricspb.NumberDataPoint{
+				Attributes:   attributes,
+				TimeUnixNano: uint64(s.Timestamp) * 1e6,
+				Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: s.Value},
+			}
+			switch data := m.Data.(type) {
+			case *metricspb.Metric_Sum:
+				data.Sum.DataPoints = append(data.Sum.DataPoints, dp)
+			case *metricspb.Metric_Gauge:
+				data.Gauge.DataPoints = append(data.Gauge.DataPoints, dp)
+			}
+		}
+	}
+
+	scope := &metricspb.ScopeMetrics{
+		Scope:   &commonpb.InstrumentationScope{Name: "categraf", Version: config.Version},
+		Metrics: make([]*metricspb.Metric, 0, len(names)),
+	}
+	for _, name := range names {
+		scope.Metrics = append(scope.Metrics, metrics[name])
+	}
+
+	return &metricspb.MetricsData{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     newResource(resourceAttributes),
+			ScopeMetrics: []*metricspb.ScopeMetrics{scope},
+		}},
+	}
+}
+
+func newMetric(name string) *metricspb.Metric {
+	if strings.HasSuffix(name, "_total") {
+		return &metricspb.Metric{
+			Name: name,
+			Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}},
+		}
+	}
+	return &metricspb.Metric{
+		Name: name,
+		Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}},
+	}
+}
+
+func newResource(attributes map[string]string) *resourcepb.Resource {
+	r := &resourcepb.Resource{}
+	if _, has := attributes["service.name"]; !has {
+		r.Attributes = append(r.Attributes, stringKeyValue("service.name", "categraf"))
+	}
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.Attributes = append(r.Attributes, stringKeyValue(k, attributes[k]))
+	}
+	return r
+}
+
+func stringKeyValue(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,168 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/outputs"
+	"flashcat.cloud/categraf/pkg/tls"
+)
+
+const outputName = "otlp"
+
+type OTLP struct {
+	// host:port of the OTLP gRPC receiver, e.g. an OpenTelemetry Collector
+	Endpoint string          `toml:"endpoint"`
+	Timeout  config.Duration `toml:"timeout"`
+	// "", "gzip" or "zstd"
+	Compression    string `toml:"compression"`
+	MaxMessageSize int    `toml:"max_message_size"`
+	// retries of an export failed with a retryable code, the interval doubles after each retry
+	MaxRetries    int             `toml:"max_retries"`
+	RetryInterval config.Duration `toml:"retry_interval"`
+	// the deadline of the export of a batch with its retries, the batch is dropped beyond
+	RetryTimeout config.Duration `toml:"retry_timeout"`
+	// gRPC metadata sent with every export, e.g. the api key of the backend
+	Headers            map[string]string `toml:"headers"`
+	ResourceAttributes map[string]string `toml:"resource_attributes"`
+	tls.ClientConfig
+
+	conn   *grpc.ClientConn
+	client colmetricspb.MetricsServiceClient
+}
+
+func init() {
+	outputs.Add(outputName, func() outputs.Output {
+		return &OTLP{}
+	})
+}
+
+func (o *OTLP) Init() error {
+	if o.Endpoint == "" {
+		return outputs.ErrDisabled
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = config.Duration(10 * time.Second)
+	}
+	if o.MaxMessageSize <= 0 {
+		o.MaxMessageSize = 4 * 1024 * 1024
+	}
+	if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	} else if o.MaxRetries == 0 {
+		o.MaxRetries = 3
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = config.Duration(time.Second)
+	}
+	if o.RetryTimeout <= 0 {
+		o.RetryTimeout = config.Duration(30 * time.Second)
+	}
+
+	callOpts := []grpc.CallOption{grpc.MaxCallSendMsgSize(o.MaxMessageSize)}
+	switch o.Compression {
+	case "", "none":
+	case "gzip", zstdName:
+		callOpts = append(callOpts, grpc.UseCompressor(o.Compression))
+	default:
+		return fmt.Errorf("unsupported compression %q, should be gzip or zstd", o.Compression)
+	}
+
+	creds := insecure.NewCredentials()
+	if o.UseTLS {
+		tlsConfig, err := o.TLSConfig()
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	// the connection is established in the background and reconnected with backoff
+	conn, err := grpc.Dial(o.Endpoint,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(callOpts...),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: time.Duration(o.Timeout),
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s: %v", o.Endpoint, err)
+	}
+	o.conn = conn
+	o.client = colmetricspb.NewMetricsServiceClient(conn)
+	return nil
+}
+
+func (o *OTLP) Write(items []prompb.TimeSeries) {
+	if len(items) == 0 {
+		return
+	}
+
+	md := toMetricsData(items, o.ResourceAttributes)
+	req := &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: md.ResourceMetrics}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.RetryTimeout))
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	interval := time.Duration(o.RetryInterval)
+	for i := 0; ; i++ {
+		err := o.export(ctx, req)
+		if err == nil {
+			return
+		}
+		if i >= o.MaxRetries || !retryable(err) || ctx.Err() != nil {
+			log.Println("E! failed to export", len(items), "time series to", o.Endpoint, "error:", err)
+			return
+		}
+		if time.Until(deadline) < interval {
+			log.Println("E! failed to export", len(items), "time series to", o.Endpoint, "before retry_timeout, error:", err)
+			return
+		}
+		log.Println("W! failed to export time series to", o.Endpoint, "retry in", interval, "error:", err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+func (o *OTLP) export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(o.Timeout))
+	defer cancel()
+	if len(o.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(o.Headers))
+	}
+
+	resp, err := o.client.Export(ctx, req)
+	if err != nil {
+		return err
+	}
+	if ps := resp.GetPartialSuccess(); ps != nil && ps.GetRejectedDataPoints() > 0 {
+		log.Println("W!", o.Endpoint, "rejected", ps.GetRejectedDataPoints(), "data points:", ps.GetErrorMessage())
+	}
+	return nil
+}
+
+// retryable reports whether the export may succeed later, the codes follow the OTLP specification
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+		codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
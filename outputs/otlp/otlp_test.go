@@ -0,0 +1,168 @@
+package otlp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"flashcat.cloud/categraf/config"
+)
+
+var testSeries = []prompb.TimeSeries{
+	{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "cpu_usage_idle"}, {Name: "cpu", Value: "cpu-total"}},
+		Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 98.5}},
+	},
+	{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "net_bytes_recv_total"}, {Name: "interface", Value: "eth0"}},
+		Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 1024}},
+	},
+	{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "cpu_usage_idle"}, {Name: "cpu", Value: "cpu0"}},
+		Samples: []prompb.Sample{{Timestamp: 1700000000000, Value: 97}},
+	},
+}
+
+func TestToMetricsData(t *testing.T) {
+	md := toMetricsData(testSeries, map[string]string{"deployment.environment": "prod"})
+
+	rm := md.ResourceMetrics[0]
+	if len(rm.Resource.Attributes) != 2 || rm.Resource.Attributes[0].Key != "service.name" {
+		t.Fatalf("unexpected resource attributes: %v", rm.Resource.Attributes)
+	}
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 metrics, got %d", len(metrics))
+	}
+
+	gauge := metrics[0].GetGauge()
+	if metrics[0].Name != "cpu_usage_idle" || gauge == nil || len(gauge.DataPoints) != 2 {
+		t.Fatalf("expected gauge cpu_usage_idle with 2 data points, got %v", metrics[0])
+	}
+	dp := gauge.DataPoints[0]
+	if dp.TimeUnixNano != 1700000000000*1e6 || dp.GetAsDouble() != 98.5 ||
+		dp.Attributes[0].Key != "cpu" || dp.Attributes[0].Value.GetStringValue() != "cpu-total" {
+		t.Fatalf("unexpected data point %v", dp)
+	}
+
+	sum := metrics[1].GetSum()
+	if metrics[1].Name != "net_bytes_recv_total" || sum == nil || !sum.IsMonotonic ||
+		sum.AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("expected cumulative monotonic sum net_bytes_recv_total, got %v", metrics[1])
+	}
+}
+
+type testMetricsServer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+
+	failures int
+	requests []*colmetricspb.ExportMetricsServiceRequest
+	apiKeys  []string
+}
+
+func (s *testMetricsServer) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		s.apiKeys = append(s.apiKeys, md.Get("x-api-key")...)
+	}
+	if s.failures > 0 {
+		s.failures--
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	s.requests = append(s.requests, req)
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestWrite(t *testing.T) {
+	for _, compression := range []string{"", "gzip", "zstd"} {
+		t.Run(compression, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := grpc.NewServer()
+			ms := &testMetricsServer{failures: 1}
+			colmetricspb.RegisterMetricsServiceServer(srv, ms)
+			go srv.Serve(l)
+			defer srv.Stop()
+
+			o := &OTLP{
+				Endpoint:      l.Addr().String(),
+				Compression:   compression,
+				RetryInterval: 1,
+				Headers:       map[string]string{"x-api-key": "secret"},
+			}
+			if err := o.Init(); err != nil {
+				t.Fatal(err)
+			}
+			defer o.conn.Close()
+
+			o.Write(testSeries)
+			if len(ms.requests) != 1 {
+				t.Fatalf("expected 1 request after the retry, got %d", len(ms.requests))
+			}
+			if n := len(ms.requests[0].ResourceMetrics[0].ScopeMetrics[0].Metrics); n != 2 {
+				t.Fatalf("expected 2 metrics, got %d", n)
+			}
+			if len(ms.apiKeys) != 2 || ms.apiKeys[0] != "secret" {
+				t.Fatalf("expected the header with every export, got %v", ms.apiKeys)
+			}
+		})
+	}
+}
+
+func TestWriteRetryTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	ms := &testMetricsServer{failures: 100}
+	colmetricspb.RegisterMetricsServiceServer(srv, ms)
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	o := &OTLP{
+		Endpoint:      l.Addr().String(),
+		MaxRetries:    100,
+		RetryInterval: config.Duration(20 * time.Millisecond),
+		RetryTimeout:  config.Duration(100 * time.Millisecond),
+	}
+	if err := o.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.conn.Close()
+
+	start := time.Now()
+	o.Write(testSeries)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the retries to stop at retry_timeout, took %v", elapsed)
+	}
+	// 20ms, 40ms and then 80ms would pass the deadline
+	if attempts := 100 - ms.failures; attempts < 2 || attempts > 4 {
+		t.Fatalf("expected 2 to 4 attempts before the deadline, got %d", attempts)
+	}
+	if len(ms.requests) != 0 {
+		t.Fatalf("expected no export, got %d", len(ms.requests))
+	}
+}
+
+func TestInitDisabled(t *testing.T) {
+	o := &OTLP{}
+	if err := o.Init(); err == nil {
+		t.Fatal("expected the output to be disabled without endpoint")
+	}
+	o = &OTLP{Endpoint: "127.0.0.1:4317", Compression: "lz4"}
+	if err := o.Init(); err == nil {
+		t.Fatal("expected an error for an unsupported compression")
+	}
+}
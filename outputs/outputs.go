@@ -0,0 +1,25 @@
+package outputs
+
+import (
+	"errors"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ErrDisabled is returned by Init when the output is not configured, the output is skipped
+var ErrDisabled = errors.New("output disabled")
+
+// Output receives the same batches of time series as the remote write writers.
+// The config of an output is loaded from conf/output.<name>/ before Init
+type Output interface {
+	Init() error
+	Write(items []prompb.TimeSeries)
+}
+
+type Creator func() Output
+
+var OutputCreators = map[string]Creator{}
+
+func Add(name string, creator Creator) {
+	OutputCreators[name] = creator
+}
@@ -0,0 +1,77 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"flashcat.cloud/categraf/pkg/jsonx"
+	"flashcat.cloud/categraf/types"
+)
+
+// Parser flattens the JSON objects, or arrays of objects, the nested fields are joined with _.
+// The numbers and the booleans are the fields of the samples named <prefix>_<field>, the
+// fields of tagKeys are labels, the other strings are ignored.
+type Parser struct {
+	prefix  string
+	tagKeys []string
+}
+
+func NewParser(prefix string, tagKeys []string) *Parser {
+	return &Parser{prefix: prefix, tagKeys: tagKeys}
+}
+
+func (p *Parser) Parse(input []byte, slist *types.SampleList) error {
+	var v interface{}
+	if err := json.Unmarshal(input, &v); err != nil {
+		return err
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return p.parseObject(t, slist)
+	case []interface{}:
+		for _, item := range t {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("unexpected JSON array item %T", item)
+			}
+			if err := p.parseObject(obj, slist); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unexpected JSON value %T, an object is expected", v)
+	}
+}
+
+func (p *Parser) parseObject(obj map[string]interface{}, slist *types.SampleList) error {
+	labels := make(map[string]string)
+	for _, key := range p.tagKeys {
+		switch v := obj[key].(type) {
+		case nil:
+		case string:
+			labels[key] = v
+		default:
+			labels[key] = fmt.Sprint(v)
+		}
+		delete(obj, key)
+	}
+
+	flattener := jsonx.JSONFlattener{}
+	if err := flattener.FullFlattenJSON("", obj, false, true); err != nil {
+		return err
+	}
+	fields := make(map[string]interface{}, len(flattener.Fields))
+	for k, v := range flattener.Fields {
+		if b, ok := v.(bool); ok {
+			if b {
+				v = 1
+			} else {
+				v = 0
+			}
+		}
+		fields[k] = v
+	}
+	slist.PushSamples(p.prefix, fields, labels)
+	return nil
+}
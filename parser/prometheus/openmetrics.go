@@ -0,0 +1,93 @@
+package prometheus
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/textparse"
+
+	util "flashcat.cloud/categraf/pkg/metrics"
+	"flashcat.cloud/categraf/pkg/prom"
+	"flashcat.cloud/categraf/types"
+)
+
+const OpenMetricsMediaType = "application/openmetrics-text"
+
+// parseOpenMetrics parses the OpenMetrics text format, which expfmt.TextParser rejects
+// (# EOF, # UNIT, exemplars, info and stateset types). Every series is pushed as is, so
+// histograms and summaries get the same _bucket, _sum, _count names as the text format,
+// the _created series of counters, histograms and summaries are dropped.
+func (p *Parser) parseOpenMetrics(buf []byte, slist *types.SampleList) error {
+	parser := textparse.NewOpenMetricsParser(buf)
+	families := make(map[string]textparse.MetricType)
+
+	for {
+		entry, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch entry {
+		case textparse.EntryType:
+			name, typ := parser.Type()
+			families[string(name)] = typ
+		case textparse.EntrySeries:
+			_, ts, value := parser.Series()
+			if math.IsNaN(value) {
+				continue
+			}
+
+			var lset labels.Labels
+			parser.Metric(&lset)
+			metricName := lset.Get(labels.MetricName)
+			if isCreatedSeries(metricName, families) {
+				continue
+			}
+			if p.IgnoreMetricsFilter != nil && p.IgnoreMetricsFilter.Match(metricName) {
+				continue
+			}
+
+			tags := make(map[string]string, len(lset)+len(p.DefaultTags))
+			for _, l := range lset {
+				if l.Name == labels.MetricName {
+					continue
+				}
+				if p.IgnoreLabelKeysFilter != nil && p.IgnoreLabelKeysFilter.Match(l.Name) {
+					continue
+				}
+				tags[l.Name] = l.Value
+			}
+			for key, value := range p.DefaultTags {
+				tags[key] = value
+			}
+
+			namePrefix := p.NamePrefix
+			if strings.HasPrefix(metricName, namePrefix) {
+				namePrefix = ""
+			}
+			sample := types.NewSample("", prom.BuildMetric(namePrefix, metricName, ""), value, tags)
+			if ts != nil {
+				sample.SetTime(util.GetMetricTime(*ts))
+			}
+			slist.PushFront(sample)
+		}
+	}
+}
+
+func isCreatedSeries(metricName string, families map[string]textparse.MetricType) bool {
+	family, found := strings.CutSuffix(metricName, "_created")
+	if !found {
+		return false
+	}
+	switch families[family] {
+	case textparse.MetricTypeCounter, textparse.MetricTypeHistogram, textparse.MetricTypeSummary:
+		return true
+	}
+	return false
+}
@@ -72,6 +72,9 @@ func (p *Parser) parse(buf []byte, slist *types.SampleList) error {
 func (p *Parser) Parse(buf []byte, slist *types.SampleList) error {
 	var MetricHeaderBytes = []byte(MetricHeader)
 	mediatype, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if mediatype == OpenMetricsMediaType {
+		return p.parseOpenMetrics(buf, slist)
+	}
 	if mediatype == "application/vnd.google.protobuf" || !p.DuplicationAllowed {
 		return p.parse(buf, slist)
 	}
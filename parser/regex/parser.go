@@ -0,0 +1,72 @@
+package regex
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"flashcat.cloud/categraf/types"
+)
+
+var errNoMatch = errors.New("no line matches the pattern")
+
+// Parser extracts the named capture groups of the lines matching the pattern, e.g.
+// `T=(?P<temperature>[0-9.]+) H=(?P<humidity>[0-9.]+) ID=(?P<sensor>\w+)` with the tag key
+// sensor gives the samples <prefix>_temperature and <prefix>_humidity labeled with sensor.
+// The groups which are not tags and not numbers are ignored.
+type Parser struct {
+	re     *regexp.Regexp
+	prefix string
+	tags   map[string]struct{}
+}
+
+func NewParser(pattern, prefix string, tagKeys []string) (*Parser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %v", pattern, err)
+	}
+	named := false
+	for _, name := range re.SubexpNames() {
+		named = named || name != ""
+	}
+	if !named {
+		return nil, fmt.Errorf("regex %q has no named capture group", pattern)
+	}
+	tags := make(map[string]struct{}, len(tagKeys))
+	for _, key := range tagKeys {
+		tags[key] = struct{}{}
+	}
+	return &Parser{re: re, prefix: prefix, tags: tags}, nil
+}
+
+func (p *Parser) Parse(input []byte, slist *types.SampleList) error {
+	matched := false
+	for _, line := range strings.Split(string(input), "\n") {
+		m := p.re.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		matched = true
+		fields := make(map[string]interface{})
+		labels := make(map[string]string)
+		for i, name := range p.re.SubexpNames() {
+			if name == "" || i >= len(m) {
+				continue
+			}
+			if _, ok := p.tags[name]; ok {
+				labels[name] = m[i]
+				continue
+			}
+			if v, err := strconv.ParseFloat(m[i], 64); err == nil {
+				fields[name] = v
+			}
+		}
+		slist.PushSamples(p.prefix, fields, labels)
+	}
+	if !matched {
+		return errNoMatch
+	}
+	return nil
+}
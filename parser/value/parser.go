@@ -0,0 +1,33 @@
+package value
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"flashcat.cloud/categraf/types"
+)
+
+// Parser parses a single number per line, e.g. "23.5", into a sample of the metric
+type Parser struct {
+	metric string
+}
+
+func NewParser(metric string) *Parser {
+	return &Parser{metric: metric}
+}
+
+func (p *Parser) Parse(input []byte, slist *types.SampleList) error {
+	for _, line := range strings.Split(string(input), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value %q", line)
+		}
+		slist.PushSample("", p.metric, v)
+	}
+	return nil
+}
@@ -61,12 +61,14 @@ func LoadConfigByDir(configDir string, configPtr interface{}) error {
 		switch {
 		case strings.HasSuffix(fpath, ".toml"):
 			s.Read(path.Join(configDir, fpath))
-			tBuf = append(tBuf, s.Data()...)
+			tBuf = append(tBuf, ExpandEnv(s.Data())...)
 			tBuf = append(tBuf, []byte("\n")...)
 		case strings.HasSuffix(fpath, ".json"):
-			loaders = append(loaders, &multiconfig.JSONLoader{Path: path.Join(configDir, fpath)})
+			s.Read(path.Join(configDir, fpath))
+			loaders = append(loaders, &multiconfig.JSONLoader{Reader: bytes.NewReader(ExpandEnv(s.Data()))})
 		case strings.HasSuffix(fpath, ".yaml") || strings.HasSuffix(fpath, ".yml"):
-			loaders = append(loaders, &multiconfig.YAMLLoader{Path: path.Join(configDir, fpath)})
+			s.Read(path.Join(configDir, fpath))
+			loaders = append(loaders, &multiconfig.YAMLLoader{Reader: bytes.NewReader(ExpandEnv(s.Data()))})
 		}
 		if s.Err() != nil {
 			return s.Err()
@@ -96,11 +98,11 @@ func LoadConfigs(configs []ConfigWithFormat, configPtr interface{}) error {
 		switch c.Format {
 		case TomlFormat:
 			tBuf = append(tBuf, []byte("\n\n")...)
-			tBuf = append(tBuf, []byte(c.Config)...)
+			tBuf = append(tBuf, ExpandEnv([]byte(c.Config))...)
 		case YamlFormat:
-			yBuf = append(yBuf, []byte(c.Config)...)
+			yBuf = append(yBuf, ExpandEnv([]byte(c.Config))...)
 		case JsonFormat:
-			jBuf = append(jBuf, []byte(c.Config)...)
+			jBuf = append(jBuf, ExpandEnv([]byte(c.Config))...)
 		}
 	}
 
@@ -127,13 +129,14 @@ func LoadSingleConfig(c ConfigWithFormat, configPtr interface{}) error {
 		&multiconfig.EnvironmentLoader{},
 	}
 
+	data := ExpandEnv([]byte(c.Config))
 	switch c.Format {
 	case TomlFormat:
-		loaders = append(loaders, &multiconfig.TOMLLoader{Reader: bytes.NewReader([]byte(c.Config))})
+		loaders = append(loaders, &multiconfig.TOMLLoader{Reader: bytes.NewReader(data)})
 	case YamlFormat:
-		loaders = append(loaders, &multiconfig.YAMLLoader{Reader: bytes.NewReader([]byte(c.Config))})
+		loaders = append(loaders, &multiconfig.YAMLLoader{Reader: bytes.NewReader(data)})
 	case JsonFormat:
-		loaders = append(loaders, &multiconfig.JSONLoader{Reader: bytes.NewReader([]byte(c.Config))})
+		loaders = append(loaders, &multiconfig.JSONLoader{Reader: bytes.NewReader(data)})
 
 	}
 
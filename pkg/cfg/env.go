@@ -0,0 +1,35 @@
+package cfg
+
+import (
+	"os"
+	"regexp"
+)
+
+// envPattern matches ${NAME} and ${NAME:-default}, the references to the secrets,
+// e.g. ${vault:secret/data/db#password}, are left to the secrets package
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces the ${NAME} tokens of the raw config by the value of the environment
+// variables. ${NAME:-default} is replaced by default when NAME is unset or empty. The value
+// is inserted as is, so it's put in a quoted string for the string settings, e.g.
+//
+//	password = "${REDIS_PASSWORD}"
+//	port = ${REDIS_PORT:-6379}
+//
+// A token without default whose variable is unset is kept untouched.
+func ExpandEnv(data []byte) []byte {
+	return envPattern.ReplaceAllFunc(data, func(token []byte) []byte {
+		match := envPattern.FindSubmatch(token)
+		value, has := os.LookupEnv(string(match[1]))
+		if match[2] != nil {
+			if value == "" {
+				return match[3]
+			}
+			return []byte(value)
+		}
+		if !has {
+			return token
+		}
+		return []byte(value)
+	})
+}
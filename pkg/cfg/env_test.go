@@ -0,0 +1,43 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("CATEGRAF_TEST_PASSWORD", `p@ss`)
+	t.Setenv("CATEGRAF_TEST_EMPTY", "")
+
+	cases := map[string]string{
+		`password = "${CATEGRAF_TEST_PASSWORD}"`:        `password = "p@ss"`,
+		`port = ${CATEGRAF_TEST_PORT:-6379}`:            `port = 6379`,
+		`user = "${CATEGRAF_TEST_EMPTY:-root}"`:         `user = "root"`,
+		`user = "${CATEGRAF_TEST_EMPTY}"`:               `user = ""`,
+		`user = "${CATEGRAF_TEST_UNSET}"`:               `user = "${CATEGRAF_TEST_UNSET}"`,
+		`password = "${vault:secret/data/db#password}"`: `password = "${vault:secret/data/db#password}"`,
+	}
+	for in, want := range cases {
+		if got := string(ExpandEnv([]byte(in))); got != want {
+			t.Errorf("ExpandEnv(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestLoadSingleConfigExpandsEnv(t *testing.T) {
+	t.Setenv("CATEGRAF_TEST_PASSWORD", "secret")
+
+	var c struct {
+		Password string `toml:"password"`
+		Port     int    `toml:"port"`
+	}
+	err := LoadSingleConfig(ConfigWithFormat{
+		Config: "password = \"${CATEGRAF_TEST_PASSWORD}\"\nport = ${CATEGRAF_TEST_PORT:-6379}",
+		Format: TomlFormat,
+	}, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Password != "secret" || c.Port != 6379 {
+		t.Errorf("unexpected config %+v", c)
+	}
+}
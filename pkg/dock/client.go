@@ -0,0 +1,29 @@
+package dock
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// NewEnvClient creates a client with the DOCKER_HOST, DOCKER_TLS_VERIFY, DOCKER_CERT_PATH and
+// DOCKER_API_VERSION environment variables, the API version is negotiated with the daemon
+// unless DOCKER_API_VERSION is set. It is shared by the docker input and the logs agent.
+func NewEnvClient() (*client.Client, error) {
+	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+}
+
+// BuildFilter creates the filters of the docker API from an even number of strings, used as key,
+// value pairs, e.g. "status", "running", "label", "app=web".
+// An empty "catch-all" filter can be created by passing no argument
+func BuildFilter(args ...string) (filters.Args, error) {
+	filter := filters.NewArgs()
+	if len(args)%2 != 0 {
+		return filter, fmt.Errorf("an even number of arguments is required")
+	}
+	for i := 0; i < len(args); i += 2 {
+		filter.Add(args[i], args[i+1])
+	}
+	return filter, nil
+}
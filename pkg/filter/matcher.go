@@ -0,0 +1,154 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// Matcher matches strings against a list of patterns, each pattern is compiled to
+// the cheapest form able to match it:
+//
+//	"cpu"         exact string
+//	"cpu_*"       prefix
+//	"*_total"     suffix
+//	"*usage*"     substring
+//	"net[0-9]?"   glob
+//	"/^(a|b)$/"   regular expression, enclosed in slashes
+//
+// The zero value and a nil Matcher match nothing.
+type Matcher struct {
+	exact    map[string]struct{}
+	prefixes []string
+	suffixes []string
+	contains []string
+	globs    []glob.Glob
+	regexps  []*regexp.Regexp
+}
+
+// NewMatcher compiles patterns, a Matcher without patterns matches nothing.
+func NewMatcher(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, p := range patterns {
+		if err := m.add(p); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// MustNewMatcher is like NewMatcher but panics if a pattern can't be compiled.
+func MustNewMatcher(patterns []string) *Matcher {
+	m, err := NewMatcher(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// IsRegexp reports whether pattern is a regular expression of a Matcher.
+func IsRegexp(pattern string) bool {
+	return len(pattern) >= 2 && pattern[0] == '/' && pattern[len(pattern)-1] == '/'
+}
+
+// IsPattern reports whether pattern matches more than the string itself.
+func IsPattern(pattern string) bool {
+	return IsRegexp(pattern) || HasMeta(pattern)
+}
+
+func (m *Matcher) add(p string) error {
+	if IsRegexp(p) {
+		re, err := regexp.Compile(p[1 : len(p)-1])
+		if err != nil {
+			return fmt.Errorf("invalid regexp %s: %v", p, err)
+		}
+		m.regexps = append(m.regexps, re)
+		return nil
+	}
+	if !HasMeta(p) {
+		if m.exact == nil {
+			m.exact = make(map[string]struct{})
+		}
+		m.exact[p] = struct{}{}
+		return nil
+	}
+
+	// the patterns whose only meta characters are leading or trailing stars
+	// don't need a glob
+	inner := strings.TrimSuffix(strings.TrimPrefix(p, "*"), "*")
+	if !HasMeta(inner) {
+		leading, trailing := strings.HasPrefix(p, "*"), strings.HasSuffix(p, "*") && len(p) > 1
+		switch {
+		case inner == "":
+			m.prefixes = append(m.prefixes, "")
+		case leading && trailing:
+			m.contains = append(m.contains, inner)
+		case trailing:
+			m.prefixes = append(m.prefixes, inner)
+		default:
+			m.suffixes = append(m.suffixes, inner)
+		}
+		return nil
+	}
+
+	g, err := glob.Compile(p)
+	if err != nil {
+		return fmt.Errorf("invalid glob %s: %v", p, err)
+	}
+	m.globs = append(m.globs, g)
+	return nil
+}
+
+// Empty reports whether the Matcher has no pattern.
+func (m *Matcher) Empty() bool {
+	return m == nil || len(m.exact) == 0 && len(m.prefixes) == 0 && len(m.suffixes) == 0 &&
+		len(m.contains) == 0 && len(m.globs) == 0 && len(m.regexps) == 0
+}
+
+// Match reports whether s matches one of the patterns.
+func (m *Matcher) Match(s string) bool {
+	if m == nil {
+		return false
+	}
+	if _, ok := m.exact[s]; ok {
+		return true
+	}
+	for _, p := range m.prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	for _, p := range m.suffixes {
+		if strings.HasSuffix(s, p) {
+			return true
+		}
+	}
+	for _, p := range m.contains {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+	for _, g := range m.globs {
+		if g.Match(s) {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAny reports whether one of ss matches one of the patterns.
+func (m *Matcher) MatchAny(ss ...string) bool {
+	for _, s := range ss {
+		if m.Match(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package filter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	m, err := NewMatcher([]string{"cpu", "disk_*", "*_total", "*usage*", "net[0-9]?", "/^mem_(used|free)$/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"cpu":            true,
+		"cpu_usage":      true,
+		"cpus":           false,
+		"disk_used":      true,
+		"disk":           false,
+		"requests_total": true,
+		"total":          false,
+		"gpu_usage_pct":  true,
+		"net1a":          true,
+		"net10a":         false,
+		"mem_used":       true,
+		"mem_used_pct":   false,
+		"":               false,
+	}
+	for s, want := range cases {
+		if got := m.Match(s); got != want {
+			t.Errorf("%q: got %v, want %v", s, got, want)
+		}
+	}
+	if !m.MatchAny("swap", "mem_free") || m.MatchAny("swap", "load1") {
+		t.Error("unexpected MatchAny result")
+	}
+}
+
+func TestMatcherEmpty(t *testing.T) {
+	var nilMatcher *Matcher
+	m, _ := NewMatcher(nil)
+	for _, m := range []*Matcher{nilMatcher, m} {
+		if !m.Empty() || m.Match("") || m.Match("cpu") {
+			t.Fatalf("expected %v to match nothing", m)
+		}
+	}
+	if all := MustNewMatcher([]string{"*"}); !all.Match("") || !all.Match("cpu") {
+		t.Fatal("expected * to match everything")
+	}
+}
+
+func TestMatcherInvalid(t *testing.T) {
+	for _, p := range []string{"/(unclosed/", "net[0-9"} {
+		if _, err := NewMatcher([]string{p}); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+	for p, want := range map[string]bool{"cpu": false, "cpu*": true, "/cpu/": true, "/": false} {
+		if got := IsPattern(p); got != want {
+			t.Errorf("IsPattern(%q): got %v, want %v", p, got, want)
+		}
+	}
+}
+
+func benchmarkMatcher(b *testing.B, patterns []string, s string) {
+	m := MustNewMatcher(patterns)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Match(s)
+	}
+}
+
+// patterns returns n patterns like format, e.g. the namepass of an input
+func patterns(format string, n int) []string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = fmt.Sprintf(format, i)
+	}
+	return ps
+}
+
+func BenchmarkMatcherExact(b *testing.B) {
+	benchmarkMatcher(b, patterns("metric_%d", 100), "metric_99")
+}
+
+func BenchmarkMatcherPrefix(b *testing.B) {
+	benchmarkMatcher(b, patterns("metric_%d_*", 10), "metric_9_requests_total")
+}
+
+func BenchmarkMatcherSuffix(b *testing.B) {
+	benchmarkMatcher(b, patterns("*_%d_total", 10), "http_requests_9_total")
+}
+
+func BenchmarkMatcherGlob(b *testing.B) {
+	benchmarkMatcher(b, patterns("metric_%d_*_total", 10), "metric_9_requests_total")
+}
+
+func BenchmarkMatcherRegexp(b *testing.B) {
+	benchmarkMatcher(b, patterns("/^metric_%d_.*_total$/", 10), "metric_9_requests_total")
+}
+
+// BenchmarkRegexpAlternation is the single regexp written for the patterns of BenchmarkMatcherPrefix
+func BenchmarkRegexpAlternation(b *testing.B) {
+	benchmarkMatcher(b, []string{"/^metric_(0|1|2|3|4|5|6|7|8|9)_/"}, "metric_9_requests_total")
+}
@@ -0,0 +1,131 @@
+package secrets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+)
+
+const defaultKubernetesTimeout = 10 * time.Second
+
+// kubernetesProvider reads the secrets of Kubernetes, e.g. k8s:monitoring/mysql#password
+// reads the password key of the secret mysql of the namespace monitoring
+type kubernetesProvider struct {
+	conf   *config.KubernetesSecretProvider
+	client *http.Client
+}
+
+func newKubernetesProvider(c *config.KubernetesSecretProvider) (*kubernetesProvider, error) {
+	if c.MountDir == "" && !c.UseAPI {
+		return nil, fmt.Errorf("either mount_dir or use_api is required")
+	}
+	p := &kubernetesProvider{conf: c}
+	if !c.UseAPI {
+		return p, nil
+	}
+
+	if c.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("api_server is required out of a kubernetes cluster")
+		}
+		c.APIServer = "https://" + net.JoinHostPort(host, port)
+	}
+	if c.TokenFile == "" {
+		c.TokenFile = serviceAccountPath + "/token"
+	}
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		// trust the cluster CA given to the pods
+		tlsConfig = &tls.Config{}
+		if ca, err := os.ReadFile(serviceAccountPath + "/ca.crt"); err == nil {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(ca)
+			tlsConfig.RootCAs = pool
+		}
+	}
+	timeout := time.Duration(c.Timeout)
+	if timeout <= 0 {
+		timeout = defaultKubernetesTimeout
+	}
+	p.client = &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+	return p, nil
+}
+
+func (k *kubernetesProvider) Resolve(path, key string) (string, time.Duration, error) {
+	namespace, name, found := strings.Cut(path, "/")
+	if !found || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", 0, fmt.Errorf("invalid secret %s, expecting <namespace>/<name>", path)
+	}
+
+	if k.conf.MountDir != "" {
+		bs, err := os.ReadFile(filepath.Join(k.conf.MountDir, namespace, name, key))
+		if err == nil {
+			return strings.TrimRight(string(bs), "\r\n"), 0, nil
+		}
+		if !os.IsNotExist(err) || !k.conf.UseAPI {
+			return "", 0, err
+		}
+	}
+	return k.fromAPI(namespace, name, key)
+}
+
+func (k *kubernetesProvider) fromAPI(namespace, name, key string) (string, time.Duration, error) {
+	// the projected tokens are rotated, the file is read on each request
+	token, err := os.ReadFile(k.conf.TokenFile)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read service account token: %v", err)
+	}
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s", strings.TrimRight(k.conf.APIServer, "/"), namespace, name)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	res, err := k.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer res.Body.Close()
+	bs, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("get secret %s/%s: %s", namespace, name, res.Status)
+	}
+
+	var secret struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(bs, &secret); err != nil {
+		return "", 0, fmt.Errorf("invalid secret %s/%s: %v", namespace, name, err)
+	}
+	encoded, has := secret.Data[key]
+	if !has {
+		return "", 0, fmt.Errorf("key %s not found in secret %s/%s", key, namespace, name)
+	}
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid key %s of secret %s/%s: %v", key, namespace, name, err)
+	}
+	return string(value), 0, nil
+}
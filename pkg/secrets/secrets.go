@@ -0,0 +1,216 @@
+// Package secrets resolves the references to the secrets of external stores found in the
+// input configs. A reference, ${<provider>:<path>#<key>}, is put in a double quoted string:
+//
+//	password = "${vault:secret/data/db#password}"
+//	password = "${k8s:monitoring/mysql#password}"
+//
+// The resolved values are cached until their lease or the refresh interval expires, then
+// re-resolved so the rotated credentials are picked up.
+package secrets
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/cfg"
+)
+
+const (
+	defaultRefreshInterval = 5 * time.Minute
+	// retryInterval is the delay before resolving again a secret which failed
+	retryInterval = 30 * time.Second
+)
+
+var referencePattern = regexp.MustCompile(`\$\{(\w+):([^}#]+)#([^}]+)\}`)
+
+// valueEscaper escapes the values for the double quoted strings of TOML, JSON and YAML
+var valueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// Provider fetches the secrets of a store
+type Provider interface {
+	// Resolve returns the value of the key of the secret at path and how long it may be
+	// cached, zero for the refresh interval
+	Resolve(path, key string) (string, time.Duration, error)
+}
+
+type value struct {
+	secret   string
+	resolved bool
+	expires  time.Time
+}
+
+// Manager resolves the references and caches their values
+type Manager struct {
+	providers map[string]Provider
+	interval  time.Duration
+
+	mu     sync.Mutex
+	values map[string]*value
+}
+
+// NewManager returns a Manager with the configured providers
+func NewManager(c *config.SecretProviders) (*Manager, error) {
+	m := &Manager{
+		providers: make(map[string]Provider),
+		interval:  time.Duration(c.RefreshInterval),
+		values:    make(map[string]*value),
+	}
+	if m.interval <= 0 {
+		m.interval = defaultRefreshInterval
+	}
+	if c.Vault != nil {
+		p, err := newVaultProvider(c.Vault)
+		if err != nil {
+			return nil, fmt.Errorf("vault secret provider: %v", err)
+		}
+		m.providers["vault"] = p
+	}
+	if c.Kubernetes != nil {
+		p, err := newKubernetesProvider(c.Kubernetes)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes secret provider: %v", err)
+		}
+		m.providers["k8s"] = p
+	}
+	return m, nil
+}
+
+// References returns the distinct references of the configs
+func References(configs []cfg.ConfigWithFormat) []string {
+	seen := make(map[string]struct{})
+	var refs []string
+	for _, c := range configs {
+		for _, ref := range referencePattern.FindAllString(c.Config, -1) {
+			if _, has := seen[ref]; !has {
+				seen[ref] = struct{}{}
+				refs = append(refs, ref)
+			}
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Interpolate returns the configs whose references are replaced by the values of the
+// secrets. The references which can't be resolved are returned in the error, they are
+// tried again by Refresh.
+func (m *Manager) Interpolate(configs []cfg.ConfigWithFormat) ([]cfg.ConfigWithFormat, error) {
+	var failed []string
+	for _, ref := range References(configs) {
+		if _, err := m.get(ref); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", ref, err))
+		}
+	}
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("failed to resolve secrets: %s", strings.Join(failed, "; "))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved := make([]cfg.ConfigWithFormat, len(configs))
+	for i, c := range configs {
+		c.Config = referencePattern.ReplaceAllStringFunc(c.Config, func(ref string) string {
+			return valueEscaper.Replace(m.values[ref].secret)
+		})
+		resolved[i] = c
+	}
+	return resolved, nil
+}
+
+// get returns the cached value of the reference, resolving it on first use
+func (m *Manager) get(ref string) (string, error) {
+	m.mu.Lock()
+	v, has := m.values[ref]
+	m.mu.Unlock()
+	if has && v.resolved {
+		return v.secret, nil
+	}
+
+	secret, ttl, err := m.resolve(ref)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.values[ref] = &value{expires: time.Now().Add(retryInterval)}
+		return "", err
+	}
+	m.values[ref] = &value{secret: secret, resolved: true, expires: time.Now().Add(ttl)}
+	return secret, nil
+}
+
+func (m *Manager) resolve(ref string) (string, time.Duration, error) {
+	match := referencePattern.FindStringSubmatch(ref)
+	if match == nil {
+		return "", 0, fmt.Errorf("invalid secret reference")
+	}
+	p, has := m.providers[match[1]]
+	if !has {
+		return "", 0, fmt.Errorf("secret provider %s not configured", match[1])
+	}
+	secret, ttl, err := p.Resolve(strings.TrimSpace(match[2]), strings.TrimSpace(match[3]))
+	if err != nil {
+		return "", 0, err
+	}
+	if ttl <= 0 || ttl > m.interval {
+		ttl = m.interval
+	}
+	return secret, ttl, nil
+}
+
+// Refresh resolves again the expired references in use and returns the ones whose value
+// changed, including the ones resolved for the first time. On failure the previous value
+// is kept. The references not in use any more are forgotten.
+func (m *Manager) Refresh(inUse map[string]struct{}) []string {
+	now := time.Now()
+	var due []string
+	m.mu.Lock()
+	for ref := range m.values {
+		if _, has := inUse[ref]; !has {
+			delete(m.values, ref)
+		}
+	}
+	for ref := range inUse {
+		if v, has := m.values[ref]; !has || !now.Before(v.expires) {
+			due = append(due, ref)
+		}
+	}
+	m.mu.Unlock()
+
+	var changed []string
+	for _, ref := range due {
+		secret, ttl, err := m.resolve(ref)
+		m.mu.Lock()
+		v, has := m.values[ref]
+		if !has {
+			v = &value{}
+			m.values[ref] = v
+		}
+		if err != nil {
+			v.expires = time.Now().Add(retryInterval)
+			if v.resolved {
+				log.Printf("W! failed to refresh secret %s, keeping the previous value: %v", ref, err)
+			} else {
+				log.Printf("W! failed to resolve secret %s: %v", ref, err)
+			}
+		} else {
+			if !v.resolved || v.secret != secret {
+				changed = append(changed, ref)
+			}
+			v.secret, v.resolved, v.expires = secret, true, time.Now().Add(ttl)
+		}
+		m.mu.Unlock()
+	}
+	sort.Strings(changed)
+	return changed
+}
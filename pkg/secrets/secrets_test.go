@@ -0,0 +1,231 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/pkg/cfg"
+)
+
+type fakeProvider struct {
+	values map[string]string
+	err    error
+}
+
+func (f *fakeProvider) Resolve(path, key string) (string, time.Duration, error) {
+	if f.err != nil {
+		return "", 0, f.err
+	}
+	v, has := f.values[path+"#"+key]
+	if !has {
+		return "", 0, errors.New("not found")
+	}
+	return v, 0, nil
+}
+
+func newTestManager(p Provider) *Manager {
+	return &Manager{
+		providers: map[string]Provider{"vault": p},
+		interval:  time.Minute,
+		values:    make(map[string]*value),
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{
+		"secret/data/db#password": `p"a\ss`,
+		"secret/data/db#user":     "root",
+	}}
+	m := newTestManager(p)
+
+	configs := []cfg.ConfigWithFormat{{
+		Config: `username = "${vault:secret/data/db#user}"
+password = "${vault:secret/data/db#password}"`,
+		Format: cfg.TomlFormat,
+	}}
+	if refs := References(configs); !reflect.DeepEqual(refs, []string{
+		"${vault:secret/data/db#password}",
+		"${vault:secret/data/db#user}",
+	}) {
+		t.Fatalf("unexpected references %v", refs)
+	}
+
+	resolved, err := m.Interpolate(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `username = "root"
+password = "p\"a\\ss"`
+	if resolved[0].Config != want {
+		t.Fatalf("got %q, want %q", resolved[0].Config, want)
+	}
+
+	if _, err := m.Interpolate([]cfg.ConfigWithFormat{{Config: `a = "${k8s:ns/name#key}"`}}); err == nil {
+		t.Fatal("expected an error for the provider not configured")
+	}
+}
+
+func TestRefreshKeepsPreviousValue(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"secret/data/db#password": "old"}}
+	m := newTestManager(p)
+	ref := "${vault:secret/data/db#password}"
+	if _, err := m.Interpolate([]cfg.ConfigWithFormat{{Config: ref}}); err != nil {
+		t.Fatal(err)
+	}
+	inUse := map[string]struct{}{ref: {}}
+
+	// not expired yet
+	p.values["secret/data/db#password"] = "new"
+	if changed := m.Refresh(inUse); len(changed) != 0 {
+		t.Fatalf("unexpected changes %v", changed)
+	}
+
+	m.values[ref].expires = time.Now()
+	p.err = errors.New("vault sealed")
+	if changed := m.Refresh(inUse); len(changed) != 0 {
+		t.Fatalf("unexpected changes %v", changed)
+	}
+	if m.values[ref].secret != "old" {
+		t.Fatalf("the previous value should be kept, got %q", m.values[ref].secret)
+	}
+
+	m.values[ref].expires = time.Now()
+	p.err = nil
+	if changed := m.Refresh(inUse); !reflect.DeepEqual(changed, []string{ref}) {
+		t.Fatalf("unexpected changes %v", changed)
+	}
+	if m.values[ref].secret != "new" {
+		t.Fatalf("got %q, want new", m.values[ref].secret)
+	}
+
+	m.Refresh(map[string]struct{}{})
+	if len(m.values) != 0 {
+		t.Fatal("the references not in use should be forgotten")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			w.Write([]byte(`{"auth": {"client_token": "s.token", "lease_duration": 3600, "renewable": true}}`))
+		case "/v1/secret/data/db":
+			if r.Header.Get("X-Vault-Token") != "s.token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"data": {"data": {"password": "secret"}, "metadata": {"version": 2}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	jwt := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(jwt, []byte("jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newVaultProvider(&config.VaultSecretProvider{
+		Address:             server.URL,
+		AuthMethod:          "kubernetes",
+		KubernetesRole:      "categraf",
+		KubernetesTokenFile: jwt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, err := p.Resolve("secret/data/db", "password")
+	if err != nil || v != "secret" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if _, _, err := p.Resolve("secret/data/missing", "password"); err == nil {
+		t.Fatal("expected an error for the missing secret")
+	}
+}
+
+func TestVaultAppRole(t *testing.T) {
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			var creds map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&creds); err != nil || creds["role_id"] != "role" || creds["secret_id"] != "s3cr3t" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors": ["invalid role or secret ID"]}`))
+				return
+			}
+			logins++
+			w.Write([]byte(`{"auth": {"client_token": "s.approle", "lease_duration": 3600, "renewable": true}}`))
+		case "/v1/database/creds/readonly":
+			if r.Header.Get("X-Vault-Token") != "s.approle" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"lease_duration": 600, "data": {"username": "v-approle", "password": "dynamic"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	secretID := filepath.Join(t.TempDir(), "secret_id")
+	if err := os.WriteFile(secretID, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newVaultProvider(&config.VaultSecretProvider{
+		Address:             server.URL,
+		AuthMethod:          "approle",
+		AppRoleRoleID:       "role",
+		AppRoleSecretIDFile: secretID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ttl, err := p.Resolve("database/creds/readonly", "password")
+	if err != nil || v != "dynamic" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	// read again before the end of the lease
+	if ttl != 400*time.Second {
+		t.Fatalf("got ttl %s, want 400s", ttl)
+	}
+	if _, _, err := p.Resolve("database/creds/readonly", "username"); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 {
+		t.Fatalf("expected the token to be reused, got %d logins", logins)
+	}
+
+	if _, err := newVaultProvider(&config.VaultSecretProvider{Address: server.URL, AuthMethod: "approle"}); err == nil {
+		t.Fatal("expected an error for the approle auth without role_id")
+	}
+}
+
+func TestKubernetesMountedSecret(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "monitoring", "mysql"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "monitoring", "mysql", "password"), []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newKubernetesProvider(&config.KubernetesSecretProvider{MountDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _, err := p.Resolve("monitoring/mysql", "password")
+	if err != nil || v != "secret" {
+		t.Fatalf("got %q, %v", v, err)
+	}
+	if _, _, err := p.Resolve("mysql", "password"); err == nil {
+		t.Fatal("expected an error for the secret without namespace")
+	}
+}
@@ -0,0 +1,331 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"flashcat.cloud/categraf/config"
+)
+
+const (
+	defaultVaultTimeout        = 10 * time.Second
+	defaultKubernetesMountPath = "kubernetes"
+	defaultAppRoleMountPath    = "approle"
+	serviceAccountPath         = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// vaultProvider reads the secrets of HashiCorp Vault, e.g. vault:secret/data/db#password
+// reads the password key of the kv v2 secret db of the secret engine
+type vaultProvider struct {
+	conf   *config.VaultSecretProvider
+	client *http.Client
+
+	mu        sync.Mutex
+	token     string
+	renewable bool
+	expires   time.Time // zero for the tokens without ttl
+	ttl       time.Duration
+}
+
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int64  `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+type vaultResponse struct {
+	Data          map[string]interface{} `json:"data"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Auth          *vaultAuth             `json:"auth"`
+	Errors        []string               `json:"errors"`
+}
+
+func newVaultProvider(c *config.VaultSecretProvider) (*vaultProvider, error) {
+	if c.Address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	switch c.AuthMethod {
+	case "", "token":
+		c.AuthMethod = "token"
+		if c.Token == "" && c.TokenFile == "" {
+			c.Token = os.Getenv("VAULT_TOKEN")
+		}
+		if c.Token == "" && c.TokenFile == "" {
+			return nil, fmt.Errorf("token or token_file is required by the token auth")
+		}
+	case "approle":
+		if c.AppRoleRoleID == "" && c.AppRoleRoleIDFile == "" {
+			return nil, fmt.Errorf("approle_role_id or approle_role_id_file is required by the approle auth")
+		}
+		if c.AppRoleMountPath == "" {
+			c.AppRoleMountPath = defaultAppRoleMountPath
+		}
+	case "kubernetes":
+		if c.KubernetesRole == "" {
+			return nil, fmt.Errorf("kubernetes_role is required by the kubernetes auth")
+		}
+		if c.KubernetesMountPath == "" {
+			c.KubernetesMountPath = defaultKubernetesMountPath
+		}
+		if c.KubernetesTokenFile == "" {
+			c.KubernetesTokenFile = serviceAccountPath + "/token"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported auth_method %s", c.AuthMethod)
+	}
+
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(c.Timeout)
+	if timeout <= 0 {
+		timeout = defaultVaultTimeout
+	}
+	return &vaultProvider{
+		conf: c,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+func (v *vaultProvider) Resolve(path, key string) (string, time.Duration, error) {
+	resp, status, err := v.authorizedRequest(http.MethodGet, "/v1/"+strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return "", 0, err
+	}
+	if status == http.StatusNotFound {
+		return "", 0, fmt.Errorf("secret %s not found", path)
+	}
+
+	data := resp.Data
+	// the data of the kv v2 secrets is wrapped along with their metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, has := data["metadata"]; has {
+			data = inner
+		}
+	}
+	raw, has := data[key]
+	if !has {
+		return "", 0, fmt.Errorf("key %s not found in secret %s", key, path)
+	}
+	// the dynamic secrets, e.g. database credentials, are read again before their lease ends
+	ttl := time.Duration(resp.LeaseDuration) * time.Second * 2 / 3
+	switch raw := raw.(type) {
+	case string:
+		return raw, ttl, nil
+	case nil:
+		return "", ttl, nil
+	default:
+		bs, err := json.Marshal(raw)
+		if err != nil {
+			return "", 0, err
+		}
+		return string(bs), ttl, nil
+	}
+}
+
+// authorizedRequest sends the request with the token, logging in again once if the token
+// was revoked or expired
+func (v *vaultProvider) authorizedRequest(method, path string) (*vaultResponse, int, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := v.getToken()
+		if err != nil {
+			return nil, 0, err
+		}
+		resp, status, err := v.request(method, path, token, nil)
+		if status == http.StatusForbidden && attempt == 0 {
+			v.mu.Lock()
+			if v.token == token {
+				v.token = ""
+			}
+			v.mu.Unlock()
+			continue
+		}
+		return resp, status, err
+	}
+}
+
+// getToken returns the current token, renewing it once two thirds of its ttl elapsed
+func (v *vaultProvider) getToken() (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.token != "" && !v.expires.IsZero() && time.Now().After(v.expires.Add(-v.ttl/3)) {
+		if v.renewable && time.Now().Before(v.expires) {
+			if err := v.renew(); err != nil {
+				log.Printf("W! failed to renew vault token, logging in again: %v", err)
+				v.token = ""
+			}
+		} else {
+			v.token = ""
+		}
+	}
+	if v.token != "" {
+		return v.token, nil
+	}
+	if err := v.login(); err != nil {
+		return "", err
+	}
+	return v.token, nil
+}
+
+func (v *vaultProvider) login() error {
+	switch v.conf.AuthMethod {
+	case "kubernetes":
+		jwt, err := os.ReadFile(v.conf.KubernetesTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read service account token: %v", err)
+		}
+		return v.loginWith(v.conf.KubernetesMountPath, map[string]string{
+			"role": v.conf.KubernetesRole,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		})
+	case "approle":
+		roleID, err := valueOrFile(v.conf.AppRoleRoleID, v.conf.AppRoleRoleIDFile)
+		if err != nil {
+			return fmt.Errorf("failed to read approle role_id: %v", err)
+		}
+		secretID, err := valueOrFile(v.conf.AppRoleSecretID, v.conf.AppRoleSecretIDFile)
+		if err != nil {
+			return fmt.Errorf("failed to read approle secret_id: %v", err)
+		}
+		creds := map[string]string{"role_id": roleID}
+		// the roles binding the secret ids to cidrs only may not need one
+		if secretID != "" {
+			creds["secret_id"] = secretID
+		}
+		return v.loginWith(v.conf.AppRoleMountPath, creds)
+	}
+
+	token := v.conf.Token
+	if v.conf.TokenFile != "" {
+		bs, err := os.ReadFile(v.conf.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %v", err)
+		}
+		token = strings.TrimSpace(string(bs))
+	}
+	// ask the ttl of the token to renew it in time, the tokens which can't look up
+	// themselves are used as is
+	auth := &vaultAuth{ClientToken: token}
+	resp, _, err := v.request(http.MethodGet, "/v1/auth/token/lookup-self", token, nil)
+	if err != nil || resp == nil {
+		log.Printf("W! failed to look up vault token, it won't be renewed: %v", err)
+		v.setToken(auth)
+		return nil
+	}
+	if ttl, ok := resp.Data["ttl"].(float64); ok {
+		auth.LeaseDuration = int64(ttl)
+	}
+	if renewable, ok := resp.Data["renewable"].(bool); ok {
+		auth.Renewable = renewable
+	}
+	v.setToken(auth)
+	return nil
+}
+
+// loginWith logs in with the credentials of the auth method mounted at mountPath
+func (v *vaultProvider) loginWith(mountPath string, creds map[string]string) error {
+	body, _ := json.Marshal(creds)
+	resp, _, err := v.request(http.MethodPost, "/v1/auth/"+strings.Trim(mountPath, "/")+"/login", "", body)
+	if err != nil {
+		return fmt.Errorf("%s login: %v", v.conf.AuthMethod, err)
+	}
+	if resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("%s login: no token in response", v.conf.AuthMethod)
+	}
+	v.setToken(resp.Auth)
+	return nil
+}
+
+// valueOrFile returns the value, or the trimmed content of the file when set
+func valueOrFile(value, file string) (string, error) {
+	if file == "" {
+		return value, nil
+	}
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(bs)), nil
+}
+
+func (v *vaultProvider) renew() error {
+	resp, _, err := v.request(http.MethodPost, "/v1/auth/token/renew-self", v.token, []byte("{}"))
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("no token in response")
+	}
+	v.setToken(resp.Auth)
+	return nil
+}
+
+func (v *vaultProvider) setToken(auth *vaultAuth) {
+	v.token = auth.ClientToken
+	v.renewable = auth.Renewable
+	v.ttl = time.Duration(auth.LeaseDuration) * time.Second
+	v.expires = time.Time{}
+	if v.ttl > 0 {
+		v.expires = time.Now().Add(v.ttl)
+	}
+}
+
+// request returns the decoded response, the status is returned along with the errors of
+// the responses other than 404
+func (v *vaultProvider) request(method, path, token string, body []byte) (*vaultResponse, int, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(v.conf.Address, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.conf.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.conf.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	bs, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, res.StatusCode, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return nil, res.StatusCode, nil
+	}
+
+	resp := &vaultResponse{}
+	if len(bs) > 0 {
+		if err := json.Unmarshal(bs, resp); err != nil && res.StatusCode < 300 {
+			return nil, res.StatusCode, fmt.Errorf("invalid response: %v", err)
+		}
+	}
+	if res.StatusCode >= 300 {
+		if len(resp.Errors) > 0 {
+			return nil, res.StatusCode, fmt.Errorf("%s: %s", res.Status, strings.Join(resp.Errors, ", "))
+		}
+		return nil, res.StatusCode, fmt.Errorf("%s", res.Status)
+	}
+	return resp, res.StatusCode, nil
+}
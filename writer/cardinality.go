@@ -0,0 +1,140 @@
+package writer
+
+import (
+	"container/list"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/agent/events"
+)
+
+// exported as categraf_cardinality_dropped_total by the self_metrics input
+var cardinalityDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cardinality_dropped_total",
+	Help: "Number of series dropped by the writer because their metric had max_cardinality series already.",
+}, []string{"metric"})
+
+func init() {
+	prometheus.MustRegister(cardinalityDroppedTotal)
+}
+
+// cardinalityGuard drops the new series of a metric once the metric has maxCardinality series,
+// the series written in the ttl are counted. The series of a metric and the metrics are LRUs,
+// a series not written in the ttl makes room for a new one and the least recently written
+// metric is forgotten beyond maxMetrics
+type cardinalityGuard struct {
+	sync.Mutex
+
+	maxCardinality int
+	ttl            time.Duration
+	maxMetrics     int
+
+	metrics map[string]*list.Element
+	lru     list.List
+}
+
+// metricSeries are the series of a metric, least recently written last
+type metricSeries struct {
+	name   string
+	series map[uint64]*list.Element
+	lru    list.List
+	// the drops are logged and published once per ttl, dropped counts the drops since the last event
+	warnedAt time.Time
+	dropped  int
+}
+
+type seriesSeen struct {
+	hash uint64
+	at   time.Time
+}
+
+func newCardinalityGuard(maxCardinality int, ttl time.Duration, maxMetrics int) *cardinalityGuard {
+	return &cardinalityGuard{
+		maxCardinality: maxCardinality,
+		ttl:            ttl,
+		maxMetrics:     maxMetrics,
+		metrics:        make(map[string]*list.Element),
+	}
+}
+
+// filter removes in place the series over the cardinality of their metric and returns the
+// remaining series, the series without a metric name are kept
+func (g *cardinalityGuard) filter(items []*prompb.TimeSeries, now time.Time) []*prompb.TimeSeries {
+	g.Lock()
+	defer g.Unlock()
+
+	kept := items[:0]
+	for _, item := range items {
+		name := metricName(item)
+		if name == "" || g.admit(name, labelsHash(item), now) {
+			kept = append(kept, item)
+		}
+	}
+	// drop the references to the removed series
+	for i := len(kept); i < len(items); i++ {
+		items[i] = nil
+	}
+	return kept
+}
+
+// admit records the series of the metric and reports whether it is under the cardinality
+func (g *cardinalityGuard) admit(name string, hash uint64, now time.Time) bool {
+	m := g.metric(name)
+	if e, has := m.series[hash]; has {
+		e.Value.(*seriesSeen).at = now
+		m.lru.MoveToFront(e)
+		return true
+	}
+	if len(m.series) >= g.maxCardinality {
+		oldest := m.lru.Back().Value.(*seriesSeen)
+		if now.Sub(oldest.at) < g.ttl {
+			cardinalityDroppedTotal.WithLabelValues(name).Inc()
+			m.dropped++
+			if now.Sub(m.warnedAt) >= g.ttl {
+				m.warnedAt = now
+				log.Printf("W! metric %s has more than %d series, the new series are dropped, please check its labels or increase max_cardinality", name, g.maxCardinality)
+				events.Publish(events.SeriesLimitTriggered, map[string]string{
+					"metric":  name,
+					"limit":   strconv.Itoa(g.maxCardinality),
+					"dropped": strconv.Itoa(m.dropped),
+				})
+				m.dropped = 0
+			}
+			return false
+		}
+		m.lru.Remove(m.lru.Back())
+		delete(m.series, oldest.hash)
+	}
+	m.series[hash] = m.lru.PushFront(&seriesSeen{hash: hash, at: now})
+	return true
+}
+
+// metric returns the series of the metric, forgetting the least recently written metric
+// when there are too many
+func (g *cardinalityGuard) metric(name string) *metricSeries {
+	if e, has := g.metrics[name]; has {
+		g.lru.MoveToFront(e)
+		return e.Value.(*metricSeries)
+	}
+	if len(g.metrics) >= g.maxMetrics {
+		oldest := g.lru.Remove(g.lru.Back()).(*metricSeries)
+		delete(g.metrics, oldest.name)
+	}
+	m := &metricSeries{name: name, series: make(map[uint64]*list.Element)}
+	g.metrics[name] = g.lru.PushFront(m)
+	return m
+}
+
+func metricName(item *prompb.TimeSeries) string {
+	for _, l := range item.Labels {
+		if l.Name == "__name__" {
+			return l.Value
+		}
+	}
+	return ""
+}
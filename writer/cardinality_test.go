@@ -0,0 +1,133 @@
+package writer
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/agent/events"
+	"flashcat.cloud/categraf/config"
+)
+
+func TestCardinalityGuard(t *testing.T) {
+	g := newCardinalityGuard(2, time.Minute, 2)
+	now := time.Now()
+
+	items := []*prompb.TimeSeries{
+		newSeries(1000, "__name__", "http_requests", "url", "/a"),
+		newSeries(1000, "__name__", "http_requests", "url", "/b"),
+		newSeries(1000, "__name__", "http_requests", "url", "/c"),
+		// the known series are kept at any timestamp
+		newSeries(2000, "__name__", "http_requests", "url", "/a"),
+		newSeries(1000, "__name__", "up", "instance", "a"),
+		newSeries(1000, "no_name", "x"),
+	}
+	before := testutil.ToFloat64(cardinalityDroppedTotal.WithLabelValues("http_requests"))
+	kept := g.filter(items, now)
+	if len(kept) != 5 || metricName(kept[2]) != "http_requests" || kept[2].Samples[0].Timestamp != 2000 {
+		t.Fatalf("expected the third series dropped, got %d series", len(kept))
+	}
+	if n := testutil.ToFloat64(cardinalityDroppedTotal.WithLabelValues("http_requests")) - before; n != 1 {
+		t.Fatalf("expected 1 dropped series, got %v", n)
+	}
+
+	// /b is not written in the ttl and makes room for /c
+	kept = g.filter([]*prompb.TimeSeries{
+		newSeries(3000, "__name__", "http_requests", "url", "/a"),
+	}, now.Add(50*time.Second))
+	if len(kept) != 1 {
+		t.Fatalf("expected the known series kept, got %d", len(kept))
+	}
+	kept = g.filter([]*prompb.TimeSeries{
+		newSeries(4000, "__name__", "http_requests", "url", "/c"),
+		newSeries(4000, "__name__", "http_requests", "url", "/b"),
+	}, now.Add(70*time.Second))
+	if len(kept) != 1 || kept[0].Labels[1].Value != "/c" {
+		t.Fatalf("expected /c to replace the expired /b, got %d series", len(kept))
+	}
+
+	// a third metric forgets the least recently written one
+	g.filter([]*prompb.TimeSeries{newSeries(5000, "__name__", "load1")}, now.Add(80*time.Second))
+	if _, has := g.metrics["up"]; has || len(g.metrics) != 2 {
+		t.Fatalf("expected up to be forgotten, got %d metrics", len(g.metrics))
+	}
+}
+
+type eventRecorder struct {
+	sync.Mutex
+	events []*events.Event
+}
+
+func (r *eventRecorder) Write(e *events.Event) error {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *eventRecorder) wait(t *testing.T, n int) []*events.Event {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.Lock()
+		got := append([]*events.Event(nil), r.events...)
+		r.Unlock()
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d events, got %d", n, len(got))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCardinalityGuardEvents(t *testing.T) {
+	oldConfig, oldHostInfo := config.Config, config.HostInfo
+	config.Config = &config.ConfigType{}
+	config.HostInfo = &config.HostInfoCache{}
+	defer func() { config.Config, config.HostInfo = oldConfig, oldHostInfo }()
+	if err := events.Init(&config.Events{Enable: true, FileName: filepath.Join(t.TempDir(), "events.jsonl")}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(events.Close)
+	rec := &eventRecorder{}
+	events.AddSink(rec)
+
+	g := newCardinalityGuard(1, time.Minute, 10)
+	now := time.Now()
+	g.filter([]*prompb.TimeSeries{
+		newSeries(1000, "__name__", "api_latency", "path", "/a"),
+		newSeries(1000, "__name__", "api_latency", "path", "/b"),
+		newSeries(1000, "__name__", "api_latency", "path", "/c"),
+	}, now)
+	// one event per ttl, the drops in between are counted in the next event
+	g.filter([]*prompb.TimeSeries{
+		newSeries(2000, "__name__", "api_latency", "path", "/a"),
+		newSeries(2000, "__name__", "api_latency", "path", "/d"),
+	}, now.Add(30*time.Second))
+	g.filter([]*prompb.TimeSeries{newSeries(3000, "__name__", "api_latency", "path", "/e")}, now.Add(61*time.Second))
+
+	got := rec.wait(t, 2)
+	want := []map[string]string{
+		{"metric": "api_latency", "limit": "1", "dropped": "1"},
+		{"metric": "api_latency", "limit": "1", "dropped": "3"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(got))
+	}
+	for i, e := range got {
+		if e.Type != events.SeriesLimitTriggered {
+			t.Fatalf("unexpected event type %s", e.Type)
+		}
+		for k, v := range want[i] {
+			if e.Fields[k] != v {
+				t.Errorf("event %d: expected %s=%s, got %v", i, k, v, e.Fields)
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package writer
+
+import (
+	"fmt"
+	"log"
+	"math/bits"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+var (
+	compareSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "writer_compare_samples_total",
+		Help: "Number of samples of the batches sent to the compared writers, by delivery result.",
+	}, []string{"writer", "result"})
+	compareDivergentCyclesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "writer_compare_divergent_cycles_total",
+		Help: "Number of batches delivered to only one of the compared writers.",
+	})
+	compareDiverged = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "writer_compare_diverged",
+		Help: "Whether the rolling hashes of the batches delivered to the compared writers differ since categraf started.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(compareSamplesTotal, compareDivergentCyclesTotal, compareDiverged)
+}
+
+// comparison compares the deliveries of the batches to two writers. Every batch is sent to
+// both writers, so the streams only diverge when a batch is delivered to one of them: such
+// a cycle is counted and the series of the batch are kept, at most maxSeries per writer,
+// to be listed by /debug/writer/compare. It only observes the deliveries.
+type comparison struct {
+	sync.Mutex
+
+	names     [2]string
+	maxSeries int
+
+	cycles          uint64
+	divergentCycles uint64
+	streams         [2]*compareStream
+}
+
+// compareStream is what a writer of the pair received
+type compareStream struct {
+	samples       uint64
+	failedSamples uint64
+	// the rolling hash of the serialized batches delivered to the writer
+	hash uint64
+	// the series of the batches delivered to this writer only
+	onlyIn    map[string]*compareSeries
+	truncated uint64
+}
+
+type compareSeries struct {
+	samples  uint64
+	lastSeen int64
+}
+
+// compareCycle records the delivery of one batch to the writers of the pair
+type compareCycle struct {
+	items     []prompb.TimeSeries
+	samples   uint64
+	hash      uint64
+	delivered [2]bool
+}
+
+func newComparison(urls []string, maxSeries int) *comparison {
+	c := &comparison{maxSeries: maxSeries}
+	for i := range c.names {
+		c.names[i] = redactWriterURL(urls[i])
+		c.streams[i] = &compareStream{onlyIn: make(map[string]*compareSeries)}
+	}
+	return c
+}
+
+// redactWriterURL hides the password of the url, the url is exported as the writer label
+func redactWriterURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
+
+// begin hashes the serialized batch, the cycle is recorded by end once it is sent to the writers
+func (c *comparison) begin(items []prompb.TimeSeries) *compareCycle {
+	cycle := &compareCycle{items: items}
+	for i := range items {
+		cycle.samples += uint64(len(items[i].Samples))
+	}
+	data, err := proto.Marshal(&prompb.WriteRequest{Timeseries: items})
+	if err != nil {
+		log.Println("W! failed to marshal the batch to compare the writers:", err)
+	}
+	cycle.hash = xxhash.Sum64(data)
+	return cycle
+}
+
+// done records the delivery of the batch to the writer i of the pair
+func (cycle *compareCycle) done(i int, err error) {
+	cycle.delivered[i] = err == nil
+}
+
+// end records the deliveries of the cycle
+func (c *comparison) end(cycle *compareCycle) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.cycles++
+	for i, s := range c.streams {
+		if !cycle.delivered[i] {
+			s.failedSamples += cycle.samples
+			compareSamplesTotal.WithLabelValues(c.names[i], "failed").Add(float64(cycle.samples))
+			continue
+		}
+		s.samples += cycle.samples
+		s.hash = mix64(bits.RotateLeft64(s.hash, 1) ^ cycle.hash)
+		compareSamplesTotal.WithLabelValues(c.names[i], "delivered").Add(float64(cycle.samples))
+	}
+
+	if cycle.delivered[0] != cycle.delivered[1] {
+		c.divergentCycles++
+		compareDivergentCyclesTotal.Inc()
+		s := c.streams[0]
+		if cycle.delivered[1] {
+			s = c.streams[1]
+		}
+		c.keep(s, cycle.items)
+	}
+	if c.streams[0].hash != c.streams[1].hash {
+		compareDiverged.Set(1)
+	} else {
+		compareDiverged.Set(0)
+	}
+}
+
+// keep adds the series delivered to the stream only, the series beyond maxSeries are counted
+func (c *comparison) keep(s *compareStream, items []prompb.TimeSeries) {
+	for i := range items {
+		key := seriesString(items[i].Labels)
+		cs, has := s.onlyIn[key]
+		if !has {
+			if len(s.onlyIn) >= c.maxSeries {
+				s.truncated++
+				continue
+			}
+			cs = &compareSeries{}
+			s.onlyIn[key] = cs
+		}
+		for _, sample := range items[i].Samples {
+			cs.samples++
+			if sample.Timestamp > cs.lastSeen {
+				cs.lastSeen = sample.Timestamp
+			}
+		}
+	}
+}
+
+// seriesString formats the labels like the prometheus text format, sorted by name
+func seriesString(labels []prompb.Label) string {
+	sorted := make([]prompb.Label, len(labels))
+	copy(sorted, labels)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var sb strings.Builder
+	for _, l := range sorted {
+		if l.Name == "__name__" {
+			sb.WriteString(l.Value)
+			break
+		}
+	}
+	sb.WriteByte('{')
+	first := true
+	for _, l := range sorted {
+		if l.Name == "__name__" {
+			continue
+		}
+		if !first {
+			sb.WriteByte(',')
+		}
+		first = false
+		fmt.Fprintf(&sb, "%s=%q", l.Name, l.Value)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// CompareStreamReport is what a writer of the compared pair received
+type CompareStreamReport struct {
+	Writer        string `json:"writer"`
+	Samples       uint64 `json:"samples"`
+	FailedSamples uint64 `json:"failed_samples"`
+	Hash          string `json:"hash"`
+	// the series of the batches delivered to this writer and not to the other one
+	OnlyIn []CompareSeriesReport `json:"only_in"`
+	// the series beyond compare_max_series, not listed in only_in
+	Truncated uint64 `json:"truncated"`
+}
+
+type CompareSeriesReport struct {
+	Series   string    `json:"series"`
+	Samples  uint64    `json:"samples"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type CompareReport struct {
+	Cycles          uint64                 `json:"cycles"`
+	DivergentCycles uint64                 `json:"divergent_cycles"`
+	Diverged        bool                   `json:"diverged"`
+	Streams         [2]CompareStreamReport `json:"streams"`
+}
+
+func (c *comparison) report() *CompareReport {
+	c.Lock()
+	defer c.Unlock()
+
+	r := &CompareReport{
+		Cycles:          c.cycles,
+		DivergentCycles: c.divergentCycles,
+		Diverged:        c.streams[0].hash != c.streams[1].hash,
+	}
+	for i, s := range c.streams {
+		sr := CompareStreamReport{
+			Writer:        c.names[i],
+			Samples:       s.samples,
+			FailedSamples: s.failedSamples,
+			Hash:          fmt.Sprintf("%016x", s.hash),
+			OnlyIn:        make([]CompareSeriesReport, 0, len(s.onlyIn)),
+			Truncated:     s.truncated,
+		}
+		for key, cs := range s.onlyIn {
+			sr.OnlyIn = append(sr.OnlyIn, CompareSeriesReport{Series: key, Samples: cs.samples, LastSeen: time.UnixMilli(cs.lastSeen)})
+		}
+		sort.Slice(sr.OnlyIn, func(i, j int) bool { return sr.OnlyIn[i].Series < sr.OnlyIn[j].Series })
+		r.Streams[i] = sr
+	}
+	return r
+}
+
+// Compare returns the comparison of the deliveries of writer_opt.compare_writers, nil when
+// no writers are compared
+func Compare() *CompareReport {
+	if writers == nil || writers.compare == nil {
+		return nil
+	}
+	return writers.compare.report()
+}
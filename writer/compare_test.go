@@ -0,0 +1,111 @@
+package writer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// newFailingServer returns a remote write server failing the requests for which fail returns true
+func newFailingServer(fail func(n int64) bool) *httptest.Server {
+	var requests atomic.Int64
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail(requests.Add(1)) {
+			http.Error(w, "injected failure", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func batch(ts int64, instances ...string) []prompb.TimeSeries {
+	items := make([]prompb.TimeSeries, 0, len(instances))
+	for _, instance := range instances {
+		items = append(items, *newSeries(ts, "__name__", "up", "instance", instance))
+	}
+	return items
+}
+
+func TestCompareWriters(t *testing.T) {
+	config.Config = &config.ConfigType{}
+	// the old backend fails the second batch, the new one the third and the fourth
+	oldBackend := newFailingServer(func(n int64) bool { return n == 2 })
+	defer oldBackend.Close()
+	newBackend := newFailingServer(func(n int64) bool { return n == 3 || n == 4 })
+	defer newBackend.Close()
+
+	writerMap := map[string]Writer{}
+	for _, u := range []string{oldBackend.URL, newBackend.URL} {
+		w, err := newWriter(config.WriterOption{Url: u, Timeout: 5000, DialTimeout: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		writerMap[u] = w
+	}
+	writers = &Writers{
+		writerMap:    writerMap,
+		compareIndex: map[string]int{oldBackend.URL: 0, newBackend.URL: 1},
+		compare:      newComparison([]string{oldBackend.URL, newBackend.URL}, 2),
+	}
+	defer func() { writers = nil }()
+
+	WriteTimeSeries(batch(1000, "a", "b"))
+	if r := Compare(); r.Diverged || r.DivergentCycles != 0 || r.Streams[0].Hash != r.Streams[1].Hash {
+		t.Fatalf("expected the streams to match after the first batch, got %+v", r)
+	}
+
+	WriteTimeSeries(batch(2000, "a", "b"))
+	WriteTimeSeries(batch(3000, "a", "c", "d"))
+	WriteTimeSeries(batch(4000, "a"))
+
+	r := Compare()
+	if r.Cycles != 4 || r.DivergentCycles != 3 || !r.Diverged {
+		t.Fatalf("expected 3 divergent cycles of 4, got %+v", r)
+	}
+	if r.Streams[0].Samples != 6 || r.Streams[0].FailedSamples != 2 || r.Streams[1].Samples != 4 || r.Streams[1].FailedSamples != 4 {
+		t.Fatalf("unexpected sample counts: %+v", r.Streams)
+	}
+	// the old backend got a, c and d of the third batch and a of the fourth, only 2 series are kept
+	onlyOld := r.Streams[0].OnlyIn
+	if len(onlyOld) != 2 || onlyOld[0].Series != `up{instance="a"}` || onlyOld[0].Samples != 2 ||
+		onlyOld[0].LastSeen.UnixMilli() != 4000 || r.Streams[0].Truncated != 1 {
+		t.Fatalf("unexpected series of the old backend only: %+v, %d truncated", onlyOld, r.Streams[0].Truncated)
+	}
+	onlyNew := r.Streams[1].OnlyIn
+	if len(onlyNew) != 2 || onlyNew[0].Series != `up{instance="a"}` || onlyNew[1].Series != `up{instance="b"}` {
+		t.Fatalf("unexpected series of the new backend only: %+v", onlyNew)
+	}
+	if v := testutil.ToFloat64(compareDiverged); v != 1 {
+		t.Fatalf("expected the diverged gauge to be 1, got %v", v)
+	}
+	if v := testutil.ToFloat64(compareSamplesTotal.WithLabelValues(redactWriterURL(newBackend.URL), "failed")); v != 4 {
+		t.Fatalf("expected 4 failed samples of the new backend, got %v", v)
+	}
+}
+
+func TestCompareSameFailures(t *testing.T) {
+	c := newComparison([]string{"http://u:secret@a/write", "http://b/write"}, 10)
+	if c.names[0] != "http://u:xxxxx@a/write" {
+		t.Fatalf("expected the password to be redacted, got %s", c.names[0])
+	}
+	for i, failed := range []bool{false, true, false} {
+		cycle := c.begin(batch(int64(i), "a"))
+		var err error
+		if failed {
+			err = http.ErrHandlerTimeout
+		}
+		// both writers failing the same batch receive the same stream
+		cycle.done(0, err)
+		cycle.done(1, err)
+		c.end(cycle)
+	}
+	if r := c.report(); r.Diverged || r.DivergentCycles != 0 || r.Streams[0].Samples != 2 || len(r.Streams[0].OnlyIn) != 0 {
+		t.Fatalf("expected the streams to match, got %+v", r)
+	}
+}
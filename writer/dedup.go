@@ -0,0 +1,148 @@
+package writer
+
+import (
+	"log"
+	"math/bits"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+var duplicateSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "writer_duplicate_samples_total",
+	Help: "Number of samples dropped by the writer dedup because the labels and timestamp were already written in the window.",
+}, []string{"input"})
+
+// exported as categraf_deduplication_dropped_total by the self_metrics input
+var batchDuplicatesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "deduplication_dropped_total",
+	Help: "Number of series dropped from a batch because a series of the batch had the same labels and timestamps.",
+})
+
+func init() {
+	prometheus.MustRegister(duplicateSamplesTotal, batchDuplicatesTotal)
+}
+
+// deduplicator drops the series whose labels and timestamp were already seen in the window,
+// the first one is kept. Only the 64-bit hashes are kept, at most maxSeries per window, the
+// series beyond are passed through
+type deduplicator struct {
+	sync.Mutex
+
+	window    time.Duration
+	maxSeries int
+
+	start time.Time
+	seen  map[uint64]struct{}
+	full  bool
+	// inputs with duplicates in the window, logged once per window
+	warned map[string]struct{}
+}
+
+func newDeduplicator(window time.Duration, maxSeries int) *deduplicator {
+	return &deduplicator{
+		window:    window,
+		maxSeries: maxSeries,
+		seen:      make(map[uint64]struct{}),
+		warned:    make(map[string]struct{}),
+	}
+}
+
+// filter removes the duplicates from items in place and returns the remaining series
+func (d *deduplicator) filter(input string, items []*prompb.TimeSeries, now time.Time) []*prompb.TimeSeries {
+	d.Lock()
+	defer d.Unlock()
+
+	if now.Sub(d.start) >= d.window {
+		d.start = now
+		d.full = false
+		clear(d.seen)
+		clear(d.warned)
+	}
+
+	kept := items[:0]
+	duplicates := 0
+	for _, item := range items {
+		key := seriesHash(item)
+		if _, has := d.seen[key]; has {
+			duplicates++
+			continue
+		}
+		if len(d.seen) < d.maxSeries {
+			d.seen[key] = struct{}{}
+		} else if !d.full {
+			d.full = true
+			log.Printf("W! writer dedup is full(%d series), the series beyond are not deduplicated in this window, please increase dedup_max_series", d.maxSeries)
+		}
+		kept = append(kept, item)
+	}
+	// drop the references to the removed series
+	for i := len(kept); i < len(items); i++ {
+		items[i] = nil
+	}
+
+	if duplicates > 0 {
+		duplicateSamplesTotal.WithLabelValues(input).Add(float64(duplicates))
+		if _, has := d.warned[input]; !has {
+			d.warned[input] = struct{}{}
+			log.Printf("W! writer dropped %d duplicate samples of input %s, the same target may be collected twice", duplicates, input)
+		}
+	}
+	return kept
+}
+
+// dedupBatch removes in place the series of a batch with the same labels and timestamps as an
+// earlier series of the batch, e.g. pushed by two inputs collecting the same target, which the
+// strict backends reject. Unlike the deduplicator it is always on and keeps nothing across batches
+func dedupBatch(items []prompb.TimeSeries) []prompb.TimeSeries {
+	if len(items) < 2 {
+		return items
+	}
+	seen := make(map[uint64]struct{}, len(items))
+	kept := items[:0]
+	for i := range items {
+		key := seriesHash(&items[i])
+		if _, has := seen[key]; has {
+			continue
+		}
+		seen[key] = struct{}{}
+		kept = append(kept, items[i])
+	}
+	if dropped := len(items) - len(kept); dropped > 0 {
+		batchDuplicatesTotal.Add(float64(dropped))
+	}
+	return kept
+}
+
+// seriesHash hashes the labels and the timestamp of the series. The hashes of the labels
+// are summed, so the order of the labels does not matter and they need not be sorted
+func seriesHash(item *prompb.TimeSeries) uint64 {
+	h := labelsSum(item.Labels)
+	for _, s := range item.Samples {
+		h = mix64(h ^ uint64(s.Timestamp))
+	}
+	return mix64(h)
+}
+
+// labelsHash hashes the labels of the series regardless of their order
+func labelsHash(item *prompb.TimeSeries) uint64 {
+	return mix64(labelsSum(item.Labels))
+}
+
+func labelsSum(labels []prompb.Label) uint64 {
+	var h uint64
+	for _, l := range labels {
+		h += bits.RotateLeft64(xxhash.Sum64String(l.Name), 31) ^ xxhash.Sum64String(l.Value)
+	}
+	return h
+}
+
+// mix64 is the finalizer of splitmix64
+func mix64(h uint64) uint64 {
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	return h ^ (h >> 31)
+}
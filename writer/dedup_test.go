@@ -0,0 +1,120 @@
+package writer
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func newSeries(ts int64, labels ...string) *prompb.TimeSeries {
+	item := &prompb.TimeSeries{Samples: []prompb.Sample{{Timestamp: ts, Value: 1}}}
+	for i := 0; i+1 < len(labels); i += 2 {
+		item.Labels = append(item.Labels, prompb.Label{Name: labels[i], Value: labels[i+1]})
+	}
+	return item
+}
+
+func TestDeduplicator(t *testing.T) {
+	d := newDeduplicator(time.Minute, 100)
+	now := time.Now()
+
+	first := newSeries(1000, "__name__", "up", "instance", "a", "job", "node")
+	items := []*prompb.TimeSeries{
+		first,
+		// the order of the labels does not matter
+		newSeries(1000, "__name__", "up", "job", "node", "instance", "a"),
+		newSeries(2000, "__name__", "up", "instance", "a", "job", "node"),
+		newSeries(1000, "__name__", "up", "instance", "b", "job", "node"),
+		// the label boundaries are part of the hash
+		newSeries(1000, "__name__", "up", "instance", "aj", "ob", "node"),
+	}
+	before := testutil.ToFloat64(duplicateSamplesTotal.WithLabelValues("prometheus"))
+	kept := d.filter("prometheus", items, now)
+	if len(kept) != 4 || kept[0] != first {
+		t.Fatalf("expected 4 series with the first kept, got %d", len(kept))
+	}
+	if n := testutil.ToFloat64(duplicateSamplesTotal.WithLabelValues("prometheus")) - before; n != 1 {
+		t.Fatalf("expected 1 duplicate, got %v", n)
+	}
+
+	// the duplicate may come from another input in the same window
+	kept = d.filter("prometheus_2", []*prompb.TimeSeries{newSeries(2000, "__name__", "up", "instance", "a", "job", "node")}, now.Add(time.Second))
+	if len(kept) != 0 {
+		t.Fatalf("expected the series to be dropped, got %d", len(kept))
+	}
+
+	// the seen series are cleared per window
+	kept = d.filter("prometheus", []*prompb.TimeSeries{newSeries(1000, "__name__", "up", "instance", "a", "job", "node")}, now.Add(time.Minute))
+	if len(kept) != 1 {
+		t.Fatalf("expected the series to be kept in a new window, got %d", len(kept))
+	}
+}
+
+func TestDeduplicatorMaxSeries(t *testing.T) {
+	d := newDeduplicator(time.Minute, 2)
+	now := time.Now()
+
+	var items []*prompb.TimeSeries
+	for i := 0; i < 3; i++ {
+		items = append(items, newSeries(1000, "__name__", "up", "instance", strconv.Itoa(i)))
+	}
+	d.filter("test", items, now)
+	if len(d.seen) != 2 {
+		t.Fatalf("expected 2 series seen, got %d", len(d.seen))
+	}
+
+	// the series beyond the limit are passed through
+	kept := d.filter("test", []*prompb.TimeSeries{newSeries(1000, "__name__", "up", "instance", "2")}, now)
+	if len(kept) != 1 {
+		t.Fatalf("expected the series to be passed through, got %d", len(kept))
+	}
+}
+
+func TestDedupBatch(t *testing.T) {
+	items := []prompb.TimeSeries{
+		*newSeries(1000, "__name__", "up", "instance", "a"),
+		*newSeries(1000, "__name__", "up", "instance", "b"),
+		*newSeries(1000, "instance", "a", "__name__", "up"),
+		// the next cycle of the same series
+		*newSeries(2000, "__name__", "up", "instance", "a"),
+	}
+	before := testutil.ToFloat64(batchDuplicatesTotal)
+	kept := dedupBatch(items)
+	if len(kept) != 3 || kept[1].Labels[1].Value != "b" || kept[2].Samples[0].Timestamp != 2000 {
+		t.Fatalf("expected the duplicate of the first series dropped, got %v", kept)
+	}
+	if n := testutil.ToFloat64(batchDuplicatesTotal) - before; n != 1 {
+		t.Fatalf("expected 1 duplicate counted, got %v", n)
+	}
+}
+
+// BenchmarkDeduplicator filters 500k series a cycle, 10% of them duplicates
+func BenchmarkDeduplicator(b *testing.B) {
+	const n = 500000
+
+	series := make([]*prompb.TimeSeries, n)
+	for i := 0; i < n; i++ {
+		id := i
+		if i%10 == 0 {
+			id = i + 1
+		}
+		series[i] = newSeries(1700000000000, "__name__", "node_cpu_seconds_total", "agent_hostname", "host-"+strconv.Itoa(id/1000),
+			"cpu", strconv.Itoa(id%1000), "mode", "user", "ident", "10.0.0.1")
+	}
+
+	d := newDeduplicator(time.Minute, 1000000)
+	items := make([]*prompb.TimeSeries, n)
+	now := time.Now()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(items, series)
+		// every cycle starts a new window
+		now = now.Add(time.Minute)
+		d.filter("bench", items, now)
+	}
+}
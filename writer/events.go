@@ -0,0 +1,30 @@
+package writer
+
+import (
+	"flashcat.cloud/categraf/agent/events"
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+// EventSink forwards agent events to the writers as categraf_event samples,
+// the event type and fields become labels of the sample
+type EventSink struct{}
+
+func (EventSink) Write(e *events.Event) error {
+	labels := make(map[string]string, len(e.Fields)+2)
+	for k, v := range config.GlobalLabels() {
+		labels[k] = v
+	}
+	for k, v := range e.Fields {
+		labels[k] = v
+	}
+	labels["event_type"] = string(e.Type)
+	if !config.Config.Global.OmitHostname {
+		labels["agent_hostname"] = e.Host
+	}
+
+	s := types.NewSample("categraf", "event", 1, labels)
+	s.Timestamp = e.Time
+	WriteSamples("events", []*types.Sample{s})
+	return nil
+}
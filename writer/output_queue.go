@@ -0,0 +1,109 @@
+package writer
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/outputs"
+)
+
+var (
+	outputQueueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "output_queue_length",
+		Help: "Number of batches waiting to be written by the output.",
+	}, []string{"output"})
+	outputDroppedSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "output_dropped_samples_total",
+		Help: "Number of samples dropped because the queue of the output was full.",
+	}, []string{"output"})
+)
+
+func init() {
+	prometheus.MustRegister(outputQueueLength, outputDroppedSamplesTotal)
+}
+
+// outputQueue writes the batches of an output in its own goroutine, so the batch loop shared
+// by the writers and the outputs never waits for a slow output, e.g. retrying an unreachable
+// backend. The batches are dropped once size batches are waiting
+type outputQueue struct {
+	name    string
+	output  outputs.Output
+	batches chan []prompb.TimeSeries
+	done    chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	// the log of the drops is throttled to one line per minute
+	lastDropLog time.Time
+}
+
+func newOutputQueue(name string, output outputs.Output, size int) *outputQueue {
+	return &outputQueue{
+		name:    name,
+		output:  output,
+		batches: make(chan []prompb.TimeSeries, size),
+		done:    make(chan struct{}),
+	}
+}
+
+func (q *outputQueue) start() {
+	go q.loop()
+}
+
+func (q *outputQueue) loop() {
+	defer close(q.done)
+	for items := range q.batches {
+		outputQueueLength.WithLabelValues(q.name).Set(float64(len(q.batches)))
+		q.output.Write(items)
+	}
+}
+
+// push queues the batch without blocking, the batch is dropped when the queue is full or
+// closed. The series are not modified by the writers, the batch is shared with them
+func (q *outputQueue) push(items []prompb.TimeSeries) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		select {
+		case q.batches <- items:
+			outputQueueLength.WithLabelValues(q.name).Set(float64(len(q.batches)))
+			return
+		default:
+		}
+	}
+
+	samples := 0
+	for i := range items {
+		samples += len(items[i].Samples)
+	}
+	outputDroppedSamplesTotal.WithLabelValues(q.name).Add(float64(samples))
+	if q.closed {
+		return
+	}
+	if time.Since(q.lastDropLog) >= time.Minute {
+		q.lastDropLog = time.Now()
+		log.Println("W! the queue of output", q.name, "is full, dropped", samples, "samples, the output is slower than the writers")
+	}
+}
+
+// close stops the queue and waits for the queued batches to be written until the deadline of
+// the context, the batches pushed afterwards are dropped
+func (q *outputQueue) close(ctx context.Context) {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.batches)
+	}
+	q.mu.Unlock()
+
+	select {
+	case <-q.done:
+	case <-ctx.Done():
+		log.Println("W! output", q.name, "did not write its", len(q.batches), "queued batches on shutdown")
+	}
+}
@@ -0,0 +1,85 @@
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// blockingOutput writes a batch once it is released
+type blockingOutput struct {
+	release chan struct{}
+	written chan int
+}
+
+func (o *blockingOutput) Init() error {
+	return nil
+}
+
+func (o *blockingOutput) Write(items []prompb.TimeSeries) {
+	<-o.release
+	o.written <- len(items)
+}
+
+func TestOutputQueueDoesNotBlock(t *testing.T) {
+	out := &blockingOutput{release: make(chan struct{}), written: make(chan int, 10)}
+	q := newOutputQueue("blocking", out, 2)
+	before := testutil.ToFloat64(outputDroppedSamplesTotal.WithLabelValues("blocking"))
+	q.start()
+
+	batch := []prompb.TimeSeries{*newSeries(1000, "__name__", "up"), *newSeries(1000, "__name__", "down")}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// the first batch is being written, two are queued and the last one is dropped
+		for i := 0; i < 4; i++ {
+			q.push(batch)
+			if i == 0 {
+				// wait for the worker to take the first batch
+				for len(q.batches) != 0 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("push blocked on a stuck output")
+	}
+	if v := testutil.ToFloat64(outputDroppedSamplesTotal.WithLabelValues("blocking")) - before; v != 2 {
+		t.Errorf("dropped samples = %v, want 2", v)
+	}
+
+	// the queued batches are written on close
+	close(out.release)
+	q.close(context.Background())
+	if n := len(out.written); n != 3 {
+		t.Errorf("written %d batches, want 3", n)
+	}
+
+	// the batches pushed after close are dropped
+	q.push(batch)
+	if v := testutil.ToFloat64(outputDroppedSamplesTotal.WithLabelValues("blocking")) - before; v != 4 {
+		t.Errorf("dropped samples = %v, want 4", v)
+	}
+}
+
+func TestOutputQueueCloseTimeout(t *testing.T) {
+	out := &blockingOutput{release: make(chan struct{}), written: make(chan int, 10)}
+	defer close(out.release)
+	q := newOutputQueue("stuck", out, 2)
+	q.start()
+	q.push([]prompb.TimeSeries{*newSeries(1000, "__name__", "up")})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	q.close(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("close waited %v for a stuck output", elapsed)
+	}
+}
@@ -0,0 +1,45 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/toolkits/pkg/file"
+
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/outputs"
+	_ "flashcat.cloud/categraf/outputs/clickhouse"
+	_ "flashcat.cloud/categraf/outputs/elasticsearch"
+	_ "flashcat.cloud/categraf/outputs/graphite"
+	_ "flashcat.cloud/categraf/outputs/kafka"
+	_ "flashcat.cloud/categraf/outputs/opentsdb"
+	_ "flashcat.cloud/categraf/outputs/otlp"
+	"flashcat.cloud/categraf/pkg/cfg"
+)
+
+// initOutputs creates the outputs configured in conf/output.<name>/
+func initOutputs() (map[string]outputs.Output, error) {
+	ret := make(map[string]outputs.Output)
+	for name, creator := range outputs.OutputCreators {
+		dir := path.Join(config.Config.ConfigDir, "output."+name)
+		if !file.IsExist(dir) {
+			continue
+		}
+
+		output := creator()
+		if err := cfg.LoadConfigByDir(dir, output); err != nil {
+			return nil, fmt.Errorf("failed to load configuration of output %s: %v", name, err)
+		}
+		if err := output.Init(); err != nil {
+			if errors.Is(err, outputs.ErrDisabled) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to init output %s: %v", name, err)
+		}
+		log.Println("I! output", name, "started")
+		ret[name] = output
+	}
+	return ret, nil
+}
@@ -0,0 +1,45 @@
+package writer
+
+import (
+	"sort"
+
+	"github.com/prometheus/prometheus/prompb"
+
+	modelLabel "flashcat.cloud/categraf/pkg/prom/labels"
+	pkgrelabel "flashcat.cloud/categraf/pkg/relabel"
+)
+
+// relabel applies the relabel_configs of the writer to the series, the dropped series are
+// removed. The batch is shared by the writers, so the relabeled series are copies
+func (w Writer) relabel(items []prompb.TimeSeries) []prompb.TimeSeries {
+	if len(w.relabelConfigs) == 0 {
+		return items
+	}
+
+	ret := make([]prompb.TimeSeries, 0, len(items))
+	for i := range items {
+		lbls := make(modelLabel.Labels, 0, len(items[i].Labels))
+		for _, l := range items[i].Labels {
+			lbls = append(lbls, modelLabel.Label{Name: l.Name, Value: l.Value})
+		}
+		// the labels of the samples are unordered, the builder of pkgrelabel expects them sorted
+		sort.Sort(lbls)
+
+		newLbls, keep := pkgrelabel.Process(lbls, w.relabelConfigs...)
+		if !keep {
+			continue
+		}
+
+		labels := make([]prompb.Label, 0, len(newLbls))
+		for _, l := range newLbls {
+			labels = append(labels, prompb.Label{Name: l.Name, Value: l.Value})
+		}
+		ret = append(ret, prompb.TimeSeries{
+			Labels:     labels,
+			Samples:    items[i].Samples,
+			Exemplars:  items[i].Exemplars,
+			Histograms: items[i].Histograms,
+		})
+	}
+	return ret
+}
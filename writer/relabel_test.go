@@ -0,0 +1,135 @@
+package writer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/agent/relabel"
+	"flashcat.cloud/categraf/config"
+	"flashcat.cloud/categraf/types"
+)
+
+func newRelabelWriter(t *testing.T, url string, rules ...*relabel.RelabelRule) Writer {
+	w, err := newWriter(config.WriterOption{Url: url, Timeout: 5000, DialTimeout: 1000, RelabelConfigs: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestWriterRelabelOrder(t *testing.T) {
+	w := newRelabelWriter(t, "http://127.0.0.1/write",
+		// the rules are applied in order, the second one sees the label set by the first one
+		&relabel.RelabelRule{SourceLabels: []string{"pod"}, Regex: "(.+)-[a-z0-9]+", TargetLabel: "workload"},
+		&relabel.RelabelRule{SourceLabels: []string{"workload"}, Regex: "canary", Action: "drop"},
+		&relabel.RelabelRule{Regex: "pod|container_id", Action: "labeldrop"},
+		&relabel.RelabelRule{Regex: "k8s_(.+)", Action: "labelmap"},
+		&relabel.RelabelRule{SourceLabels: []string{"workload"}, TargetLabel: "shard", Modulus: 4, Action: "hashmod"},
+	)
+
+	items := []prompb.TimeSeries{
+		// the labels of the samples are unordered
+		*newSeries(1000, "pod", "api-7d9f", "__name__", "up", "container_id", "abc", "k8s_ns", "prod"),
+		*newSeries(1000, "__name__", "up", "pod", "canary-x1"),
+	}
+	got := w.relabel(items)
+	if len(got) != 1 {
+		t.Fatalf("expected the canary series to be dropped, got %d series", len(got))
+	}
+	if s := seriesString(got[0].Labels); s != `up{k8s_ns="prod",ns="prod",shard="2",workload="api"}` {
+		t.Fatalf("unexpected relabeled series %s", s)
+	}
+	for i := 1; i < len(got[0].Labels); i++ {
+		if got[0].Labels[i-1].Name >= got[0].Labels[i].Name {
+			t.Fatalf("expected the labels to be sorted, got %v", got[0].Labels)
+		}
+	}
+	// the batch is shared by the writers and left untouched
+	if s := seriesString(items[0].Labels); s != `up{container_id="abc",k8s_ns="prod",pod="api-7d9f"}` {
+		t.Fatalf("expected the batch to be unchanged, got %s", s)
+	}
+	if len(got[0].Samples) != 1 || got[0].Samples[0].Timestamp != 1000 {
+		t.Fatalf("unexpected samples %v", got[0].Samples)
+	}
+
+	if _, err := newWriter(config.WriterOption{Url: "http://127.0.0.1/write", RelabelConfigs: []*relabel.RelabelRule{
+		{SourceLabels: []string{"instance"}, TargetLabel: "shard", Action: "hashmod"},
+	}}); err == nil {
+		t.Fatal("expected hashmod without a modulus to be rejected")
+	}
+}
+
+func TestWriterRelabelGlobalLabels(t *testing.T) {
+	var received []*prompb.WriteRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := io.ReadAll(r.Body)
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Error(err)
+		}
+		req := &prompb.WriteRequest{}
+		if err := proto.Unmarshal(data, req); err != nil {
+			t.Error(err)
+		}
+		received = append(received, req)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	// the global rules run before the writers and see the global labels
+	if err := relabel.Init([]*relabel.RelabelRule{
+		{SourceLabels: []string{"region"}, TargetLabel: "zone", Replacement: "$1-a"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer relabel.Init(nil)
+
+	// region and agent_hostname are the global labels of the samples
+	samples := relabel.Process([]*types.Sample{
+		types.NewSample("", "cpu_usage_idle", 90, map[string]string{"cpu": "cpu-total", "region": "bj", "agent_hostname": "web01"}),
+		types.NewSample("", "cpu_usage_idle", 80, map[string]string{"cpu": "cpu0", "region": "bj", "agent_hostname": "web01"}),
+	})
+	items := make([]prompb.TimeSeries, 0, len(samples))
+	for _, s := range samples {
+		s.Timestamp = time.UnixMilli(1000)
+		items = append(items, *s.ConvertTimeSeries("ms"))
+	}
+
+	plain := newRelabelWriter(t, ts.URL)
+	// the other backend knows the hosts as host, and has no use for the region
+	renamed := newRelabelWriter(t, ts.URL,
+		&relabel.RelabelRule{SourceLabels: []string{"agent_hostname"}, TargetLabel: "host"},
+		&relabel.RelabelRule{Regex: "agent_hostname|region", Action: "labeldrop"},
+		&relabel.RelabelRule{SourceLabels: []string{"cpu"}, Regex: "cpu-total", Action: "keep"},
+	)
+	dropAll := newRelabelWriter(t, ts.URL, &relabel.RelabelRule{SourceLabels: []string{"__name__"}, Regex: "cpu_.*", Action: "drop"})
+	for _, w := range []Writer{plain, renamed, dropAll} {
+		if err := w.Write(items); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// nothing is posted once every series is dropped
+	if len(received) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(received))
+	}
+	if n := len(received[0].Timeseries); n != 2 {
+		t.Fatalf("expected the series of the writer without rules, got %d", n)
+	}
+	if s := seriesString(received[0].Timeseries[0].Labels); s != `cpu_usage_idle{agent_hostname="web01",cpu="cpu-total",region="bj",zone="bj-a"}` {
+		t.Fatalf("unexpected series of the writer without rules %s", s)
+	}
+	if n := len(received[1].Timeseries); n != 1 {
+		t.Fatalf("expected the cpu-total series only, got %d", n)
+	}
+	if s := seriesString(received[1].Timeseries[0].Labels); s != `cpu_usage_idle{cpu="cpu-total",host="web01",zone="bj-a"}` {
+		t.Fatalf("unexpected relabeled series %s", s)
+	}
+}
@@ -0,0 +1,244 @@
+package writer
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	writerQueueLength = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "writer_queue_length",
+		Help: "Number of samples of the failed batches waiting in the retry queue of the writer.",
+	}, []string{"writer"})
+	writerRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "writer_retries_total",
+		Help: "Number of retries of the failed batches of the writer.",
+	}, []string{"writer"})
+	writerDroppedSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "writer_dropped_samples_total",
+		Help: "Number of samples failed to be written and dropped by the writer, by reason.",
+	}, []string{"writer", "reason"})
+)
+
+func init() {
+	prometheus.MustRegister(writerQueueLength, writerRetriesTotal, writerDroppedSamplesTotal)
+}
+
+// the reasons of writer_dropped_samples_total
+const (
+	// the backend rejected the batch with a 4xx, it would be rejected again
+	dropRejected = "rejected"
+	// the batch failed and the retries are disabled
+	dropWriteFailed = "write_failed"
+	dropMaxRetries  = "max_retries"
+	// evicted by the newer batches beyond queue_max_samples
+	dropQueueFull = "queue_full"
+	// still queued once the final flush timed out
+	dropShutdown = "shutdown"
+)
+
+// retryBatch is a serialized remote write request failed to be written
+type retryBatch struct {
+	data    []byte
+	samples int
+	// the retries failed so far
+	attempts int
+	next     time.Time
+	removed  bool
+}
+
+// retryQueue retries the failed batches of a writer in the background, so the batch loop
+// shared by the writers is never blocked by a failing backend. The batches are retried in
+// order, with an exponential backoff until maxRetries, and the oldest batches are dropped
+// once the queue holds maxSamples samples
+type retryQueue struct {
+	mu      sync.Mutex
+	batches list.List
+	samples int
+
+	name       string
+	post       func(ctx context.Context, data []byte) error
+	maxRetries int
+	maxSamples int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newRetryQueue(name string, post func(ctx context.Context, data []byte) error, maxRetries, maxSamples int,
+	backoff, maxBackoff time.Duration) *retryQueue {
+	return &retryQueue{
+		name:       name,
+		post:       post,
+		maxRetries: maxRetries,
+		maxSamples: maxSamples,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+func (q *retryQueue) start() {
+	go q.loop()
+}
+
+// delay returns the wait before the retry following the failed attempts
+func (q *retryQueue) delay(attempts int) time.Duration {
+	d := q.backoff
+	for i := 0; i < attempts && d < q.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.maxBackoff {
+		d = q.maxBackoff
+	}
+	return d
+}
+
+// push queues a batch failed with a retriable error, the oldest batches are dropped to make room
+func (q *retryQueue) push(data []byte, samples int) {
+	if samples > q.maxSamples {
+		writerDroppedSamplesTotal.WithLabelValues(q.name, dropQueueFull).Add(float64(samples))
+		return
+	}
+
+	q.mu.Lock()
+	var evicted int
+	for q.samples+samples > q.maxSamples {
+		evicted += q.remove(q.batches.Front())
+	}
+	q.batches.PushBack(&retryBatch{data: data, samples: samples, next: time.Now().Add(q.delay(0))})
+	q.samples += samples
+	writerQueueLength.WithLabelValues(q.name).Set(float64(q.samples))
+	q.mu.Unlock()
+
+	if evicted > 0 {
+		log.Println("W! retry queue of writer", q.name, "is full, dropped", evicted, "samples, please increase queue_max_samples")
+		writerDroppedSamplesTotal.WithLabelValues(q.name, dropQueueFull).Add(float64(evicted))
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// remove removes the batch and returns its samples, the lock must be held
+func (q *retryQueue) remove(e *list.Element) int {
+	b := e.Value.(*retryBatch)
+	q.batches.Remove(e)
+	b.removed = true
+	q.samples -= b.samples
+	writerQueueLength.WithLabelValues(q.name).Set(float64(q.samples))
+	return b.samples
+}
+
+// queued returns the samples waiting in the queue
+func (q *retryQueue) queued() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.samples
+}
+
+func (q *retryQueue) loop() {
+	defer close(q.done)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		q.mu.Lock()
+		front := q.batches.Front()
+		var wait time.Duration
+		if front != nil {
+			wait = time.Until(front.Value.(*retryBatch).next)
+		}
+		q.mu.Unlock()
+
+		switch {
+		case front == nil:
+			select {
+			case <-q.wake:
+			case <-q.stop:
+				return
+			}
+		case wait > 0:
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-q.wake:
+				timer.Stop()
+			case <-q.stop:
+				timer.Stop()
+				return
+			}
+		default:
+			q.retry(front)
+		}
+	}
+}
+
+// retry sends the batch again, it is dropped once retried maxRetries times
+func (q *retryQueue) retry(e *list.Element) {
+	b := e.Value.(*retryBatch)
+	err := q.post(context.Background(), b.data)
+	writerRetriesTotal.WithLabelValues(q.name).Inc()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// evicted while being sent
+	if b.removed {
+		return
+	}
+	if err == nil {
+		q.remove(e)
+		return
+	}
+	b.attempts++
+	switch {
+	case !retriable(err):
+		log.Println("E! writer", q.name, "rejected a retried batch of", b.samples, "samples:", err)
+		writerDroppedSamplesTotal.WithLabelValues(q.name, dropRejected).Add(float64(q.remove(e)))
+	case b.attempts >= q.maxRetries:
+		log.Println("E! writer", q.name, "failed to write a batch of", b.samples, "samples after", b.attempts, "retries:", err)
+		writerDroppedSamplesTotal.WithLabelValues(q.name, dropMaxRetries).Add(float64(q.remove(e)))
+	default:
+		b.next = time.Now().Add(q.delay(b.attempts))
+	}
+}
+
+// flush stops the background retries and sends the queued batches once until the deadline
+// of the context, the batches left are dropped
+func (q *retryQueue) flush(ctx context.Context) {
+	close(q.stop)
+	<-q.done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var dropped int
+	for e := q.batches.Front(); e != nil; e = q.batches.Front() {
+		if ctx.Err() != nil {
+			dropped += q.remove(e)
+			continue
+		}
+		b := e.Value.(*retryBatch)
+		q.mu.Unlock()
+		err := q.post(ctx, b.data)
+		q.mu.Lock()
+		if err != nil {
+			dropped += q.remove(e)
+			continue
+		}
+		q.remove(e)
+	}
+	if dropped > 0 {
+		log.Println("W! writer", q.name, "dropped", dropped, "samples of the retry queue on shutdown")
+		writerDroppedSamplesTotal.WithLabelValues(q.name, dropShutdown).Add(float64(dropped))
+	}
+}
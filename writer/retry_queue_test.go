@@ -0,0 +1,265 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+
+	"flashcat.cloud/categraf/config"
+)
+
+// waitQueued waits until the queue holds the samples
+func waitQueued(t *testing.T, q *retryQueue, samples int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.queued() != samples {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued %d samples, want %d", q.queued(), samples)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// counterSince returns a function reading the increase of the counter since the call,
+// the counters are shared by the runs of the tests
+func counterSince(c prometheus.Collector) func() float64 {
+	before := testutil.ToFloat64(c)
+	return func() float64 {
+		return testutil.ToFloat64(c) - before
+	}
+}
+
+func TestRetriable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&statusError{code: 500}, true},
+		{&statusError{code: 503}, true},
+		{&statusError{code: 400}, false},
+		{&statusError{code: 429}, false},
+		{errors.New("dial tcp: connection refused"), true},
+	} {
+		if got := retriable(tc.err); got != tc.want {
+			t.Errorf("retriable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryQueueDelay(t *testing.T) {
+	q := newRetryQueue("delay", nil, 5, 10, time.Second, 5*time.Second)
+	for attempts, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := q.delay(attempts); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRetryQueueRetriesUntilSuccess(t *testing.T) {
+	var calls atomic.Int32
+	post := func(ctx context.Context, data []byte) error {
+		if calls.Add(1) < 3 {
+			return &statusError{code: 503}
+		}
+		return nil
+	}
+	retries := counterSince(writerRetriesTotal.WithLabelValues("success"))
+	q := newRetryQueue("success", post, 5, 100, time.Millisecond, time.Millisecond)
+	q.start()
+	defer q.flush(context.Background())
+
+	q.push([]byte("batch"), 10)
+	waitQueued(t, q, 0)
+
+	if n := calls.Load(); n != 3 {
+		t.Errorf("posted %d times, want 3", n)
+	}
+	if v := retries(); v != 3 {
+		t.Errorf("writer_retries_total = %v, want 3", v)
+	}
+	if v := testutil.ToFloat64(writerQueueLength.WithLabelValues("success")); v != 0 {
+		t.Errorf("writer_queue_length = %v, want 0", v)
+	}
+}
+
+func TestRetryQueueMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	post := func(ctx context.Context, data []byte) error {
+		calls.Add(1)
+		return errors.New("connection refused")
+	}
+	dropped := counterSince(writerDroppedSamplesTotal.WithLabelValues("max", dropMaxRetries))
+	q := newRetryQueue("max", post, 2, 100, time.Millisecond, time.Millisecond)
+	q.start()
+	defer q.flush(context.Background())
+
+	q.push([]byte("batch"), 10)
+	waitQueued(t, q, 0)
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("posted %d times, want 2", n)
+	}
+	if v := dropped(); v != 10 {
+		t.Errorf("dropped samples = %v, want 10", v)
+	}
+}
+
+func TestRetryQueueRejected(t *testing.T) {
+	post := func(ctx context.Context, data []byte) error {
+		return &statusError{code: 400}
+	}
+	retries := counterSince(writerRetriesTotal.WithLabelValues("rejected"))
+	dropped := counterSince(writerDroppedSamplesTotal.WithLabelValues("rejected", dropRejected))
+	q := newRetryQueue("rejected", post, 5, 100, time.Millisecond, time.Millisecond)
+	q.start()
+	defer q.flush(context.Background())
+
+	q.push([]byte("batch"), 10)
+	waitQueued(t, q, 0)
+
+	if v := retries(); v != 1 {
+		t.Errorf("writer_retries_total = %v, want 1", v)
+	}
+	if v := dropped(); v != 10 {
+		t.Errorf("dropped samples = %v, want 10", v)
+	}
+}
+
+func TestRetryQueueEvictsOldest(t *testing.T) {
+	// not started, the batches stay queued
+	q := newRetryQueue("full", nil, 5, 10, time.Hour, time.Hour)
+	dropped := counterSince(writerDroppedSamplesTotal.WithLabelValues("full", dropQueueFull))
+
+	q.push([]byte("first"), 4)
+	q.push([]byte("second"), 4)
+	q.push([]byte("third"), 4)
+	if n := q.queued(); n != 8 {
+		t.Fatalf("queued %d samples, want 8", n)
+	}
+	if data := string(q.batches.Front().Value.(*retryBatch).data); data != "second" {
+		t.Errorf("oldest batch %q, want second", data)
+	}
+
+	// a batch larger than the queue is dropped at once
+	q.push([]byte("huge"), 11)
+	if n := q.queued(); n != 8 {
+		t.Errorf("queued %d samples, want 8", n)
+	}
+	if v := dropped(); v != 15 {
+		t.Errorf("dropped samples = %v, want 15", v)
+	}
+	if v := testutil.ToFloat64(writerQueueLength.WithLabelValues("full")); v != 8 {
+		t.Errorf("writer_queue_length = %v, want 8", v)
+	}
+}
+
+func TestRetryQueueFlush(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		posted []string
+	)
+	post := func(ctx context.Context, data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		posted = append(posted, string(data))
+		return nil
+	}
+	// the backoff outlives the test, only the flush sends the batches
+	q := newRetryQueue("flush", post, 5, 100, time.Hour, time.Hour)
+	q.start()
+	q.push([]byte("first"), 1)
+	q.push([]byte("second"), 2)
+	q.flush(context.Background())
+
+	if len(posted) != 2 || posted[0] != "first" || posted[1] != "second" {
+		t.Errorf("posted %v, want [first second]", posted)
+	}
+	if n := q.queued(); n != 0 {
+		t.Errorf("queued %d samples, want 0", n)
+	}
+}
+
+func TestRetryQueueFlushTimeout(t *testing.T) {
+	post := func(ctx context.Context, data []byte) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	dropped := counterSince(writerDroppedSamplesTotal.WithLabelValues("timeout", dropShutdown))
+	q := newRetryQueue("timeout", post, 5, 100, time.Hour, time.Hour)
+	q.start()
+	q.push([]byte("first"), 1)
+	q.push([]byte("second"), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	q.flush(ctx)
+
+	if v := dropped(); v != 3 {
+		t.Errorf("dropped samples = %v, want 3", v)
+	}
+}
+
+func TestWriterRetryQueue(t *testing.T) {
+	var (
+		calls  atomic.Int32
+		status atomic.Int32
+	)
+	status.Store(http.StatusServiceUnavailable)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer backend.Close()
+
+	w, err := newWriter(config.WriterOption{
+		Url:          backend.URL,
+		Timeout:      5000,
+		DialTimeout:  1000,
+		MaxRetries:   3,
+		RetryBackoff: config.Duration(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := redactWriterURL(backend.URL)
+	items := []prompb.TimeSeries{*newSeries(1000, "__name__", "up"), *newSeries(1000, "__name__", "down")}
+
+	// the 5xx is queued for the retries
+	if err := w.Write(items); err == nil {
+		t.Fatal("expected the error of the 503")
+	}
+	if n := w.retry.queued(); n != 2 {
+		t.Fatalf("queued %d samples, want 2", n)
+	}
+
+	// the 4xx is dropped at once
+	status.Store(http.StatusBadRequest)
+	if err := w.Write(items); err == nil {
+		t.Fatal("expected the error of the 400")
+	}
+	if n := w.retry.queued(); n != 2 {
+		t.Errorf("queued %d samples, want 2", n)
+	}
+	if v := testutil.ToFloat64(writerDroppedSamplesTotal.WithLabelValues(name, dropRejected)); v != 2 {
+		t.Errorf("rejected samples = %v, want 2", v)
+	}
+
+	// the queued batch is sent once more on shutdown
+	status.Store(http.StatusOK)
+	w.retry.flush(context.Background())
+	if n := calls.Load(); n != 3 {
+		t.Errorf("backend called %d times, want 3", n)
+	}
+	if n := w.retry.queued(); n != 0 {
+		t.Errorf("queued %d samples, want 0", n)
+	}
+}
@@ -0,0 +1,147 @@
+package writer
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// the reasons of the sanitization of the series
+const (
+	sanitizeTooManyLabels = "too_many_labels"
+	sanitizeValueTooLong  = "value_too_long"
+	sanitizeInvalidUTF8   = "invalid_utf8"
+	sanitizeNewline       = "newline"
+	sanitizeLabelName     = "label_name"
+)
+
+var sanitizedSeriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "writer_sanitized_series_total",
+	Help: "Number of series modified by the writer sanitization, by input and reason.",
+}, []string{"input", "reason"})
+
+func init() {
+	prometheus.MustRegister(sanitizedSeriesTotal)
+}
+
+// sanitizer fixes the series the backends reject: the label names out of the prometheus
+// charset, the values with invalid utf-8 or newlines, the too long values and the series
+// with too many labels
+type sanitizer struct {
+	// labels of a series besides __name__, 0 for unlimited
+	maxLabels int
+	// bytes of a label value, 0 for unlimited
+	maxValueLength int
+}
+
+func newSanitizer(maxLabels, maxValueLength int) *sanitizer {
+	return &sanitizer{maxLabels: maxLabels, maxValueLength: maxValueLength}
+}
+
+// sanitize fixes the series in place, counting each fix once per series and reason
+func (s *sanitizer) sanitize(input string, items []*prompb.TimeSeries) {
+	reasons := make(map[string]struct{}, 4)
+	for _, item := range items {
+		s.sanitizeSeries(item, reasons)
+		for reason := range reasons {
+			sanitizedSeriesTotal.WithLabelValues(input, reason).Inc()
+			delete(reasons, reason)
+		}
+	}
+}
+
+func (s *sanitizer) sanitizeSeries(item *prompb.TimeSeries, reasons map[string]struct{}) {
+	renamed := false
+	for i := range item.Labels {
+		l := &item.Labels[i]
+		if name, changed := normalizeLabelName(l.Name, l.Name == model.MetricNameLabel); changed {
+			l.Name = name
+			renamed = true
+			reasons[sanitizeLabelName] = struct{}{}
+		}
+		if l.Name == model.MetricNameLabel {
+			continue
+		}
+		if !utf8.ValidString(l.Value) {
+			l.Value = strings.ToValidUTF8(l.Value, string(utf8.RuneError))
+			reasons[sanitizeInvalidUTF8] = struct{}{}
+		}
+		if strings.ContainsAny(l.Value, "\r\n") {
+			l.Value = newlineReplacer.Replace(l.Value)
+			reasons[sanitizeNewline] = struct{}{}
+		}
+		if s.maxValueLength > 0 && len(l.Value) > s.maxValueLength {
+			l.Value = truncateUTF8(l.Value, s.maxValueLength)
+			reasons[sanitizeValueTooLong] = struct{}{}
+		}
+	}
+
+	if !renamed && (s.maxLabels <= 0 || len(item.Labels) <= s.maxLabels+1) {
+		return
+	}
+	// the labels are sorted, so that the same labels are kept whatever the order of the
+	// sample labels, the first of the labels renamed to the same name is kept
+	sort.SliceStable(item.Labels, func(i, j int) bool {
+		return item.Labels[i].Name < item.Labels[j].Name
+	})
+	kept := item.Labels[:0]
+	others := 0
+	for i, l := range item.Labels {
+		if i > 0 && l.Name == item.Labels[i-1].Name {
+			continue
+		}
+		if l.Name != model.MetricNameLabel {
+			if s.maxLabels > 0 && others >= s.maxLabels {
+				reasons[sanitizeTooManyLabels] = struct{}{}
+				continue
+			}
+			others++
+		}
+		kept = append(kept, l)
+	}
+	item.Labels = kept
+}
+
+var newlineReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// normalizeLabelName replaces the characters out of [a-zA-Z_][a-zA-Z0-9_]* by _, the colons
+// are valid in the metric names
+func normalizeLabelName(name string, metric bool) (string, bool) {
+	valid := func(i int, c byte) bool {
+		return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9') || (metric && c == ':')
+	}
+	changed := name == ""
+	for i := 0; i < len(name) && !changed; i++ {
+		changed = !valid(i, name[i])
+	}
+	if !changed {
+		return name, false
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(name) + 1)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		sb.WriteByte('_')
+	}
+	for _, r := range name {
+		if r < utf8.RuneSelf && valid(1, byte(r)) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String(), true
+}
+
+// truncateUTF8 truncates s to at most n bytes without splitting a rune
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,44 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestSanitizer(t *testing.T) {
+	s := newSanitizer(3, 8)
+	items := []*prompb.TimeSeries{
+		newSeries(1000, "__name__", "up", "job", "node", "instance", "a"),
+		newSeries(1000, "__name__", "http_requests", "path", "/café/menu", "status", "2\n00", "host", "h1\xff", "z", "dropped", "app", "web"),
+		newSeries(1000, "__name__", "up", "kubernetes.io/name", "x", "1zone", "b", "kubernetes_io_name", "y"),
+	}
+	reasons := []string{sanitizeTooManyLabels, sanitizeValueTooLong, sanitizeInvalidUTF8, sanitizeNewline, sanitizeLabelName}
+	before := make(map[string]float64, len(reasons))
+	for _, reason := range reasons {
+		before[reason] = testutil.ToFloat64(sanitizedSeriesTotal.WithLabelValues("prometheus", reason))
+	}
+	s.sanitize("prometheus", items)
+
+	want := [][]prompb.Label{
+		// untouched
+		{{Name: "__name__", Value: "up"}, {Name: "job", Value: "node"}, {Name: "instance", Value: "a"}},
+		// the labels beyond 3 are dropped by name order
+		{{Name: "__name__", Value: "http_requests"}, {Name: "app", Value: "web"}, {Name: "host", Value: "h1�"}, {Name: "path", Value: "/café/m"}},
+		// normalized names, the first of the same names is kept
+		{{Name: "_1zone", Value: "b"}, {Name: "__name__", Value: "up"}, {Name: "kubernetes_io_name", Value: "x"}},
+	}
+	for i, item := range items {
+		if !reflect.DeepEqual(item.Labels, want[i]) {
+			t.Errorf("series %d: got %v, want %v", i, item.Labels, want[i])
+		}
+	}
+
+	for _, reason := range reasons {
+		if n := testutil.ToFloat64(sanitizedSeriesTotal.WithLabelValues("prometheus", reason)) - before[reason]; n != 1 {
+			t.Errorf("expected 1 series sanitized for %s, got %v", reason, n)
+		}
+	}
+}
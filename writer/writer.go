@@ -3,6 +3,7 @@ package writer
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -15,16 +16,48 @@ import (
 	"github.com/prometheus/client_golang/api"
 	"github.com/prometheus/prometheus/prompb"
 
+	"flashcat.cloud/categraf/agent/relabel"
 	"flashcat.cloud/categraf/config"
+	pkgrelabel "flashcat.cloud/categraf/pkg/relabel"
 )
 
 type Writer struct {
 	Opts   config.WriterOption
 	Client api.Client
+
+	// the compiled relabel_configs of the writer
+	relabelConfigs []*pkgrelabel.Config
+	// the failed batches retried in the background, nil when max_retries is 0
+	retry *retryQueue
+}
+
+// statusError is a remote write request answered with an error status code
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("push data with remote write request got status code: %v, response body: %s", e.code, e.body)
+}
+
+// retriable reports whether a failed request may succeed later: the network errors and
+// the 5xx, the other status codes are rejections of the batch
+func retriable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	return true
 }
 
 // newWriter creates a new Writer from config.WriterOption
 func newWriter(opt config.WriterOption) (Writer, error) {
+	relabelConfigs, err := relabel.Compile(opt.RelabelConfigs)
+	if err != nil {
+		return Writer{}, fmt.Errorf("relabel_configs of writer %s: %v", opt.Url, err)
+	}
+
 	tr := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -50,15 +83,37 @@ func newWriter(opt config.WriterOption) (Writer, error) {
 		return Writer{}, err
 	}
 
-	return Writer{
-		Opts:   opt,
-		Client: cli,
-	}, nil
+	w := Writer{
+		Opts:           opt,
+		Client:         cli,
+		relabelConfigs: relabelConfigs,
+	}
+	if opt.MaxRetries > 0 {
+		if opt.RetryBackoff <= 0 {
+			opt.RetryBackoff = config.Duration(time.Second)
+		}
+		if opt.RetryMaxBackoff < opt.RetryBackoff {
+			opt.RetryMaxBackoff = config.Duration(time.Minute)
+			if opt.RetryMaxBackoff < opt.RetryBackoff {
+				opt.RetryMaxBackoff = opt.RetryBackoff
+			}
+		}
+		if opt.QueueMaxSamples <= 0 {
+			opt.QueueMaxSamples = 100000
+		}
+		w.Opts = opt
+		w.retry = newRetryQueue(redactWriterURL(opt.Url), w.post, opt.MaxRetries, opt.QueueMaxSamples,
+			time.Duration(opt.RetryBackoff), time.Duration(opt.RetryMaxBackoff))
+		w.retry.start()
+	}
+	return w, nil
 }
 
-func (w Writer) Write(items []prompb.TimeSeries) {
+// Write posts the series to the writer, the errors are logged and returned
+func (w Writer) Write(items []prompb.TimeSeries) error {
+	items = w.relabel(items)
 	if len(items) == 0 {
-		return
+		return nil
 	}
 
 	req := &prompb.WriteRequest{
@@ -68,16 +123,36 @@ func (w Writer) Write(items []prompb.TimeSeries) {
 	data, err := proto.Marshal(req)
 	if err != nil {
 		log.Println("W! marshal prom data to proto got error:", err, "data:", items)
-		return
+		return err
 	}
 
-	if err := w.post(snappy.Encode(nil, data)); err != nil {
+	compressed := snappy.Encode(nil, data)
+	if err := w.post(context.Background(), compressed); err != nil {
 		log.Println("W! post to", w.Opts.Url, "got error:", err)
 		log.Println("W! example timeseries:", items[0].String())
+		w.failed(compressed, items, err)
+		return err
+	}
+	return nil
+}
+
+// failed queues the failed batch for the retries, or counts its samples as dropped
+func (w Writer) failed(data []byte, items []prompb.TimeSeries, err error) {
+	samples := 0
+	for i := range items {
+		samples += len(items[i].Samples)
+	}
+	switch {
+	case !retriable(err):
+		writerDroppedSamplesTotal.WithLabelValues(redactWriterURL(w.Opts.Url), dropRejected).Add(float64(samples))
+	case w.retry != nil:
+		w.retry.push(data, samples)
+	default:
+		writerDroppedSamplesTotal.WithLabelValues(redactWriterURL(w.Opts.Url), dropWriteFailed).Add(float64(samples))
 	}
 }
 
-func (w Writer) post(req []byte) error {
+func (w Writer) post(ctx context.Context, req []byte) error {
 	httpReq, err := http.NewRequest("POST", w.Opts.Url, bytes.NewReader(req))
 	if err != nil {
 		log.Println("W! create remote write request got error:", err)
@@ -100,15 +175,14 @@ func (w Writer) post(req []byte) error {
 		httpReq.SetBasicAuth(w.Opts.BasicAuthUser, w.Opts.BasicAuthPass)
 	}
 
-	resp, body, err := w.Client.Do(context.Background(), httpReq)
+	resp, body, err := w.Client.Do(ctx, httpReq)
 	if err != nil {
 		log.Println("W! push data with remote write request got error:", err, "response body:", string(body))
 		return err
 	}
 
 	if resp.StatusCode >= 400 {
-		err = fmt.Errorf("push data with remote write request got status code: %v, response body: %s", resp.StatusCode, string(body))
-		return err
+		return &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
 	return nil
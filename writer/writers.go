@@ -1,6 +1,7 @@
 package writer
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sort"
@@ -10,6 +11,8 @@ import (
 
 	"github.com/prometheus/prometheus/prompb"
 
+	"flashcat.cloud/categraf/agent/alerting"
+	"flashcat.cloud/categraf/agent/relabel"
 	"flashcat.cloud/categraf/config"
 	"flashcat.cloud/categraf/types"
 )
@@ -17,8 +20,15 @@ import (
 // Writers manage all writers and metric queue
 type (
 	Writers struct {
-		writerMap map[string]Writer
-		queue     *types.SafeListLimited[*prompb.TimeSeries]
+		writerMap   map[string]Writer
+		outputs     map[string]*outputQueue
+		queue       *types.SafeListLimited[*prompb.TimeSeries]
+		dedup       *deduplicator
+		sanitizer   *sanitizer
+		cardinality *cardinalityGuard
+		// the writers of writer_opt.compare_writers by url, and their comparison
+		compareIndex map[string]int
+		compare      *comparison
 		sync.Mutex
 
 		Snapshot
@@ -46,10 +56,45 @@ func InitWriters() error {
 		writerMap[opt.Url] = writer
 	}
 
+	outputMap, err := initOutputs()
+	if err != nil {
+		return err
+	}
+
+	outputQueues := make(map[string]*outputQueue, len(outputMap))
+	for name, output := range outputMap {
+		outputQueues[name] = newOutputQueue(name, output, config.Config.WriterOpt.OutputQueueSize)
+		outputQueues[name].start()
+	}
+
 	writers = &Writers{
 		writerMap: writerMap,
+		outputs:   outputQueues,
 		queue:     types.NewSafeListLimited[*prompb.TimeSeries](config.Config.WriterOpt.ChanSize),
 	}
+	if config.Config.WriterOpt.Sanitize {
+		writers.sanitizer = newSanitizer(config.Config.WriterOpt.SanitizeMaxLabels, config.Config.WriterOpt.SanitizeMaxLabelValueLength)
+	}
+	if config.Config.WriterOpt.MaxCardinality > 0 {
+		writers.cardinality = newCardinalityGuard(config.Config.WriterOpt.MaxCardinality, time.Duration(config.Config.WriterOpt.CardinalityTTL), config.Config.WriterOpt.CardinalityMaxMetrics)
+	}
+	if config.Config.WriterOpt.Dedup {
+		writers.dedup = newDeduplicator(time.Duration(config.Config.WriterOpt.DedupWindow), config.Config.WriterOpt.DedupMaxSeries)
+	}
+	if urls := config.Config.WriterOpt.CompareWriters; len(urls) > 0 {
+		if len(urls) != 2 || urls[0] == urls[1] {
+			return fmt.Errorf("compare_writers must hold the urls of two writers, got %v", urls)
+		}
+		writers.compareIndex = make(map[string]int, len(urls))
+		for i, u := range urls {
+			if _, has := writerMap[u]; !has {
+				return fmt.Errorf("writer %s of compare_writers is not configured", u)
+			}
+			writers.compareIndex[u] = i
+		}
+		writers.compare = newComparison(urls, config.Config.WriterOpt.CompareMaxSeries)
+		log.Println("I! comparing the deliveries of the writers", redactWriterURL(urls[0]), "and", redactWriterURL(urls[1]))
+	}
 
 	go writers.LoopRead()
 	return nil
@@ -72,18 +117,56 @@ func (ws *Writers) LoopRead() {
 	}
 }
 
-// WriteSamples convert samples to []prompb.TimeSeries and batch write to queue
-func WriteSamples(samples []*types.Sample) {
-	if len(samples) == 0 {
+// Shutdown flushes the retry queues of the writers once and waits for the outputs to write
+// their queued batches, the samples still queued when the timeout expires are dropped
+func Shutdown(timeout time.Duration) {
+	if writers == nil {
 		return
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, w := range writers.writerMap {
+		if w.retry == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(q *retryQueue) {
+			defer wg.Done()
+			q.flush(ctx)
+		}(w.retry)
+	}
+	for _, q := range writers.outputs {
+		wg.Add(1)
+		go func(q *outputQueue) {
+			defer wg.Done()
+			q.close(ctx)
+		}(q)
+	}
+	wg.Wait()
+}
+
+// WriteSamples convert samples of the input to []prompb.TimeSeries and batch write to queue
+func WriteSamples(input string, samples []*types.Sample) {
+	PushSamples(input, samples)
+}
+
+// PushSamples is WriteSamples returning false when the queue is full and the samples are
+// dropped, so that the receivers pushed to can apply backpressure to their clients
+func PushSamples(input string, samples []*types.Sample) bool {
+	if len(samples) == 0 {
+		return true
+	}
+	samples = relabel.Process(samples)
 	if config.Config.TestMode {
 		printTestMetrics(samples)
-		return
+		return true
 	}
 	if config.Config.DebugMode {
 		printTestMetrics(samples)
 	}
+	alerting.Evaluate(samples)
 
 	items := make([]*prompb.TimeSeries, 0, len(samples))
 	for _, sample := range samples {
@@ -93,12 +176,22 @@ func WriteSamples(samples []*types.Sample) {
 		}
 		items = append(items, item)
 	}
+	if writers.sanitizer != nil {
+		writers.sanitizer.sanitize(input, items)
+	}
+	if writers.cardinality != nil {
+		items = writers.cardinality.filter(items, time.Now())
+	}
+	if writers.dedup != nil {
+		items = writers.dedup.filter(input, items, time.Now())
+	}
 	success := writers.queue.PushFrontN(items)
 	l := writers.queue.Len()
 	if !success {
 		log.Printf("E! write %d samples failed, please increase queue size(%d)", len(items), l)
 	}
 	go snapshot(uint64(len(items)), uint64(l), success)
+	return success
 }
 
 func snapshot(count, size uint64, success bool) {
@@ -121,20 +214,35 @@ func QueueMetrics() *Snapshot {
 
 // WriteTimeSeries write prompb.TimeSeries to all writers
 func WriteTimeSeries(timeSeries []prompb.TimeSeries) {
+	timeSeries = dedupBatch(timeSeries)
 	if len(timeSeries) == 0 {
 		return
 	}
 
 	now := time.Now()
+	var cycle *compareCycle
+	if writers.compare != nil {
+		cycle = writers.compare.begin(timeSeries)
+	}
 	wg := sync.WaitGroup{}
 	for key := range writers.writerMap {
 		wg.Add(1)
 		go func(key string) {
 			defer wg.Done()
-			writers.writerMap[key].Write(timeSeries)
+			err := writers.writerMap[key].Write(timeSeries)
+			if i, has := writers.compareIndex[key]; has && cycle != nil {
+				cycle.done(i, err)
+			}
 		}(key)
 	}
+	// the outputs write in the background, only the writers are waited for
+	for _, q := range writers.outputs {
+		q.push(timeSeries)
+	}
 	wg.Wait()
+	if cycle != nil {
+		writers.compare.end(cycle)
+	}
 	if config.Config.DebugMode {
 		log.Println("D!, write", len(timeSeries), "time series to all writers, cost:",
 			time.Since(now).Milliseconds(), "ms")